@@ -7,6 +7,10 @@ package main
 import (
 	"zeroplex/pkg/app"
 	"zeroplex/pkg/cli"
+	"zeroplex/pkg/exitcode"
+
+	"fmt"
+	"os"
 )
 
 // Version information
@@ -19,5 +23,9 @@ func main() {
 	// Parse flags ONCE at program start
 	cli.ParseFlags()
 	app.Version = Version
-	app.New().Run()
+	app.BuildTime = BuildTime
+	if err := app.New().Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitcode.Code(err))
+	}
 }
@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Reference Quick Settings applet for the zeroplex D-Bus interface. It reads the daemon state once
+// when com.nfrastack.ZeroPlex appears and then follows the StateChanged signal; nothing is polled.
+
+import Gio from 'gi://Gio';
+import GObject from 'gi://GObject';
+
+import {Extension} from 'resource:///org/gnome/shell/extensions/extension.js';
+import * as Main from 'resource:///org/gnome/shell/ui/main.js';
+import {QuickToggle, SystemIndicator} from 'resource:///org/gnome/shell/ui/quickSettings.js';
+
+const BUS_NAME = 'com.nfrastack.ZeroPlex';
+const OBJECT_PATH = '/com/nfrastack/ZeroPlex';
+const INTERFACE = 'com.nfrastack.ZeroPlex1';
+
+const ZeroPlexIndicator = GObject.registerClass(
+class ZeroPlexIndicator extends SystemIndicator {
+    constructor() {
+        super();
+
+        this._icon = this._addIndicator();
+        this._icon.iconName = 'network-vpn-symbolic';
+        this._icon.visible = false;
+
+        this._toggle = new QuickToggle({title: 'ZeroTier DNS', iconName: 'network-vpn-symbolic'});
+        this._toggle.connect('clicked', () => this._call(this._toggle.checked ? 'Restore' : 'Apply'));
+        this.quickSettingsItems.push(this._toggle);
+
+        this._signalId = Gio.DBus.system.signal_subscribe(BUS_NAME, INTERFACE, 'StateChanged', OBJECT_PATH,
+            null, Gio.DBusSignalFlags.NONE, (_conn, _sender, _path, _iface, _signal, params) => {
+                const [summary, snapshot] = params.deepUnpack();
+                this._update(summary, JSON.parse(snapshot));
+            });
+        this._watchId = Gio.bus_watch_name(Gio.BusType.SYSTEM, BUS_NAME, Gio.BusNameWatcherFlags.NONE,
+            () => this._refresh(),
+            () => this._update('ZeroTier DNS: daemon unavailable', null));
+    }
+
+    _call(method, callback) {
+        Gio.DBus.system.call(BUS_NAME, OBJECT_PATH, INTERFACE, method, null, null,
+            Gio.DBusCallFlags.NONE, -1, null, (conn, res) => {
+                try {
+                    const reply = conn.call_finish(res);
+                    callback?.(reply.deepUnpack());
+                } catch (e) {
+                    console.warn(`zeroplex: ${method} failed: ${e.message}`);
+                }
+            });
+    }
+
+    _refresh() {
+        this._call('Summary', ([summary]) => {
+            this._call('Status', ([snapshot]) => this._update(summary, JSON.parse(snapshot)));
+        });
+    }
+
+    _update(summary, snapshot) {
+        this._toggle.subtitle = summary.replace(/^ZeroTier DNS: /, '');
+        this._toggle.checked = snapshot !== null && !snapshot.paused &&
+            snapshot.networks.some(network => network.managed);
+        this._toggle.reactive = snapshot !== null;
+        this._icon.visible = this._toggle.checked;
+    }
+
+    destroy() {
+        Gio.DBus.system.signal_unsubscribe(this._signalId);
+        Gio.bus_unwatch_name(this._watchId);
+        this.quickSettingsItems.forEach(item => item.destroy());
+        super.destroy();
+    }
+});
+
+export default class ZeroPlexExtension extends Extension {
+    enable() {
+        this._indicator = new ZeroPlexIndicator();
+        Main.panel.statusArea.quickSettings.addExternalIndicator(this._indicator);
+    }
+
+    disable() {
+        this._indicator.destroy();
+        this._indicator = null;
+    }
+}
@@ -42,7 +42,17 @@
               "-X main.Version=${version}"
             ];
 
-            vendorHash = "sha256-QYYExOIcFBaaYIq6miZaOdQJnd4p/rv2fTULRACAQWI=";
+            vendorHash = "sha256-/6a9YcMS9d9dkLJZf/euyMa6aSkjd2SbFidHLKDSsOI=";
+
+            nativeBuildInputs = [ pkgs.installShellFiles ];
+            postInstall = ''
+              $out/bin/zeroplex docs man > zeroplex.8
+              installManPage zeroplex.8
+              installShellCompletion --cmd zeroplex \
+                --bash <($out/bin/zeroplex completion bash) \
+                --zsh <($out/bin/zeroplex completion zsh) \
+                --fish <($out/bin/zeroplex completion fish)
+            '';
           };
         });
 
@@ -409,6 +419,8 @@ ${lib.generators.toYAML {} profile}
                       bannerArg
                     ]);
                   in args;
+                # SIGHUP reloads the configuration without restarting the daemon
+                ExecReload = "${pkgs.coreutils}/bin/kill -HUP $MAINPID";
                 User = "root";
                 Group = "root";
                 RestartSec = "10s";
@@ -3,14 +3,23 @@ module zeroplex
 go 1.21.0
 
 require (
+	filippo.io/age v1.2.1
+	github.com/BurntSushi/toml v1.4.0
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/godbus/dbus/v5 v5.1.0
 	github.com/vishvananda/netlink v1.3.1
+	github.com/vishvananda/netns v0.0.5
 	github.com/zerotier/go-zerotier-one v0.1.1
-	golang.org/x/sys v0.10.0
+	golang.org/x/sys v0.21.0
+	google.golang.org/grpc v1.65.0
+	google.golang.org/protobuf v1.34.2
 	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/deepmap/oapi-codegen v1.9.0 // indirect
-	github.com/vishvananda/netns v0.0.5 // indirect
+	golang.org/x/crypto v0.24.0 // indirect
+	golang.org/x/net v0.25.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240528184218-531527333157 // indirect
 )
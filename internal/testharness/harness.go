@@ -0,0 +1,624 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build linux
+
+// Package testharness builds a throwaway environment for exercising the full
+// runner -> mode -> dns pipeline: a private network namespace with dummy zt
+// interfaces, a mocked ZeroTier service API, and fake resolvectl/systemctl/
+// networkctl binaries that record what they were asked to do.
+package testharness
+
+import (
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/modes"
+	"zeroplex/pkg/runner"
+	"zeroplex/pkg/state"
+
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+// Token is the API token the mock ZeroTier API expects
+const Token = "zeroplex-integration-token"
+
+const fakeResolvectl = `#!/bin/sh
+# Fake resolvectl: keeps per-link state in files under $ZEROPLEX_FAKE_STATE
+state="${ZEROPLEX_FAKE_STATE:?}"
+echo "resolvectl $*" >> "$state/calls.log"
+cmd="$1"
+link="$2"
+if [ $# -lt 2 ]; then
+  printf 'Global: %s\n' "$(cat "$state/global.$cmd" 2>/dev/null)"
+  for f in "$state"/*."$cmd"; do
+    [ -e "$f" ] || continue
+    l="$(basename "$f" ".$cmd")"
+    case "$l" in *[!0-9]*) continue ;; esac
+    printf 'Link %s (%s): %s\n' "$l" "$(cat "$state/$l.name" 2>/dev/null || echo fake)" "$(cat "$f")"
+  done
+  exit 0
+fi
+shift 2
+case "$cmd" in
+  dns|domain|mdns|dnsovertls|dnssec|nta|llmnr)
+    if [ $# -gt 0 ]; then
+      if [ -e "$state/set-fail" ]; then
+        echo "Failed to set DNS configuration: Connection timed out" >&2
+        exit 1
+      fi
+      echo "$*" > "$state/$link.$cmd"
+    else
+      printf 'Link %s (fake): %s\n' "$link" "$(cat "$state/$link.$cmd" 2>/dev/null)"
+    fi
+    ;;
+  revert)
+    rm -f "$state/$link".*
+    ;;
+  query)
+    [ -e "$state/query-fail" ] && exit 1
+    exit 0
+    ;;
+  *)
+    echo "fake resolvectl: unsupported command $cmd" >&2
+    exit 1
+    ;;
+esac
+`
+
+const fakeSystemctl = `#!/bin/sh
+# Fake systemctl: every unit is present and active
+echo "systemctl $*" >> "${ZEROPLEX_FAKE_STATE:?}/calls.log"
+case "$1" in
+  is-active) echo active ;;
+esac
+exit 0
+`
+
+const fakeNmcli = `#!/bin/sh
+# Fake nmcli: keeps the runtime settings of each device in files under $ZEROPLEX_FAKE_STATE
+state="${ZEROPLEX_FAKE_STATE:?}"
+echo "nmcli $*" >> "$state/calls.log"
+[ "$1" = "-t" ] && shift 3
+[ "$1" = "device" ] || { echo "fake nmcli: unsupported object $1" >&2; exit 1; }
+iface="$3"
+case "$2" in
+  show)
+    for family in 4 6; do
+      i=1
+      for v in $(tr ',' ' ' < "$state/nm.$iface.ipv$family.dns" 2>/dev/null); do
+        echo "IP$family.DNS[$i]:$(echo "$v" | sed 's/:/\\:/g')"; i=$((i+1))
+      done
+    done
+    i=1
+    for v in $(tr ',' ' ' < "$state/nm.$iface.ipv4.dns-search" 2>/dev/null); do
+      echo "IP4.DOMAIN[$i]:$v"; i=$((i+1))
+    done
+    ;;
+  modify)
+    shift 3
+    while [ $# -ge 2 ]; do
+      echo "$2" > "$state/nm.$iface.$1"
+      shift 2
+    done
+    ;;
+  reapply)
+    rm -f "$state/nm.$iface".*
+    ;;
+  *)
+    echo "fake nmcli: unsupported command $2" >&2
+    exit 1
+    ;;
+esac
+`
+
+const fakeResolvconf = `#!/bin/sh
+# Fake openresolv: keeps the entry of each interface in a file under $ZEROPLEX_FAKE_STATE
+state="${ZEROPLEX_FAKE_STATE:?}"
+echo "resolvconf $*" >> "$state/calls.log"
+case "$1" in
+  -a) cat > "$state/resolvconf.$2" ;;
+  -d) rm -f "$state/resolvconf.$2" ;;
+  -l) cat "$state/resolvconf.$2" 2>/dev/null ;;
+  *)
+    echo "fake resolvconf: unsupported option $1" >&2
+    exit 1
+    ;;
+esac
+exit 0
+`
+
+const fakeUnboundControl = `#!/bin/sh
+# Fake unbound-control: keeps each forward zone in a file under $ZEROPLEX_FAKE_STATE
+state="${ZEROPLEX_FAKE_STATE:?}"
+echo "unbound-control $*" >> "$state/calls.log"
+cmd="$1"; shift
+[ "$1" = "+i" ] && shift
+case "$cmd" in
+  forward_add) zone="$1"; shift; echo "$zone IN forward +i $*" > "$state/unbound.$zone" ;;
+  forward_remove) rm -f "$state/unbound.$1" ;;
+  list_forwards) cat "$state"/unbound.* 2>/dev/null ;;
+  local_zone|local_zone_remove|reload) ;;
+  *)
+    echo "fake unbound-control: unsupported command $cmd" >&2
+    exit 1
+    ;;
+esac
+exit 0
+`
+
+const fakeUci = `#!/bin/sh
+# Fake uci: keeps each list option as lines of a file under $ZEROPLEX_FAKE_STATE
+state="${ZEROPLEX_FAKE_STATE:?}"
+echo "uci $*" >> "$state/calls.log"
+[ "$1" = "-q" ] && shift
+cmd="$1"; shift
+case "$cmd" in
+  get)
+    [ -s "$state/uci.$1" ] || exit 1
+    tr '\n' ' ' < "$state/uci.$1" | sed 's/ $//'; echo
+    ;;
+  add_list) key="${1%%=*}"; echo "${1#*=}" >> "$state/uci.$key" ;;
+  del_list)
+    key="${1%%=*}"; value="${1#*=}"
+    grep -vxF "$value" "$state/uci.$key" > "$state/uci.tmp"; mv "$state/uci.tmp" "$state/uci.$key"
+    ;;
+  commit) ;;
+  *)
+    echo "fake uci: unsupported command $cmd" >&2
+    exit 1
+    ;;
+esac
+exit 0
+`
+
+const fakeRecorder = `#!/bin/sh
+# Fake command that only records its invocation
+echo "$(basename "$0") $*" >> "${ZEROPLEX_FAKE_STATE:?}/calls.log"
+exit 0
+`
+
+// Harness is a single isolated test environment
+type Harness struct {
+	T           testing.TB
+	Dir         string
+	StateDir    string
+	NetworkdDir string
+	RuntimeDir  string // stands in for /run/systemd/network
+	StatePath   string
+	ResolvConf  string
+	ResolverDir string
+	DisableFile string // stands in for /etc/zeroplex.disable, not created
+	API         *MockAPI
+}
+
+// New creates the environment and registers cleanup with t. The calling goroutine is
+// locked to its OS thread and moved into a fresh network namespace; tests that need
+// root or namespace support are skipped when they are unavailable.
+func New(t testing.TB) *Harness {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("integration tests require root (network namespaces)")
+	}
+
+	dir := t.TempDir()
+	h := &Harness{
+		T:           t,
+		Dir:         dir,
+		StateDir:    filepath.Join(dir, "state"),
+		NetworkdDir: filepath.Join(dir, "network"),
+		RuntimeDir:  filepath.Join(dir, "run", "network"),
+		StatePath:   filepath.Join(dir, "zeroplex", "state.json"),
+		ResolvConf:  filepath.Join(dir, "resolv.conf"),
+		ResolverDir: filepath.Join(dir, "resolver"),
+		DisableFile: filepath.Join(dir, "zeroplex.disable"),
+	}
+	for _, d := range []string{h.StateDir, h.NetworkdDir, filepath.Join(dir, "bin")} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatalf("create %s: %v", d, err)
+		}
+	}
+
+	// Fake system binaries shadow the real ones via PATH
+	binDir := filepath.Join(dir, "bin")
+	scripts := map[string]string{
+		"resolvectl":      fakeResolvectl,
+		"systemctl":       fakeSystemctl,
+		"networkctl":      fakeRecorder,
+		"nmcli":           fakeNmcli,
+		"resolvconf":      fakeResolvconf,
+		"unbound-control": fakeUnboundControl,
+		"uci":             fakeUci,
+		"ubus":            fakeRecorder,
+		"ping":            fakeRecorder,
+	}
+	for name, body := range scripts {
+		if err := os.WriteFile(filepath.Join(binDir, name), []byte(body), 0755); err != nil {
+			t.Fatalf("write fake %s: %v", name, err)
+		}
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("ZEROPLEX_FAKE_STATE", h.StateDir)
+
+	tokenFile := filepath.Join(dir, "authtoken.secret")
+	if err := os.WriteFile(tokenFile, []byte(Token+"\n"), 0600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+
+	// The API listener is created before switching namespaces: HTTP dials happen on
+	// other OS threads, which stay in the original namespace.
+	h.API = newMockAPI(t)
+
+	previousDir := modes.NetworkdConfigDir
+	modes.NetworkdConfigDir = h.NetworkdDir
+	t.Cleanup(func() { modes.NetworkdConfigDir = previousDir })
+	previousRuntimeDir := modes.NetworkdRuntimeDir
+	modes.NetworkdRuntimeDir = h.RuntimeDir
+	t.Cleanup(func() { modes.NetworkdRuntimeDir = previousRuntimeDir })
+	previousResolvConf := modes.ResolvConfPath
+	modes.ResolvConfPath = h.ResolvConf
+	t.Cleanup(func() { modes.ResolvConfPath = previousResolvConf })
+	previousResolverDir := modes.ResolverDir
+	modes.ResolverDir = h.ResolverDir
+	t.Cleanup(func() { modes.ResolverDir = previousResolverDir })
+	previousState := state.DefaultPath
+	state.DefaultPath = h.StatePath
+	t.Cleanup(func() { state.DefaultPath = previousState })
+	previousDisableFile := runner.DisableFile
+	runner.DisableFile = h.DisableFile
+	t.Cleanup(func() { runner.DisableFile = previousDisableFile })
+
+	h.enterNetNS()
+	return h
+}
+
+func (h *Harness) enterNetNS() {
+	t := h.T
+	runtime.LockOSThread()
+	orig, err := netns.Get()
+	if err != nil {
+		runtime.UnlockOSThread()
+		t.Skipf("network namespaces unavailable: %v", err)
+	}
+	ns, err := netns.New()
+	if err != nil {
+		orig.Close()
+		runtime.UnlockOSThread()
+		t.Skipf("cannot create network namespace: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = netns.Set(orig)
+		ns.Close()
+		orig.Close()
+		runtime.UnlockOSThread()
+	})
+	if lo, err := netlink.LinkByName("lo"); err == nil {
+		_ = netlink.LinkSetUp(lo)
+	}
+}
+
+// AddZTInterface creates an up dummy (or, without the dummy driver, TAP) interface standing in for a zt device
+func (h *Harness) AddZTInterface(name string, cidrs ...string) netlink.Link {
+	t := h.T
+	t.Helper()
+	var link netlink.Link = &netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: name}}
+	if err := netlink.LinkAdd(link); err != nil {
+		// zerotier-one itself uses TAP devices, so they make an equally valid stand-in
+		tap := &netlink.Tuntap{LinkAttrs: netlink.LinkAttrs{Name: name}, Mode: netlink.TUNTAP_MODE_TAP, Flags: netlink.TUNTAP_DEFAULTS}
+		if tapErr := netlink.LinkAdd(tap); tapErr != nil {
+			t.Skipf("cannot create test interface %s: dummy: %v, tap: %v", name, err, tapErr)
+		}
+		link = tap
+	}
+	for _, cidr := range cidrs {
+		addr, err := netlink.ParseAddr(cidr)
+		if err != nil {
+			t.Fatalf("parse %s: %v", cidr, err)
+		}
+		if err := netlink.AddrAdd(link, addr); err != nil {
+			t.Fatalf("add address %s to %s: %v", cidr, name, err)
+		}
+	}
+	if err := netlink.LinkSetUp(link); err != nil {
+		t.Fatalf("set %s up: %v", name, err)
+	}
+	created, err := netlink.LinkByName(name)
+	if err != nil {
+		t.Fatalf("look up %s: %v", name, err)
+	}
+	return created
+}
+
+// Config returns a configuration pointing at the mock API for the given mode
+func (h *Harness) Config(mode string) config.Config {
+	cfg := config.DefaultConfig()
+	cfg.Default.Mode = mode
+	cfg.Default.Daemon.Enabled = false
+	cfg.Default.Log.Level = "error"
+	cfg.Default.Client.Host = h.API.Host
+	cfg.Default.Client.Port = h.API.Port
+	cfg.Default.Client.TokenFile = filepath.Join(h.Dir, "authtoken.secret")
+	return cfg
+}
+
+// AddResolvedLink creates an interface whose domains the fake resolvectl reports as set by another
+// DNS manager, like tailscaled or a VPN client would
+func (h *Harness) AddResolvedLink(name string, domains ...string) netlink.Link {
+	h.T.Helper()
+	link := h.AddZTInterface(name)
+	index := strconv.Itoa(link.Attrs().Index)
+	for file, content := range map[string]string{index + ".name": name, index + ".domain": strings.Join(domains, " ")} {
+		if err := os.WriteFile(filepath.Join(h.StateDir, file), []byte(content+"\n"), 0644); err != nil {
+			h.T.Fatalf("write %s: %v", file, err)
+		}
+	}
+	return link
+}
+
+// ResolvedLink returns the DNS servers and domains the fake resolvectl holds for an interface
+func (h *Harness) ResolvedLink(name string) (servers, domains []string) {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		h.T.Fatalf("look up %s: %v", name, err)
+	}
+	index := strconv.Itoa(link.Attrs().Index)
+	read := func(kind string) []string {
+		content, err := os.ReadFile(filepath.Join(h.StateDir, index+"."+kind))
+		if err != nil {
+			return nil
+		}
+		return strings.Fields(string(content))
+	}
+	return read("dns"), read("domain")
+}
+
+// NetworkManagerDevice returns the runtime setting prop (such as "ipv4.dns") the fake nmcli holds
+// for an interface, or "" if none was set
+func (h *Harness) NetworkManagerDevice(name, prop string) string {
+	content, err := os.ReadFile(filepath.Join(h.StateDir, "nm."+name+"."+prop))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// Resolvconf returns the entry the fake resolvconf holds for an interface, or "" if there is none
+func (h *Harness) Resolvconf(name string) string {
+	content, err := os.ReadFile(filepath.Join(h.StateDir, "resolvconf."+name))
+	if err != nil {
+		return ""
+	}
+	return string(content)
+}
+
+// UnboundForward returns the servers the fake unbound-control forwards a zone to, or "" if it has no
+// such zone
+func (h *Harness) UnboundForward(zone string) string {
+	content, err := os.ReadFile(filepath.Join(h.StateDir, "unbound."+zone))
+	if err != nil {
+		return ""
+	}
+	_, servers, _ := strings.Cut(strings.TrimSpace(string(content)), "+i ")
+	return servers
+}
+
+// UciList returns the entries the fake uci holds for a list option such as
+// "dhcp.@dnsmasq[0].server"
+func (h *Harness) UciList(option string) []string {
+	content, err := os.ReadFile(filepath.Join(h.StateDir, "uci."+option))
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(content))
+}
+
+// FailQueries makes the fake "resolvectl query" fail (or succeed again)
+func (h *Harness) FailQueries(fail bool) {
+	h.T.Helper()
+	marker := filepath.Join(h.StateDir, "query-fail")
+	if !fail {
+		os.Remove(marker)
+		return
+	}
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		h.T.Fatalf("write %s: %v", marker, err)
+	}
+}
+
+// FailLinkChanges makes the fake resolvectl fail to change the settings of a link, as while
+// systemd-resolved restarts (or succeed again)
+func (h *Harness) FailLinkChanges(fail bool) {
+	h.T.Helper()
+	marker := filepath.Join(h.StateDir, "set-fail")
+	if !fail {
+		os.Remove(marker)
+		return
+	}
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		h.T.Fatalf("write %s: %v", marker, err)
+	}
+}
+
+// ReadOnlyNetworkd bind mounts NetworkdDir read-only over itself, as /etc is on ostree based
+// systems, until the test ends
+func (h *Harness) ReadOnlyNetworkd() {
+	t := h.T
+	t.Helper()
+	if err := syscall.Mount(h.NetworkdDir, h.NetworkdDir, "", syscall.MS_BIND, ""); err != nil {
+		t.Skipf("cannot bind mount %s: %v", h.NetworkdDir, err)
+	}
+	t.Cleanup(func() { _ = syscall.Unmount(h.NetworkdDir, syscall.MNT_DETACH) })
+	if err := syscall.Mount("", h.NetworkdDir, "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY, ""); err != nil {
+		t.Skipf("cannot make %s read-only: %v", h.NetworkdDir, err)
+	}
+}
+
+// ServeDNS answers every query sent to address (port 53 of an address of a test interface) with
+// NXDOMAIN after delay, until the test ends. It must be called from the test goroutine, which is
+// in the test namespace.
+func (h *Harness) ServeDNS(address string, delay time.Duration) {
+	t := h.T
+	t.Helper()
+	conn, err := net.ListenPacket("udp", net.JoinHostPort(address, "53"))
+	if err != nil {
+		t.Fatalf("listen on %s:53: %v", address, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, peer, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < 12 {
+				continue
+			}
+			answer := append([]byte{}, buf[:n]...)
+			answer[2] |= 0x80                     // QR: a response
+			answer[3] = answer[3]&0x70 | 0x80 | 3 // RA, RCODE NXDOMAIN
+			time.AfterFunc(delay, func() { _, _ = conn.WriteTo(answer, peer) })
+		}
+	}()
+}
+
+// Calls returns every recorded invocation of the fake binaries
+func (h *Harness) Calls() []string {
+	content, err := os.ReadFile(filepath.Join(h.StateDir, "calls.log"))
+	if err != nil {
+		return nil
+	}
+	return strings.Split(strings.TrimSpace(string(content)), "\n")
+}
+
+// Called reports whether any recorded invocation starts with prefix
+func (h *Harness) Called(prefix string) bool {
+	for _, c := range h.Calls() {
+		if strings.HasPrefix(c, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Network describes a joined network served by the mock API
+type Network struct {
+	ID        string
+	Name      string
+	Interface string
+	Status    string
+	Servers   []string
+	Domain    string
+	Addresses []string
+}
+
+func (n Network) toJSON() map[string]interface{} {
+	status := n.Status
+	if status == "" {
+		status = "OK"
+	}
+	return map[string]interface{}{
+		"id":                n.ID,
+		"name":              n.Name,
+		"portDeviceName":    n.Interface,
+		"status":            status,
+		"assignedAddresses": n.Addresses,
+		"routes":            []interface{}{},
+		"dns": map[string]interface{}{
+			"domain":  n.Domain,
+			"servers": n.Servers,
+		},
+	}
+}
+
+// MockAPI is a fake zerotier-one service API serving GET /network
+type MockAPI struct {
+	Host string
+	Port int
+
+	mu       sync.Mutex
+	networks []Network
+	requests int
+	delay    time.Duration
+}
+
+func newMockAPI(t testing.TB) *MockAPI {
+	api := &MockAPI{}
+	server := httptest.NewServer(http.HandlerFunc(api.serve))
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("parse mock API URL: %v", err)
+	}
+	host, port, _ := net.SplitHostPort(u.Host)
+	api.Host = "http://" + host
+	api.Port, _ = strconv.Atoi(port)
+	return api
+}
+
+func (a *MockAPI) serve(w http.ResponseWriter, req *http.Request) {
+	a.mu.Lock()
+	a.requests++
+	networks := append([]Network(nil), a.networks...)
+	delay := a.delay
+	a.mu.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return
+		}
+	}
+
+	if req.Header.Get("X-ZT1-Auth") != Token {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	if req.URL.Path != "/network" {
+		http.NotFound(w, req)
+		return
+	}
+	body := make([]map[string]interface{}, 0, len(networks))
+	for _, n := range networks {
+		body = append(body, n.toJSON())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// SetDelay makes the API answer only after d, as a wedged zerotier-one would
+func (a *MockAPI) SetDelay(d time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.delay = d
+}
+
+// SetNetworks replaces the networks the API reports
+func (a *MockAPI) SetNetworks(networks ...Network) {
+	a.mu.Lock()
+	a.networks = networks
+	a.mu.Unlock()
+}
+
+// Requests returns how many requests the API has served
+func (a *MockAPI) Requests() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.requests
+}
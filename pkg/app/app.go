@@ -7,62 +7,122 @@ package app
 import (
 	"zeroplex/pkg/cli"
 	"zeroplex/pkg/config"
+	"zeroplex/pkg/docs"
+	"zeroplex/pkg/exitcode"
+	"zeroplex/pkg/hardening"
+	"zeroplex/pkg/initsys"
 	"zeroplex/pkg/log"
+	"zeroplex/pkg/modes"
+	"zeroplex/pkg/privilege"
 	"zeroplex/pkg/runner"
+	"zeroplex/pkg/state"
 	"zeroplex/pkg/utils"
 
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"time"
 )
 
 var Version = "development"
 
+// BuildTime is when the binary was built, set by main
+var BuildTime = "unknown"
+
 type App struct {
 	cfg    config.Config
 	runner *runner.Runner
+
+	// Where the configuration was loaded from, to load it again on reload
+	configFile string
+	configDir  string
 }
 
 func New() *App {
 	return &App{}
 }
 
-// ValidateAndLoadConfig validates and loads configuration from file
-func ValidateAndLoadConfig(configFile string) config.Config {
-	logger := log.NewScopedLogger("[config]", "info")
-	logger.Trace("ValidateAndLoadConfig() started with file: %s", configFile)
+// ValidateAndLoadConfig validates and loads configuration from a file, or from a configuration
+// directory when configDir is set
+func ValidateAndLoadConfig(configFile, configDir string) config.Config {
+	cfg, err := loadConfig(configFile, configDir)
+	if err != nil {
+		utils.ExitWithError("Loading configuration", err, exitcode.Config)
+	}
+	return cfg
+}
+
+// configSearchPaths are the configuration files tried in turn when neither a file nor a directory
+// is given
+var configSearchPaths = []string{"./zeroplex.yml", "/etc/zeroplex.yml"}
+
+// loadConfig is ValidateAndLoadConfig returning its errors, for reloads that must not exit
+func loadConfig(configFile, configDir string) (config.Config, error) {
+	logger := log.NewScopedLogger("[config]", "")
+	logger.Trace("loadConfig() started with file: %s, directory: %s", configFile, configDir)
+
+	if configDir != "" {
+		files, err := config.DirFiles(configDir)
+		if err != nil {
+			return config.Config{}, err
+		}
+		logger.Debug("Loading configuration from directory %s: %v", configDir, files)
+		cfg, err := config.LoadConfigFiles(files...)
+		if err != nil {
+			return config.Config{}, err
+		}
+		if err := config.ValidateConfig(&cfg); err != nil {
+			logger.Debug("Configuration validation failed: %v", err)
+			return config.Config{}, err
+		}
+		return cfg, nil
+	}
 
 	// Enhanced config file search logic
 	tryFiles := []string{}
 	if configFile != "" {
 		tryFiles = append(tryFiles, configFile)
 	} else {
-		tryFiles = append(tryFiles, "./zeroplex.yml", "/etc/zeroplex.yml")
+		tryFiles = append(tryFiles, configSearchPaths...)
 	}
 
-	var cfg config.Config
-	var found bool
 	for _, f := range tryFiles {
+		// Fragments in the drop-in directory (/etc/zeroplex.d) are loaded over the file, or on their
+		// own when a package ships fragments but there is no file
+		dropIns, err := config.DropInFiles(f)
+		if err != nil {
+			return config.Config{}, err
+		}
+		files := dropIns
 		if fi, err := os.Stat(f); err == nil && !fi.IsDir() {
 			logger.Debug("Loading configuration from file: %s", f)
-			cfg = config.LoadConfiguration(f)
-			found = true
-			break
+			files = append([]string{f}, dropIns...)
 		}
-	}
-
-	if !found {
-		logger.Warn("No configuration file found (tried: %v). Proceeding with defaults and CLI flags only.", tryFiles)
-		cfg = config.Config{} // Use empty config; CLI flags will apply
-	} else {
-		err := config.ValidateConfig(&cfg)
-		if err != nil {
-			logger.Debug("Configuration validation failed: %v", err)
-			utils.ErrorHandler("Validating configuration", err, true)
+		if len(dropIns) > 0 {
+			logger.Debug("Loading drop-ins from %s: %v", config.DropInDir(f), dropIns)
+		}
+		if len(files) > 0 {
+			cfg, err := config.LoadConfigFiles(files...)
+			if err != nil {
+				return config.Config{}, err
+			}
+			// As config.LoadConfiguration does, an emptied token file falls back to the default
+			if cfg.Default.Client.TokenFile == "" {
+				cfg.Default.Client.TokenFile = config.DefaultConfig().Default.Client.TokenFile
+			}
+			if err := config.ValidateConfig(&cfg); err != nil {
+				logger.Debug("Configuration validation failed: %v", err)
+				return config.Config{}, err
+			}
+			return cfg, nil
 		}
 	}
-	return cfg
+
+	logger.Warn("No configuration file found (tried: %v). Proceeding with defaults and CLI flags only.", tryFiles)
+	return config.DefaultConfig(), nil // CLI flags are applied over the built-in defaults
 }
 
 func showStartupBanner(logLevel string, showTimestamps bool, version string) {
@@ -100,16 +160,23 @@ func (a *App) Run() error {
 		printHelpWithVersion(false)
 		return nil
 	}
+	if *flags.HelpAll {
+		printVersion(getVersionString())
+		fmt.Println()
+		docs.HelpAll(os.Stdout)
+		return nil
+	}
 	if *flags.Version || *flags.VersionShort {
 		printVersion(getVersionString())
 		return nil
 	}
 
-	// Require root for all other operations
-	if os.Geteuid() != 0 {
-		printVersion(getVersionString())
-		fmt.Fprintln(os.Stderr, "This application must be run as root. Exiting.")
-		os.Exit(1)
+	// Subcommands (e.g. `zeroplex state show`) run instead of the normal DNS management loop
+	if args := flag.Args(); len(args) > 0 {
+		if err := runCommand(args); err != nil && !errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return nil
 	}
 
 	// Now proceed to config and normal operation
@@ -121,25 +188,67 @@ func (a *App) Run() error {
 		showStartupBanner(cfg.Default.Log.Level, cfg.Default.Log.Timestamps, "")
 	}
 	printStartupVersion(getVersionString())
-	// Perform mode auto-detection before creating the runner
-	if cfg.Default.Mode == "auto" {
-		r := runner.New(cfg, dryRun)
-		detectedMode, detected := r.DetectMode()
-		if detected {
-			cfg.Default.Mode = detectedMode
-			log.NewLogger("[runner]", cfg.Default.Log.Level).Info("Auto-detected mode: %s", detectedMode)
-		} else {
-			log.NewLogger("[runner]", cfg.Default.Log.Level).Warn("Failed to auto-detect mode, keeping 'auto'")
-		}
+	// Perform mode auto-detection before creating the runner, and before checking what the mode needs
+	detectMode(&cfg, dryRun)
+	// Root (an elevated administrator on Windows), or what the configured modes need to make their changes
+	if missing := privilege.Check(cfg); len(missing) > 0 {
+		return privilege.Error(missing)
+	}
+	if err := prepareStateDir(cfg.Default.StateDir, log.NewScopedLogger("[state]", cfg.Default.Log.Level)); err != nil {
+		return err
+	}
+	if err := hardening.Apply(cfg.Default.Hardening, log.NewScopedLogger("[hardening]", cfg.Default.Log.Level)); err != nil {
+		return exitcode.Wrap(exitcode.Privilege, err)
 	}
 	a.cfg = cfg
 	r := runner.New(cfg, dryRun)
+	r.SetReloader(a.reloadConfig, a.configPaths()...)
+	r.SetVersion(getVersionString())
+	logger := log.NewScopedLogger("[app]", cfg.Default.Log.Level)
+	logger.Verbose("Features: %s", r.Features(getVersionString(), BuildTime).Summary())
+	if active := cfg.Default.ActiveExperiments(); len(active) > 0 {
+		logger.Warn("Experimental features enabled: %s", strings.Join(active, ", "))
+	}
 	if cfg.Default.Daemon.Enabled {
-		r.RunDaemon()
+		return r.RunDaemon()
+	}
+	return r.RunOnce()
+}
+
+// detectMode replaces mode: auto with the detected mode
+func detectMode(cfg *config.Config, dryRun bool) {
+	if cfg.Default.Mode != "auto" {
+		return
+	}
+	detectedMode, detected := runner.New(*cfg, dryRun).DetectMode()
+	if detected {
+		cfg.Default.Mode = detectedMode
+		log.NewLogger("[runner]", cfg.Default.Log.Level).Info("Auto-detected mode: %s", detectedMode)
 	} else {
-		r.RunOnce()
+		log.NewLogger("[runner]", cfg.Default.Log.Level).Warn("Failed to auto-detect mode, keeping 'auto'")
 	}
-	return nil
+}
+
+// prepareStateDir moves the state directory to dir if it is set, bringing along the files of the
+// default one, and prepares it before anything is written there
+func prepareStateDir(dir string, logger *log.Logger) error {
+	legacy := state.DefaultDir()
+	if dir != "" {
+		state.SetDefaultDir(dir)
+	}
+	current := state.DefaultDir()
+	changes, err := current.Prepare()
+	for _, change := range changes {
+		logger.Verbose("State directory: %s", change)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to prepare the state directory %s: %w", current.Path(), err)
+	}
+	moved, err := current.Migrate(legacy)
+	if len(moved) > 0 {
+		logger.Info("Moved %s from %s to %s", strings.Join(moved, ", "), legacy.Path(), current.Path())
+	}
+	return err
 }
 
 func getVersionString() string {
@@ -158,11 +267,15 @@ func printHelp() {
 
 // parseArgsWithBanner parses command line arguments and loads configuration, returning showBanner
 func (a *App) parseArgsWithBanner() (config.Config, bool, bool, error) {
-	logger := log.NewScopedLogger("[app/args]", "info")
+	logger := log.NewScopedLogger("[app/args]", "")
 	logger.Trace("Starting command line argument parsing")
 
 	flags := cli.FlagsInstance
 	explicitFlags := cli.ExplicitFlags
+	// Honour --log-level while the configuration is still being loaded
+	if explicitFlags["log-level"] {
+		log.SetLevel(*flags.LogLevel)
+	}
 
 	// Help/version logic: allow these even as non-root
 	if *flags.Help || *flags.HelpShort {
@@ -185,36 +298,53 @@ func (a *App) parseArgsWithBanner() (config.Config, bool, bool, error) {
 	if *flags.ConfigFileC != "" {
 		finalConfigFile = *flags.ConfigFileC
 	}
+	configDir := *flags.ConfigDir
 
-	logger.Verbose("Loading configuration from file: %s", finalConfigFile)
-	cfg := ValidateAndLoadConfig(finalConfigFile)
-	logger.Debug("Configuration loaded and validated successfully")
-
-	// Handle profile selection
-	if *flags.SelectedProfile != "" {
-		if profile, exists := cfg.Profiles[*flags.SelectedProfile]; exists {
-			logger.Debug("Applying selected profile: %s", *flags.SelectedProfile)
-			cfg.Default = mergeProfiles(cfg.Default, profile)
+	// ZEROPLEX_CONFIG names a file or a configuration directory, for deployments that can set the
+	// environment more easily than the command line; the flags take precedence
+	if env := os.Getenv(config.ConfigEnv); env != "" && finalConfigFile == "" && configDir == "" {
+		if fi, err := os.Stat(env); err == nil && fi.IsDir() {
+			configDir = env
 		} else {
-			logger.Debug("Selected profile '%s' not found. Using default profile.", *flags.SelectedProfile)
+			finalConfigFile = env
 		}
+		logger.Debug("Using configuration location from %s: %s", config.ConfigEnv, env)
 	}
 
-	// Apply explicit flags over config/defaults and merged profile (flags always win)
-	cli.ApplyExplicitFlags(&cfg, flags, explicitFlags)
+	if *flags.DecryptionKeyFile != "" {
+		config.DecryptionKeyFile = *flags.DecryptionKeyFile
+	}
+	modes.Force = *flags.Force
+
+	if finalConfigFile != "" && configDir != "" {
+		return config.Config{}, false, false, exitcode.Wrap(exitcode.Usage, fmt.Errorf("--config-file and --config-dir cannot be used together"))
+	}
+	if configDir != "" {
+		logger.Verbose("Loading configuration from directory: %s", configDir)
+	} else {
+		logger.Verbose("Loading configuration from file: %s", finalConfigFile)
+	}
+	a.configFile, a.configDir = finalConfigFile, configDir
+	cfg := ValidateAndLoadConfig(finalConfigFile, configDir)
+	logger.Debug("Configuration loaded and validated successfully")
+	if err := selectProfileAndFlags(&cfg, logger); err != nil {
+		return config.Config{}, false, false, err
+	}
 
 	// Validate daemon configuration
 	if cfg.Default.Daemon.Enabled {
 		logger.Verbose("Validating daemon mode configuration")
-		if cfg.Default.Daemon.PollInterval == "" {
-			cfg.Default.Daemon.PollInterval = "1m" // Default interval
-			logger.Debug("Set default poll interval to 1m")
-		}
 
 		// Validate interval
 		if _, err := utils.ParseInterval(cfg.Default.Daemon.PollInterval); err != nil {
 			logger.Error("Invalid poll interval '%s': %v", cfg.Default.Daemon.PollInterval, err)
-			return config.Config{}, false, false, fmt.Errorf("invalid poll interval '%s': %w", cfg.Default.Daemon.PollInterval, err)
+			return config.Config{}, false, false, exitcode.Wrap(exitcode.Config, fmt.Errorf("invalid poll interval '%s': %w", cfg.Default.Daemon.PollInterval, err))
+		}
+		if cfg.Default.Daemon.StartJitter != "" {
+			if _, err := utils.ParseInterval(cfg.Default.Daemon.StartJitter); err != nil {
+				logger.Error("Invalid start jitter '%s': %v", cfg.Default.Daemon.StartJitter, err)
+				return config.Config{}, false, false, exitcode.Wrap(exitcode.Config, fmt.Errorf("invalid start jitter '%s': %w", cfg.Default.Daemon.StartJitter, err))
+			}
 		}
 		logger.Verbose("Running in daemon mode with API polling interval: %s", cfg.Default.Daemon.PollInterval)
 	} else {
@@ -223,6 +353,11 @@ func (a *App) parseArgsWithBanner() (config.Config, bool, bool, error) {
 
 	// After all config/profile merging and explicit flag application, update logger global state
 	log.GetLogger().SetShowTimestamps(cfg.Default.Log.Timestamps)
+	log.SetLevel(cfg.Default.Log.Level)
+	if err := initsys.Use(cfg.Default.InitSystem); err != nil {
+		return config.Config{}, false, false, exitcode.Wrap(exitcode.Config, err)
+	}
+	logger.Debug("Using init system %s", initsys.Current().Name())
 
 	// Set up logging output type and file if specified
 	if cfg.Default.Log.Type == "file" || cfg.Default.Log.Type == "both" {
@@ -253,69 +388,84 @@ func (a *App) parseArgsWithBanner() (config.Config, bool, bool, error) {
 	return cfg, *flags.DryRun, *flags.Banner, nil
 }
 
-// mergeProfiles merges a selected profile with the default profile
-func mergeProfiles(defaultProfile, selectedProfile config.Profile) config.Profile {
-	merged := defaultProfile
-
-	if selectedProfile.Mode != "" {
-		merged.Mode = selectedProfile.Mode
+// selectProfileAndFlags applies the selected profile, then the ZEROPLEX_* environment variables and
+// then the explicit command line flags, which always win, over a loaded configuration
+func selectProfileAndFlags(cfg *config.Config, logger *log.Logger) error {
+	flags := cli.FlagsInstance
+	// Handle profile selection
+	if *flags.SelectedProfile != "" {
+		if profile, exists := cfg.SelectProfile(*flags.SelectedProfile); exists {
+			logger.Debug("Applying selected profile: %s", *flags.SelectedProfile)
+			cfg.Default = profile
+		} else {
+			logger.Debug("Selected profile '%s' not found. Using default profile.", *flags.SelectedProfile)
+		}
 	}
-	// Merge Log
-	if selectedProfile.Log.Level != "" {
-		merged.Log.Level = selectedProfile.Log.Level
+
+	applied, err := config.ApplyEnv(&cfg.Default, os.Environ())
+	if err != nil {
+		return exitcode.Wrap(exitcode.Config, err)
 	}
-	if selectedProfile.Log.Type != "" {
-		merged.Log.Type = selectedProfile.Log.Type
+	if len(applied) > 0 {
+		logger.Debug("Set from the environment: %s", strings.Join(applied, ", "))
+		if err := config.ValidateConfig(cfg); err != nil {
+			return exitcode.Wrap(exitcode.Config, fmt.Errorf("environment: %w", err))
+		}
 	}
-	if selectedProfile.Log.File != "" {
-		merged.Log.File = selectedProfile.Log.File
+
+	// Apply explicit flags over config/defaults and merged profile (flags always win)
+	cli.ApplyExplicitFlags(cfg, flags, cli.ExplicitFlags)
+	if cli.ExplicitFlags["timeout"] {
+		if d, err := time.ParseDuration(cfg.Default.Timeout); err != nil || d <= 0 {
+			return exitcode.Wrap(exitcode.Usage, fmt.Errorf("invalid --timeout %q: must be a positive duration such as 90s or 2m", cfg.Default.Timeout))
+		}
 	}
-	merged.Log.Timestamps = selectedProfile.Log.Timestamps || merged.Log.Timestamps
 
-	// Merge Daemon
-	merged.Daemon.Enabled = selectedProfile.Daemon.Enabled || merged.Daemon.Enabled
-	if selectedProfile.Daemon.PollInterval != "" {
-		merged.Daemon.PollInterval = selectedProfile.Daemon.PollInterval
+	if cfg.Default.Daemon.Enabled && cfg.Default.Daemon.PollInterval == "" {
+		cfg.Default.Daemon.PollInterval = "1m" // Default interval
+		logger.Debug("Set default poll interval to 1m")
 	}
+	return nil
+}
 
-	// Merge Client
-	if selectedProfile.Client.Host != "" {
-		merged.Client.Host = selectedProfile.Client.Host
+// reloadConfig loads the configuration again the way the daemon was started with it
+func (a *App) reloadConfig() (config.Config, error) {
+	cfg, err := loadConfig(a.configFile, a.configDir)
+	if err != nil {
+		return config.Config{}, err
 	}
-	if selectedProfile.Client.Port != 0 {
-		merged.Client.Port = selectedProfile.Client.Port
+	if err := selectProfileAndFlags(&cfg, log.NewScopedLogger("[app/reload]", "")); err != nil {
+		return config.Config{}, err
 	}
-	if selectedProfile.Client.TokenFile != "" {
-		merged.Client.TokenFile = selectedProfile.Client.TokenFile
+	if _, err := utils.ParseInterval(cfg.Default.Daemon.PollInterval); cfg.Default.Daemon.Enabled && err != nil {
+		return config.Config{}, fmt.Errorf("invalid poll interval '%s': %w", cfg.Default.Daemon.PollInterval, err)
 	}
+	return cfg, nil
+}
 
-	// Merge Networkd
-	merged.Networkd.AutoRestart = selectedProfile.Networkd.AutoRestart || merged.Networkd.AutoRestart
-	merged.Networkd.Reconcile = selectedProfile.Networkd.Reconcile || merged.Networkd.Reconcile
-
-	// Merge Features
-	merged.Features.DNSOverTLS = selectedProfile.Features.DNSOverTLS || merged.Features.DNSOverTLS
-	merged.Features.AddReverseDomains = selectedProfile.Features.AddReverseDomains || merged.Features.AddReverseDomains
-	merged.Features.MulticastDNS = selectedProfile.Features.MulticastDNS || merged.Features.MulticastDNS
-	merged.Features.RestoreOnExit = selectedProfile.Features.RestoreOnExit || merged.Features.RestoreOnExit
-
-	// Merge InterfaceWatch
-	if selectedProfile.InterfaceWatch.Mode != "" {
-		merged.InterfaceWatch.Mode = selectedProfile.InterfaceWatch.Mode
+// configPaths returns the configuration directory, or the file and its drop-in directory,
+// reloadConfig reads. When no file was given it is the first of the search paths that exists, or
+// all of them if none does yet, so creating one is noticed too.
+func (a *App) configPaths() []string {
+	if a.configDir != "" {
+		return []string{a.configDir}
 	}
-	if selectedProfile.InterfaceWatch.Retry.Count != 0 {
-		merged.InterfaceWatch.Retry.Count = selectedProfile.InterfaceWatch.Retry.Count
+	withDropIns := func(files ...string) []string {
+		var paths []string
+		for _, f := range files {
+			paths = append(paths, f, config.DropInDir(f))
+		}
+		return paths
 	}
-	if selectedProfile.InterfaceWatch.Retry.Delay != "" {
-		merged.InterfaceWatch.Retry.Delay = selectedProfile.InterfaceWatch.Retry.Delay
+	if a.configFile != "" {
+		return withDropIns(a.configFile)
 	}
-
-	// Merge Filters
-	if len(selectedProfile.Filters) > 0 {
-		merged.Filters = selectedProfile.Filters
+	for _, f := range configSearchPaths {
+		if fi, err := os.Stat(f); err == nil && !fi.IsDir() {
+			return withDropIns(f)
+		}
 	}
-
-	return merged
+	return withDropIns(configSearchPaths...)
 }
 
 func init() {
@@ -327,35 +477,6 @@ func init() {
 		printCopyrightAndLicense()
 		// Only print version once
 		fmt.Fprintf(flag.CommandLine.Output(), "ZeroPlex version: %s\n\n", getVersionString())
-		fmt.Fprintf(flag.CommandLine.Output(), "Usage: zeroplex [options]\n\n")
-		fmt.Fprintf(flag.CommandLine.Output(), "General Options:\n")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %-29s %s\n", "--help", "Show help message and exit")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %-29s %s\n", "--version", "Print the version and exit")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %-29s %s\n", "--config-file", "Path to the configuration file")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %-29s %s\n", "--profile", "Specify a profile to use from the configuration file")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %-29s %s\n", "--dry-run", "Enable dry-run mode. No changes will be made.")
-		fmt.Fprintf(flag.CommandLine.Output(), "\nLogging Options:\n")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %-29s %s\n", "--log-level", "Set the logging level ('info', 'verbose'*, 'error', 'debug', 'trace')")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %-29s %s\n", "--log-type", "Log output type: 'console'*, 'file', or 'both'")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %-29s %s\n", "--log-file", "Log file path if log-type is 'file' or 'both'")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %-29s %s\n", "--log-timestamps", "Enable timestamps in logs")
-		fmt.Fprintf(flag.CommandLine.Output(), "\nFeatures:\n")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %-29s %s\n", "--dns-over-tls", "Automatically prefer DNS-over-TLS")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %-29s %s\n", "--multicast-dns", "Enable Multicast DNS (mDNS)")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %-29s %s\n", "--add-reverse-domains", "Add ip6.arpa and in-addr.arpa search domains")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %-29s %s\n", "--restore-on-exit", "Restore original DNS settings for all managed interfaces on exit")
-		fmt.Fprintf(flag.CommandLine.Output(), "\nNetworkd Options:\n")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %-29s %s\n", "--auto-restart", "Automatically restart systemd-networkd when things change")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %-29s %s\n", "--reconcile", "Automatically remove left networks from systemd-networkd configuration")
-		fmt.Fprintf(flag.CommandLine.Output(), "\nInterface Watch Options:\n")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %-29s %s\n", "--interface-watch-mode", "Interface watch mode: event, poll, or off")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %-29s %s\n", "--interface-watch-retry-count", "Number of retries after interface event")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %-29s %s\n", "--interface-watch-retry-delay", "Delay between interface event retries (e.g., '2s')")
-		fmt.Fprintf(flag.CommandLine.Output(), "\nZeroTier Client Options:\n")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %-29s %s\n", "--host", "ZeroTier client host address")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %-29s %s\n", "--port", "ZeroTier client port number")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %-29s %s\n", "--token", "API token to use. Overrides token-file if provided")
-		fmt.Fprintf(flag.CommandLine.Output(), "  %-29s %s\n", "--token-file", "Path to the ZeroTier authentication token file")
-		fmt.Fprintf(flag.CommandLine.Output(), "\n") // Add trailing newline for clean output
+		docs.Usage(flag.CommandLine.Output())
 	}
 }
@@ -0,0 +1,199 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package app
+
+import (
+	"zeroplex/pkg/client"
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/runner"
+	"zeroplex/pkg/utils"
+
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+func runConfigCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: zeroplex config init [options]")
+	}
+	switch args[0] {
+	case "init":
+		return runConfigInit(args[1:])
+	default:
+		return fmt.Errorf("unknown config command %q (expected init)", args[0])
+	}
+}
+
+// runConfigInit writes a commented starter configuration for this host: the mode that would be
+// detected, and the port and token file of zerotier-one. With --interactive it asks before using
+// what it found, and which of the joined networks to manage.
+func runConfigInit(args []string) error {
+	fs := flag.NewFlagSet("config init", flag.ContinueOnError)
+	output := fs.String("output", "/etc/zeroplex.yml", "File to write the configuration to, or - for stdout")
+	interactive := fs.Bool("interactive", false, "Prompt for each setting, offering what was detected")
+	force := fs.Bool("force", false, "Overwrite the file if it exists")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *output != "-" && !*force {
+		if _, err := os.Stat(*output); err == nil {
+			return fmt.Errorf("%s exists; use --force to overwrite it", *output)
+		}
+	}
+	// Detection logs, and prompts go to stderr, so the configuration can be piped
+	log.GetLogger().SetOutput(os.Stderr)
+
+	starter := config.DefaultStarter()
+	if mode, detected := runner.New(config.DefaultConfig(), true).DetectMode(); detected {
+		starter.Mode = mode
+	}
+	if _, err := os.Stat(starter.TokenFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: the ZeroTier API token %s can't be read (%v); set client.token_file or client.token_source\n", starter.TokenFile, err)
+	}
+	fmt.Fprintf(os.Stderr, "Detected mode %s, ZeroTier API on port %d\n", starter.Mode, starter.Port)
+
+	if *interactive {
+		if err := promptStarter(&starter, bufio.NewReader(os.Stdin), os.Stderr); err != nil {
+			return err
+		}
+	}
+
+	content := config.StarterConfig(starter)
+	if *output == "-" {
+		_, err := os.Stdout.Write(content)
+		return err
+	}
+	if err := os.WriteFile(*output, content, 0600); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s; check it with zeroplex --config-file %s --dry-run\n", *output, *output)
+	return nil
+}
+
+// promptStarter asks for the settings of starter, keeping those answered with an empty line
+func promptStarter(starter *config.Starter, in *bufio.Reader, out io.Writer) error {
+	ask := func(question, current string) (string, error) {
+		fmt.Fprintf(out, "%s [%s]: ", question, current)
+		line, err := in.ReadString('\n')
+		if err == io.EOF && line == "" {
+			// Without a terminal every remaining setting keeps what was detected
+			fmt.Fprintln(out)
+			return current, nil
+		}
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			return line, nil
+		}
+		return current, nil
+	}
+
+	for {
+		mode, err := ask("Mode ("+strings.Join(config.Modes, ", ")+")", starter.Mode)
+		if err != nil {
+			return err
+		}
+		if utils.Contains(config.Modes, mode) {
+			starter.Mode = mode
+			break
+		}
+		fmt.Fprintf(out, "Unknown mode %q\n", mode)
+	}
+	host, err := ask("ZeroTier API host", starter.Host)
+	if err != nil {
+		return err
+	}
+	starter.Host = host
+	for {
+		port, err := ask("ZeroTier API port", strconv.Itoa(starter.Port))
+		if err != nil {
+			return err
+		}
+		if n, err := strconv.Atoi(port); err == nil && n > 0 && n <= 65535 {
+			starter.Port = n
+			break
+		}
+		fmt.Fprintf(out, "Invalid port %q\n", port)
+	}
+	tokenFile, err := ask("ZeroTier API token file", starter.TokenFile)
+	if err != nil {
+		return err
+	}
+	starter.TokenFile = tokenFile
+
+	daemon := "yes"
+	if !starter.Daemon {
+		daemon = "no"
+	}
+	answer, err := ask("Run as a daemon (yes or no)", daemon)
+	if err != nil {
+		return err
+	}
+	starter.Daemon = !strings.HasPrefix(strings.ToLower(answer), "n")
+	if starter.Daemon {
+		for {
+			interval, err := ask("Poll interval", starter.PollInterval)
+			if err != nil {
+				return err
+			}
+			if d, err := time.ParseDuration(interval); err == nil && d > 0 {
+				starter.PollInterval = interval
+				break
+			}
+			fmt.Fprintf(out, "Invalid duration %q\n", interval)
+		}
+	}
+
+	networks, err := joinedNetworks(*starter)
+	if err != nil {
+		fmt.Fprintf(out, "Not listing the joined networks: %v\n", err)
+		return nil
+	}
+	if len(networks) == 0 {
+		return nil
+	}
+	fmt.Fprintln(out, "Joined networks:")
+	for _, network := range networks {
+		fmt.Fprintf(out, "  %s  %s (%s)\n", utils.GetString(network.Id), utils.GetString(network.Name), utils.GetString(network.PortDeviceName))
+	}
+	answer, err = ask("Networks to manage (IDs, comma separated)", "all")
+	if err != nil {
+		return err
+	}
+	if answer != "all" {
+		starter.Networks = nil
+		for _, id := range strings.Split(answer, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				starter.Networks = append(starter.Networks, id)
+			}
+		}
+	}
+	return nil
+}
+
+// joinedNetworks asks the ZeroTier API of starter for the joined networks
+func joinedNetworks(starter config.Starter) ([]service.Network, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := client.New(config.ClientConfig{Host: starter.Host, Port: starter.Port, TokenFile: starter.TokenFile}).Refresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.JSON200 == nil {
+		return nil, fmt.Errorf("ZeroTier API returned %s", resp.Status())
+	}
+	return *resp.JSON200, nil
+}
@@ -0,0 +1,171 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package app
+
+import (
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/control"
+	"zeroplex/pkg/exitcode"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/modes"
+	"zeroplex/pkg/privilege"
+	"zeroplex/pkg/runner"
+
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// commandPaths maps the subcommands that drive the running daemon to their control API endpoint
+var commandPaths = map[string]string{
+	"apply":   control.ApplyPath,
+	"flush":   control.RestorePath,
+	"restore": control.RestorePath,
+	"reload":  control.ReloadPath,
+	"pause":   control.PausePath,
+	"resume":  control.ResumePath,
+}
+
+// localCommands are the commandPaths commands zeroplex runs itself when no daemon answers on the
+// control socket
+var localCommands = map[string]func() error{
+	"apply": runLocalApply,
+	"flush": runLocalFlush,
+}
+
+// runControlCommand sends one of the commandPaths commands to the running daemon
+func runControlCommand(name string, args []string) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	socket := fs.String("socket", control.DefaultSocket, "Path to the daemon's control socket")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	result, err := control.NewClient(*socket).Command(context.Background(), commandPaths[name])
+	if local, ok := localCommands[name]; ok && daemonUnreachable(err) {
+		return local()
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(result.Message)
+	for _, iface := range result.Restored {
+		fmt.Printf("  restored %s\n", iface)
+	}
+	return nil
+}
+
+// daemonUnreachable reports whether err is the failure to connect to the control socket, because no
+// daemon is running or it has the control API disabled
+func daemonUnreachable(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// localConfig loads the configuration for a command run without the daemon, which needs the same
+// privileges as the daemon
+func localConfig() (config.Config, bool, error) {
+	cfg, dryRun, _, err := New().parseArgsWithBanner()
+	if err != nil {
+		return cfg, dryRun, err
+	}
+	detectMode(&cfg, dryRun)
+	if missing := privilege.Check(cfg); len(missing) > 0 {
+		return cfg, dryRun, exitcode.Wrap(exitcode.Privilege, fmt.Errorf("no daemon is running, and running the command without one needs root or %s", strings.Join(missing, "; ")))
+	}
+	if err := prepareStateDir(cfg.Default.StateDir, log.NewScopedLogger("[state]", cfg.Default.Log.Level)); err != nil {
+		return cfg, dryRun, err
+	}
+	return cfg, dryRun, nil
+}
+
+// runLocalApply runs one reconcile when no daemon is running
+func runLocalApply() error {
+	cfg, dryRun, err := localConfig()
+	if err != nil {
+		return err
+	}
+	return runner.New(cfg, dryRun).RunOnce()
+}
+
+// runLocalFlush removes every change zeroplex made when no daemon is running: generated networkd files,
+// resolved link settings and whatever else the configured mode manages
+func runLocalFlush() error {
+	cfg, dryRun, err := localConfig()
+	if err != nil {
+		return err
+	}
+	if err := runner.DisabledError(); err != nil {
+		return err
+	}
+	restored := modes.RestoreManaged(cfg, dryRun)
+	fmt.Printf("restored %d interfaces\n", len(restored))
+	for _, iface := range restored {
+		fmt.Printf("  restored %s\n", iface)
+	}
+	return nil
+}
+
+// runStatusCommand prints the running daemon's status
+func runStatusCommand(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	socket := fs.String("socket", control.DefaultSocket, "Path to the daemon's control socket")
+	format := fs.String("format", "text", "Output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown format %q (expected text or json)", *format)
+	}
+
+	status, err := control.NewClient(*socket).Status(context.Background())
+	if err != nil {
+		return err
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(status)
+	}
+
+	state := "enforcing"
+	if status.Disabled {
+		state = "administratively disabled"
+	} else if status.Paused {
+		state = "paused"
+	} else if !status.Enforcing {
+		state = "not enforcing"
+	}
+	fmt.Printf("Mode:      %s (%s)\n", status.Mode, state)
+	if !status.LastRunAt.IsZero() {
+		fmt.Printf("Last run:  %s (%s)\n", status.LastRunAt.Local().Format(time.RFC3339), status.LastTrigger)
+	}
+	if status.DisabledReason != "" {
+		fmt.Printf("Reason:    %s\n", status.DisabledReason)
+	}
+	if status.LastError != "" {
+		fmt.Printf("Error:     %s\n", status.LastError)
+	}
+	if !status.NextRunAt.IsZero() {
+		fmt.Printf("Next run:  %s\n", status.NextRunAt.Local().Format(time.RFC3339))
+	}
+	if status.ConfigEpoch != "" {
+		fmt.Printf("Epoch:     %s\n", status.ConfigEpoch)
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NETWORK\tNAME\tINTERFACE\tMANAGED\tDOMAIN\tSERVERS")
+	for _, n := range status.Networks {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\t%s\n", n.ID, n.Name, n.Interface, n.Managed, n.Domain, strings.Join(n.Servers, ","))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,37 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package app
+
+import (
+	"zeroplex/pkg/docs"
+
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runDocsCommand prints generated documentation, e.g. `zeroplex docs man > zeroplex.8`
+func runDocsCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: zeroplex docs man|help-all")
+	}
+	switch args[0] {
+	case "man":
+		docs.Man(os.Stdout, getVersionString())
+	case "help-all":
+		docs.HelpAll(os.Stdout)
+	default:
+		return fmt.Errorf("unknown docs format %q (expected man or help-all)", args[0])
+	}
+	return nil
+}
+
+// runCompletionCommand prints a shell completion script, e.g. `zeroplex completion bash > /etc/bash_completion.d/zeroplex`
+func runCompletionCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: zeroplex completion %s", strings.Join(docs.Shells, "|"))
+	}
+	return docs.Completion(os.Stdout, args[0])
+}
@@ -0,0 +1,94 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package app
+
+import (
+	"zeroplex/pkg/control"
+	"zeroplex/pkg/events"
+	"zeroplex/pkg/utils"
+
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// runEventsCommand prints the daemon's recent events, or streams new ones with --follow
+func runEventsCommand(args []string) error {
+	fs := flag.NewFlagSet("events", flag.ContinueOnError)
+	socket := fs.String("socket", control.DefaultSocket, "Path to the daemon's control socket")
+	follow := fs.Bool("follow", false, "Keep streaming events as they are published")
+	types := fs.String("type", "", "Comma separated event types to show (default all)")
+	replay := fs.Int("replay", 0, "With --follow, number of recent events to print before streaming")
+	format := fs.String("format", "text", "Output format: text or json (one object per line)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown format %q (expected text or json)", *format)
+	}
+	var only []string
+	for _, t := range strings.Split(*types, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			only = append(only, t)
+		}
+	}
+	emit := func(ev events.Event) error {
+		if *format == "json" {
+			return json.NewEncoder(os.Stdout).Encode(ev)
+		}
+		_, err := fmt.Println(formatEvent(ev))
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	client := control.NewClient(*socket)
+	if *follow {
+		return client.Events(ctx, control.EventsOptions{Types: only, Replay: *replay}, emit)
+	}
+
+	status, err := client.Status(ctx)
+	if err != nil {
+		return err
+	}
+	for _, ev := range status.Events {
+		if len(only) > 0 && !utils.Contains(only, ev.Type) {
+			continue
+		}
+		if err := emit(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatEvent renders an event as a single human readable line
+func formatEvent(ev events.Event) string {
+	parts := []string{ev.Time.Local().Format("2006-01-02 15:04:05"), ev.Type}
+	if ev.Interface != "" {
+		parts = append(parts, "interface="+ev.Interface)
+	}
+	if ev.NetworkID != "" {
+		parts = append(parts, "network="+ev.NetworkID)
+	}
+	keys := make([]string, 0, len(ev.Data))
+	for key := range ev.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%v", key, ev.Data[key]))
+	}
+	if ev.Message != "" {
+		parts = append(parts, "- "+ev.Message)
+	}
+	return strings.Join(parts, " ")
+}
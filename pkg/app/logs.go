@@ -0,0 +1,56 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package app
+
+import (
+	"zeroplex/pkg/control"
+	"zeroplex/pkg/log"
+
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runLogsCommand prints the most recent log records kept in memory by the running daemon
+func runLogsCommand(args []string) error {
+	fs := flag.NewFlagSet("logs", flag.ContinueOnError)
+	socket := fs.String("socket", control.DefaultSocket, "Path to the daemon's control socket")
+	tail := fs.Int("tail", 200, fmt.Sprintf("Number of recent records to print (0 for all %d kept)", log.BufferSize))
+	format := fs.String("format", "text", "Output format: text or json (one object per line)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown format %q (expected text or json)", *format)
+	}
+
+	records, err := control.NewClient(*socket).Logs(context.Background(), *tail)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	for _, rec := range records {
+		if *format == "json" {
+			if err := encoder.Encode(rec); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Println(formatRecord(rec))
+	}
+	return nil
+}
+
+// formatRecord renders a log record like the daemon's console output
+func formatRecord(rec log.Record) string {
+	parts := []string{rec.Time.Local().Format("2006-01-02 15:04:05"), fmt.Sprintf("%8s", strings.ToUpper(rec.Level))}
+	if rec.Scope != "" {
+		parts = append(parts, rec.Scope)
+	}
+	return strings.Join(append(parts, rec.Message), " ")
+}
@@ -0,0 +1,37 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package app
+
+import (
+	"zeroplex/pkg/control"
+
+	"context"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func runMetricsCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: zeroplex metrics dump [options]")
+	}
+	switch args[0] {
+	case "dump":
+		return runMetricsDump(args[1:])
+	default:
+		return fmt.Errorf("unknown metrics command %q (expected dump)", args[0])
+	}
+}
+
+// runMetricsDump prints the running daemon's metrics in OpenMetrics text format, for a one-shot
+// scrape from cron-based monitoring
+func runMetricsDump(args []string) error {
+	fs := flag.NewFlagSet("metrics dump", flag.ContinueOnError)
+	socket := fs.String("socket", control.DefaultSocket, "Path to the daemon's control socket")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return control.NewClient(*socket).Metrics(context.Background(), os.Stdout)
+}
@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package app
+
+import (
+	"zeroplex/pkg/client"
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/control"
+	"zeroplex/pkg/filters"
+	"zeroplex/pkg/log"
+
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+// queryNetworks loads the configuration selected by the global options and returns it, with the
+// networks ZeroTier has joined and the response to the filters have been applied to. Log lines go to
+// stderr, so the output of the command can be piped.
+func queryNetworks() (config.Config, []service.Network, *service.GetNetworksResponse, error) {
+	log.GetLogger().SetOutput(os.Stderr)
+	cfg, _, _, err := New().parseArgsWithBanner()
+	if err != nil {
+		return cfg, nil, nil, err
+	}
+	log.GetLogger().SetOutput(os.Stderr)
+	resp, err := client.New(cfg.Default.Client).Refresh(context.Background())
+	if err != nil {
+		return cfg, nil, nil, err
+	}
+	if resp.JSON200 == nil {
+		return cfg, nil, nil, fmt.Errorf("ZeroTier API returned %s", resp.Status())
+	}
+	// ApplyFilters replaces the list rather than filtering it in place
+	all := *resp.JSON200
+	filters.ApplyFilters(resp, cfg.Default)
+	return cfg, all, resp, nil
+}
+
+func runNetworksCommand(args []string) error {
+	if len(args) == 0 || args[0] != "list" {
+		return fmt.Errorf("usage: zeroplex [options] networks list [--json] [--socket PATH]")
+	}
+	fs := flag.NewFlagSet("networks list", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "Print the networks as JSON")
+	socket := fs.String("socket", control.DefaultSocket, "Control socket of the daemon, asked when the ZeroTier API can't be queried directly")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	var networks []control.JoinedNetwork
+	_, all, resp, err := queryNetworks()
+	if err == nil {
+		networks = control.JoinedNetworks(all, *resp.JSON200)
+	} else {
+		// Without root the configuration or the token is usually unreadable, but the daemon can tell
+		var daemonErr error
+		if networks, daemonErr = control.NewClient(*socket).Networks(context.Background()); daemonErr != nil {
+			return fmt.Errorf("%w (nor through the daemon: %v)", err, daemonErr)
+		}
+		fmt.Fprintf(os.Stderr, "Listing the networks through the daemon, since %v\n", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(networks)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NETWORK\tNAME\tINTERFACE\tSTATUS\tINCLUDED\tDOMAIN\tSERVERS")
+	for _, n := range networks {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%s\t%s\n", n.ID, n.Name, n.Interface, n.Status, n.Included, n.Domain, strings.Join(n.Servers, ","))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,243 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package app
+
+import (
+	"zeroplex/pkg/control"
+
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// fleetReport is the document printed by `zeroplex report --format json`
+type fleetReport struct {
+	Summary control.FleetSummary `json:"summary"`
+	Hosts   []control.HostReport `json:"hosts"`
+}
+
+// runReportCommand aggregates the status documents of many hosts into one table: fetched over ssh
+// from the hosts of --remote, or else read from stdin as `zeroplex status --format json` prints them
+func runReportCommand(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	remote := fs.String("remote", "", "Hosts to query, comma separated, or @FILE with one per line")
+	sshCommand := fs.String("ssh", "ssh -o BatchMode=yes", "Command that runs a command on a host, given the host and the command")
+	statusCommand := fs.String("command", "zeroplex status --format json", "Command printing the status document on each host")
+	parallel := fs.Int("parallel", 8, "Hosts queried at once")
+	timeout := fs.Duration("timeout", 30*time.Second, "How long each host may take to answer")
+	format := fs.String("format", "text", "Output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown format %q (expected text or json)", *format)
+	}
+
+	var reports []control.HostReport
+	if *remote != "" {
+		hosts, err := reportHosts(*remote)
+		if err != nil {
+			return err
+		}
+		if *parallel < 1 {
+			*parallel = 1
+		}
+		reports = fetchRemoteStatus(hosts, strings.Fields(*sshCommand), *statusCommand, *parallel, *timeout)
+	} else {
+		var err error
+		if reports, err = readStatusDocuments(os.Stdin); err != nil {
+			return err
+		}
+		if len(reports) == 0 {
+			return fmt.Errorf("no status documents on stdin; pipe `zeroplex status --format json` of each host, or use --remote")
+		}
+	}
+
+	summary := control.SummarizeFleet(reports)
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(fleetReport{Summary: summary, Hosts: reports}); err != nil {
+			return err
+		}
+	} else if err := printFleetReport(os.Stdout, reports, summary); err != nil {
+		return err
+	}
+	if summary.Unreachable > 0 {
+		return fmt.Errorf("%d of %d host(s) could not be queried", summary.Unreachable, summary.Hosts)
+	}
+	return nil
+}
+
+// reportHosts parses --remote: hosts separated by commas, or @FILE naming a file with one host per
+// line, where blank lines and lines starting with # are skipped
+func reportHosts(remote string) ([]string, error) {
+	list := strings.Split(remote, ",")
+	if file, ok := strings.CutPrefix(remote, "@"); ok {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		list = strings.Split(string(content), "\n")
+	}
+	var hosts []string
+	for _, host := range list {
+		if host = strings.TrimSpace(host); host != "" && !strings.HasPrefix(host, "#") {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no hosts in --remote %q", remote)
+	}
+	return hosts, nil
+}
+
+// fetchRemoteStatus runs statusCommand on every host through ssh, at most parallel at once, keeping
+// the order of hosts
+func fetchRemoteStatus(hosts, ssh []string, statusCommand string, parallel int, timeout time.Duration) []control.HostReport {
+	if len(ssh) == 0 {
+		ssh = []string{"ssh"}
+	}
+	reports := make([]control.HostReport, len(hosts))
+	slots := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			reports[i] = fetchHostStatus(host, ssh, statusCommand, timeout)
+		}(i, host)
+	}
+	wg.Wait()
+	return reports
+}
+
+// fetchHostStatus runs statusCommand on host and decodes the status document it prints
+func fetchHostStatus(host string, ssh []string, statusCommand string, timeout time.Duration) control.HostReport {
+	report := control.HostReport{Host: host}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	args := append(append(append([]string{}, ssh[1:]...), host), statusCommand)
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ssh[0], args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("no answer within %s", timeout)
+		} else if msg := strings.TrimSpace(lastLine(stderr.String())); msg != "" {
+			err = fmt.Errorf("%v: %s", err, msg)
+		}
+		report.Error = err.Error()
+		return report
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &report.Status); err != nil {
+		report.Error = fmt.Sprintf("invalid status document: %v", err)
+	}
+	return report
+}
+
+// lastLine returns the last non-empty line of s, where ssh and the remote command put their error
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return lines[len(lines)-1]
+}
+
+// readStatusDocuments reads the status documents of r, one after the other or in JSON arrays, as
+// `for h in ...; do ssh $h zeroplex status --format json; done` prints them. A document without a
+// hostname is named after its position.
+func readStatusDocuments(r io.Reader) ([]control.HostReport, error) {
+	var reports []control.HostReport
+	add := func(status control.Status) {
+		host := status.Hostname
+		if host == "" {
+			host = fmt.Sprintf("#%d", len(reports)+1)
+		}
+		reports = append(reports, control.HostReport{Host: host, Status: status})
+	}
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); errors.Is(err, io.EOF) {
+			return reports, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("reading status document %d: %w", len(reports)+1, err)
+		}
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+			var list []control.Status
+			if err := json.Unmarshal(raw, &list); err != nil {
+				return nil, fmt.Errorf("reading status document %d: %w", len(reports)+1, err)
+			}
+			for _, status := range list {
+				add(status)
+			}
+			continue
+		}
+		var status control.Status
+		if err := json.Unmarshal(raw, &status); err != nil {
+			return nil, fmt.Errorf("reading status document %d: %w", len(reports)+1, err)
+		}
+		add(status)
+	}
+}
+
+// printFleetReport prints a line per host and the fleet summary
+func printFleetReport(out io.Writer, reports []control.HostReport, summary control.FleetSummary) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tVERSION\tMODE\tSTATE\tMANAGED\tDRIFT\tWATCHDOGS\tLAST RUN\tERROR")
+	for _, report := range reports {
+		if !report.Reachable() {
+			fmt.Fprintf(w, "%s\t-\t-\tunreachable\t-\t-\t-\t-\t%s\n", report.Host, report.Error)
+			continue
+		}
+		status := report.Status
+		version := status.Version
+		if version == "" {
+			version = "unknown"
+		}
+		watchdogs := "-"
+		if len(status.Watchdogs) > 0 {
+			healthy := 0
+			for _, watchdog := range status.Watchdogs {
+				if watchdog.Healthy {
+					healthy++
+				}
+			}
+			watchdogs = fmt.Sprintf("%d/%d healthy", healthy, len(status.Watchdogs))
+		}
+		lastRun := "-"
+		if !status.LastRunAt.IsZero() {
+			lastRun = time.Since(status.LastRunAt).Round(time.Second).String() + " ago"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d/%d\t%d\t%s\t%s\t%s\n", report.Host, version, status.Mode, status.State(),
+			status.ManagedCount(), len(status.Networks), status.DriftPending, watchdogs, lastRun, status.LastError)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "\n%d host(s): %d unreachable, %d with drift, %d failing, %d disabled\n",
+		summary.Hosts, summary.Unreachable, summary.Drifted, summary.Failing, summary.Disabled)
+	var versions []string
+	for _, version := range summary.SortedVersions() {
+		versions = append(versions, fmt.Sprintf("%s (%d)", version, summary.Versions[version]))
+	}
+	if len(versions) > 0 {
+		fmt.Fprintf(out, "Versions: %s\n", strings.Join(versions, ", "))
+	}
+	return nil
+}
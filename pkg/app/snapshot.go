@@ -0,0 +1,57 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package app
+
+import (
+	"zeroplex/pkg/modes"
+
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// runSnapshotCommand prints the desired state of a run, for review or to diff between runs and hosts
+func runSnapshotCommand(args []string) error {
+	fs := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	format := fs.String("format", "yaml", "Output format: yaml or json")
+	output := fs.String("output", "", "Write the snapshot to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "yaml" && *format != "json" {
+		return fmt.Errorf("unknown format %q (expected yaml or json)", *format)
+	}
+
+	cfg, _, resp, err := queryNetworks()
+	if err != nil {
+		return err
+	}
+	detectMode(&cfg, true)
+	snap := modes.DesiredSnapshot(cfg, resp)
+
+	var buf bytes.Buffer
+	if *format == "json" {
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(snap)
+	} else {
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		err = enc.Encode(snap)
+		enc.Close()
+	}
+	if err != nil {
+		return err
+	}
+	if *output != "" {
+		return os.WriteFile(*output, buf.Bytes(), 0644)
+	}
+	_, err = os.Stdout.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,248 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package app
+
+import (
+	"zeroplex/pkg/state"
+
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// runCommand dispatches a subcommand given after the global options
+func runCommand(args []string) error {
+	switch args[0] {
+	case "state":
+		return runStateCommand(args[1:])
+	case "config":
+		return runConfigCommand(args[1:])
+	case "tray":
+		return runTrayCommand(args[1:])
+	case "top":
+		return runTopCommand(args[1:])
+	case "events":
+		return runEventsCommand(args[1:])
+	case "logs":
+		return runLogsCommand(args[1:])
+	case "metrics":
+		return runMetricsCommand(args[1:])
+	case "completion":
+		return runCompletionCommand(args[1:])
+	case "docs":
+		return runDocsCommand(args[1:])
+	case "version":
+		return runVersionCommand(args[1:])
+	case "status":
+		return runStatusCommand(args[1:])
+	case "snapshot":
+		return runSnapshotCommand(args[1:])
+	case "networks":
+		return runNetworksCommand(args[1:])
+	case "report":
+		return runReportCommand(args[1:])
+	case "export-state":
+		return runExportState(args[1:])
+	case "import-state":
+		return runImportState(args[1:])
+	case "apply", "flush", "restore", "reload", "pause", "resume":
+		return runControlCommand(args[0], args[1:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+func runStateCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: zeroplex state <show|forget> [options]")
+	}
+	switch args[0] {
+	case "show":
+		return runStateShow(args[1:])
+	case "forget":
+		return runStateForget(args[1:])
+	default:
+		return fmt.Errorf("unknown state command %q (expected show or forget)", args[0])
+	}
+}
+
+func runStateShow(args []string) error {
+	fs := flag.NewFlagSet("state show", flag.ContinueOnError)
+	format := fs.String("format", "table", "Output format: table or json")
+	actions := fs.Bool("actions", false, "Include the recorded action history")
+	path := fs.String("state-file", state.DefaultPath, "Path to the state file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	store, err := state.Open(*path)
+	if err != nil {
+		return err
+	}
+	snap := store.Snapshot()
+	if !*actions {
+		snap.Actions = nil
+	}
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(snap)
+	case "table":
+		writeStateTable(os.Stdout, store.Interfaces(), snap.Actions)
+		return nil
+	default:
+		return fmt.Errorf("invalid format %q (expected table or json)", *format)
+	}
+}
+
+func writeStateTable(out io.Writer, interfaces []state.Interface, actions []state.Action) {
+	if len(interfaces) == 0 {
+		fmt.Fprintln(out, "No managed interfaces recorded.")
+	} else {
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "INTERFACE\tINDEX\tMODE\tNETWORK\tDNS\tDOMAINS\tUPDATED")
+		for _, iface := range interfaces {
+			network := iface.NetworkID
+			if iface.NetworkName != "" {
+				network = fmt.Sprintf("%s (%s)", iface.NetworkID, iface.NetworkName)
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n",
+				iface.Name, iface.Index, iface.Mode, orDash(network),
+				orDash(strings.Join(iface.DNS, ",")), orDash(strings.Join(iface.Domains, ",")),
+				iface.UpdatedAt.Local().Format(time.RFC3339))
+		}
+		tw.Flush()
+	}
+	if len(actions) == 0 {
+		return
+	}
+	fmt.Fprintln(out)
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tMODE\tOPERATION\tINTERFACE\tAPPLIED")
+	for _, action := range actions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\n",
+			action.Time.Local().Format(time.RFC3339), action.Mode, action.Operation, orDash(action.Interface), action.Applied)
+	}
+	tw.Flush()
+}
+
+func runStateForget(args []string) error {
+	fs := flag.NewFlagSet("state forget", flag.ContinueOnError)
+	iface := fs.String("interface", "", "Interface to remove from the state store")
+	path := fs.String("state-file", state.DefaultPath, "Path to the state file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *iface == "" {
+		return fmt.Errorf("--interface is required")
+	}
+	store, err := state.Open(*path)
+	if err != nil {
+		return err
+	}
+	existed, err := store.Forget(*iface)
+	if err != nil {
+		return err
+	}
+	if !existed {
+		return fmt.Errorf("interface %s is not recorded in %s", *iface, store.Path())
+	}
+	fmt.Printf("Forgot interface %s\n", *iface)
+	return nil
+}
+
+// runExportState writes the state store, its action history and the other files of the state
+// directory as one JSON document, to be brought back with import-state after a reinstall
+func runExportState(args []string) error {
+	fs := flag.NewFlagSet("export-state", flag.ContinueOnError)
+	output := fs.String("output", "-", "File to write the bundle to, or - for stdout")
+	path := fs.String("state-file", state.DefaultPath, "Path to the state file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	bundle, err := state.Export(*path)
+	if err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+	content = append(content, '\n')
+	if *output == "-" {
+		_, err = os.Stdout.Write(content)
+		return err
+	}
+	if err := os.WriteFile(*output, content, 0600); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d interface(s), %d action(s) and %d file(s) to %s\n",
+		len(bundle.State.Interfaces), len(bundle.State.Actions), len(bundle.Files), *output)
+	return nil
+}
+
+// runImportState restores a bundle written by export-state, refusing to replace a store that
+// already records something unless --force is given
+func runImportState(args []string) error {
+	fs := flag.NewFlagSet("import-state", flag.ContinueOnError)
+	force := fs.Bool("force", false, "Replace a state store that already records interfaces or actions")
+	path := fs.String("state-file", state.DefaultPath, "Path to the state file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: zeroplex import-state [--force] [--state-file PATH] <FILE|->")
+	}
+	var content []byte
+	var err error
+	if fs.Arg(0) == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(fs.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+	var bundle state.Bundle
+	if err := json.Unmarshal(content, &bundle); err != nil {
+		return fmt.Errorf("failed to parse state bundle %s: %w", fs.Arg(0), err)
+	}
+	if !*force {
+		empty, err := state.Empty(*path)
+		if err != nil {
+			return err
+		}
+		if !empty {
+			return fmt.Errorf("%s already records interfaces or actions; use --force to replace it", *path)
+		}
+	}
+	written, err := state.Import(*path, bundle)
+	if err != nil {
+		return err
+	}
+	from := "the bundle"
+	if bundle.Hostname != "" {
+		from = bundle.Hostname
+	}
+	fmt.Printf("Imported %d interface(s) and %d action(s) exported from %s at %s\n",
+		len(bundle.State.Interfaces), len(bundle.State.Actions), from, bundle.ExportedAt.Local().Format(time.RFC3339))
+	for _, name := range written {
+		fmt.Printf("Restored %s\n", name)
+	}
+	return nil
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
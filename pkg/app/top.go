@@ -0,0 +1,30 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package app
+
+import (
+	"zeroplex/pkg/control"
+	"zeroplex/pkg/top"
+
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// runTopCommand runs the terminal dashboard against the daemon's control API
+func runTopCommand(args []string) error {
+	fs := flag.NewFlagSet("top", flag.ContinueOnError)
+	socket := fs.String("socket", control.DefaultSocket, "Path to the daemon's control socket")
+	interval := fs.Duration("interval", top.DefaultInterval, "Refresh interval")
+	once := fs.Bool("once", false, "Print a single frame and exit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return top.Run(ctx, top.Options{Socket: *socket, Interval: *interval, Once: *once})
+}
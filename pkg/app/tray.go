@@ -0,0 +1,28 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package app
+
+import (
+	"zeroplex/pkg/tray"
+
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// runTrayCommand runs the desktop tray helper in the user session
+func runTrayCommand(args []string) error {
+	fs := flag.NewFlagSet("tray", flag.ContinueOnError)
+	interval := fs.Duration("interval", tray.DefaultInterval, "How often to poll the daemon for its status")
+	logLevel := fs.String("log-level", "info", "Log level for the tray helper")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return tray.Run(ctx, tray.Options{Interval: *interval, LogLevel: *logLevel})
+}
@@ -0,0 +1,46 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package app
+
+import (
+	"zeroplex/pkg/runner"
+
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runVersionCommand prints the version and what the binary was built with
+func runVersionCommand(args []string) error {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "Print the version and feature matrix as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	features := runner.BuildFeatures(getVersionString(), BuildTime)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(features)
+	}
+	printVersion(features.Version)
+	fmt.Println()
+	for _, row := range [][2]string{
+		{"Platform", features.Platform},
+		{"Go", features.GoVersion},
+		{"Modes", strings.Join(features.Modes, ", ")},
+		{"Init systems", strings.Join(features.InitSystems, ", ")},
+		{"Secret providers", strings.Join(features.Secrets, ", ")},
+		{"Integrations", strings.Join(features.Integrations, ", ")},
+	} {
+		fmt.Printf("%-18s %s\n", row[0]+":", row[1])
+	}
+	if features.BuildTime != "" {
+		fmt.Printf("%-18s %s\n", "Built:", features.BuildTime)
+	}
+	return nil
+}
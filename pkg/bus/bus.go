@@ -0,0 +1,143 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package bus holds the names and documents shared by the daemon's system bus object and the
+// desktop helpers that talk to it
+package bus
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	// Name is the well-known name the daemon requests on the system bus
+	Name = "com.nfrastack.ZeroPlex"
+	// Path is the object path of the daemon object
+	Path = dbus.ObjectPath("/com/nfrastack/ZeroPlex")
+	// Interface is the versioned interface implemented at Path
+	Interface = "com.nfrastack.ZeroPlex1"
+)
+
+// Signals emitted on Interface. Their names and arguments are stable; new information is only ever
+// added as new fields of the snapshot document.
+const (
+	// SignalStateChanged (summary s, snapshot s) is emitted when the daemon's state changes
+	SignalStateChanged = "StateChanged"
+	// SignalRunFinished (trigger s, success b, error s) is emitted after every reconcile run
+	SignalRunFinished = "RunFinished"
+)
+
+// Properties of Interface, read with org.freedesktop.DBus.Properties. Each change is announced with
+// PropertiesChanged carrying the new value.
+const (
+	PropertyMode        = "Mode"        // s
+	PropertySummary     = "Summary"     // s, as returned by the Summary method
+	PropertyEnforcing   = "Enforcing"   // b
+	PropertyPaused      = "Paused"      // b
+	PropertyDisabled    = "Disabled"    // b, administratively by the host's sentinel file
+	PropertyLastError   = "LastError"   // s, empty after a successful run
+	PropertyConfigEpoch = "ConfigEpoch" // s
+	PropertyNetworks    = "Networks"    // a(sssssasb), see NetworkProperty
+	PropertyInterfaces  = "Interfaces"  // a(sssasas), see InterfaceProperty
+)
+
+// NetworkProperty is an element of the Networks property: the DNS state of a joined network
+type NetworkProperty struct {
+	ID        string
+	Name      string
+	Interface string
+	Status    string
+	Domain    string
+	Servers   []string
+	Managed   bool
+}
+
+// InterfaceProperty is an element of the Interfaces property: an interface whose DNS zeroplex manages
+type InterfaceProperty struct {
+	Name      string
+	NetworkID string
+	Mode      string
+	DNS       []string
+	Domains   []string
+}
+
+// StatusAuthenticationRequired is the ZeroTier network status of a network waiting for SSO login
+const StatusAuthenticationRequired = "AUTHENTICATION_REQUIRED"
+
+// Snapshot is the JSON document returned by the Status method and carried by StateChanged
+type Snapshot struct {
+	Mode           string    `json:"mode"`
+	Enforcing      bool      `json:"enforcing"`
+	Paused         bool      `json:"paused"`
+	Disabled       bool      `json:"disabled"`                  // administratively, by the host's sentinel file
+	DisabledReason string    `json:"disabled_reason,omitempty"` // written in the sentinel file
+	LastTrigger    string    `json:"last_trigger,omitempty"`
+	LastRunAt      time.Time `json:"last_run_at,omitempty"`
+	LastError      string    `json:"last_error,omitempty"`
+	NextRunAt      time.Time `json:"next_run_at,omitempty"`
+	ConfigEpoch    string    `json:"config_epoch,omitempty"`
+	Networks       []Network `json:"networks"`
+}
+
+// Network is the DNS state of a single joined ZeroTier network
+type Network struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name,omitempty"`
+	Interface string   `json:"interface,omitempty"`
+	Status    string   `json:"status,omitempty"`
+	Domain    string   `json:"domain,omitempty"`
+	Servers   []string `json:"servers,omitempty"`
+	Managed   bool     `json:"managed"`
+	AuthURL   string   `json:"authentication_url,omitempty"`
+}
+
+// NeedsAuthentication reports whether the network is waiting for an SSO login
+func (n Network) NeedsAuthentication() bool {
+	return n.Status == StatusAuthenticationRequired
+}
+
+// ManagedCount returns the number of networks whose DNS zeroplex currently manages
+func (s Snapshot) ManagedCount() int {
+	count := 0
+	for _, network := range s.Networks {
+		if network.Managed {
+			count++
+		}
+	}
+	return count
+}
+
+// Summary is a one-line description such as "ZeroTier DNS: active (3 networks)"
+func (s Snapshot) Summary() string {
+	count, noun := s.ManagedCount(), "networks"
+	if count == 1 {
+		noun = "network"
+	}
+	return fmt.Sprintf("ZeroTier DNS: %s (%d %s)", s.State(), count, noun)
+}
+
+// State is the state named by Summary, such as "active" or "paused"
+func (s Snapshot) State() string {
+	state := "active"
+	switch {
+	case s.Disabled:
+		state = "administratively disabled"
+	case s.Paused:
+		state = "paused"
+	case !s.Enforcing:
+		state = "observing"
+	case s.LastError != "":
+		state = "error"
+	}
+	for _, network := range s.Networks {
+		if network.NeedsAuthentication() && !s.Disabled {
+			state = "sign-in required"
+			break
+		}
+	}
+	return state
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"strings"
 	"zeroplex/pkg/config"
+	"zeroplex/pkg/exitcode"
 )
 
 // Flags represents all command line flags
@@ -18,10 +19,15 @@ type Flags struct {
 	VersionShort             *bool
 	Help                     *bool
 	HelpShort                *bool
+	HelpAll                  *bool
 	ConfigFile               *string
 	ConfigFileShort          *string
 	ConfigFileC              *string
+	ConfigDir                *string
+	Force                    *bool
+	DecryptionKeyFile        *string
 	DryRun                   *bool
+	Enforce                  *bool
 	Mode                     *string
 	Host                     *string
 	Port                     *int
@@ -42,6 +48,7 @@ type Flags struct {
 	LogType                  *string
 	LogFile                  *string
 	Banner                   *bool
+	Timeout                  *string
 }
 
 // Global variables to hold parsed flags and explicit flags
@@ -55,13 +62,18 @@ func ParseFlags() (*Flags, map[string]bool) {
 		VersionShort:             flag.Bool("v", false, "Print the version and exit (alias)"),
 		Help:                     flag.Bool("help", false, "Show help message and exit"),
 		HelpShort:                flag.Bool("h", false, "Show help message and exit (alias)"),
+		HelpAll:                  flag.Bool("help-all", false, "Show help including configuration keys, environment variables and exit codes"),
 		AddReverseDomains:        flag.Bool("add-reverse-domains", false, "Add ip6.arpa and in-addr.arpa search domains. Default: false"),
 		AutoRestart:              flag.Bool("auto-restart", true, "Automatically restart systemd-networkd when things change. Default: true"),
-		ConfigFile:               flag.String("config-file", "/etc/zeroplex.conf", "Path to the configuration file"),
+		ConfigFile:               flag.String("config-file", "", "Path to the configuration file. Default: ./zeroplex.yml, then /etc/zeroplex.yml"),
 		ConfigFileC:              flag.String("c", "", "Path to the configuration file (alias)"),
 		ConfigFileShort:          flag.String("config", "", "Path to the configuration file (alias)"),
+		ConfigDir:                flag.String("config-dir", "", "Directory holding zeroplex.yml and conf.d/*.yml, loaded in order"),
+		DecryptionKeyFile:        flag.String("decryption-key-file", "", "age identity file used to decrypt encrypted configuration files or values"),
 		DNSOverTLS:               flag.Bool("dns-over-tls", false, "Automatically prefer DNS-over-TLS. Default: false"),
 		DryRun:                   flag.Bool("dry-run", false, "Enable dry-run mode. No changes will be made."),
+		Force:                    flag.Bool("force", false, "Apply changes even when they exceed the safety limits. Default: false"),
+		Enforce:                  flag.Bool("enforce", true, "Apply changes; when false only detect and report drift. Default: true"),
 		Host:                     flag.String("host", "http://localhost", "ZeroTier client host address. Default: http://localhost"),
 		InterfaceWatchMode:       flag.String("interface-watch-mode", "event", "Interface watch mode: event, poll, or off."),
 		InterfaceWatchRetryCount: flag.Int("interface-watch-retry-count", 3, "Number of retries after interface event."),
@@ -70,15 +82,16 @@ func ParseFlags() (*Flags, map[string]bool) {
 		LogLevel:                 flag.String("log-level", "info", "Set the logging level (info or debug). Default: info"),
 		LogTimestamps:            flag.Bool("log-timestamps", false, "Enable timestamps in logs. Default: false"),
 		LogType:                  flag.String("log-type", "console", "Log output type: console, file, or both. Default: console."),
-		Mode:                     flag.String("mode", "auto", "Mode of operation (networkd, resolved, or auto)."),
+		Mode:                     flag.String("mode", "auto", "Mode of operation (networkd, resolved, networkmanager, resolvconf, resolvfile, dnsmasq, unbound, openwrt, macos, windows, noop, or auto)."),
 		MulticastDNS:             flag.Bool("multicast-dns", false, "Enable Multicast DNS (mDNS). Default: false"),
 		Port:                     flag.Int("port", 9993, "ZeroTier client port number. Default: 9993"),
 		Reconcile:                flag.Bool("reconcile", true, "Automatically remove left networks from systemd-networkd configuration"),
 		RestoreOnExit:            flag.Bool("restore-on-exit", false, "Restore original DNS settings for all managed interfaces on exit (default: false)"),
 		SelectedProfile:          flag.String("profile", "", "Specify a profile to use from the configuration file. Default: none"),
 		Token:                    flag.String("token", "", "API token to use. Overrides token-file if provided."),
-		TokenFile:                flag.String("token-file", "/var/lib/zerotier-one/authtoken.secret", "Path to the ZeroTier authentication token file. Default: /var/lib/zerotier-one/authtoken.secret"),
+		TokenFile:                flag.String("token-file", config.DefaultTokenFile(), "Path to the ZeroTier authentication token file. Default: "+config.DefaultTokenFile()),
 		Banner:                   flag.Bool("banner", true, "Show the startup banner (default: true)"),
+		Timeout:                  flag.String("timeout", "", "Abort a one-shot run that takes longer than this (e.g. 2m). Default: none"),
 	}
 
 	flag.Parse()
@@ -106,7 +119,8 @@ func validateFlagsWithValues() {
 			if strings.HasPrefix(arg, "--") || (len(arg) > 1 && arg[1] != '-') {
 				flagName := strings.TrimLeft(arg, "-")
 				if flagName == "log-level" || flagName == "mode" || flagName == "profile" ||
-					flagName == "host" || flagName == "token" || flagName == "token-file" || flagName == "config-file" {
+					flagName == "host" || flagName == "token" || flagName == "token-file" || flagName == "config-file" ||
+					flagName == "config-dir" || flagName == "decryption-key-file" || flagName == "timeout" {
 
 					hasValue := false
 					if i+1 < len(os.Args) {
@@ -117,7 +131,7 @@ func validateFlagsWithValues() {
 					if !hasValue {
 						fmt.Fprintf(os.Stderr, "Error: Flag -%s requires a value\n", flagName)
 						flag.Usage()
-						os.Exit(1)
+						os.Exit(exitcode.Usage)
 					}
 				}
 			}
@@ -136,6 +150,10 @@ func ApplyExplicitFlags(cfg *config.Config, flags *Flags, explicitFlags map[stri
 	if explicitFlags["dns-over-tls"] {
 		cfg.Default.Features.DNSOverTLS = *flags.DNSOverTLS
 	}
+	if explicitFlags["enforce"] {
+		enforce := *flags.Enforce
+		cfg.Default.Enforce = &enforce
+	}
 	if explicitFlags["host"] {
 		cfg.Default.Client.Host = *flags.Host
 	}
@@ -157,6 +175,9 @@ func ApplyExplicitFlags(cfg *config.Config, flags *Flags, explicitFlags map[stri
 	if explicitFlags["reconcile"] {
 		cfg.Default.Networkd.Reconcile = *flags.Reconcile
 	}
+	if explicitFlags["token"] {
+		cfg.Default.Client.Token = *flags.Token
+	}
 	if explicitFlags["token-file"] {
 		cfg.Default.Client.TokenFile = *flags.TokenFile
 	}
@@ -178,4 +199,7 @@ func ApplyExplicitFlags(cfg *config.Config, flags *Flags, explicitFlags map[stri
 	if explicitFlags["log-file"] {
 		cfg.Default.Log.File = *flags.LogFile
 	}
+	if explicitFlags["timeout"] {
+		cfg.Default.Timeout = *flags.Timeout
+	}
 }
@@ -0,0 +1,31 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package cli
+
+import (
+	"zeroplex/pkg/config"
+
+	"testing"
+)
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestFlagsOnlyOverrideWhenExplicit(t *testing.T) {
+	flags := &Flags{AutoRestart: boolPtr(true), Reconcile: boolPtr(true)}
+	cfg := config.DefaultConfig()
+	cfg.Default.Networkd.AutoRestart = false
+	cfg.Default.Networkd.Reconcile = false
+
+	// The flags default to true, which must not undo a profile or file turning them off
+	ApplyExplicitFlags(&cfg, flags, map[string]bool{})
+	if cfg.Default.Networkd.AutoRestart || cfg.Default.Networkd.Reconcile {
+		t.Errorf("flag defaults overrode the configuration: %+v", cfg.Default.Networkd)
+	}
+
+	ApplyExplicitFlags(&cfg, flags, map[string]bool{"auto-restart": true, "reconcile": true})
+	if !cfg.Default.Networkd.AutoRestart || !cfg.Default.Networkd.Reconcile {
+		t.Errorf("explicit flags were not applied: %+v", cfg.Default.Networkd)
+	}
+}
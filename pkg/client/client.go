@@ -5,9 +5,15 @@
 package client
 
 import (
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/secrets"
+
+	"errors"
 	"fmt"
+	"io/fs"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 )
@@ -19,14 +25,9 @@ type ServiceAPIClient struct {
 }
 
 // NewServiceAPI creates a new authenticated HTTP client for ZeroTier API
-func NewServiceAPI(tokenFile string) (*ServiceAPIClient, error) {
-	content, err := os.ReadFile(tokenFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read token file %s: %w", tokenFile, err)
-	}
-
+func NewServiceAPI(token string) (*ServiceAPIClient, error) {
 	return &ServiceAPIClient{
-		apiKey: strings.TrimSpace(string(content)),
+		apiKey: strings.TrimSpace(token),
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
@@ -43,16 +44,36 @@ func (c *ServiceAPIClient) Do(req *http.Request) (*http.Response, error) {
 	return c.client.Do(req)
 }
 
-// LoadAPIToken loads API token from file or argument
-func LoadAPIToken(tokenFile, tokenArg string) string {
-	if tokenArg != "" {
-		return tokenArg
-	}
+// TokenCredential is the systemd credential the API token is read from when the unit passes one,
+// e.g. with LoadCredential=ztauth:/var/lib/zerotier-one/authtoken.secret
+const TokenCredential = "ztauth"
 
-	content, err := os.ReadFile(tokenFile)
+// LoadAPIToken returns the ZeroTier API token. An inline token wins, then token_source (a secret
+// reference such as vault://, env://, cmd:// or systemd-creds://), token_env or token_command, then
+// the ztauth credential of the unit, then token_file. Every request to the ZeroTier service
+// resolves its token here.
+func LoadAPIToken(cfg config.ClientConfig) (string, error) {
+	if token := strings.TrimSpace(cfg.Token); token != "" {
+		return token, nil
+	}
+	if ref, key := cfg.TokenReference(); ref != "" {
+		token, err := secrets.Resolve(ref)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s: %w", key, err)
+		}
+		return strings.TrimSpace(token), nil
+	}
+	if dir := os.Getenv("CREDENTIALS_DIRECTORY"); dir != "" {
+		// A sandboxed unit can't read token_file, so the credential takes its place
+		if content, err := os.ReadFile(filepath.Join(dir, TokenCredential)); err == nil {
+			return strings.TrimSpace(string(content)), nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("failed to read credential %s: %w", TokenCredential, err)
+		}
+	}
+	content, err := os.ReadFile(cfg.TokenFile)
 	if err != nil {
-		return ""
+		return "", fmt.Errorf("failed to read token file %s: %w", cfg.TokenFile, err)
 	}
-
-	return strings.TrimSpace(string(content))
-}
\ No newline at end of file
+	return strings.TrimSpace(string(content)), nil
+}
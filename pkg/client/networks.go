@@ -0,0 +1,110 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package client
+
+import (
+	"zeroplex/pkg/config"
+
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+// networksCacheTTL is how long Networks reuses a network list. It is short enough that readiness
+// retries see current data, and long enough that the helpers called together on startup or for one
+// event share a request.
+const networksCacheTTL = time.Second
+
+// Client queries the ZeroTier service through the typed service package. One Client is shared by
+// the modes and the runner helpers so they all authenticate, time out and parse the same way.
+// It is safe for concurrent use; concurrent callers wait for a single request.
+type Client struct {
+	cfg config.ClientConfig
+
+	mu        sync.Mutex
+	status    int
+	header    http.Header
+	body      []byte
+	fetchedAt time.Time
+}
+
+// New returns a client for the ZeroTier service configured in cfg
+func New(cfg config.ClientConfig) *Client {
+	return &Client{cfg: cfg}
+}
+
+// BaseURL returns the URL of the ZeroTier service
+func (c *Client) BaseURL() (string, error) {
+	return c.cfg.BaseURL()
+}
+
+// Networks returns the networks joined by the node, from a list fetched less than networksCacheTTL
+// ago if there is one. Each call returns a freshly parsed response, so callers may modify it
+// (filters and rename handling do).
+func (c *Client) Networks(ctx context.Context) (*service.GetNetworksResponse, error) {
+	return c.networks(ctx, networksCacheTTL)
+}
+
+// Refresh queries the networks joined by the node, bypassing the cache. Reconcile runs use it so
+// they always act on the current state.
+func (c *Client) Refresh(ctx context.Context) (*service.GetNetworksResponse, error) {
+	return c.networks(ctx, 0)
+}
+
+func (c *Client) networks(ctx context.Context, maxAge time.Duration) (*service.GetNetworksResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.body == nil || time.Since(c.fetchedAt) >= maxAge {
+		if err := c.fetchNetworks(ctx); err != nil {
+			return nil, err
+		}
+	}
+	networks, err := service.ParseGetNetworksResponse(&http.Response{
+		StatusCode: c.status,
+		Header:     c.header,
+		Body:       io.NopCloser(bytes.NewReader(c.body)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse networks response: %w", err)
+	}
+	return networks, nil
+}
+
+// fetchNetworks queries the network list and caches the response; c.mu is held
+func (c *Client) fetchNetworks(ctx context.Context) error {
+	baseURL, err := c.cfg.BaseURL()
+	if err != nil {
+		return err
+	}
+	token, err := LoadAPIToken(c.cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load API token: %w", err)
+	}
+	sAPI, err := NewServiceAPI(token)
+	if err != nil {
+		return fmt.Errorf("failed to create service API client: %w", err)
+	}
+	ztClient, err := service.NewClient(baseURL, service.WithHTTPClient(sAPI))
+	if err != nil {
+		return fmt.Errorf("failed to create ZeroTier client: %w", err)
+	}
+	resp, err := ztClient.GetNetworks(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get networks: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read API response body: %w", err)
+	}
+	c.status, c.header, c.body, c.fetchedAt = resp.StatusCode, resp.Header, body, time.Now()
+	return nil
+}
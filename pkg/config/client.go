@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// DefaultTokenFile returns where ZeroTier One keeps its API token on this platform
+func DefaultTokenFile() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "/Library/Application Support/ZeroTier/One/authtoken.secret"
+	case "windows":
+		return `C:\ProgramData\ZeroTier\One\authtoken.secret`
+	}
+	return "/var/lib/zerotier-one/authtoken.secret"
+}
+
+// Address splits Host into the scheme, host and port of the ZeroTier service. Host may be a URL
+// ("http://localhost"), a bare name or IP address, a bracketed IPv6 literal ("[::1]") or any of
+// these with a port ("localhost:9993", "http://[::1]:9993"); a port in Host takes precedence over
+// Port. The returned host is unbracketed, with any IPv6 zone unescaped.
+func (c ClientConfig) Address() (scheme, host string, port int, err error) {
+	s := strings.TrimRight(strings.TrimSpace(c.Host), "/")
+	scheme = "http"
+	if before, after, ok := strings.Cut(s, "://"); ok {
+		scheme = strings.ToLower(before)
+		s = after
+	}
+	if scheme != "http" && scheme != "https" {
+		return "", "", 0, fmt.Errorf("invalid client.host %q: scheme must be http or https", c.Host)
+	}
+	if strings.Contains(s, "/") {
+		return "", "", 0, fmt.Errorf("invalid client.host %q: must not contain a path", c.Host)
+	}
+
+	var portStr string
+	switch {
+	case strings.HasPrefix(s, "["):
+		end := strings.Index(s, "]")
+		if end < 0 {
+			return "", "", 0, fmt.Errorf("invalid client.host %q: missing ]", c.Host)
+		}
+		host = strings.ReplaceAll(s[1:end], "%25", "%")
+		rest := s[end+1:]
+		if rest != "" {
+			if !strings.HasPrefix(rest, ":") {
+				return "", "", 0, fmt.Errorf("invalid client.host %q: unexpected %q after ]", c.Host, rest)
+			}
+			portStr = rest[1:]
+		}
+		if addr, err := netip.ParseAddr(host); err != nil || !addr.Is6() {
+			return "", "", 0, fmt.Errorf("invalid client.host %q: %s is not an IPv6 address", c.Host, host)
+		}
+	case strings.Count(s, ":") > 1:
+		// Unbracketed IPv6 literal, which can't carry a port
+		if _, err := netip.ParseAddr(s); err != nil {
+			return "", "", 0, fmt.Errorf("invalid client.host %q: %v", c.Host, err)
+		}
+		host = s
+	default:
+		host, portStr, _ = strings.Cut(s, ":")
+	}
+	if host == "" {
+		return "", "", 0, fmt.Errorf("invalid client.host %q: missing host", c.Host)
+	}
+
+	port = c.Port
+	if portStr != "" {
+		port, err = strconv.Atoi(portStr)
+		if err != nil {
+			return "", "", 0, fmt.Errorf("invalid client.host %q: bad port %q", c.Host, portStr)
+		}
+	}
+	if port < 1 || port > 65535 {
+		return "", "", 0, fmt.Errorf("invalid ZeroTier service port %d (must be 1-65535)", port)
+	}
+	return scheme, host, port, nil
+}
+
+// BaseURL returns the URL of the ZeroTier service, without a trailing slash, for Host and Port as
+// accepted by Address
+func (c ClientConfig) BaseURL() (string, error) {
+	scheme, host, port, err := c.Address()
+	if err != nil {
+		return "", err
+	}
+	return scheme + "://" + net.JoinHostPort(strings.ReplaceAll(host, "%", "%25"), strconv.Itoa(port)), nil
+}
@@ -5,10 +5,16 @@
 package config
 
 import (
+	"zeroplex/pkg/exitcode"
+	"zeroplex/pkg/utils"
+
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -21,59 +27,378 @@ type LogConfig struct {
 }
 
 type DaemonConfig struct {
-	Enabled      bool   `yaml:"enabled"`
-	PollInterval string `yaml:"poll_interval"`
+	Enabled        bool   `yaml:"enabled"`
+	PollInterval   string `yaml:"poll_interval"`
+	StartJitter    string `yaml:"start_jitter"`
+	SkipInitialRun bool   `yaml:"skip_initial_run"`
+	DBus           bool   `yaml:"dbus,omitempty"`
+	WatchConfig    bool   `yaml:"watch_config,omitempty"` // reload when the configuration files change
 }
 
 type ClientConfig struct {
-	Host      string `yaml:"host"`
-	Port      int    `yaml:"port"`
-	TokenFile string `yaml:"token_file"`
+	Host        string `yaml:"host"`
+	Port        int    `yaml:"port"`
+	Token       string `yaml:"token,omitempty"`
+	TokenFile   string `yaml:"token_file"`
+	TokenSource string `yaml:"token_source,omitempty"`
+	// TokenEnv names an environment variable holding the token, TokenCommand is a shell command
+	// printing it; shorthands for token_source env:// and cmd://
+	TokenEnv     string `yaml:"token_env,omitempty"`
+	TokenCommand string `yaml:"token_command,omitempty"`
+}
+
+// TokenReference returns the secret reference the token is resolved from, of token_source,
+// token_env or token_command, and the key that set it
+func (c ClientConfig) TokenReference() (string, string) {
+	switch {
+	case c.TokenSource != "":
+		return c.TokenSource, "token_source"
+	case c.TokenEnv != "":
+		return "env://" + c.TokenEnv, "token_env"
+	case c.TokenCommand != "":
+		return "cmd://" + c.TokenCommand, "token_command"
+	}
+	return "", ""
 }
 
 type FeaturesConfig struct {
 	DNSOverTLS         bool     `yaml:"dns_over_tls"`
 	AddReverseDomains  bool     `yaml:"add_reverse_domains"`
 	MulticastDNS       bool     `yaml:"multicast_dns"`
+	MDNSConflict       string   `yaml:"mdns_conflict,omitempty"`
+	MDNSAdvertise      bool     `yaml:"mdns_advertise,omitempty"`
 	RestoreOnExit      bool     `yaml:"restore_on_exit"`
 	WatchdogIP         string   `yaml:"watchdog_ip"`
 	WatchdogInterval   string   `yaml:"watchdog_interval"`
 	WatchdogBackoff    []string `yaml:"watchdog_backoff"`
 	WatchdogHostname   string   `yaml:"watchdog_hostname"`
-	WatchdogExpectedIP string   `yaml:"watchdog_expected_ip"`
+	WatchdogExpectedIP string   `yaml:"watchdog_expected_ip"` // comma separated IPv4 and IPv6 addresses
+	ExtraSearchDomains []string `yaml:"extra_search_domains,omitempty"`
+	VerifyHostname     string   `yaml:"verify_hostname,omitempty"`
+	VerifyTimeout      string   `yaml:"verify_timeout,omitempty"`
+	// WatchdogNetworks enables the hostname watchdog per ZeroTier network, keyed by network ID
+	WatchdogNetworks map[string]NetworkWatchdogConfig `yaml:"watchdog_networks,omitempty"`
+	// WatchdogExec is a site-provided probe command; exit code 0 is healthy, 1 unhealthy
+	WatchdogExec        []string `yaml:"watchdog_exec,omitempty"`
+	WatchdogExecTimeout string   `yaml:"watchdog_exec_timeout,omitempty"` // default: 10s
+}
+
+// NetworkWatchdogConfig is the hostname watchdog for a single ZeroTier network
+type NetworkWatchdogConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Hostname   string `yaml:"hostname,omitempty"`    // default: %hostname%.%domain%
+	ExpectedIP string `yaml:"expected_ip,omitempty"` // comma separated; default: this node's addresses on the network
 }
 
 type NetworkdConfig struct {
-	AutoRestart bool `yaml:"auto_restart"`
-	Reconcile   bool `yaml:"reconcile"`
+	AutoRestart       bool   `yaml:"auto_restart"`
+	Reconcile         bool   `yaml:"reconcile"`
+	MaxReloadsPerHour int    `yaml:"max_reloads_per_hour,omitempty"` // 0: unlimited
+	WaitForCarrier    string `yaml:"wait_for_carrier,omitempty"`     // how long the first reload waits for the carrier of the ZeroTier interfaces, e.g. "30s"
+}
+
+// DnsmasqConfig configures the dnsmasq mode
+type DnsmasqConfig struct {
+	ConfigDir   string `yaml:"config_dir,omitempty"`   // default: /etc/dnsmasq.d
+	ServersFile string `yaml:"servers_file,omitempty"` // one file for all networks, loaded with servers-file=
+	Service     string `yaml:"service,omitempty"`      // default: dnsmasq
+	Reload      bool   `yaml:"reload"`
+}
+
+// UnboundConfig configures the unbound mode
+type UnboundConfig struct {
+	IncludeFile string `yaml:"include_file,omitempty"` // default: /etc/unbound/unbound.conf.d/zeroplex.conf
+	Control     bool   `yaml:"control"`                // add zones at runtime with unbound-control instead
+	Service     string `yaml:"service,omitempty"`      // default: unbound
+	Reload      bool   `yaml:"reload"`
+	Reconcile   bool   `yaml:"reconcile"`
+}
+
+// OpenWrtConfig configures the openwrt mode
+type OpenWrtConfig struct {
+	Section       string `yaml:"section,omitempty"` // uci section of /etc/config/dhcp; default: @dnsmasq[0]
+	RebindDomains bool   `yaml:"rebind_domains"`    // exempt the domains from dnsmasq's rebind protection
+	Reload        bool   `yaml:"reload"`
+}
+
+// WindowsConfig configures the windows mode
+type WindowsConfig struct {
+	NRPT         bool `yaml:"nrpt"`          // route the domains to the network's servers with NRPT rules
+	InterfaceDNS bool `yaml:"interface_dns"` // also set the servers and suffix of the adapter
 }
 
 type InterfaceWatchRetry struct {
-	Count    int      `yaml:"count"`
-	Delay    string   `yaml:"delay"`
-	Backoff  []string `yaml:"backoff"`
-	MaxTotal string   `yaml:"max_total"`
+	Count         int      `yaml:"count"`
+	Delay         string   `yaml:"delay"`
+	Backoff       []string `yaml:"backoff"`
+	MaxTotal      string   `yaml:"max_total"`
+	GlobalTimeout string   `yaml:"global_timeout"`
+	MaxConcurrent int      `yaml:"max_concurrent"`
+}
+
+// WebhookConfig describes an HTTP endpoint that receives event notifications as JSON
+type WebhookConfig struct {
+	URL     string   `yaml:"url"`
+	Events  []string `yaml:"events,omitempty"`
+	Secret  string   `yaml:"secret,omitempty"`
+	Timeout string   `yaml:"timeout,omitempty"`
+}
+
+// ControlConfig enables the local control API used by `zeroplex top`
+type ControlConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Socket  string `yaml:"socket,omitempty"` // default: /run/zeroplex/control.sock
+	User    string `yaml:"user,omitempty"`   // owns the socket, and may run commands like root
+	Group   string `yaml:"group,omitempty"`  // may read the status over the socket
+	Mode    string `yaml:"mode,omitempty"`   // octal permissions of the socket; default: 0600, 0660 with a group
+	// AdminGroup may also run the commands: apply, restore, reload, pause and resume
+	AdminGroup string `yaml:"admin_group,omitempty"`
+}
+
+// HealthConfig enables the HTTP liveness and readiness endpoints probed by container runtimes and
+// service managers
+type HealthConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Listen  string `yaml:"listen,omitempty"` // host:port; default: 127.0.0.1:9780
+}
+
+// HardeningConfig is the hardening the process applies to itself at startup
+type HardeningConfig struct {
+	Umask            string `yaml:"umask,omitempty"`    // octal, e.g. "0077"
+	NoFile           uint64 `yaml:"nofile,omitempty"`   // RLIMIT_NOFILE, soft and hard
+	DisableCoreDumps bool   `yaml:"disable_core_dumps"` // RLIMIT_CORE 0, so no core dump holds the API token
+	LockStateDir     bool   `yaml:"lock_state_dir"`     // make the state directory 0700, owned by user if set
+	User             string `yaml:"user,omitempty"`     // daemon mode only: user to drop to once the sockets are bound
+	Group            string `yaml:"group,omitempty"`    // default: the user's primary group
+}
+
+// GRPCConfig enables the gRPC management API used by fleet management agents
+type GRPCConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Listen  string `yaml:"listen,omitempty"` // unix:PATH or host:port; default: unix:/run/zeroplex/grpc.sock
+}
+
+// SafetyConfig limits how much a single reconcile run may change, so a bad filter or a controller
+// glitch that suddenly matches nothing can't tear down every interface at once
+type SafetyConfig struct {
+	MaxChangesPerRun  int    `yaml:"max_changes_per_run,omitempty"`  // 0: unlimited
+	MaxRemovalsPerRun int    `yaml:"max_removals_per_run,omitempty"` // 0: unlimited
+	Ack               string `yaml:"ack,omitempty"`                  // plan ID of a blocked run to let through
+	KeepOnEmptyFilter bool   `yaml:"keep_on_empty_filter"`           // abort a run whose filters select no network
+}
+
+// MaintenanceConfig defers changes during time windows, for sites where resolver changes are only
+// allowed outside business hours. Drift found inside a window is reported, not corrected.
+type MaintenanceConfig struct {
+	DeferWindows []string `yaml:"defer_windows,omitempty"` // cron expressions; each matching minute is deferred
+	Timezone     string   `yaml:"timezone,omitempty"`      // IANA zone the windows are in (default: local time)
+}
+
+// ResolvWatchConfig watches resolver configuration files for rewrites by other software, such as VPN
+// clients and DHCP hooks replacing /etc/resolv.conf, that bypass the split DNS zeroplex set up
+type ResolvWatchConfig struct {
+	Enabled  bool     `yaml:"enabled"`
+	Paths    []string `yaml:"paths,omitempty"`    // default: /etc/resolv.conf and /run/systemd/resolve/stub-resolv.conf
+	Interval string   `yaml:"interval,omitempty"` // default: 5s
+	Reassert bool     `yaml:"reassert"`           // start a reconcile run after a rewrite
+}
+
+// ServerOrderConfig decides the order the DNS servers of a network are applied in, which is the
+// order resolvers try them in
+type ServerOrderConfig struct {
+	Strategy string `yaml:"strategy,omitempty"` // pushed (as ZeroTier pushes them) or rtt (fastest first); default: pushed
+	Interval string `yaml:"interval,omitempty"` // how long a round-trip time is reused before it is measured again; default: 5m
+}
+
+// ServerOrderStrategies are the values of server_order.strategy
+var ServerOrderStrategies = []string{"pushed", "rtt"}
+
+// CoordinationConfig decides what happens when another DNS manager (tailscaled, an OpenVPN
+// update-resolv-conf hook, NetworkManager split DNS) routes a domain zeroplex also manages
+type CoordinationConfig struct {
+	Enabled bool              `yaml:"enabled"`
+	Policy  string            `yaml:"policy,omitempty"`  // yield, override or merge; default: merge
+	Domains map[string]string `yaml:"domains,omitempty"` // policy per domain (and its subdomains)
+}
+
+// PolicyFor returns the coordination policy of a domain: that of the longest matching entry of
+// domains, or the default policy
+func (c CoordinationConfig) PolicyFor(domain string) string {
+	policy, matched := c.Policy, -1
+	for key, value := range c.Domains {
+		key = strings.ToLower(strings.TrimSuffix(key, "."))
+		if (domain == key || strings.HasSuffix(domain, "."+key)) && len(key) > matched {
+			policy, matched = value, len(key)
+		}
+	}
+	if policy == "" {
+		return "merge"
+	}
+	return strings.ToLower(policy)
+}
+
+// Deferring reports whether t falls inside one of the defer windows, and which
+func (m MaintenanceConfig) Deferring(t time.Time) (string, bool) {
+	if len(m.DeferWindows) == 0 {
+		return "", false
+	}
+	if m.Timezone != "" {
+		if loc, err := time.LoadLocation(m.Timezone); err == nil {
+			t = t.In(loc)
+		}
+	}
+	for _, expr := range m.DeferWindows {
+		if window, err := utils.ParseCronWindow(expr); err == nil && window.Contains(t) {
+			return expr, true
+		}
+	}
+	return "", false
 }
 
 type InterfaceWatch struct {
-	Mode  string              `yaml:"mode"`
-	Retry InterfaceWatchRetry `yaml:"retry"`
+	Mode      string              `yaml:"mode"`
+	Retry     InterfaceWatchRetry `yaml:"retry"`
+	Readiness string              `yaml:"readiness,omitempty"` // readiness strategy of every network; default: api-status
+	Checks    []string            `yaml:"checks,omitempty"`    // strategies all required by the composite strategy
+	// Networks sets the readiness requirements per ZeroTier network, keyed by network ID
+	Networks map[string]NetworkReadinessConfig `yaml:"networks,omitempty"`
+}
+
+// InterfaceWatchModes are the values of interface_watch.mode
+var InterfaceWatchModes = []string{"event", "poll", "off"}
+
+// ReadinessStrategies are the ways of deciding that an interface is ready for DNS to be applied
+var ReadinessStrategies = []string{"api-status", "address-present", "route-present", "dns-probe", "composite"}
+
+// NetworkReadinessConfig is what a single ZeroTier network's interface needs before DNS is applied to it
+type NetworkReadinessConfig struct {
+	Readiness string   `yaml:"readiness,omitempty"` // default: interface_watch.readiness
+	Checks    []string `yaml:"checks,omitempty"`    // default: interface_watch.checks
+	DualStack bool     `yaml:"dual_stack"`          // wait for both an IPv4 and an IPv6 address to be assigned
+}
+
+// NetworkOverride changes how a single ZeroTier network is configured. Unset keys keep the
+// settings of the profile.
+type NetworkOverride struct {
+	Mode               string   `yaml:"mode,omitempty"`                 // manage the network with another mode
+	DNSServers         []string `yaml:"dns_servers,omitempty"`          // instead of the servers ZeroTier pushes
+	ExtraSearchDomains []string `yaml:"extra_search_domains,omitempty"` // instead of features.extra_search_domains
+	DNSOverTLS         *bool    `yaml:"dns_over_tls,omitempty"`
+	MulticastDNS       *bool    `yaml:"multicast_dns,omitempty"`
+	HoldDown           string   `yaml:"hold_down,omitempty"` // apply DNS only once the network has been ready this long
 }
 
+// Modes are the values of mode
+var Modes = []string{"auto", "networkd", "resolved", "networkmanager", "resolvconf", "resolvfile", "dnsmasq", "unbound", "openwrt", "macos", "windows", "noop"}
+
+// LogLevels are the values of log.level
+var LogLevels = []string{"error", "warn", "info", "verbose", "debug", "trace"}
+
 type Profile struct {
-	Mode           string                   `yaml:"mode"`
-	Log            LogConfig                `yaml:"log"`
-	Daemon         DaemonConfig             `yaml:"daemon"`
-	Client         ClientConfig             `yaml:"client"`
-	Features       FeaturesConfig           `yaml:"features"`
-	Networkd       NetworkdConfig           `yaml:"networkd"`
-	InterfaceWatch InterfaceWatch           `yaml:"interface_watch"`
-	Filters        []map[string]interface{} `yaml:"filters,omitempty"`
+	Mode           string             `yaml:"mode"`
+	InitSystem     string             `yaml:"init_system,omitempty"`
+	Enforce        *bool              `yaml:"enforce,omitempty"`
+	Log            LogConfig          `yaml:"log"`
+	Daemon         DaemonConfig       `yaml:"daemon"`
+	Client         ClientConfig       `yaml:"client"`
+	Features       FeaturesConfig     `yaml:"features"`
+	Networkd       NetworkdConfig     `yaml:"networkd"`
+	Dnsmasq        DnsmasqConfig      `yaml:"dnsmasq,omitempty"`
+	Unbound        UnboundConfig      `yaml:"unbound,omitempty"`
+	OpenWrt        OpenWrtConfig      `yaml:"openwrt,omitempty"`
+	Windows        WindowsConfig      `yaml:"windows,omitempty"`
+	InterfaceWatch InterfaceWatch     `yaml:"interface_watch"`
+	Control        ControlConfig      `yaml:"control,omitempty"`
+	Health         HealthConfig       `yaml:"health,omitempty"`
+	GRPC           GRPCConfig         `yaml:"grpc,omitempty"`
+	Hardening      HardeningConfig    `yaml:"hardening,omitempty"`
+	Safety         SafetyConfig       `yaml:"safety,omitempty"`
+	Maintenance    MaintenanceConfig  `yaml:"maintenance,omitempty"`
+	ResolvWatch    ResolvWatchConfig  `yaml:"resolv_watch,omitempty"`
+	Coordination   CoordinationConfig `yaml:"coordination,omitempty"`
+	ServerOrder    ServerOrderConfig  `yaml:"server_order,omitempty"`
+	// Networks overrides settings per ZeroTier network, keyed by network ID
+	Networks    map[string]NetworkOverride `yaml:"networks,omitempty"`
+	Filters     []map[string]interface{}   `yaml:"filters,omitempty"`
+	Webhooks    []WebhookConfig            `yaml:"webhooks,omitempty"`
+	Labels      map[string]string          `yaml:"labels,omitempty"`
+	ConfigEpoch string                     `yaml:"config_epoch,omitempty"` // generation of a staged configuration rollout
+	StateDir    string                     `yaml:"state_dir,omitempty"`    // default: /var/lib/zeroplex
+	Timeout     string                     `yaml:"timeout,omitempty"`      // one-shot runs are aborted after this long
+	// Experimental turns on experimental features by name, see ExperimentalFeatures
+	Experimental map[string]bool `yaml:"experimental,omitempty"`
+}
+
+// ExperimentalFeatures are the features that ship turned off, to be turned on by name under
+// experimental: while they are tried out. A feature leaves this list once it graduates to a regular
+// setting or is dropped.
+var ExperimentalFeatures = []string{
+	"resolved_dbus", // resolved mode sets link DNS over D-Bus instead of running resolvectl
+}
+
+// ExperimentEnabled reports whether the experimental feature called name is turned on
+func (p Profile) ExperimentEnabled(name string) bool {
+	return p.Experimental[name]
+}
+
+// ActiveExperiments returns the experimental features that are turned on, sorted
+func (p Profile) ActiveExperiments() []string {
+	var active []string
+	for name, enabled := range p.Experimental {
+		if enabled {
+			active = append(active, name)
+		}
+	}
+	slices.Sort(active)
+	return active
+}
+
+// NetworkOverride returns the overrides of the network with the given ID, if it has any
+func (p Profile) NetworkOverride(id string) (NetworkOverride, bool) {
+	for key, override := range p.Networks {
+		if strings.EqualFold(key, id) {
+			return override, true
+		}
+	}
+	return NetworkOverride{}, false
+}
+
+// NetworkMode returns the mode managing the network with the given ID: its override, or mode
+func (p Profile) NetworkMode(id string) string {
+	if override, ok := p.NetworkOverride(id); ok && override.Mode != "" {
+		return strings.ToLower(override.Mode)
+	}
+	return p.Mode
+}
+
+// ActiveModes returns mode followed by the other modes networks are set to manage them, sorted
+func (p Profile) ActiveModes() []string {
+	modes := []string{p.Mode}
+	var others []string
+	for _, override := range p.Networks {
+		mode := strings.ToLower(override.Mode)
+		if mode != "" && !slices.Contains(modes, mode) && !slices.Contains(others, mode) {
+			others = append(others, mode)
+		}
+	}
+	slices.Sort(others)
+	return append(modes, others...)
+}
+
+// Enforcing reports whether changes should be applied; with enforce: false drift is only reported
+func (p Profile) Enforcing() bool {
+	return p.Enforce == nil || *p.Enforce
 }
 
 type Config struct {
 	Default  Profile            `yaml:"default"`
 	Profiles map[string]Profile `yaml:"profiles"`
+
+	// profileNodes keeps the YAML of each loaded profile, in the order the files were loaded, so
+	// SelectProfile overrides exactly the keys a profile sets, including booleans set to false
+	profileNodes map[string][]*yaml.Node
+	// sources keeps every loaded file, so ValidateConfig can tell where a key was set
+	sources []configSource
 }
 
 // HasAdvancedFilters checks if the profile has advanced filters configured
@@ -98,7 +423,8 @@ func (p Profile) GetAdvancedFilterConfig() (map[string]interface{}, error) {
 func DefaultConfig() Config {
 	return Config{
 		Default: Profile{
-			Mode: "auto",
+			Mode:       "auto",
+			InitSystem: "auto",
 			Log: LogConfig{
 				Level:      "verbose",
 				Type:       "console",
@@ -112,12 +438,43 @@ func DefaultConfig() Config {
 			Client: ClientConfig{
 				Host:      "http://localhost",
 				Port:      9993,
-				TokenFile: "/var/lib/zerotier-one/authtoken.secret",
+				TokenFile: DefaultTokenFile(),
 			},
 			Networkd: NetworkdConfig{
 				AutoRestart: true,
 				Reconcile:   true,
 			},
+			Dnsmasq: DnsmasqConfig{
+				ConfigDir: "/etc/dnsmasq.d",
+				Service:   "dnsmasq",
+				Reload:    true,
+			},
+			Unbound: UnboundConfig{
+				IncludeFile: "/etc/unbound/unbound.conf.d/zeroplex.conf",
+				Service:     "unbound",
+				Reload:      true,
+				Reconcile:   true,
+			},
+			OpenWrt: OpenWrtConfig{
+				Section:       "@dnsmasq[0]",
+				RebindDomains: true,
+				Reload:        true,
+			},
+			Windows: WindowsConfig{
+				NRPT: true,
+			},
+			ResolvWatch: ResolvWatchConfig{
+				Interval: "5s",
+				Reassert: true,
+			},
+			Coordination: CoordinationConfig{
+				Enabled: true,
+				Policy:  "merge",
+			},
+			ServerOrder: ServerOrderConfig{
+				Strategy: "pushed",
+				Interval: "5m",
+			},
 			Features: FeaturesConfig{
 				DNSOverTLS:        false,
 				AddReverseDomains: false,
@@ -137,28 +494,52 @@ func DefaultConfig() Config {
 }
 
 func LoadConfig(filePath string) (Config, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return Config{}, err
-	}
-	defer file.Close()
-
-	var config Config
-
-	ext := strings.ToLower(filepath.Ext(filePath))
+	return LoadConfigFiles(filePath)
+}
 
-	switch ext {
-	case ".yaml", ".yml":
-		decoder := yaml.NewDecoder(file)
-		if err := decoder.Decode(&config); err != nil {
-			return Config{}, fmt.Errorf("failed to parse YAML config: %w", err)
+// LoadConfigFiles loads configuration files in order, each one overriding the keys it sets in the
+// ones before it. Profiles defined in several files are layered the same way.
+func LoadConfigFiles(filePaths ...string) (Config, error) {
+	// Keys the files leave out keep their built-in defaults
+	config := DefaultConfig()
+	config.profileNodes = map[string][]*yaml.Node{}
+	for _, filePath := range filePaths {
+		if err := decodeConfigFile(&config, filePath); err != nil {
+			if len(filePaths) > 1 {
+				return Config{}, fmt.Errorf("%s: %w", filePath, err)
+			}
+			return Config{}, err
 		}
+	}
+	return config, nil
+}
 
-	default:
-		return Config{}, fmt.Errorf("unsupported config file format: %s (supported: .yaml, .yml)", ext)
+// decodeConfigFile decodes one configuration file over config
+func decodeConfigFile(config *Config, filePath string) error {
+	content, err := readConfigContent(filePath)
+	if err != nil {
+		return err
 	}
 
-	return config, nil
+	ext := strings.ToLower(filepath.Ext(strings.TrimSuffix(filePath, ".age")))
+	root, err := parseDocument(content, ext)
+	if err != nil {
+		return err
+	}
+	if len(root.Content) == 0 {
+		return nil
+	}
+	if err := decryptValues(root); err != nil {
+		return fmt.Errorf("failed to decrypt config values: %w", err)
+	}
+	if err := root.Decode(config); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+	for name, node := range profileNodes(root) {
+		config.profileNodes[name] = append(config.profileNodes[name], node)
+	}
+	config.sources = append(config.sources, configSource{file: filePath, root: root})
+	return nil
 }
 
 func LoadConfiguration(configFile string) Config {
@@ -169,7 +550,7 @@ func LoadConfiguration(configFile string) Config {
 			if err != nil {
 				if configFile != "/etc/zeroplex.yaml" {
 					fmt.Fprintf(os.Stderr, "ERROR: Configuration file %s not found: %v\n", configFile, err)
-					os.Exit(1)
+					os.Exit(exitcode.Config)
 				}
 				return DefaultConfig()
 			}
@@ -185,87 +566,187 @@ func LoadConfiguration(configFile string) Config {
 		} else if os.IsNotExist(err) {
 			if configFile != "/etc/zeroplex.yaml" {
 				fmt.Fprintf(os.Stderr, "ERROR: Configuration file %s not found: %v\n", configFile, err)
-				os.Exit(1)
+				os.Exit(exitcode.Config)
 			}
 		} else {
 			fmt.Fprintf(os.Stderr, "ERROR: Checking configuration file existence: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitcode.Config)
 		}
 	}
 
 	return DefaultConfig()
 }
 
+// ValidateConfig checks every key of default: and of the profiles against the schema of schema.go.
+// It returns all the problems found, each with the file, line and column it was set at, as a
+// ValidationError.
 func ValidateConfig(cfg *Config) error {
-	if cfg.Default.Client.Host == "" {
-		return fmt.Errorf("missing required configuration: client.host")
-	}
-	if cfg.Default.Client.Port == 0 {
-		return fmt.Errorf("missing required configuration: client.port")
+	v := &validator{sources: cfg.sources}
+	for _, source := range cfg.sources {
+		if len(source.root.Content) > 0 {
+			v.unknownKeys(nil, source.root.Content[0], reflect.TypeOf(Config{}))
+		}
 	}
 
-	mode := strings.ToLower(cfg.Default.Mode)
-	if mode != "auto" && mode != "networkd" && mode != "resolved" {
-		return fmt.Errorf("invalid mode: %s (must be auto, networkd, or resolved)", cfg.Default.Mode)
+	client := cfg.Default.Client
+	if client.Host == "" {
+		v.report([]string{"default", "client", "host"}, "", "missing required configuration: client.host")
+	} else if _, _, _, err := client.Address(); err != nil {
+		if client.Port == 0 {
+			v.report([]string{"default", "client", "port"}, "", "missing required configuration: client.port")
+		} else {
+			v.report([]string{"default", "client", "host"}, client.Host, "%v", err)
+		}
 	}
-
-	logLevel := strings.ToLower(cfg.Default.Log.Level)
-	if logLevel != "error" && logLevel != "warn" && logLevel != "info" && logLevel != "verbose" && logLevel != "debug" && logLevel != "trace" {
-		return fmt.Errorf("invalid log level: %s (must be error, warn, info, verbose, debug, or trace)", cfg.Default.Log.Level)
+	if cfg.Default.Mode == "" {
+		v.report([]string{"default", "mode"}, "", "missing required configuration: mode")
+	}
+	if cfg.Default.Log.Level == "" {
+		v.report([]string{"default", "log", "level"}, "", "missing required configuration: log.level")
 	}
+	v.profile([]string{"default"}, cfg.Default)
 
-	// Validate profiles
-	for name, profile := range cfg.Profiles {
-		if profile.Mode != "" {
-			mode = strings.ToLower(profile.Mode)
-			if mode != "auto" && mode != "networkd" && mode != "resolved" {
-				return fmt.Errorf("invalid mode in profile %s: %s (must be auto, networkd, or resolved)",
-					name, profile.Mode)
-			}
-		}
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		v.profile([]string{"profiles", name}, cfg.Profiles[name])
+	}
 
-		if profile.Log.Level != "" {
-			logLevel = strings.ToLower(profile.Log.Level)
-			if logLevel != "error" && logLevel != "warn" && logLevel != "info" && logLevel != "verbose" && logLevel != "debug" && logLevel != "trace" {
-				return fmt.Errorf("invalid log level in profile %s: %s (must be error, warn, info, verbose, debug, or trace)",
-					name, profile.Log.Level)
-			}
-		}
+	if len(v.errs) == 0 {
+		return nil
 	}
+	return v.errs
+}
 
-	return nil
+// MergeLabels overlays override on base, returning a new map
+func MergeLabels(base, override map[string]string) map[string]string {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
 }
 
+// SaveConfig writes config to filePath, as YAML, TOML or JSON by its extension
 func SaveConfig(filePath string, config Config) error {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if !slices.Contains(Extensions, ext) {
+		return fmt.Errorf("unsupported config file format: %s (supported: %s)", ext, strings.Join(Extensions, ", "))
+	}
 	file, err := os.Create(filePath)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
+	return encodeConfig(file, config, ext)
+}
 
-	ext := strings.ToLower(filepath.Ext(filePath))
-	switch ext {
-	case ".yaml", ".yml":
-		encoder := yaml.NewEncoder(file)
-		defer encoder.Close()
-		encoder.SetIndent(2)
-		if err := encoder.Encode(config); err != nil {
-			return fmt.Errorf("failed to encode YAML config: %w", err)
+// SelectProfile returns the default profile with the named profile layered over it, and whether the
+// profile exists. Together with the built-in defaults under the file and the explicit flags applied
+// afterwards this makes the precedence chain: defaults < default: < profiles.<name>: < ZEROPLEX_*
+// environment < flags.
+// Every key a loaded profile sets wins, so a profile can also turn off what default: turns on.
+func (c Config) SelectProfile(name string) (Profile, bool) {
+	selected, ok := c.Profiles[name]
+	if !ok {
+		return c.Default, false
+	}
+	nodes := c.profileNodes[name]
+	if len(nodes) == 0 {
+		// Built in code rather than loaded, so which keys were set is unknown
+		return MergeProfiles(c.Default, selected), true
+	}
+	// Decoding writes through pointers and into maps, so those must not be shared with c.Default
+	merged := c.Default
+	if c.Default.Enforce != nil {
+		enforce := *c.Default.Enforce
+		merged.Enforce = &enforce
+	}
+	merged.Labels = cloneMap(c.Default.Labels)
+	merged.Features.WatchdogNetworks = cloneMap(c.Default.Features.WatchdogNetworks)
+	merged.InterfaceWatch.Networks = cloneMap(c.Default.InterfaceWatch.Networks)
+	merged.Networks = cloneMap(c.Default.Networks)
+	merged.Coordination.Domains = cloneMap(c.Default.Coordination.Domains)
+	merged.Experimental = cloneMap(c.Default.Experimental)
+	for _, node := range nodes {
+		if err := node.Decode(&merged); err != nil {
+			// The same node decoded when the file was loaded, so this would be a bug
+			return MergeProfiles(c.Default, selected), true
 		}
+	}
+	if selected.Client.TokenSource != "" || selected.Client.TokenEnv != "" || selected.Client.TokenCommand != "" {
+		// Exclusive, so the one the profile names replaces whichever default set
+		merged.Client.TokenSource = selected.Client.TokenSource
+		merged.Client.TokenEnv = selected.Client.TokenEnv
+		merged.Client.TokenCommand = selected.Client.TokenCommand
+	}
+	return merged, true
+}
 
-	default:
-		return fmt.Errorf("unsupported config file format: %s (supported: .yaml, .yml)", ext)
+// profileNodes returns the YAML node of every profile under profiles:
+func profileNodes(root *yaml.Node) map[string]*yaml.Node {
+	nodes := map[string]*yaml.Node{}
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return nodes
+	}
+	profiles := mappingValue(root.Content[0], "profiles")
+	if profiles == nil || profiles.Kind != yaml.MappingNode {
+		return nodes
 	}
+	for i := 0; i+1 < len(profiles.Content); i += 2 {
+		nodes[profiles.Content[i].Value] = profiles.Content[i+1]
+	}
+	return nodes
+}
 
+// mappingValue returns the value of key in a YAML mapping node, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
 	return nil
 }
 
+func cloneMap[K comparable, V any](m map[K]V) map[K]V {
+	if m == nil {
+		return nil
+	}
+	clone := make(map[K]V, len(m))
+	for k, v := range m {
+		clone[k] = v
+	}
+	return clone
+}
+
+// MergeProfiles layers selectedProfile over defaultProfile field by field. It is the fallback of
+// SelectProfile for profiles built in code: a zero value there can't be told from an unset key, so
+// it can't turn a boolean off.
 func MergeProfiles(defaultProfile, selectedProfile Profile) Profile {
 	mergedProfile := defaultProfile
 
 	if selectedProfile.Mode != "" {
 		mergedProfile.Mode = selectedProfile.Mode
 	}
+	if selectedProfile.InitSystem != "" {
+		mergedProfile.InitSystem = selectedProfile.InitSystem
+	}
+	if selectedProfile.Enforce != nil {
+		mergedProfile.Enforce = selectedProfile.Enforce
+	}
 
 	// Merge Log Config
 	if selectedProfile.Log.Level != "" {
@@ -286,6 +767,18 @@ func MergeProfiles(defaultProfile, selectedProfile Profile) Profile {
 	if selectedProfile.Daemon.PollInterval != "" {
 		mergedProfile.Daemon.PollInterval = selectedProfile.Daemon.PollInterval
 	}
+	if selectedProfile.Daemon.StartJitter != "" {
+		mergedProfile.Daemon.StartJitter = selectedProfile.Daemon.StartJitter
+	}
+	if selectedProfile.Daemon.SkipInitialRun {
+		mergedProfile.Daemon.SkipInitialRun = true
+	}
+	if selectedProfile.Daemon.DBus {
+		mergedProfile.Daemon.DBus = true
+	}
+	if selectedProfile.Daemon.WatchConfig {
+		mergedProfile.Daemon.WatchConfig = true
+	}
 
 	// Merge Client Config
 	if selectedProfile.Client.Host != "" {
@@ -294,15 +787,30 @@ func MergeProfiles(defaultProfile, selectedProfile Profile) Profile {
 	if selectedProfile.Client.Port != 0 {
 		mergedProfile.Client.Port = selectedProfile.Client.Port
 	}
+	if selectedProfile.Client.Token != "" {
+		mergedProfile.Client.Token = selectedProfile.Client.Token
+	}
+	if selectedProfile.Client.TokenSource != "" || selectedProfile.Client.TokenEnv != "" || selectedProfile.Client.TokenCommand != "" {
+		// The three are exclusive, so a profile naming one replaces whichever default set
+		mergedProfile.Client.TokenSource = selectedProfile.Client.TokenSource
+		mergedProfile.Client.TokenEnv = selectedProfile.Client.TokenEnv
+		mergedProfile.Client.TokenCommand = selectedProfile.Client.TokenCommand
+	}
 	if selectedProfile.Client.TokenFile != "" {
 		mergedProfile.Client.TokenFile = selectedProfile.Client.TokenFile
 	} else if mergedProfile.Client.TokenFile == "" {
-		mergedProfile.Client.TokenFile = "/var/lib/zerotier-one/authtoken.secret"
+		mergedProfile.Client.TokenFile = DefaultTokenFile()
 	}
 
 	// Merge Networkd Config
 	mergedProfile.Networkd.AutoRestart = mergedProfile.Networkd.AutoRestart || selectedProfile.Networkd.AutoRestart
 	mergedProfile.Networkd.Reconcile = mergedProfile.Networkd.Reconcile || selectedProfile.Networkd.Reconcile
+	if selectedProfile.Networkd.MaxReloadsPerHour != 0 {
+		mergedProfile.Networkd.MaxReloadsPerHour = selectedProfile.Networkd.MaxReloadsPerHour
+	}
+	if selectedProfile.Networkd.WaitForCarrier != "" {
+		mergedProfile.Networkd.WaitForCarrier = selectedProfile.Networkd.WaitForCarrier
+	}
 
 	// Merge Features Config
 	if selectedProfile.Features.DNSOverTLS {
@@ -317,12 +825,227 @@ func MergeProfiles(defaultProfile, selectedProfile Profile) Profile {
 	if selectedProfile.Features.RestoreOnExit {
 		mergedProfile.Features.RestoreOnExit = true
 	}
+	if len(selectedProfile.Features.ExtraSearchDomains) > 0 {
+		mergedProfile.Features.ExtraSearchDomains = selectedProfile.Features.ExtraSearchDomains
+	}
+	if len(selectedProfile.Features.WatchdogNetworks) > 0 {
+		mergedProfile.Features.WatchdogNetworks = selectedProfile.Features.WatchdogNetworks
+	}
+	if selectedProfile.Features.MDNSConflict != "" {
+		mergedProfile.Features.MDNSConflict = selectedProfile.Features.MDNSConflict
+	}
+	mergedProfile.Features.MDNSAdvertise = mergedProfile.Features.MDNSAdvertise || selectedProfile.Features.MDNSAdvertise
+	if selectedProfile.Features.VerifyHostname != "" {
+		mergedProfile.Features.VerifyHostname = selectedProfile.Features.VerifyHostname
+	}
+	if selectedProfile.Features.VerifyTimeout != "" {
+		mergedProfile.Features.VerifyTimeout = selectedProfile.Features.VerifyTimeout
+	}
+	if len(selectedProfile.Features.WatchdogExec) > 0 {
+		mergedProfile.Features.WatchdogExec = selectedProfile.Features.WatchdogExec
+	}
+	if selectedProfile.Features.WatchdogExecTimeout != "" {
+		mergedProfile.Features.WatchdogExecTimeout = selectedProfile.Features.WatchdogExecTimeout
+	}
 
 	// Copy Filters
 	if len(selectedProfile.Filters) > 0 {
 		mergedProfile.Filters = selectedProfile.Filters
 	}
 
+	// Copy Control
+	if selectedProfile.Control.Enabled {
+		mergedProfile.Control.Enabled = true
+	}
+	if selectedProfile.Control.Socket != "" {
+		mergedProfile.Control.Socket = selectedProfile.Control.Socket
+	}
+	if selectedProfile.Control.Group != "" {
+		mergedProfile.Control.Group = selectedProfile.Control.Group
+	}
+	if selectedProfile.Control.User != "" {
+		mergedProfile.Control.User = selectedProfile.Control.User
+	}
+	if selectedProfile.Control.Mode != "" {
+		mergedProfile.Control.Mode = selectedProfile.Control.Mode
+	}
+	if selectedProfile.Control.AdminGroup != "" {
+		mergedProfile.Control.AdminGroup = selectedProfile.Control.AdminGroup
+	}
+
+	// Copy Health
+	if selectedProfile.Health.Enabled {
+		mergedProfile.Health.Enabled = true
+	}
+	if selectedProfile.Health.Listen != "" {
+		mergedProfile.Health.Listen = selectedProfile.Health.Listen
+	}
+
+	// Copy GRPC
+	if selectedProfile.GRPC.Enabled {
+		mergedProfile.GRPC.Enabled = true
+	}
+	if selectedProfile.GRPC.Listen != "" {
+		mergedProfile.GRPC.Listen = selectedProfile.GRPC.Listen
+	}
+
+	// Copy Hardening
+	if selectedProfile.Hardening.Umask != "" {
+		mergedProfile.Hardening.Umask = selectedProfile.Hardening.Umask
+	}
+	if selectedProfile.Hardening.NoFile != 0 {
+		mergedProfile.Hardening.NoFile = selectedProfile.Hardening.NoFile
+	}
+	if selectedProfile.Hardening.DisableCoreDumps {
+		mergedProfile.Hardening.DisableCoreDumps = true
+	}
+	if selectedProfile.Hardening.LockStateDir {
+		mergedProfile.Hardening.LockStateDir = true
+	}
+	if selectedProfile.Hardening.User != "" {
+		mergedProfile.Hardening.User = selectedProfile.Hardening.User
+	}
+	if selectedProfile.Hardening.Group != "" {
+		mergedProfile.Hardening.Group = selectedProfile.Hardening.Group
+	}
+
+	// Copy Dnsmasq
+	if selectedProfile.Dnsmasq.ConfigDir != "" {
+		mergedProfile.Dnsmasq.ConfigDir = selectedProfile.Dnsmasq.ConfigDir
+	}
+	if selectedProfile.Dnsmasq.ServersFile != "" {
+		mergedProfile.Dnsmasq.ServersFile = selectedProfile.Dnsmasq.ServersFile
+	}
+	if selectedProfile.Dnsmasq.Service != "" {
+		mergedProfile.Dnsmasq.Service = selectedProfile.Dnsmasq.Service
+	}
+	if selectedProfile.Dnsmasq.Reload {
+		mergedProfile.Dnsmasq.Reload = true
+	}
+
+	// Copy Unbound
+	if selectedProfile.Unbound.IncludeFile != "" {
+		mergedProfile.Unbound.IncludeFile = selectedProfile.Unbound.IncludeFile
+	}
+	if selectedProfile.Unbound.Control {
+		mergedProfile.Unbound.Control = true
+	}
+	if selectedProfile.Unbound.Service != "" {
+		mergedProfile.Unbound.Service = selectedProfile.Unbound.Service
+	}
+	if selectedProfile.Unbound.Reload {
+		mergedProfile.Unbound.Reload = true
+	}
+	if selectedProfile.Unbound.Reconcile {
+		mergedProfile.Unbound.Reconcile = true
+	}
+
+	// Copy OpenWrt
+	if selectedProfile.OpenWrt.Section != "" {
+		mergedProfile.OpenWrt.Section = selectedProfile.OpenWrt.Section
+	}
+	if selectedProfile.OpenWrt.RebindDomains {
+		mergedProfile.OpenWrt.RebindDomains = true
+	}
+	if selectedProfile.OpenWrt.Reload {
+		mergedProfile.OpenWrt.Reload = true
+	}
+
+	// Copy Windows
+	if selectedProfile.Windows.NRPT {
+		mergedProfile.Windows.NRPT = true
+	}
+	if selectedProfile.Windows.InterfaceDNS {
+		mergedProfile.Windows.InterfaceDNS = true
+	}
+
+	// Copy Safety
+	if selectedProfile.Safety.MaxChangesPerRun != 0 {
+		mergedProfile.Safety.MaxChangesPerRun = selectedProfile.Safety.MaxChangesPerRun
+	}
+	if selectedProfile.Safety.MaxRemovalsPerRun != 0 {
+		mergedProfile.Safety.MaxRemovalsPerRun = selectedProfile.Safety.MaxRemovalsPerRun
+	}
+	if selectedProfile.Safety.Ack != "" {
+		mergedProfile.Safety.Ack = selectedProfile.Safety.Ack
+	}
+	if selectedProfile.Safety.KeepOnEmptyFilter {
+		mergedProfile.Safety.KeepOnEmptyFilter = true
+	}
+
+	// Copy Maintenance
+	if len(selectedProfile.Maintenance.DeferWindows) > 0 {
+		mergedProfile.Maintenance.DeferWindows = selectedProfile.Maintenance.DeferWindows
+	}
+	if selectedProfile.Maintenance.Timezone != "" {
+		mergedProfile.Maintenance.Timezone = selectedProfile.Maintenance.Timezone
+	}
+
+	// Copy ResolvWatch
+	if selectedProfile.ResolvWatch.Enabled {
+		mergedProfile.ResolvWatch.Enabled = true
+	}
+	if len(selectedProfile.ResolvWatch.Paths) > 0 {
+		mergedProfile.ResolvWatch.Paths = selectedProfile.ResolvWatch.Paths
+	}
+	if selectedProfile.ResolvWatch.Interval != "" {
+		mergedProfile.ResolvWatch.Interval = selectedProfile.ResolvWatch.Interval
+	}
+	if selectedProfile.ResolvWatch.Reassert {
+		mergedProfile.ResolvWatch.Reassert = true
+	}
+
+	// Copy Coordination
+	if selectedProfile.Coordination.Enabled {
+		mergedProfile.Coordination.Enabled = true
+	}
+	if selectedProfile.Coordination.Policy != "" {
+		mergedProfile.Coordination.Policy = selectedProfile.Coordination.Policy
+	}
+	// Per-domain policies from the profile are added to (and override) the default ones
+	mergedProfile.Coordination.Domains = MergeLabels(defaultProfile.Coordination.Domains, selectedProfile.Coordination.Domains)
+
+	// Copy ServerOrder
+	if selectedProfile.ServerOrder.Strategy != "" {
+		mergedProfile.ServerOrder.Strategy = selectedProfile.ServerOrder.Strategy
+	}
+	if selectedProfile.ServerOrder.Interval != "" {
+		mergedProfile.ServerOrder.Interval = selectedProfile.ServerOrder.Interval
+	}
+
+	// Copy Webhooks
+	if len(selectedProfile.Webhooks) > 0 {
+		mergedProfile.Webhooks = selectedProfile.Webhooks
+	}
+
+	// Labels from the profile are added to (and override) the default ones
+	mergedProfile.Labels = MergeLabels(defaultProfile.Labels, selectedProfile.Labels)
+
+	// Copy ConfigEpoch
+	if selectedProfile.ConfigEpoch != "" {
+		mergedProfile.ConfigEpoch = selectedProfile.ConfigEpoch
+	}
+	if selectedProfile.Timeout != "" {
+		mergedProfile.Timeout = selectedProfile.Timeout
+	}
+
+	// Copy StateDir
+	if selectedProfile.StateDir != "" {
+		mergedProfile.StateDir = selectedProfile.StateDir
+	}
+
+	// Experimental features set by the profile, on or off, override the default ones
+	if len(selectedProfile.Experimental) > 0 {
+		experimental := cloneMap(defaultProfile.Experimental)
+		if experimental == nil {
+			experimental = map[string]bool{}
+		}
+		for name, enabled := range selectedProfile.Experimental {
+			experimental[name] = enabled
+		}
+		mergedProfile.Experimental = experimental
+	}
+
 	// Interface Watch
 	if selectedProfile.InterfaceWatch.Mode != "" {
 		mergedProfile.InterfaceWatch.Mode = selectedProfile.InterfaceWatch.Mode
@@ -333,6 +1056,24 @@ func MergeProfiles(defaultProfile, selectedProfile Profile) Profile {
 	if selectedProfile.InterfaceWatch.Retry.Delay != "" {
 		mergedProfile.InterfaceWatch.Retry.Delay = selectedProfile.InterfaceWatch.Retry.Delay
 	}
+	if selectedProfile.InterfaceWatch.Retry.GlobalTimeout != "" {
+		mergedProfile.InterfaceWatch.Retry.GlobalTimeout = selectedProfile.InterfaceWatch.Retry.GlobalTimeout
+	}
+	if selectedProfile.InterfaceWatch.Retry.MaxConcurrent != 0 {
+		mergedProfile.InterfaceWatch.Retry.MaxConcurrent = selectedProfile.InterfaceWatch.Retry.MaxConcurrent
+	}
+	if selectedProfile.InterfaceWatch.Readiness != "" {
+		mergedProfile.InterfaceWatch.Readiness = selectedProfile.InterfaceWatch.Readiness
+	}
+	if len(selectedProfile.InterfaceWatch.Checks) > 0 {
+		mergedProfile.InterfaceWatch.Checks = selectedProfile.InterfaceWatch.Checks
+	}
+	if len(selectedProfile.InterfaceWatch.Networks) > 0 {
+		mergedProfile.InterfaceWatch.Networks = selectedProfile.InterfaceWatch.Networks
+	}
+	if len(selectedProfile.Networks) > 0 {
+		mergedProfile.Networks = selectedProfile.Networks
+	}
 
 	return mergedProfile
 }
@@ -0,0 +1,707 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"gopkg.in/yaml.v3"
+)
+
+// leaf is a configuration key: the YAML path of a field of Profile that isn't a nested struct
+type leaf struct {
+	path []string
+	typ  reflect.Type
+}
+
+func (l leaf) String() string {
+	return strings.Join(l.path, ".")
+}
+
+// leaves lists every key of Profile
+func leaves(t reflect.Type, prefix []string) []leaf {
+	var out []leaf
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if !field.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		path := append(append([]string{}, prefix...), name)
+		if field.Type.Kind() == reflect.Struct {
+			out = append(out, leaves(field.Type, path)...)
+			continue
+		}
+		out = append(out, leaf{path: path, typ: field.Type})
+	}
+	return out
+}
+
+// sample returns a value of type t; values for different variants differ
+func sample(t reflect.Type, variant int) reflect.Value {
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Bool:
+		// The default layer turns booleans on and the profile layer off again
+		v.SetBool(variant == 0)
+	case reflect.String:
+		v.SetString([]string{"first", "second"}[variant])
+	case reflect.Int:
+		v.SetInt(int64(variant + 1))
+	case reflect.Interface:
+		v.Set(reflect.ValueOf([]string{"first", "second"}[variant]))
+	case reflect.Ptr:
+		v.Set(reflect.New(t.Elem()))
+		v.Elem().Set(sample(t.Elem(), variant))
+	case reflect.Slice:
+		v.Set(reflect.Append(reflect.MakeSlice(t, 0, 1), sample(t.Elem(), variant)))
+	case reflect.Map:
+		v.Set(reflect.MakeMap(t))
+		v.SetMapIndex(reflect.ValueOf([]string{"first", "second"}[variant]).Convert(t.Key()), sample(t.Elem(), variant))
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				v.Field(i).Set(sample(t.Field(i).Type, variant))
+			}
+		}
+	}
+	return v
+}
+
+// nested builds the YAML value setting path to value
+func nested(path []string, value interface{}) map[string]interface{} {
+	if len(path) == 1 {
+		return map[string]interface{}{path[0]: value}
+	}
+	return map[string]interface{}{path[0]: nested(path[1:], value)}
+}
+
+// lookup returns the field of p at a YAML path
+func lookup(p Profile, path []string) reflect.Value {
+	v := reflect.ValueOf(p)
+	for _, name := range path {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0] == name {
+				v = v.Field(i)
+				break
+			}
+		}
+	}
+	return v
+}
+
+func loadYAML(t *testing.T, document interface{}) Config {
+	t.Helper()
+	content, err := yaml.Marshal(document)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	file := filepath.Join(t.TempDir(), "zeroplex.yml")
+	if err := os.WriteFile(file, content, 0600); err != nil {
+		t.Fatalf("write %s: %v", file, err)
+	}
+	cfg, err := LoadConfig(file)
+	if err != nil {
+		t.Fatalf("LoadConfig:\n%s\n%v", content, err)
+	}
+	return cfg
+}
+
+func TestUnsetKeysKeepBuiltInDefaults(t *testing.T) {
+	cfg := loadYAML(t, map[string]interface{}{"default": map[string]interface{}{}})
+	if want := DefaultConfig().Default; !reflect.DeepEqual(cfg.Default, want) {
+		t.Errorf("default profile of an empty file = %+v, want the built-in defaults %+v", cfg.Default, want)
+	}
+}
+
+func TestPrecedenceOfEveryKey(t *testing.T) {
+	keys := leaves(reflect.TypeOf(Profile{}), nil)
+	if len(keys) < 40 {
+		t.Fatalf("found only %d configuration keys", len(keys))
+	}
+	for _, key := range keys {
+		t.Run(key.String(), func(t *testing.T) {
+			first := sample(key.typ, 0)
+			second := sample(key.typ, 1)
+			cfg := loadYAML(t, map[string]interface{}{
+				"default": nested(key.path, first.Interface()),
+				"profiles": map[string]interface{}{
+					"override": nested(key.path, second.Interface()),
+					"other":    map[string]interface{}{"labels": map[string]string{"unrelated": "yes"}},
+				},
+			})
+
+			if got := lookup(cfg.Default, key.path); !reflect.DeepEqual(got.Interface(), first.Interface()) {
+				t.Errorf("default: sets %v, loaded %v", first, got)
+			}
+
+			want := second
+			if key.typ.Kind() == reflect.Map {
+				// Maps are merged, the profile adding and overriding entries
+				want = reflect.MakeMap(key.typ)
+				for _, m := range []reflect.Value{first, second} {
+					for _, k := range m.MapKeys() {
+						want.SetMapIndex(k, m.MapIndex(k))
+					}
+				}
+			}
+			selected, ok := cfg.SelectProfile("override")
+			if !ok {
+				t.Fatal("profile override not found")
+			}
+			if got := lookup(selected, key.path); !reflect.DeepEqual(got.Interface(), want.Interface()) {
+				t.Errorf("profile setting %v over %v gives %v", second, first, got)
+			}
+
+			other, _ := cfg.SelectProfile("other")
+			if key.String() != "labels" {
+				if got := lookup(other, key.path); !reflect.DeepEqual(got.Interface(), first.Interface()) {
+					t.Errorf("profile not setting the key gives %v, want %v from default:", got, first)
+				}
+			}
+			if got := lookup(cfg.Default, key.path); !reflect.DeepEqual(got.Interface(), first.Interface()) {
+				t.Errorf("selecting a profile changed default: to %v", got)
+			}
+		})
+	}
+}
+
+func TestProfileCanTurnOffDefaults(t *testing.T) {
+	cfg := loadYAML(t, map[string]interface{}{
+		"profiles": map[string]interface{}{
+			"desktop": map[string]interface{}{"networkd": map[string]interface{}{"auto_restart": false, "reconcile": false}},
+		},
+	})
+	if !cfg.Default.Networkd.AutoRestart || !cfg.Default.Networkd.Reconcile {
+		t.Fatalf("built-in defaults not applied: %+v", cfg.Default.Networkd)
+	}
+	selected, _ := cfg.SelectProfile("desktop")
+	if selected.Networkd.AutoRestart || selected.Networkd.Reconcile {
+		t.Errorf("profile could not turn off auto_restart/reconcile: %+v", selected.Networkd)
+	}
+}
+
+func TestExperimentalFeatures(t *testing.T) {
+	cfg := loadYAML(t, map[string]interface{}{
+		"default": map[string]interface{}{"experimental": map[string]bool{"resolved_dbus": true}},
+		"profiles": map[string]interface{}{
+			"conservative": map[string]interface{}{"experimental": map[string]bool{"resolved_dbus": false}},
+		},
+	})
+	if err := ValidateConfig(&cfg); err != nil {
+		t.Fatalf("ValidateConfig: %v", err)
+	}
+	if got := cfg.Default.ActiveExperiments(); !reflect.DeepEqual(got, []string{"resolved_dbus"}) {
+		t.Errorf("ActiveExperiments() = %v, want [resolved_dbus]", got)
+	}
+	selected, _ := cfg.SelectProfile("conservative")
+	if selected.ExperimentEnabled("resolved_dbus") {
+		t.Errorf("profile could not turn off resolved_dbus: %v", selected.Experimental)
+	}
+	if !cfg.Default.ExperimentEnabled("resolved_dbus") {
+		t.Errorf("selecting the profile turned off resolved_dbus in default")
+	}
+
+	cfg.Default.Experimental["resolvd_dbus"] = true
+	if err := ValidateConfig(&cfg); err == nil || !strings.Contains(err.Error(), "resolvd_dbus") {
+		t.Errorf("ValidateConfig with a misspelled feature = %v, want an error naming it", err)
+	}
+}
+
+func TestWatchdogExpectedAddresses(t *testing.T) {
+	cfg := loadYAML(t, map[string]interface{}{
+		"default": map[string]interface{}{"features": map[string]interface{}{
+			"watchdog_expected_ip": "10.147.20.1, fd80:56c2:e21c::5",
+		}},
+		"profiles": map[string]interface{}{
+			"v6only": map[string]interface{}{"features": map[string]interface{}{
+				"watchdog_networks": map[string]interface{}{
+					"8056c2e21c000001": map[string]interface{}{"enabled": true, "expected_ip": "fd80:56c2:e21c::5"},
+				},
+			}},
+		},
+	})
+	if err := ValidateConfig(&cfg); err != nil {
+		t.Fatalf("ValidateConfig: %v", err)
+	}
+
+	cfg.Profiles["v6only"].Features.WatchdogNetworks["8056c2e21c000001"] = NetworkWatchdogConfig{Enabled: true, ExpectedIP: "fd80:56c2:e21c::5, gw.example"}
+	if err := ValidateConfig(&cfg); err == nil || !strings.Contains(err.Error(), "gw.example") {
+		t.Errorf("ValidateConfig with a hostname as expected_ip = %v, want an error naming it", err)
+	}
+}
+
+func TestNetworkOverrides(t *testing.T) {
+	cfg := loadYAML(t, map[string]interface{}{
+		"default": map[string]interface{}{
+			"mode": "resolved",
+			"networks": map[string]interface{}{
+				"8056C2E21C000001": map[string]interface{}{"dns_servers": []string{"10.147.20.53"}, "dns_over_tls": false},
+				"a09acf0233000002": map[string]interface{}{"mode": "dnsmasq"},
+				"a09acf0233000003": map[string]interface{}{"mode": "dnsmasq"},
+			},
+		},
+	})
+	if err := ValidateConfig(&cfg); err != nil {
+		t.Fatalf("ValidateConfig: %v", err)
+	}
+	if got := cfg.Default.ActiveModes(); !reflect.DeepEqual(got, []string{"resolved", "dnsmasq"}) {
+		t.Errorf("ActiveModes() = %v, want [resolved dnsmasq]", got)
+	}
+	if got := cfg.Default.NetworkMode("8056c2e21c000001"); got != "resolved" {
+		t.Errorf("NetworkMode of a network without a mode = %s, want resolved", got)
+	}
+	if override, ok := cfg.Default.NetworkOverride("8056c2e21c000001"); !ok || override.DNSOverTLS == nil || *override.DNSOverTLS {
+		t.Errorf("NetworkOverride of an upper-case key = %+v, %t; want dns_over_tls false", override, ok)
+	}
+
+	for _, tc := range []struct {
+		id       string
+		override NetworkOverride
+		want     string
+	}{
+		{"8056c2e21c00", NetworkOverride{}, "network ID"},
+		{"8056c2e21c000004", NetworkOverride{Mode: "auto"}, "mode"},
+		{"8056c2e21c000004", NetworkOverride{DNSServers: []string{"dns.example"}}, "dns_servers"},
+		{"8056c2e21c000004", NetworkOverride{HoldDown: "30"}, "hold_down"},
+	} {
+		bad := cfg
+		bad.Default.Networks = map[string]NetworkOverride{tc.id: tc.override}
+		if err := ValidateConfig(&bad); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("ValidateConfig with networks.%s %+v = %v, want an error about its %s", tc.id, tc.override, err, tc.want)
+		}
+	}
+}
+
+func TestTokenSourcesAreExclusive(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Default.Client.TokenEnv = "ZT_TOKEN"
+	if err := ValidateConfig(&cfg); err != nil {
+		t.Fatalf("ValidateConfig with token_env: %v", err)
+	}
+	if ref, key := cfg.Default.Client.TokenReference(); ref != "env://ZT_TOKEN" || key != "token_env" {
+		t.Errorf("TokenReference = %q, %q; want env://ZT_TOKEN of token_env", ref, key)
+	}
+	cfg.Default.Client.TokenCommand = "pass show zerotier/token"
+	if err := ValidateConfig(&cfg); err == nil || !strings.Contains(err.Error(), "client.token_command and client.token_env") {
+		t.Errorf("ValidateConfig with token_env and token_command = %v, want them reported exclusive", err)
+	}
+
+	profiles := loadYAML(t, map[string]interface{}{
+		"default":  map[string]interface{}{"client": map[string]interface{}{"token_source": "vault://secret/zt#token"}},
+		"profiles": map[string]interface{}{"laptop": map[string]interface{}{"client": map[string]interface{}{"token_command": "pass show zt"}}},
+	})
+	laptop, _ := profiles.SelectProfile("laptop")
+	if ref, _ := laptop.Client.TokenReference(); ref != "cmd://pass show zt" {
+		t.Errorf("profile token_command over a default token_source resolves %q, want the command", ref)
+	}
+}
+
+func TestValidationReportsEveryProblemWithItsPosition(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "zeroplex.yml")
+	content := `default:
+  daemon:
+    pol_interval: 1m
+  interface_watch:
+    retry:
+      backoff: ["1s", "2q"]
+  filters:
+    - type: network_idd
+profiles:
+  office:
+    networks:
+      8056c2e21c000001:
+        hold_down: "30"
+`
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ValidateConfig(&cfg)
+	var problems ValidationError
+	if !errors.As(err, &problems) {
+		t.Fatalf("ValidateConfig = %v, want a ValidationError", err)
+	}
+	want := []FieldError{
+		{Path: "default.daemon.pol_interval", Line: 3, Column: 5},
+		{Path: "default.interface_watch.retry.backoff[1]", Line: 6, Column: 23},
+		{Path: "default.filters[0].type", Line: 8, Column: 13},
+		{Path: "profiles.office.networks.8056c2e21c000001.hold_down", Line: 13, Column: 20},
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("ValidateConfig found %d problems, want %d:\n%v", len(problems), len(want), err)
+	}
+	for i, problem := range problems {
+		if problem.Path != want[i].Path || problem.File != file || problem.Line != want[i].Line || problem.Column != want[i].Column {
+			t.Errorf("problem %d = %s at %s:%d:%d, want %s at line %d, column %d", i, problem.Path, problem.File, problem.Line, problem.Column,
+				want[i].Path, want[i].Line, want[i].Column)
+		}
+	}
+
+	// Changed after loading, so the file no longer says where the value came from
+	cfg.Profiles["office"].Networks["8056c2e21c000001"] = NetworkOverride{HoldDown: "2x"}
+	if err := ValidateConfig(&cfg); !strings.Contains(err.Error(), "\n  invalid profiles.office.networks.8056c2e21c000001.hold_down: 2x") {
+		t.Errorf("ValidateConfig of a changed value = %v, want it reported without the position of the old one", err)
+	}
+}
+
+func TestStarterConfigLoads(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "zerotier-one.port"), []byte("9994\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	port, ok := ZeroTierPort(dir)
+	if !ok || port != 9994 {
+		t.Errorf("ZeroTierPort = %d, %t; want 9994", port, ok)
+	}
+
+	for _, starter := range []Starter{
+		{Mode: "resolved", Host: "http://localhost", Port: port, TokenFile: `C:\ProgramData\ZeroTier\One\authtoken.secret`, Daemon: true, PollInterval: "5m"},
+		{Mode: "auto", Host: "http://[::1]", Port: 9993, TokenFile: "/var/lib/zerotier-one/authtoken.secret", PollInterval: "1m", Networks: []string{"8056c2e21c000001"}},
+	} {
+		file := filepath.Join(dir, "zeroplex.yml")
+		if err := os.WriteFile(file, StarterConfig(starter), 0600); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := LoadConfig(file)
+		if err == nil {
+			err = ValidateConfig(&cfg)
+		}
+		if err != nil {
+			t.Fatalf("starter configuration for %+v: %v", starter, err)
+		}
+		got := cfg.Default
+		if got.Mode != starter.Mode || got.Client.Port != starter.Port || got.Client.TokenFile != starter.TokenFile ||
+			got.Daemon.Enabled != starter.Daemon || got.Daemon.PollInterval != starter.PollInterval {
+			t.Errorf("starter configuration for %+v loads as mode %s, port %d, token file %s, daemon %t every %s",
+				starter, got.Mode, got.Client.Port, got.Client.TokenFile, got.Daemon.Enabled, got.Daemon.PollInterval)
+		}
+		if wantFilters := len(starter.Networks) > 0; len(got.Filters) > 0 != wantFilters {
+			t.Errorf("starter configuration for %+v has filters %v", starter, got.Filters)
+		}
+	}
+}
+
+func TestConfigDirLayersFragments(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+	write("zeroplex.yml", "default:\n  mode: resolved\n  log:\n    level: debug\nprofiles:\n  desktop:\n    mode: networkmanager\n")
+	write("conf.d/20-log.yml", "default:\n  log:\n    level: error\n")
+	write("conf.d/10-client.yaml", "default:\n  client:\n    port: 9994\n  log:\n    level: info\n")
+	write("conf.d/30-desktop.yml", "profiles:\n  desktop:\n    networkd:\n      reconcile: false\n")
+	write("conf.d/README", "not configuration\n")
+
+	files, err := DirFiles(dir)
+	if err != nil {
+		t.Fatalf("DirFiles: %v", err)
+	}
+	var names []string
+	for _, file := range files {
+		names = append(names, filepath.Base(file))
+	}
+	if want := []string{"zeroplex.yml", "10-client.yaml", "20-log.yml", "30-desktop.yml"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("DirFiles = %v, want %v", names, want)
+	}
+
+	cfg, err := LoadConfigFiles(files...)
+	if err != nil {
+		t.Fatalf("LoadConfigFiles: %v", err)
+	}
+	if cfg.Default.Mode != "resolved" || cfg.Default.Client.Port != 9994 || cfg.Default.Log.Level != "error" {
+		t.Errorf("default: mode %q, port %d, log level %q; want resolved, 9994, error",
+			cfg.Default.Mode, cfg.Default.Client.Port, cfg.Default.Log.Level)
+	}
+	desktop, ok := cfg.SelectProfile("desktop")
+	if !ok {
+		t.Fatal("profile desktop not found")
+	}
+	if desktop.Mode != "networkmanager" || desktop.Networkd.Reconcile || desktop.Client.Port != 9994 {
+		t.Errorf("desktop: mode %q, reconcile %t, port %d; want networkmanager, false, 9994",
+			desktop.Mode, desktop.Networkd.Reconcile, desktop.Client.Port)
+	}
+
+	if _, err := DirFiles(t.TempDir()); err == nil {
+		t.Error("DirFiles accepted an empty directory")
+	}
+}
+
+func TestDropInFiles(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "zeroplex.yml")
+	if got, want := DropInDir(file), filepath.Join(dir, "zeroplex.d"); got != want {
+		t.Errorf("DropInDir = %q, want %q", got, want)
+	}
+	if got, want := DropInDir(file+".age"), filepath.Join(dir, "zeroplex.d"); got != want {
+		t.Errorf("DropInDir of an encrypted file = %q, want %q", got, want)
+	}
+	if files, err := DropInFiles(file); err != nil || len(files) != 0 {
+		t.Errorf("DropInFiles without a directory = %v, %v; want none", files, err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "zeroplex.d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"zeroplex.yml":              "default:\n  mode: resolved\n  filters:\n    - type: name\n      conditions:\n        - value: home\n",
+		"zeroplex.d/20-lab.toml":    "[profiles.lab]\nmode = \"networkd\"\n",
+		"zeroplex.d/10-filters.yml": "default:\n  filters:\n    - type: name\n      conditions:\n        - value: corp*\n",
+		"zeroplex.d/.swp.yml":       "default: [",
+		"zeroplex.d/README":         "not configuration\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dropIns, err := DropInFiles(file)
+	if err != nil {
+		t.Fatalf("DropInFiles: %v", err)
+	}
+	want := []string{filepath.Join(dir, "zeroplex.d", "10-filters.yml"), filepath.Join(dir, "zeroplex.d", "20-lab.toml")}
+	if !reflect.DeepEqual(dropIns, want) {
+		t.Fatalf("DropInFiles = %v, want %v", dropIns, want)
+	}
+
+	cfg, err := LoadConfigFiles(append([]string{file}, dropIns...)...)
+	if err != nil {
+		t.Fatalf("LoadConfigFiles: %v", err)
+	}
+	filters, _ := yaml.Marshal(cfg.Default.Filters)
+	if cfg.Default.Mode != "resolved" || len(cfg.Default.Filters) != 1 || !strings.Contains(string(filters), "corp*") {
+		t.Errorf("default: mode %q, filters %v; want resolved with the drop-in's filters", cfg.Default.Mode, cfg.Default.Filters)
+	}
+	if lab, ok := cfg.SelectProfile("lab"); !ok || lab.Mode != "networkd" {
+		t.Errorf("profile lab from the drop-in: %q, %t", lab.Mode, ok)
+	}
+}
+
+func TestTOMLAndJSONConfig(t *testing.T) {
+	dir := t.TempDir()
+	documents := map[string]string{
+		"zeroplex.toml": `
+[default]
+mode = "resolved"
+labels = { site = "fra1" }
+
+[default.hardening]
+nofile = 1048576
+
+[[default.filters]]
+type = "name"
+value = "prod*"
+
+[profiles.desktop.networkd]
+reconcile = false
+`,
+		"zeroplex.json": `{
+  "default": {
+    "mode": "resolved",
+    "labels": {"site": "fra1"},
+    "hardening": {"nofile": 1048576},
+    "filters": [{"type": "name", "value": "prod*"}]
+  },
+  "profiles": {"desktop": {"networkd": {"reconcile": false}}}
+}`,
+	}
+	for name, content := range documents {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatalf("write %s: %v", path, err)
+			}
+			cfg, err := LoadConfig(path)
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			if cfg.Default.Mode != "resolved" || cfg.Default.Labels["site"] != "fra1" || cfg.Default.Hardening.NoFile != 1048576 {
+				t.Errorf("default profile not loaded: mode %q, labels %v, nofile %d", cfg.Default.Mode, cfg.Default.Labels, cfg.Default.Hardening.NoFile)
+			}
+			if len(cfg.Default.Filters) != 1 || cfg.Default.Filters[0]["value"] != "prod*" {
+				t.Errorf("filters not loaded: %v", cfg.Default.Filters)
+			}
+			selected, _ := cfg.SelectProfile("desktop")
+			if selected.Networkd.Reconcile || !selected.Networkd.AutoRestart || selected.Mode != "resolved" {
+				t.Errorf("profile not layered over default: %+v, mode %q", selected.Networkd, selected.Mode)
+			}
+
+			// Saving in the same format and loading again gives the same configuration
+			saved := filepath.Join(dir, "saved"+filepath.Ext(name))
+			if err := SaveConfig(saved, cfg); err != nil {
+				t.Fatalf("SaveConfig: %v", err)
+			}
+			reloaded, err := LoadConfig(saved)
+			if err != nil {
+				t.Fatalf("LoadConfig of the saved file: %v", err)
+			}
+			// Compared as YAML, since an empty list or map may come back as nil
+			want, _ := yaml.Marshal(cfg)
+			got, _ := yaml.Marshal(reloaded)
+			if string(got) != string(want) {
+				t.Errorf("saved configuration differs:\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	profile := DefaultConfig().Default
+	applied, err := ApplyEnv(&profile, []string{
+		"ZEROPLEX_MODE=resolved",
+		"ZEROPLEX_CLIENT_PORT=9994",
+		"ZEROPLEX_LOG_LEVEL=debug",
+		"ZEROPLEX_NETWORKD_RECONCILE=false",
+		"ZEROPLEX_ENFORCE=false",
+		"ZEROPLEX_HARDENING_NOFILE=1048576",
+		"ZEROPLEX_FEATURES_EXTRA_SEARCH_DOMAINS=corp.example, lab.example",
+		"ZEROPLEX_CONFIG=/etc/zeroplex.yml",
+		"PATH=/usr/bin",
+	})
+	if err != nil {
+		t.Fatalf("ApplyEnv: %v", err)
+	}
+	want := []string{"client.port", "enforce", "features.extra_search_domains", "hardening.nofile", "log.level", "mode", "networkd.reconcile"}
+	if !reflect.DeepEqual(applied, want) {
+		t.Errorf("applied = %v, want %v", applied, want)
+	}
+	if profile.Mode != "resolved" || profile.Client.Port != 9994 || profile.Log.Level != "debug" || profile.Networkd.Reconcile || profile.Enforcing() || profile.Hardening.NoFile != 1048576 {
+		t.Errorf("profile not overridden: %+v", profile)
+	}
+	if !reflect.DeepEqual(profile.Features.ExtraSearchDomains, []string{"corp.example", "lab.example"}) {
+		t.Errorf("extra_search_domains = %v", profile.Features.ExtraSearchDomains)
+	}
+	if profile.Client.Host != DefaultConfig().Default.Client.Host {
+		t.Errorf("unset key changed: client.host = %q", profile.Client.Host)
+	}
+
+	if _, err := ApplyEnv(&profile, []string{"ZEROPLEX_CLIENT_PORT=ninety"}); err == nil || !strings.Contains(err.Error(), "ZEROPLEX_CLIENT_PORT") {
+		t.Errorf("ApplyEnv with a bad port = %v, want an error naming the variable", err)
+	}
+	for _, name := range EnvVariables() {
+		if name == "ZEROPLEX_CONFIG" || name == "ZEROPLEX_AGE_KEY_FILE" {
+			t.Errorf("%s names a configuration key as well as its own setting", name)
+		}
+	}
+}
+
+// useAgeKey makes identity the key encrypted configuration is decrypted with for the test
+func useAgeKey(t *testing.T, identity *age.X25519Identity) {
+	t.Helper()
+	keyFile := filepath.Join(t.TempDir(), "age.key")
+	if err := os.WriteFile(keyFile, []byte(identity.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ZEROPLEX_AGE_KEY_FILE", "")
+	t.Setenv("SOPS_AGE_KEY_FILE", "")
+	previous := DecryptionKeyFile
+	DecryptionKeyFile = keyFile
+	t.Cleanup(func() { DecryptionKeyFile = previous })
+}
+
+// encryptAge encrypts plaintext to recipient, armored or binary
+func encryptAge(t *testing.T, recipient age.Recipient, plaintext string, armored bool) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	dst := io.WriteCloser(nopCloser{&out})
+	if armored {
+		dst = armor.NewWriter(&out)
+	}
+	w, err := age.Encrypt(dst, recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(w, plaintext); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+func TestAgeEncryptedConfig(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	useAgeKey(t, identity)
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	inline := "age:" + base64.StdEncoding.EncodeToString(encryptAge(t, identity.Recipient(), "inline-token", false))
+	cfg, err := LoadConfig(write("inline.yml", "default:\n  client:\n    token: \""+inline+"\"\n"))
+	if err != nil || cfg.Default.Client.Token != "inline-token" {
+		t.Errorf("inline age: value: token %q, %v; want inline-token", cfg.Default.Client.Token, err)
+	}
+
+	armored := strings.ReplaceAll(strings.TrimSpace(string(encryptAge(t, identity.Recipient(), "armored-token", true))), "\n", "\n      ")
+	cfg, err = LoadConfig(write("armored.yml", "default:\n  client:\n    token: |\n      "+armored+"\n"))
+	if err != nil || cfg.Default.Client.Token != "armored-token" {
+		t.Errorf("armored value: token %q, %v; want armored-token", cfg.Default.Client.Token, err)
+	}
+
+	whole := encryptAge(t, identity.Recipient(), "default:\n  mode: networkd\n", false)
+	cfg, err = LoadConfig(write("zeroplex.yml.age", string(whole)))
+	if err != nil || cfg.Default.Mode != "networkd" {
+		t.Errorf("whole-file .age config: mode %q, %v; want networkd", cfg.Default.Mode, err)
+	}
+
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign := "age:" + base64.StdEncoding.EncodeToString(encryptAge(t, other.Recipient(), "foreign-token", false))
+	_, err = LoadConfig(write("foreign.yml", "default:\n  mode: resolved\n  client:\n    token: \""+foreign+"\"\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 4:") {
+		t.Errorf("value encrypted to another identity: %v; want a decryption error on line 4", err)
+	}
+
+	DecryptionKeyFile = filepath.Join(dir, "missing.key")
+	if _, err := LoadConfig(filepath.Join(dir, "inline.yml")); err == nil || !strings.Contains(err.Error(), "failed to open age key file") {
+		t.Errorf("missing key file: %v; want it reported", err)
+	}
+	DecryptionKeyFile = ""
+	if _, err := os.Stat(DefaultDecryptionKeyFile); err == nil {
+		t.Skipf("%s exists", DefaultDecryptionKeyFile)
+	}
+	if _, err := LoadConfig(filepath.Join(dir, "inline.yml")); err == nil || !strings.Contains(err.Error(), "no age key file is configured") {
+		t.Errorf("no key file: %v; want it reported", err)
+	}
+}
@@ -0,0 +1,184 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"gopkg.in/yaml.v3"
+)
+
+// DecryptionKeyFile is the age identity file used for encrypted configuration. When empty,
+// ZEROPLEX_AGE_KEY_FILE, SOPS_AGE_KEY_FILE and DefaultDecryptionKeyFile are tried in turn.
+var DecryptionKeyFile string
+
+// DefaultDecryptionKeyFile is used when no key file is configured and it exists
+const DefaultDecryptionKeyFile = "/etc/zeroplex/age.key"
+
+// agePrefix marks an inline value holding base64 encoded age ciphertext
+const agePrefix = "age:"
+
+const ageArmorHeader = "-----BEGIN AGE ENCRYPTED FILE-----"
+
+// ageBinaryHeader starts every non-armored age file
+const ageBinaryHeader = "age-encryption.org/v1"
+
+// decryptionKeyFile returns the age identity file to use, or "" if none is available
+func decryptionKeyFile() string {
+	for _, candidate := range []string{DecryptionKeyFile, os.Getenv("ZEROPLEX_AGE_KEY_FILE"), os.Getenv("SOPS_AGE_KEY_FILE")} {
+		if candidate != "" {
+			return candidate
+		}
+	}
+	if _, err := os.Stat(DefaultDecryptionKeyFile); err == nil {
+		return DefaultDecryptionKeyFile
+	}
+	return ""
+}
+
+func loadIdentities() ([]age.Identity, error) {
+	keyFile := decryptionKeyFile()
+	if keyFile == "" {
+		return nil, fmt.Errorf("configuration is encrypted but no age key file is configured (use --decryption-key-file or ZEROPLEX_AGE_KEY_FILE)")
+	}
+	f, err := os.Open(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open age key file: %w", err)
+	}
+	defer f.Close()
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse age key file %s: %w", keyFile, err)
+	}
+	return identities, nil
+}
+
+// decryptAge decrypts armored or binary age ciphertext
+func decryptAge(ciphertext []byte, identities []age.Identity) ([]byte, error) {
+	var src io.Reader = bytes.NewReader(ciphertext)
+	if bytes.HasPrefix(bytes.TrimSpace(ciphertext), []byte(ageArmorHeader)) {
+		src = armor.NewReader(bytes.NewReader(bytes.TrimSpace(ciphertext)))
+	}
+	r, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func isAgeFile(path string, content []byte) bool {
+	trimmed := bytes.TrimSpace(content)
+	return strings.HasSuffix(path, ".age") ||
+		bytes.HasPrefix(trimmed, []byte(ageArmorHeader)) ||
+		bytes.HasPrefix(trimmed, []byte(ageBinaryHeader))
+}
+
+// isSopsFile reports whether a YAML document carries sops metadata
+func isSopsFile(content []byte) bool {
+	var probe struct {
+		Sops map[string]interface{} `yaml:"sops"`
+	}
+	if err := yaml.Unmarshal(content, &probe); err != nil {
+		return false
+	}
+	_, hasMAC := probe.Sops["mac"]
+	return hasMAC
+}
+
+// decryptSops decrypts a whole sops file with the sops binary, passing the age key file along
+func decryptSops(path string) ([]byte, error) {
+	if _, err := exec.LookPath("sops"); err != nil {
+		return nil, fmt.Errorf("configuration is sops encrypted but the sops binary is not available")
+	}
+	cmd := exec.Command("sops", "--decrypt", "--input-type", "yaml", "--output-type", "yaml", path)
+	cmd.Env = os.Environ()
+	if keyFile := decryptionKeyFile(); keyFile != "" {
+		cmd.Env = append(cmd.Env, "SOPS_AGE_KEY_FILE="+keyFile)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("sops --decrypt failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// readConfigContent reads a configuration file, decrypting it if it is a whole-file age or sops document
+func readConfigContent(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isAgeFile(path, content) {
+		identities, err := loadIdentities()
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := decryptAge(content, identities)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %w", path, err)
+		}
+		return plaintext, nil
+	}
+	if isSopsFile(content) {
+		return decryptSops(path)
+	}
+	return content, nil
+}
+
+// decryptValues replaces every age encrypted scalar in the YAML tree with its plaintext
+func decryptValues(node *yaml.Node) error {
+	var identities []age.Identity
+	var walk func(n *yaml.Node) error
+	walk = func(n *yaml.Node) error {
+		if n.Kind == yaml.ScalarNode {
+			value := strings.TrimSpace(n.Value)
+			var ciphertext []byte
+			switch {
+			case strings.HasPrefix(value, ageArmorHeader):
+				ciphertext = []byte(value)
+			case strings.HasPrefix(value, agePrefix):
+				decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, agePrefix))
+				if err != nil {
+					return fmt.Errorf("line %d: invalid base64 in age value: %w", n.Line, err)
+				}
+				ciphertext = decoded
+			default:
+				return nil
+			}
+			if identities == nil {
+				var err error
+				if identities, err = loadIdentities(); err != nil {
+					return err
+				}
+			}
+			plaintext, err := decryptAge(ciphertext, identities)
+			if err != nil {
+				return fmt.Errorf("line %d: failed to decrypt value: %w", n.Line, err)
+			}
+			// Reset tag and style so the plaintext is typed as if it had been written inline
+			n.Value = strings.TrimRight(string(plaintext), "\n")
+			n.Tag = ""
+			n.Style = 0
+			return nil
+		}
+		for _, child := range n.Content {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(node)
+}
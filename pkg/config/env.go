@@ -0,0 +1,138 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix starts the name of the environment variable of each configuration key
+const EnvPrefix = "ZEROPLEX_"
+
+// envKey is a configuration key that can be set from the environment
+type envKey struct {
+	path  string // e.g. client.port
+	index []int  // of the field in Profile
+}
+
+// envKeys maps the environment variable names to the keys of Profile they set: the YAML path
+// upper-cased with dots replaced by underscores, so client.port is ZEROPLEX_CLIENT_PORT. Keys
+// holding maps or lists of structs have no variable.
+func envKeys() map[string]envKey {
+	keys := map[string]envKey{}
+	var walk func(t reflect.Type, path []string, index []int)
+	walk = func(t reflect.Type, path []string, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if !field.IsExported() || name == "" || name == "-" {
+				continue
+			}
+			fieldPath := append(append([]string{}, path...), name)
+			fieldIndex := append(append([]int{}, index...), i)
+			typ := field.Type
+			if typ.Kind() == reflect.Ptr {
+				typ = typ.Elem()
+			}
+			switch typ.Kind() {
+			case reflect.Struct:
+				walk(typ, fieldPath, fieldIndex)
+				continue
+			case reflect.Map:
+				continue
+			case reflect.Slice:
+				if typ.Elem().Kind() != reflect.String {
+					continue
+				}
+			}
+			env := EnvPrefix + strings.ToUpper(strings.Join(fieldPath, "_"))
+			keys[env] = envKey{path: strings.Join(fieldPath, "."), index: fieldIndex}
+		}
+	}
+	walk(reflect.TypeOf(Profile{}), nil, nil)
+	return keys
+}
+
+// EnvVariables returns the names of the environment variables ApplyEnv reads, sorted
+func EnvVariables() []string {
+	keys := envKeys()
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyEnv overrides the keys of profile set by ZEROPLEX_* variables of environ, which holds
+// key=value entries as os.Environ returns them. Lists are comma separated. Variables that name no
+// key, such as ZEROPLEX_CONFIG, are left alone. It returns the keys it set, sorted.
+func ApplyEnv(profile *Profile, environ []string) ([]string, error) {
+	keys := envKeys()
+	var applied []string
+	for _, entry := range environ {
+		name, value, ok := strings.Cut(entry, "=")
+		key, known := keys[name]
+		if !ok || !known {
+			continue
+		}
+		field := reflect.ValueOf(profile).Elem().FieldByIndex(key.index)
+		if err := setFromEnv(field, value); err != nil {
+			return applied, fmt.Errorf("invalid %s: %q (%s %v)", name, value, key.path, err)
+		}
+		applied = append(applied, key.path)
+	}
+	sort.Strings(applied)
+	return applied, nil
+}
+
+// setFromEnv sets a field of Profile from the value of its environment variable
+func setFromEnv(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Ptr {
+		target := reflect.New(field.Type().Elem())
+		if err := setFromEnv(target.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(target)
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("must be true or false")
+		}
+		field.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("must be a whole number")
+		}
+		field.SetInt(int64(n))
+	case reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("must be a whole number")
+		}
+		field.SetUint(n)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("cannot be set from the environment")
+	}
+	return nil
+}
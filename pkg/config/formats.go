@@ -0,0 +1,161 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Extensions are the file extensions of the configuration formats, which LoadConfig and SaveConfig
+// pick the format by
+var Extensions = []string{".yml", ".yaml", ".toml", ".json"}
+
+// parseDocument parses a configuration file in the format of ext into a YAML document, so TOML and
+// JSON files are decoded, decrypted and layered exactly like YAML ones
+func parseDocument(content []byte, ext string) (*yaml.Node, error) {
+	var root yaml.Node
+	switch ext {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(content, &root); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML config: %w", err)
+		}
+		return &root, nil
+	case ".toml":
+		var value map[string]interface{}
+		if err := toml.Unmarshal(content, &value); err != nil {
+			return nil, fmt.Errorf("failed to parse TOML config: %w", err)
+		}
+		root.Kind = yaml.DocumentNode
+		root.Content = []*yaml.Node{valueNode(value)}
+		return &root, nil
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		// Keep integers integers rather than float64s, so large ones such as hardening.nofile survive
+		decoder.UseNumber()
+		var value interface{}
+		if err := decoder.Decode(&value); err == io.EOF {
+			return &root, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse JSON config: %w", err)
+		}
+		if _, ok := value.(map[string]interface{}); !ok && value != nil {
+			return nil, fmt.Errorf("failed to parse JSON config: the top level must be an object")
+		}
+		root.Kind = yaml.DocumentNode
+		root.Content = []*yaml.Node{valueNode(value)}
+		return &root, nil
+	}
+	return nil, fmt.Errorf("unsupported config file format: %s (supported: %s)", ext, strings.Join(Extensions, ", "))
+}
+
+// valueNode returns the YAML node of a value decoded from TOML or JSON
+func valueNode(value interface{}) *yaml.Node {
+	scalar := func(tag, text string) *yaml.Node {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: text}
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			node.Content = append(node.Content, scalar("!!str", key), valueNode(v[key]))
+		}
+		return node
+	case []map[string]interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range v {
+			node.Content = append(node.Content, valueNode(item))
+		}
+		return node
+	case []interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range v {
+			node.Content = append(node.Content, valueNode(item))
+		}
+		return node
+	case string:
+		return scalar("!!str", v)
+	case bool:
+		return scalar("!!bool", strconv.FormatBool(v))
+	case int64:
+		return scalar("!!int", strconv.FormatInt(v, 10))
+	case float64:
+		return scalar("!!float", strconv.FormatFloat(v, 'g', -1, 64))
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return scalar("!!int", v.String())
+		}
+		return scalar("!!float", v.String())
+	case time.Time:
+		return scalar("!!timestamp", v.Format(time.RFC3339Nano))
+	case nil:
+		return scalar("!!null", "null")
+	}
+	return scalar("!!str", fmt.Sprint(value))
+}
+
+// encodeConfig writes config to w in the format of ext. TOML and JSON get the keys YAML would, since
+// the fields only carry yaml tags.
+func encodeConfig(w io.Writer, config Config, ext string) error {
+	switch ext {
+	case ".yaml", ".yml":
+		encoder := yaml.NewEncoder(w)
+		defer encoder.Close()
+		encoder.SetIndent(2)
+		if err := encoder.Encode(config); err != nil {
+			return fmt.Errorf("failed to encode YAML config: %w", err)
+		}
+		return nil
+	case ".toml", ".json":
+		var node yaml.Node
+		if err := node.Encode(config); err != nil {
+			return fmt.Errorf("failed to encode config: %w", err)
+		}
+		var value map[string]interface{}
+		if err := node.Decode(&value); err != nil {
+			return fmt.Errorf("failed to encode config: %w", err)
+		}
+		if ext == ".json" {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(value); err != nil {
+				return fmt.Errorf("failed to encode JSON config: %w", err)
+			}
+			return nil
+		}
+		if err := toml.NewEncoder(w).Encode(dropNulls(value)); err != nil {
+			return fmt.Errorf("failed to encode TOML config: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported config file format: %s (supported: %s)", ext, strings.Join(Extensions, ", "))
+}
+
+// dropNulls removes the null values TOML has no way of writing
+func dropNulls(value map[string]interface{}) map[string]interface{} {
+	for key, v := range value {
+		switch v := v.(type) {
+		case nil:
+			delete(value, key)
+		case map[string]interface{}:
+			value[key] = dropNulls(v)
+		}
+	}
+	return value
+}
@@ -0,0 +1,96 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ConfigEnv names the environment variable that points at the configuration, as a file or as a
+// directory laid out like --config-dir
+const ConfigEnv = "ZEROPLEX_CONFIG"
+
+// DirFiles lists the configuration files of a configuration directory in load order: zeroplex.yml
+// (or zeroplex.yaml, .toml or .json) if present, then every configuration file in conf.d sorted by
+// name. Either part may be
+// missing, but not both, so a directory generated elsewhere (a container volume, a NixOS module) can
+// consist of fragments only.
+func DirFiles(dir string) ([]string, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("configuration directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("configuration directory %s is not a directory", dir)
+	}
+
+	var files []string
+	var names []string
+	for _, ext := range Extensions {
+		names = append(names, "zeroplex"+ext)
+	}
+	for _, ext := range Extensions {
+		names = append(names, "zeroplex"+ext+".age")
+	}
+	for _, name := range names {
+		if fi, err := os.Stat(filepath.Join(dir, name)); err == nil && !fi.IsDir() {
+			files = append(files, filepath.Join(dir, name))
+			break
+		}
+	}
+
+	fragments, err := fragmentFiles(filepath.Join(dir, "conf.d"))
+	if err != nil {
+		return nil, fmt.Errorf("configuration directory: %w", err)
+	}
+	files = append(files, fragments...)
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("configuration directory %s has neither zeroplex.yml nor configuration files in conf.d", dir)
+	}
+	return files, nil
+}
+
+// DropInDir returns the drop-in directory of a configuration file: the file's name without its
+// extension followed by .d, so /etc/zeroplex.d for /etc/zeroplex.yml
+func DropInDir(file string) string {
+	base := strings.TrimSuffix(file, ".age")
+	return strings.TrimSuffix(base, filepath.Ext(base)) + ".d"
+}
+
+// DropInFiles lists the configuration fragments in the drop-in directory of file, sorted by name,
+// to be loaded over it. Packages and provisioning tools add fragments there rather than edit the
+// file. A missing directory has none.
+func DropInFiles(file string) ([]string, error) {
+	fragments, err := fragmentFiles(DropInDir(file))
+	if err != nil {
+		return nil, fmt.Errorf("drop-in directory: %w", err)
+	}
+	return fragments, nil
+}
+
+// fragmentFiles lists the configuration files in dir sorted by name, skipping hidden files and
+// other extensions; a missing dir has none
+func fragmentFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var fragments []string
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(strings.TrimSuffix(entry.Name(), ".age")))
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || !slices.Contains(Extensions, ext) {
+			continue
+		}
+		fragments = append(fragments, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(fragments)
+	return fragments, nil
+}
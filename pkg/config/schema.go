@@ -0,0 +1,606 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package config
+
+import (
+	"zeroplex/pkg/utils"
+
+	"fmt"
+	"net"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FieldError is a problem with one key of the configuration. File, Line and Column say where the
+// key is set when that is known: for a key of a loaded YAML file that still has the loaded value.
+type FieldError struct {
+	Path    string // e.g. profiles.office.networks.8056c2e21c000001.hold_down
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	switch {
+	case e.Line > 0 && e.File != "":
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+	case e.Line > 0:
+		return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+	}
+	return e.Message
+}
+
+// ValidationError is every problem ValidateConfig found, in the order of the keys
+type ValidationError []*FieldError
+
+func (e ValidationError) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	lines := []string{fmt.Sprintf("%d problems in the configuration:", len(e))}
+	for _, err := range e {
+		lines = append(lines, "  "+err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e ValidationError) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// configSource is a loaded configuration file, kept to tell where a key was set
+type configSource struct {
+	file string
+	root *yaml.Node
+}
+
+// check validates the value of a key, returning what is wrong with it as "value (must be ...)".
+// Ints are checked in their decimal form; unset keys, empty or 0, are never checked.
+type check func(value string) error
+
+// fieldRules are the checks of the values of the keys of a profile. In a key, * stands for any key of
+// a map and [] for any element of a list.
+var fieldRules = map[string]check{
+	"mode":                          oneOfAnyCase(Modes...),
+	"init_system":                   oneOfAnyCase("auto", "systemd", "openrc", "runit", "none"),
+	"log.level":                     oneOfAnyCase(LogLevels...),
+	"log.type":                      oneOf("console", "file", "both"),
+	"log.file":                      absolutePath,
+	"daemon.poll_interval":          interval,
+	"daemon.start_jitter":           interval,
+	"client.port":                   portNumber,
+	"features.mdns_conflict":        oneOfAnyCase("warn", "skip", "avahi", "off"),
+	"features.watchdog_ip":          ipAddress,
+	"features.watchdog_interval":    duration,
+	"features.watchdog_backoff[]":   duration,
+	"features.watchdog_expected_ip": ipList,
+	"features.verify_timeout":       duration,
+	"features.watchdog_networks.*.expected_ip": ipList,
+	"features.watchdog_exec_timeout":           positiveInterval,
+	"networkd.max_reloads_per_hour":            atLeast(0),
+	"networkd.wait_for_carrier":                duration,
+	"dnsmasq.config_dir":                       absolutePath,
+	"dnsmasq.servers_file":                     absolutePath,
+	"unbound.include_file":                     absolutePath,
+	"interface_watch.mode":                     oneOf(InterfaceWatchModes...),
+	"interface_watch.retry.count":              atLeast(0),
+	"interface_watch.retry.delay":              duration,
+	"interface_watch.retry.backoff[]":          duration,
+	"interface_watch.retry.max_total":          duration,
+	"interface_watch.retry.global_timeout":     duration,
+	"interface_watch.retry.max_concurrent":     atLeast(0),
+	"interface_watch.readiness":                oneOf(ReadinessStrategies...),
+	"interface_watch.checks[]":                 readinessCheck,
+	"interface_watch.networks.*.readiness":     oneOf(ReadinessStrategies...),
+	"interface_watch.networks.*.checks[]":      readinessCheck,
+	"control.socket":                           absolutePath,
+	"control.mode":                             fileMode,
+	"health.listen":                            hostPort,
+	"grpc.listen":                              grpcListen,
+	"hardening.umask":                          umask,
+	"safety.max_changes_per_run":               atLeast(0),
+	"safety.max_removals_per_run":              atLeast(0),
+	"maintenance.defer_windows[]":              cronWindow,
+	"maintenance.timezone":                     timezone,
+	"resolv_watch.paths[]":                     absolutePath,
+	"resolv_watch.interval":                    positiveInterval,
+	"coordination.policy":                      oneOfAnyCase("yield", "override", "merge"),
+	"coordination.domains.*":                   oneOfAnyCase("yield", "override", "merge"),
+	"server_order.strategy":                    oneOf(ServerOrderStrategies...),
+	"server_order.interval":                    positiveInterval,
+	"networks.*.mode":                          oneOfAnyCase(Modes[1:]...),
+	"networks.*.dns_servers[]":                 ipAddress,
+	"networks.*.hold_down":                     duration,
+	"webhooks[].url":                           httpURL,
+	"webhooks[].timeout":                       duration,
+	"config_epoch":                             singleToken,
+	"state_dir":                                absolutePath,
+	"timeout":                                  positiveDuration,
+}
+
+// keyRules are the checks of the keys of the maps of a profile
+var keyRules = map[string]check{
+	"labels":                     labelName,
+	"networks":                   networkID,
+	"features.watchdog_networks": networkID,
+	"interface_watch.networks":   networkID,
+	"experimental":               oneOf(ExperimentalFeatures...),
+}
+
+// FilterTypes are the values of the type of a filter
+var FilterTypes = []string{"none", "name", "interface", "network", "network_id", "online", "assigned", "address", "route"}
+
+func oneOf(values ...string) check {
+	return func(value string) error {
+		if !slices.Contains(values, value) {
+			return fmt.Errorf("%s (must be one of %s)", value, strings.Join(values, ", "))
+		}
+		return nil
+	}
+}
+
+func oneOfAnyCase(values ...string) check {
+	exact := oneOf(values...)
+	return func(value string) error {
+		if exact(strings.ToLower(value)) != nil {
+			return exact(value)
+		}
+		return nil
+	}
+}
+
+func atLeast(min int) check {
+	return func(value string) error {
+		if n, err := strconv.Atoi(value); err != nil || n < min {
+			return fmt.Errorf("%s (must be %d or more)", value, min)
+		}
+		return nil
+	}
+}
+
+func portNumber(value string) error {
+	if n, err := strconv.Atoi(value); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%s (must be a port between 1 and 65535)", value)
+	}
+	return nil
+}
+
+// duration checks a value parsed with time.ParseDuration, such as the timeouts
+func duration(value string) error {
+	if d, err := time.ParseDuration(value); err != nil || d < 0 {
+		return fmt.Errorf("%s (must be a duration such as 30s)", value)
+	}
+	return nil
+}
+
+func positiveDuration(value string) error {
+	if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+		return fmt.Errorf("%s (must be a positive duration such as 90s or 2m)", value)
+	}
+	return nil
+}
+
+// interval checks a value parsed with utils.ParseInterval, which also takes seconds and days
+func interval(value string) error {
+	if _, err := utils.ParseInterval(value); err != nil {
+		return fmt.Errorf("%s (must be a duration such as 60, 5m or 1d, or disabled)", value)
+	}
+	return nil
+}
+
+func positiveInterval(value string) error {
+	if d, err := utils.ParseInterval(value); err != nil || d <= 0 {
+		return fmt.Errorf("%s (must be a positive duration)", value)
+	}
+	return nil
+}
+
+func ipAddress(value string) error {
+	if utils.ParseIP(value) == nil {
+		return fmt.Errorf("%s (must be an IPv4 or IPv6 address)", value)
+	}
+	return nil
+}
+
+// ipList checks comma separated IPv4 and IPv6 addresses, as watchdog_expected_ip takes
+func ipList(value string) error {
+	for _, ip := range utils.SplitIPs(value) {
+		if utils.ParseIP(ip) == nil {
+			return fmt.Errorf("%s (must be IPv4 or IPv6 addresses, comma separated)", ip)
+		}
+	}
+	return nil
+}
+
+func hostPort(value string) error {
+	if _, _, err := net.SplitHostPort(value); err != nil {
+		return fmt.Errorf("%s (must be host:port)", value)
+	}
+	return nil
+}
+
+func grpcListen(value string) error {
+	if path, ok := strings.CutPrefix(value, "unix:"); ok {
+		if path == "" {
+			return fmt.Errorf("%s (the socket path is missing)", value)
+		}
+		return nil
+	}
+	if hostPort(value) != nil {
+		return fmt.Errorf("%s (must be unix:PATH or host:port)", value)
+	}
+	return nil
+}
+
+func umask(value string) error {
+	if mask, err := strconv.ParseUint(value, 8, 32); err != nil || mask > 0777 {
+		return fmt.Errorf("%s (must be an octal mask such as 0077)", value)
+	}
+	return nil
+}
+
+func fileMode(value string) error {
+	if mode, err := strconv.ParseUint(value, 8, 32); err != nil || mode > 0777 {
+		return fmt.Errorf("%s (must be octal permissions such as 0660)", value)
+	}
+	return nil
+}
+
+func cronWindow(value string) error {
+	if _, err := utils.ParseCronWindow(value); err != nil {
+		return err
+	}
+	return nil
+}
+
+func timezone(value string) error {
+	if _, err := time.LoadLocation(value); err != nil {
+		return fmt.Errorf("%s (%v)", value, err)
+	}
+	return nil
+}
+
+func absolutePath(value string) error {
+	if !filepath.IsAbs(value) {
+		return fmt.Errorf("%s (must be an absolute path)", value)
+	}
+	return nil
+}
+
+// readinessCheck checks a strategy combined by the composite strategy, which can't combine itself
+func readinessCheck(value string) error {
+	if value == "composite" || !slices.Contains(ReadinessStrategies, value) {
+		return fmt.Errorf("%s (must be one of %s)", value, strings.Join(ReadinessStrategies[:len(ReadinessStrategies)-1], ", "))
+	}
+	return nil
+}
+
+// httpURL checks a webhook URL; it may hold %variables%, so it isn't parsed any further
+func httpURL(value string) error {
+	if lower := strings.ToLower(value); !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
+		return fmt.Errorf("%s (must be an http:// or https:// URL)", value)
+	}
+	return nil
+}
+
+// singleToken keeps a value a single token, such as config_epoch, so it can be grepped for in logs
+func singleToken(value string) error {
+	if strings.ContainsFunc(value, unicode.IsSpace) || strings.ContainsFunc(value, unicode.IsControl) {
+		return fmt.Errorf("%q (must not contain spaces or control characters)", value)
+	}
+	return nil
+}
+
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// labelName checks that a label name is usable as a Prometheus label name
+func labelName(value string) error {
+	if !labelNamePattern.MatchString(value) || strings.HasPrefix(value, "__") {
+		return fmt.Errorf("%q (must match [a-zA-Z_][a-zA-Z0-9_]* and not start with __)", value)
+	}
+	return nil
+}
+
+// networkIDPattern matches a ZeroTier network ID
+var networkIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{16}$`)
+
+func networkID(value string) error {
+	if !networkIDPattern.MatchString(value) {
+		return fmt.Errorf("%s is not a ZeroTier network ID (16 hex digits)", value)
+	}
+	return nil
+}
+
+// validator collects the problems of a configuration, finding where each was set in sources
+type validator struct {
+	sources []configSource
+	errs    ValidationError
+}
+
+// report records a problem with the key at path. value is what the key holds, or the key itself for
+// a problem with a map key; the position is only given when the file still holds that.
+func (v *validator) report(path []string, value, format string, args ...interface{}) {
+	err := &FieldError{Path: joinPath(path), Message: fmt.Sprintf(format, args...)}
+	// The last file setting the key is the one whose value counts
+	for i := len(v.sources) - 1; i >= 0; i-- {
+		key, node := findNode(v.sources[i].root, path)
+		if key == nil {
+			continue
+		}
+		if node.Kind == yaml.ScalarNode && node.Value == value {
+			err.Line, err.Column = node.Line, node.Column
+		} else if key.Value == value || value == "" {
+			err.Line, err.Column = key.Line, key.Column
+		}
+		if err.Line > 0 {
+			err.File = v.sources[i].file
+		}
+		break
+	}
+	v.errs = append(v.errs, err)
+}
+
+// findNode returns the key node and the value node path leads to in a YAML document
+func findNode(root *yaml.Node, path []string) (*yaml.Node, *yaml.Node) {
+	if root == nil || root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return nil, nil
+	}
+	var key *yaml.Node
+	node := root.Content[0]
+	for _, name := range path {
+		if index, ok := pathIndex(name); ok {
+			if node.Kind != yaml.SequenceNode || index >= len(node.Content) {
+				return nil, nil
+			}
+			key, node = node.Content[index], node.Content[index]
+			continue
+		}
+		if node.Kind != yaml.MappingNode {
+			return nil, nil
+		}
+		found := false
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == name {
+				key, node, found = node.Content[i], node.Content[i+1], true
+				break
+			}
+		}
+		if !found {
+			return nil, nil
+		}
+	}
+	return key, node
+}
+
+// pathIndex returns the index of a path element naming a list element, such as [2]
+func pathIndex(name string) (int, bool) {
+	if !strings.HasPrefix(name, "[") || !strings.HasSuffix(name, "]") {
+		return 0, false
+	}
+	index, err := strconv.Atoi(name[1 : len(name)-1])
+	return index, err == nil
+}
+
+// joinPath writes a path as it is written in messages and fieldRules: networks.ID.dns_servers[0]
+func joinPath(path []string) string {
+	return strings.ReplaceAll(strings.Join(path, "."), ".[", "[")
+}
+
+// fieldName returns the key of a struct field, or "" if it isn't a key
+func fieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+	if !field.IsExported() || name == "-" {
+		return ""
+	}
+	return name
+}
+
+// walk checks the value at path against fieldRules and keyRules, and everything below it. pattern
+// is path with its map keys replaced by * and its list indexes by [].
+func (v *validator) walk(path, pattern []string, value reflect.Value) {
+	switch value.Kind() {
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			if name := fieldName(value.Type().Field(i)); name != "" {
+				v.walk(append(slices.Clip(path), name), append(slices.Clip(pattern), name), value.Field(i))
+			}
+		}
+	case reflect.Map:
+		keys := value.MapKeys()
+		sort.Slice(keys, func(a, b int) bool { return keys[a].String() < keys[b].String() })
+		rule := keyRules[joinPath(pattern)]
+		for _, key := range keys {
+			keyPath := append(slices.Clip(path), key.String())
+			if rule != nil {
+				if err := rule(key.String()); err != nil {
+					v.report(keyPath, key.String(), "invalid %s: %v", joinPath(path), err)
+				}
+			}
+			v.walk(keyPath, append(slices.Clip(pattern), "*"), value.MapIndex(key))
+		}
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			v.walk(append(slices.Clip(path), fmt.Sprintf("[%d]", i)), append(slices.Clip(pattern), "[]"), value.Index(i))
+		}
+	case reflect.Ptr:
+		if !value.IsNil() {
+			v.walk(path, pattern, value.Elem())
+		}
+	case reflect.String, reflect.Int, reflect.Uint64:
+		rule := fieldRules[joinPath(pattern)]
+		if rule == nil || value.IsZero() {
+			return
+		}
+		text := fmt.Sprint(value.Interface())
+		if err := rule(text); err != nil {
+			v.report(path, text, "invalid %s: %v", joinPath(path), err)
+		}
+	}
+}
+
+// unknownKeys reports the keys of a YAML node that t has no field for, so a misspelled key isn't
+// silently left at its default
+func (v *validator) unknownKeys(path []string, node *yaml.Node, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case node.Kind == yaml.AliasNode:
+		return
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		fields := map[string]reflect.Type{}
+		for i := 0; i < t.NumField(); i++ {
+			if name := fieldName(t.Field(i)); name != "" {
+				fields[name] = t.Field(i).Type
+			}
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			keyPath := append(slices.Clip(path), key.Value)
+			field, ok := fields[key.Value]
+			if !ok {
+				v.report(keyPath, key.Value, "unknown key %s", joinPath(keyPath))
+				continue
+			}
+			v.unknownKeys(keyPath, node.Content[i+1], field)
+		}
+	case t.Kind() == reflect.Map && node.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			v.unknownKeys(append(slices.Clip(path), node.Content[i].Value), node.Content[i+1], t.Elem())
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for i, element := range node.Content {
+			v.unknownKeys(append(slices.Clip(path), fmt.Sprintf("[%d]", i)), element, t.Elem())
+		}
+	}
+}
+
+// filters checks the shape of the filters of a profile, which are decoded as plain maps
+func (v *validator) filters(path []string, filters []map[string]interface{}) {
+	for i, filter := range filters {
+		at := append(slices.Clip(path), fmt.Sprintf("[%d]", i))
+		keys := make([]string, 0, len(filter))
+		for key := range filter {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			if !slices.Contains([]string{"type", "value", "operation", "negate", "conditions"}, key) {
+				keyPath := append(slices.Clip(at), key)
+				v.report(keyPath, key, "unknown key %s (a filter has type, value, operation, negate and conditions)", joinPath(keyPath))
+			}
+		}
+		typ, ok := filter["type"].(string)
+		if !ok {
+			v.report(at, "", "invalid %s: the filter has no type (must be one of %s)", joinPath(at), strings.Join(FilterTypes, ", "))
+		} else if err := oneOf(FilterTypes...)(typ); err != nil {
+			v.report(append(slices.Clip(at), "type"), typ, "invalid %s.type: %v", joinPath(at), err)
+		}
+		if value, ok := filter["value"]; ok {
+			if _, ok := value.(string); !ok {
+				v.report(append(slices.Clip(at), "value"), "", "invalid %s.value: %v (must be a string)", joinPath(at), value)
+			}
+		}
+		if operation, ok := filter["operation"]; ok {
+			text, _ := operation.(string)
+			if err := oneOf("AND", "OR", "NOT")(strings.ToUpper(text)); err != nil {
+				v.report(append(slices.Clip(at), "operation"), text, "invalid %s.operation: %v (must be and, or, or not)", joinPath(at), operation)
+			}
+		}
+		if negate, ok := filter["negate"]; ok {
+			if _, ok := negate.(bool); !ok {
+				v.report(append(slices.Clip(at), "negate"), "", "invalid %s.negate: %v (must be true or false)", joinPath(at), negate)
+			}
+		}
+		conditions, ok := filter["conditions"]
+		if !ok {
+			continue
+		}
+		list, ok := conditions.([]interface{})
+		if !ok {
+			v.report(append(slices.Clip(at), "conditions"), "", "invalid %s.conditions: must be a list of value and logic", joinPath(at))
+			continue
+		}
+		for j, condition := range list {
+			cp := append(slices.Clip(at), "conditions", fmt.Sprintf("[%d]", j))
+			fields, ok := condition.(map[string]interface{})
+			if !ok {
+				v.report(cp, "", "invalid %s: must be a value and a logic", joinPath(cp))
+				continue
+			}
+			for key, value := range fields {
+				switch key {
+				case "value":
+					if _, ok := value.(string); !ok {
+						v.report(append(slices.Clip(cp), key), "", "invalid %s.value: %v (must be a string)", joinPath(cp), value)
+					}
+				case "logic":
+					text, _ := value.(string)
+					if err := oneOf("and", "or")(strings.ToLower(text)); err != nil {
+						v.report(append(slices.Clip(cp), key), text, "invalid %s.logic: %v (must be and or or)", joinPath(cp), value)
+					}
+				default:
+					keyPath := append(slices.Clip(cp), key)
+					v.report(keyPath, key, "unknown key %s (a condition has value and logic)", joinPath(keyPath))
+				}
+			}
+		}
+	}
+}
+
+// profile checks a profile at path: default, or profiles and its name
+func (v *validator) profile(path []string, p Profile) {
+	v.walk(path, nil, reflect.ValueOf(p))
+	v.filters(append(slices.Clip(path), "filters"), p.Filters)
+	at := func(keys ...string) []string {
+		return append(slices.Clip(path), keys...)
+	}
+
+	var set []string
+	for key, value := range map[string]string{"token_source": p.Client.TokenSource, "token_env": p.Client.TokenEnv, "token_command": p.Client.TokenCommand} {
+		if value != "" {
+			set = append(set, "client."+key)
+		}
+	}
+	if len(set) > 1 {
+		slices.Sort(set)
+		v.report(at("client"), "", "invalid %s: %s are exclusive (set only one)", joinPath(at("client")), strings.Join(set, " and "))
+	}
+	if len(p.Features.WatchdogExec) > 0 && strings.TrimSpace(p.Features.WatchdogExec[0]) == "" {
+		v.report(at("features", "watchdog_exec", "[0]"), p.Features.WatchdogExec[0], "invalid %s: the first element must be the program to run", joinPath(at("features", "watchdog_exec")))
+	}
+	if p.InterfaceWatch.Readiness == "composite" && len(p.InterfaceWatch.Checks) == 0 {
+		v.report(at("interface_watch", "readiness"), "composite", "invalid %s: composite needs the strategies to combine in %s", joinPath(at("interface_watch", "readiness")), joinPath(at("interface_watch", "checks")))
+	}
+	ids := make([]string, 0, len(p.InterfaceWatch.Networks))
+	for id := range p.InterfaceWatch.Networks {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		network := p.InterfaceWatch.Networks[id]
+		if network.Readiness == "composite" && len(network.Checks) == 0 && len(p.InterfaceWatch.Checks) == 0 {
+			key := at("interface_watch", "networks", id)
+			v.report(append(key, "readiness"), "composite", "invalid %s.readiness: composite needs the strategies to combine in %s.checks", joinPath(key), joinPath(key))
+		}
+	}
+	if p.Hardening.Group != "" && p.Hardening.User == "" {
+		v.report(at("hardening", "group"), p.Hardening.Group, "invalid %s: %s (needs %s)", joinPath(at("hardening", "group")), p.Hardening.Group, joinPath(at("hardening", "user")))
+	}
+}
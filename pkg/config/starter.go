@@ -0,0 +1,109 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Starter holds what `zeroplex config init` detected, or was told, about the host
+type Starter struct {
+	Mode         string
+	Host         string
+	Port         int
+	TokenFile    string
+	Daemon       bool
+	PollInterval string
+	Networks     []string // IDs of the networks to manage; all joined networks when empty
+}
+
+// DefaultStarter returns the starter settings of this host: the built-in defaults, with the port
+// zerotier-one listens on when it says so
+func DefaultStarter() Starter {
+	defaults := DefaultConfig().Default
+	starter := Starter{
+		Mode:         defaults.Mode,
+		Host:         defaults.Client.Host,
+		Port:         defaults.Client.Port,
+		TokenFile:    defaults.Client.TokenFile,
+		Daemon:       true,
+		PollInterval: defaults.Daemon.PollInterval,
+	}
+	if port, ok := ZeroTierPort(filepath.Dir(starter.TokenFile)); ok {
+		starter.Port = port
+	}
+	return starter
+}
+
+// ZeroTierPort returns the port zerotier-one writes to zerotier-one.port in its home directory
+// once it listens, which differs from 9993 when primaryPort is set in local.conf
+func ZeroTierPort(home string) (int, bool) {
+	content, err := os.ReadFile(filepath.Join(home, "zerotier-one.port"))
+	if err != nil {
+		return 0, false
+	}
+	port, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, false
+	}
+	return port, true
+}
+
+var starterTemplate = template.Must(template.New("starter").Funcs(template.FuncMap{"quote": strconv.Quote}).Parse(`# ZeroPlex Configuration
+#
+# Written by zeroplex config init. Every key left out keeps its built-in default;
+# see contrib/config/zeroplex.yml.sample and the README for all of them.
+
+default:
+  # Options: auto, networkd, resolved, networkmanager, resolvconf, resolvfile, dnsmasq, unbound, openwrt, macos, windows, noop
+  mode: {{quote .Mode}}
+  # enforce: false              # Only report drift via logs, metrics and webhooks, change nothing
+  log:
+    level: "info"               # Options: error, warn, info, verbose, debug, trace
+  daemon:
+    # true: keep running and reconcile every poll_interval; false: reconcile once and exit
+    enabled: {{.Daemon}}
+    poll_interval: {{quote .PollInterval}}
+    # watch_config: true        # Reload when this file changes (SIGHUP always reloads)
+  client:
+    host: {{quote .Host}}
+    port: {{.Port}}
+    token_file: {{quote .TokenFile}}
+    # token_source: "systemd-creds://ztauth" # file://, env://, cmd://, vault://path#field or systemd-creds:// (overrides token_file)
+  features:
+    # add_reverse_domains: true # Route reverse lookups of the networks' subnets to their DNS servers
+    # restore_on_exit: true     # Remove the DNS settings of the networks when zeroplex stops
+    # watchdog_hostname: "%hostname%.%domain%" # Re-apply DNS when this stops resolving
+{{- if .Networks}}
+  filters:                      # Manage only these networks
+    - type: "network_id"
+      conditions:
+{{- range .Networks}}
+        - value: {{quote .}}
+          logic: "or"
+{{- end}}
+{{- else}}
+  # filters:                    # Manage only some of the joined networks
+  #   - type: "network_id"
+  #     conditions:
+  #       - value: "8056c2e21c000001"
+  #         logic: "or"
+{{- end}}
+`))
+
+// StarterConfig returns a commented starter configuration for s, as YAML
+func StarterConfig(s Starter) []byte {
+	var buf bytes.Buffer
+	if err := starterTemplate.Execute(&buf, s); err != nil {
+		// Only a broken template fails, with any Starter
+		panic(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,297 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package control defines the daemon's local control API, an HTTP API served on a Unix socket,
+// and a client for it used by commands such as `zeroplex top`
+package control
+
+import (
+	"zeroplex/pkg/bus"
+	"zeroplex/pkg/events"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/state"
+	"zeroplex/pkg/utils"
+
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+// DefaultSocket is where the control API listens unless control.socket is set
+const DefaultSocket = "/run/zeroplex/control.sock"
+
+// StatusPath is the endpoint returning a Status document
+const StatusPath = "/v1/status"
+
+// EventsPath streams published events as they happen, as Server-Sent Events or, when the request
+// asks for an upgrade, over a WebSocket. The optional query parameters are types (comma separated
+// event types to include) and replay (number of recent events to send first).
+const EventsPath = "/v1/events"
+
+// LogsPath returns the daemon's most recent log records, oldest first, whatever its log output is
+// configured to. The optional tail query parameter limits how many are returned.
+const LogsPath = "/v1/logs"
+
+// MetricsPath returns the daemon's metrics in OpenMetrics text format
+const MetricsPath = "/v1/metrics"
+
+// NetworksPath returns the networks ZeroTier has joined as JoinedNetwork documents, for users who
+// can't read the ZeroTier API token themselves
+const NetworksPath = "/v1/networks"
+
+// The command endpoints, called with POST. ApplyPath resumes scheduled runs and reconciles now,
+// RestorePath reverts everything zeroplex manages and pauses scheduled runs until the next apply,
+// ReloadPath re-reads the configuration, and PausePath and ResumePath stop and restart scheduled runs.
+const (
+	ApplyPath   = "/v1/apply"
+	RestorePath = "/v1/restore"
+	ReloadPath  = "/v1/reload"
+	PausePath   = "/v1/pause"
+	ResumePath  = "/v1/resume"
+)
+
+// Watchdog is the latest result of a single DNS watchdog target
+type Watchdog struct {
+	Target    string    `json:"target"`
+	Kind      string    `json:"kind"` // "ping" or "lookup"
+	Healthy   bool      `json:"healthy"`
+	Failures  int       `json:"failures"` // consecutive failed checks
+	LastCheck time.Time `json:"last_check"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Status is the document served on StatusPath: the daemon snapshot plus what zeroplex manages,
+// the watchdog results and recent history
+type Status struct {
+	bus.Snapshot
+	Hostname     string            `json:"hostname,omitempty"`
+	Version      string            `json:"version,omitempty"`
+	DriftPending int               `json:"drift_pending"` // items an observe-only or deferred run left uncorrected
+	Interfaces   []state.Interface `json:"interfaces"`
+	Watchdogs    []Watchdog        `json:"watchdogs"`
+	Events       []events.Event    `json:"events"`
+	Actions      []state.Action    `json:"actions"`
+}
+
+// Peer is the user and the groups of the client of a control API connection
+type Peer struct {
+	UID    int
+	Groups []int // primary and supplementary group IDs
+}
+
+// InGroup reports whether the peer is a member of the group with ID gid
+func (p Peer) InGroup(gid int) bool {
+	return slices.Contains(p.Groups, gid)
+}
+
+// JoinedNetwork is a network ZeroTier has joined, as `zeroplex networks list` prints it
+type JoinedNetwork struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Interface string   `json:"interface"`
+	Status    string   `json:"status"`
+	Servers   []string `json:"servers"`
+	Domain    string   `json:"domain"`
+	Included  bool     `json:"included"` // passes the configured filters
+}
+
+// JoinedNetworks describes all, the networks ZeroTier has joined, of which the filters keep included
+func JoinedNetworks(all []service.Network, included []service.Network) []JoinedNetwork {
+	kept := map[string]bool{}
+	for _, network := range included {
+		kept[utils.GetString(network.Id)] = true
+	}
+	networks := []JoinedNetwork{}
+	for _, network := range all {
+		joined := JoinedNetwork{
+			ID:        utils.GetString(network.Id),
+			Name:      utils.GetString(network.Name),
+			Interface: utils.GetString(network.PortDeviceName),
+			Status:    utils.GetString(network.Status),
+			Servers:   []string{},
+		}
+		joined.Included = kept[joined.ID]
+		if network.Dns != nil {
+			joined.Domain = utils.GetString(network.Dns.Domain)
+			if network.Dns.Servers != nil {
+				joined.Servers = append(joined.Servers, *network.Dns.Servers...)
+			}
+		}
+		networks = append(networks, joined)
+	}
+	return networks
+}
+
+// Result is the answer to a command
+type Result struct {
+	Message  string   `json:"message"`
+	Restored []string `json:"restored,omitempty"` // interfaces reverted by a restore
+}
+
+// Client talks to the control API of a running daemon
+type Client struct {
+	socket string
+	http   *http.Client
+	stream *http.Client // like http, without the timeout, for long-lived streams
+}
+
+// NewClient returns a client for the control API listening on socket (DefaultSocket if empty)
+func NewClient(socket string) *Client {
+	if socket == "" {
+		socket = DefaultSocket
+	}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}
+	return &Client{
+		socket: socket,
+		http:   &http.Client{Timeout: 10 * time.Second, Transport: transport},
+		stream: &http.Client{Transport: transport},
+	}
+}
+
+// Status fetches the current Status document
+func (c *Client) Status(ctx context.Context) (Status, error) {
+	var status Status
+	err := c.get(ctx, StatusPath, &status)
+	return status, err
+}
+
+// Networks fetches the networks ZeroTier has joined, through the daemon
+func (c *Client) Networks(ctx context.Context) ([]JoinedNetwork, error) {
+	var networks []JoinedNetwork
+	err := c.get(ctx, NetworksPath, &networks)
+	return networks, err
+}
+
+// EventsOptions selects what Events streams
+type EventsOptions struct {
+	Types  []string // event types to include; all when empty
+	Replay int      // number of recent events to receive before newly published ones
+}
+
+// Events streams events from EventsPath to fn until ctx is cancelled, the daemon goes away or fn
+// returns an error
+func (c *Client) Events(ctx context.Context, opts EventsOptions, fn func(events.Event) error) error {
+	query := url.Values{}
+	if len(opts.Types) > 0 {
+		query.Set("types", strings.Join(opts.Types, ","))
+	}
+	if opts.Replay > 0 {
+		query.Set("replay", strconv.Itoa(opts.Replay))
+	}
+	path := EventsPath
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	resp, err := c.do(ctx, c.stream, http.MethodGet, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Each event is a "data:" line followed by a blank line; comments and other fields are skipped
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var ev events.Event
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &ev); err != nil {
+			return fmt.Errorf("invalid event from control API: %w", err)
+		}
+		if err := fn(ev); err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("control API closed the event stream")
+}
+
+// Logs fetches up to the last tail log records kept by the daemon; tail <= 0 fetches all of them
+func (c *Client) Logs(ctx context.Context, tail int) ([]log.Record, error) {
+	path := LogsPath
+	if tail > 0 {
+		path += "?tail=" + strconv.Itoa(tail)
+	}
+	var records []log.Record
+	err := c.get(ctx, path, &records)
+	return records, err
+}
+
+// Metrics copies the daemon's metrics, in OpenMetrics text format, to w
+func (c *Client) Metrics(ctx context.Context, w io.Writer) error {
+	resp, err := c.do(ctx, c.http, http.MethodGet, MetricsPath)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// Command sends a command to one of the command endpoints, such as ApplyPath
+func (c *Client) Command(ctx context.Context, path string) (Result, error) {
+	resp, err := c.do(ctx, c.http, http.MethodPost, path)
+	if err != nil {
+		return Result{}, err
+	}
+	defer resp.Body.Close()
+	var result Result
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	return result, err
+}
+
+func (c *Client) get(ctx context.Context, path string, into interface{}) error {
+	resp, err := c.do(ctx, c.http, http.MethodGet, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(into)
+}
+
+// do issues a request for path, turning transport failures and non-200 answers into errors
+func (c *Client) do(ctx context.Context, client *http.Client, method, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, "http://zeroplex"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if errors.Is(err, fs.ErrPermission) {
+		return nil, fmt.Errorf("not allowed to connect to the control API at %s (is this user in control.group?): %w", c.socket, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("control API unavailable at %s (is the daemon running with control.enabled?): %w", c.socket, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("control API %s returned %s: %s", path, resp.Status, body)
+	}
+	return resp, nil
+}
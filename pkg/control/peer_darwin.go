@@ -0,0 +1,36 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package control
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// PeerCredentials returns the user and the groups of the process at the other end of a Unix socket
+// connection
+func PeerCredentials(conn net.Conn) (Peer, bool) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return Peer{}, false
+	}
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return Peer{}, false
+	}
+	var cred *unix.Xucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptXucred(int(fd), unix.SOL_LOCAL, unix.LOCAL_PEERCRED)
+	}); err != nil || credErr != nil {
+		return Peer{}, false
+	}
+	peer := Peer{UID: int(cred.Uid)}
+	for _, gid := range cred.Groups[:cred.Ngroups] {
+		peer.Groups = append(peer.Groups, int(gid))
+	}
+	return peer, true
+}
@@ -0,0 +1,65 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package control
+
+import (
+	"net"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// maxPeerGroups bounds the supplementary groups read from a connection, as NGROUPS_MAX does
+const maxPeerGroups = 65536
+
+// PeerCredentials returns the user and the groups of the process at the other end of a Unix socket
+// connection, as the socket recorded them when it connected. A kernel before 4.13 doesn't record the
+// supplementary groups; only the primary group is known then.
+func PeerCredentials(conn net.Conn) (Peer, bool) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return Peer{}, false
+	}
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return Peer{}, false
+	}
+	var cred *unix.Ucred
+	var credErr error
+	var groups []int
+	if err := raw.Control(func(fd uintptr) {
+		if cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED); credErr == nil {
+			groups = peerGroups(int(fd))
+		}
+	}); err != nil || credErr != nil {
+		return Peer{}, false
+	}
+	return Peer{UID: int(cred.Uid), Groups: append([]int{int(cred.Gid)}, groups...)}, true
+}
+
+// peerGroups returns the supplementary groups SO_PEERGROUPS reports for the socket fd, or none
+// when it can't. They aren't read from /proc: the pid SO_PEERCRED reports may belong to another
+// process by then.
+func peerGroups(fd int) []int {
+	buf := make([]uint32, 64)
+	for {
+		size := uint32(len(buf) * 4)
+		_, _, errno := unix.Syscall6(unix.SYS_GETSOCKOPT, uintptr(fd), unix.SOL_SOCKET, unix.SO_PEERGROUPS,
+			uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)), 0)
+		if errno == unix.ERANGE && int(size/4) > len(buf) && size/4 <= maxPeerGroups {
+			// size is the length the groups need
+			buf = make([]uint32, size/4)
+			continue
+		}
+		if errno != 0 {
+			return nil
+		}
+		groups := make([]int, 0, size/4)
+		for _, gid := range buf[:size/4] {
+			groups = append(groups, int(gid))
+		}
+		return groups
+	}
+}
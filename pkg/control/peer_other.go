@@ -0,0 +1,16 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !linux && !darwin
+
+package control
+
+import (
+	"net"
+)
+
+// PeerCredentials are unknown on this platform
+func PeerCredentials(conn net.Conn) (Peer, bool) {
+	return Peer{}, false
+}
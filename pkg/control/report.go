@@ -0,0 +1,85 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package control
+
+import (
+	"sort"
+)
+
+// HostReport is one host of a fleet report: its status document, or why it couldn't be fetched
+type HostReport struct {
+	Host   string `json:"host"`
+	Status Status `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Reachable reports whether the status document of the host was fetched
+func (h HostReport) Reachable() bool {
+	return h.Error == ""
+}
+
+// Failing reports whether the last run of the host failed or one of its watchdogs is unhealthy
+func (h HostReport) Failing() bool {
+	if h.Status.LastError != "" {
+		return true
+	}
+	for _, watchdog := range h.Status.Watchdogs {
+		if !watchdog.Healthy {
+			return true
+		}
+	}
+	return false
+}
+
+// FleetSummary counts the hosts of a fleet report by their state
+type FleetSummary struct {
+	Hosts       int            `json:"hosts"`
+	Unreachable int            `json:"unreachable"`
+	Drifted     int            `json:"drifted"` // with drift pending
+	Failing     int            `json:"failing"`
+	Disabled    int            `json:"disabled"` // administratively
+	Versions    map[string]int `json:"versions"` // hosts by the version they run
+}
+
+// SummarizeFleet counts reports by state and version. Unreachable hosts only count as unreachable.
+func SummarizeFleet(reports []HostReport) FleetSummary {
+	summary := FleetSummary{Hosts: len(reports), Versions: map[string]int{}}
+	for _, report := range reports {
+		if !report.Reachable() {
+			summary.Unreachable++
+			continue
+		}
+		if report.Status.DriftPending > 0 {
+			summary.Drifted++
+		}
+		if report.Failing() {
+			summary.Failing++
+		}
+		if report.Status.Disabled {
+			summary.Disabled++
+		}
+		version := report.Status.Version
+		if version == "" {
+			version = "unknown"
+		}
+		summary.Versions[version]++
+	}
+	return summary
+}
+
+// SortedVersions returns the versions of the summary, the most common first
+func (s FleetSummary) SortedVersions() []string {
+	versions := make([]string, 0, len(s.Versions))
+	for version := range s.Versions {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		if s.Versions[versions[i]] != s.Versions[versions[j]] {
+			return s.Versions[versions[i]] > s.Versions[versions[j]]
+		}
+		return versions[i] < versions[j]
+	})
+	return versions
+}
@@ -9,6 +9,7 @@ import (
 
 	"context"
 	"fmt"
+	"sync"
 	"time"
 )
 
@@ -17,16 +18,28 @@ type Interface interface {
 	Start() error
 	Stop()
 	IsRunning() bool
+	NextRun() time.Time
+	// Done returns a channel closed once the current run loop (including any in-flight task) has exited
+	Done() <-chan struct{}
 }
 
+// closedChan is returned by Done before the first Start
+var closedChan = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
 // Simple implements basic daemon functionality
 type Simple struct {
-	interval    time.Duration
-	task        func(context.Context) error
-	ticker      *time.Ticker
-	stopChan    chan struct{}
-	running     bool
-	logger      *log.Logger
+	interval time.Duration
+	task     func(context.Context) error
+	logger   *log.Logger
+	mu       sync.Mutex
+	running  bool
+	stopChan chan struct{}
+	doneChan chan struct{}
+	nextRun  time.Time
 }
 
 // NewSimple creates a new daemon instance
@@ -34,52 +47,62 @@ func NewSimple(interval time.Duration, task func(context.Context) error) *Simple
 	return &Simple{
 		interval: interval,
 		task:     task,
-		stopChan: make(chan struct{}),
-		logger:   log.NewScopedLogger("[daemon]", "info"),
+		logger:   log.NewScopedLogger("[daemon]", ""),
 	}
 }
 
+// Start begins the run loop; a stopped daemon may be started again
 func (d *Simple) Start() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	if d.running {
 		return fmt.Errorf("daemon already running")
 	}
 
 	d.running = true
-	d.ticker = time.NewTicker(d.interval)
+	d.stopChan = make(chan struct{})
+	d.doneChan = make(chan struct{})
+	d.nextRun = time.Now().Add(d.interval)
 
-	go func() {
-		defer func() {
-			d.running = false
-			if d.ticker != nil {
-				d.ticker.Stop()
-			}
-		}()
+	go d.loop(d.stopChan, d.doneChan)
 
-		// Execute task immediately on start
-		d.logger.Debug("Executing initial task")
-		if err := d.task(context.Background()); err != nil {
-			d.logger.Error("Initial task execution failed: %v", err)
-		}
+	return nil
+}
 
-		// Then start the interval-based execution
-		for {
-			select {
-			case <-d.ticker.C:
-				d.logger.Debug("Executing scheduled task")
-				if err := d.task(context.Background()); err != nil {
-					d.logger.Error("Scheduled task execution failed: %v", err)
-				}
-			case <-d.stopChan:
-				d.logger.Debug("Daemon stopping")
-				return
-			}
-		}
+func (d *Simple) loop(stop <-chan struct{}, done chan<- struct{}) {
+	ticker := time.NewTicker(d.interval)
+	defer func() {
+		ticker.Stop()
+		d.setNextRun(time.Time{})
+		close(done)
 	}()
 
-	return nil
+	// Execute task immediately on start
+	d.logger.Debug("Executing initial task")
+	if err := d.task(context.Background()); err != nil {
+		d.logger.Error("Initial task execution failed: %v", err)
+	}
+
+	// Then start the interval-based execution
+	for {
+		select {
+		case <-ticker.C:
+			d.setNextRun(time.Now().Add(d.interval))
+			d.logger.Debug("Executing scheduled task")
+			if err := d.task(context.Background()); err != nil {
+				d.logger.Error("Scheduled task execution failed: %v", err)
+			}
+		case <-stop:
+			d.logger.Debug("Daemon stopping")
+			return
+		}
+	}
 }
 
+// Stop signals the run loop to exit; use Done to wait for an in-flight task to finish
 func (d *Simple) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	if !d.running {
 		return
 	}
@@ -89,5 +112,30 @@ func (d *Simple) Stop() {
 }
 
 func (d *Simple) IsRunning() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	return d.running
-}
\ No newline at end of file
+}
+
+// Done returns a channel closed once the run loop has exited
+func (d *Simple) Done() <-chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.doneChan == nil {
+		return closedChan
+	}
+	return d.doneChan
+}
+
+// NextRun returns when the next scheduled task execution is due (zero if not scheduled)
+func (d *Simple) NextRun() time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.nextRun
+}
+
+func (d *Simple) setNextRun(t time.Time) {
+	d.mu.Lock()
+	d.nextRun = t
+	d.mu.Unlock()
+}
@@ -0,0 +1,280 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package daemon
+
+import (
+	"zeroplex/pkg/log"
+
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Scheduler is a daemon whose schedule can be adjusted while it is running
+type Scheduler interface {
+	Interface
+	// Trigger queues an immediate task execution using ctx; it runs even while paused
+	Trigger(ctx context.Context)
+	// SetInterval changes the interval and restarts the countdown to the next run
+	SetInterval(interval time.Duration)
+	// Pause suspends scheduled executions until Resume is called
+	Pause()
+	Resume()
+	IsPaused() bool
+}
+
+// Scheduled implements Scheduler with a resettable timer instead of a fixed ticker
+type Scheduled struct {
+	task   func(context.Context) error
+	logger *log.Logger
+
+	mu          sync.Mutex
+	interval    time.Duration
+	startJitter time.Duration
+	skipInitial bool
+	beatEvery   time.Duration
+	beat        func()
+	running     bool
+	paused      bool
+	nextRun     time.Time
+	triggers    chan context.Context
+	reset       chan struct{}
+	stopChan    chan struct{}
+	doneChan    chan struct{}
+}
+
+// NewScheduled creates a new scheduler instance
+func NewScheduled(interval time.Duration, task func(context.Context) error) *Scheduled {
+	return &Scheduled{
+		interval: interval,
+		task:     task,
+		logger:   log.NewScopedLogger("[daemon]", ""),
+	}
+}
+
+// SetStartJitter delays the initial task by a random duration in [0, max) to spread out fleets
+// that start simultaneously. Must be called before Start.
+func (s *Scheduled) SetStartJitter(max time.Duration) {
+	s.mu.Lock()
+	s.startJitter = max
+	s.mu.Unlock()
+}
+
+// SetSkipInitialRun makes Start wait for the first interval instead of running the task immediately.
+// Must be called before Start.
+func (s *Scheduled) SetSkipInitialRun(skip bool) {
+	s.mu.Lock()
+	s.skipInitial = skip
+	s.mu.Unlock()
+}
+
+// SetHeartbeat calls beat every interval from the run loop, between the task executions, so a task
+// that hangs stops it. Must be called before Start.
+func (s *Scheduled) SetHeartbeat(every time.Duration, beat func()) {
+	s.mu.Lock()
+	s.beatEvery, s.beat = every, beat
+	s.mu.Unlock()
+}
+
+func (s *Scheduled) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return fmt.Errorf("daemon already running")
+	}
+
+	s.running = true
+	s.triggers = make(chan context.Context, 1)
+	s.reset = make(chan struct{}, 1)
+	s.stopChan = make(chan struct{})
+	s.doneChan = make(chan struct{})
+
+	go s.loop(s.stopChan, s.doneChan, s.triggers, s.reset)
+	return nil
+}
+
+func (s *Scheduled) loop(stop <-chan struct{}, done chan<- struct{}, triggers <-chan context.Context, reset <-chan struct{}) {
+	defer close(done)
+
+	s.mu.Lock()
+	jitter, skipInitial := s.startJitter, s.skipInitial
+	beatEvery, beat := s.beatEvery, s.beat
+	s.mu.Unlock()
+
+	// A nil channel never fires, so without a heartbeat its cases are never selected
+	var heartbeat <-chan time.Time
+	if beatEvery > 0 && beat != nil {
+		ticker := time.NewTicker(beatEvery)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+		beat()
+	}
+
+	if skipInitial {
+		s.logger.Debug("Skipping initial task, first run after %s", s.currentInterval())
+	} else {
+		if jitter > 0 {
+			delay := time.Duration(rand.Int63n(int64(jitter)))
+			s.logger.Debug("Delaying initial task by %s (start jitter up to %s)", delay.Round(time.Millisecond), jitter)
+			s.setNextRun(time.Now().Add(delay))
+			wait := time.After(delay)
+		jitterWait:
+			for {
+				select {
+				case <-wait:
+					break jitterWait
+				case <-heartbeat:
+					beat()
+				case <-stop:
+					s.logger.Debug("Daemon stopping")
+					s.setNextRun(time.Time{})
+					return
+				}
+			}
+		}
+
+		// Execute task on start
+		s.logger.Debug("Executing initial task")
+		if err := s.task(context.Background()); err != nil {
+			s.logger.Error("Initial task execution failed: %v", err)
+		}
+	}
+
+	timer := time.NewTimer(s.currentInterval())
+	s.setNextRun(time.Now().Add(s.currentInterval()))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			if s.IsPaused() {
+				s.logger.Debug("Scheduled task skipped (paused)")
+			} else {
+				s.logger.Debug("Executing scheduled task")
+				if err := s.task(context.Background()); err != nil {
+					s.logger.Error("Scheduled task execution failed: %v", err)
+				}
+			}
+			timer.Reset(s.currentInterval())
+			s.setNextRun(time.Now().Add(s.currentInterval()))
+		case ctx := <-triggers:
+			s.logger.Debug("Executing triggered task")
+			if err := s.task(ctx); err != nil {
+				s.logger.Error("Triggered task execution failed: %v", err)
+			}
+		case <-heartbeat:
+			beat()
+		case <-reset:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(s.currentInterval())
+			s.setNextRun(time.Now().Add(s.currentInterval()))
+			s.logger.Debug("Schedule reset, next run in %s", s.currentInterval())
+		case <-stop:
+			s.logger.Debug("Daemon stopping")
+			s.setNextRun(time.Time{})
+			return
+		}
+	}
+}
+
+func (s *Scheduled) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.running {
+		return
+	}
+	close(s.stopChan)
+	s.running = false
+}
+
+// Done returns a channel closed once the run loop has exited
+func (s *Scheduled) Done() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.doneChan == nil {
+		return closedChan
+	}
+	return s.doneChan
+}
+
+func (s *Scheduled) IsRunning() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+// NextRun returns when the next scheduled task execution is due (zero if not scheduled)
+func (s *Scheduled) NextRun() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nextRun
+}
+
+func (s *Scheduled) Trigger(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.running {
+		s.logger.Debug("Trigger ignored, daemon not running")
+		return
+	}
+	select {
+	case s.triggers <- ctx:
+	default:
+		s.logger.Debug("Trigger ignored, a triggered run is already queued")
+	}
+}
+
+func (s *Scheduled) SetInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interval = interval
+	if !s.running {
+		return
+	}
+	select {
+	case s.reset <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduled) Pause() {
+	s.mu.Lock()
+	s.paused = true
+	s.mu.Unlock()
+}
+
+func (s *Scheduled) Resume() {
+	s.mu.Lock()
+	s.paused = false
+	s.mu.Unlock()
+}
+
+func (s *Scheduled) IsPaused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused
+}
+
+func (s *Scheduled) currentInterval() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.interval
+}
+
+func (s *Scheduled) setNextRun(t time.Time) {
+	s.mu.Lock()
+	s.nextRun = t
+	s.mu.Unlock()
+}
@@ -8,14 +8,20 @@ import (
 	"zeroplex/pkg/log"
 	"zeroplex/pkg/utils"
 
+	"context"
 	"fmt"
 	"math"
 	"net"
 	"os"
+	"runtime"
+	"strconv"
 	"strings"
+
+	"github.com/vishvananda/netlink"
 )
 
 type SavedDNS struct {
+	Index  int
 	DNS    []string
 	Search []string
 }
@@ -27,11 +33,29 @@ var changedInterfaces = make(map[string]struct{})
 
 // MarkInterfaceChanged records that an interface's DNS was changed by this tool
 func MarkInterfaceChanged(interfaceName string) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
 	changedInterfaces[interfaceName] = struct{}{}
 }
 
+// RenameInterface moves the saved DNS state of an interface renamed from old to new
+func RenameInterface(old, new string) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	if saved, ok := savedDNSState[old]; ok {
+		savedDNSState[new] = saved
+		delete(savedDNSState, old)
+	}
+	if _, ok := changedInterfaces[old]; ok {
+		changedInterfaces[new] = struct{}{}
+		delete(changedInterfaces, old)
+	}
+}
+
 // GetChangedInterfaces returns a list of interfaces changed by this tool
 func GetChangedInterfaces() []string {
+	stateMu.Lock()
+	defer stateMu.Unlock()
 	keys := make([]string, 0, len(changedInterfaces))
 	for k := range changedInterfaces {
 		keys = append(keys, k)
@@ -39,45 +63,115 @@ func GetChangedInterfaces() []string {
 	return keys
 }
 
-// SaveCurrentDNSIfNeeded saves the current DNS/search domains for an interface if not already saved
-func SaveCurrentDNSIfNeeded(interfaceName string, logLevel string) {
-	if _, exists := savedDNSState[interfaceName]; exists {
+// LinkIndex resolves an interface name to its kernel ifindex via netlink, or through the standard
+// library where netlink isn't available
+func LinkIndex(interfaceName string) (int, error) {
+	if runtime.GOOS != "linux" {
+		iface, err := net.InterfaceByName(interfaceName)
+		if err != nil {
+			return 0, fmt.Errorf("failed to look up link %s: %w", interfaceName, err)
+		}
+		return iface.Index, nil
+	}
+	link, err := netlink.LinkByName(interfaceName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up link %s: %w", interfaceName, err)
+	}
+	return link.Attrs().Index, nil
+}
+
+// linkArg returns the ifindex of an interface formatted for use as a resolvectl link argument
+func linkArg(index int) string {
+	return strconv.Itoa(index)
+}
+
+// CurrentDNS returns the DNS servers and domains systemd-resolved currently holds for a link
+func CurrentDNS(index int) (servers, domains []string, err error) {
+	output, err := utils.ExecuteCommand("resolvectl", "dns", linkArg(index))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query DNS for ifindex %d: %w", index, err)
+	}
+	for _, value := range utils.ParseResolvectlOutput(output, "Link ") {
+		servers = append(servers, strings.Fields(value)...)
+	}
+	output, err = utils.ExecuteCommand("resolvectl", "domain", linkArg(index))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query domains for ifindex %d: %w", index, err)
+	}
+	for _, value := range utils.ParseResolvectlOutput(output, "Link ") {
+		domains = append(domains, strings.Fields(value)...)
+	}
+	return servers, domains, nil
+}
+
+// SaveCurrentDNSIfNeeded saves the current DNS/search domains for an interface if not already saved.
+// The caller holds LockInterface(interfaceName).
+func SaveCurrentDNSIfNeeded(ctx context.Context, interfaceName string, logLevel string) {
+	stateMu.Lock()
+	_, exists := savedDNSState[interfaceName]
+	stateMu.Unlock()
+	if exists {
 		return
 	}
-	logger := log.NewScopedLogger("[dns]", logLevel)
-	output, err := utils.ExecuteCommand("resolvectl", "dns", interfaceName)
+	logger := log.NewScopedLogger("[dns]", logLevel).WithContext(ctx)
+	index, err := LinkIndex(interfaceName)
+	if err != nil {
+		logger.Warn("Could not save original DNS for %s: %v", interfaceName, err)
+		return
+	}
+	output, err := utils.ExecuteCommand("resolvectl", "dns", linkArg(index))
 	if err != nil {
 		logger.Warn("Could not save original DNS for %s: %v", interfaceName, err)
 		return
 	}
 	currentDNS := utils.ParseResolvectlOutput(output, "Link ")
-	output, err = utils.ExecuteCommand("resolvectl", "domain", interfaceName)
+	output, err = utils.ExecuteCommand("resolvectl", "domain", linkArg(index))
 	if err != nil {
 		logger.Warn("Could not save original search domains for %s: %v", interfaceName, err)
 		return
 	}
 	currentDomains := utils.ParseResolvectlOutput(output, "Link ")
-	savedDNSState[interfaceName] = SavedDNS{DNS: currentDNS, Search: currentDomains}
-	logger.Debug("Saved original DNS/search domains for %s: DNS=%v, Search=%v", interfaceName, currentDNS, currentDomains)
+	stateMu.Lock()
+	savedDNSState[interfaceName] = SavedDNS{Index: index, DNS: currentDNS, Search: currentDomains}
+	stateMu.Unlock()
+	logger.Debug("Saved original DNS/search domains for %s (ifindex %d): DNS=%v, Search=%v", interfaceName, index, currentDNS, currentDomains)
 }
 
 // RestoreSavedDNS restores the saved DNS/search domains for an interface, if present
 // Returns true if a restore was performed, false otherwise
-func RestoreSavedDNS(interfaceName string, logLevel string) bool {
+func RestoreSavedDNS(ctx context.Context, interfaceName string, logLevel string) bool {
+	defer LockInterface(interfaceName)()
+	stateMu.Lock()
 	saved, exists := savedDNSState[interfaceName]
-	logger := log.NewScopedLogger("[dns]", logLevel)
+	_, changed := changedInterfaces[interfaceName]
+	stateMu.Unlock()
+	logger := log.NewScopedLogger("[dns]", logLevel).WithContext(ctx)
 	if !exists {
 		logger.Verbose("No saved DNS state for %s, nothing to restore (interface may have disappeared)", interfaceName)
 		return false
 	}
-	if _, changed := changedInterfaces[interfaceName]; !changed {
+	if !changed {
 		logger.Verbose("Interface %s was not changed by this tool, skipping restore", interfaceName)
 		return false
 	}
 	logger.Info("Restoring original DNS/search domains for %s: DNS=%v, Search=%v", interfaceName, saved.DNS, saved.Search)
 
+	// Revert by the ifindex recorded at save time so a rename in between doesn't matter
+	link, err := netlink.LinkByIndex(saved.Index)
+	if err != nil {
+		logger.Warn("Interface %s (ifindex %d) is gone while reverting; skipping restore.", interfaceName, saved.Index)
+		stateMu.Lock()
+		delete(savedDNSState, interfaceName)
+		delete(changedInterfaces, interfaceName)
+		stateMu.Unlock()
+		return false
+	}
+	if current := link.Attrs().Name; current != interfaceName {
+		logger.Verbose("Interface %s (ifindex %d) is now named %s, reverting by index", interfaceName, saved.Index, current)
+	}
+
 	// Use resolvectl revert for robust cleanup
-	_, err := utils.ExecuteCommand("resolvectl", "revert", interfaceName)
+	_, err = utils.ExecuteCommand("resolvectl", "revert", linkArg(saved.Index))
 	if err != nil {
 		if strings.Contains(err.Error(), "No such device") {
 			logger.Warn("Interface %s is gone (No such device) while reverting; skipping restore.", interfaceName)
@@ -90,8 +184,23 @@ func RestoreSavedDNS(interfaceName string, logLevel string) bool {
 	return true
 }
 
+// RevertLink reverts the link with ifindex index, which has no saved DNS state in this process, with
+// 'resolvectl revert'
+func RevertLink(ctx context.Context, interfaceName string, index int, logLevel string) bool {
+	defer LockInterface(interfaceName)()
+	logger := log.NewScopedLogger("[dns]", logLevel).WithContext(ctx)
+	if _, err := utils.ExecuteCommand("resolvectl", "revert", linkArg(index)); err != nil {
+		logger.Warn("Failed to revert DNS settings for %s: %v", interfaceName, err)
+		return false
+	}
+	logger.Info("Reverted all temporary DNS settings for %s using 'resolvectl revert'", interfaceName)
+	return true
+}
+
 // GetSavedDNSState returns a copy of the saved DNS state map (interface names only)
 func GetSavedDNSState() map[string]SavedDNS {
+	stateMu.Lock()
+	defer stateMu.Unlock()
 	copy := make(map[string]SavedDNS)
 	for k, v := range savedDNSState {
 		copy[k] = v
@@ -146,6 +255,49 @@ func CalculateReverseDomains(assignedAddresses *[]string) []string {
 	return reverseDomains
 }
 
+// DNSSECEnabled reports whether systemd-resolved validates DNSSEC for a link (ifindex or name),
+// falling back to the global setting when the link has none of its own
+func DNSSECEnabled(link string) bool {
+	setting := ""
+	if out, err := utils.ExecuteCommand("resolvectl", "dnssec", link); err == nil {
+		if values := utils.ParseResolvectlOutput(out, "Link "); len(values) > 0 {
+			setting = values[0]
+		}
+	}
+	if setting == "" {
+		if out, err := utils.ExecuteCommand("resolvectl", "dnssec"); err == nil {
+			if values := utils.ParseResolvectlOutput(out, "Global"); len(values) > 0 {
+				setting = values[0]
+			}
+		}
+	}
+	setting = strings.ToLower(strings.TrimSpace(setting))
+	return setting != "" && setting != "no" && setting != "false"
+}
+
+// NegativeTrustAnchors returns the per-link DNSSEC negative trust anchors of a link
+func NegativeTrustAnchors(link string) ([]string, error) {
+	out, err := utils.ExecuteCommand("resolvectl", "nta", link)
+	if err != nil {
+		return nil, err
+	}
+	var anchors []string
+	for _, value := range utils.ParseResolvectlOutput(out, "Link ") {
+		anchors = append(anchors, strings.Fields(value)...)
+	}
+	return anchors, nil
+}
+
+// SetNegativeTrustAnchors replaces the per-link negative trust anchors; an empty list clears them
+func SetNegativeTrustAnchors(link string, anchors []string) error {
+	args := []string{"nta", link}
+	if len(anchors) == 0 {
+		args = append(args, "")
+	}
+	_, err := utils.ExecuteCommand("resolvectl", append(args, anchors...)...)
+	return err
+}
+
 func CompareDNS(current, desired []string) bool {
 	if len(current) != len(desired) {
 		return false
@@ -162,45 +314,65 @@ func CompareDNS(current, desired []string) bool {
 	return true
 }
 
-// Accept logLevel as a parameter
-func ConfigureDNSAndSearchDomains(interfaceName string, dnsServers, searchKeys []string, dryRun bool, logLevel string) {
-	logger := log.NewScopedLogger("[dns]", logLevel)
+// ConfigureDNSAndSearchDomains sets the DNS servers and search domains of an interface through
+// systemd-resolved when they differ. A failure is recorded for LinkFailures until an attempt
+// succeeds. The caller holds LockInterface(interfaceName).
+func ConfigureDNSAndSearchDomains(ctx context.Context, interfaceName string, dnsServers, searchKeys []string, dryRun bool, logLevel string) error {
+	if dryRun {
+		log.NewScopedLogger("[dns]", logLevel).WithContext(ctx).Info("Would set Interface: %s Search Domain: %s and DNS: %s", interfaceName, strings.Join(searchKeys, ", "), strings.Join(dnsServers, ", "))
+		return nil
+	}
+	err := configureDNSAndSearchDomains(ctx, interfaceName, dnsServers, searchKeys, logLevel)
+	if err != nil {
+		recordLinkFailure(interfaceName, dnsServers, searchKeys, err)
+		return err
+	}
+	ForgetLinkFailure(interfaceName)
+	return nil
+}
+
+func configureDNSAndSearchDomains(ctx context.Context, interfaceName string, dnsServers, searchKeys []string, logLevel string) error {
+	logger := log.NewScopedLogger("[dns]", logLevel).WithContext(ctx)
 	logger.Trace("ConfigureDNSAndSearchDomains() started for interface: %s", interfaceName)
 	logger.Debug("Configuring DNS for interface: %s", interfaceName)
 
-	if dryRun {
-		logger.Info("Would set Interface: %s Search Domain: %s and DNS: %s", interfaceName, strings.Join(searchKeys, ", "), strings.Join(dnsServers, ", "))
-		return
-	}
+	SaveCurrentDNSIfNeeded(ctx, interfaceName, logLevel)
 
-	SaveCurrentDNSIfNeeded(interfaceName, logLevel)
+	// Resolve the ifindex once so every resolvectl call in this apply targets the same link
+	index, err := LinkIndex(interfaceName)
+	if err != nil {
+		logger.Error("Failed to resolve ifindex for interface %s: %v", interfaceName, err)
+		return err
+	}
+	link := linkArg(index)
+	logger.Trace("Interface %s resolved to ifindex %d", interfaceName, index)
 
 	logger.Debug("Querying current DNS configuration via resolvectl")
-	logger.Trace("Executing command: resolvectl dns %s", interfaceName)
-	output, err := utils.ExecuteCommand("resolvectl", "dns", interfaceName)
-	logger.Trace("Command: resolvectl dns %s", interfaceName)
+	logger.Trace("Executing command: resolvectl dns %s", link)
+	output, err := utils.ExecuteCommand("resolvectl", "dns", link)
+	logger.Trace("Command: resolvectl dns %s", link)
 	logger.Trace("Command output: %s", output)
 	if err != nil {
 		logger.Error("Failed to query DNS via resolvectl for interface %s: %v", interfaceName, err)
 		logger.Trace("Command output: %s", output)
 		fmt.Fprintf(os.Stderr, "Could not query DNS for interface %s. Please ensure the interface exists and resolvectl is configured correctly.\n", interfaceName)
-		return
+		return fmt.Errorf("failed to query DNS for %s: %w", interfaceName, err)
 	}
-	logger.Trace("Command succeeded: resolvectl dns %s", interfaceName)
+	logger.Trace("Command succeeded: resolvectl dns %s", link)
 	logger.Trace("Command output length: %d characters", len(output))
 	currentDNS := utils.ParseResolvectlOutput(output, "Link ")
 	logger.Debug("Current systemd-resolved DNS for interface %s: %v", interfaceName, currentDNS)
 
 	logger.Debug("Querying current search domains via resolvectl")
-	logger.Trace("Executing command: resolvectl domain %s", interfaceName)
-	output, err = utils.ExecuteCommand("resolvectl", "domain", interfaceName)
-	logger.Trace("Command: resolvectl domain %s", interfaceName)
+	logger.Trace("Executing command: resolvectl domain %s", link)
+	output, err = utils.ExecuteCommand("resolvectl", "domain", link)
+	logger.Trace("Command: resolvectl domain %s", link)
 	logger.Trace("Command output: %s", output)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to query search domains via resolvectl for interface %s: %v\n", interfaceName, err)
-		return
+		return fmt.Errorf("failed to query search domains for %s: %w", interfaceName, err)
 	}
-	logger.Trace("Command succeeded: resolvectl domain %s", interfaceName)
+	logger.Trace("Command succeeded: resolvectl domain %s", link)
 	logger.Trace("Command output length: %d characters", len(output))
 	currentDomains := utils.ParseResolvectlOutput(output, "Link ")
 	logger.Debug("Current systemd-resolved search domains for interface %s: %v", interfaceName, currentDomains)
@@ -218,54 +390,58 @@ func ConfigureDNSAndSearchDomains(interfaceName string, dnsServers, searchKeys [
 
 	if sameDNS && sameDomains {
 		logger.Verbose("No changes needed for interface %s; DNS and search domains are already up-to-date", interfaceName)
-		return
+		return nil
 	}
 
 	logger.Info("DNS configuration changes needed for interface %s", interfaceName)
-	// Configure DNS and domains using resolvectl
-	configureViaDbus(interfaceName, dnsServers, searchKeys)
+	// Configure DNS and domains over D-Bus or with resolvectl
+	if err := configureViaDbus(interfaceName, index, dnsServers, searchKeys); err != nil {
+		return err
+	}
 	// Mark as changed only if we actually updated
 	MarkInterfaceChanged(interfaceName)
+	return nil
 }
 
-func configureViaDbus(interfaceName string, dnsServers, searchKeys []string) {
-	// Import dbus here to keep it contained to this function
-	conn, err := net.Dial("unix", "/run/systemd/resolve/io.systemd.Resolve")
-	if err != nil {
-		// Fallback to using resolvectl commands
-		configureViaResolvectl(interfaceName, dnsServers, searchKeys)
-		return
+// configureViaDbus sets the link DNS over D-Bus when the resolved_dbus experimental feature is on,
+// and with resolvectl otherwise or if that fails
+func configureViaDbus(interfaceName string, index int, dnsServers, searchKeys []string) error {
+	if resolvedDBus.Load() {
+		err := setLinkOverDBus(index, dnsServers, searchKeys)
+		if err == nil {
+			log.NewScopedLogger("[dns]", "").Verbose("Configured for Interface: %s DNS: %s Search Domain: %s (D-Bus)", interfaceName, strings.Join(dnsServers, ", "), strings.Join(searchKeys, ", "))
+			return nil
+		}
+		log.NewScopedLogger("[dns]", "").Warn("Could not configure %s over D-Bus, falling back to resolvectl: %v", interfaceName, err)
 	}
-	defer conn.Close()
-
-	// For now, use resolvectl as fallback until we implement full D-Bus
-	configureViaResolvectl(interfaceName, dnsServers, searchKeys)
+	return configureViaResolvectl(interfaceName, index, dnsServers, searchKeys)
 }
 
-func configureViaResolvectl(interfaceName string, dnsServers, searchKeys []string) {
+func configureViaResolvectl(interfaceName string, index int, dnsServers, searchKeys []string) error {
 	// Set DNS servers
 	if len(dnsServers) > 0 {
-		args := append([]string{"dns", interfaceName}, dnsServers...)
+		args := append([]string{"dns", linkArg(index)}, dnsServers...)
 		_, err := utils.ExecuteCommand("resolvectl", args...)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to set DNS servers for %s: %v\n", interfaceName, err)
-			return
+			return fmt.Errorf("failed to set DNS servers for %s: %w", interfaceName, err)
 		}
 	}
 
 	// Set search domains
 	if len(searchKeys) > 0 {
-		args := append([]string{"domain", interfaceName}, searchKeys...)
+		args := append([]string{"domain", linkArg(index)}, searchKeys...)
 		_, err := utils.ExecuteCommand("resolvectl", args...)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to set search domains for %s: %v\n", interfaceName, err)
-			return
+			return fmt.Errorf("failed to set search domains for %s: %w", interfaceName, err)
 		}
 	}
 
 	if len(searchKeys) > 0 {
-		log.NewScopedLogger("[dns]", "info").Info("Configured for Interface: %s DNS: %s Search Domain: %s", interfaceName, strings.Join(dnsServers, ", "), strings.Join(searchKeys, ", "))
+		log.NewScopedLogger("[dns]", "").Verbose("Configured for Interface: %s DNS: %s Search Domain: %s", interfaceName, strings.Join(dnsServers, ", "), strings.Join(searchKeys, ", "))
 	} else {
-		log.NewScopedLogger("[dns]", "info").Info("Configured for Interface: %s DNS: %s", interfaceName, strings.Join(dnsServers, ", "))
+		log.NewScopedLogger("[dns]", "").Verbose("Configured for Interface: %s DNS: %s", interfaceName, strings.Join(dnsServers, ", "))
 	}
+	return nil
 }
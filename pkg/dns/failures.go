@@ -0,0 +1,84 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package dns
+
+import (
+	"zeroplex/pkg/metrics"
+
+	"sort"
+	"sync"
+	"time"
+)
+
+// LinkFailure is an interface whose DNS could not be applied, with what was to be applied, so it
+// can be retried on its own rather than at the next poll
+type LinkFailure struct {
+	Interface string
+	DNS       []string
+	Domains   []string
+	Failures  int       // consecutive failed attempts
+	LastError string    // error of the last attempt
+	Since     time.Time // when the first of the consecutive failures happened
+}
+
+var (
+	failuresMu   sync.Mutex
+	linkFailures = map[string]*LinkFailure{}
+)
+
+// recordLinkFailure counts a failed attempt to apply DNS to an interface
+func recordLinkFailure(interfaceName string, dnsServers, searchKeys []string, err error) {
+	failuresMu.Lock()
+	failure, ok := linkFailures[interfaceName]
+	if !ok {
+		failure = &LinkFailure{Interface: interfaceName, Since: time.Now()}
+		linkFailures[interfaceName] = failure
+	}
+	failure.DNS = append([]string(nil), dnsServers...)
+	failure.Domains = append([]string(nil), searchKeys...)
+	failure.Failures++
+	failure.LastError = err.Error()
+	failures := failure.Failures
+	failuresMu.Unlock()
+
+	labels := metrics.Labels{"interface": interfaceName}
+	metrics.Inc("zeroplex_link_apply_failures_total", "Failed attempts to apply DNS to an interface", labels)
+	metrics.Set("zeroplex_link_apply_consecutive_failures", "Failed attempts to apply DNS to an interface since it last succeeded", float64(failures), labels)
+}
+
+// ForgetLinkFailure forgets the failures of an interface, once its DNS is applied or it is no longer
+// managed
+func ForgetLinkFailure(interfaceName string) {
+	failuresMu.Lock()
+	_, ok := linkFailures[interfaceName]
+	delete(linkFailures, interfaceName)
+	failuresMu.Unlock()
+	if ok {
+		metrics.Set("zeroplex_link_apply_consecutive_failures", "Failed attempts to apply DNS to an interface since it last succeeded", 0, metrics.Labels{"interface": interfaceName})
+	}
+}
+
+// LinkFailures returns the interfaces whose last attempt to apply DNS failed, sorted by name
+func LinkFailures() []LinkFailure {
+	failuresMu.Lock()
+	defer failuresMu.Unlock()
+	failures := make([]LinkFailure, 0, len(linkFailures))
+	for _, failure := range linkFailures {
+		failures = append(failures, *failure)
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].Interface < failures[j].Interface })
+	return failures
+}
+
+// LinkFailureOf returns the failure of an interface, if its last attempt to apply DNS failed
+func LinkFailureOf(interfaceName string) (LinkFailure, bool) {
+	failuresMu.Lock()
+	defer failuresMu.Unlock()
+	failure, ok := linkFailures[interfaceName]
+	if !ok {
+		return LinkFailure{}, false
+	}
+	return *failure, true
+}
@@ -0,0 +1,33 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package dns
+
+import (
+	"sync"
+)
+
+var (
+	linkLocksMu sync.Mutex
+	linkLocks   = map[string]*sync.Mutex{}
+
+	// stateMu guards savedDNSState and changedInterfaces, which runs, restores and renames share
+	stateMu sync.Mutex
+)
+
+// LockInterface serializes DNS operations on an interface and returns the function releasing it.
+// An apply holds it for its whole resolvectl sequence, so a restore triggered meanwhile (a removal
+// event racing a poll tick, a restore over the control socket) runs before or after it, never in
+// between, and can't leave the link half configured.
+func LockInterface(interfaceName string) (unlock func()) {
+	linkLocksMu.Lock()
+	mu, ok := linkLocks[interfaceName]
+	if !ok {
+		mu = &sync.Mutex{}
+		linkLocks[interfaceName] = mu
+	}
+	linkLocksMu.Unlock()
+	mu.Lock()
+	return mu.Unlock
+}
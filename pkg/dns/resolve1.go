@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package dns
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// The systemd-resolved D-Bus API used by the resolved_dbus experimental feature
+const (
+	resolve1Name    = "org.freedesktop.resolve1"
+	resolve1Path    = dbus.ObjectPath("/org/freedesktop/resolve1")
+	resolve1Manager = "org.freedesktop.resolve1.Manager"
+)
+
+// resolvedDBus is set while the resolved_dbus experimental feature is turned on
+var resolvedDBus atomic.Bool
+
+// SetResolvedDBus makes ConfigureDNSAndSearchDomains set link DNS through the systemd-resolved
+// D-Bus API rather than resolvectl, falling back to resolvectl if the call fails
+func SetResolvedDBus(enabled bool) {
+	resolvedDBus.Store(enabled)
+}
+
+// resolve1Address is an address as SetLinkDNS takes it: the address family and its bytes
+type resolve1Address struct {
+	Family  int32
+	Address []byte
+}
+
+// resolve1Domain is a domain as SetLinkDomains takes it; a routing-only domain is one given to
+// resolvectl with a ~ prefix
+type resolve1Domain struct {
+	Domain      string
+	RoutingOnly bool
+}
+
+// setLinkOverDBus sets the DNS servers and search domains of the link with ifindex index with the
+// SetLinkDNS and SetLinkDomains methods of systemd-resolved
+func setLinkOverDBus(index int, dnsServers, searchKeys []string) error {
+	var addresses []resolve1Address
+	for _, server := range dnsServers {
+		ip := net.ParseIP(server)
+		if ip == nil {
+			return fmt.Errorf("invalid DNS server address %q", server)
+		}
+		if v4 := ip.To4(); v4 != nil {
+			addresses = append(addresses, resolve1Address{Family: 2, Address: v4}) // AF_INET
+		} else {
+			addresses = append(addresses, resolve1Address{Family: 10, Address: ip.To16()}) // AF_INET6
+		}
+	}
+	var domains []resolve1Domain
+	for _, key := range searchKeys {
+		domain, routingOnly := strings.CutPrefix(key, "~")
+		domains = append(domains, resolve1Domain{Domain: domain, RoutingOnly: routingOnly})
+	}
+
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the system bus: %w", err)
+	}
+	defer conn.Close()
+	manager := conn.Object(resolve1Name, resolve1Path)
+	if len(addresses) > 0 {
+		if err := manager.Call(resolve1Manager+".SetLinkDNS", 0, int32(index), addresses).Err; err != nil {
+			return fmt.Errorf("SetLinkDNS: %w", err)
+		}
+	}
+	if len(domains) > 0 {
+		if err := manager.Call(resolve1Manager+".SetLinkDomains", 0, int32(index), domains).Err; err != nil {
+			return fmt.Errorf("SetLinkDomains: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,424 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package docs
+
+import (
+	"zeroplex/pkg/config"
+
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Shells are the shells Completion writes scripts for
+var Shells = []string{"bash", "zsh", "fish"}
+
+// FlagValues lists the values of the global flags that take one of a fixed set
+var FlagValues = map[string][]string{
+	"mode":                 config.Modes,
+	"log-level":            config.LogLevels,
+	"log-type":             {"console", "file", "both"},
+	"interface-watch-mode": config.InterfaceWatchModes,
+}
+
+// pathFlags are the global flags that take a file, or with "dir" a directory
+var pathFlags = map[string]string{
+	"config-file": "file", "config": "file", "c": "file", "config-dir": "dir",
+	"decryption-key-file": "file", "log-file": "file", "token-file": "file",
+}
+
+// completionFlag is a flag as offered by the completion scripts
+type completionFlag struct {
+	name        string
+	description string
+	takesValue  bool
+	values      []string
+	path        string // "file" or "dir" when the value is a path
+}
+
+// option returns the flag as typed, with one dash for single-letter names
+func (f completionFlag) option() string {
+	if len(f.name) == 1 {
+		return "-" + f.name
+	}
+	return "--" + f.name
+}
+
+// completionCommand is a command, with the subcommands and flags given after it
+type completionCommand struct {
+	name        string
+	description string
+	subcommands []Entry
+	flags       []completionFlag
+}
+
+// commandFlagPattern matches the flags in a command's usage, or in parentheses in its description,
+// with what they take: "--format table|json", "--interface NAME" or "--actions"
+var commandFlagPattern = regexp.MustCompile(`--([a-z][a-z-]*)(?: ([A-Za-z][A-Za-z-]*(?:\|[a-z-]+)*))?`)
+
+// parenthesesPattern matches the parenthesized parts of a description
+var parenthesesPattern = regexp.MustCompile(`\([^)]*\)`)
+
+// globalFlags returns every registered flag, described by Options where it is documented
+func globalFlags() []completionFlag {
+	descriptions := map[string]string{}
+	for _, section := range Options {
+		for _, opt := range section.Entries {
+			descriptions[opt.Name] = strings.ReplaceAll(opt.Description, "*", "")
+		}
+	}
+	var flags []completionFlag
+	flag.VisitAll(func(f *flag.Flag) {
+		description := descriptions[f.Name]
+		if description == "" {
+			description = f.Usage
+		}
+		isBool := false
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok {
+			isBool = b.IsBoolFlag()
+		}
+		flags = append(flags, completionFlag{
+			name: f.Name, description: description, takesValue: !isBool,
+			values: FlagValues[f.Name], path: pathFlags[f.Name],
+		})
+	})
+	return flags
+}
+
+// completionCommands turns Commands into the commands the scripts complete. Entries sharing their
+// first word ("state show", "state forget") become subcommands of one command, and alternatives
+// ("pause|resume", "docs man|help-all") are split.
+func completionCommands() []completionCommand {
+	var commands []completionCommand
+	index := map[string]int{}
+	for _, entry := range Commands {
+		fields := strings.Fields(entry.Name)
+		var subcommands []string
+		if len(fields) > 1 && !strings.HasPrefix(fields[1], "[") {
+			subcommands = strings.Split(fields[1], "|")
+		}
+		var flags []completionFlag
+		usage := entry.Name + " " + strings.Join(parenthesesPattern.FindAllString(entry.Description, -1), " ")
+		for _, match := range commandFlagPattern.FindAllStringSubmatch(usage, -1) {
+			f := completionFlag{name: match[1]}
+			switch arg := match[2]; {
+			case arg == "FILE":
+				f.takesValue, f.path = true, "file"
+			case arg != "" && strings.ToUpper(arg) == arg:
+				f.takesValue = true
+			case arg != "":
+				f.takesValue, f.values = true, strings.Split(arg, "|")
+			}
+			flags = append(flags, f)
+		}
+
+		for _, name := range strings.Split(fields[0], "|") {
+			i, ok := index[name]
+			if !ok {
+				index[name] = len(commands)
+				i = len(commands)
+				commands = append(commands, completionCommand{name: name, description: entry.Description})
+			} else {
+				commands[i].description = strings.Join(commandNames(commands[i].subcommands, subcommands), ", ")
+			}
+			for _, sub := range subcommands {
+				commands[i].subcommands = append(commands[i].subcommands, Entry{sub, entry.Description})
+			}
+			commands[i].flags = mergeFlags(commands[i].flags, flags)
+		}
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].name < commands[j].name })
+	return commands
+}
+
+// commandNames lists the names of existing and added subcommands, to describe a command that only
+// groups them
+func commandNames(existing []Entry, added []string) []string {
+	var names []string
+	for _, sub := range existing {
+		names = append(names, sub.Name)
+	}
+	return append(names, added...)
+}
+
+// mergeFlags adds the flags not already in flags
+func mergeFlags(flags, added []completionFlag) []completionFlag {
+	for _, f := range added {
+		known := false
+		for i, existing := range flags {
+			if existing.name == f.name {
+				known = true
+				flags[i].values = append(flags[i].values, f.values...)
+			}
+		}
+		if !known {
+			flags = append(flags, f)
+		}
+	}
+	return flags
+}
+
+// Completion writes the completion script of shell, one of Shells
+func Completion(w io.Writer, shell string) error {
+	switch shell {
+	case "bash":
+		bashCompletion(w, globalFlags(), completionCommands())
+	case "zsh":
+		zshCompletion(w, globalFlags(), completionCommands())
+	case "fish":
+		fishCompletion(w, globalFlags(), completionCommands())
+	default:
+		return fmt.Errorf("unknown shell %q (expected %s)", shell, strings.Join(Shells, ", "))
+	}
+	return nil
+}
+
+// bashValueCases writes the case branches completing the value of flags
+func bashValueCases(w io.Writer, flags []completionFlag, indent string) {
+	for _, f := range flags {
+		if !f.takesValue {
+			continue
+		}
+		pattern := f.option()
+		if len(f.name) > 1 {
+			pattern = "-" + f.name + "|--" + f.name
+		}
+		switch {
+		case len(f.values) > 0:
+			fmt.Fprintf(w, "%s%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", indent, pattern, strings.Join(f.values, " "))
+		case f.path == "dir":
+			fmt.Fprintf(w, "%s%s) COMPREPLY=($(compgen -d -- \"$cur\")); return ;;\n", indent, pattern)
+		case f.path == "file":
+			fmt.Fprintf(w, "%s%s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", indent, pattern)
+		default:
+			fmt.Fprintf(w, "%s%s) return ;;\n", indent, pattern)
+		}
+	}
+}
+
+func bashCompletion(w io.Writer, global []completionFlag, commands []completionCommand) {
+	var options, valued, names []string
+	for _, f := range global {
+		options = append(options, f.option())
+		if f.takesValue {
+			valued = append(valued, "-"+f.name, "--"+f.name)
+		}
+	}
+	for _, cmd := range commands {
+		names = append(names, cmd.name)
+	}
+
+	fmt.Fprintf(w, "# bash completion for zeroplex, generated by `zeroplex completion bash`\n\n")
+	fmt.Fprintf(w, "_zeroplex() {\n")
+	fmt.Fprintf(w, "    local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	fmt.Fprintf(w, "    local valued=\" %s \"\n", strings.Join(valued, " "))
+	fmt.Fprintf(w, "    local i word cmd=\"\" sub=\"\"\n")
+	fmt.Fprintf(w, "    # Global options come before the command, and some take the next word as their value\n")
+	fmt.Fprintf(w, "    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+	fmt.Fprintf(w, "        word=\"${COMP_WORDS[i]}\"\n")
+	fmt.Fprintf(w, "        if [[ \"$word\" == -* ]]; then\n")
+	fmt.Fprintf(w, "            [[ -z \"$cmd\" && \"$valued\" == *\" $word \"* ]] && ((i++))\n")
+	fmt.Fprintf(w, "        elif [[ -z \"$cmd\" ]]; then\n")
+	fmt.Fprintf(w, "            cmd=\"$word\"\n")
+	fmt.Fprintf(w, "        elif [[ -z \"$sub\" ]]; then\n")
+	fmt.Fprintf(w, "            sub=\"$word\"\n")
+	fmt.Fprintf(w, "        fi\n")
+	fmt.Fprintf(w, "    done\n\n")
+
+	fmt.Fprintf(w, "    if [[ -z \"$cmd\" ]]; then\n")
+	fmt.Fprintf(w, "        case \"$prev\" in\n")
+	bashValueCases(w, global, "            ")
+	fmt.Fprintf(w, "        esac\n")
+	fmt.Fprintf(w, "        if [[ \"$cur\" == -* ]]; then\n")
+	fmt.Fprintf(w, "            COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(options, " "))
+	fmt.Fprintf(w, "        else\n")
+	fmt.Fprintf(w, "            COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(names, " "))
+	fmt.Fprintf(w, "        fi\n")
+	fmt.Fprintf(w, "        return\n")
+	fmt.Fprintf(w, "    fi\n\n")
+
+	fmt.Fprintf(w, "    case \"$cmd\" in\n")
+	for _, cmd := range commands {
+		if len(cmd.subcommands) == 0 && len(cmd.flags) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "        %s)\n", cmd.name)
+		var words []string
+		valued := false
+		for _, f := range cmd.flags {
+			words = append(words, f.option())
+			valued = valued || f.takesValue
+		}
+		if valued {
+			fmt.Fprintf(w, "            case \"$prev\" in\n")
+			bashValueCases(w, cmd.flags, "                ")
+			fmt.Fprintf(w, "            esac\n")
+		}
+		if len(cmd.subcommands) > 0 {
+			var subs []string
+			for _, sub := range cmd.subcommands {
+				subs = append(subs, sub.Name)
+			}
+			fmt.Fprintf(w, "            if [[ -z \"$sub\" && \"$cur\" != -* ]]; then\n")
+			fmt.Fprintf(w, "                COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(subs, " "))
+			fmt.Fprintf(w, "                return\n")
+			fmt.Fprintf(w, "            fi\n")
+		}
+		if len(words) > 0 {
+			fmt.Fprintf(w, "            COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(words, " "))
+		}
+		fmt.Fprintf(w, "            ;;\n")
+	}
+	fmt.Fprintf(w, "    esac\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "complete -F _zeroplex zeroplex\n")
+}
+
+// zshQuote escapes s for a single-quoted _arguments spec or _describe item
+func zshQuote(s string) string {
+	return strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+}
+
+// zshSpec returns the _arguments spec of a flag
+func zshSpec(f completionFlag) string {
+	spec := f.option()
+	if f.description != "" {
+		spec += "[" + zshQuote(f.description) + "]"
+	}
+	switch {
+	case !f.takesValue:
+	case len(f.values) > 0:
+		spec += ":" + f.name + ":(" + strings.Join(f.values, " ") + ")"
+	case f.path == "dir":
+		spec += ":" + f.name + ":_directories"
+	case f.path == "file":
+		spec += ":" + f.name + ":_files"
+	default:
+		spec += ":" + f.name + ": "
+	}
+	return "'" + spec + "'"
+}
+
+func zshCompletion(w io.Writer, global []completionFlag, commands []completionCommand) {
+	fmt.Fprintf(w, "#compdef zeroplex\n")
+	fmt.Fprintf(w, "# zsh completion for zeroplex, generated by `zeroplex completion zsh`\n\n")
+	fmt.Fprintf(w, "_zeroplex() {\n")
+	fmt.Fprintf(w, "  local curcontext=\"$curcontext\" state line\n")
+	fmt.Fprintf(w, "  local -a commands\n")
+	fmt.Fprintf(w, "  commands=(\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "    '%s:%s'\n", cmd.name, zshQuote(cmd.description))
+	}
+	fmt.Fprintf(w, "  )\n\n")
+	fmt.Fprintf(w, "  _arguments -C \\\n")
+	for _, f := range global {
+		fmt.Fprintf(w, "    %s \\\n", zshSpec(f))
+	}
+	fmt.Fprintf(w, "    '1:command:->command' \\\n")
+	fmt.Fprintf(w, "    '*::argument:->argument'\n\n")
+	fmt.Fprintf(w, "  case $state in\n")
+	fmt.Fprintf(w, "    command)\n")
+	fmt.Fprintf(w, "      _describe -t commands 'zeroplex command' commands\n")
+	fmt.Fprintf(w, "      ;;\n")
+	fmt.Fprintf(w, "    argument)\n")
+	fmt.Fprintf(w, "      case $words[1] in\n")
+	for _, cmd := range commands {
+		if len(cmd.subcommands) == 0 && len(cmd.flags) == 0 {
+			continue
+		}
+		var specs []string
+		for _, f := range cmd.flags {
+			specs = append(specs, zshSpec(f))
+		}
+		fmt.Fprintf(w, "        %s)\n", cmd.name)
+		indent := "          "
+		if len(cmd.subcommands) > 0 {
+			var subs []string
+			for _, sub := range cmd.subcommands {
+				subs = append(subs, "'"+sub.Name+":"+zshQuote(sub.Description)+"'")
+			}
+			fmt.Fprintf(w, "          if (( CURRENT == 2 )); then\n")
+			fmt.Fprintf(w, "            local -a subcommands=(%s)\n", strings.Join(subs, " "))
+			fmt.Fprintf(w, "            _describe -t subcommands 'zeroplex %s command' subcommands\n", cmd.name)
+			if len(specs) > 0 {
+				fmt.Fprintf(w, "          else\n")
+			}
+			indent = "            "
+		}
+		if len(specs) > 0 {
+			fmt.Fprintf(w, "%s_arguments %s\n", indent, strings.Join(specs, " "))
+		}
+		if len(cmd.subcommands) > 0 {
+			fmt.Fprintf(w, "          fi\n")
+		}
+		fmt.Fprintf(w, "          ;;\n")
+	}
+	fmt.Fprintf(w, "      esac\n")
+	fmt.Fprintf(w, "      ;;\n")
+	fmt.Fprintf(w, "  esac\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "_zeroplex \"$@\"\n")
+}
+
+// fishQuote quotes s as a single fish argument
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+// fishFlag writes the complete line of a flag offered when condition holds
+func fishFlag(w io.Writer, f completionFlag, condition string) {
+	option := "-l " + f.name
+	if len(f.name) == 1 {
+		option = "-s " + f.name
+	}
+	line := fmt.Sprintf("complete -c zeroplex -n %s %s", fishQuote(condition), option)
+	switch {
+	case !f.takesValue:
+	case len(f.values) > 0:
+		line += " -x -a " + fishQuote(strings.Join(f.values, " "))
+	case f.path == "dir":
+		line += " -x -a '(__fish_complete_directories)'"
+	case f.path == "file":
+		line += " -r -F"
+	default:
+		line += " -x"
+	}
+	if f.description != "" {
+		line += " -d " + fishQuote(f.description)
+	}
+	fmt.Fprintln(w, line)
+}
+
+func fishCompletion(w io.Writer, global []completionFlag, commands []completionCommand) {
+	fmt.Fprintf(w, "# fish completion for zeroplex, generated by `zeroplex completion fish`\n\n")
+	fmt.Fprintf(w, "complete -c zeroplex -f\n\n")
+	for _, f := range global {
+		fishFlag(w, f, "__fish_use_subcommand")
+	}
+	fmt.Fprintf(w, "\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "complete -c zeroplex -n __fish_use_subcommand -a %s -d %s\n", cmd.name, fishQuote(cmd.description))
+	}
+	for _, cmd := range commands {
+		if len(cmd.subcommands) == 0 && len(cmd.flags) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n")
+		var subs []string
+		for _, sub := range cmd.subcommands {
+			subs = append(subs, sub.Name)
+		}
+		for _, sub := range cmd.subcommands {
+			condition := "__fish_seen_subcommand_from " + cmd.name + "; and not __fish_seen_subcommand_from " + strings.Join(subs, " ")
+			fmt.Fprintf(w, "complete -c zeroplex -n %s -a %s -d %s\n", fishQuote(condition), sub.Name, fishQuote(sub.Description))
+		}
+		for _, f := range cmd.flags {
+			fishFlag(w, f, "__fish_seen_subcommand_from "+cmd.name)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package docs holds the structured description of the command line, configuration keys,
+// environment variables and exit codes. The help output and the man page are both generated
+// from it, so they can't disagree.
+package docs
+
+import (
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/exitcode"
+
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Entry is a single documented item: a command, flag, environment variable or exit code
+type Entry struct {
+	Name        string
+	Description string
+}
+
+// Section is a titled group of entries
+type Section struct {
+	Title   string
+	Entries []Entry
+}
+
+// Commands lists the subcommands, run as `zeroplex [options] <command>`
+var Commands = []Entry{
+	{"state show", "Show managed interfaces from the state store (--format table|json, --actions)"},
+	{"state forget", "Remove a stale entry from the state store (--interface NAME)"},
+	{"export-state [--output FILE]", "Write the state store, its action history and the other files of the state directory as one JSON bundle"},
+	{"import-state [--force] FILE|-", "Restore a bundle written by export-state, after a reinstall or on a replacement host"},
+	{"tray", "Show a desktop tray icon for the running daemon (needs daemon.dbus)"},
+	{"top", "Live terminal dashboard of the running daemon (needs control.enabled)"},
+	{"events [--follow]", "Print or stream the running daemon's events (needs control.enabled)"},
+	{"logs [--tail N]", "Print the running daemon's recent log lines kept in memory (needs control.enabled)"},
+	{"metrics dump", "Print the running daemon's metrics in OpenMetrics text format (needs control.enabled)"},
+	{"status [--format json]", "Print the running daemon's state and networks (needs control.enabled)"},
+	{"report [--remote HOSTS|@FILE]", "Summarize the status of many hosts, fetched over ssh or piped as status JSON: drift, failures, versions (--format json)"},
+	{"networks list [--json] [--socket PATH]", "Print the joined networks and whether the filters include them, without touching DNS; asks the daemon when ZeroTier can't be queried directly"},
+	{"snapshot [--format yaml|json]", "Print the desired state: filtered networks, DNS per interface and generated files (--output FILE)"},
+	{"apply", "Resume scheduled runs and reconcile now; without a running daemon, run one reconcile"},
+	{"flush", "Remove every change zeroplex made, pausing the running daemon's scheduled runs until the next apply"},
+	{"restore", "Restore every managed interface and pause scheduled runs until the next apply (needs control.enabled)"},
+	{"reload", "Re-read the configuration and reconcile, keeping the current one if it is invalid (needs control.enabled)"},
+	{"pause|resume", "Stop or restart scheduled runs of the running daemon (needs control.enabled)"},
+	{"config init", "Write a commented starter configuration for this host (--output FILE|-, --interactive, --force)"},
+	{"version [--json]", "Print the version, platform, available modes, secret providers and built-in integrations"},
+	{"docs man|help-all", "Print the zeroplex(8) man page in roff format, or the --help-all text"},
+	{"completion bash|zsh|fish", "Print the shell completion script for the commands, options and their values"},
+}
+
+// Options lists the global flags by topic. Entries name the flag as registered with the flag
+// package, so defaults can be looked up; "*" marks the default value where it is spelled out.
+var Options = []Section{
+	{"General Options", []Entry{
+		{"help", "Show help message and exit"},
+		{"help-all", "Show help including configuration keys, environment variables and exit codes"},
+		{"version", "Print the version and exit"},
+		{"config-file", "Path to the configuration file: YAML, TOML or JSON by its extension (default ./zeroplex.yml, then /etc/zeroplex.yml), with fragments in its .d drop-in directory loaded over it"},
+		{"config-dir", "Directory holding zeroplex.yml and conf.d/*.yml (or .toml, .json) fragments, loaded in name order"},
+		{"profile", "Specify a profile to use from the configuration file"},
+		{"decryption-key-file", "age identity file for encrypted configuration (age or sops)"},
+		{"mode", "Mode of operation: 'auto'*, 'networkd', 'resolved', 'networkmanager', 'resolvconf', 'resolvfile', 'dnsmasq', 'unbound', 'openwrt', 'macos', 'windows', or 'noop'"},
+		{"dry-run", "Enable dry-run mode. No changes will be made."},
+		{"enforce", "Apply changes (default true); false only reports drift via logs, metrics and webhooks"},
+		{"force", "Apply changes even when they exceed the safety limits"},
+		{"timeout", "Abort a one-shot run that takes longer than this (e.g. '2m'), with exit code 10"},
+	}},
+	{"Logging Options", []Entry{
+		{"log-level", "Set the logging level ('info', 'verbose'*, 'error', 'debug', 'trace')"},
+		{"log-type", "Log output type: 'console'*, 'file', or 'both'"},
+		{"log-file", "Log file path if log-type is 'file' or 'both'"},
+		{"log-timestamps", "Enable timestamps in logs"},
+	}},
+	{"Features", []Entry{
+		{"dns-over-tls", "Automatically prefer DNS-over-TLS"},
+		{"multicast-dns", "Enable Multicast DNS (mDNS)"},
+		{"add-reverse-domains", "Add ip6.arpa and in-addr.arpa search domains"},
+		{"restore-on-exit", "Restore original DNS settings for all managed interfaces on exit"},
+	}},
+	{"Networkd Options", []Entry{
+		{"auto-restart", "Automatically restart systemd-networkd when things change"},
+		{"reconcile", "Automatically remove left networks from systemd-networkd configuration"},
+	}},
+	{"Interface Watch Options", []Entry{
+		{"interface-watch-mode", "Interface watch mode: event, poll, or off"},
+		{"interface-watch-retry-count", "Number of retries after interface event"},
+		{"interface-watch-retry-delay", "Delay between interface event retries (e.g., '2s')"},
+	}},
+	{"ZeroTier Client Options", []Entry{
+		{"host", "ZeroTier client host address"},
+		{"port", "ZeroTier client port number"},
+		{"token", "API token to use. Overrides token-file if provided"},
+		{"token-file", "Path to the ZeroTier authentication token file"},
+	}},
+}
+
+// Environment lists the environment variables zeroplex reads
+var Environment = []Entry{
+	{"ZEROPLEX_CONFIG", "Configuration file, or directory laid out like --config-dir, when neither flag is given"},
+	{"ZEROPLEX_AGE_KEY_FILE", "age identity file for encrypted configuration, when --decryption-key-file is not given"},
+	{"ZEROPLEX_<KEY>", "Any configuration key, its path upper-cased with dots as underscores (ZEROPLEX_MODE, ZEROPLEX_CLIENT_PORT, ZEROPLEX_LOG_LEVEL); overrides the file, flags override it. Lists are comma separated"},
+	{"SOPS_AGE_KEY_FILE", "Fallback age identity file, shared with sops"},
+	{"CREDENTIALS_DIRECTORY", "Directory of systemd credentials resolved by systemd-creds:// secret references; its ztauth credential is the API token"},
+	{"VAULT_ADDR", "HashiCorp Vault address for vault:// secret references"},
+	{"VAULT_TOKEN", "HashiCorp Vault token (falls back to ~/.vault-token)"},
+	{"VAULT_NAMESPACE", "HashiCorp Vault Enterprise namespace"},
+	{"DBUS_SYSTEM_BUS_ADDRESS", "System bus used for sleep/resume detection and the D-Bus interface"},
+	{"SOURCE_DATE_EPOCH", "Date stamped into the generated man page, for reproducible builds"},
+}
+
+// ExitCodes lists the process exit codes, see package exitcode
+var ExitCodes = []Entry{
+	{fmt.Sprint(exitcode.OK), "Success"},
+	{fmt.Sprint(exitcode.Failure), "Failure without a more specific code"},
+	{fmt.Sprint(exitcode.Usage), "Invalid command line"},
+	{fmt.Sprint(exitcode.Config), "Configuration missing, unreadable or invalid, or the mode can't be detected"},
+	{fmt.Sprint(exitcode.Privilege), "Not running as root and missing a capability, polkit authorization or write access the configuration needs, or permission denied"},
+	{fmt.Sprint(exitcode.APIUnreachable), "The ZeroTier API could not be queried"},
+	{fmt.Sprint(exitcode.PartialApply), "At least one interface failed apply verification"},
+	{fmt.Sprint(exitcode.DriftPending), "An observe-only or deferred run found drift that was not corrected"},
+	{fmt.Sprint(exitcode.Restored), "The ZeroTier API failed and applied DNS was restored before exit"},
+	{fmt.Sprint(exitcode.SafetyLimit), "The run would have exceeded a safety limit and changed nothing"},
+	{fmt.Sprint(exitcode.Timeout), "A one-shot run did not finish within --timeout and was aborted"},
+}
+
+// ConfigKey is a single configuration key of a profile
+type ConfigKey struct {
+	Key         string // dotted path, e.g. "daemon.poll_interval"
+	Type        string
+	Default     string
+	Description string
+}
+
+// configDescriptions documents the configuration keys by dotted path. Keys without an entry are
+// still listed, with their type and default.
+var configDescriptions = map[string]string{
+	"mode":                                     "Mode of operation: auto, networkd, resolved, networkmanager, resolvconf, resolvfile, dnsmasq, unbound, openwrt, macos, windows or noop",
+	"init_system":                              "Init system used to check and reload services: auto, systemd, openrc, runit or none",
+	"enforce":                                  "Apply changes; false only detects and reports drift (default: true)",
+	"log.level":                                "Log level: error, warn, info, verbose, debug or trace",
+	"log.type":                                 "Log output: console, file or both",
+	"log.file":                                 "Log file used by log.type file and both",
+	"log.timestamps":                           "Prefix log lines with timestamps",
+	"daemon.enabled":                           "Keep running and reconcile periodically instead of once",
+	"daemon.poll_interval":                     "Interval between scheduled runs",
+	"daemon.start_jitter":                      "Delay the first run by a random duration up to this",
+	"daemon.skip_initial_run":                  "Wait one poll interval before the first run",
+	"daemon.dbus":                              "Export the com.nfrastack.ZeroPlex object on the system bus",
+	"daemon.watch_config":                      "Reload the configuration when its files change, as SIGHUP does",
+	"client.host":                              "ZeroTier service address: URL, host name or IP address (IPv6 optionally bracketed), with an optional port",
+	"client.port":                              "ZeroTier service port",
+	"client.token":                             "Inline ZeroTier API token, taking precedence over token_source and token_file",
+	"client.token_file":                        "File containing the ZeroTier API token",
+	"client.token_source":                      "Secret reference for the API token (file://, env://, cmd://, vault://, systemd-creds://)",
+	"client.token_env":                         "Environment variable holding the API token (instead of token_source)",
+	"client.token_command":                     "Shell command printing the API token, such as a secrets manager CLI (instead of token_source)",
+	"features.dns_over_tls":                    "Prefer DNS-over-TLS",
+	"features.add_reverse_domains":             "Add in-addr.arpa and ip6.arpa routing domains",
+	"features.multicast_dns":                   "Enable mDNS resolution on ZeroTier interfaces",
+	"features.mdns_conflict":                   "How to resolve a conflict with avahi-daemon: warn, skip, avahi or off",
+	"features.mdns_advertise":                  "Advertise this host's name over mDNS on ZeroTier interfaces",
+	"features.restore_on_exit":                 "Restore the original DNS of managed interfaces on shutdown",
+	"features.watchdog_ip":                     "Address pinged by the DNS watchdog (IPv4, or IPv6 with ping -6)",
+	"features.watchdog_interval":               "Interval between watchdog checks",
+	"features.watchdog_backoff":                "Retry delays after a failed watchdog check",
+	"features.watchdog_hostname":               "Hostname resolved by the lookup watchdog",
+	"features.watchdog_expected_ip":            "Addresses the watchdog hostname must resolve to, comma separated (only AAAA records are looked up if all are IPv6)",
+	"features.extra_search_domains":            "Additional search domains; substitution variables are expanded",
+	"features.verify_hostname":                 "Hostname resolved through each interface after applying",
+	"features.verify_timeout":                  "Timeout of the apply verification lookup",
+	"features.watchdog_networks":               "Hostname watchdog per ZeroTier network, keyed by network ID",
+	"features.watchdog_networks.*.enabled":     "Enable the hostname watchdog for this network",
+	"features.watchdog_networks.*.hostname":    "Hostname to resolve (default: %hostname%.%domain%)",
+	"features.watchdog_networks.*.expected_ip": "Expected addresses, comma separated (default: this node's addresses on the network)",
+	"features.watchdog_exec":                   "Probe command and arguments; exit 0 is healthy, 1 unhealthy, anything else a probe error",
+	"features.watchdog_exec_timeout":           "Time a watchdog_exec probe may run (default: 10s)",
+	"networkd.auto_restart":                    "Reload systemd-networkd after changing .network files",
+	"networkd.reconcile":                       "Remove .network files of networks that were left",
+	"networkd.max_reloads_per_hour":            "Refuse networkd reloads beyond this many in a rolling hour (0: unlimited)",
+	"networkd.wait_for_carrier":                "Defer the first networkd reload until the ZeroTier interfaces report carrier, for at most this long (e.g. '30s')",
+	"interface_watch.mode":                     "React to interface changes: event, poll or off",
+	"interface_watch.retry.count":              "Retries after an interface event",
+	"interface_watch.retry.delay":              "Delay between retries",
+	"interface_watch.retry.backoff":            "Explicit retry delays, overriding delay",
+	"interface_watch.retry.max_total":          "Upper bound on a single recovery attempt",
+	"interface_watch.retry.global_timeout":     "Shared deadline of overlapping recovery attempts (default: 10m)",
+	"interface_watch.retry.max_concurrent":     "Maximum recovery loops running at once (default: 2)",
+	"interface_watch.readiness":                "Readiness strategy: api-status, address-present, route-present, dns-probe or composite (default: api-status)",
+	"interface_watch.checks":                   "Strategies a composite readiness requires",
+	"interface_watch.networks":                 "Readiness requirements per ZeroTier network, keyed by network ID",
+	"interface_watch.networks.*.readiness":     "Readiness strategy of this network",
+	"interface_watch.networks.*.checks":        "Strategies this network's composite readiness requires",
+	"interface_watch.networks.*.dual_stack":    "Wait for both an IPv4 and an IPv6 address before applying DNS",
+	"dnsmasq.config_dir":                       "conf.d directory dnsmasq mode writes zeroplex-<interface>.conf snippets to",
+	"dnsmasq.servers_file":                     "Write the server lines of all networks to this file, loaded by dnsmasq with servers-file=, instead",
+	"dnsmasq.service":                          "Service restarted (or reloaded, with servers_file) after changes",
+	"dnsmasq.reload":                           "Restart or reload dnsmasq after changing its configuration",
+	"unbound.include_file":                     "File unbound mode writes forward-zone stanzas to; must be included by unbound",
+	"unbound.control":                          "Add forward zones at runtime with unbound-control forward_add instead of writing include_file",
+	"unbound.service":                          "Service reloaded after include_file changes",
+	"unbound.reload":                           "Reload unbound after changing include_file",
+	"unbound.reconcile":                        "Remove the forward zones of networks that were left",
+	"openwrt.section":                          "uci section of /etc/config/dhcp that openwrt mode adds server entries to",
+	"openwrt.rebind_domains":                   "Also add the domains as rebind_domain entries, so dnsmasq's rebind protection lets private answers through",
+	"openwrt.reload":                           "Reload dnsmasq through ubus after committing changes",
+	"windows.nrpt":                             "Route the domains of each network to its DNS servers with NRPT rules (windows mode)",
+	"windows.interface_dns":                    "Also set the DNS servers and connection-specific suffix of each ZeroTier adapter (windows mode)",
+	"safety.max_changes_per_run":               "Abort a run that would change more interfaces than this (0: unlimited)",
+	"safety.max_removals_per_run":              "Abort a run that would remove more interfaces or files than this (0: unlimited)",
+	"safety.ack":                               "Plan ID logged by an aborted run; a run with exactly that plan proceeds",
+	"safety.keep_on_empty_filter":              "Abort a run whose filters select none of the joined networks, instead of removing their DNS",
+	"maintenance.defer_windows":                "Cron expressions (minute hour day month weekday) of the times changes are deferred and drift only reported",
+	"maintenance.timezone":                     "IANA time zone the defer windows are in (default: local time)",
+	"coordination.enabled":                     "Detect domains also routed by tailscaled, VPN clients or NetworkManager split DNS",
+	"coordination.policy":                      "What to do about them: merge (configure alongside), yield (leave to the other manager) or override",
+	"coordination.domains":                     "Policy per domain, also covering its subdomains",
+	"server_order.strategy":                    "Order of the DNS servers of each network: pushed (as the controller lists them) or rtt (fastest first)",
+	"server_order.interval":                    "How long a round-trip time measurement is kept before it is taken again",
+	"resolv_watch.enabled":                     "Watch resolver files for rewrites by VPN clients or DHCP hooks (daemon mode)",
+	"resolv_watch.paths":                       "Files to watch (default: /etc/resolv.conf and the systemd-resolved stub file)",
+	"resolv_watch.interval":                    "How often the files are checked (default: 5s)",
+	"resolv_watch.reassert":                    "Start a reconcile run to put the managed settings back after a rewrite",
+	"control.enabled":                          "Serve the local control API",
+	"control.socket":                           "Unix socket of the control API (default: /run/zeroplex/control.sock)",
+	"control.user":                             "User owning the control socket, who may run commands like root",
+	"control.group":                            "Group whose members may read the status over the control socket",
+	"control.admin_group":                      "Group whose members may also run commands over the control socket (apply, restore, reload, pause, resume)",
+	"control.mode":                             "Octal permissions of the control socket (default: 0600, 0660 with a group, 0666 with different reader and admin groups)",
+	"health.enabled":                           "Serve the /healthz and /readyz HTTP endpoints",
+	"health.listen":                            "Address of the health endpoints (default: 127.0.0.1:9780)",
+	"grpc.enabled":                             "Serve the gRPC management API",
+	"grpc.listen":                              "Address of the gRPC API: unix:PATH or host:port (default: unix:/run/zeroplex/grpc.sock)",
+	"hardening.umask":                          "Octal umask of the process, e.g. 0077",
+	"hardening.nofile":                         "Soft and hard limit on open files",
+	"hardening.disable_core_dumps":             "Set the core dump limit to 0",
+	"hardening.lock_state_dir":                 "Make the state directory 0700 and its files 0600, owned by hardening.user if set",
+	"hardening.user":                           "Daemon mode: user to drop to once the sockets are bound",
+	"hardening.group":                          "Group to drop to (default: the user's primary group)",
+	"networks":                                 "Settings overridden per ZeroTier network, keyed by network ID",
+	"networks.*.mode":                          "Mode managing this network instead of mode (not auto)",
+	"networks.*.dns_servers":                   "DNS servers of this network, instead of those ZeroTier pushes",
+	"networks.*.extra_search_domains":          "Extra search domains of this network, instead of features.extra_search_domains",
+	"networks.*.dns_over_tls":                  "DNS-over-TLS for this network, instead of features.dns_over_tls",
+	"networks.*.multicast_dns":                 "mDNS for this network, instead of features.multicast_dns (still subject to features.mdns_conflict)",
+	"networks.*.hold_down":                     "Apply DNS to this network only once it has been ready (OK, with DNS servers) this long without a break",
+	"filters":                                  "Network and interface filters",
+	"webhooks":                                 "HTTP endpoints receiving events as JSON",
+	"webhooks[].url":                           "Endpoint URL",
+	"webhooks[].events":                        "Event types to deliver (default: all)",
+	"webhooks[].secret":                        "HMAC secret signing the payload; accepts secret references",
+	"webhooks[].timeout":                       "Request timeout",
+	"labels":                                   "Fleet labels attached to metrics, events and recorded actions",
+	"config_epoch":                             "Generation of the configuration, logged with every run and attached to metrics, events and recorded actions",
+	"timeout":                                  "Abort a one-shot run that takes longer than this, with exit code 10; daemon runs are not limited",
+	"experimental":                             "Experimental features to turn on by name (resolved_dbus)",
+	"state_dir":                                "Directory zeroplex keeps its state store and backups in (default: /var/lib/zeroplex)",
+}
+
+// ConfigKeys lists every key of a profile, as found under `default:` and `profiles.<name>:`,
+// in the order they are declared
+func ConfigKeys() []ConfigKey {
+	var keys []ConfigKey
+	walkConfig(reflect.ValueOf(config.DefaultConfig().Default), "", &keys)
+	return keys
+}
+
+func walkConfig(value reflect.Value, prefix string, keys *[]ConfigKey) {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		key := prefix + name
+		fieldValue := value.Field(i)
+		fieldType := field.Type
+		// An unset pointer means "not configured"; its default is documented in the description
+		unset := false
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+			if unset = fieldValue.IsNil(); unset {
+				fieldValue = reflect.Zero(fieldType)
+			} else {
+				fieldValue = fieldValue.Elem()
+			}
+		}
+
+		switch {
+		case fieldType.Kind() == reflect.Struct:
+			walkConfig(fieldValue, key+".", keys)
+		case fieldType.Kind() == reflect.Slice && fieldType.Elem().Kind() == reflect.Struct:
+			*keys = append(*keys, configKey(key, "list", ""))
+			walkConfig(reflect.Zero(fieldType.Elem()), key+"[].", keys)
+		case fieldType.Kind() == reflect.Map && fieldType.Elem().Kind() == reflect.Struct:
+			*keys = append(*keys, configKey(key, "map", ""))
+			walkConfig(reflect.Zero(fieldType.Elem()), key+".*.", keys)
+		case unset:
+			*keys = append(*keys, configKey(key, typeName(fieldType), ""))
+		default:
+			*keys = append(*keys, configKey(key, typeName(fieldType), defaultValue(fieldValue)))
+		}
+	}
+}
+
+func configKey(key, typ, def string) ConfigKey {
+	return ConfigKey{Key: key, Type: typ, Default: def, Description: configDescriptions[key]}
+}
+
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return "int"
+	case reflect.Slice:
+		return "list"
+	case reflect.Map:
+		return "map"
+	default:
+		return "string"
+	}
+}
+
+func defaultValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Bool:
+		return fmt.Sprint(v.Bool())
+	case reflect.Slice, reflect.Map:
+		if v.Len() == 0 {
+			return ""
+		}
+		var items []string
+		if v.Kind() == reflect.Slice {
+			for i := 0; i < v.Len(); i++ {
+				items = append(items, fmt.Sprint(v.Index(i).Interface()))
+			}
+		} else {
+			for _, k := range v.MapKeys() {
+				items = append(items, fmt.Sprintf("%v=%v", k.Interface(), v.MapIndex(k).Interface()))
+			}
+			sort.Strings(items)
+		}
+		return strings.Join(items, ",")
+	default:
+		if v.IsZero() {
+			return ""
+		}
+		return fmt.Sprint(v.Interface())
+	}
+}
@@ -0,0 +1,139 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package docs
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Usage writes the commands and global options, as printed by --help
+func Usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: zeroplex [options] [command]\n\n")
+	fmt.Fprintf(w, "Commands:\n")
+	for _, cmd := range Commands {
+		fmt.Fprintf(w, "  %-29s %s\n", cmd.Name, cmd.Description)
+	}
+	for _, section := range Options {
+		fmt.Fprintf(w, "\n%s:\n", section.Title)
+		for _, opt := range section.Entries {
+			fmt.Fprintf(w, "  %-29s %s\n", "--"+opt.Name, opt.Description)
+		}
+	}
+	fmt.Fprintf(w, "\n")
+}
+
+// HelpAll writes Usage followed by the configuration keys, environment variables and exit codes
+func HelpAll(w io.Writer) {
+	Usage(w)
+	fmt.Fprintf(w, "Configuration Keys (under default: or profiles.<name>:):\n")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, key := range ConfigKeys() {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", key.Key, key.Type, keySummary(key))
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\nEnvironment:\n")
+	for _, env := range Environment {
+		fmt.Fprintf(w, "  %-29s %s\n", env.Name, env.Description)
+	}
+	fmt.Fprintf(w, "\nExit Codes:\n")
+	for _, code := range ExitCodes {
+		fmt.Fprintf(w, "  %-29s %s\n", code.Name, code.Description)
+	}
+	fmt.Fprintf(w, "\n")
+}
+
+// Man writes the zeroplex(8) man page in roff format
+func Man(w io.Writer, version string) {
+	fmt.Fprintf(w, ".TH ZEROPLEX 8 %q %q \"System Administration\"\n", manDate().Format("2006-01-02"), "zeroplex "+version)
+	fmt.Fprintf(w, ".SH NAME\nzeroplex \\- per-interface DNS configuration for ZeroTier networks\n")
+	fmt.Fprintf(w, ".SH SYNOPSIS\n.B zeroplex\n[\\fIoptions\\fR] [\\fIcommand\\fR]\n")
+	fmt.Fprintf(w, ".SH DESCRIPTION\n%s\n", roff("zeroplex detects the DNS servers and domains assigned by ZeroTier controllers "+
+		"and applies them to each ZeroTier interface through systemd-networkd, systemd-resolved, NetworkManager, dnsmasq, unbound, OpenWrt UCI, resolvconf or /etc/resolv.conf on Linux, /etc/resolver on macOS, or the adapter settings on Windows. "+
+		"It runs once, or as a daemon that reconciles periodically and on interface, resume and watchdog events."))
+
+	fmt.Fprintf(w, ".SH COMMANDS\n")
+	for _, cmd := range Commands {
+		fmt.Fprintf(w, ".TP\n.B %s\n%s\n", roff(cmd.Name), roff(cmd.Description))
+	}
+
+	fmt.Fprintf(w, ".SH OPTIONS\n")
+	for _, section := range Options {
+		fmt.Fprintf(w, ".SS %s\n", roff(section.Title))
+		for _, opt := range section.Entries {
+			description := opt.Description
+			if def := flagDefault(opt.Name); def != "" {
+				description += " (default: " + def + ")"
+			}
+			fmt.Fprintf(w, ".TP\n.B \\-\\-%s\n%s\n", roff(opt.Name), roff(description))
+		}
+	}
+
+	fmt.Fprintf(w, ".SH CONFIGURATION\n%s\n", roff("The configuration file is YAML. Every key below can be set under default: "+
+		"and overridden under profiles.<name>:, selected with --profile. Command line flags override the file."))
+	for _, key := range ConfigKeys() {
+		fmt.Fprintf(w, ".TP\n.BR %s \" (%s)\"\n%s\n", roff(key.Key), key.Type, roff(keySummary(key)))
+	}
+
+	fmt.Fprintf(w, ".SH ENVIRONMENT\n")
+	for _, env := range Environment {
+		fmt.Fprintf(w, ".TP\n.B %s\n%s\n", roff(env.Name), roff(env.Description))
+	}
+
+	fmt.Fprintf(w, ".SH EXIT STATUS\n")
+	for _, code := range ExitCodes {
+		fmt.Fprintf(w, ".TP\n.B %s\n%s\n", code.Name, roff(code.Description))
+	}
+
+	fmt.Fprintf(w, ".SH FILES\n.TP\n.I /etc/zeroplex.yml\nConfiguration file\n.TP\n.I <config-dir>/conf.d/*.yml\nConfiguration fragments loaded after <config-dir>/zeroplex.yml with --config-dir\n")
+	fmt.Fprintf(w, ".SH SEE ALSO\n.BR systemd\\-networkd (8),\n.BR systemd\\-resolved (8),\n.BR resolvectl (1),\n.BR zerotier\\-cli (1)\n")
+	fmt.Fprintf(w, ".SH AUTHORS\nNfrastack <code@nfrastack.com>\n")
+}
+
+// keySummary is the description of a key with its default appended
+func keySummary(key ConfigKey) string {
+	summary := key.Description
+	if key.Default != "" {
+		if summary != "" {
+			summary += " "
+		}
+		summary += "(default: " + key.Default + ")"
+	}
+	return summary
+}
+
+// flagDefault returns the default of a registered flag, or "" for unregistered and zero defaults
+func flagDefault(name string) string {
+	f := flag.Lookup(name)
+	if f == nil || f.DefValue == "" || f.DefValue == "false" || f.DefValue == "0" {
+		return ""
+	}
+	return f.DefValue
+}
+
+// manDate honours SOURCE_DATE_EPOCH so packaged man pages are reproducible
+func manDate() time.Time {
+	if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		return time.Unix(epoch, 0).UTC()
+	}
+	return time.Now().UTC()
+}
+
+// roff escapes text for use in a man page line
+func roff(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	// A leading dot or quote would be read as a request
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
@@ -0,0 +1,139 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package events fans out notable occurrences (drift, applied changes, ...) to configured sinks.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types published by zeroplex
+const (
+	TypeDrift             = "drift"
+	TypeDriftCleared      = "drift_cleared"
+	TypeApply             = "apply"              // DNS settings of an interface were applied or changed
+	TypeRestore           = "restore"            // an interface was restored and is no longer managed
+	TypeWatchdogFailure   = "watchdog_failure"   // a watchdog target started failing
+	TypeWatchdogRecovered = "watchdog_recovered" // a failing watchdog target is healthy again
+	TypeAPIError          = "api_error"          // the ZeroTier API could not be queried
+	TypeAPIRecovered      = "api_recovered"      // the ZeroTier API answers again after an api_error
+	TypeRename            = "rename"             // a managed interface was renamed and its state moved along
+	TypeSafetyAbort       = "safety_abort"       // a run was aborted because it would exceed a safety limit
+	TypeConflict          = "dns_conflict"       // another DNS manager routes a domain zeroplex manages
+	TypeReloadLimit       = "reload_limit"       // a service reload was refused because it reached its hourly ceiling
+	TypeAdminDisabled     = "admin_disabled"     // the sentinel file administratively disabling zeroplex appeared
+	TypeAdminEnabled      = "admin_enabled"      // the sentinel file administratively disabling zeroplex was removed
+)
+
+// Event is a single notification delivered to every sink
+type Event struct {
+	Type        string                 `json:"type"`
+	Time        time.Time              `json:"time"`
+	Mode        string                 `json:"mode,omitempty"`
+	Interface   string                 `json:"interface,omitempty"`
+	NetworkID   string                 `json:"network_id,omitempty"`
+	Message     string                 `json:"message,omitempty"`
+	Data        map[string]interface{} `json:"data,omitempty"`
+	Labels      map[string]string      `json:"labels,omitempty"`
+	ConfigEpoch string                 `json:"config_epoch,omitempty"`
+}
+
+// Sink receives published events. Send must not block for long; slow sinks should queue.
+type Sink interface {
+	Send(ev Event)
+	Close()
+}
+
+// recentSize bounds the history of published events kept for the control API
+const recentSize = 100
+
+var (
+	mu          sync.RWMutex
+	sinks       []Sink
+	labels      map[string]string
+	epoch       string
+	recent      []Event
+	subscribers = map[chan Event]struct{}{}
+)
+
+// SetSinks replaces the registered sinks, closing the previous ones
+func SetSinks(next ...Sink) {
+	mu.Lock()
+	previous := sinks
+	sinks = next
+	mu.Unlock()
+	for _, s := range previous {
+		s.Close()
+	}
+}
+
+// SetLabels sets the fleet identity labels attached to every published event
+func SetLabels(next map[string]string) {
+	mu.Lock()
+	defer mu.Unlock()
+	labels = next
+}
+
+// SetConfigEpoch sets the configuration generation attached to every published event
+func SetConfigEpoch(next string) {
+	mu.Lock()
+	defer mu.Unlock()
+	epoch = next
+}
+
+// Publish delivers ev to every registered sink
+func Publish(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if ev.Labels == nil {
+		ev.Labels = labels
+	}
+	if ev.ConfigEpoch == "" {
+		ev.ConfigEpoch = epoch
+	}
+	recent = append(recent, ev)
+	if len(recent) > recentSize {
+		recent = recent[len(recent)-recentSize:]
+	}
+	for _, s := range sinks {
+		s.Send(ev)
+	}
+	for ch := range subscribers {
+		// A subscriber that can't keep up misses events rather than stalling the publisher
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel receiving every event published from now on, buffering up to
+// buffer events, and a func that cancels the subscription and closes the channel
+func Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	mu.Lock()
+	subscribers[ch] = struct{}{}
+	mu.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			mu.Lock()
+			delete(subscribers, ch)
+			mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Recent returns up to the last recentSize published events, oldest first
+func Recent() []Event {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]Event(nil), recent...)
+}
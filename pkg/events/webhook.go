@@ -0,0 +1,151 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package events
+
+import (
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/secrets"
+	"zeroplex/pkg/utils"
+
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// webhookQueueSize bounds how many events may wait for delivery before new ones are dropped
+const webhookQueueSize = 64
+
+// Webhook posts events as JSON to an HTTP endpoint from a background worker
+type Webhook struct {
+	url    string
+	secret string
+	types  map[string]struct{}
+	client *http.Client
+	logger *log.Logger
+
+	queue     chan Event
+	closeOnce sync.Once
+}
+
+// NewWebhook creates a webhook sink and starts its delivery worker
+func NewWebhook(cfg config.WebhookConfig, logLevel string) (*Webhook, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("webhook url is required")
+	}
+	timeout := 5 * time.Second
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook timeout %q: %w", cfg.Timeout, err)
+		}
+		timeout = d
+	}
+	secret, err := secrets.Resolve(cfg.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("webhook %s: %w", cfg.URL, err)
+	}
+	w := &Webhook{
+		url:    cfg.URL,
+		secret: secret,
+		client: &http.Client{Timeout: timeout},
+		logger: log.NewScopedLogger("[events/webhook]", logLevel),
+		queue:  make(chan Event, webhookQueueSize),
+	}
+	if len(cfg.Events) > 0 {
+		w.types = make(map[string]struct{}, len(cfg.Events))
+		for _, t := range cfg.Events {
+			w.types[t] = struct{}{}
+		}
+	}
+	go w.run()
+	return w, nil
+}
+
+// Send queues ev for delivery, dropping it if the queue is full
+func (w *Webhook) Send(ev Event) {
+	if w.types != nil {
+		if _, ok := w.types[ev.Type]; !ok {
+			return
+		}
+	}
+	select {
+	case w.queue <- ev:
+	default:
+		w.logger.Warn("Webhook queue for %s is full, dropping %s event", w.url, ev.Type)
+	}
+}
+
+// Close stops the worker once queued events have been delivered
+func (w *Webhook) Close() {
+	w.closeOnce.Do(func() { close(w.queue) })
+}
+
+func (w *Webhook) run() {
+	for ev := range w.queue {
+		if err := w.deliver(ev); err != nil {
+			w.logger.Warn("Failed to deliver %s event to %s: %v", ev.Type, w.url, err)
+		}
+	}
+}
+
+func (w *Webhook) deliver(ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, eventVars(ev).Expand(w.url), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "zeroplex")
+	req.Header.Set("X-Zeroplex-Event", ev.Type)
+	if w.secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.secret))
+		mac.Write(body)
+		req.Header.Set("X-Zeroplex-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	w.logger.Trace("Delivered %s event to %s", ev.Type, w.url)
+	return nil
+}
+
+// eventVars returns the substitution variables for a webhook URL, e.g. https://hooks.example.com/%hostname%/%interface%
+func eventVars(ev Event) utils.Vars {
+	domain, _ := ev.Data["domain"].(string)
+	return utils.NetworkVars(ev.Interface, ev.NetworkID, domain)
+}
+
+// ConfigureWebhooks replaces the registered sinks with the configured webhooks
+func ConfigureWebhooks(webhooks []config.WebhookConfig, logLevel string) {
+	logger := log.NewScopedLogger("[events]", logLevel)
+	var next []Sink
+	for _, cfg := range webhooks {
+		w, err := NewWebhook(cfg, logLevel)
+		if err != nil {
+			logger.Warn("Ignoring webhook: %v", err)
+			continue
+		}
+		next = append(next, w)
+	}
+	SetSinks(next...)
+	if len(next) > 0 {
+		logger.Debug("Configured %d webhook(s)", len(next))
+	}
+}
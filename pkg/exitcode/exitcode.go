@@ -0,0 +1,74 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package exitcode defines the process exit codes of zeroplex, so wrappers can tell failure types
+// apart. The values are part of the command line interface and never change meaning.
+package exitcode
+
+import (
+	"errors"
+	"io/fs"
+)
+
+const (
+	OK             = 0  // success
+	Failure        = 1  // any failure without a more specific code
+	Usage          = 2  // invalid command line
+	Config         = 3  // configuration could not be loaded or is invalid
+	Privilege      = 4  // not running with the required privileges
+	APIUnreachable = 5  // the ZeroTier API could not be queried
+	PartialApply   = 6  // settings were applied but at least one interface did not take them
+	DriftPending   = 7  // observe-only run found drift that was not corrected
+	Restored       = 8  // DNS was restored to its saved state before exiting instead of applied
+	SafetyLimit    = 9  // the run would have exceeded a safety limit and changed nothing
+	Timeout        = 10 // a one-shot run did not finish within its timeout
+)
+
+// Coder is implemented by errors that carry their own exit code
+type Coder interface {
+	ExitCode() int
+}
+
+// Error attaches an exit code to an error
+type Error struct {
+	Code int
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the attached exit code
+func (e *Error) ExitCode() int {
+	return e.Code
+}
+
+// Wrap attaches code to err; a nil err stays nil
+func Wrap(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: code, Err: err}
+}
+
+// Code returns the exit code for err: OK for nil, the code of the outermost Coder in its chain,
+// Privilege for permission errors, or Failure
+func Code(err error) int {
+	if err == nil {
+		return OK
+	}
+	var coder Coder
+	if errors.As(err, &coder) {
+		return coder.ExitCode()
+	}
+	if errors.Is(err, fs.ErrPermission) {
+		return Privilege
+	}
+	return Failure
+}
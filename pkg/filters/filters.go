@@ -69,7 +69,7 @@ func DefaultFilterConfig() FilterConfig {
 
 // ApplyFilters applies filtering
 func ApplyFilters(networks *service.GetNetworksResponse, profile config.Profile) {
-	logger := log.NewLogger("[filters]", profile.Log.Level)
+	logger := log.NewScopedLogger("[filters]", profile.Log.Level)
 	logger.Trace("ApplyFilters() started")
 
 	if !profile.HasAdvancedFilters() {
@@ -82,7 +82,6 @@ func ApplyFilters(networks *service.GetNetworksResponse, profile config.Profile)
 
 	filterOptions, err := profile.GetAdvancedFilterConfig()
 	if err != nil {
-		logger := log.NewScopedLogger("[filters]", "error")
 		logger.Error("Failed to get advanced filter config: %v", err)
 		return
 	}
@@ -90,7 +89,6 @@ func ApplyFilters(networks *service.GetNetworksResponse, profile config.Profile)
 	logger.Trace("Converting filter options to FilterConfig")
 	filterConfig, err := NewFilterFromStructuredOptions(filterOptions)
 	if err != nil {
-		logger := log.NewScopedLogger("[filters]", "error")
 		logger.Error("Failed to parse advanced filters: %v", err)
 		return
 	}
@@ -101,7 +99,7 @@ func ApplyFilters(networks *service.GetNetworksResponse, profile config.Profile)
 
 // ApplyAdvancedFilters applies filtering with multiple filters and AND/OR operations
 func ApplyAdvancedFilters(networks *service.GetNetworksResponse, filterConfig FilterConfig) {
-	logger := log.NewScopedLogger("[filters]", "debug")
+	logger := log.NewScopedLogger("[filters]", "")
 
 	if len(filterConfig.Filters) == 0 || (len(filterConfig.Filters) == 1 && filterConfig.Filters[0].Type == FilterTypeNone) {
 		logger.Debug("No filtering applied - no filters configured")
@@ -172,7 +170,7 @@ func (fc FilterConfig) Evaluate(network service.Network, evaluator func(Filter,
 
 // evaluateZTFilter evaluates a single filter against a ZeroTier network
 func evaluateZTFilter(filter Filter, network service.Network) bool {
-	logger := log.NewScopedLogger("[filters]", "debug")
+	logger := log.NewScopedLogger("[filters]", "")
 
 	switch filter.Type {
 	case FilterTypeNone:
@@ -289,7 +287,7 @@ func evaluateConditions(value string, conditions []FilterCondition) bool {
 
 // matchesSingleCondition checks if a value matches a single condition
 func matchesSingleCondition(value, pattern string) bool {
-	logger := log.NewScopedLogger("[filters]", "debug")
+	logger := log.NewScopedLogger("[filters]", "")
 
 	// Support regex patterns if they start with ^
 	if strings.HasPrefix(pattern, "^") {
@@ -313,7 +311,7 @@ func matchesSingleCondition(value, pattern string) bool {
 
 // LoadAdvancedFiltersFromYAML loads Filters from YAML configuration
 func LoadAdvancedFiltersFromYAML(data []byte) (FilterConfig, error) {
-	logger := log.NewScopedLogger("[filters]", "error")
+	logger := log.NewScopedLogger("[filters]", "")
 
 	var config FilterConfig
 
@@ -350,7 +348,7 @@ func LoadAdvancedFiltersFromYAML(data []byte) (FilterConfig, error) {
 
 // NewFilterFromStructuredOptions creates a FilterConfig from structured options
 func NewFilterFromStructuredOptions(options map[string]interface{}) (FilterConfig, error) {
-	logger := log.NewScopedLogger("[filters]", "error")
+	logger := log.NewScopedLogger("[filters]", "")
 
 	config := FilterConfig{}
 
@@ -0,0 +1,70 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package hardening applies the hardening settings of the hardening: section to the running
+// process: its umask and resource limits, the permissions of the state directory, and dropping
+// root once the daemon's sockets are bound.
+package hardening
+
+import (
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/state"
+
+	"fmt"
+	"strconv"
+)
+
+// Apply sets the umask and resource limits and locks the state directory, as configured. It runs
+// at startup, before any file is written.
+func Apply(cfg config.HardeningConfig, logger *log.Logger) error {
+	if cfg.Umask != "" {
+		mask, err := strconv.ParseUint(cfg.Umask, 8, 32)
+		if err != nil {
+			return fmt.Errorf("invalid hardening.umask %s: %w", cfg.Umask, err)
+		}
+		old, err := setUmask(int(mask))
+		if err != nil {
+			return err
+		}
+		logger.Debug("Umask set to %04o (was %04o)", mask, old)
+	}
+	if cfg.NoFile > 0 {
+		if err := setNoFile(cfg.NoFile); err != nil {
+			return fmt.Errorf("failed to limit open files to %d: %w", cfg.NoFile, err)
+		}
+		logger.Debug("Open files limited to %d", cfg.NoFile)
+	}
+	if cfg.DisableCoreDumps {
+		if err := disableCoreDumps(); err != nil {
+			return fmt.Errorf("failed to disable core dumps: %w", err)
+		}
+		logger.Debug("Core dumps disabled")
+	}
+	if cfg.LockStateDir {
+		dir := state.DefaultDir().Path()
+		if err := lockDir(dir, cfg.User, cfg.Group); err != nil {
+			return fmt.Errorf("failed to lock the state directory %s: %w", dir, err)
+		}
+		logger.Debug("State directory %s locked", dir)
+	}
+	return nil
+}
+
+// DropPrivileges switches the process to cfg.User and cfg.Group, if a user is set; it is called
+// by the daemon once its sockets are bound. The switch can't be undone.
+func DropPrivileges(cfg config.HardeningConfig, logger *log.Logger) error {
+	if cfg.User == "" {
+		return nil
+	}
+	uid, gid, err := lookupIDs(cfg.User, cfg.Group)
+	if err != nil {
+		return err
+	}
+	if err := setIDs(uid, gid); err != nil {
+		return fmt.Errorf("failed to drop to user %s: %w", cfg.User, err)
+	}
+	logger.Info("Dropped privileges to user %s (uid %d, gid %d)", cfg.User, uid, gid)
+	return nil
+}
@@ -0,0 +1,104 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !windows
+
+package hardening
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+func setUmask(mask int) (int, error) {
+	return syscall.Umask(mask), nil
+}
+
+func setNoFile(limit uint64) error {
+	return syscall.Setrlimit(syscall.RLIMIT_NOFILE, &syscall.Rlimit{Cur: limit, Max: limit})
+}
+
+func disableCoreDumps() error {
+	return syscall.Setrlimit(syscall.RLIMIT_CORE, &syscall.Rlimit{Cur: 0, Max: 0})
+}
+
+// lockDir makes dir and the files and directories in it accessible to their owner only, handing them to the user
+// the daemon drops to so it can still write them
+func lockDir(dir, username, group string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	uid, gid := -1, -1
+	if username != "" {
+		var err error
+		if uid, gid, err = lookupIDs(username, group); err != nil {
+			return err
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	modes := map[string]os.FileMode{dir: 0700}
+	for _, entry := range entries {
+		switch {
+		case entry.IsDir():
+			modes[filepath.Join(dir, entry.Name())] = 0700
+		case entry.Type().IsRegular():
+			modes[filepath.Join(dir, entry.Name())] = 0600
+		}
+	}
+	for path, mode := range modes {
+		if uid >= 0 {
+			if err := os.Chown(path, uid, gid); err != nil {
+				return err
+			}
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookupIDs returns the uid of username and the gid of group, or of the user's primary group
+func lookupIDs(username, group string) (int, int, error) {
+	u, err := user.Lookup(username)
+	if err != nil {
+		return 0, 0, fmt.Errorf("unknown hardening.user %s: %w", username, err)
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return 0, 0, fmt.Errorf("user %s has a non-numeric uid %s", username, u.Uid)
+	}
+	gidString := u.Gid
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			return 0, 0, fmt.Errorf("unknown hardening.group %s: %w", group, err)
+		}
+		gidString = g.Gid
+	}
+	gid, err := strconv.Atoi(gidString)
+	if err != nil {
+		return 0, 0, fmt.Errorf("group of %s has a non-numeric gid %s", username, gidString)
+	}
+	return uid, gid, nil
+}
+
+// setIDs drops the supplementary groups, then switches group and user; Go applies each to every
+// thread of the process
+func setIDs(uid, gid int) error {
+	if err := syscall.Setgroups([]int{gid}); err != nil {
+		return err
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return err
+	}
+	return syscall.Setuid(uid)
+}
@@ -0,0 +1,34 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package hardening
+
+import "errors"
+
+// errUnsupported is returned for the hardening settings that have no Windows equivalent
+var errUnsupported = errors.New("not supported on Windows")
+
+func setUmask(mask int) (int, error) {
+	return 0, errors.New("hardening.umask is not supported on Windows")
+}
+
+func setNoFile(limit uint64) error {
+	return errUnsupported
+}
+
+func disableCoreDumps() error {
+	return errUnsupported
+}
+
+func lockDir(dir, username, group string) error {
+	return errUnsupported
+}
+
+func lookupIDs(username, group string) (int, int, error) {
+	return 0, 0, errors.New("hardening.user is not supported on Windows")
+}
+
+func setIDs(uid, gid int) error {
+	return errUnsupported
+}
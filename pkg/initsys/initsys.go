@@ -0,0 +1,109 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package initsys hides which init system manages the host's services, so backends can check and
+// reload the services they depend on under systemd, OpenRC or runit alike
+package initsys
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Manager checks and controls services through an init system. Services are named without an
+// init-specific suffix, e.g. "systemd-resolved" or "avahi-daemon".
+type Manager interface {
+	// Name returns the init system, "systemd", "openrc", "runit" or "none"
+	Name() string
+	// Exists reports whether the service is installed
+	Exists(service string) bool
+	// IsActive reports whether the service is running
+	IsActive(service string) bool
+	// Reload asks a running service to reload its configuration
+	Reload(service string) error
+	// TryRestart restarts the service if it is running, and does nothing otherwise
+	TryRestart(service string) error
+}
+
+// Names lists the values accepted by New, besides "auto" and ""
+var Names = []string{"systemd", "openrc", "runit", "none"}
+
+// New returns the manager for an init system by name; "auto" or "" detects it
+func New(name string) (Manager, error) {
+	switch strings.ToLower(name) {
+	case "", "auto":
+		return Detect(), nil
+	case "systemd":
+		return systemd{}, nil
+	case "openrc":
+		return openrc{}, nil
+	case "runit":
+		return runit{}, nil
+	case "none":
+		return none{}, nil
+	}
+	return nil, fmt.Errorf("unknown init system %q (must be auto, %s)", name, strings.Join(Names, ", "))
+}
+
+// Detect returns the manager for the running init system. When nothing is recognised it falls back
+// to systemd if systemctl is installed, which is what zeroplex always assumed, and to none otherwise,
+// as in containers and on sysvinit or s6 hosts.
+func Detect() Manager {
+	switch {
+	case isDir("/run/systemd/system"):
+		return systemd{}
+	case isDir("/run/openrc"):
+		return openrc{}
+	case isDir("/run/runit") || isDir("/etc/runit/runsvdir"):
+		return runit{}
+	}
+	if _, err := exec.LookPath("systemctl"); err == nil {
+		return systemd{}
+	}
+	return none{}
+}
+
+var (
+	currentMu sync.Mutex
+	current   Manager
+)
+
+// Use selects the init system used by Current; "auto" or "" detects it
+func Use(name string) error {
+	m, err := New(name)
+	if err != nil {
+		return err
+	}
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	current = m
+	return nil
+}
+
+// Current returns the init system selected with Use, detecting it on first use otherwise
+func Current() Manager {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	if current == nil {
+		current = Detect()
+	}
+	return current
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// run executes an init system command, including its output in the error
+func run(name string, args ...string) (string, error) {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("%s %s failed: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
@@ -0,0 +1,98 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package initsys
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// listenFdsStart is the first file descriptor passed with socket activation, as SD_LISTEN_FDS_START
+const listenFdsStart = 3
+
+// activatedSocket is a listening socket passed by the service manager, by its FileDescriptorName=
+type activatedSocket struct {
+	name     string
+	listener net.Listener
+}
+
+var (
+	activatedOnce sync.Once
+	activatedMu   sync.Mutex
+	activated     []activatedSocket // not handed out yet
+)
+
+// loadActivated takes the sockets of $LISTEN_FDS, as sd_listen_fds(3) does, and unsets the
+// variables so the commands zeroplex runs don't take them for theirs
+func loadActivated() {
+	pid, pidErr := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	count, countErr := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	for _, name := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+		os.Unsetenv(name)
+	}
+	if pidErr != nil || countErr != nil || pid != os.Getpid() || count <= 0 {
+		return
+	}
+	for i := 0; i < count; i++ {
+		fd := listenFdsStart + i
+		unix.CloseOnExec(fd)
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+		file := os.NewFile(uintptr(fd), name)
+		// FileListener works on a duplicate, and fails for what isn't a listening stream socket
+		listener, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			continue
+		}
+		activated = append(activated, activatedSocket{name: name, listener: listener})
+	}
+}
+
+// ActivatedListener returns the listening socket the service manager passed with socket
+// activation for name: the one with FileDescriptorName=name, or else the first one listening on
+// network at address. Each socket is handed out once.
+func ActivatedListener(name, network, address string) (net.Listener, bool) {
+	activatedOnce.Do(loadActivated)
+	activatedMu.Lock()
+	defer activatedMu.Unlock()
+	match := -1
+	for i, socket := range activated {
+		if socket.listener.Addr().Network() != network {
+			continue
+		}
+		if socket.name == name {
+			match = i
+			break
+		}
+		if match == -1 && sameAddress(socket.listener.Addr(), address) {
+			match = i
+		}
+	}
+	if match == -1 {
+		return nil, false
+	}
+	listener := activated[match].listener
+	activated = append(activated[:match], activated[match+1:]...)
+	return listener, true
+}
+
+// sameAddress reports whether addr is address: the path of a Unix socket, or a TCP host and port
+func sameAddress(addr net.Addr, address string) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return addr.String() == address
+	}
+	want, err := net.ResolveTCPAddr("tcp", address)
+	return err == nil && tcp.Port == want.Port && tcp.IP.Equal(want.IP)
+}
@@ -0,0 +1,16 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !linux
+
+package initsys
+
+import (
+	"net"
+)
+
+// ActivatedListener never has a socket: there is no systemd socket activation on this platform
+func ActivatedListener(name, network, address string) (net.Listener, bool) {
+	return nil, false
+}
@@ -0,0 +1,147 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package initsys
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// systemd manages units with systemctl
+type systemd struct{}
+
+func (systemd) Name() string { return "systemd" }
+
+func unit(service string) string {
+	if strings.Contains(service, ".") {
+		return service
+	}
+	return service + ".service"
+}
+
+func (systemd) Exists(service string) bool {
+	// status exits 4 for unknown units, and 3 for installed units that are not running
+	_, err := run("systemctl", "status", unit(service))
+	return err == nil || !strings.Contains(err.Error(), "exit status 4")
+}
+
+func (systemd) IsActive(service string) bool {
+	out, err := run("systemctl", "is-active", unit(service))
+	return err == nil && strings.TrimSpace(out) == "active"
+}
+
+func (systemd) Reload(service string) error {
+	_, err := run("systemctl", "reload", unit(service))
+	return err
+}
+
+func (systemd) TryRestart(service string) error {
+	_, err := run("systemctl", "try-restart", unit(service))
+	return err
+}
+
+// openrc manages services with rc-service, as on Alpine, Gentoo and Artix
+type openrc struct{}
+
+func (openrc) Name() string { return "openrc" }
+
+func (openrc) Exists(service string) bool {
+	_, err := run("rc-service", "--exists", service)
+	return err == nil
+}
+
+func (openrc) IsActive(service string) bool {
+	_, err := run("rc-service", service, "status")
+	return err == nil
+}
+
+func (openrc) Reload(service string) error {
+	_, err := run("rc-service", service, "reload")
+	return err
+}
+
+func (openrc) TryRestart(service string) error {
+	_, err := run("rc-service", "--ifstarted", service, "restart")
+	return err
+}
+
+// runit manages services with sv, as on Void and Artix
+type runit struct{}
+
+func (runit) Name() string { return "runit" }
+
+// serviceDirs are where runit distributions link enabled services
+var serviceDirs = []string{"/var/service", "/etc/service", "/run/runit/service", "/etc/runit/runsvdir/default"}
+
+func (runit) Exists(service string) bool {
+	for _, dir := range serviceDirs {
+		if _, err := os.Stat(filepath.Join(dir, service)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (runit) IsActive(service string) bool {
+	out, err := run("sv", "status", service)
+	return err == nil && strings.HasPrefix(out, "run:")
+}
+
+func (runit) Reload(service string) error {
+	_, err := run("sv", "reload", service)
+	return err
+}
+
+func (r runit) TryRestart(service string) error {
+	if !r.IsActive(service) {
+		return nil
+	}
+	_, err := run("sv", "restart", service)
+	return err
+}
+
+// none is used without a recognised init system: services are found among the running processes,
+// and can't be reloaded or restarted
+type none struct{}
+
+func (none) Name() string { return "none" }
+
+func (n none) Exists(service string) bool {
+	if _, err := exec.LookPath(service); err == nil {
+		return true
+	}
+	return n.IsActive(service)
+}
+
+// IsActive looks for a process running the service's program
+func (none) IsActive(service string) bool {
+	cmdlines, _ := filepath.Glob("/proc/[0-9]*/cmdline")
+	for _, path := range cmdlines {
+		cmdline, err := os.ReadFile(path)
+		if err != nil || len(cmdline) == 0 {
+			continue
+		}
+		program, _, _ := bytes.Cut(cmdline, []byte{0})
+		if filepath.Base(string(program)) == service {
+			return true
+		}
+	}
+	return false
+}
+
+func (none) Reload(service string) error {
+	return fmt.Errorf("no init system to reload %s with; reload it by hand or set init_system", service)
+}
+
+func (n none) TryRestart(service string) error {
+	if !n.IsActive(service) {
+		return nil
+	}
+	return fmt.Errorf("no init system to restart %s with; restart it by hand or set init_system", service)
+}
@@ -0,0 +1,26 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !linux
+
+package initsys
+
+import (
+	"time"
+)
+
+// Notify does nothing: there is no systemd on this platform
+func Notify(state string) (bool, error) {
+	return false, nil
+}
+
+// WatchdogInterval is never set on this platform
+func WatchdogInterval() (time.Duration, bool) {
+	return 0, false
+}
+
+// MonotonicUsec is unused on this platform
+func MonotonicUsec() int64 {
+	return 0
+}
@@ -0,0 +1,54 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+// BufferSize bounds the log records kept in memory for the control API
+const BufferSize = 1000
+
+// Record is a logged line as kept in the in-memory buffer
+type Record struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Scope   string    `json:"scope,omitempty"`
+	Message string    `json:"message"`
+}
+
+// buffer is a ring of the last BufferSize records, written whatever the configured log output
+var buffer struct {
+	mu      sync.Mutex
+	records []Record
+	next    int
+}
+
+// remember adds a logged line to the buffer, overwriting the oldest once it is full
+func remember(level, scope, message string) {
+	rec := Record{Time: time.Now(), Level: level, Scope: scope, Message: message}
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+	if len(buffer.records) < BufferSize {
+		buffer.records = append(buffer.records, rec)
+		return
+	}
+	buffer.records[buffer.next] = rec
+	buffer.next = (buffer.next + 1) % BufferSize
+}
+
+// Tail returns up to the last n buffered records, oldest first; n <= 0 returns all of them
+func Tail(n int) []Record {
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+	ordered := make([]Record, 0, len(buffer.records))
+	ordered = append(ordered, buffer.records[buffer.next:]...)
+	ordered = append(ordered, buffer.records[:buffer.next]...)
+	if n > 0 && n < len(ordered) {
+		ordered = ordered[len(ordered)-n:]
+	}
+	return ordered
+}
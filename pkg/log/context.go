@@ -0,0 +1,51 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package log
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+type runIDKey struct{}
+
+// NewRunID returns a short random ID that identifies one reconcile run in the logs
+func NewRunID() string {
+	b := make([]byte, 3)
+	if _, err := rand.Read(b); err != nil {
+		return "000000"
+	}
+	return hex.EncodeToString(b)
+}
+
+// WithRunID returns a context carrying the run ID
+func WithRunID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, runIDKey{}, id)
+}
+
+// RunID returns the run ID carried by ctx, or ""
+func RunID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(runIDKey{}).(string)
+	return id
+}
+
+// WithContext returns a logger that tags every line with the run ID carried by ctx, so the lines
+// of concurrent runs can be told apart. Without a run ID the logger itself is returned.
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	id := RunID(ctx)
+	if id == "" {
+		return l
+	}
+	tagged := *l
+	tagged.prefix = l.prefix + " [run=" + id + "]"
+	if l.prefix == "" {
+		tagged.prefix = "[run=" + id + "]"
+	}
+	return &tagged
+}
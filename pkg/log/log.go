@@ -11,6 +11,7 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -35,7 +36,12 @@ const (
 	LogLevelNone
 )
 
-var globalLogLevel LogLevel = LogLevelVerbose
+// globalLogLevel holds the LogLevel of loggers created without a level of their own
+var globalLogLevel atomic.Int32
+
+func init() {
+	globalLogLevel.Store(int32(LogLevelVerbose))
+}
 
 func ParseLogLevel(levelStr string) LogLevel {
 	switch strings.ToLower(levelStr) {
@@ -66,12 +72,12 @@ func NewLogger(prefix, logLevel string) *Logger {
 	var level LogLevel
 	var isOverride bool
 	if logLevel == "" {
-		level = globalLogLevel
+		level = LogLevel(globalLogLevel.Load())
 		isOverride = false
 	} else {
 		level = ParseLogLevel(logLevel)
 		if level == LogLevelNone {
-			level = globalLogLevel
+			level = LogLevel(globalLogLevel.Load())
 			isOverride = false
 		} else {
 			isOverride = true
@@ -84,23 +90,59 @@ func NewLogger(prefix, logLevel string) *Logger {
 	}
 }
 
-// For compatibility, alias NewScopedLogger to NewLogger
-var NewScopedLogger = NewLogger
+type scopeKey struct {
+	prefix string
+	level  LogLevel
+}
+
+// scopedLoggers caches loggers by scope and level. Loggers are immutable once created, so one
+// instance is shared by every caller asking for the same scope.
+var (
+	scopedMu      sync.RWMutex
+	scopedLoggers = map[scopeKey]*Logger{}
+)
+
+// NewScopedLogger returns the logger for a scope such as "[dns]". The same scope and level always
+// return the same logger, so hot paths can ask for one on every call without allocating. An empty
+// or unknown level follows the global level set with SetLevel.
+func NewScopedLogger(prefix, logLevel string) *Logger {
+	key := scopeKey{prefix: prefix, level: ParseLogLevel(logLevel)}
+	scopedMu.RLock()
+	logger, ok := scopedLoggers[key]
+	scopedMu.RUnlock()
+	if ok {
+		return logger
+	}
+	scopedMu.Lock()
+	defer scopedMu.Unlock()
+	if logger, ok := scopedLoggers[key]; ok {
+		return logger
+	}
+	logger = NewLogger(prefix, logLevel)
+	scopedLoggers[key] = logger
+	return logger
+}
 
-func updateGlobalLogLevel(levelStr string) {
-	globalLogLevel = ParseLogLevel(levelStr)
-	if globalLogLevel == LogLevelNone {
-		globalLogLevel = LogLevelVerbose
+// SetLevel sets the global level, used by every logger without a level of its own
+func SetLevel(levelStr string) {
+	level := ParseLogLevel(levelStr)
+	if level == LogLevelNone {
+		level = LogLevelVerbose
 	}
+	globalLogLevel.Store(int32(level))
 }
 
 func (l *Logger) shouldLog(messageLevel LogLevel) bool {
+	if !l.isOverride {
+		return messageLevel <= LogLevel(globalLogLevel.Load())
+	}
 	return messageLevel <= l.level
 }
 
 func (l *Logger) Debug(format string, args ...interface{}) {
 	if l.shouldLog(LogLevelDebug) {
 		message := fmt.Sprintf(format, args...)
+		remember(LevelDebug, l.prefix, message)
 		levelStr := "   DEBUG"
 		if l.prefix != "" {
 			message = fmt.Sprintf("%s %s", l.prefix, message)
@@ -118,6 +160,7 @@ func (l *Logger) Debug(format string, args ...interface{}) {
 func (l *Logger) Trace(format string, args ...interface{}) {
 	if l.shouldLog(LogLevelTrace) {
 		message := fmt.Sprintf(format, args...)
+		remember(LevelTrace, l.prefix, message)
 		levelStr := "   TRACE"
 		if l.prefix != "" {
 			message = fmt.Sprintf("%s %s", l.prefix, message)
@@ -135,6 +178,7 @@ func (l *Logger) Trace(format string, args ...interface{}) {
 func (l *Logger) Verbose(format string, args ...interface{}) {
 	if l.shouldLog(LogLevelVerbose) {
 		message := fmt.Sprintf(format, args...)
+		remember(LevelVerbose, l.prefix, message)
 		levelStr := " VERBOSE"
 		if l.prefix != "" {
 			message = fmt.Sprintf("%s %s", l.prefix, message)
@@ -152,6 +196,7 @@ func (l *Logger) Verbose(format string, args ...interface{}) {
 func (l *Logger) Info(format string, args ...interface{}) {
 	if l.shouldLog(LogLevelInfo) {
 		message := fmt.Sprintf(format, args...)
+		remember(LevelInfo, l.prefix, message)
 		levelStr := "    INFO"
 		if l.prefix != "" {
 			message = fmt.Sprintf("%s %s", l.prefix, message)
@@ -169,6 +214,7 @@ func (l *Logger) Info(format string, args ...interface{}) {
 func (l *Logger) Warn(format string, args ...interface{}) {
 	if l.shouldLog(LogLevelWarn) {
 		message := fmt.Sprintf(format, args...)
+		remember(LevelWarn, l.prefix, message)
 		levelStr := "    WARN"
 		if l.prefix != "" {
 			message = fmt.Sprintf("%s %s", l.prefix, message)
@@ -186,6 +232,7 @@ func (l *Logger) Warn(format string, args ...interface{}) {
 func (l *Logger) Error(format string, args ...interface{}) {
 	if l.shouldLog(LogLevelError) {
 		message := fmt.Sprintf(format, args...)
+		remember(LevelError, l.prefix, message)
 		levelStr := "   ERROR"
 		if l.prefix != "" {
 			message = fmt.Sprintf("%s %s", l.prefix, message)
@@ -0,0 +1,277 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Labels are the key/value pairs attached to a single sample
+type Labels map[string]string
+
+type metricType string
+
+const (
+	typeCounter   metricType = "counter"
+	typeGauge     metricType = "gauge"
+	typeHistogram metricType = "histogram"
+)
+
+// DefaultBuckets are the histogram buckets (in seconds) used when none are given
+var DefaultBuckets = []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+type sample struct {
+	labels    Labels
+	value     float64
+	histogram *histogram
+}
+
+type family struct {
+	name    string
+	help    string
+	kind    metricType
+	samples map[string]*sample
+}
+
+// Registry holds metric families in memory until they are written out
+type Registry struct {
+	mu          sync.Mutex
+	families    map[string]*family
+	constLabels Labels
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+var defaultRegistry = NewRegistry()
+
+// Default returns the process-wide registry
+func Default() *Registry {
+	return defaultRegistry
+}
+
+func labelKey(labels Labels) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%q", k, labels[k]))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (r *Registry) sample(name, help string, kind metricType, labels Labels) *sample {
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{name: name, help: help, kind: kind, samples: make(map[string]*sample)}
+		r.families[name] = f
+	}
+	key := labelKey(labels)
+	s, ok := f.samples[key]
+	if !ok {
+		copied := Labels{}
+		for k, v := range labels {
+			copied[k] = v
+		}
+		s = &sample{labels: copied}
+		f.samples[key] = s
+	}
+	return s
+}
+
+// Add increments a counter by delta
+func (r *Registry) Add(name, help string, delta float64, labels Labels) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sample(name, help, typeCounter, labels).value += delta
+}
+
+// Set sets a gauge to value
+func (r *Registry) Set(name, help string, value float64, labels Labels) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sample(name, help, typeGauge, labels).value = value
+}
+
+// Observe records value into a histogram, creating it with buckets on first use
+func (r *Registry) Observe(name, help string, value float64, buckets []float64, labels Labels) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.sample(name, help, typeHistogram, labels)
+	if s.histogram == nil {
+		if len(buckets) == 0 {
+			buckets = DefaultBuckets
+		}
+		s.histogram = &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+	}
+	h := s.histogram
+	for i, b := range h.buckets {
+		if value <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+// Reset drops every sample of a family, e.g. before republishing a gauge set that may have shrunk
+func (r *Registry) Reset(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if f, ok := r.families[name]; ok {
+		f.samples = make(map[string]*sample)
+	}
+}
+
+// SetConstLabels attaches labels to every sample when written out; a sample's own labels take precedence
+func (r *Registry) SetConstLabels(labels Labels) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.constLabels = Labels{}
+	for k, v := range labels {
+		r.constLabels[k] = v
+	}
+}
+
+// withConstLabels returns labels merged over the registry's constant labels; r.mu must be held
+func (r *Registry) withConstLabels(labels Labels) Labels {
+	if len(r.constLabels) == 0 {
+		return labels
+	}
+	merged := make(Labels, len(r.constLabels)+len(labels))
+	for k, v := range r.constLabels {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return merged
+}
+
+// Inc increments a counter on the default registry
+func Inc(name, help string, labels Labels) {
+	defaultRegistry.Add(name, help, 1, labels)
+}
+
+// Set sets a gauge on the default registry
+func Set(name, help string, value float64, labels Labels) {
+	defaultRegistry.Set(name, help, value, labels)
+}
+
+// Observe records a histogram observation on the default registry
+func Observe(name, help string, value float64, labels Labels) {
+	defaultRegistry.Observe(name, help, value, nil, labels)
+}
+
+// Reset drops every sample of a family on the default registry
+func Reset(name string) {
+	defaultRegistry.Reset(name)
+}
+
+// SetConstLabels sets the labels attached to every sample of the default registry
+func SetConstLabels(labels Labels) {
+	defaultRegistry.SetConstLabels(labels)
+}
+
+func formatLabels(labels Labels, extra ...string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys)+len(extra)/2)
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=\"%s\"", k, escapeLabel(labels[k])))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		parts = append(parts, fmt.Sprintf("%s=\"%s\"", extra[i], escapeLabel(extra[i+1])))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func escapeLabel(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, "\n", `\n`)
+	return strings.ReplaceAll(v, `"`, `\"`)
+}
+
+func formatValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return fmt.Sprintf("%g", v)
+}
+
+// WriteOpenMetrics writes every family in OpenMetrics text exposition format
+func (r *Registry) WriteOpenMetrics(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		f := r.families[name]
+		// OpenMetrics names counter families without the _total suffix
+		familyName := name
+		if f.kind == typeCounter {
+			familyName = strings.TrimSuffix(name, "_total")
+		}
+		fmt.Fprintf(&b, "# TYPE %s %s\n", familyName, f.kind)
+		if f.help != "" {
+			fmt.Fprintf(&b, "# HELP %s %s\n", familyName, f.help)
+		}
+		keys := make([]string, 0, len(f.samples))
+		for k := range f.samples {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			s := f.samples[k]
+			labels := r.withConstLabels(s.labels)
+			switch f.kind {
+			case typeHistogram:
+				h := s.histogram
+				for i, bound := range h.buckets {
+					fmt.Fprintf(&b, "%s_bucket%s %d\n", name, formatLabels(labels, "le", formatValue(bound)), h.counts[i])
+				}
+				fmt.Fprintf(&b, "%s_bucket%s %d\n", name, formatLabels(labels, "le", "+Inf"), h.count)
+				fmt.Fprintf(&b, "%s_sum%s %s\n", name, formatLabels(labels), formatValue(h.sum))
+				fmt.Fprintf(&b, "%s_count%s %d\n", name, formatLabels(labels), h.count)
+			case typeCounter:
+				fmt.Fprintf(&b, "%s_total%s %s\n", familyName, formatLabels(labels), formatValue(s.value))
+			default:
+				fmt.Fprintf(&b, "%s%s %s\n", name, formatLabels(labels), formatValue(s.value))
+			}
+		}
+	}
+	b.WriteString("# EOF\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
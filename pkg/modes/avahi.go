@@ -0,0 +1,333 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/initsys"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/utils"
+
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+// How an enabled multicast_dns is reconciled with a running avahi-daemon (features.mdns_conflict)
+const (
+	MDNSConflictWarn  = "warn"  // enable resolved mDNS anyway and warn about dueling responders
+	MDNSConflictSkip  = "skip"  // leave mDNS on ZeroTier interfaces to Avahi
+	MDNSConflictAvahi = "avahi" // add ZeroTier interfaces to Avahi's deny-interfaces, then enable resolved mDNS
+	MDNSConflictOff   = "off"   // don't check for Avahi
+)
+
+// AvahiConfigFile is the avahi-daemon configuration updated in "avahi" conflict mode
+var AvahiConfigFile = "/etc/avahi/avahi-daemon.conf"
+
+// avahiWarned remembers the warnings already logged, so a poll loop doesn't repeat them
+var avahiWarned sync.Map
+
+// avahiRunning reports whether avahi-daemon is active
+func avahiRunning() bool {
+	if initsys.Current().IsActive("avahi-daemon") {
+		return true
+	}
+	_, err := os.Stat("/run/avahi-daemon/pid")
+	return err == nil
+}
+
+// resolveMDNSConflict returns whether resolved mDNS should be enabled on the ZeroTier interfaces,
+// taking a running avahi-daemon and mdns_advertise into account. A network whose multicast_dns
+// override turns mDNS on is held to the same policy.
+func (b *BaseMode) resolveMDNSConflict(networks *service.GetNetworksResponse) bool {
+	features := b.cfg.Default.Features
+	wanted := features.MulticastDNS || features.MDNSAdvertise
+	if !wanted && !overridesWantMDNS(networks) {
+		return false
+	}
+	allowed := b.mdnsAllowed(networks)
+	mdnsSuppressed.Store(!allowed)
+	return allowed && wanted
+}
+
+// mdnsAllowed applies features.mdns_conflict, returning whether mDNS may be enabled
+func (b *BaseMode) mdnsAllowed(networks *service.GetNetworksResponse) bool {
+	features := b.cfg.Default.Features
+	logger := log.NewScopedLogger(fmt.Sprintf("[modes/%s/mdns]", b.mode), b.cfg.Default.Log.Level)
+	policy := strings.ToLower(features.MDNSConflict)
+	if policy == "" {
+		policy = MDNSConflictWarn
+	}
+	if policy == MDNSConflictOff || !avahiRunning() {
+		if features.MDNSAdvertise {
+			checkResolvedAdvertises(logger)
+		}
+		return true
+	}
+
+	ifaces := ztInterfaceNames(networks)
+	switch policy {
+	case MDNSConflictSkip:
+		if features.MDNSAdvertise {
+			// Avahi owns mDNS here, so it is the one to publish the host name
+			b.updateAvahi(ifaces, false, logger)
+			return false
+		}
+		logger.Warn("avahi-daemon is running; not enabling systemd-resolved mDNS on %v (mdns_conflict: skip)", ifaces)
+		return false
+	case MDNSConflictAvahi:
+		if !b.updateAvahi(ifaces, true, logger) {
+			return false
+		}
+		if features.MDNSAdvertise {
+			checkResolvedAdvertises(logger)
+		}
+		return true
+	default:
+		// Warn once per set of interfaces rather than on every poll
+		warnOnce(strings.Join(ifaces, ","), func() {
+			logger.Warn("avahi-daemon is running and mDNS is enabled: both will answer mDNS on %v. Set features.mdns_conflict to 'skip' or 'avahi' to avoid dueling responders", ifaces)
+		})
+		return true
+	}
+}
+
+// updateAvahi denies (or, to let Avahi publish the host name, allows) the ZeroTier interfaces in
+// Avahi and restarts it if its configuration changed. It returns false if the update failed.
+func (b *BaseMode) updateAvahi(ifaces []string, deny bool, logger *log.Logger) bool {
+	action := "allow"
+	if deny {
+		action = "deny"
+	}
+	if b.dryRun {
+		logger.Info("[dry-run] Would %s %v in %s and restart avahi-daemon", action, ifaces, AvahiConfigFile)
+		return true
+	}
+	changed, err := updateAvahiInterfaces(AvahiConfigFile, ifaces, deny)
+	if err != nil {
+		logger.Warn("Failed to %s %v in %s: %v", action, ifaces, AvahiConfigFile, err)
+		return false
+	}
+	if !deny && !avahiPublishesHostname(AvahiConfigFile) {
+		warnOnce("avahi-publish", func() {
+			logger.Warn("mdns_advertise: %s sets publish-hostname=no, so Avahi will not advertise this host", AvahiConfigFile)
+		})
+	}
+	if !changed {
+		return true
+	}
+	logger.Info("Updated %s to %s %v", AvahiConfigFile, action, ifaces)
+	if err := initsys.Current().TryRestart("avahi-daemon"); err != nil {
+		logger.Warn("Failed to restart avahi-daemon: %v", err)
+	}
+	return true
+}
+
+// checkResolvedAdvertises warns once if systemd-resolved's global mDNS setting only resolves,
+// in which case the per-link setting alone does not publish the host name
+func checkResolvedAdvertises(logger *log.Logger) {
+	out, err := utils.ExecuteCommand("resolvectl", "status")
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "Protocols:") {
+			continue
+		}
+		if !strings.Contains(line, "+mDNS") {
+			warnOnce("resolved-mdns", func() {
+				logger.Warn("mdns_advertise: systemd-resolved's global mDNS setting does not respond (%s); set MulticastDNS=yes in resolved.conf to publish this host", line)
+			})
+		}
+		// The first Protocols line belongs to the Global section
+		return
+	}
+}
+
+// avahiPublishesHostname reports whether an avahi-daemon.conf leaves publish-hostname enabled
+func avahiPublishesHostname(path string) bool {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return true
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) == "publish-hostname" {
+			return strings.TrimSpace(parts[1]) != "no"
+		}
+	}
+	return true
+}
+
+// warnOnce runs warn the first time key is seen in this process
+func warnOnce(key string, warn func()) {
+	if _, warned := avahiWarned.LoadOrStore(key, struct{}{}); !warned {
+		warn()
+	}
+}
+
+// ztInterfaceNames returns the sorted interface names of the given networks
+func ztInterfaceNames(networks *service.GetNetworksResponse) []string {
+	var names []string
+	if networks == nil || networks.JSON200 == nil {
+		return names
+	}
+	for _, network := range *networks.JSON200 {
+		if network.PortDeviceName != nil && *network.PortDeviceName != "" {
+			names = append(names, *network.PortDeviceName)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// updateAvahiInterfaces edits deny-interfaces and allow-interfaces in the [server] section of an
+// avahi-daemon.conf, keeping the rest of the file as is. With deny every iface ends up denied (and
+// not allowed); otherwise no iface is denied and, if an allow list exists, every iface is allowed.
+func updateAvahiInterfaces(path string, ifaces []string, deny bool) (bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+
+	serverStart, serverEnd := -1, len(lines)
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "[") {
+			continue
+		}
+		if serverStart >= 0 {
+			serverEnd = i
+			break
+		}
+		if strings.EqualFold(trimmed, "[server]") {
+			serverStart = i
+		}
+	}
+	if serverStart < 0 {
+		lines = append(lines, "", "[server]")
+		serverStart, serverEnd = len(lines)-1, len(lines)
+	}
+
+	parseList := func(line string) []string {
+		var out []string
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) < 2 {
+			return out
+		}
+		for _, item := range strings.Split(parts[1], ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+		return out
+	}
+
+	lineOf := map[string]int{"deny-interfaces": -1, "allow-interfaces": -1}
+	for i := serverStart + 1; i < serverEnd; i++ {
+		key := strings.TrimSpace(strings.SplitN(lines[i], "=", 2)[0])
+		if _, ok := lineOf[key]; ok {
+			lineOf[key] = i
+		}
+	}
+
+	// add puts ifaces into the list on key, creating it if needed; remove takes them out of an existing one
+	changed := false
+	add := func(key string) {
+		var list []string
+		if lineOf[key] >= 0 {
+			list = parseList(lines[lineOf[key]])
+		}
+		grown := false
+		for _, iface := range ifaces {
+			if !utils.Contains(list, iface) {
+				list = append(list, iface)
+				grown = true
+			}
+		}
+		if !grown {
+			return
+		}
+		changed = true
+		next := key + "=" + strings.Join(list, ",")
+		if lineOf[key] >= 0 {
+			lines[lineOf[key]] = next
+			return
+		}
+		lines = append(lines[:serverStart+1], append([]string{next}, lines[serverStart+1:]...)...)
+		for k, i := range lineOf {
+			if i > serverStart {
+				lineOf[k] = i + 1
+			}
+		}
+		lineOf[key] = serverStart + 1
+	}
+	remove := func(key string) {
+		if lineOf[key] < 0 {
+			return
+		}
+		list := parseList(lines[lineOf[key]])
+		var kept []string
+		for _, item := range list {
+			if !utils.Contains(ifaces, item) {
+				kept = append(kept, item)
+			}
+		}
+		if len(kept) == len(list) {
+			return
+		}
+		changed = true
+		if len(kept) > 0 {
+			lines[lineOf[key]] = key + "=" + strings.Join(kept, ",")
+			return
+		}
+		// An empty list is dropped rather than left as "key="
+		at := lineOf[key]
+		lines = append(lines[:at], lines[at+1:]...)
+		for k, i := range lineOf {
+			if i > at {
+				lineOf[k] = i - 1
+			}
+		}
+		lineOf[key] = -1
+	}
+
+	if deny {
+		// An allow list that names a ZeroTier interface would override the deny list
+		remove("allow-interfaces")
+		add("deny-interfaces")
+	} else {
+		remove("deny-interfaces")
+		if lineOf["allow-interfaces"] >= 0 && len(parseList(lines[lineOf["allow-interfaces"]])) > 0 {
+			add("allow-interfaces")
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".avahi-daemon-*.conf")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+	if info, err := os.Stat(path); err == nil {
+		os.Chmod(tmp.Name(), info.Mode().Perm())
+	}
+	return true, os.Rename(tmp.Name(), path)
+}
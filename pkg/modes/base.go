@@ -7,29 +7,37 @@ package modes
 import (
 	"zeroplex/pkg/client"
 	"zeroplex/pkg/config"
+	"zeroplex/pkg/dns"
+	"zeroplex/pkg/events"
+	"zeroplex/pkg/exitcode"
 	"zeroplex/pkg/filters"
 	"zeroplex/pkg/log"
 	"zeroplex/pkg/utils"
 
-	"bytes"
 	"context"
 	"fmt"
-	"io"
+	"sort"
+	"strings"
+	"sync/atomic"
 
 	"github.com/zerotier/go-zerotier-one/service"
 )
 
 // BaseMode provides common functionality for all mode implementations
 type BaseMode struct {
-	cfg    config.Config
-	dryRun bool
-	mode   string
+	cfg      config.Config
+	zt       *client.Client
+	dryRun   bool
+	mode     string
+	joined   int // networks ZeroTier reported before the filters, set by ProcessNetworks
+	selected int // networks the filters left, of every mode, set by ProcessNetworks
 }
 
-// NewBaseMode creates a new base mode instance
-func NewBaseMode(cfg config.Config, dryRun bool, mode string) *BaseMode {
+// NewBaseMode creates a new base mode instance querying ZeroTier through zt
+func NewBaseMode(cfg config.Config, zt *client.Client, dryRun bool, mode string) *BaseMode {
 	return &BaseMode{
 		cfg:    cfg,
+		zt:     zt,
 		dryRun: dryRun,
 		mode:   mode,
 	}
@@ -37,54 +45,22 @@ func NewBaseMode(cfg config.Config, dryRun bool, mode string) *BaseMode {
 
 // FetchNetworks retrieves networks from ZeroTier API
 func (b *BaseMode) FetchNetworks(ctx context.Context) (*service.GetNetworksResponse, error) {
-	logger := log.NewScopedLogger("[api]", b.cfg.Default.Log.Level)
+	logger := log.NewScopedLogger("[api]", b.cfg.Default.Log.Level).WithContext(ctx)
 
-	// Create API client
-	sAPI, err := client.NewServiceAPI(b.cfg.Default.Client.TokenFile)
-	if err != nil {
-		logger.Error("Failed to create service API client: %v", err)
-		return nil, fmt.Errorf("failed to create service API client: %w", err)
-	}
-
-	// Create ZeroTier client
-	ztBaseURL := fmt.Sprintf("%s:%d", b.cfg.Default.Client.Host, b.cfg.Default.Client.Port)
-	logger.Debug("Creating ZeroTier client with URL: %s", ztBaseURL)
-	ztClient, err := service.NewClient(ztBaseURL, service.WithHTTPClient(sAPI))
-	if err != nil {
-		logger.Error("Failed to create ZeroTier client: %v", err)
-		return nil, fmt.Errorf("failed to create ZeroTier client: %w", err)
+	if baseURL, err := b.zt.BaseURL(); err == nil {
+		logger.Trace("Making API request to fetch networks (GET %s/network)", baseURL)
 	}
-
-	// Fetch networks
-	logger.Trace("Making API request to fetch networks (GET %s/networks)", ztBaseURL)
-	resp, err := ztClient.GetNetworks(ctx)
+	networks, err := b.zt.Refresh(ctx)
 	if err != nil {
-		logger.Error("Failed to get networks: %v (could not access the ZeroTier API server)", err)
-		return nil, fmt.Errorf("failed to get networks: %w", err)
+		logger.Error("%v (could not access the ZeroTier API server)", err)
+		return nil, err
 	}
 
 	// Log raw response body (truncate if very large)
-	var respBodyBytes []byte
-	if resp != nil && resp.Body != nil {
-		respBodyBytes, err = io.ReadAll(resp.Body)
-		if err != nil {
-			logger.Error("Failed to read API response body: %v", err)
-			return nil, fmt.Errorf("failed to read API response body: %w", err)
-		}
-		if len(respBodyBytes) > 2048 {
-			logger.Trace("Raw API response (truncated to 2KB): %s...", string(respBodyBytes[:2048]))
-		} else {
-			logger.Trace("Raw API response: %s", string(respBodyBytes))
-		}
-		// Replace resp.Body so it can be read again
-		resp.Body = io.NopCloser(bytes.NewReader(respBodyBytes))
-	}
-
-	logger.Trace("Parsing API response")
-	networks, err := service.ParseGetNetworksResponse(resp)
-	if err != nil {
-		logger.Error("Failed to parse networks response: %v", err)
-		return nil, fmt.Errorf("failed to parse networks response: %w", err)
+	if len(networks.Body) > 2048 {
+		logger.Trace("Raw API response (truncated to 2KB): %s...", string(networks.Body[:2048]))
+	} else {
+		logger.Trace("Raw API response: %s", string(networks.Body))
 	}
 
 	return networks, nil
@@ -96,8 +72,8 @@ func (b *BaseMode) ApplyFilters(networks *service.GetNetworksResponse) {
 }
 
 // LogNetworkDiscovery logs the network discovery process
-func (b *BaseMode) LogNetworkDiscovery(networks *service.GetNetworksResponse, preFilter bool) {
-	logger := log.NewScopedLogger(fmt.Sprintf("[modes/%s]", b.mode), b.cfg.Default.Log.Level)
+func (b *BaseMode) LogNetworkDiscovery(ctx context.Context, networks *service.GetNetworksResponse, preFilter bool) {
+	logger := log.NewScopedLogger(fmt.Sprintf("[modes/%s]", b.mode), b.cfg.Default.Log.Level).WithContext(ctx)
 
 	if preFilter {
 		logger.Debug("Retrieved %d networks from ZeroTier", len(*networks.JSON200))
@@ -143,6 +119,16 @@ func (b *BaseMode) LogNetworkDiscovery(networks *service.GetNetworksResponse, pr
 // LogConfiguration logs the configuration details
 func (b *BaseMode) LogConfiguration() {
 	logger := log.NewScopedLogger("[config]", b.cfg.Default.Log.Level)
+	if b.cfg.Default.Client.Token != "" {
+		logger.Debug("Host: %s, Port: %d, Token: inline", b.cfg.Default.Client.Host, b.cfg.Default.Client.Port)
+		return
+	}
+	if source := b.cfg.Default.Client.TokenSource; source != "" {
+		scheme, _, _ := strings.Cut(source, "://")
+		logger.Debug("Host: %s, Port: %d, TokenSource: %s://…",
+			b.cfg.Default.Client.Host, b.cfg.Default.Client.Port, scheme)
+		return
+	}
 	logger.Debug("Host: %s, Port: %d, TokenFile: %s",
 		b.cfg.Default.Client.Host, b.cfg.Default.Client.Port, b.cfg.Default.Client.TokenFile)
 }
@@ -202,9 +188,85 @@ func (b *BaseMode) GetDNSDomain(network service.Network) string {
 	return *network.Dns.Domain
 }
 
+// GetSearchDomains returns the sorted routing domains for a network, including reverse domains if requested
+func (b *BaseMode) GetSearchDomains(network service.Network, addReverseDomains bool) []string {
+	return searchDomains(network, addReverseDomains, b.cfg.Default.Features.ExtraSearchDomains)
+}
+
+// searchDomains returns the sorted routing domains zeroplex configures for a network: those of
+// networkDomains, less the ones left to another DNS manager by the coordination policy
+func searchDomains(network service.Network, addReverseDomains bool, extra []string) []string {
+	all := networkDomains(network, addReverseDomains, extra)
+	keys := all[:0:0]
+	for _, key := range all {
+		if !yielded(key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// networkDomains returns the sorted routing domains for a network: its DNS domain, reverse domains
+// if requested, and the extra search domains (its own under networks:, or extra) with %domain%,
+// %interface%, %network_id% and %hostname% expanded. Extra domains referencing a variable the
+// network has no value for are skipped.
+func networkDomains(network service.Network, addReverseDomains bool, extra []string) []string {
+	extra = extraSearchDomainsOf(network, extra)
+	search := map[string]struct{}{}
+	domain := ""
+	if network.Dns != nil && network.Dns.Domain != nil {
+		domain = *network.Dns.Domain
+	}
+	if domain != "" {
+		search[domain] = struct{}{}
+	}
+	if addReverseDomains {
+		for _, reverse := range dns.CalculateReverseDomains(network.AssignedAddresses) {
+			search[reverse] = struct{}{}
+		}
+	}
+	if len(extra) > 0 {
+		vars := utils.NetworkVars(stringValue(network.PortDeviceName), stringValue(network.Id), domain)
+		for _, entry := range extra {
+			if expanded, ok := vars.ExpandStrict(entry); ok && expanded != "" {
+				search[expanded] = struct{}{}
+			}
+		}
+	}
+	keys := make([]string, 0, len(search))
+	for key := range search {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func stringValue(ptr *string) string {
+	if ptr == nil {
+		return ""
+	}
+	return *ptr
+}
+
+// apiFailing is set while the ZeroTier API is failing, so api_error is published once per outage
+var apiFailing atomic.Bool
+
+// reportAPIHealth publishes api_error when fetching networks starts failing and api_recovered when it works again
+func (b *BaseMode) reportAPIHealth(err error) {
+	if err != nil {
+		if !apiFailing.Swap(true) {
+			events.Publish(events.Event{Type: events.TypeAPIError, Mode: b.mode, Message: err.Error()})
+		}
+		return
+	}
+	if apiFailing.Swap(false) {
+		events.Publish(events.Event{Type: events.TypeAPIRecovered, Mode: b.mode, Message: "ZeroTier API reachable again"})
+	}
+}
+
 // ProcessNetworks handles the common network processing workflow
 func (b *BaseMode) ProcessNetworks(ctx context.Context) (*service.GetNetworksResponse, error) {
-	logger := log.NewScopedLogger(fmt.Sprintf("[modes/%s]", b.mode), b.cfg.Default.Log.Level)
+	logger := log.NewScopedLogger(fmt.Sprintf("[modes/%s]", b.mode), b.cfg.Default.Log.Level).WithContext(ctx)
 
 	// Log configuration
 	b.LogConfiguration()
@@ -212,19 +274,36 @@ func (b *BaseMode) ProcessNetworks(ctx context.Context) (*service.GetNetworksRes
 	// Fetch networks
 	logger.Debug("Fetching networks from ZeroTier API")
 	networks, err := b.FetchNetworks(ctx)
+	b.reportAPIHealth(err)
 	if err != nil {
-		return nil, err
+		return nil, exitcode.Wrap(exitcode.APIUnreachable, err)
 	}
 
+	// Follow interfaces renamed since ZeroTier created them or since the last run
+	followRenames(networks, b.dryRun, logger)
+
 	// Log discovery (before filtering)
-	b.LogNetworkDiscovery(networks, true)
+	b.LogNetworkDiscovery(ctx, networks, true)
+	reported := len(*networks.JSON200)
 
 	// Apply filters
 	logger.Trace("Applying network filters")
 	b.ApplyFilters(networks)
+	b.joined, b.selected = reported, len(*networks.JSON200)
+	countSummary(func(s *RunSummary) { s.Networks, s.Filtered = reported, len(*networks.JSON200) })
+	reportEmptyFilter(b, logger)
+
+	// Networks set to another mode under networks: are left to a run of that mode
+	applyNetworkOverrides(networks, b.cfg.Default, logger)
+	*networks = *networksOfMode(networks, b.cfg.Default, b.mode)
+	holdDownNetworks(networks, b.cfg.Default, logger)
+	orderServersByRTT(ctx, networks, b.cfg.Default.ServerOrder, logger)
 
 	// Log discovery (after filtering)
-	b.LogNetworkDiscovery(networks, false)
+	b.LogNetworkDiscovery(ctx, networks, false)
+
+	// Settle the domains other DNS managers route too before any mode computes its domains
+	b.coordinate(networks, logger)
 
 	// Validate networks
 	for _, network := range *networks.JSON200 {
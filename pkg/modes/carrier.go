@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/metrics"
+
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// SysClassNet is where the kernel reports the carrier of each interface
+var SysClassNet = "/sys/class/net"
+
+// carrierPoll is how often the carrier of the interfaces is checked while waiting for it
+const carrierPoll = 250 * time.Millisecond
+
+// carrierAwaited is set once a networkd reload of this process waited for carrier, see awaitCarrier
+var carrierAwaited atomic.Bool
+
+// hasCarrier reports whether the kernel reports carrier on iface
+func hasCarrier(iface string) bool {
+	data, err := os.ReadFile(filepath.Join(SysClassNet, iface, "carrier"))
+	return err == nil && strings.TrimSpace(string(data)) == "1"
+}
+
+// awaitCarrier defers the first networkd reload of the process, the one at boot, until every one
+// of interfaces reports carrier or networkd.wait_for_carrier has passed. A reload while a
+// ZeroTier interface is still coming up can make networkd restart the DHCP negotiation of the
+// other links. Later reloads don't wait, so a link that stays down doesn't hold up every run.
+func awaitCarrier(ctx context.Context, interfaces []string, wait string, logger *log.Logger) {
+	timeout, err := time.ParseDuration(wait)
+	if wait == "" || err != nil || timeout <= 0 || len(interfaces) == 0 || !carrierAwaited.CompareAndSwap(false, true) {
+		return
+	}
+	started := time.Now()
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(carrierPoll)
+	defer ticker.Stop()
+	logged := false
+	for {
+		var waiting []string
+		for _, iface := range interfaces {
+			if !hasCarrier(iface) {
+				waiting = append(waiting, iface)
+			}
+		}
+		if len(waiting) == 0 {
+			if logged {
+				logger.Verbose("Carrier on %s after %s; reloading systemd-networkd", strings.Join(interfaces, ", "), time.Since(started).Round(time.Millisecond))
+			}
+			metrics.Set("zeroplex_networkd_carrier_wait_seconds", "How long the first networkd reload waited for the carrier of the ZeroTier interfaces", time.Since(started).Seconds(), nil)
+			return
+		}
+		if !logged {
+			logger.Verbose("Waiting up to %s for carrier on %s before reloading systemd-networkd", timeout, strings.Join(waiting, ", "))
+			logged = true
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline.C:
+			logger.Warn("No carrier on %s after %s (networkd.wait_for_carrier); reloading systemd-networkd anyway", strings.Join(waiting, ", "), timeout)
+			metrics.Set("zeroplex_networkd_carrier_wait_seconds", "How long the first networkd reload waited for the carrier of the ZeroTier interfaces", timeout.Seconds(), nil)
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,310 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/dns"
+	"zeroplex/pkg/events"
+	"zeroplex/pkg/initsys"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/utils"
+
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+// ResolvconfInterfaceDir holds the records Debian's resolvconf keeps per interface, where OpenVPN's
+// update-resolv-conf hook adds the pushed domains
+var ResolvconfInterfaceDir = "/run/resolvconf/interface"
+
+// NetworkManagerDnsmasqDir holds the split-DNS snippets of NetworkManager's dnsmasq plugin
+var NetworkManagerDnsmasqDir = "/etc/NetworkManager/dnsmasq.d"
+
+// claim is a domain another DNS manager routes
+type claim struct {
+	Domain  string
+	Manager string // tailscaled, openvpn, NetworkManager, ...
+	Source  string // where it was found: a systemd-resolved link, resolvconf record or file
+	Link    int    // systemd-resolved ifindex, 0 for the other sources
+	Domains []string
+}
+
+var (
+	coordinationMu    sync.Mutex
+	yieldedDomains    = map[string]string{} // domain -> the manager it was left to
+	reportedConflicts = map[string]bool{}   // domain|manager -> reported
+)
+
+// yielded reports whether a domain is currently left to another DNS manager
+func yielded(domain string) bool {
+	coordinationMu.Lock()
+	defer coordinationMu.Unlock()
+	_, ok := yieldedDomains[normalizeDomain(domain)]
+	return ok
+}
+
+func normalizeDomain(domain string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(domain, "~"), "."))
+}
+
+// coordinate finds the domains of the networks that other DNS managers route too and applies the
+// coordination policy: yield leaves a domain to the other manager, override takes it from a
+// systemd-resolved link, merge configures it alongside. Each conflict is reported once, when it
+// appears, rather than on every poll.
+func (b *BaseMode) coordinate(networks *service.GetNetworksResponse, logger *log.Logger) {
+	cfg := b.cfg.Default
+	if !cfg.Coordination.Enabled || b.mode == "noop" {
+		return
+	}
+
+	own := map[string]bool{}     // interface names zeroplex manages
+	ownIndex := map[int]bool{}   // and their ifindexes
+	domains := map[string]bool{} // the domains of the networks
+	for _, network := range *networks.JSON200 {
+		if b.ValidateNetwork(network) != nil {
+			continue
+		}
+		name := *network.PortDeviceName
+		own[name] = true
+		if index, err := dns.LinkIndex(name); err == nil {
+			ownIndex[index] = true
+		}
+		for _, domain := range networkDomains(network, cfg.Features.AddReverseDomains, cfg.Features.ExtraSearchDomains) {
+			domains[normalizeDomain(domain)] = true
+		}
+	}
+
+	var conflicts []claim
+	for _, c := range otherClaims(own, ownIndex, logger) {
+		if domains[c.Domain] {
+			conflicts = append(conflicts, c)
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Domain != conflicts[j].Domain {
+			return conflicts[i].Domain < conflicts[j].Domain
+		}
+		return conflicts[i].Manager < conflicts[j].Manager
+	})
+
+	yield := map[string]string{}
+	current := map[string]bool{}
+	for _, c := range conflicts {
+		policy := cfg.Coordination.PolicyFor(c.Domain)
+		key := c.Domain + "|" + c.Manager
+		current[key] = true
+		coordinationMu.Lock()
+		first := !reportedConflicts[key]
+		reportedConflicts[key] = true
+		coordinationMu.Unlock()
+		if first {
+			logger.Warn("%s also routes %s (%s); coordination policy: %s", c.Manager, c.Domain, c.Source, policy)
+			events.Publish(events.Event{
+				Type:    events.TypeConflict,
+				Mode:    b.mode,
+				Message: c.Manager + " also routes " + c.Domain,
+				Data:    map[string]interface{}{"domain": c.Domain, "manager": c.Manager, "source": c.Source, "policy": policy},
+			})
+		}
+
+		switch policy {
+		case "yield":
+			yield[c.Domain] = c.Manager
+			if first {
+				logger.Info("Leaving %s to %s", c.Domain, c.Manager)
+			}
+		case "override":
+			if c.Link == 0 {
+				if first {
+					logger.Info("Cannot take %s from %s (%s); configuring it alongside", c.Domain, c.Manager, c.Source)
+				}
+				continue
+			}
+			b.takeDomain(c, logger)
+		}
+	}
+
+	coordinationMu.Lock()
+	for domain, manager := range yieldedDomains {
+		if _, still := yield[domain]; !still {
+			logger.Info("%s no longer routes %s, configuring it again", manager, domain)
+		}
+	}
+	yieldedDomains = yield
+	for key := range reportedConflicts {
+		if !current[key] {
+			delete(reportedConflicts, key)
+		}
+	}
+	coordinationMu.Unlock()
+}
+
+// takeDomain removes a domain from the systemd-resolved link of another manager, for the override
+// policy. The other manager may add it back, in which case the next run removes it again.
+func (b *BaseMode) takeDomain(c claim, logger *log.Logger) {
+	if b.dryRun {
+		logger.Info("[dry-run] Would remove %s from %s (%s)", c.Domain, c.Source, c.Manager)
+		return
+	}
+	window, deferring := b.cfg.Default.Maintenance.Deferring(now())
+	if !b.cfg.Default.Enforcing() || deferring {
+		logger.Debug("Not taking %s from %s: changes are not allowed (maintenance window %q)", c.Domain, c.Manager, window)
+		return
+	}
+	args := []string{"domain", strconv.Itoa(c.Link)}
+	for _, domain := range c.Domains {
+		if normalizeDomain(domain) != c.Domain {
+			args = append(args, domain)
+		}
+	}
+	if len(args) == 2 {
+		args = append(args, "")
+	}
+	logger.Info("Taking %s from %s (%s)", c.Domain, c.Manager, c.Source)
+	if _, err := utils.ExecuteCommand("resolvectl", args...); err != nil {
+		logger.Warn("Failed to remove %s from %s: %v", c.Domain, c.Source, err)
+	}
+}
+
+// otherClaims lists the domains routed by other DNS managers: systemd-resolved links zeroplex doesn't
+// manage, resolvconf records of other interfaces and NetworkManager dnsmasq snippets
+func otherClaims(own map[string]bool, ownIndex map[int]bool, logger *log.Logger) []claim {
+	var claims []claim
+	if utils.CommandExists("resolvectl") {
+		if out, err := utils.ExecuteCommand("resolvectl", "domain"); err == nil {
+			claims = append(claims, resolvedClaims(out, own, ownIndex)...)
+		} else {
+			logger.Debug("Could not list the domains of systemd-resolved links: %v", err)
+		}
+	}
+	claims = append(claims, resolvconfClaims(own)...)
+	claims = append(claims, networkManagerDnsmasqClaims()...)
+	return claims
+}
+
+var resolvectlLinkLine = regexp.MustCompile(`^Link (\d+) \(([^)]*)\):\s*(.*)$`)
+
+// resolvedClaims parses "resolvectl domain" output, one "Link N (name): domains" line per link
+func resolvedClaims(out string, own map[string]bool, ownIndex map[int]bool) []claim {
+	var claims []claim
+	for _, line := range strings.Split(out, "\n") {
+		m := resolvectlLinkLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		index, _ := strconv.Atoi(m[1])
+		name := m[2]
+		if own[name] || ownIndex[index] || strings.HasPrefix(name, "zt") {
+			continue
+		}
+		linkDomains := strings.Fields(m[3])
+		if len(linkDomains) == 0 {
+			continue
+		}
+		manager := linkManager(name)
+		for _, domain := range linkDomains {
+			if d := normalizeDomain(domain); d != "" {
+				claims = append(claims, claim{Domain: d, Manager: manager, Source: "systemd-resolved link " + name, Link: index, Domains: linkDomains})
+			}
+		}
+	}
+	return claims
+}
+
+// linkManager guesses which program manages the DNS of an interface from its name
+func linkManager(name string) string {
+	switch {
+	case strings.HasPrefix(name, "tailscale"):
+		return "tailscaled"
+	case strings.HasPrefix(name, "tun"), strings.HasPrefix(name, "tap"), strings.HasPrefix(name, "ovpn"):
+		return "openvpn"
+	case strings.HasPrefix(name, "wg"):
+		return "wireguard"
+	case initsys.Current().IsActive("NetworkManager"):
+		return "NetworkManager"
+	}
+	return "link " + name
+}
+
+// resolvconfClaims reads the search and domain lines of the resolvconf records of other interfaces
+func resolvconfClaims(own map[string]bool) []claim {
+	entries, err := os.ReadDir(ResolvconfInterfaceDir)
+	if err != nil {
+		return nil
+	}
+	var claims []claim
+	for _, e := range entries {
+		record := e.Name()
+		iface, _, _ := strings.Cut(record, ".")
+		if own[iface] || strings.HasPrefix(iface, "zt") {
+			continue
+		}
+		manager := linkManager(iface)
+		if strings.HasSuffix(record, ".openvpn") {
+			manager = "openvpn"
+		}
+		for _, domain := range resolvConfDomains(filepath.Join(ResolvconfInterfaceDir, record)) {
+			claims = append(claims, claim{Domain: domain, Manager: manager, Source: "resolvconf record " + record})
+		}
+	}
+	return claims
+}
+
+// resolvConfDomains returns the domains on the search and domain lines of a resolv.conf style file
+func resolvConfDomains(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var domains []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 1 && (fields[0] == "search" || fields[0] == "domain") {
+			for _, domain := range fields[1:] {
+				domains = append(domains, normalizeDomain(domain))
+			}
+		}
+	}
+	return domains
+}
+
+// networkManagerDnsmasqClaims reads the server=/domain/ lines of NetworkManager's dnsmasq snippets
+func networkManagerDnsmasqClaims() []claim {
+	files, _ := filepath.Glob(filepath.Join(NetworkManagerDnsmasqDir, "*.conf"))
+	var claims []claim
+	for _, path := range files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			value, ok := strings.CutPrefix(strings.TrimSpace(line), "server=/")
+			if !ok {
+				continue
+			}
+			// server=/domain/[domain/...]server
+			end := strings.LastIndex(value, "/")
+			if end < 0 {
+				continue
+			}
+			for _, domain := range strings.Split(value[:end], "/") {
+				if d := normalizeDomain(domain); d != "" {
+					claims = append(claims, claim{Domain: d, Manager: "NetworkManager", Source: path})
+				}
+			}
+		}
+	}
+	return claims
+}
@@ -0,0 +1,322 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/client"
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/dns"
+	"zeroplex/pkg/initsys"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/state"
+	"zeroplex/pkg/utils"
+
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+// dnsmasqFileHeader marks the files zeroplex generates, so only those are ever replaced or removed
+const dnsmasqFileHeader = "# Generated by zeroplex for ZeroTier DNS, do not edit"
+
+// DnsmasqMode forwards the domains of each ZeroTier network to its DNS servers through dnsmasq, for
+// routers and Pi-hole style hosts where dnsmasq answers the local network. Each network gets a
+// snippet of "server=/domain/ip" lines in dnsmasq's conf.d directory, or all networks share one
+// servers-file, which dnsmasq re-reads on SIGHUP without a restart.
+type DnsmasqMode struct {
+	*BaseMode
+}
+
+// NewDnsmasqMode creates a new dnsmasq mode runner
+func NewDnsmasqMode(cfg config.Config, zt *client.Client, dryRun bool) (*DnsmasqMode, error) {
+	logger := log.NewScopedLogger("[modes/dnsmasq]", cfg.Default.Log.Level)
+	dir := cfg.Default.Dnsmasq.ConfigDir
+	if cfg.Default.Dnsmasq.ServersFile != "" {
+		dir = filepath.Dir(cfg.Default.Dnsmasq.ServersFile)
+	}
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		logger.Error("dnsmasq configuration directory %s not found", dir)
+		return nil, fmt.Errorf("dnsmasq configuration directory %s does not exist", dir)
+	}
+
+	return &DnsmasqMode{
+		BaseMode: NewBaseMode(cfg, zt, dryRun, "dnsmasq"),
+	}, nil
+}
+
+// GetMode returns the mode name
+func (d *DnsmasqMode) GetMode() string {
+	return "dnsmasq"
+}
+
+// Run executes the dnsmasq mode logic
+func (d *DnsmasqMode) Run(ctx context.Context) error {
+	logger := log.NewScopedLogger("[modes/dnsmasq]", d.GetConfig().Default.Log.Level).WithContext(ctx)
+	logger.Trace(">>> DnsmasqMode.Run() started")
+	logger.Debug("Running in dnsmasq mode (dry-run: %t)", d.IsDryRun())
+
+	networks, err := d.ProcessNetworks(ctx)
+	if err != nil {
+		logger.Error("Failed to process networks: %v", err)
+		return fmt.Errorf("failed to process networks: %w", err)
+	}
+
+	logIgnoredMDNSAndDoT(d.GetConfig().Default.Features, networks, "dnsmasq has no per-server mDNS or DNS-over-TLS settings, ignoring them", logger)
+
+	if !changesAllowed(d.BaseMode, logger) {
+		reportDrift("dnsmasq", dnsmasqDrift(networks, d.BaseMode, logger), logger)
+		return nil
+	}
+
+	if err := guardChanges("dnsmasq", networks, func() []Drift { return dnsmasqDrift(networks, d.BaseMode, logger) }, d.BaseMode, logger); err != nil {
+		return err
+	}
+
+	d.processNetworks(networks, logger)
+
+	logger.Trace("<<< DnsmasqMode.Run() completed")
+	return nil
+}
+
+// dnsmasqFile is a file to generate and the interfaces whose servers it holds
+type dnsmasqFile struct {
+	content []byte
+	entries []state.Interface
+}
+
+// dnsmasqFiles renders the files the networks need, keyed by path
+func dnsmasqFiles(networks *service.GetNetworksResponse, base *BaseMode, logger *log.Logger) map[string]*dnsmasqFile {
+	cfg := base.GetConfig().Default
+	files := map[string]*dnsmasqFile{}
+	for _, network := range *networks.JSON200 {
+		if base.ValidateNetwork(network) != nil {
+			continue
+		}
+		servers := base.GetDNSServers(network)
+		domains := base.GetSearchDomains(network, cfg.Features.AddReverseDomains)
+		if len(servers) == 0 || len(domains) == 0 {
+			// A server line without a domain would make the ZeroTier servers the upstream for everything
+			logger.Debug("Network %s has no DNS servers or domains, nothing to forward", GetNetworkName(network))
+			continue
+		}
+		interfaceName := *network.PortDeviceName
+		path := cfg.Dnsmasq.ServersFile
+		if path == "" {
+			path = dnsmasqFilePath(cfg.Dnsmasq.ConfigDir, interfaceName)
+		}
+		file := files[path]
+		if file == nil {
+			file = &dnsmasqFile{content: []byte(dnsmasqFileHeader + "\n")}
+			files[path] = file
+		}
+
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "# ZeroTier network %s on %s\n", GetNetworkName(network), interfaceName)
+		for _, domain := range domains {
+			for _, server := range servers {
+				fmt.Fprintf(&b, "server=/%s/%s\n", domain, server)
+			}
+		}
+		file.content = append(file.content, b.Bytes()...)
+
+		index, _ := dns.LinkIndex(interfaceName)
+		file.entries = append(file.entries, state.Interface{
+			Name:        interfaceName,
+			Index:       index,
+			NetworkID:   utils.GetString(network.Id),
+			NetworkName: utils.GetString(network.Name),
+			Mode:        "dnsmasq",
+			DNS:         servers,
+			Domains:     domains,
+			Files:       []string{path},
+		})
+	}
+	// dnsmasq refuses to start when its servers-file is missing, so it is emptied rather than removed
+	if path := cfg.Dnsmasq.ServersFile; path != "" && files[path] == nil {
+		files[path] = &dnsmasqFile{content: []byte(dnsmasqFileHeader + "\n")}
+	}
+	return files
+}
+
+func dnsmasqFilePath(dir, interfaceName string) string {
+	return filepath.Join(dir, "zeroplex-"+interfaceName+".conf")
+}
+
+// managedDnsmasqFiles returns the generated files currently on disk
+func managedDnsmasqFiles(cfg config.DnsmasqConfig) map[string]struct{} {
+	candidates := []string{cfg.ServersFile}
+	if cfg.ServersFile == "" {
+		candidates, _ = filepath.Glob(dnsmasqFilePath(cfg.ConfigDir, "*"))
+	}
+	found := map[string]struct{}{}
+	for _, path := range candidates {
+		if content, err := os.ReadFile(path); err == nil && bytes.HasPrefix(content, []byte(dnsmasqFileHeader)) {
+			found[path] = struct{}{}
+		}
+	}
+	return found
+}
+
+// processNetworks writes the files that changed, removes the ones no network needs any more and
+// reloads dnsmasq if anything changed
+func (d *DnsmasqMode) processNetworks(networks *service.GetNetworksResponse, logger *log.Logger) {
+	cfg := d.GetConfig().Default.Dnsmasq
+	files := dnsmasqFiles(networks, d.BaseMode, logger)
+	stale := managedDnsmasqFiles(cfg)
+	current := map[string]struct{}{}
+	changed := false
+	logger.Verbose("Processing %d networks for dnsmasq configuration", len(*networks.JSON200))
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		file := files[path]
+		delete(stale, path)
+		for _, entry := range file.entries {
+			current[entry.Name] = struct{}{}
+		}
+
+		if content, err := os.ReadFile(path); err == nil && bytes.Equal(content, file.content) {
+			logger.Verbose("No changes needed for %s; already up-to-date", path)
+			for _, entry := range file.entries {
+				recordManaged(entry, logger)
+			}
+			continue
+		}
+		if d.IsDryRun() {
+			logger.Info("[dry-run] Would write %s:\n%s", path, file.content)
+			continue
+		}
+		if err := os.WriteFile(path, file.content, 0644); err != nil {
+			logger.Warn("Failed to write %s: %v", path, err)
+			countSummary(func(s *RunSummary) { s.Errors++ })
+			continue
+		}
+		changed = true
+		for _, entry := range file.entries {
+			logger.Verbose("Configured Interface=%s, Network=%s, ID=%s, DNS Servers=%v, Domains=%v in %s",
+				entry.Name, entry.NetworkName, entry.NetworkID, entry.DNS, entry.Domains, path)
+			recordManaged(entry, logger)
+		}
+	}
+
+	for path := range stale {
+		if d.IsDryRun() {
+			logger.Info("[dry-run] Would remove stale %s", path)
+			continue
+		}
+		logger.Info("Removing stale %s", path)
+		if err := os.Remove(path); err != nil {
+			logger.Warn("Failed to remove %s: %v", path, err)
+			countSummary(func(s *RunSummary) { s.Errors++ })
+			continue
+		}
+		changed = true
+	}
+	revertLeft("dnsmasq", current, d.IsDryRun(), logger, func(entry state.Interface) {
+		logger.Info("Network %s left, no longer forwarding for %s", entry.NetworkID, entry.Name)
+	})
+
+	if changed {
+		reloadDnsmasq(cfg, logger)
+	}
+}
+
+// reloadDnsmasq makes dnsmasq pick up the generated files. A servers-file is re-read on SIGHUP,
+// which the init system sends on reload; conf.d snippets are only read at startup.
+func reloadDnsmasq(cfg config.DnsmasqConfig, logger *log.Logger) {
+	if !cfg.Reload {
+		logger.Debug("dnsmasq.reload is off, not reloading %s", cfg.Service)
+		return
+	}
+	services := initsys.Current()
+	var err error
+	if cfg.ServersFile != "" {
+		logger.Info("Servers changed; reloading %s...", cfg.Service)
+		err = services.Reload(cfg.Service)
+	} else {
+		logger.Info("Files changed; restarting %s...", cfg.Service)
+		err = services.TryRestart(cfg.Service)
+	}
+	if err != nil {
+		logger.Warn("Failed to reload %s (%s): %v", cfg.Service, services.Name(), err)
+		countSummary(func(s *RunSummary) { s.Errors++ })
+	}
+}
+
+// dnsmasqDrift compares the generated files with what would be written
+func dnsmasqDrift(networks *service.GetNetworksResponse, base *BaseMode, logger *log.Logger) []Drift {
+	cfg := base.GetConfig().Default.Dnsmasq
+	stale := managedDnsmasqFiles(cfg)
+	var drifts []Drift
+	for path, file := range dnsmasqFiles(networks, base, logger) {
+		delete(stale, path)
+		content, err := os.ReadFile(path)
+		if err == nil && bytes.Equal(content, file.content) {
+			continue
+		}
+		for _, entry := range file.entries {
+			d := Drift{Interface: entry.Name, NetworkID: entry.NetworkID, Kind: DriftFile, Desired: []string{path}}
+			if err == nil {
+				d.Current = []string{path}
+			}
+			drifts = append(drifts, d)
+		}
+	}
+	for path := range stale {
+		interfaceName := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "zeroplex-"), ".conf")
+		drifts = append(drifts, Drift{Interface: interfaceName, Kind: DriftStale, Current: []string{path}})
+	}
+	return drifts
+}
+
+// restoreDnsmasq removes every generated file and reloads dnsmasq, returning the interfaces whose
+// servers were removed
+func restoreDnsmasq(cfg config.DnsmasqConfig, dryRun bool, logger *log.Logger) []string {
+	var restored []string
+	removed := false
+	for path := range managedDnsmasqFiles(cfg) {
+		if dryRun {
+			logger.Info("[dry-run] Would remove %s", path)
+			continue
+		}
+		var err error
+		if path == cfg.ServersFile {
+			err = os.WriteFile(path, []byte(dnsmasqFileHeader+"\n"), 0644)
+		} else {
+			err = os.Remove(path)
+		}
+		if err != nil {
+			logger.Warn("Failed to remove %s: %v", path, err)
+			continue
+		}
+		logger.Info("Removed the ZeroTier servers from %s", path)
+		removed = true
+	}
+	if store, err := state.Default(); err == nil {
+		for _, entry := range store.Interfaces() {
+			if entry.Mode != "dnsmasq" {
+				continue
+			}
+			if !dryRun {
+				forgetManaged(entry.Name, logger)
+			}
+			restored = append(restored, entry.Name)
+		}
+	}
+	if removed {
+		reloadDnsmasq(cfg, logger)
+	}
+	return restored
+}
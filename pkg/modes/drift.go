@@ -0,0 +1,189 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/dns"
+	"zeroplex/pkg/events"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/metrics"
+	"zeroplex/pkg/utils"
+
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+// Drift kinds
+const (
+	DriftDNS     = "dns"
+	DriftDomains = "domains"
+	DriftFile    = "file"
+	DriftStale   = "stale"
+)
+
+// Drift is a single difference between the desired and the actual system configuration
+type Drift struct {
+	Interface string   `json:"interface"`
+	NetworkID string   `json:"network_id,omitempty"`
+	Kind      string   `json:"kind"`
+	Current   []string `json:"current,omitempty"`
+	Desired   []string `json:"desired,omitempty"`
+}
+
+func (d Drift) key() string {
+	return d.Interface + "/" + d.Kind
+}
+
+func (d Drift) signature() string {
+	return strings.Join(d.Current, ",") + "|" + strings.Join(d.Desired, ",")
+}
+
+// reportedDrift remembers what was last reported so webhooks only fire when drift changes
+var (
+	reportedDriftMu sync.Mutex
+	reportedDrift   = map[string]Drift{}
+)
+
+// resolvedDrift compares each network's desired DNS with what systemd-resolved currently holds
+func resolvedDrift(networks *service.GetNetworksResponse, base *BaseMode, logger *log.Logger) []Drift {
+	var drifts []Drift
+	for _, network := range *networks.JSON200 {
+		servers := base.GetDNSServers(network)
+		if base.ValidateNetwork(network) != nil || len(servers) == 0 {
+			continue
+		}
+		interfaceName := *network.PortDeviceName
+		index, err := dns.LinkIndex(interfaceName)
+		if err != nil {
+			logger.Debug("Skipping drift check for %s: %v", interfaceName, err)
+			continue
+		}
+		currentDNS, currentDomains, err := dns.CurrentDNS(index)
+		if err != nil {
+			logger.Warn("Could not read current DNS for %s: %v", interfaceName, err)
+			continue
+		}
+
+		desiredDomains := base.GetSearchDomains(network, base.GetConfig().Default.Features.AddReverseDomains)
+		for i, domain := range desiredDomains {
+			desiredDomains[i] = "~" + domain
+		}
+
+		networkID := utils.GetString(network.Id)
+		if !dns.CompareDNS(currentDNS, servers) {
+			drifts = append(drifts, Drift{Interface: interfaceName, NetworkID: networkID, Kind: DriftDNS, Current: currentDNS, Desired: servers})
+		}
+		if !dns.CompareDNS(currentDomains, desiredDomains) {
+			drifts = append(drifts, Drift{Interface: interfaceName, NetworkID: networkID, Kind: DriftDomains, Current: currentDomains, Desired: desiredDomains})
+		}
+	}
+	return drifts
+}
+
+// networkdDrift compares the generated .network files with what would be written
+func networkdDrift(networks *service.GetNetworksResponse, base *BaseMode, logger *log.Logger) []Drift {
+	features := base.GetConfig().Default.Features
+	found, err := managedNetworkdFiles()
+	if err != nil {
+		logger.Debug("Could not list %s: %v", networkdDir(), err)
+	}
+
+	var drifts []Drift
+	for _, network := range *networks.JSON200 {
+		if base.ValidateNetwork(network) != nil || network.Dns == nil || network.Dns.Servers == nil {
+			continue
+		}
+		interfaceName := *network.PortDeviceName
+		fn := networkdFilePath(interfaceName)
+		delete(found, path.Base(fn))
+
+		_, rendered, err := renderNetworkdFile(network, features.AddReverseDomains, features.DNSOverTLS, features.MulticastDNS, features.ExtraSearchDomains)
+		if err != nil {
+			logger.Warn("Could not render %s: %v", fn, err)
+			continue
+		}
+		content, err := os.ReadFile(fn)
+		if err == nil && bytes.Equal(content, rendered) {
+			continue
+		}
+		d := Drift{Interface: interfaceName, NetworkID: utils.GetString(network.Id), Kind: DriftFile, Desired: []string{fn}}
+		if err == nil {
+			d.Current = []string{fn}
+		}
+		drifts = append(drifts, d)
+	}
+
+	if base.GetConfig().Default.Networkd.Reconcile {
+		for name := range found {
+			interfaceName := strings.TrimSuffix(strings.TrimPrefix(name, "99-"), ".network")
+			drifts = append(drifts, Drift{Interface: interfaceName, Kind: DriftStale, Current: []string{filepath.Join(networkdDir(), name)}})
+		}
+	}
+	return drifts
+}
+
+// reportDrift logs drift, publishes it as metrics, and emits webhook events when it changes
+// DriftPending returns the number of drifted items found by the last observe-only check
+func DriftPending() int {
+	reportedDriftMu.Lock()
+	defer reportedDriftMu.Unlock()
+	return len(reportedDrift)
+}
+
+func reportDrift(mode string, drifts []Drift, logger *log.Logger) {
+	sort.Slice(drifts, func(i, j int) bool { return drifts[i].key() < drifts[j].key() })
+
+	metrics.Inc("zeroplex_drift_checks_total", "Observe-only drift checks performed", metrics.Labels{"mode": mode})
+	metrics.Reset("zeroplex_drift")
+	for _, d := range drifts {
+		metrics.Set("zeroplex_drift", "Detected drift by interface and kind (1 = drifted)", 1, metrics.Labels{"mode": mode, "interface": d.Interface, "kind": d.Kind})
+	}
+	metrics.Set("zeroplex_drift_items", "Number of drifted items found by the last observe-only check", float64(len(drifts)), metrics.Labels{"mode": mode})
+
+	if len(drifts) == 0 {
+		logger.Verbose("Observe-only: no drift detected")
+	}
+
+	reportedDriftMu.Lock()
+	defer reportedDriftMu.Unlock()
+	current := make(map[string]Drift, len(drifts))
+	for _, d := range drifts {
+		current[d.key()] = d
+		logger.Warn("Observe-only: drift on %s (%s): current=%v desired=%v", d.Interface, d.Kind, d.Current, d.Desired)
+		if previous, ok := reportedDrift[d.key()]; ok && previous.signature() == d.signature() {
+			continue
+		}
+		events.Publish(events.Event{
+			Type:      events.TypeDrift,
+			Mode:      mode,
+			Interface: d.Interface,
+			NetworkID: d.NetworkID,
+			Message:   "configuration drift detected (" + d.Kind + ")",
+			Data:      map[string]interface{}{"kind": d.Kind, "current": d.Current, "desired": d.Desired},
+		})
+	}
+	for key, d := range reportedDrift {
+		if _, ok := current[key]; ok {
+			continue
+		}
+		logger.Info("Observe-only: drift on %s (%s) cleared", d.Interface, d.Kind)
+		events.Publish(events.Event{
+			Type:      events.TypeDriftCleared,
+			Mode:      mode,
+			Interface: d.Interface,
+			NetworkID: d.NetworkID,
+			Message:   "configuration drift cleared (" + d.Kind + ")",
+			Data:      map[string]interface{}{"kind": d.Kind},
+		})
+	}
+	reportedDrift = current
+}
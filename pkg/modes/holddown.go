@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/metrics"
+	"zeroplex/pkg/state"
+	"zeroplex/pkg/utils"
+
+	"sync"
+	"time"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+var (
+	holdDownMu sync.Mutex
+	holdDownAt time.Time                // when the first network held down by the last run is due, see HoldDownDue
+	readySince = map[string]time.Time{} // by network ID, when the state store is unavailable
+)
+
+// HoldDownDue returns when the first network the last run held down has been ready for its
+// hold_down, or the zero time if none is waiting for that
+func HoldDownDue() time.Time {
+	holdDownMu.Lock()
+	defer holdDownMu.Unlock()
+	return holdDownAt
+}
+
+// networkReady reports whether ZeroTier reports network OK with DNS servers, as the api-status
+// readiness strategy of the interface watch does
+func networkReady(network service.Network) bool {
+	return stringValue(network.Status) == "OK" && network.Dns != nil && network.Dns.Servers != nil && len(*network.Dns.Servers) > 0
+}
+
+// markReady records whether the network with ID id is ready, returning since when it has been
+// ready without a break. The state store keeps it across one-shot runs.
+func markReady(id string, ready bool, logger *log.Logger) time.Time {
+	if store, err := state.Default(); err == nil {
+		since, err := store.MarkReady(id, ready)
+		if err == nil {
+			return since
+		}
+		logger.Debug("Failed to record the readiness of network %s in the state store: %v", id, err)
+	} else {
+		logger.Debug("State store unavailable, keeping the readiness of network %s in memory: %v", id, err)
+	}
+	holdDownMu.Lock()
+	defer holdDownMu.Unlock()
+	if !ready {
+		delete(readySince, id)
+		return time.Time{}
+	}
+	if _, ok := readySince[id]; !ok {
+		readySince[id] = time.Now()
+	}
+	return readySince[id]
+}
+
+// holdDownNetworks leaves out the networks with a hold_down under networks: that haven't been ready
+// for that long without a break, so a network flapping during controller maintenance isn't
+// configured and removed again with every flap
+func holdDownNetworks(networks *service.GetNetworksResponse, profile config.Profile, logger *log.Logger) {
+	now := time.Now()
+	var due time.Time
+	kept := []service.Network{}
+	for _, network := range *networks.JSON200 {
+		id := utils.GetString(network.Id)
+		override, _ := profile.NetworkOverride(id)
+		hold, err := time.ParseDuration(override.HoldDown)
+		if override.HoldDown == "" || err != nil || hold <= 0 {
+			kept = append(kept, network)
+			continue
+		}
+		labels := metrics.Labels{"network": id}
+		since := markReady(id, networkReady(network), logger)
+		if !since.IsZero() && now.Sub(since) >= hold {
+			metrics.Set("zeroplex_network_held_down", "Whether the DNS of a network is held back until it has been ready for its hold_down", 0, labels)
+			kept = append(kept, network)
+			continue
+		}
+		metrics.Set("zeroplex_network_held_down", "Whether the DNS of a network is held back until it has been ready for its hold_down", 1, labels)
+		countSummary(func(s *RunSummary) { s.Held++ })
+		if since.IsZero() {
+			logger.Verbose("Network %s: not ready (status %s), holding its DNS back until it has been ready for %s", GetNetworkName(network), utils.GetString(network.Status), hold)
+			continue
+		}
+		release := since.Add(hold)
+		logger.Verbose("Network %s: ready for %s, holding its DNS back until %s", GetNetworkName(network), now.Sub(since).Round(time.Second), release.Format("15:04:05"))
+		if due.IsZero() || release.Before(due) {
+			due = release
+		}
+	}
+	holdDownMu.Lock()
+	holdDownAt = due
+	holdDownMu.Unlock()
+	networks.JSON200 = &kept
+}
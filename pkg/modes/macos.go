@@ -0,0 +1,315 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/client"
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/dns"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/state"
+	"zeroplex/pkg/utils"
+
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+// ResolverDir is where macOS looks for per-domain resolver files, see resolver(5)
+var ResolverDir = "/etc/resolver"
+
+// resolverFileHeader marks the resolver files zeroplex generates, so only those are ever replaced or removed
+const resolverFileHeader = "# Generated by zeroplex for ZeroTier DNS, do not edit"
+
+// MacOSMode sends the domains of each ZeroTier network to its DNS servers on macOS, by writing a
+// resolver file per domain to /etc/resolver. The system resolver picks the files up on its own,
+// so nothing has to be restarted.
+type MacOSMode struct {
+	*BaseMode
+}
+
+// NewMacOSMode creates a new macOS mode runner
+func NewMacOSMode(cfg config.Config, zt *client.Client, dryRun bool) (*MacOSMode, error) {
+	return &MacOSMode{
+		BaseMode: NewBaseMode(cfg, zt, dryRun, "macos"),
+	}, nil
+}
+
+// GetMode returns the mode name
+func (m *MacOSMode) GetMode() string {
+	return "macos"
+}
+
+// Run executes the macOS mode logic
+func (m *MacOSMode) Run(ctx context.Context) error {
+	logger := log.NewScopedLogger("[modes/macos]", m.GetConfig().Default.Log.Level).WithContext(ctx)
+	logger.Trace(">>> MacOSMode.Run() started")
+	logger.Debug("Running in macos mode (dry-run: %t)", m.IsDryRun())
+
+	networks, err := m.ProcessNetworks(ctx)
+	if err != nil {
+		logger.Error("Failed to process networks: %v", err)
+		return fmt.Errorf("failed to process networks: %w", err)
+	}
+
+	logIgnoredMDNSAndDoT(m.GetConfig().Default.Features, networks, "macOS resolver files have no mDNS or DNS-over-TLS settings, ignoring them", logger)
+
+	if !changesAllowed(m.BaseMode, logger) {
+		reportDrift("macos", resolverDrift(networks, m.BaseMode, logger), logger)
+		return nil
+	}
+
+	if err := guardChanges("macos", networks, func() []Drift { return resolverDrift(networks, m.BaseMode, logger) }, m.BaseMode, logger); err != nil {
+		return err
+	}
+
+	m.processNetworks(networks, logger)
+
+	logger.Trace("<<< MacOSMode.Run() completed")
+	return nil
+}
+
+// resolverFile is a resolver file to generate and the interface whose servers it holds
+type resolverFile struct {
+	content []byte
+	entry   state.Interface
+}
+
+// resolverFiles renders the resolver file of every domain the networks have, keyed by path
+func resolverFiles(networks *service.GetNetworksResponse, base *BaseMode, logger *log.Logger) map[string]*resolverFile {
+	cfg := base.GetConfig().Default
+	files := map[string]*resolverFile{}
+	for _, network := range *networks.JSON200 {
+		if base.ValidateNetwork(network) != nil {
+			continue
+		}
+		servers := base.GetDNSServers(network)
+		domains := base.GetSearchDomains(network, cfg.Features.AddReverseDomains)
+		if len(servers) == 0 || len(domains) == 0 {
+			// Without a domain there is no resolver file to write
+			logger.Debug("Network %s has no DNS servers or domains, nothing to do", GetNetworkName(network))
+			continue
+		}
+		interfaceName := *network.PortDeviceName
+
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "%s\n# ZeroTier network %s on %s\n", resolverFileHeader, GetNetworkName(network), interfaceName)
+		for _, server := range servers {
+			// resolver(5) takes link-local IPv6 servers with their zone
+			if ip := net.ParseIP(server); ip != nil && ip.To4() == nil && ip.IsLinkLocalUnicast() {
+				server += "%" + interfaceName
+			}
+			fmt.Fprintf(&b, "nameserver %s\n", server)
+		}
+
+		index, _ := dns.LinkIndex(interfaceName)
+		entry := state.Interface{
+			Name:        interfaceName,
+			Index:       index,
+			NetworkID:   utils.GetString(network.Id),
+			NetworkName: utils.GetString(network.Name),
+			Mode:        "macos",
+			DNS:         servers,
+			Domains:     domains,
+		}
+		for _, domain := range domains {
+			entry.Files = append(entry.Files, filepath.Join(ResolverDir, domain))
+		}
+		for _, path := range entry.Files {
+			if existing, ok := files[path]; ok {
+				logger.Warn("Domain %s is used by both %s and %s; using the servers of %s", filepath.Base(path), existing.entry.Name, interfaceName, interfaceName)
+			}
+			files[path] = &resolverFile{content: b.Bytes(), entry: entry}
+		}
+	}
+	return files
+}
+
+// managedResolverFiles returns the generated resolver files currently on disk
+func managedResolverFiles() map[string]struct{} {
+	found := map[string]struct{}{}
+	entries, err := os.ReadDir(ResolverDir)
+	if err != nil {
+		return found
+	}
+	for _, e := range entries {
+		path := filepath.Join(ResolverDir, e.Name())
+		if content, err := os.ReadFile(path); err == nil && bytes.HasPrefix(content, []byte(resolverFileHeader)) {
+			found[path] = struct{}{}
+		}
+	}
+	return found
+}
+
+// processNetworks writes the resolver files that changed and removes the ones no network needs any more
+func (m *MacOSMode) processNetworks(networks *service.GetNetworksResponse, logger *log.Logger) {
+	files := resolverFiles(networks, m.BaseMode, logger)
+	stale := managedResolverFiles()
+	current := map[string]struct{}{}
+	failed := map[string]bool{}
+	changed := false
+	logger.Verbose("Processing %d networks for macOS resolver configuration", len(*networks.JSON200))
+
+	if len(files) > 0 && !m.IsDryRun() {
+		if err := os.MkdirAll(ResolverDir, 0755); err != nil {
+			logger.Warn("Failed to create %s: %v", ResolverDir, err)
+			countSummary(func(s *RunSummary) { s.Errors++ })
+			return
+		}
+	}
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		file := files[path]
+		delete(stale, path)
+		current[file.entry.Name] = struct{}{}
+
+		if content, err := os.ReadFile(path); err == nil && bytes.Equal(content, file.content) {
+			continue
+		}
+		if m.IsDryRun() {
+			logger.Info("[dry-run] Would write %s:\n%s", path, file.content)
+			continue
+		}
+		if err := os.WriteFile(path, file.content, 0644); err != nil {
+			logger.Warn("Failed to write %s: %v", path, err)
+			failed[file.entry.Name] = true
+			continue
+		}
+		logger.Verbose("Wrote %s for %s", path, file.entry.Name)
+		changed = true
+	}
+
+	if !m.IsDryRun() {
+		recorded := map[string]bool{}
+		for _, path := range paths {
+			entry := files[path].entry
+			if recorded[entry.Name] {
+				continue
+			}
+			recorded[entry.Name] = true
+			if failed[entry.Name] {
+				countSummary(func(s *RunSummary) { s.Errors++ })
+				continue
+			}
+			recordManaged(entry, logger)
+		}
+	}
+
+	for path := range stale {
+		if m.IsDryRun() {
+			logger.Info("[dry-run] Would remove stale %s", path)
+			continue
+		}
+		logger.Info("Removing stale %s", path)
+		if err := os.Remove(path); err != nil {
+			logger.Warn("Failed to remove %s: %v", path, err)
+			countSummary(func(s *RunSummary) { s.Errors++ })
+			continue
+		}
+		changed = true
+	}
+	revertLeft("macos", current, m.IsDryRun(), logger, func(entry state.Interface) {
+		logger.Info("Network %s left, no longer resolving %v through %s", entry.NetworkID, entry.Domains, entry.Name)
+	})
+
+	if changed {
+		flushMacOSCache(logger)
+	}
+}
+
+// flushMacOSCache drops cached answers, so names that failed before the change resolve right away
+func flushMacOSCache(logger *log.Logger) {
+	if !utils.CommandExists("dscacheutil") {
+		return
+	}
+	logger.Debug("Flushing the DNS cache")
+	if out, err := exec.Command("dscacheutil", "-flushcache").CombinedOutput(); err != nil {
+		logger.Debug("dscacheutil -flushcache failed: %v: %s", err, out)
+	}
+	if out, err := exec.Command("killall", "-HUP", "mDNSResponder").CombinedOutput(); err != nil {
+		logger.Debug("Failed to signal mDNSResponder: %v: %s", err, out)
+	}
+}
+
+// resolverDrift compares the resolver files with what would be written
+func resolverDrift(networks *service.GetNetworksResponse, base *BaseMode, logger *log.Logger) []Drift {
+	stale := managedResolverFiles()
+	var drifts []Drift
+	for path, file := range resolverFiles(networks, base, logger) {
+		delete(stale, path)
+		content, err := os.ReadFile(path)
+		if err == nil && bytes.Equal(content, file.content) {
+			continue
+		}
+		d := Drift{Interface: file.entry.Name, NetworkID: file.entry.NetworkID, Kind: DriftFile, Desired: []string{path}}
+		if err == nil {
+			d.Current = []string{path}
+		}
+		drifts = append(drifts, d)
+	}
+	for path := range stale {
+		drifts = append(drifts, Drift{Interface: resolverFileInterface(path), Kind: DriftStale, Current: []string{path}})
+	}
+	return drifts
+}
+
+// resolverFileInterface returns the interface a generated resolver file was written for
+func resolverFileInterface(path string) string {
+	content, _ := os.ReadFile(path)
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(line, "# ZeroTier network ") {
+			if i := strings.LastIndex(line, " on "); i >= 0 {
+				return line[i+len(" on "):]
+			}
+		}
+	}
+	return filepath.Base(path)
+}
+
+// restoreMacOS removes every generated resolver file, returning the interfaces whose files were removed
+func restoreMacOS(dryRun bool, logger *log.Logger) []string {
+	removed := false
+	for path := range managedResolverFiles() {
+		if dryRun {
+			logger.Info("[dry-run] Would remove %s", path)
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			logger.Warn("Failed to remove %s: %v", path, err)
+			continue
+		}
+		logger.Info("Removed %s", path)
+		removed = true
+	}
+	var restored []string
+	if store, err := state.Default(); err == nil {
+		for _, entry := range store.Interfaces() {
+			if entry.Mode != "macos" {
+				continue
+			}
+			if !dryRun {
+				forgetManaged(entry.Name, logger)
+			}
+			restored = append(restored, entry.Name)
+		}
+	}
+	if removed {
+		flushMacOSCache(logger)
+	}
+	return restored
+}
@@ -0,0 +1,55 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/log"
+
+	"sync"
+	"time"
+)
+
+// now is the clock maintenance windows are checked against
+var now = time.Now
+
+// deferringWindow is the maintenance window that deferred the last run, so entering and leaving a
+// window is logged once rather than on every poll
+var (
+	deferringMu     sync.Mutex
+	deferringWindow string
+)
+
+// changesAllowed reports whether a run may apply its changes: enforcement is on and no maintenance
+// window defers them. A run that may not only reports drift.
+func changesAllowed(base *BaseMode, logger *log.Logger) bool {
+	cfg := base.GetConfig().Default
+	if !cfg.Enforcing() {
+		logger.Debug("Enforcement disabled, checking for drift only")
+		return false
+	}
+
+	window, deferring := cfg.Maintenance.Deferring(now())
+	deferringMu.Lock()
+	previous := deferringWindow
+	deferringWindow = window
+	deferringMu.Unlock()
+	switch {
+	case deferring && previous != window:
+		logger.Info("Maintenance window %q is active: changes are deferred, drift is only reported", window)
+	case deferring:
+		logger.Debug("Maintenance window %q is active, checking for drift only", window)
+	case previous != "":
+		logger.Info("Maintenance window %q is over, applying deferred changes", previous)
+	}
+	return !deferring
+}
+
+// Deferring returns the maintenance window that deferred the changes of the last run, or "" if the
+// run wasn't deferred
+func Deferring() string {
+	deferringMu.Lock()
+	defer deferringMu.Unlock()
+	return deferringWindow
+}
@@ -0,0 +1,45 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/log"
+
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindowDefersChanges(t *testing.T) {
+	previous := now
+	now = func() time.Time { return time.Date(2026, time.March, 2, 3, 30, 0, 0, time.UTC) }
+	defer func() { now = previous }()
+	defer func() { deferringWindow = "" }()
+	logger := log.NewScopedLogger("[test]", "")
+
+	var cfg config.Config
+	cfg.Default.Maintenance.DeferWindows = []string{"* * 31 feb *", "* 3 * * *"}
+	if changesAllowed(NewBaseMode(cfg, nil, false, "resolved"), logger) {
+		t.Error("changes allowed inside the window")
+	}
+	if window := Deferring(); window != "* 3 * * *" {
+		t.Errorf("Deferring = %q, want the matching window", window)
+	}
+
+	cfg.Default.Maintenance.DeferWindows = []string{"* * 31 feb *"}
+	if !changesAllowed(NewBaseMode(cfg, nil, false, "resolved"), logger) {
+		t.Error("changes deferred outside the window")
+	}
+	if window := Deferring(); window != "" {
+		t.Errorf("Deferring after the window = %q, want none", window)
+	}
+
+	// Observe-only runs never apply, window or not
+	enforce := false
+	cfg.Default.Enforce = &enforce
+	if changesAllowed(NewBaseMode(cfg, nil, false, "resolved"), logger) {
+		t.Error("changes allowed with enforce: false")
+	}
+}
@@ -0,0 +1,207 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/events"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/state"
+	"zeroplex/pkg/utils"
+
+	"strings"
+)
+
+// recordManaged notes in the state store that an interface is now managed; failures are only logged
+func recordManaged(entry state.Interface, logger *log.Logger) {
+	store, err := state.Default()
+	if err != nil {
+		logger.Debug("State store unavailable, not recording %s: %v", entry.Name, err)
+		return
+	}
+	if entry.AltNames == nil {
+		if _, altNames, err := utils.LinkNames(entry.Name); err == nil {
+			entry.AltNames = altNames
+		}
+	}
+	// Skip the write when nothing but the timestamp would change; reload first so entries
+	// forgotten by another process are recorded again
+	if err := store.Reload(); err != nil {
+		logger.Warn("Failed to reload state store: %v", err)
+	}
+	existing, ok := store.Snapshot().Interfaces[entry.Name]
+	if ok && sameEntry(existing, entry) {
+		countSummary(func(s *RunSummary) { s.Unchanged++ })
+		return
+	}
+	countSummary(func(s *RunSummary) { s.Applied++ })
+	if err := store.SetInterface(entry); err != nil {
+		logger.Warn("Failed to record managed interface %s: %v", entry.Name, err)
+	}
+
+	delta := diffEntries(existing, entry)
+	if !delta.empty() {
+		logger.Info("Changed %s (network %s): %s", entry.Name, entry.NetworkID, delta)
+	}
+	recordDelta(store, "apply", entry, delta, logger)
+	data := delta.data()
+	data["dns"] = entry.DNS
+	data["domains"] = entry.Domains
+	events.Publish(events.Event{
+		Type:      events.TypeApply,
+		Mode:      entry.Mode,
+		Interface: entry.Name,
+		NetworkID: entry.NetworkID,
+		Message:   "DNS settings applied",
+		Data:      data,
+	})
+}
+
+// forgetManaged removes an interface from the state store once it is no longer managed
+func forgetManaged(name string, logger *log.Logger) {
+	store, err := state.Default()
+	if err != nil {
+		logger.Debug("State store unavailable, not forgetting %s: %v", name, err)
+		return
+	}
+	entry := store.Snapshot().Interfaces[name]
+	forgotten, err := store.Forget(name)
+	if err != nil {
+		logger.Warn("Failed to forget interface %s: %v", name, err)
+	}
+	if forgotten {
+		countSummary(func(s *RunSummary) { s.Removed++ })
+		delta := diffEntries(entry, state.Interface{})
+		if !delta.empty() {
+			logger.Info("Restored %s (network %s): %s", name, entry.NetworkID, delta)
+		}
+		recordDelta(store, "restore", entry, delta, logger)
+		events.Publish(events.Event{
+			Type:      events.TypeRestore,
+			Mode:      entry.Mode,
+			Interface: name,
+			NetworkID: entry.NetworkID,
+			Message:   "DNS settings restored",
+			Data:      delta.data(),
+		})
+	}
+}
+
+// entryDelta is what changed between two recorded configurations of an interface
+type entryDelta struct {
+	DNSAdded, DNSRemoved         []string
+	DomainsAdded, DomainsRemoved []string
+}
+
+// diffEntries returns the servers and domains added and removed going from before to after
+func diffEntries(before, after state.Interface) entryDelta {
+	return entryDelta{
+		DNSAdded:       missingFrom(before.DNS, after.DNS),
+		DNSRemoved:     missingFrom(after.DNS, before.DNS),
+		DomainsAdded:   missingFrom(before.Domains, after.Domains),
+		DomainsRemoved: missingFrom(after.Domains, before.Domains),
+	}
+}
+
+// missingFrom returns the items of list that aren't in base, in list order
+func missingFrom(base, list []string) []string {
+	var missing []string
+	for _, item := range list {
+		if !utils.Contains(base, item) {
+			missing = append(missing, item)
+		}
+	}
+	return missing
+}
+
+func (d entryDelta) empty() bool {
+	return len(d.DNSAdded)+len(d.DNSRemoved)+len(d.DomainsAdded)+len(d.DomainsRemoved) == 0
+}
+
+// String renders the delta as e.g. "DNS +10.0.0.2 -10.0.0.1, domains +b.example"
+func (d entryDelta) String() string {
+	var parts []string
+	for _, set := range []struct {
+		name           string
+		added, removed []string
+	}{{"DNS", d.DNSAdded, d.DNSRemoved}, {"domains", d.DomainsAdded, d.DomainsRemoved}} {
+		var changes []string
+		for _, item := range set.added {
+			changes = append(changes, "+"+item)
+		}
+		for _, item := range set.removed {
+			changes = append(changes, "-"+item)
+		}
+		if len(changes) > 0 {
+			parts = append(parts, set.name+" "+strings.Join(changes, " "))
+		}
+	}
+	if len(parts) == 0 {
+		return "no DNS changes"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// data returns the non-empty parts of the delta, keyed as in webhook payloads
+func (d entryDelta) data() map[string]interface{} {
+	data := map[string]interface{}{}
+	for key, items := range map[string][]string{
+		"dns_added": d.DNSAdded, "dns_removed": d.DNSRemoved,
+		"domains_added": d.DomainsAdded, "domains_removed": d.DomainsRemoved,
+	} {
+		if len(items) > 0 {
+			data[key] = items
+		}
+	}
+	return data
+}
+
+// recordDelta adds an apply or restore to the action history, with the delta as its details
+func recordDelta(store *state.Store, operation string, entry state.Interface, delta entryDelta, logger *log.Logger) {
+	details := map[string]string{}
+	for key, items := range delta.data() {
+		details[key] = strings.Join(items.([]string), ",")
+	}
+	if err := store.RecordAction(state.Action{
+		Mode:      entry.Mode,
+		Operation: operation,
+		Interface: entry.Name,
+		NetworkID: entry.NetworkID,
+		Applied:   true,
+		Details:   details,
+	}); err != nil {
+		logger.Debug("Failed to record %s of %s: %v", operation, entry.Name, err)
+	}
+}
+
+// revertLeft reverts and forgets the interfaces recorded for mode whose network is not in current,
+// for backends that keep no bookkeeping of their own besides the state store
+func revertLeft(mode string, current map[string]struct{}, dryRun bool, logger *log.Logger, revert func(state.Interface)) {
+	store, err := state.Default()
+	if err != nil {
+		return
+	}
+	for _, entry := range store.Interfaces() {
+		if entry.Mode != mode {
+			continue
+		}
+		if _, ok := current[entry.Name]; ok {
+			continue
+		}
+		if dryRun {
+			logger.Info("[dry-run] Would revert %s (network %s left)", entry.Name, entry.NetworkID)
+			continue
+		}
+		revert(entry)
+		forgetManaged(entry.Name, logger)
+	}
+}
+
+func sameEntry(a, b state.Interface) bool {
+	return a.Index == b.Index && a.NetworkID == b.NetworkID && a.NetworkName == b.NetworkName && a.Mode == b.Mode &&
+		strings.Join(a.DNS, ",") == strings.Join(b.DNS, ",") &&
+		strings.Join(a.Domains, ",") == strings.Join(b.Domains, ",") &&
+		strings.Join(a.Files, ",") == strings.Join(b.Files, ",") &&
+		strings.Join(a.AltNames, ",") == strings.Join(b.AltNames, ",")
+}
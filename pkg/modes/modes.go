@@ -6,10 +6,13 @@ package modes
 
 import (
 	"zeroplex/pkg/dns"
+	"zeroplex/pkg/initsys"
 	"zeroplex/pkg/log"
+	"zeroplex/pkg/state"
 	"zeroplex/pkg/utils"
 
 	"bytes"
+	"context"
 	"fmt"
 	"html/template"
 	"os"
@@ -17,11 +20,19 @@ import (
 	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/zerotier/go-zerotier-one/service"
 )
 
+// NetworkdConfigDir is where generated .network files are written, unless it is read-only (see
+// NetworkdRuntimeDir)
+var NetworkdConfigDir = "/etc/systemd/network"
+
+// networkdService is the service networkd.max_reloads_per_hour limits the reloads of
+const networkdService = "systemd-networkd"
+
 type templateScaffold struct {
 	FileHeader  string
 	ZTInterface string
@@ -32,11 +43,9 @@ type templateScaffold struct {
 	MDNS        bool
 }
 
-func RunNetworkdMode(networks *service.GetNetworksResponse, addReverseDomains, autoRestart, dnsOverTLS, dryRun, multicastDNS, reconcile bool) {
-	logger := log.NewScopedLogger("[networkd]", "info")
+const networkdFileHeader = "--- Managed by zeroplex. Do not remove this comment. ---"
 
-	const fileheader = "--- Managed by zeroplex. Do not remove this comment. ---"
-	const networkTemplate = `# {{ .FileHeader }}
+const networkdTemplate = `# {{ .FileHeader }}
 [Match]
 Name={{ .ZTInterface }}
 
@@ -57,25 +66,96 @@ ConfigureWithoutCarrier=true
 KeepConfiguration=static
 `
 
-	logger.Trace(">>> RunNetworkdMode() started")
-	logger.Debug("RunNetworkdMode parameters: addReverse=%t, autoRestart=%t, dnsOverTLS=%t, dryRun=%t, mDNS=%t, reconcile=%t",
-		addReverseDomains, autoRestart, dnsOverTLS, dryRun, multicastDNS, reconcile)
+var networkdTmpl = template.Must(template.New("network").Parse(networkdTemplate))
+
+// networkdFilePath returns the generated .network file for an interface
+func networkdFilePath(interfaceName string) string {
+	return fmt.Sprintf("%s/99-%s.network", networkdDir(), interfaceName)
+}
+
+// renderNetworkdFile renders the .network file contents for a network, whose overrides under
+// networks: replace dnsOverTLS and multicastDNS
+func renderNetworkdFile(network service.Network, addReverseDomains, dnsOverTLS, multicastDNS bool, extraSearchDomains []string) (templateScaffold, []byte, error) {
+	searchkeys := searchDomains(network, addReverseDomains, extraSearchDomains)
+
+	out := templateScaffold{
+		ZTInterface: *network.PortDeviceName,
+		ZTNetwork:   *network.Name,
+		DNS:         *network.Dns.Servers,
+		Domain:      strings.Join(searchkeys, " "),
+		FileHeader:  networkdFileHeader,
+		DNS_TLS:     dnsOverTLSOf(network, dnsOverTLS),
+		MDNS:        multicastDNSOf(network, multicastDNS),
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := networkdTmpl.Execute(buf, out); err != nil {
+		return out, nil, err
+	}
+	return out, buf.Bytes(), nil
+}
 
-	t, err := template.New("network").Parse(networkTemplate)
+// managedNetworkdFiles returns the generated 99-*.network files currently in the directory they
+// are written to
+func managedNetworkdFiles() (map[string]struct{}, error) {
+	found := map[string]struct{}{}
+	entries, err := os.ReadDir(networkdDir())
 	if err != nil {
-		logger.Debug("Template parsing error: %v", err)
-		utils.ErrorHandler("Failed to parse template", err, true)
+		return found, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, "99-") || !strings.HasSuffix(name, ".network") {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(networkdDir(), name))
+		if err == nil && bytes.Contains(content, []byte(networkdFileHeader)) {
+			found[name] = struct{}{}
+		}
 	}
+	return found, nil
+}
+
+// networkdEntry describes a generated .network file for the state store
+func networkdEntry(network service.Network, out templateScaffold, fn string) state.Interface {
+	index, _ := dns.LinkIndex(out.ZTInterface)
+	return state.Interface{
+		Name:        out.ZTInterface,
+		Index:       index,
+		NetworkID:   utils.GetString(network.Id),
+		NetworkName: out.ZTNetwork,
+		Mode:        "networkd",
+		DNS:         out.DNS,
+		Domains:     strings.Fields(out.Domain),
+		Files:       []string{fn},
+	}
+}
 
-	serviceAvailable := utils.ServiceExists("systemd-networkd.service")
+func RunNetworkdMode(ctx context.Context, networks *service.GetNetworksResponse, addReverseDomains, autoRestart, dnsOverTLS, dryRun, multicastDNS, reconcile bool, extraSearchDomains []string, maxReloadsPerHour int, waitForCarrier string) {
+	logger := log.NewScopedLogger("[networkd]", "").WithContext(ctx)
+
+	logger.Trace(">>> RunNetworkdMode() started")
+	logger.Debug("RunNetworkdMode parameters: addReverse=%t, autoRestart=%t, dnsOverTLS=%t, dryRun=%t, mDNS=%t, reconcile=%t",
+		addReverseDomains, autoRestart, dnsOverTLS, dryRun, multicastDNS, reconcile)
+
+	serviceAvailable := initsys.Current().IsActive("systemd-networkd")
 	if !serviceAvailable {
 		logger.Debug("systemd-networkd.service is not available; changes will not trigger a service restart")
 	} else {
 		logger.Debug("systemd-networkd.service is available")
 	}
 
-	found := map[string]struct{}{}
+	checkNetworkdDir(dryRun, logger)
+	if !dryRun {
+		defer syncNetworkdIntent(logger)
+	}
+
+	// Collect previously generated files so networks that have been left can be reconciled
+	found, err := managedNetworkdFiles()
+	if err != nil {
+		logger.Debug("Could not list %s: %v", networkdDir(), err)
+	}
 	var changed bool
+	var written []string
 
 	logger.Verbose("Processing %d networks for networkd configuration", len(*networks.JSON200))
 
@@ -84,49 +164,21 @@ KeepConfiguration=static
 			i+1, len(*networks.JSON200),
 			utils.GetString(network.PortDeviceName), utils.GetString(network.Name), utils.GetString(network.Id))
 
-		fn := fmt.Sprintf("%s/99-%s.network", "/etc/systemd/network", *network.PortDeviceName)
+		fn := networkdFilePath(*network.PortDeviceName)
 		logger.Trace("Target file: %s", fn)
 
 		delete(found, path.Base(fn))
 
-		search := map[string]struct{}{}
-
 		if network.Dns.Domain != nil {
-			search[*network.Dns.Domain] = struct{}{}
 			logger.Debug("Added DNS domain to search: %s, DNS servers: %v", *network.Dns.Domain, *network.Dns.Servers)
 		}
 
-		if addReverseDomains {
-			logger.Trace("Calculating reverse domains for assigned addresses")
-			reverseDomains := dns.CalculateReverseDomains(network.AssignedAddresses)
-			for _, domain := range reverseDomains {
-				search[domain] = struct{}{}
-				logger.Debug("Added reverse domain to search: %s", domain)
-			}
-		}
-
-		searchkeys := []string{}
-		for key := range search {
-			searchkeys = append(searchkeys, key)
-		}
-		sort.Strings(searchkeys)
-		logger.Verbose("Search domains for %s: %v", utils.GetString(network.PortDeviceName), searchkeys)
-
-		out := templateScaffold{
-			ZTInterface: *network.PortDeviceName,
-			ZTNetwork:   *network.Name,
-			DNS:         *network.Dns.Servers,
-			Domain:      strings.Join(searchkeys, " "),
-			FileHeader:  fileheader,
-			DNS_TLS:     dnsOverTLS,
-			MDNS:        multicastDNS,
-		}
-
-		buf := bytes.NewBuffer(nil)
-		if err := t.Execute(buf, out); err != nil {
+		out, rendered, err := renderNetworkdFile(network, addReverseDomains, dnsOverTLS, multicastDNS, extraSearchDomains)
+		if err != nil {
 			logger.Debug("Error executing template for %q: %v", fn, err)
 			utils.ErrorHandler(fmt.Sprintf("Failed to execute template for %q", fn), err, true)
 		}
+		logger.Verbose("Search domains for %s: %v", utils.GetString(network.PortDeviceName), out.Domain)
 		logger.Trace("Template executed successfully for %s", fn)
 
 		if dryRun {
@@ -141,8 +193,9 @@ KeepConfiguration=static
 				utils.ErrorHandler(fmt.Sprintf("Failed to read file %q", fn), err, true)
 			}
 
-			if bytes.Equal(content, buf.Bytes()) {
+			if bytes.Equal(content, rendered) {
 				logger.Info("No changes needed for file %s; already up-to-date", fn)
+				recordManaged(networkdEntry(network, out, fn), logger)
 				continue
 			}
 			logger.Debug("File %s needs updating", fn)
@@ -158,7 +211,7 @@ KeepConfiguration=static
 		}
 		logger.Debug("Successfully created file %s", fn)
 
-		if _, err := f.Write(buf.Bytes()); err != nil {
+		if _, err := f.Write(rendered); err != nil {
 			logger.Debug("Error writing to file %s: %v", fn, err)
 			utils.ErrorHandler("Failed to write to file", err, true)
 		}
@@ -168,11 +221,13 @@ KeepConfiguration=static
 		logger.Debug("Closed file %s", fn)
 
 		changed = true
+		written = append(written, *network.PortDeviceName)
+		recordManaged(networkdEntry(network, out, fn), logger)
 
 		if changed {
-			logger.Info("Processed Interface=%s, Network=%s, ID=%s, DNS Search Domain=%s, DNS Servers=%v, wrote to /etc/systemd/network/99-%s.network",
+			logger.Verbose("Processed Interface=%s, Network=%s, ID=%s, DNS Search Domain=%s, DNS Servers=%v, wrote to %s",
 				utils.GetString(network.PortDeviceName), utils.GetString(network.Name), utils.GetString(network.Id),
-				utils.GetString(network.Dns.Domain), *network.Dns.Servers, utils.GetString(network.PortDeviceName))
+				utils.GetString(network.Dns.Domain), *network.Dns.Servers, fn)
 		}
 	}
 
@@ -187,19 +242,24 @@ KeepConfiguration=static
 				continue
 			}
 
-			if err := os.Remove(filepath.Join("/etc/systemd/network", fn)); err != nil {
+			if err := os.Remove(filepath.Join(networkdDir(), fn)); err != nil {
 				utils.ErrorHandler(fmt.Sprintf("Failed to remove file %q", fn), err, true)
 			}
+			forgetManaged(strings.TrimSuffix(strings.TrimPrefix(fn, "99-"), ".network"), logger)
 		}
 	}
 
-	if (changed || len(found) > 0) && autoRestart && serviceAvailable {
+	if (changed || len(found) > 0 || reloadIsPending(networkdService)) && autoRestart && serviceAvailable {
 		logger.Info("Files changed; reloading systemd-networkd...")
 
 		if dryRun {
 			logger.Debug("Would reload systemd-networkd")
 			return
 		}
+		if !allowReload("networkd", networkdService, "networkd.max_reloads_per_hour", maxReloadsPerHour, logger) {
+			return
+		}
+		awaitCarrier(ctx, written, waitForCarrier, logger)
 
 		if err := exec.Command("networkctl", "reload").Run(); err != nil {
 			utils.ErrorHandler("Failed to reload systemd-networkd", err, true)
@@ -211,8 +271,8 @@ KeepConfiguration=static
 
 var managedZTInterfaces = make(map[string]struct{})
 
-func RunResolvedMode(networks *service.GetNetworksResponse, addReverseDomains, dnsOverTLS, multicastDNS, dryRun bool, logLevel string) {
-	logger := log.NewScopedLogger("[resolved]", logLevel)
+func RunResolvedMode(ctx context.Context, networks *service.GetNetworksResponse, addReverseDomains, dnsOverTLS, multicastDNS, dryRun bool, logLevel string, extraSearchDomains []string) {
+	logger := log.NewScopedLogger("[resolved]", logLevel).WithContext(ctx)
 
 	if !utils.CommandExists("resolvectl") {
 		utils.ErrorHandler("resolvectl is required for systemd-resolved but is not available on this system", nil, true)
@@ -249,8 +309,12 @@ func RunResolvedMode(networks *service.GetNetworksResponse, addReverseDomains, d
 	for iface := range managedZTInterfaces {
 		if _, stillPresent := currentZT[iface]; !stillPresent {
 			logger.Info("Interface %s no longer present in ZeroTier networks, restoring original DNS", iface)
-			dns.RestoreSavedDNS(iface, logLevel)
+			dns.RestoreSavedDNS(ctx, iface, logLevel)
+			dns.ForgetLinkFailure(iface)
 			delete(managedZTInterfaces, iface)
+			if !dryRun {
+				forgetManaged(iface, logger)
+			}
 		}
 	}
 
@@ -258,97 +322,142 @@ func RunResolvedMode(networks *service.GetNetworksResponse, addReverseDomains, d
 		logger.Verbose("Processing network: Interface=%s, Name=%s, ID=%s", utils.GetString(network.PortDeviceName), utils.GetString(network.Name), utils.GetString(network.Id))
 
 		if network.Dns != nil && len(*network.Dns.Servers) != 0 {
-			interfaceName := *network.PortDeviceName
-			dnsServers := *network.Dns.Servers
-			dnsSearch := ""
-
-			if network.Dns.Domain != nil {
-				dnsSearch = *network.Dns.Domain
-			}
+			applyResolvedLink(ctx, network, addReverseDomains, dnsOverTLS, multicastDNS, dryRun, logLevel, extraSearchDomains, logger)
+		}
+	}
+}
 
-			// Calculate in-addr.arpa and ip6.arpa search domains
-			searchDomains := map[string]struct{}{}
-			if dnsSearch != "" {
-				searchDomains[dnsSearch] = struct{}{}
+// applyResolvedLink applies the DNS servers, routing domains, mDNS, DNS-over-TLS and DNSSEC
+// negative trust anchors of network to its link. The link is locked for the whole sequence so a
+// concurrent restore of it can't interleave.
+func applyResolvedLink(ctx context.Context, network service.Network, addReverseDomains, dnsOverTLS, multicastDNS, dryRun bool, logLevel string, extraSearchDomains []string, logger *log.Logger) {
+	interfaceName := *network.PortDeviceName
+	dnsServers := *network.Dns.Servers
+	defer dns.LockInterface(interfaceName)()
+
+	// DNS domain plus in-addr.arpa/ip6.arpa and extra search domains
+	searchKeys := []string{}
+	for _, key := range searchDomains(network, addReverseDomains, extraSearchDomains) {
+		// Ensure tilde prefix for systemd-resolved split DNS
+		if !strings.HasPrefix(key, "~") {
+			key = "~" + key
+		}
+		searchKeys = append(searchKeys, key)
+	}
+	sort.Strings(searchKeys)
+
+	// Save original DNS before first change
+	dns.SaveCurrentDNSIfNeeded(ctx, interfaceName, logLevel)
+	managedZTInterfaces[interfaceName] = struct{}{}
+	if err := dns.ConfigureDNSAndSearchDomains(ctx, interfaceName, dnsServers, searchKeys, dryRun, logLevel); err != nil {
+		// The daemon retries the interface on its own, see runner.startLinkRetries
+		logger.Warn("Failed to apply DNS to %s: %v", interfaceName, err)
+		countSummary(func(s *RunSummary) { s.Errors++ })
+	}
+	if !dryRun {
+		// Address the link by ifindex, falling back to the name if it can't be resolved
+		link := interfaceName
+		index, err := dns.LinkIndex(interfaceName)
+		if err == nil {
+			link = strconv.Itoa(index)
+		} else {
+			logger.Debug("Could not resolve ifindex for %s, using name: %v", interfaceName, err)
+		}
+		recordManaged(state.Interface{
+			Name:        interfaceName,
+			Index:       index,
+			NetworkID:   utils.GetString(network.Id),
+			NetworkName: utils.GetString(network.Name),
+			Mode:        "resolved",
+			DNS:         dnsServers,
+			Domains:     searchKeys,
+		}, logger)
+
+		// mDNS
+		mdnsValue := "no"
+		if multicastDNSOf(network, multicastDNS) {
+			mdnsValue = "yes"
+		}
+		// Query current mDNS setting
+		currentMDNS := ""
+		if out, err := utils.ExecuteCommand("resolvectl", "mdns", link); err == nil {
+			currentMDNS = parseResolvectlStatus(out)
+			logger.Trace("Current mDNS for %s (get): %s", interfaceName, currentMDNS)
+		}
+		logger.Debug("Checking mDNS for %s: current=%s, desired=%s", interfaceName, currentMDNS, mdnsValue)
+		if currentMDNS != mdnsValue {
+			logger.Debug("Setting mDNS for %s: %s -> %s", interfaceName, currentMDNS, mdnsValue)
+			logger.Trace("Running: resolvectl mdns %s %s", link, mdnsValue)
+			if out, err := utils.ExecuteCommand("resolvectl", "mdns", link, mdnsValue); err != nil {
+				logger.Warn("Failed to set mDNS (%s) for %s: %v", mdnsValue, interfaceName, err)
+				countSummary(func(s *RunSummary) { s.Errors++ })
+			} else if strings.TrimSpace(out) != "" {
+				logger.Trace("resolvectl mdns output: %s", out)
 			}
+			logger.Verbose("Set mDNS (%s) for %s", mdnsValue, interfaceName)
+		} else {
+			logger.Trace("mDNS for %s already set to %s, no change needed", interfaceName, mdnsValue)
+		}
 
-			if addReverseDomains {
-				reverseDomains := dns.CalculateReverseDomains(network.AssignedAddresses)
-				for _, domain := range reverseDomains {
-					searchDomains[domain] = struct{}{}
-				}
+		// DNS-over-TLS
+		dotValue := "no"
+		if dnsOverTLSOf(network, dnsOverTLS) {
+			dotValue = "yes"
+		}
+		currentDOT := ""
+		if out, err := utils.ExecuteCommand("resolvectl", "dnsovertls", link); err == nil {
+			currentDOT = parseResolvectlStatus(out)
+			logger.Trace("Current DNS-over-TLS for %s (get): %s", interfaceName, currentDOT)
+		}
+		logger.Debug("Checking DNS-over-TLS for %s: current=%s, desired=%s", interfaceName, currentDOT, dotValue)
+		if currentDOT != dotValue {
+			logger.Debug("Setting DNS-over-TLS for %s: %s -> %s", interfaceName, currentDOT, dotValue)
+			logger.Trace("Running: resolvectl dnsovertls %s %s", link, dotValue)
+			if out, err := utils.ExecuteCommand("resolvectl", "dnsovertls", link, dotValue); err != nil {
+				logger.Warn("Failed to set DNS-over-TLS (%s) for %s: %v", dotValue, interfaceName, err)
+				countSummary(func(s *RunSummary) { s.Errors++ })
+			} else if strings.TrimSpace(out) != "" {
+				logger.Trace("resolvectl dnsovertls output: %s", out)
 			}
+			logger.Verbose("Set DNS-over-TLS (%s) for %s", dotValue, interfaceName)
+		} else {
+			logger.Trace("DNS-over-TLS for %s already set to %s, no change needed", interfaceName, dotValue)
+		}
 
-			searchKeys := []string{}
-			for key := range searchDomains {
-				// Ensure tilde prefix for systemd-resolved split DNS
-				if !strings.HasPrefix(key, "~") {
-					key = "~" + key
-				}
-				searchKeys = append(searchKeys, key)
+		// DNSSEC negative trust anchors: ZeroTier domains aren't signed, so validation would fail
+		// them. They are dropped again by the resolvectl revert on restore.
+		if dns.DNSSECEnabled(link) {
+			anchors := negativeTrustAnchors(searchKeys)
+			current, err := dns.NegativeTrustAnchors(link)
+			if err != nil {
+				logger.Debug("Could not read negative trust anchors for %s: %v", interfaceName, err)
 			}
-			sort.Strings(searchKeys)
-
-			// Save original DNS before first change
-			dns.SaveCurrentDNSIfNeeded(interfaceName, logLevel)
-			managedZTInterfaces[interfaceName] = struct{}{}
-			dns.ConfigureDNSAndSearchDomains(interfaceName, dnsServers, searchKeys, dryRun, logLevel)
-
-			if !dryRun {
-				// mDNS
-				mdnsValue := "no"
-				if multicastDNS {
-					mdnsValue = "yes"
-				}
-				// Query current mDNS setting
-				currentMDNS := ""
-				if out, err := utils.ExecuteCommand("resolvectl", "mdns", interfaceName); err == nil {
-					currentMDNS = parseResolvectlStatus(out)
-					logger.Trace("Current mDNS for %s (get): %s", interfaceName, currentMDNS)
-				}
-				logger.Debug("Checking mDNS for %s: current=%s, desired=%s", interfaceName, currentMDNS, mdnsValue)
-				if currentMDNS != mdnsValue {
-					logger.Debug("Setting mDNS for %s: %s -> %s", interfaceName, currentMDNS, mdnsValue)
-					logger.Trace("Running: resolvectl mdns %s %s", interfaceName, mdnsValue)
-					if out, err := utils.ExecuteCommand("resolvectl", "mdns", interfaceName, mdnsValue); err != nil {
-						logger.Warn("Failed to set mDNS (%s) for %s: %v", mdnsValue, interfaceName, err)
-					} else if strings.TrimSpace(out) != "" {
-						logger.Trace("resolvectl mdns output: %s", out)
-					}
-					logger.Verbose("Set mDNS (%s) for %s", mdnsValue, interfaceName)
+			if !dns.CompareDNS(current, anchors) {
+				logger.Trace("Running: resolvectl nta %s %s", link, strings.Join(anchors, " "))
+				if err := dns.SetNegativeTrustAnchors(link, anchors); err != nil {
+					logger.Warn("Failed to set DNSSEC negative trust anchors for %s: %v", interfaceName, err)
+					countSummary(func(s *RunSummary) { s.Errors++ })
 				} else {
-					logger.Trace("mDNS for %s already set to %s, no change needed", interfaceName, mdnsValue)
-				}
-
-				// DNS-over-TLS
-				dotValue := "no"
-				if dnsOverTLS {
-					dotValue = "yes"
-				}
-				currentDOT := ""
-				if out, err := utils.ExecuteCommand("resolvectl", "dnsovertls", interfaceName); err == nil {
-					currentDOT = parseResolvectlStatus(out)
-					logger.Trace("Current DNS-over-TLS for %s (get): %s", interfaceName, currentDOT)
-				}
-				logger.Debug("Checking DNS-over-TLS for %s: current=%s, desired=%s", interfaceName, currentDOT, dotValue)
-				if currentDOT != dotValue {
-					logger.Debug("Setting DNS-over-TLS for %s: %s -> %s", interfaceName, currentDOT, dotValue)
-					logger.Trace("Running: resolvectl dnsovertls %s %s", interfaceName, dotValue)
-					if out, err := utils.ExecuteCommand("resolvectl", "dnsovertls", interfaceName, dotValue); err != nil {
-						logger.Warn("Failed to set DNS-over-TLS (%s) for %s: %v", dotValue, interfaceName, err)
-					} else if strings.TrimSpace(out) != "" {
-						logger.Trace("resolvectl dnsovertls output: %s", out)
-					}
-					logger.Verbose("Set DNS-over-TLS (%s) for %s", dotValue, interfaceName)
-				} else {
-					logger.Trace("DNS-over-TLS for %s already set to %s, no change needed", interfaceName, dotValue)
+					logger.Verbose("Set DNSSEC negative trust anchors for %s: %v", interfaceName, anchors)
 				}
 			} else {
-				logger.Info("[dry-run] Would set mDNS (%v) and DNS-over-TLS (%v) for %s", multicastDNS, dnsOverTLS, interfaceName)
+				logger.Trace("Negative trust anchors for %s already set to %v, no change needed", interfaceName, anchors)
 			}
-			// --- End new code ---
+		}
+	} else {
+		logger.Info("[dry-run] Would set mDNS (%v) and DNS-over-TLS (%v) for %s", multicastDNS, dnsOverTLS, interfaceName)
+	}
+}
+
+// negativeTrustAnchors returns the routing domains as plain domain names for resolvectl nta
+func negativeTrustAnchors(searchKeys []string) []string {
+	anchors := make([]string, 0, len(searchKeys))
+	for _, key := range searchKeys {
+		if domain := strings.TrimPrefix(key, "~"); domain != "" && domain != "." {
+			anchors = append(anchors, domain)
 		}
 	}
+	return anchors
 }
 
 // parseResolvectlStatus extracts the value (e.g. "no" or "yes") from the output of resolvectl mdns/dnsovertls
@@ -5,9 +5,10 @@
 package modes
 
 import (
+	"zeroplex/pkg/client"
 	"zeroplex/pkg/config"
+	"zeroplex/pkg/initsys"
 	"zeroplex/pkg/log"
-	"zeroplex/pkg/utils"
 
 	"context"
 	"fmt"
@@ -21,29 +22,29 @@ type NetworkdMode struct {
 }
 
 // NewNetworkdMode creates a new networkd mode runner
-func NewNetworkdMode(cfg config.Config, dryRun bool) (*NetworkdMode, error) {
-	logger := log.NewScopedLogger("[modes/networkd]", "info")
+func NewNetworkdMode(cfg config.Config, zt *client.Client, dryRun bool) (*NetworkdMode, error) {
+	logger := log.NewScopedLogger("[modes/networkd]", cfg.Default.Log.Level)
 	// Verify systemd-networkd is available
-	if !utils.ServiceExists("systemd-networkd.service") {
-		logger.Error("systemd-networkd.service is not available")
-		return nil, fmt.Errorf("systemd-networkd.service is not available")
+	if !initsys.Current().IsActive("systemd-networkd") {
+		logger.Error("systemd-networkd is not available (%s)", initsys.Current().Name())
+		return nil, fmt.Errorf("systemd-networkd is not available")
 	}
 
 	return &NetworkdMode{
-		BaseMode: NewBaseMode(cfg, dryRun, "networkd"),
+		BaseMode: NewBaseMode(cfg, zt, dryRun, "networkd"),
 	}, nil
 }
 
 // GetMode returns the mode name
 func (n *NetworkdMode) GetMode() string {
-	logger := log.NewScopedLogger("[modes/networkd]", "info")
+	logger := log.NewScopedLogger("[modes/networkd]", n.GetConfig().Default.Log.Level)
 	logger.Trace("GetMode called")
 	return "networkd"
 }
 
 // Run executes the networkd mode logic
 func (n *NetworkdMode) Run(ctx context.Context) error {
-	logger := log.NewScopedLogger("[modes/networkd]", n.GetConfig().Default.Log.Level)
+	logger := log.NewScopedLogger("[modes/networkd]", n.GetConfig().Default.Log.Level).WithContext(ctx)
 	logger.Trace(">>> NetworkdMode.Run() started")
 	logger.Debug("Running in networkd mode (dry-run: %t)", n.IsDryRun())
 
@@ -53,6 +54,12 @@ func (n *NetworkdMode) Run(ctx context.Context) error {
 		n.GetConfig().Default.Features.DNSOverTLS, n.GetConfig().Default.Networkd.AutoRestart, n.GetConfig().Default.Features.AddReverseDomains,
 		n.GetConfig().Default.Features.MulticastDNS, n.GetConfig().Default.Networkd.Reconcile)
 
+	// After a reboot, put back the files kept for a read-only /etc before waiting on ZeroTier
+	if cfg := n.GetConfig().Default; !n.IsDryRun() && cfg.Enforcing() {
+		checkNetworkdDir(false, logger)
+		reapplyNetworkdIntent(ctx, cfg.Networkd, logger)
+	}
+
 	// Use BaseMode.ProcessNetworks for all network fetching, logging, and filtering
 	networks, err := n.ProcessNetworks(ctx)
 	if err != nil {
@@ -60,6 +67,15 @@ func (n *NetworkdMode) Run(ctx context.Context) error {
 		return fmt.Errorf("failed to process networks: %w", err)
 	}
 
+	if !changesAllowed(n.BaseMode, logger) {
+		reportDrift("networkd", networkdDrift(networks, n.BaseMode, logger), logger)
+		return nil
+	}
+
+	if err := guardChanges("networkd", networks, func() []Drift { return networkdDrift(networks, n.BaseMode, logger) }, n.BaseMode, logger); err != nil {
+		return err
+	}
+
 	// Process networks for networkd
 	logger.Verbose("Processing networks for systemd-networkd configuration")
 	logger.Trace("Calling processNetworks() for systemd-networkd integration")
@@ -75,11 +91,12 @@ func (n *NetworkdMode) Run(ctx context.Context) error {
 
 // processNetworks handles the actual network processing for networkd
 func (n *NetworkdMode) processNetworks(ctx context.Context, networks *service.GetNetworksResponse) error {
-	logger := log.NewScopedLogger("[modes/networkd]", "info")
+	logger := log.NewScopedLogger("[modes/networkd]", n.GetConfig().Default.Log.Level).WithContext(ctx)
 	logger.Trace("processNetworks called")
 	// Call the existing networkd implementation directly
-	RunNetworkdMode(networks, n.GetConfig().Default.Features.AddReverseDomains, n.GetConfig().Default.Networkd.AutoRestart,
-		n.GetConfig().Default.Features.DNSOverTLS, n.IsDryRun(), n.GetConfig().Default.Features.MulticastDNS, n.GetConfig().Default.Networkd.Reconcile)
+	RunNetworkdMode(ctx, networks, n.GetConfig().Default.Features.AddReverseDomains, n.GetConfig().Default.Networkd.AutoRestart,
+		n.GetConfig().Default.Features.DNSOverTLS, n.IsDryRun(), n.resolveMDNSConflict(networks), n.GetConfig().Default.Networkd.Reconcile,
+		n.GetConfig().Default.Features.ExtraSearchDomains, n.GetConfig().Default.Networkd.MaxReloadsPerHour, n.GetConfig().Default.Networkd.WaitForCarrier)
 
-	return nil
+	return n.VerifyApplied(ctx, networks)
 }
@@ -0,0 +1,156 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/initsys"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/state"
+
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"syscall"
+)
+
+// NetworkdRuntimeDir is where generated .network files are written while NetworkdConfigDir is on
+// a read-only filesystem, as /etc is on ostree based systems. networkd reads it too, but it is
+// emptied at boot, so the files are also kept in the state directory to be re-created from there.
+var NetworkdRuntimeDir = "/run/systemd/network"
+
+// networkdIntentFile is the file of the state directory holding the .network files written to
+// NetworkdRuntimeDir
+const networkdIntentFile = "networkd-runtime.json"
+
+// networkdReadOnly is set while NetworkdConfigDir is found to be read-only
+var networkdReadOnly atomic.Bool
+
+// networkdDir returns the directory generated .network files are written to
+func networkdDir() string {
+	if networkdReadOnly.Load() {
+		return NetworkdRuntimeDir
+	}
+	return NetworkdConfigDir
+}
+
+// checkNetworkdDir finds out whether NetworkdConfigDir can be written to, switching to
+// NetworkdRuntimeDir while it is on a read-only filesystem. A dry run writes nothing, so it keeps
+// what the last run or the state directory says.
+func checkNetworkdDir(dryRun bool, logger *log.Logger) {
+	if dryRun {
+		_, err := os.Stat(state.DefaultDir().File(networkdIntentFile))
+		networkdReadOnly.Store(err == nil)
+		return
+	}
+	probe, err := os.CreateTemp(NetworkdConfigDir, ".zeroplex-*")
+	if err == nil {
+		probe.Close()
+		os.Remove(probe.Name())
+	}
+	readOnly := errors.Is(err, syscall.EROFS)
+	if networkdReadOnly.Swap(readOnly) != readOnly {
+		if readOnly {
+			logger.Warn("%s is on a read-only filesystem; writing .network files to %s instead, and keeping them in the state directory to re-create them at boot",
+				NetworkdConfigDir, NetworkdRuntimeDir)
+		} else {
+			logger.Info("%s is writable again; writing .network files there", NetworkdConfigDir)
+		}
+	}
+	if readOnly {
+		if err := os.MkdirAll(NetworkdRuntimeDir, 0755); err != nil {
+			logger.Error("Failed to create %s: %v", NetworkdRuntimeDir, err)
+		}
+	}
+}
+
+// syncNetworkdIntent keeps the state directory's copy of the .network files in NetworkdRuntimeDir
+// up to date, or removes it once NetworkdConfigDir is written to again
+func syncNetworkdIntent(logger *log.Logger) {
+	dir := state.DefaultDir()
+	if !networkdReadOnly.Load() {
+		if err := os.Remove(dir.File(networkdIntentFile)); err == nil {
+			logger.Debug("Removed %s, .network files are written to %s", networkdIntentFile, NetworkdConfigDir)
+		}
+		return
+	}
+	found, err := managedNetworkdFiles()
+	if err != nil {
+		logger.Warn("Could not list %s: %v", NetworkdRuntimeDir, err)
+		return
+	}
+	files := map[string]string{}
+	for name := range found {
+		content, err := os.ReadFile(filepath.Join(NetworkdRuntimeDir, name))
+		if err != nil {
+			logger.Warn("Could not read %s: %v", name, err)
+			continue
+		}
+		files[name] = string(content)
+	}
+	data, err := json.MarshalIndent(files, "", "  ")
+	if err == nil {
+		err = dir.WriteFile(networkdIntentFile, data, 0600)
+	}
+	if err != nil {
+		logger.Warn("Failed to save the .network files of %s in the state directory, they will be missing after a reboot until ZeroTier answers: %v", NetworkdRuntimeDir, err)
+	}
+}
+
+// reapplyNetworkdIntent re-creates the .network files missing from NetworkdRuntimeDir, which is
+// emptied at boot, from the state directory's copy, and reloads networkd if it did. It runs before
+// ZeroTier is asked for its networks, so DNS works from boot even if ZeroTier is slow to start.
+func reapplyNetworkdIntent(ctx context.Context, cfg config.NetworkdConfig, logger *log.Logger) {
+	if !networkdReadOnly.Load() {
+		return
+	}
+	data, err := os.ReadFile(state.DefaultDir().File(networkdIntentFile))
+	if err != nil {
+		return
+	}
+	var files map[string]string
+	if err := json.Unmarshal(data, &files); err != nil {
+		logger.Warn("Ignoring %s: %v", networkdIntentFile, err)
+		return
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var created []string
+	for _, name := range names {
+		fn := filepath.Join(NetworkdRuntimeDir, filepath.Base(name))
+		if _, err := os.Stat(fn); err == nil {
+			continue
+		}
+		if err := os.WriteFile(fn, []byte(files[name]), 0644); err != nil {
+			logger.Warn("Failed to re-create %s: %v", fn, err)
+			continue
+		}
+		created = append(created, name)
+	}
+	if len(created) == 0 {
+		return
+	}
+	logger.Info("Re-created %v in %s from the state directory", created, NetworkdRuntimeDir)
+	if cfg.AutoRestart && initsys.Current().IsActive("systemd-networkd") &&
+		allowReload("networkd", networkdService, "networkd.max_reloads_per_hour", cfg.MaxReloadsPerHour, logger) {
+		var interfaces []string
+		for _, name := range created {
+			interfaces = append(interfaces, strings.TrimSuffix(strings.TrimPrefix(name, "99-"), ".network"))
+		}
+		awaitCarrier(ctx, interfaces, cfg.WaitForCarrier, logger)
+		if err := exec.Command("networkctl", "reload").Run(); err != nil {
+			logger.Warn("Failed to reload systemd-networkd: %v", err)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/client"
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/dns"
+	"zeroplex/pkg/initsys"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/state"
+	"zeroplex/pkg/utils"
+
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+// NetworkManagerMode applies ZeroTier DNS servers and search domains through NetworkManager, for
+// desktops where NetworkManager rather than systemd-networkd owns the interfaces. Settings are
+// applied to the active device with "nmcli device modify", so the saved connection profile is never
+// changed and "nmcli device reapply" restores it.
+type NetworkManagerMode struct {
+	*BaseMode
+}
+
+// NewNetworkManagerMode creates a new NetworkManager mode runner
+func NewNetworkManagerMode(cfg config.Config, zt *client.Client, dryRun bool) (*NetworkManagerMode, error) {
+	logger := log.NewScopedLogger("[modes/networkmanager]", cfg.Default.Log.Level)
+	if !initsys.Current().IsActive("NetworkManager") {
+		logger.Error("NetworkManager is not running (%s)", initsys.Current().Name())
+		return nil, fmt.Errorf("NetworkManager is not running")
+	}
+	if !utils.CommandExists("nmcli") {
+		logger.Error("nmcli command not found")
+		return nil, fmt.Errorf("nmcli is required for NetworkManager but is not available")
+	}
+
+	return &NetworkManagerMode{
+		BaseMode: NewBaseMode(cfg, zt, dryRun, "networkmanager"),
+	}, nil
+}
+
+// GetMode returns the mode name
+func (n *NetworkManagerMode) GetMode() string {
+	return "networkmanager"
+}
+
+// Run executes the NetworkManager mode logic
+func (n *NetworkManagerMode) Run(ctx context.Context) error {
+	logger := log.NewScopedLogger("[modes/networkmanager]", n.GetConfig().Default.Log.Level).WithContext(ctx)
+	logger.Trace(">>> NetworkManagerMode.Run() started")
+	logger.Debug("Running in networkmanager mode (dry-run: %t)", n.IsDryRun())
+
+	networks, err := n.ProcessNetworks(ctx)
+	if err != nil {
+		logger.Error("Failed to process networks: %v", err)
+		return fmt.Errorf("failed to process networks: %w", err)
+	}
+
+	if !changesAllowed(n.BaseMode, logger) {
+		reportDrift("networkmanager", networkManagerDrift(networks, n.BaseMode, logger), logger)
+		return nil
+	}
+
+	if err := guardChanges("networkmanager", networks, func() []Drift { return networkManagerDrift(networks, n.BaseMode, logger) }, n.BaseMode, logger); err != nil {
+		return err
+	}
+
+	n.processNetworks(networks, logger)
+
+	if utils.CommandExists("resolvectl") {
+		if err := n.VerifyApplied(ctx, networks); err != nil {
+			return err
+		}
+	} else if n.GetConfig().Default.Features.VerifyHostname != "" {
+		logger.Debug("Skipping apply verification, resolvectl is not available")
+	}
+
+	logger.Trace("<<< NetworkManagerMode.Run() completed")
+	return nil
+}
+
+// nmSettings are the DNS settings of one device, as applied with nmcli device modify
+type nmSettings struct {
+	DNS     []string
+	Domains []string
+}
+
+// desired returns the settings a network should have
+func (n *NetworkManagerMode) desired(network service.Network) nmSettings {
+	return nmSettings{
+		DNS:     n.GetDNSServers(network),
+		Domains: n.GetSearchDomains(network, n.GetConfig().Default.Features.AddReverseDomains),
+	}
+}
+
+// processNetworks applies the settings of every network, then reapplies the connection profile of
+// devices whose network was left
+func (n *NetworkManagerMode) processNetworks(networks *service.GetNetworksResponse, logger *log.Logger) {
+	features := n.GetConfig().Default.Features
+	current := map[string]struct{}{}
+	logger.Verbose("Processing %d networks for NetworkManager configuration", len(*networks.JSON200))
+
+	for _, network := range *networks.JSON200 {
+		if err := n.ValidateNetwork(network); err != nil {
+			continue
+		}
+		want := n.desired(network)
+		if len(want.DNS) == 0 {
+			logger.Debug("Network %s has no DNS servers, nothing to do", GetNetworkName(network))
+			continue
+		}
+		interfaceName := *network.PortDeviceName
+		current[interfaceName] = struct{}{}
+		index, _ := dns.LinkIndex(interfaceName)
+		entry := state.Interface{
+			Name:        interfaceName,
+			Index:       index,
+			NetworkID:   utils.GetString(network.Id),
+			NetworkName: utils.GetString(network.Name),
+			Mode:        "networkmanager",
+			DNS:         want.DNS,
+			Domains:     want.Domains,
+		}
+
+		have, err := nmDeviceSettings(interfaceName)
+		if err != nil {
+			logger.Warn("Could not read NetworkManager settings of %s: %v", interfaceName, err)
+			countSummary(func(s *RunSummary) { s.Errors++ })
+			continue
+		}
+		if dns.CompareDNS(have.DNS, want.DNS) && dns.CompareDNS(have.Domains, want.Domains) {
+			logger.Verbose("No changes needed for %s; NetworkManager already has DNS %v and search domains %v", interfaceName, want.DNS, want.Domains)
+			recordManaged(entry, logger)
+			continue
+		}
+		if n.IsDryRun() {
+			logger.Info("[dry-run] Would set %s to DNS %v and search domains %v through NetworkManager", interfaceName, want.DNS, want.Domains)
+			continue
+		}
+		if err := nmApply(interfaceName, want, multicastDNSOf(network, features.MulticastDNS), dnsOverTLSOf(network, features.DNSOverTLS)); err != nil {
+			logger.Warn("Failed to configure %s through NetworkManager: %v", interfaceName, err)
+			countSummary(func(s *RunSummary) { s.Errors++ })
+			continue
+		}
+		logger.Verbose("Configured Interface=%s, Network=%s, ID=%s, DNS Servers=%v, Search Domains=%v through NetworkManager",
+			interfaceName, utils.GetString(network.Name), utils.GetString(network.Id), want.DNS, want.Domains)
+		recordManaged(entry, logger)
+	}
+
+	revertLeft("networkmanager", current, n.IsDryRun(), logger, func(entry state.Interface) {
+		logger.Info("Network %s left, reapplying the NetworkManager connection of %s", entry.NetworkID, entry.Name)
+		nmRevert(entry.Name, logger)
+	})
+}
+
+// networkManagerDrift compares each network's desired DNS with what NetworkManager holds
+func networkManagerDrift(networks *service.GetNetworksResponse, base *BaseMode, logger *log.Logger) []Drift {
+	n := &NetworkManagerMode{BaseMode: base}
+	var drifts []Drift
+	for _, network := range *networks.JSON200 {
+		want := n.desired(network)
+		if base.ValidateNetwork(network) != nil || len(want.DNS) == 0 {
+			continue
+		}
+		interfaceName := *network.PortDeviceName
+		have, err := nmDeviceSettings(interfaceName)
+		if err != nil {
+			logger.Warn("Could not read NetworkManager settings of %s: %v", interfaceName, err)
+			continue
+		}
+		networkID := utils.GetString(network.Id)
+		if !dns.CompareDNS(have.DNS, want.DNS) {
+			drifts = append(drifts, Drift{Interface: interfaceName, NetworkID: networkID, Kind: DriftDNS, Current: have.DNS, Desired: want.DNS})
+		}
+		if !dns.CompareDNS(have.Domains, want.Domains) {
+			drifts = append(drifts, Drift{Interface: interfaceName, NetworkID: networkID, Kind: DriftDomains, Current: have.Domains, Desired: want.Domains})
+		}
+	}
+	return drifts
+}
+
+// nmDeviceSettings reads the DNS servers and search domains NetworkManager applied to a device
+func nmDeviceSettings(iface string) (nmSettings, error) {
+	out, err := exec.Command("nmcli", "-t", "-f", "IP4.DNS,IP6.DNS,IP4.DOMAIN,IP6.DOMAIN", "device", "show", iface).CombinedOutput()
+	if err != nil {
+		return nmSettings{}, fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	var settings nmSettings
+	for _, line := range strings.Split(string(out), "\n") {
+		field, value, ok := strings.Cut(line, ":")
+		if !ok || value == "" {
+			continue
+		}
+		// Terse output escapes the colons of IPv6 addresses
+		value = strings.ReplaceAll(value, `\:`, ":")
+		switch {
+		case strings.HasPrefix(field, "IP4.DNS"), strings.HasPrefix(field, "IP6.DNS"):
+			settings.DNS = append(settings.DNS, value)
+		case strings.HasPrefix(field, "IP4.DOMAIN"), strings.HasPrefix(field, "IP6.DOMAIN"):
+			if !utils.Contains(settings.Domains, value) {
+				settings.Domains = append(settings.Domains, value)
+			}
+		}
+	}
+	return settings, nil
+}
+
+// nmApply sets the DNS settings of a device without touching its saved connection profile
+func nmApply(iface string, settings nmSettings, multicastDNS, dnsOverTLS bool) error {
+	var dns4, dns6 []string
+	for _, server := range settings.DNS {
+		if ip := net.ParseIP(server); ip != nil && ip.To4() == nil {
+			dns6 = append(dns6, server)
+		} else {
+			dns4 = append(dns4, server)
+		}
+	}
+	args := []string{"device", "modify", iface,
+		"ipv4.dns", strings.Join(dns4, ","),
+		"ipv6.dns", strings.Join(dns6, ","),
+		"ipv4.dns-search", strings.Join(settings.Domains, ","),
+		"connection.mdns", nmBool(multicastDNS),
+		"connection.dns-over-tls", nmBool(dnsOverTLS),
+	}
+	if out, err := exec.Command("nmcli", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// nmRevert reapplies the saved connection profile of a device, dropping the settings zeroplex applied
+func nmRevert(iface string, logger *log.Logger) {
+	if _, err := net.InterfaceByName(iface); err != nil {
+		logger.Debug("Interface %s is gone, nothing to reapply", iface)
+		return
+	}
+	if out, err := exec.Command("nmcli", "device", "reapply", iface).CombinedOutput(); err != nil {
+		logger.Warn("Failed to reapply the NetworkManager connection of %s: %v: %s", iface, err, strings.TrimSpace(string(out)))
+	}
+}
+
+func nmBool(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
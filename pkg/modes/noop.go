@@ -0,0 +1,154 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/client"
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/dns"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/state"
+	"zeroplex/pkg/utils"
+
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+// NoopMode computes everything a real backend would apply, logs it and records it in the
+// state store without touching the system. Useful for tests, demos and observe-only rollouts.
+type NoopMode struct {
+	*BaseMode
+}
+
+// NewNoopMode creates a new noop mode runner; it has no system requirements
+func NewNoopMode(cfg config.Config, zt *client.Client, dryRun bool) (*NoopMode, error) {
+	return &NoopMode{
+		BaseMode: NewBaseMode(cfg, zt, dryRun, "noop"),
+	}, nil
+}
+
+// GetMode returns the mode name
+func (n *NoopMode) GetMode() string {
+	return "noop"
+}
+
+// Run executes the noop mode logic
+func (n *NoopMode) Run(ctx context.Context) error {
+	logger := log.NewScopedLogger("[modes/noop]", n.GetConfig().Default.Log.Level).WithContext(ctx)
+	logger.Trace(">>> NoopMode.Run() started")
+	logger.Debug("Running in noop mode (dry-run: %t)", n.IsDryRun())
+
+	networks, err := n.ProcessNetworks(ctx)
+	if err != nil {
+		logger.Error("Failed to process networks: %v", err)
+		return fmt.Errorf("failed to process networks: %w", err)
+	}
+
+	// Recording is skipped on dry runs so a dry run leaves no trace at all
+	var store *state.Store
+	if !n.IsDryRun() {
+		if store, err = state.Default(); err != nil {
+			logger.Warn("State store unavailable, planned actions will only be logged: %v", err)
+			store = nil
+		}
+	}
+
+	n.processNetworks(networks, store, logger)
+
+	logger.Trace("<<< NoopMode.Run() completed")
+	return nil
+}
+
+// processNetworks logs and records the plan for every network, then the reverts for networks that left
+func (n *NoopMode) processNetworks(networks *service.GetNetworksResponse, store *state.Store, logger *log.Logger) {
+	features := n.GetConfig().Default.Features
+	current := map[string]struct{}{}
+
+	for _, network := range *networks.JSON200 {
+		if err := n.ValidateNetwork(network); err != nil {
+			continue
+		}
+		servers := n.GetDNSServers(network)
+		if len(servers) == 0 {
+			logger.Debug("Network %s has no DNS servers, nothing to do", GetNetworkName(network))
+			continue
+		}
+
+		interfaceName := *network.PortDeviceName
+		current[interfaceName] = struct{}{}
+
+		domains := n.GetSearchDomains(network, features.AddReverseDomains)
+		for i, domain := range domains {
+			domains[i] = "~" + domain
+		}
+
+		index, err := dns.LinkIndex(interfaceName)
+		ifindex := strconv.Itoa(index)
+		if err != nil {
+			ifindex = "absent"
+		}
+
+		mdns, dnsOverTLS := multicastDNSOf(network, features.MulticastDNS), dnsOverTLSOf(network, features.DNSOverTLS)
+		logger.Info("[noop] Would configure interface=%s ifindex=%s network_id=%s network=%q dns=%s domains=%s mdns=%t dnsovertls=%t",
+			interfaceName, ifindex, utils.GetString(network.Id), utils.GetString(network.Name),
+			strings.Join(servers, ","), strings.Join(domains, ","), mdns, dnsOverTLS)
+
+		if store == nil {
+			continue
+		}
+		if err := store.RecordAction(state.Action{
+			Mode:      "noop",
+			Operation: "configure",
+			Interface: interfaceName,
+			NetworkID: utils.GetString(network.Id),
+			Details: map[string]string{
+				"ifindex":    ifindex,
+				"network":    utils.GetString(network.Name),
+				"dns":        strings.Join(servers, ","),
+				"domains":    strings.Join(domains, ","),
+				"mdns":       strconv.FormatBool(mdns),
+				"dnsovertls": strconv.FormatBool(dnsOverTLS),
+			},
+		}); err != nil {
+			logger.Warn("Failed to record planned action for %s: %v", interfaceName, err)
+		}
+		if err := store.SetInterface(state.Interface{
+			Name:        interfaceName,
+			Index:       index,
+			NetworkID:   utils.GetString(network.Id),
+			NetworkName: utils.GetString(network.Name),
+			Mode:        "noop",
+			DNS:         servers,
+			Domains:     domains,
+		}); err != nil {
+			logger.Warn("Failed to record interface %s: %v", interfaceName, err)
+		}
+		countSummary(func(s *RunSummary) { s.Applied++ })
+	}
+
+	if store == nil {
+		return
+	}
+	for _, entry := range store.Interfaces() {
+		if entry.Mode != "noop" {
+			continue
+		}
+		if _, ok := current[entry.Name]; ok {
+			continue
+		}
+		logger.Info("[noop] Would revert interface=%s network_id=%s (no longer present in ZeroTier networks)", entry.Name, entry.NetworkID)
+		if err := store.RecordAction(state.Action{Mode: "noop", Operation: "revert", Interface: entry.Name, NetworkID: entry.NetworkID}); err != nil {
+			logger.Warn("Failed to record planned revert for %s: %v", entry.Name, err)
+		}
+		if _, err := store.Forget(entry.Name); err != nil {
+			logger.Warn("Failed to forget interface %s: %v", entry.Name, err)
+		}
+		countSummary(func(s *RunSummary) { s.Removed++ })
+	}
+}
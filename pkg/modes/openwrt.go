@@ -0,0 +1,380 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/client"
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/dns"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/state"
+	"zeroplex/pkg/utils"
+
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+// OpenWrtRelease exists on OpenWrt, which is how auto mode recognises it
+var OpenWrtRelease = "/etc/openwrt_release"
+
+// OpenWrtMode forwards the domains of each ZeroTier network to its DNS servers on OpenWrt routers.
+// It adds "server" list entries (and "rebind_domain" entries, so the private answers pass dnsmasq's
+// rebind protection) to the dnsmasq section of /etc/config/dhcp through uci, and has procd reload
+// dnsmasq through ubus.
+type OpenWrtMode struct {
+	*BaseMode
+}
+
+// NewOpenWrtMode creates a new OpenWrt mode runner
+func NewOpenWrtMode(cfg config.Config, zt *client.Client, dryRun bool) (*OpenWrtMode, error) {
+	logger := log.NewScopedLogger("[modes/openwrt]", cfg.Default.Log.Level)
+	if !utils.CommandExists("uci") {
+		logger.Error("uci not found")
+		return nil, fmt.Errorf("uci not found; openwrt mode needs OpenWrt's configuration tool")
+	}
+
+	return &OpenWrtMode{
+		BaseMode: NewBaseMode(cfg, zt, dryRun, "openwrt"),
+	}, nil
+}
+
+// GetMode returns the mode name
+func (o *OpenWrtMode) GetMode() string {
+	return "openwrt"
+}
+
+// Run executes the OpenWrt mode logic
+func (o *OpenWrtMode) Run(ctx context.Context) error {
+	logger := log.NewScopedLogger("[modes/openwrt]", o.GetConfig().Default.Log.Level).WithContext(ctx)
+	logger.Trace(">>> OpenWrtMode.Run() started")
+	logger.Debug("Running in openwrt mode (dry-run: %t)", o.IsDryRun())
+
+	networks, err := o.ProcessNetworks(ctx)
+	if err != nil {
+		logger.Error("Failed to process networks: %v", err)
+		return fmt.Errorf("failed to process networks: %w", err)
+	}
+
+	logIgnoredMDNSAndDoT(o.GetConfig().Default.Features, networks, "dnsmasq has no per-server mDNS or DNS-over-TLS settings, ignoring them", logger)
+
+	if !changesAllowed(o.BaseMode, logger) {
+		reportDrift("openwrt", openWrtDrift(networks, o.BaseMode, logger), logger)
+		return nil
+	}
+
+	if err := guardChanges("openwrt", networks, func() []Drift { return openWrtDrift(networks, o.BaseMode, logger) }, o.BaseMode, logger); err != nil {
+		return err
+	}
+
+	o.processNetworks(networks, logger)
+
+	logger.Trace("<<< OpenWrtMode.Run() completed")
+	return nil
+}
+
+// openWrtEntries returns the interfaces whose domains should be forwarded, one entry per network
+func openWrtEntries(networks *service.GetNetworksResponse, base *BaseMode, logger *log.Logger) []state.Interface {
+	cfg := base.GetConfig().Default
+	var entries []state.Interface
+	for _, network := range *networks.JSON200 {
+		if base.ValidateNetwork(network) != nil {
+			continue
+		}
+		servers := base.GetDNSServers(network)
+		domains := base.GetSearchDomains(network, cfg.Features.AddReverseDomains)
+		if len(servers) == 0 || len(domains) == 0 {
+			// A server entry without a domain would make the ZeroTier servers the upstream for everything
+			logger.Debug("Network %s has no DNS servers or domains, nothing to forward", GetNetworkName(network))
+			continue
+		}
+		interfaceName := *network.PortDeviceName
+		index, _ := dns.LinkIndex(interfaceName)
+		entries = append(entries, state.Interface{
+			Name:        interfaceName,
+			Index:       index,
+			NetworkID:   utils.GetString(network.Id),
+			NetworkName: utils.GetString(network.Name),
+			Mode:        "openwrt",
+			DNS:         servers,
+			Domains:     domains,
+		})
+	}
+	return entries
+}
+
+// openWrtValues returns the uci list entries an interface needs, by option
+func openWrtValues(entry state.Interface, cfg config.OpenWrtConfig) map[string][]string {
+	values := map[string][]string{}
+	for _, domain := range entry.Domains {
+		for _, server := range entry.DNS {
+			values["server"] = append(values["server"], "/"+domain+"/"+server)
+		}
+		if cfg.RebindDomains {
+			values["rebind_domain"] = append(values["rebind_domain"], domain)
+		}
+	}
+	return values
+}
+
+// openWrtOptions are the list options zeroplex adds entries to
+var openWrtOptions = []string{"server", "rebind_domain"}
+
+// openWrtList returns the entries of a list option of the dnsmasq section
+func openWrtList(cfg config.OpenWrtConfig, option string) (map[string]bool, error) {
+	out, err := exec.Command("uci", "-q", "get", "dhcp."+cfg.Section+"."+option).Output()
+	if err != nil {
+		// uci -q get exits 1 without output when the option is not set
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 && len(out) == 0 {
+			return map[string]bool{}, nil
+		}
+		return nil, fmt.Errorf("uci get dhcp.%s.%s: %w", cfg.Section, option, err)
+	}
+	values := map[string]bool{}
+	for _, value := range strings.Fields(string(out)) {
+		values[value] = true
+	}
+	return values, nil
+}
+
+// openWrtLists returns the current entries of every option zeroplex manages
+func openWrtLists(cfg config.OpenWrtConfig) (map[string]map[string]bool, error) {
+	lists := map[string]map[string]bool{}
+	for _, option := range openWrtOptions {
+		values, err := openWrtList(cfg, option)
+		if err != nil {
+			return nil, err
+		}
+		lists[option] = values
+	}
+	return lists, nil
+}
+
+// uci runs a uci command that changes the configuration
+func uci(args ...string) error {
+	if out, err := exec.Command("uci", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("uci %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// processNetworks adds the missing list entries of every network, removes the ones zeroplex added
+// for networks that changed or were left, then commits and reloads dnsmasq if anything changed
+func (o *OpenWrtMode) processNetworks(networks *service.GetNetworksResponse, logger *log.Logger) {
+	cfg := o.GetConfig().Default.OpenWrt
+	entries := openWrtEntries(networks, o.BaseMode, logger)
+	lists, err := openWrtLists(cfg)
+	if err != nil {
+		logger.Warn("Failed to read the dnsmasq configuration: %v", err)
+		countSummary(func(s *RunSummary) { s.Errors++ })
+		return
+	}
+	current := map[string]struct{}{}
+	wanted := map[string]map[string]bool{}
+	for _, option := range openWrtOptions {
+		wanted[option] = map[string]bool{}
+	}
+	changed := false
+	logger.Verbose("Processing %d networks for the OpenWrt dnsmasq configuration", len(*networks.JSON200))
+
+	// The entries zeroplex added before, taken from the state store before it is updated below
+	previous := map[string]map[string]bool{}
+	if store, err := state.Default(); err == nil {
+		for _, entry := range store.Interfaces() {
+			if entry.Mode != "openwrt" {
+				continue
+			}
+			for option, values := range openWrtValues(entry, cfg) {
+				if previous[option] == nil {
+					previous[option] = map[string]bool{}
+				}
+				for _, value := range values {
+					previous[option][value] = true
+				}
+			}
+		}
+	}
+
+	for _, entry := range entries {
+		current[entry.Name] = struct{}{}
+		var missing [][2]string
+		for option, values := range openWrtValues(entry, cfg) {
+			for _, value := range values {
+				wanted[option][value] = true
+				if !lists[option][value] {
+					missing = append(missing, [2]string{option, value})
+				}
+			}
+		}
+		if len(missing) == 0 {
+			logger.Verbose("No changes needed for %s; dnsmasq already forwards %v to %v", entry.Name, entry.Domains, entry.DNS)
+			recordManaged(entry, logger)
+			continue
+		}
+		if o.IsDryRun() {
+			logger.Info("[dry-run] Would forward %v to %v for %s through uci", entry.Domains, entry.DNS, entry.Name)
+			continue
+		}
+		failed := false
+		for _, m := range missing {
+			if err := uci("add_list", "dhcp."+cfg.Section+"."+m[0]+"="+m[1]); err != nil {
+				logger.Warn("Failed to add %s %s for %s: %v", m[0], m[1], entry.Name, err)
+				failed = true
+				continue
+			}
+			lists[m[0]][m[1]] = true
+			changed = true
+		}
+		if failed {
+			countSummary(func(s *RunSummary) { s.Errors++ })
+			continue
+		}
+		logger.Verbose("Configured Interface=%s, Network=%s, ID=%s, DNS Servers=%v, Domains=%v in dhcp.%s",
+			entry.Name, entry.NetworkName, entry.NetworkID, entry.DNS, entry.Domains, cfg.Section)
+		recordManaged(entry, logger)
+	}
+
+	for _, option := range openWrtOptions {
+		var stale []string
+		for value := range previous[option] {
+			if !wanted[option][value] && lists[option][value] {
+				stale = append(stale, value)
+			}
+		}
+		sort.Strings(stale)
+		for _, value := range stale {
+			if o.IsDryRun() {
+				logger.Info("[dry-run] Would remove %s %s", option, value)
+				continue
+			}
+			logger.Info("Removing stale %s %s", option, value)
+			if err := uci("del_list", "dhcp."+cfg.Section+"."+option+"="+value); err != nil {
+				logger.Warn("Failed to remove %s %s: %v", option, value, err)
+				countSummary(func(s *RunSummary) { s.Errors++ })
+				continue
+			}
+			changed = true
+		}
+	}
+	revertLeft("openwrt", current, o.IsDryRun(), logger, func(entry state.Interface) {
+		logger.Info("Network %s left, no longer forwarding %v through %s", entry.NetworkID, entry.Domains, entry.Name)
+	})
+
+	if changed {
+		commitOpenWrt(cfg, logger)
+	}
+}
+
+// commitOpenWrt commits the dhcp changes and has procd reload dnsmasq, the same config.change event
+// OpenWrt's reload_config sends
+func commitOpenWrt(cfg config.OpenWrtConfig, logger *log.Logger) {
+	if err := uci("commit", "dhcp"); err != nil {
+		logger.Warn("Failed to commit the dnsmasq configuration: %v", err)
+		countSummary(func(s *RunSummary) { s.Errors++ })
+		return
+	}
+	if !cfg.Reload {
+		logger.Debug("openwrt.reload is off, not reloading dnsmasq")
+		return
+	}
+	logger.Info("Servers changed; reloading dnsmasq...")
+	out, err := exec.Command("ubus", "call", "service", "event", `{"type":"config.change","data":{"package":"dhcp"}}`).CombinedOutput()
+	if err != nil {
+		logger.Warn("Failed to reload dnsmasq through ubus: %v: %s", err, strings.TrimSpace(string(out)))
+		countSummary(func(s *RunSummary) { s.Errors++ })
+	}
+}
+
+// openWrtDrift compares the dnsmasq list entries with the ones the networks need
+func openWrtDrift(networks *service.GetNetworksResponse, base *BaseMode, logger *log.Logger) []Drift {
+	cfg := base.GetConfig().Default.OpenWrt
+	lists, err := openWrtLists(cfg)
+	if err != nil {
+		logger.Warn("Failed to read the dnsmasq configuration: %v", err)
+		return nil
+	}
+	current := map[string]bool{}
+	var drifts []Drift
+	for _, entry := range openWrtEntries(networks, base, logger) {
+		current[entry.Name] = true
+		var have, want []string
+		for _, value := range openWrtValues(entry, cfg)["server"] {
+			want = append(want, value)
+			if lists["server"][value] {
+				have = append(have, value)
+			}
+		}
+		if len(have) != len(want) {
+			drifts = append(drifts, Drift{Interface: entry.Name, NetworkID: entry.NetworkID, Kind: DriftDNS, Current: have, Desired: want})
+		}
+		var domains []string
+		for _, domain := range openWrtValues(entry, cfg)["rebind_domain"] {
+			if lists["rebind_domain"][domain] {
+				domains = append(domains, domain)
+			}
+		}
+		if cfg.RebindDomains && len(domains) != len(entry.Domains) {
+			drifts = append(drifts, Drift{Interface: entry.Name, NetworkID: entry.NetworkID, Kind: DriftDomains, Current: domains, Desired: entry.Domains})
+		}
+	}
+	if store, err := state.Default(); err == nil {
+		for _, entry := range store.Interfaces() {
+			if entry.Mode == "openwrt" && !current[entry.Name] {
+				drifts = append(drifts, Drift{Interface: entry.Name, NetworkID: entry.NetworkID, Kind: DriftStale, Current: entry.Domains})
+			}
+		}
+	}
+	return drifts
+}
+
+// restoreOpenWrt removes every list entry zeroplex added, returning the interfaces whose entries
+// were removed
+func restoreOpenWrt(cfg config.OpenWrtConfig, dryRun bool, logger *log.Logger) []string {
+	store, err := state.Default()
+	if err != nil {
+		logger.Warn("State store unavailable, cannot find OpenWrt entries to restore: %v", err)
+		return nil
+	}
+	lists, err := openWrtLists(cfg)
+	if err != nil {
+		logger.Warn("Failed to read the dnsmasq configuration: %v", err)
+		return nil
+	}
+	var restored []string
+	removed := false
+	for _, entry := range store.Interfaces() {
+		if entry.Mode != "openwrt" {
+			continue
+		}
+		restored = append(restored, entry.Name)
+		if dryRun {
+			logger.Info("[dry-run] Would remove the dnsmasq servers %v of %s", entry.Domains, entry.Name)
+			continue
+		}
+		for _, option := range openWrtOptions {
+			for _, value := range openWrtValues(entry, cfg)[option] {
+				if !lists[option][value] {
+					continue
+				}
+				if err := uci("del_list", "dhcp."+cfg.Section+"."+option+"="+value); err != nil {
+					logger.Warn("Failed to remove %s %s: %v", option, value, err)
+					continue
+				}
+				delete(lists[option], value)
+				removed = true
+			}
+		}
+		logger.Info("Removed the dnsmasq servers of %s", entry.Name)
+		forgetManaged(entry.Name, logger)
+	}
+	if removed {
+		commitOpenWrt(cfg, logger)
+	}
+	return restored
+}
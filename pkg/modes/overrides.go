@@ -0,0 +1,138 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/utils"
+
+	"sync"
+	"sync/atomic"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+var (
+	overridesMu      sync.Mutex
+	networkOverrides map[string]config.NetworkOverride // of the profile of the last run, see setNetworkOverrides
+)
+
+// mdnsSuppressed is set while features.mdns_conflict keeps mDNS off, which a network's
+// multicast_dns override can't turn on either
+var mdnsSuppressed atomic.Bool
+
+// setNetworkOverrides makes the networks: section of profile the one the helpers below consult
+func setNetworkOverrides(profile config.Profile) {
+	overridesMu.Lock()
+	defer overridesMu.Unlock()
+	networkOverrides = profile.Networks
+}
+
+// overrideOf returns the overrides of network
+func overrideOf(network service.Network) config.NetworkOverride {
+	overridesMu.Lock()
+	defer overridesMu.Unlock()
+	override, _ := config.Profile{Networks: networkOverrides}.NetworkOverride(stringValue(network.Id))
+	return override
+}
+
+// extraSearchDomainsOf returns the extra search domains of network: its own, or extra
+func extraSearchDomainsOf(network service.Network, extra []string) []string {
+	if override := overrideOf(network); len(override.ExtraSearchDomains) > 0 {
+		return override.ExtraSearchDomains
+	}
+	return extra
+}
+
+// dnsOverTLSOf reports whether DNS-over-TLS is wanted for network: its own setting, or enabled
+func dnsOverTLSOf(network service.Network, enabled bool) bool {
+	if override := overrideOf(network); override.DNSOverTLS != nil {
+		return *override.DNSOverTLS
+	}
+	return enabled
+}
+
+// multicastDNSOf reports whether mDNS is wanted for network: its own setting unless mDNS is kept
+// off by features.mdns_conflict, or enabled
+func multicastDNSOf(network service.Network, enabled bool) bool {
+	if override := overrideOf(network); override.MulticastDNS != nil {
+		return *override.MulticastDNS && !mdnsSuppressed.Load()
+	}
+	return enabled
+}
+
+// overridesWantMDNS reports whether the multicast_dns override of one of networks turns mDNS on
+func overridesWantMDNS(networks *service.GetNetworksResponse) bool {
+	for _, network := range *networks.JSON200 {
+		if override := overrideOf(network); override.MulticastDNS != nil && *override.MulticastDNS {
+			return true
+		}
+	}
+	return false
+}
+
+// overridesWantDoT reports whether the dns_over_tls override of one of networks turns DNS-over-TLS
+// on
+func overridesWantDoT(networks *service.GetNetworksResponse) bool {
+	for _, network := range *networks.JSON200 {
+		if override := overrideOf(network); override.DNSOverTLS != nil && *override.DNSOverTLS {
+			return true
+		}
+	}
+	return false
+}
+
+// logIgnoredMDNSAndDoT logs message, that the mode has no mDNS or DNS-over-TLS settings, when
+// features or a network override asks for them. An override only asks for the networks it names,
+// so it is warned about rather than left to the debug log with the features.
+func logIgnoredMDNSAndDoT(features config.FeaturesConfig, networks *service.GetNetworksResponse, message string, logger *log.Logger) {
+	switch {
+	case features.MulticastDNS || features.DNSOverTLS:
+		logger.Debug("%s", message)
+	case overridesWantMDNS(networks) || overridesWantDoT(networks):
+		logger.Warn("%s (set by networks.<id>.multicast_dns or dns_over_tls)", message)
+	}
+}
+
+// applyNetworkOverrides replaces the DNS servers of the networks that have their own, and records
+// the overrides the modes consult for the rest
+func applyNetworkOverrides(networks *service.GetNetworksResponse, profile config.Profile, logger *log.Logger) {
+	setNetworkOverrides(profile)
+	for i, network := range *networks.JSON200 {
+		override, ok := profile.NetworkOverride(stringValue(network.Id))
+		if !ok || len(override.DNSServers) == 0 {
+			continue
+		}
+		// A copy, so the servers ZeroTier pushed stay in the response the filters were given
+		dnsConfig := &struct {
+			Domain  *string   `json:"domain,omitempty"`
+			Servers *[]string `json:"servers,omitempty"`
+		}{}
+		if network.Dns != nil {
+			*dnsConfig = *network.Dns
+		}
+		servers := append([]string{}, override.DNSServers...)
+		dnsConfig.Servers = &servers
+		logger.Verbose("Network %s: using the DNS servers %v of networks.%s instead of those ZeroTier pushed", GetNetworkName(network), servers, utils.GetString(network.Id))
+		(*networks.JSON200)[i].Dns = dnsConfig
+	}
+}
+
+// networksOfMode returns the networks of networks managed by mode, as networks: sets them
+func networksOfMode(networks *service.GetNetworksResponse, profile config.Profile, mode string) *service.GetNetworksResponse {
+	selected := *networks
+	var kept []service.Network
+	for _, network := range *networks.JSON200 {
+		if profile.NetworkMode(stringValue(network.Id)) == mode {
+			kept = append(kept, network)
+		}
+	}
+	if kept == nil {
+		kept = []service.Network{}
+	}
+	selected.JSON200 = &kept
+	return &selected
+}
@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/events"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/metrics"
+	"zeroplex/pkg/state"
+
+	"fmt"
+	"sync"
+	"time"
+)
+
+// reloadWindow is the period the reload ceilings count over
+const reloadWindow = time.Hour
+
+// Services whose reloads are rate limited. A reload refused by the ceiling stays pending, so the
+// files it would have activated are picked up by the next allowed one; the refusal alerts once.
+var (
+	reloadGuardMu sync.Mutex
+	reloadPending = map[string]bool{}
+	reloadAlerted = map[string]bool{}
+)
+
+// allowReload reserves a reload of service against a ceiling of limit per hour, counted in the state
+// store so it holds across restarts of zeroplex too. Reloads go ahead when the store is unavailable.
+func allowReload(mode, service, key string, limit int, logger *log.Logger) bool {
+	store, err := state.Default()
+	if err != nil {
+		logger.Debug("State store unavailable, not counting the reload of %s: %v", service, err)
+		return true
+	}
+	allowed, count, err := store.ReserveReload(service, limit, reloadWindow)
+	if err != nil {
+		logger.Warn("Failed to count the reload of %s: %v", service, err)
+		return true
+	}
+
+	reloadGuardMu.Lock()
+	defer reloadGuardMu.Unlock()
+	if allowed {
+		reloadPending[service] = false
+		reloadAlerted[service] = false
+		metrics.Inc("zeroplex_service_reloads_total", "Service reloads zeroplex triggered", metrics.Labels{"service": service})
+		logger.Debug("Reloading %s (%d in the last hour)", service, count)
+		return true
+	}
+
+	reloadPending[service] = true
+	countSummary(func(s *RunSummary) { s.Errors++ })
+	metrics.Inc("zeroplex_service_reloads_refused_total", "Service reloads refused because they reached their hourly ceiling", metrics.Labels{"service": service})
+	logger.Error("Not reloading %s: %d reloads in the last hour reached %s. The changes take effect with the next allowed reload",
+		service, count, key)
+	if !reloadAlerted[service] {
+		reloadAlerted[service] = true
+		events.Publish(events.Event{
+			Type:    events.TypeReloadLimit,
+			Mode:    mode,
+			Message: fmt.Sprintf("reload of %s refused: %d reloads in the last hour reached %s", service, count, key),
+			Data:    map[string]interface{}{"service": service, "reloads": count, "limit": limit},
+		})
+	}
+	return false
+}
+
+// reloadIsPending reports whether a reload of service was refused and still has to happen
+func reloadIsPending(service string) bool {
+	reloadGuardMu.Lock()
+	defer reloadGuardMu.Unlock()
+	return reloadPending[service]
+}
@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/dns"
+	"zeroplex/pkg/events"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/state"
+	"zeroplex/pkg/utils"
+
+	"os"
+	"path/filepath"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+// followRenames keeps zeroplex attached to interfaces renamed by udev or by hand. Networks are
+// pointed at the current name of their device (ZeroTier may still report the name it created),
+// and what was recorded under an old name moves to the new one instead of being orphaned.
+func followRenames(networks *service.GetNetworksResponse, dryRun bool, logger *log.Logger) {
+	if networks == nil || networks.JSON200 == nil {
+		return
+	}
+	reported := map[string]bool{}
+	for i := range *networks.JSON200 {
+		network := &(*networks.JSON200)[i]
+		if network.PortDeviceName == nil || *network.PortDeviceName == "" {
+			continue
+		}
+		name := *network.PortDeviceName
+		if current, _, err := utils.LinkNames(name); err == nil && current != name {
+			logger.Verbose("ZeroTier reports interface %s for network %s, which is now named %s", name, utils.GetString(network.Id), current)
+			network.PortDeviceName = &current
+			name = current
+		}
+		reported[name] = true
+	}
+
+	store, err := state.Default()
+	if err != nil {
+		return
+	}
+	for _, entry := range store.Interfaces() {
+		if renamed := renamedTo(entry, reported); renamed != "" {
+			moveManaged(store, entry, renamed, dryRun, logger)
+		}
+	}
+}
+
+// renamedTo returns the new name of a recorded interface, or "" if it still has its name or can't
+// be found. A device is followed through an altname matching the recorded name, or through its
+// ifindex when the name found there is one ZeroTier reports, which guards against reused indexes.
+func renamedTo(entry state.Interface, reported map[string]bool) string {
+	if current, _, err := utils.LinkNames(entry.Name); err == nil {
+		if current == entry.Name {
+			return ""
+		}
+		return current
+	}
+	if entry.Index > 0 {
+		if current, _, err := utils.LinkNamesByIndex(entry.Index); err == nil && current != entry.Name && reported[current] {
+			return current
+		}
+	}
+	for _, alt := range entry.AltNames {
+		if current, _, err := utils.LinkNames(alt); err == nil && current != entry.Name {
+			return current
+		}
+	}
+	return ""
+}
+
+// moveManaged moves the saved DNS, in-memory bookkeeping and state store entry of a renamed
+// interface to its new name. A generated .network file is removed, so the run that follows writes
+// one matching the new name and reloads systemd-networkd.
+func moveManaged(store *state.Store, entry state.Interface, renamed string, dryRun bool, logger *log.Logger) {
+	old := entry.Name
+	if dryRun {
+		logger.Info("[dry-run] Would move state of renamed interface %s to %s", old, renamed)
+		return
+	}
+	logger.Info("Interface %s was renamed to %s, moving its DNS state along", old, renamed)
+	dns.RenameInterface(old, renamed)
+	if _, ok := managedZTInterfaces[old]; ok {
+		delete(managedZTInterfaces, old)
+		managedZTInterfaces[renamed] = struct{}{}
+	}
+
+	var files []string
+	for _, fn := range entry.Files {
+		if filepath.Base(fn) == filepath.Base(networkdFilePath(old)) {
+			if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
+				logger.Warn("Failed to remove %s of renamed interface %s: %v", fn, old, err)
+			}
+			fn = networkdFilePath(renamed)
+		}
+		files = append(files, fn)
+	}
+	entry.Name = renamed
+	entry.Files = files
+	if _, altNames, err := utils.LinkNames(renamed); err == nil {
+		entry.AltNames = altNames
+	}
+	if index, err := dns.LinkIndex(renamed); err == nil {
+		entry.Index = index
+	}
+	if err := store.Rename(old, entry); err != nil {
+		logger.Warn("Failed to move state of %s to %s: %v", old, renamed, err)
+	}
+	if err := store.RecordAction(state.Action{
+		Mode:      entry.Mode,
+		Operation: "rename",
+		Interface: renamed,
+		NetworkID: entry.NetworkID,
+		Applied:   true,
+		Details:   map[string]string{"from": old},
+	}); err != nil {
+		logger.Debug("Failed to record rename of %s: %v", old, err)
+	}
+	events.Publish(events.Event{
+		Type:      events.TypeRename,
+		Mode:      entry.Mode,
+		Interface: renamed,
+		NetworkID: entry.NetworkID,
+		Message:   "interface renamed from " + old,
+		Data:      map[string]interface{}{"from": old},
+	})
+}
@@ -0,0 +1,245 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/client"
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/dns"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/state"
+	"zeroplex/pkg/utils"
+
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+// ResolvconfInterfaceDirs are where the resolvconf implementations keep the fragment of each
+// interface, used to read back what is applied when "resolvconf -l" isn't supported
+var ResolvconfInterfaceDirs = []string{"/run/resolvconf/interfaces", "/run/resolvconf/interface"}
+
+// ResolvconfMode applies ZeroTier DNS servers and search domains through resolvconf (openresolv or
+// Debian's resolvconf), for systems running neither systemd-networkd nor systemd-resolved. Each
+// interface gets its own fragment, added with "resolvconf -a" and removed with "resolvconf -d", so
+// the other sources of /etc/resolv.conf are left alone.
+type ResolvconfMode struct {
+	*BaseMode
+}
+
+// NewResolvconfMode creates a new resolvconf mode runner
+func NewResolvconfMode(cfg config.Config, zt *client.Client, dryRun bool) (*ResolvconfMode, error) {
+	logger := log.NewScopedLogger("[modes/resolvconf]", cfg.Default.Log.Level)
+	if !utils.CommandExists("resolvconf") {
+		logger.Error("resolvconf command not found")
+		return nil, fmt.Errorf("resolvconf is required but is not available")
+	}
+
+	return &ResolvconfMode{
+		BaseMode: NewBaseMode(cfg, zt, dryRun, "resolvconf"),
+	}, nil
+}
+
+// GetMode returns the mode name
+func (r *ResolvconfMode) GetMode() string {
+	return "resolvconf"
+}
+
+// Run executes the resolvconf mode logic
+func (r *ResolvconfMode) Run(ctx context.Context) error {
+	logger := log.NewScopedLogger("[modes/resolvconf]", r.GetConfig().Default.Log.Level).WithContext(ctx)
+	logger.Trace(">>> ResolvconfMode.Run() started")
+	logger.Debug("Running in resolvconf mode (dry-run: %t)", r.IsDryRun())
+
+	networks, err := r.ProcessNetworks(ctx)
+	if err != nil {
+		logger.Error("Failed to process networks: %v", err)
+		return fmt.Errorf("failed to process networks: %w", err)
+	}
+
+	logIgnoredMDNSAndDoT(r.GetConfig().Default.Features, networks, "resolv.conf has no per-interface mDNS or DNS-over-TLS settings, ignoring them", logger)
+
+	if !changesAllowed(r.BaseMode, logger) {
+		reportDrift("resolvconf", resolvconfDrift(networks, r.BaseMode), logger)
+		return nil
+	}
+
+	if err := guardChanges("resolvconf", networks, func() []Drift { return resolvconfDrift(networks, r.BaseMode) }, r.BaseMode, logger); err != nil {
+		return err
+	}
+
+	r.processNetworks(networks, logger)
+
+	logger.Trace("<<< ResolvconfMode.Run() completed")
+	return nil
+}
+
+// resolvconfSettings are the DNS settings of one interface fragment
+type resolvconfSettings struct {
+	DNS     []string
+	Domains []string
+}
+
+// desired returns the settings a network should have. Reverse lookup domains are left out: a
+// search list only qualifies short names, so they would have no effect.
+func (r *ResolvconfMode) desired(network service.Network) resolvconfSettings {
+	return resolvconfSettings{
+		DNS:     r.GetDNSServers(network),
+		Domains: r.GetSearchDomains(network, false),
+	}
+}
+
+// processNetworks adds or updates the fragment of every network, then deletes the fragments of
+// interfaces whose network was left
+func (r *ResolvconfMode) processNetworks(networks *service.GetNetworksResponse, logger *log.Logger) {
+	current := map[string]struct{}{}
+	logger.Verbose("Processing %d networks for resolvconf configuration", len(*networks.JSON200))
+
+	for _, network := range *networks.JSON200 {
+		if err := r.ValidateNetwork(network); err != nil {
+			continue
+		}
+		want := r.desired(network)
+		if len(want.DNS) == 0 {
+			logger.Debug("Network %s has no DNS servers, nothing to do", GetNetworkName(network))
+			continue
+		}
+		interfaceName := *network.PortDeviceName
+		current[interfaceName] = struct{}{}
+		index, _ := dns.LinkIndex(interfaceName)
+		entry := state.Interface{
+			Name:        interfaceName,
+			Index:       index,
+			NetworkID:   utils.GetString(network.Id),
+			NetworkName: utils.GetString(network.Name),
+			Mode:        "resolvconf",
+			DNS:         want.DNS,
+			Domains:     want.Domains,
+		}
+
+		if have, ok := resolvconfFragment(interfaceName); ok && dns.CompareDNS(have.DNS, want.DNS) && dns.CompareDNS(have.Domains, want.Domains) {
+			logger.Verbose("No changes needed for %s; resolvconf already has DNS %v and search domains %v", interfaceName, want.DNS, want.Domains)
+			recordManaged(entry, logger)
+			continue
+		}
+		if r.IsDryRun() {
+			logger.Info("[dry-run] Would set %s to DNS %v and search domains %v through resolvconf", interfaceName, want.DNS, want.Domains)
+			continue
+		}
+		if err := resolvconfAdd(interfaceName, network, want); err != nil {
+			logger.Warn("Failed to configure %s through resolvconf: %v", interfaceName, err)
+			countSummary(func(s *RunSummary) { s.Errors++ })
+			continue
+		}
+		logger.Verbose("Configured Interface=%s, Network=%s, ID=%s, DNS Servers=%v, Search Domains=%v through resolvconf",
+			interfaceName, utils.GetString(network.Name), utils.GetString(network.Id), want.DNS, want.Domains)
+		recordManaged(entry, logger)
+	}
+
+	revertLeft("resolvconf", current, r.IsDryRun(), logger, func(entry state.Interface) {
+		logger.Info("Network %s left, deleting the resolvconf entry of %s", entry.NetworkID, entry.Name)
+		resolvconfDelete(entry.Name, logger)
+	})
+}
+
+// resolvconfDrift compares each network's desired DNS with the fragment resolvconf holds
+func resolvconfDrift(networks *service.GetNetworksResponse, base *BaseMode) []Drift {
+	r := &ResolvconfMode{BaseMode: base}
+	var drifts []Drift
+	for _, network := range *networks.JSON200 {
+		want := r.desired(network)
+		if base.ValidateNetwork(network) != nil || len(want.DNS) == 0 {
+			continue
+		}
+		interfaceName := *network.PortDeviceName
+		have, _ := resolvconfFragment(interfaceName)
+		networkID := utils.GetString(network.Id)
+		if !dns.CompareDNS(have.DNS, want.DNS) {
+			drifts = append(drifts, Drift{Interface: interfaceName, NetworkID: networkID, Kind: DriftDNS, Current: have.DNS, Desired: want.DNS})
+		}
+		if !dns.CompareDNS(have.Domains, want.Domains) {
+			drifts = append(drifts, Drift{Interface: interfaceName, NetworkID: networkID, Kind: DriftDomains, Current: have.Domains, Desired: want.Domains})
+		}
+	}
+	return drifts
+}
+
+// resolvconfFragment reads back the fragment of an interface, with "resolvconf -l" (openresolv) or
+// from the interface directory (Debian's resolvconf). It reports false when there is none.
+func resolvconfFragment(iface string) (resolvconfSettings, bool) {
+	content, err := exec.Command("resolvconf", "-l", iface).Output()
+	if err != nil || len(strings.TrimSpace(string(content))) == 0 {
+		content = nil
+		for _, dir := range ResolvconfInterfaceDirs {
+			if data, err := os.ReadFile(filepath.Join(dir, iface)); err == nil {
+				content = data
+				break
+			}
+		}
+	}
+	if content == nil {
+		return resolvconfSettings{}, false
+	}
+
+	var settings resolvconfSettings
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			server, _, _ := strings.Cut(fields[1], "%")
+			settings.DNS = append(settings.DNS, server)
+		case "search", "domain":
+			for _, domain := range fields[1:] {
+				if !utils.Contains(settings.Domains, domain) {
+					settings.Domains = append(settings.Domains, domain)
+				}
+			}
+		}
+	}
+	return settings, true
+}
+
+// resolvconfContent renders the resolv.conf fragment of a network
+func resolvconfContent(network service.Network, settings resolvconfSettings) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated by zeroplex for ZeroTier network %s\n", GetNetworkName(network))
+	for _, server := range settings.DNS {
+		// resolv.conf takes link-local IPv6 servers with their zone
+		if ip := net.ParseIP(server); ip != nil && ip.To4() == nil && ip.IsLinkLocalUnicast() && network.PortDeviceName != nil {
+			server += "%" + *network.PortDeviceName
+		}
+		fmt.Fprintf(&b, "nameserver %s\n", server)
+	}
+	if len(settings.Domains) > 0 {
+		fmt.Fprintf(&b, "search %s\n", strings.Join(settings.Domains, " "))
+	}
+	return b.String()
+}
+
+// resolvconfAdd adds or replaces the fragment of an interface
+func resolvconfAdd(iface string, network service.Network, settings resolvconfSettings) error {
+	cmd := exec.Command("resolvconf", "-a", iface)
+	cmd.Stdin = strings.NewReader(resolvconfContent(network, settings))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// resolvconfDelete removes the fragment of an interface, regenerating /etc/resolv.conf without it
+func resolvconfDelete(iface string, logger *log.Logger) {
+	if out, err := exec.Command("resolvconf", "-d", iface).CombinedOutput(); err != nil {
+		logger.Warn("Failed to delete the resolvconf entry of %s: %v: %s", iface, err, strings.TrimSpace(string(out)))
+	}
+}
@@ -5,8 +5,11 @@
 package modes
 
 import (
+	"zeroplex/pkg/client"
 	"zeroplex/pkg/config"
 	"zeroplex/pkg/dns"
+	"zeroplex/pkg/exitcode"
+	"zeroplex/pkg/initsys"
 	"zeroplex/pkg/log"
 	"zeroplex/pkg/utils"
 
@@ -22,13 +25,12 @@ type ResolvedMode struct {
 }
 
 // NewResolvedMode creates a new resolved mode runner
-func NewResolvedMode(cfg config.Config, dryRun bool) (*ResolvedMode, error) {
+func NewResolvedMode(cfg config.Config, zt *client.Client, dryRun bool) (*ResolvedMode, error) {
 	logger := log.NewScopedLogger("[modes/resolved]", cfg.Default.Log.Level)
 	// Verify systemd-resolved is available and running
 	logger.Trace("Checking systemd-resolved service status")
-	output, err := utils.ExecuteCommand("systemctl", "is-active", "systemd-resolved.service")
-	if err != nil || output != "active\n" {
-		logger.Error("systemd-resolved service check failed: %v", err)
+	if !initsys.Current().IsActive("systemd-resolved") {
+		logger.Error("systemd-resolved service check failed (%s)", initsys.Current().Name())
 		return nil, fmt.Errorf("systemd-resolved is not running")
 	}
 	logger.Debug("systemd-resolved service is active")
@@ -42,7 +44,7 @@ func NewResolvedMode(cfg config.Config, dryRun bool) (*ResolvedMode, error) {
 	logger.Trace("resolvectl command is available")
 
 	return &ResolvedMode{
-		BaseMode: NewBaseMode(cfg, dryRun, "resolved"),
+		BaseMode: NewBaseMode(cfg, zt, dryRun, "resolved"),
 	}, nil
 }
 
@@ -53,7 +55,7 @@ func (r *ResolvedMode) GetMode() string {
 
 // Run executes the resolved mode logic
 func (r *ResolvedMode) Run(ctx context.Context) error {
-	logger := log.NewScopedLogger("[modes/resolved]", r.GetConfig().Default.Log.Level)
+	logger := log.NewScopedLogger("[modes/resolved]", r.GetConfig().Default.Log.Level).WithContext(ctx)
 	logger.Trace(">>> ResolvedMode.Run() started")
 	logger.Debug("Running in resolved mode (dry-run: %t)", r.IsDryRun())
 
@@ -61,14 +63,30 @@ func (r *ResolvedMode) Run(ctx context.Context) error {
 	networks, err := r.ProcessNetworks(ctx)
 	if err != nil {
 		logger.Error("Failed to process networks: %v", err)
+		if _, deferring := r.GetConfig().Default.Maintenance.Deferring(now()); !r.GetConfig().Default.Enforcing() || deferring {
+			return err
+		}
 		// Restore DNS for all interfaces with saved state
 		logger.Warn("Restoring DNS for all managed interfaces due to ZeroTier API/network failure")
-		for _, iface := range dns.GetChangedInterfaces() {
-			dns.RestoreSavedDNS(iface, r.GetConfig().Default.Log.Level)
+		restored := dns.GetChangedInterfaces()
+		for _, iface := range restored {
+			dns.RestoreSavedDNS(ctx, iface, r.GetConfig().Default.Log.Level)
+		}
+		if len(restored) > 0 {
+			return exitcode.Wrap(exitcode.Restored, err)
 		}
 		return err
 	}
 
+	if !changesAllowed(r.BaseMode, logger) {
+		reportDrift("resolved", resolvedDrift(networks, r.BaseMode, logger), logger)
+		return nil
+	}
+
+	if err := guardChanges("resolved", networks, func() []Drift { return resolvedDrift(networks, r.BaseMode, logger) }, r.BaseMode, logger); err != nil {
+		return err
+	}
+
 	// Process networks for resolved
 	logger.Debug("Processing networks for systemd-resolved configuration")
 	logger.Trace("Calling processNetworks() for systemd-resolved integration")
@@ -84,14 +102,17 @@ func (r *ResolvedMode) Run(ctx context.Context) error {
 
 // processNetworks handles the actual network processing for resolved
 func (r *ResolvedMode) processNetworks(ctx context.Context, networks *service.GetNetworksResponse) error {
+	dns.SetResolvedDBus(r.GetConfig().Default.ExperimentEnabled("resolved_dbus"))
 	// Call the resolved implementation, passing all relevant feature toggles
 	RunResolvedMode(
+		ctx,
 		networks,
 		r.GetConfig().Default.Features.AddReverseDomains,
 		r.GetConfig().Default.Features.DNSOverTLS,
-		r.GetConfig().Default.Features.MulticastDNS,
+		r.resolveMDNSConflict(networks),
 		r.IsDryRun(),
 		r.GetConfig().Default.Log.Level,
+		r.GetConfig().Default.Features.ExtraSearchDomains,
 	)
-	return nil
+	return r.VerifyApplied(ctx, networks)
 }
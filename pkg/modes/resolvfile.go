@@ -0,0 +1,371 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/client"
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/dns"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/state"
+	"zeroplex/pkg/utils"
+
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+// ResolvConfPath is the resolver configuration managed in "resolvfile" mode
+var ResolvConfPath = "/etc/resolv.conf"
+
+const (
+	resolvBlockBegin = "# BEGIN zeroplex managed block: changes here are overwritten"
+	resolvBlockEnd   = "# END zeroplex managed block"
+	// resolvDisabled prefixes the search and domain lines of the original file while the block
+	// provides the search list, since only the last search line counts
+	resolvDisabled = "# zeroplex: "
+	// resolvMaxNameservers is how many nameserver lines the C library resolver uses (MAXNS)
+	resolvMaxNameservers = 3
+)
+
+// ResolvFileMode writes ZeroTier DNS servers and search domains straight into /etc/resolv.conf, for
+// hosts without a resolver that can route queries per interface. zeroplex owns a marked block at the
+// top of the file; everything else is kept, and a copy of the file without the block is kept next to
+// the state store so it can be restored.
+type ResolvFileMode struct {
+	*BaseMode
+}
+
+// NewResolvFileMode creates a new resolv.conf mode runner
+func NewResolvFileMode(cfg config.Config, zt *client.Client, dryRun bool) (*ResolvFileMode, error) {
+	return &ResolvFileMode{
+		BaseMode: NewBaseMode(cfg, zt, dryRun, "resolvfile"),
+	}, nil
+}
+
+// GetMode returns the mode name
+func (m *ResolvFileMode) GetMode() string {
+	return "resolvfile"
+}
+
+// Run executes the resolv.conf mode logic
+func (m *ResolvFileMode) Run(ctx context.Context) error {
+	logger := log.NewScopedLogger("[modes/resolvfile]", m.GetConfig().Default.Log.Level).WithContext(ctx)
+	logger.Trace(">>> ResolvFileMode.Run() started")
+	logger.Debug("Running in resolvfile mode on %s (dry-run: %t)", ResolvConfPath, m.IsDryRun())
+
+	networks, err := m.ProcessNetworks(ctx)
+	if err != nil {
+		logger.Error("Failed to process networks: %v", err)
+		return fmt.Errorf("failed to process networks: %w", err)
+	}
+
+	if !changesAllowed(m.BaseMode, logger) {
+		reportDrift("resolvfile", resolvFileDrift(networks, m.BaseMode, logger), logger)
+		return nil
+	}
+
+	if err := guardChanges("resolvfile", networks, func() []Drift { return resolvFileDrift(networks, m.BaseMode, logger) }, m.BaseMode, logger); err != nil {
+		return err
+	}
+
+	m.processNetworks(networks, logger)
+
+	logger.Trace("<<< ResolvFileMode.Run() completed")
+	return nil
+}
+
+// resolvFileResolvers collects the state entries of the networks with DNS servers, and the
+// servers and search domains of all of them in network order
+func resolvFileResolvers(networks *service.GetNetworksResponse, base *BaseMode) (entries []state.Interface, servers, domains []string) {
+	for _, network := range *networks.JSON200 {
+		if base.ValidateNetwork(network) != nil {
+			continue
+		}
+		networkServers := base.GetDNSServers(network)
+		if len(networkServers) == 0 {
+			continue
+		}
+		// A search list only qualifies short names, so reverse lookup domains are left out
+		networkDomains := base.GetSearchDomains(network, false)
+		interfaceName := *network.PortDeviceName
+		index, _ := dns.LinkIndex(interfaceName)
+		entries = append(entries, state.Interface{
+			Name:        interfaceName,
+			Index:       index,
+			NetworkID:   utils.GetString(network.Id),
+			NetworkName: utils.GetString(network.Name),
+			Mode:        "resolvfile",
+			DNS:         networkServers,
+			Domains:     networkDomains,
+			Files:       []string{ResolvConfPath},
+		})
+		for _, server := range networkServers {
+			if !utils.Contains(servers, server) {
+				servers = append(servers, server)
+			}
+		}
+		for _, domain := range networkDomains {
+			if !utils.Contains(domains, domain) {
+				domains = append(domains, domain)
+			}
+		}
+	}
+	return entries, servers, domains
+}
+
+// processNetworks renders the managed block for every network and rewrites the file when it changed.
+// Without any network left the block is removed again.
+func (m *ResolvFileMode) processNetworks(networks *service.GetNetworksResponse, logger *log.Logger) {
+	entries, servers, domains := resolvFileResolvers(networks, m.BaseMode)
+	current := map[string]struct{}{}
+	for _, entry := range entries {
+		current[entry.Name] = struct{}{}
+	}
+	logger.Verbose("Processing %d networks for %s, %d with DNS servers", len(*networks.JSON200), ResolvConfPath, len(entries))
+
+	content, err := os.ReadFile(ResolvConfPath)
+	if err != nil && !os.IsNotExist(err) {
+		logger.Warn("Failed to read %s: %v", ResolvConfPath, err)
+		countSummary(func(s *RunSummary) { s.Errors++ })
+		return
+	}
+	original := stripResolvBlock(string(content))
+
+	want := original
+	if len(entries) > 0 {
+		want = renderResolvConf(original, servers, domains)
+	}
+	if len(servers) > resolvMaxNameservers {
+		logger.Warn("%s can only use %d nameservers, ignoring %v", ResolvConfPath, resolvMaxNameservers, servers[resolvMaxNameservers:])
+	}
+
+	switch {
+	case want == string(content):
+		if len(entries) > 0 {
+			logger.Verbose("No changes needed for %s; it already has DNS %v and search domains %v", ResolvConfPath, servers, domains)
+		}
+	case m.IsDryRun():
+		if len(entries) == 0 {
+			logger.Info("[dry-run] Would remove the zeroplex block from %s", ResolvConfPath)
+		} else {
+			logger.Info("[dry-run] Would set %s to DNS %v and search domains %v", ResolvConfPath, servers, domains)
+		}
+		return
+	default:
+		if err := saveResolvBackup(original); err != nil {
+			logger.Warn("Not changing %s, failed to back it up: %v", ResolvConfPath, err)
+			countSummary(func(s *RunSummary) { s.Errors++ })
+			return
+		}
+		if err := writeResolvConf(want); err != nil {
+			logger.Warn("Failed to write %s: %v", ResolvConfPath, err)
+			countSummary(func(s *RunSummary) { s.Errors++ })
+			return
+		}
+		if len(entries) == 0 {
+			logger.Info("Removed the zeroplex block from %s", ResolvConfPath)
+			removeResolvBackup(logger)
+		} else {
+			logger.Verbose("Configured %s with DNS Servers=%v, Search Domains=%v", ResolvConfPath, servers, domains)
+		}
+	}
+
+	for _, entry := range entries {
+		recordManaged(entry, logger)
+	}
+	revertLeft("resolvfile", current, m.IsDryRun(), logger, func(entry state.Interface) {
+		logger.Info("Network %s left, dropped %s from %s", entry.NetworkID, entry.Name, ResolvConfPath)
+	})
+}
+
+// resolvFileDrift compares each network's desired DNS with the managed block of the file
+func resolvFileDrift(networks *service.GetNetworksResponse, base *BaseMode, logger *log.Logger) []Drift {
+	entries, _, _ := resolvFileResolvers(networks, base)
+	content, err := os.ReadFile(ResolvConfPath)
+	if err != nil && !os.IsNotExist(err) {
+		logger.Warn("Failed to read %s: %v", ResolvConfPath, err)
+		return nil
+	}
+	haveServers, haveDomains := parseResolvBlock(string(content))
+	var drifts []Drift
+	for _, entry := range entries {
+		for _, server := range entry.DNS {
+			if !utils.Contains(haveServers, server) {
+				drifts = append(drifts, Drift{Interface: entry.Name, NetworkID: entry.NetworkID, Kind: DriftDNS, Current: haveServers, Desired: entry.DNS})
+				break
+			}
+		}
+		for _, domain := range entry.Domains {
+			if !utils.Contains(haveDomains, domain) {
+				drifts = append(drifts, Drift{Interface: entry.Name, NetworkID: entry.NetworkID, Kind: DriftDomains, Current: haveDomains, Desired: entry.Domains})
+				break
+			}
+		}
+	}
+	return drifts
+}
+
+// renderResolvConf puts the managed block at the top of original, so the ZeroTier servers are asked
+// first. The search list of the block also carries the domains of the original search and domain
+// lines, which are commented out.
+func renderResolvConf(original string, servers, domains []string) string {
+	var rest []string
+	search := append([]string{}, domains...)
+	for _, line := range strings.SplitAfter(original, "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) > 1 && (fields[0] == "search" || fields[0] == "domain") {
+			for _, domain := range fields[1:] {
+				if !utils.Contains(search, domain) {
+					search = append(search, domain)
+				}
+			}
+			line = resolvDisabled + line
+		}
+		rest = append(rest, line)
+	}
+
+	var b strings.Builder
+	b.WriteString(resolvBlockBegin + "\n")
+	for _, server := range servers {
+		fmt.Fprintf(&b, "nameserver %s\n", server)
+	}
+	if len(search) > 0 {
+		fmt.Fprintf(&b, "search %s\n", strings.Join(search, " "))
+	}
+	b.WriteString(resolvBlockEnd + "\n")
+	for _, line := range rest {
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// stripResolvBlock returns content as it was before zeroplex changed it: without the managed block
+// and with the search and domain lines it commented out enabled again
+func stripResolvBlock(content string) string {
+	var b strings.Builder
+	inBlock := false
+	for _, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == resolvBlockBegin:
+			inBlock = true
+		case trimmed == resolvBlockEnd:
+			inBlock = false
+		case inBlock:
+		default:
+			b.WriteString(strings.TrimPrefix(line, resolvDisabled))
+		}
+	}
+	return b.String()
+}
+
+// parseResolvBlock returns the nameservers and search domains of the managed block
+func parseResolvBlock(content string) (servers, domains []string) {
+	inBlock := false
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case strings.TrimSpace(line) == resolvBlockBegin:
+			inBlock = true
+		case strings.TrimSpace(line) == resolvBlockEnd:
+			inBlock = false
+		case !inBlock || len(fields) < 2:
+		case fields[0] == "nameserver":
+			servers = append(servers, fields[1])
+		case fields[0] == "search":
+			domains = append(domains, fields[1:]...)
+		}
+	}
+	return servers, domains
+}
+
+// resolvBackupName is the file in the state directory that keeps the file without the managed block
+const resolvBackupName = "resolv.conf.backup"
+
+// resolvBackupPath is where the file without the managed block is kept, next to the state store
+func resolvBackupPath() string {
+	return state.DefaultDir().File(resolvBackupName)
+}
+
+// saveResolvBackup records the file as it would be without zeroplex. It is refreshed on every write,
+// so edits made outside the block while zeroplex manages the file survive a restore.
+func saveResolvBackup(original string) error {
+	return state.DefaultDir().WriteFile(resolvBackupName, []byte(original), 0644)
+}
+
+func removeResolvBackup(logger *log.Logger) {
+	if err := os.Remove(resolvBackupPath()); err != nil && !os.IsNotExist(err) {
+		logger.Debug("Failed to remove %s: %v", resolvBackupPath(), err)
+	}
+}
+
+// writeResolvConf replaces the file atomically. A symlinked /etc/resolv.conf is followed, so the
+// link is kept and its target is replaced.
+func writeResolvConf(content string) error {
+	path := ResolvConfPath
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".resolv.conf-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// restoreResolvConf puts back the file as it was before zeroplex changed it, from the backup when
+// there is one. It reports whether the file was changed.
+func restoreResolvConf(dryRun bool, logger *log.Logger) bool {
+	content, err := os.ReadFile(ResolvConfPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warn("Failed to read %s: %v", ResolvConfPath, err)
+		}
+		return false
+	}
+	if !strings.Contains(string(content), resolvBlockBegin) {
+		logger.Debug("%s has no zeroplex block, nothing to restore", ResolvConfPath)
+		removeResolvBackup(logger)
+		return false
+	}
+	original := stripResolvBlock(string(content))
+	if backup, err := os.ReadFile(resolvBackupPath()); err == nil {
+		original = string(backup)
+	}
+	if dryRun {
+		logger.Info("[dry-run] Would restore %s", ResolvConfPath)
+		return true
+	}
+	if err := writeResolvConf(original); err != nil {
+		logger.Warn("Failed to restore %s: %v", ResolvConfPath, err)
+		return false
+	}
+	logger.Info("Restored %s", ResolvConfPath)
+	removeResolvBackup(logger)
+	return true
+}
@@ -0,0 +1,175 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/dns"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/state"
+
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// RestoreManaged undoes zeroplex's changes on every interface it manages: resolved links are reverted,
+// generated networkd files are removed, NetworkManager connections are reapplied, resolvconf entries
+// are deleted, dnsmasq snippets, unbound forward zones, OpenWrt dnsmasq servers and macOS resolver
+// files are removed, zeroplex NRPT rules are removed, Windows adapters are cleared and /etc/resolv.conf is put back. It returns the restored interfaces.
+// The interfaces of networks set to another mode under networks: are restored by that mode.
+func RestoreManaged(cfg config.Config, dryRun bool) []string {
+	var restored []string
+	for _, mode := range cfg.Default.ActiveModes() {
+		restored = append(restored, restoreMode(cfg, mode, dryRun)...)
+	}
+	sort.Strings(restored)
+	return slices.Compact(restored)
+}
+
+// restoreMode undoes the changes of mode, see RestoreManaged
+func restoreMode(cfg config.Config, mode string, dryRun bool) []string {
+	logger := log.NewScopedLogger("[modes/restore]", cfg.Default.Log.Level)
+	var restored []string
+
+	switch mode {
+	case "resolved":
+		for _, iface := range dns.GetChangedInterfaces() {
+			if dryRun {
+				logger.Info("[dry-run] Would restore DNS for %s", iface)
+				restored = append(restored, iface)
+				continue
+			}
+			if dns.RestoreSavedDNS(context.Background(), iface, cfg.Default.Log.Level) {
+				delete(managedZTInterfaces, iface)
+				forgetManaged(iface, logger)
+				restored = append(restored, iface)
+			}
+		}
+		// Links another zeroplex process changed, such as a daemon that has since exited, have no
+		// saved DNS here; revert the ones recorded in the state store
+		store, err := state.Default()
+		if err != nil {
+			break
+		}
+		for _, entry := range store.Interfaces() {
+			if entry.Mode != "resolved" || entry.Index == 0 || slices.Contains(restored, entry.Name) {
+				continue
+			}
+			if dryRun {
+				logger.Info("[dry-run] Would restore DNS for %s", entry.Name)
+				restored = append(restored, entry.Name)
+				continue
+			}
+			if dns.RevertLink(context.Background(), entry.Name, entry.Index, cfg.Default.Log.Level) {
+				delete(managedZTInterfaces, entry.Name)
+				forgetManaged(entry.Name, logger)
+				restored = append(restored, entry.Name)
+			}
+		}
+	case "networkd":
+		checkNetworkdDir(dryRun, logger)
+		found, err := managedNetworkdFiles()
+		if err != nil {
+			logger.Warn("Could not list %s: %v", networkdDir(), err)
+		}
+		for fn := range found {
+			iface := strings.TrimSuffix(strings.TrimPrefix(fn, "99-"), ".network")
+			if dryRun {
+				logger.Info("[dry-run] Would remove %s", fn)
+				restored = append(restored, iface)
+				continue
+			}
+			if err := os.Remove(filepath.Join(networkdDir(), fn)); err != nil {
+				logger.Warn("Failed to remove %s: %v", fn, err)
+				continue
+			}
+			logger.Info("Removed %s", fn)
+			forgetManaged(iface, logger)
+			restored = append(restored, iface)
+		}
+		if !dryRun {
+			syncNetworkdIntent(logger)
+		}
+		if len(restored) > 0 && !dryRun && cfg.Default.Networkd.AutoRestart &&
+			allowReload("networkd", networkdService, "networkd.max_reloads_per_hour", cfg.Default.Networkd.MaxReloadsPerHour, logger) {
+			if err := exec.Command("networkctl", "reload").Run(); err != nil {
+				logger.Warn("Failed to reload systemd-networkd: %v", err)
+			}
+		}
+	case "networkmanager":
+		store, err := state.Default()
+		if err != nil {
+			logger.Warn("State store unavailable, cannot find NetworkManager devices to restore: %v", err)
+			break
+		}
+		for _, entry := range store.Interfaces() {
+			if entry.Mode != "networkmanager" {
+				continue
+			}
+			if dryRun {
+				logger.Info("[dry-run] Would reapply the NetworkManager connection of %s", entry.Name)
+				restored = append(restored, entry.Name)
+				continue
+			}
+			nmRevert(entry.Name, logger)
+			forgetManaged(entry.Name, logger)
+			restored = append(restored, entry.Name)
+		}
+	case "resolvconf":
+		store, err := state.Default()
+		if err != nil {
+			logger.Warn("State store unavailable, cannot find resolvconf entries to restore: %v", err)
+			break
+		}
+		for _, entry := range store.Interfaces() {
+			if entry.Mode != "resolvconf" {
+				continue
+			}
+			if dryRun {
+				logger.Info("[dry-run] Would delete the resolvconf entry of %s", entry.Name)
+				restored = append(restored, entry.Name)
+				continue
+			}
+			resolvconfDelete(entry.Name, logger)
+			forgetManaged(entry.Name, logger)
+			restored = append(restored, entry.Name)
+		}
+	case "dnsmasq":
+		restored = restoreDnsmasq(cfg.Default.Dnsmasq, dryRun, logger)
+	case "unbound":
+		restored = restoreUnbound(cfg.Default.Unbound, dryRun, logger)
+	case "openwrt":
+		restored = restoreOpenWrt(cfg.Default.OpenWrt, dryRun, logger)
+	case "macos":
+		restored = restoreMacOS(dryRun, logger)
+	case "windows":
+		restored = restoreWindows(cfg.Default.Windows, dryRun, logger)
+	case "resolvfile":
+		if !restoreResolvConf(dryRun, logger) {
+			break
+		}
+		store, err := state.Default()
+		if err != nil {
+			break
+		}
+		for _, entry := range store.Interfaces() {
+			if entry.Mode != "resolvfile" {
+				continue
+			}
+			if !dryRun {
+				forgetManaged(entry.Name, logger)
+			}
+			restored = append(restored, entry.Name)
+		}
+	default:
+		logger.Verbose("Nothing to restore in %s mode", mode)
+	}
+	return restored
+}
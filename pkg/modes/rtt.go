@@ -0,0 +1,191 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/metrics"
+	"zeroplex/pkg/utils"
+
+	"context"
+	"encoding/binary"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+// rttProbeTimeout bounds the query sent to a DNS server to measure its round-trip time; a server
+// that doesn't answer in time is put last
+const rttProbeTimeout = 2 * time.Second
+
+// rttBucket is the resolution round-trip times are compared at, so servers a few milliseconds apart
+// don't swap places with every measurement
+const rttBucket = 5 * time.Millisecond
+
+// rttSample is the last round-trip time measured to a DNS server through an interface
+type rttSample struct {
+	rtt      time.Duration
+	answered bool
+	measured time.Time
+}
+
+var (
+	rttMu      sync.Mutex
+	rttSamples = map[string]rttSample{} // by interface and server, see rttKey
+)
+
+// rttKey identifies the measurement of server through iface
+func rttKey(iface, server string) string {
+	return iface + "|" + server
+}
+
+// dialRTT opens the socket the round-trip time of server is measured through. UDP sends nothing
+// to connect, so the sockets of all servers are opened before any is probed.
+func dialRTT(iface, server string) (net.Conn, error) {
+	address := server
+	if ip := net.ParseIP(server); ip != nil && ip.IsLinkLocalUnicast() && iface != "" {
+		address = server + "%" + iface
+	}
+	return net.Dial("udp", net.JoinHostPort(address, "53"))
+}
+
+// probeRTT measures the round-trip time of an NS query for domain sent through conn. Any answer
+// counts, negative or not; only a timeout or a refused query doesn't.
+func probeRTT(ctx context.Context, conn net.Conn, domain string) (time.Duration, bool) {
+	ctx, cancel := context.WithTimeout(ctx, rttProbeTimeout)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	id := uint16(time.Now().UnixNano())
+	started := time.Now()
+	if _, err := conn.Write(rttQuery(id, domain)); err != nil {
+		return 0, false
+	}
+	reply := make([]byte, 512)
+	for {
+		n, err := conn.Read(reply)
+		if err != nil {
+			return 0, false
+		}
+		// The ID and the QR bit of the header tell a reply to this query from a stray datagram
+		if n >= 12 && binary.BigEndian.Uint16(reply) == id && reply[2]&0x80 != 0 {
+			return time.Since(started), true
+		}
+	}
+}
+
+// rttQuery builds a DNS query with the given ID asking for the NS records of domain
+func rttQuery(id uint16, domain string) []byte {
+	query := binary.BigEndian.AppendUint16(nil, id)
+	query = append(query, 0x01, 0x00) // recursion desired
+	query = append(query, 0, 1, 0, 0, 0, 0, 0, 0)
+	for _, label := range strings.Split(strings.Trim(domain, "."), ".") {
+		if label == "" || len(label) > 63 {
+			continue
+		}
+		query = append(query, byte(len(label)))
+		query = append(query, label...)
+	}
+	query = append(query, 0)
+	return append(query, 0, 2, 0, 1) // NS, IN
+}
+
+// serverRTTs returns the round-trip time of each of servers through iface, measuring those whose
+// last measurement is older than interval, all at once
+func serverRTTs(ctx context.Context, iface, domain string, servers []string, interval time.Duration, logger *log.Logger) map[string]rttSample {
+	now := time.Now()
+	samples := map[string]rttSample{}
+	var stale []string
+	rttMu.Lock()
+	for _, server := range servers {
+		sample, ok := rttSamples[rttKey(iface, server)]
+		if ok && now.Sub(sample.measured) < interval {
+			samples[server] = sample
+		} else {
+			stale = append(stale, server)
+		}
+	}
+	rttMu.Unlock()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, server := range stale {
+		conn, err := dialRTT(iface, server)
+		wg.Add(1)
+		go func(server string) {
+			defer wg.Done()
+			var rtt time.Duration
+			var answered bool
+			if err == nil {
+				rtt, answered = probeRTT(ctx, conn, domain)
+				conn.Close()
+			}
+			sample := rttSample{rtt: rtt, answered: answered, measured: now}
+			if answered {
+				logger.Debug("DNS server %s answers through %s in %s", server, iface, rtt.Round(time.Microsecond))
+				metrics.Set("zeroplex_dns_server_rtt_seconds", "Round-trip time last measured to a DNS server of a ZeroTier network", rtt.Seconds(),
+					metrics.Labels{"interface": iface, "server": server})
+			} else {
+				logger.Debug("DNS server %s does not answer through %s", server, iface)
+			}
+			mu.Lock()
+			samples[server] = sample
+			mu.Unlock()
+			rttMu.Lock()
+			rttSamples[rttKey(iface, server)] = sample
+			rttMu.Unlock()
+		}(server)
+	}
+	wg.Wait()
+	return samples
+}
+
+// orderServersByRTT puts the DNS servers of each of networks fastest first with server_order.strategy
+// rtt. Servers that don't answer go last, and servers as fast as each other keep the order ZeroTier
+// pushed them in.
+func orderServersByRTT(ctx context.Context, networks *service.GetNetworksResponse, order config.ServerOrderConfig, logger *log.Logger) {
+	if order.Strategy != "rtt" {
+		return
+	}
+	interval, err := utils.ParseInterval(order.Interval)
+	if err != nil || interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	for i, network := range *networks.JSON200 {
+		if network.Dns == nil || network.Dns.Servers == nil || len(*network.Dns.Servers) < 2 {
+			continue
+		}
+		iface := stringValue(network.PortDeviceName)
+		domain := stringValue(network.Dns.Domain)
+		if domain == "" {
+			domain = "."
+		}
+		pushed := *network.Dns.Servers
+		samples := serverRTTs(ctx, iface, domain, pushed, interval, logger)
+		servers := append([]string{}, pushed...)
+		sort.SliceStable(servers, func(a, b int) bool {
+			sa, sb := samples[servers[a]], samples[servers[b]]
+			if sa.answered != sb.answered {
+				return sa.answered
+			}
+			return sa.rtt/rttBucket < sb.rtt/rttBucket
+		})
+		if slices.Equal(servers, pushed) {
+			continue
+		}
+		logger.Verbose("Network %s: ordering its DNS servers by round-trip time: %v", GetNetworkName(network), servers)
+		// A copy, like applyNetworkOverrides makes, so the pushed order stays in the servers' source
+		dnsConfig := *network.Dns
+		dnsConfig.Servers = &servers
+		(*networks.JSON200)[i].Dns = &dnsConfig
+	}
+}
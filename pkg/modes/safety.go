@@ -0,0 +1,175 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/events"
+	"zeroplex/pkg/exitcode"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/metrics"
+	"zeroplex/pkg/state"
+	"zeroplex/pkg/utils"
+
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+// Force lets runs exceed the safety limits; --force sets it for the whole invocation
+var Force bool
+
+// lastAbort is the plan ID of the last aborted run, so a daemon blocked on the same plan alerts once
+var (
+	lastAbortMu sync.Mutex
+	lastAbort   string
+)
+
+// Plan is what a reconcile run is about to do, as checked against the safety limits
+type Plan struct {
+	ID       string   `json:"id"`
+	Changes  []string `json:"changes,omitempty"`  // interfaces whose settings would be rewritten
+	Removals []string `json:"removals,omitempty"` // interfaces or generated files that would be removed
+}
+
+// planChanges builds the plan of a run from the drift it would correct and the interfaces recorded
+// for mode in the state store whose network is no longer configured
+func planChanges(mode string, networks *service.GetNetworksResponse, drifts []Drift, base *BaseMode) Plan {
+	var plan Plan
+	removed := map[string]bool{}
+	for _, d := range drifts {
+		if d.Kind == DriftStale {
+			removed[d.Interface] = true
+			if len(d.Current) > 0 {
+				plan.Removals = append(plan.Removals, d.Current...)
+			} else {
+				plan.Removals = append(plan.Removals, d.Interface)
+			}
+			continue
+		}
+		if !utils.Contains(plan.Changes, d.Interface) {
+			plan.Changes = append(plan.Changes, d.Interface)
+		}
+	}
+
+	if store, err := state.Default(); err == nil {
+		current := map[string]bool{}
+		for _, network := range *networks.JSON200 {
+			if base.ValidateNetwork(network) == nil && len(base.GetDNSServers(network)) > 0 {
+				current[*network.PortDeviceName] = true
+			}
+		}
+		for _, entry := range store.Interfaces() {
+			if entry.Mode == mode && !current[entry.Name] && !removed[entry.Name] {
+				plan.Removals = append(plan.Removals, entry.Name)
+			}
+		}
+	}
+
+	sort.Strings(plan.Changes)
+	sort.Strings(plan.Removals)
+	sum := sha256.Sum256([]byte(mode + "\n" + strings.Join(plan.Changes, ",") + "\n" + strings.Join(plan.Removals, ",")))
+	plan.ID = hex.EncodeToString(sum[:])[:12]
+	return plan
+}
+
+// emptyFilter reports whether the filters of the profile left none of the networks ZeroTier reported
+func emptyFilter(base *BaseMode) bool {
+	return base.joined > 0 && base.selected == 0 && base.GetConfig().Default.HasAdvancedFilters()
+}
+
+// reportEmptyFilter warns when the filters select none of the joined networks, which is almost always
+// a filter that no longer matches rather than an intentionally empty selection, and publishes it as
+// the zeroplex_filters_empty metric
+func reportEmptyFilter(base *BaseMode, logger *log.Logger) {
+	value := 0.0
+	if emptyFilter(base) {
+		value = 1
+		logger.Warn("0 of %d networks selected by filters: check the filters of the profile, since DNS is removed from every network they leave out", base.joined)
+	}
+	metrics.Set("zeroplex_filters_empty", "1 when the filters select none of the joined networks", value, metrics.Labels{"mode": base.GetModeName()})
+}
+
+// guardEmptyFilter aborts a run whose filters select none of the joined networks when
+// safety.keep_on_empty_filter is set, so a broken filter doesn't remove every managed interface
+func guardEmptyFilter(mode string, base *BaseMode, logger *log.Logger) error {
+	if !base.GetConfig().Default.Safety.KeepOnEmptyFilter || !emptyFilter(base) {
+		return nil
+	}
+	switch {
+	case base.IsDryRun():
+		logger.Warn("[dry-run] The filters select none of the %d networks; a real run would abort (safety.keep_on_empty_filter)", base.joined)
+		return nil
+	case Force:
+		logger.Warn("The filters select none of the %d networks, proceeding because of --force", base.joined)
+		return nil
+	}
+	logger.Error("Aborting: the filters select none of the %d networks and safety.keep_on_empty_filter is set. Fix the filters or rerun with --force to remove the managed interfaces", base.joined)
+	countSummary(func(s *RunSummary) { s.Errors++ })
+	metrics.Inc("zeroplex_safety_aborts_total", "Runs aborted because they would exceed a safety limit", metrics.Labels{"mode": mode})
+	return exitcode.Wrap(exitcode.SafetyLimit, fmt.Errorf("filters select none of the %d networks", base.joined))
+}
+
+// guardChanges aborts a run whose plan exceeds the safety limits of the profile, unless --force is
+// given or safety.ack names the plan. drifts is only called when a limit is set, since reading the
+// current settings of every interface isn't free.
+func guardChanges(mode string, networks *service.GetNetworksResponse, drifts func() []Drift, base *BaseMode, logger *log.Logger) error {
+	if err := guardEmptyFilter(mode, base, logger); err != nil {
+		return err
+	}
+	safety := base.GetConfig().Default.Safety
+	if safety.MaxChangesPerRun == 0 && safety.MaxRemovalsPerRun == 0 {
+		return nil
+	}
+	plan := planChanges(mode, networks, drifts(), base)
+
+	var exceeded []string
+	if safety.MaxChangesPerRun > 0 && len(plan.Changes) > safety.MaxChangesPerRun {
+		exceeded = append(exceeded, fmt.Sprintf("change %d interfaces (max_changes_per_run %d)", len(plan.Changes), safety.MaxChangesPerRun))
+	}
+	if safety.MaxRemovalsPerRun > 0 && len(plan.Removals) > safety.MaxRemovalsPerRun {
+		exceeded = append(exceeded, fmt.Sprintf("remove %d interfaces or files (max_removals_per_run %d)", len(plan.Removals), safety.MaxRemovalsPerRun))
+	}
+	if len(exceeded) == 0 {
+		logger.Debug("Plan %s within safety limits: %d changes, %d removals", plan.ID, len(plan.Changes), len(plan.Removals))
+		return nil
+	}
+	summary := strings.Join(exceeded, " and ")
+
+	switch {
+	case base.IsDryRun():
+		logger.Warn("[dry-run] This run would %s; a real run would abort (plan %s)", summary, plan.ID)
+		return nil
+	case Force:
+		logger.Warn("This run will %s, proceeding because of --force (plan %s)", summary, plan.ID)
+		return nil
+	case safety.Ack != "" && safety.Ack == plan.ID:
+		logger.Warn("This run will %s, proceeding because safety.ack acknowledges plan %s", summary, plan.ID)
+		return nil
+	}
+
+	logger.Error("Aborting: this run would %s. Changes: %v, removals: %v. Rerun with --force or set safety.ack: %q to proceed",
+		summary, plan.Changes, plan.Removals, plan.ID)
+	countSummary(func(s *RunSummary) { s.Errors++ })
+	metrics.Inc("zeroplex_safety_aborts_total", "Runs aborted because they would exceed a safety limit", metrics.Labels{"mode": mode})
+
+	lastAbortMu.Lock()
+	alerted := lastAbort == plan.ID
+	lastAbort = plan.ID
+	lastAbortMu.Unlock()
+	if !alerted {
+		events.Publish(events.Event{
+			Type:    events.TypeSafetyAbort,
+			Mode:    mode,
+			Message: "run aborted: it would " + summary,
+			Data:    map[string]interface{}{"plan_id": plan.ID, "changes": plan.Changes, "removals": plan.Removals},
+		})
+	}
+	return exitcode.Wrap(exitcode.SafetyLimit, fmt.Errorf("run would %s (plan %s)", summary, plan.ID))
+}
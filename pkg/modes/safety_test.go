@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/exitcode"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/state"
+
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+// useStateStore points the state store at an empty file for the test
+func useStateStore(t *testing.T) *state.Store {
+	t.Helper()
+	previous := state.DefaultPath
+	state.DefaultPath = filepath.Join(t.TempDir(), "state.json")
+	t.Cleanup(func() { state.DefaultPath = previous })
+	store, err := state.Default()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestGuardChangesLimitsRemovals(t *testing.T) {
+	store := useStateStore(t)
+	for _, name := range []string{"ztsafe0", "ztsafe1", "ztsafe2"} {
+		if err := store.SetInterface(state.Interface{Name: name, Mode: "networkd"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A filter that suddenly matches nothing would remove every managed interface
+	networks := &service.GetNetworksResponse{JSON200: &[]service.Network{}}
+	noDrift := func() []Drift { return nil }
+	logger := log.NewScopedLogger("[test]", "")
+	var cfg config.Config
+	cfg.Default.Safety.MaxRemovalsPerRun = 1
+
+	err := guardChanges("networkd", networks, noDrift, NewBaseMode(cfg, nil, false, "networkd"), logger)
+	if code := exitcode.Code(err); code != exitcode.SafetyLimit {
+		t.Fatalf("guardChanges over the limit = %v (exit code %d), want exit code %d", err, code, exitcode.SafetyLimit)
+	}
+	_, plan, ok := strings.Cut(err.Error(), "(plan ")
+	if !ok {
+		t.Fatalf("no plan ID in %q", err)
+	}
+	plan = strings.TrimSuffix(plan, ")")
+	// The same changes make the same plan
+	if again := guardChanges("networkd", networks, noDrift, NewBaseMode(cfg, nil, false, "networkd"), logger); again == nil || !strings.Contains(again.Error(), plan) {
+		t.Errorf("second guardChanges = %v, want plan %s again", again, plan)
+	}
+
+	if err := guardChanges("networkd", networks, noDrift, NewBaseMode(cfg, nil, true, "networkd"), logger); err != nil {
+		t.Errorf("dry run over the limit = %v, want only a warning", err)
+	}
+	// Removals of another mode's interfaces aren't this mode's to make
+	if err := guardChanges("resolved", networks, noDrift, NewBaseMode(cfg, nil, false, "resolved"), logger); err != nil {
+		t.Errorf("guardChanges of resolved = %v, want no removals", err)
+	}
+
+	cfg.Default.Safety.Ack = "000000000000"
+	if err := guardChanges("networkd", networks, noDrift, NewBaseMode(cfg, nil, false, "networkd"), logger); exitcode.Code(err) != exitcode.SafetyLimit {
+		t.Errorf("guardChanges acknowledging another plan = %v, want refused", err)
+	}
+	// Acknowledging exactly this plan lets it through
+	cfg.Default.Safety.Ack = plan
+	if err := guardChanges("networkd", networks, noDrift, NewBaseMode(cfg, nil, false, "networkd"), logger); err != nil {
+		t.Errorf("guardChanges with safety.ack: %v", err)
+	}
+
+	cfg.Default.Safety.Ack = ""
+	Force = true
+	defer func() { Force = false }()
+	if err := guardChanges("networkd", networks, noDrift, NewBaseMode(cfg, nil, false, "networkd"), logger); err != nil {
+		t.Errorf("guardChanges with --force: %v", err)
+	}
+}
+
+func TestGuardChangesLimitsChanges(t *testing.T) {
+	useStateStore(t)
+	networks := &service.GetNetworksResponse{JSON200: &[]service.Network{}}
+	drifts := func() []Drift {
+		return []Drift{
+			{Interface: "ztsafe0", Kind: DriftDNS},
+			{Interface: "ztsafe0", Kind: DriftDomains},
+			{Interface: "ztsafe1", Kind: DriftDNS},
+		}
+	}
+	logger := log.NewScopedLogger("[test]", "")
+	var cfg config.Config
+	cfg.Default.Safety.MaxChangesPerRun = 2
+	// Two kinds of drift of one interface are one change
+	if err := guardChanges("resolved", networks, drifts, NewBaseMode(cfg, nil, false, "resolved"), logger); err != nil {
+		t.Errorf("guardChanges of 2 interfaces = %v, want within max_changes_per_run 2", err)
+	}
+	cfg.Default.Safety.MaxChangesPerRun = 1
+	if err := guardChanges("resolved", networks, drifts, NewBaseMode(cfg, nil, false, "resolved"), logger); exitcode.Code(err) != exitcode.SafetyLimit {
+		t.Errorf("guardChanges of 2 interfaces = %v, want refused by max_changes_per_run 1", err)
+	}
+}
@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/utils"
+
+	"context"
+	"sort"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+// Snapshot is the desired state of a run: the networks left after the filters, the DNS each
+// interface should get and the files the mode would generate. It leaves out anything that differs
+// between runs or hosts for reasons other than the configuration, such as timestamps and ifindexes,
+// and is sorted, so two snapshots can be diffed or committed.
+type Snapshot struct {
+	Mode       string              `json:"mode" yaml:"mode"`
+	Networks   []SnapshotNetwork   `json:"networks" yaml:"networks"`
+	Interfaces []SnapshotInterface `json:"interfaces" yaml:"interfaces"`
+	Files      []SnapshotFile      `json:"files" yaml:"files"`
+}
+
+// SnapshotNetwork is a joined network the filters include
+type SnapshotNetwork struct {
+	ID        string `json:"id" yaml:"id"`
+	Name      string `json:"name" yaml:"name"`
+	Interface string `json:"interface" yaml:"interface"`
+}
+
+// SnapshotInterface is the DNS an interface should get
+type SnapshotInterface struct {
+	Name      string   `json:"name" yaml:"name"`
+	NetworkID string   `json:"network_id" yaml:"network_id"`
+	DNS       []string `json:"dns" yaml:"dns"`
+	Domains   []string `json:"domains" yaml:"domains"`
+}
+
+// SnapshotFile is a file the mode would write, with its full contents
+type SnapshotFile struct {
+	Path    string `json:"path" yaml:"path"`
+	Content string `json:"content" yaml:"content"`
+}
+
+// DesiredSnapshot returns the Snapshot of cfg for networks, which the filters have been applied to.
+// Files are only listed for the modes that generate whole files: networkd, dnsmasq, unbound with an
+// include file, and macos, each for the networks it manages.
+func DesiredSnapshot(cfg config.Config, networks *service.GetNetworksResponse) Snapshot {
+	logger := log.NewScopedLogger("[modes/snapshot]", cfg.Default.Log.Level)
+	mode := cfg.Default.Mode
+	applyNetworkOverrides(networks, cfg.Default, logger)
+	orderServersByRTT(context.Background(), networks, cfg.Default.ServerOrder, logger)
+	base := NewBaseMode(cfg, nil, true, mode)
+	features := cfg.Default.Features
+	snap := Snapshot{Mode: mode, Networks: []SnapshotNetwork{}, Interfaces: []SnapshotInterface{}, Files: []SnapshotFile{}}
+
+	for _, network := range *networks.JSON200 {
+		snap.Networks = append(snap.Networks, SnapshotNetwork{
+			ID:        utils.GetString(network.Id),
+			Name:      utils.GetString(network.Name),
+			Interface: utils.GetString(network.PortDeviceName),
+		})
+		servers := base.GetDNSServers(network)
+		if base.ValidateNetwork(network) != nil || len(servers) == 0 {
+			continue
+		}
+		domains := base.GetSearchDomains(network, features.AddReverseDomains)
+		if domains == nil {
+			domains = []string{}
+		}
+		snap.Interfaces = append(snap.Interfaces, SnapshotInterface{
+			Name:      *network.PortDeviceName,
+			NetworkID: utils.GetString(network.Id),
+			DNS:       servers,
+			Domains:   domains,
+		})
+		if cfg.Default.NetworkMode(utils.GetString(network.Id)) == "networkd" {
+			if _, rendered, err := renderNetworkdFile(network, features.AddReverseDomains, features.DNSOverTLS, features.MulticastDNS, features.ExtraSearchDomains); err == nil {
+				snap.Files = append(snap.Files, SnapshotFile{Path: networkdFilePath(*network.PortDeviceName), Content: string(rendered)})
+			} else {
+				logger.Warn("Could not render %s: %v", networkdFilePath(*network.PortDeviceName), err)
+			}
+		}
+	}
+
+	for _, active := range cfg.Default.ActiveModes() {
+		managed := networksOfMode(networks, cfg.Default, active)
+		switch active {
+		case "dnsmasq":
+			for path, file := range dnsmasqFiles(managed, base, logger) {
+				snap.Files = append(snap.Files, SnapshotFile{Path: path, Content: string(file.content)})
+			}
+		case "unbound":
+			if !cfg.Default.Unbound.Control {
+				// Blocks carried over from the current file depend on the host, not the configuration
+				unbound := cfg.Default.Unbound
+				unbound.Reconcile = true
+				content := unboundIncludeContent(unboundEntries(managed, base, logger), unbound)
+				snap.Files = append(snap.Files, SnapshotFile{Path: unbound.IncludeFile, Content: content})
+			}
+		case "macos":
+			for path, file := range resolverFiles(managed, base, logger) {
+				snap.Files = append(snap.Files, SnapshotFile{Path: path, Content: string(file.content)})
+			}
+		}
+	}
+
+	sort.Slice(snap.Networks, func(i, j int) bool { return snap.Networks[i].ID < snap.Networks[j].ID })
+	sort.Slice(snap.Interfaces, func(i, j int) bool { return snap.Interfaces[i].Name < snap.Interfaces[j].Name })
+	sort.Slice(snap.Files, func(i, j int) bool { return snap.Files[i].Path < snap.Files[j].Path })
+	return snap
+}
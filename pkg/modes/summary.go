@@ -0,0 +1,46 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"sync"
+)
+
+// RunSummary counts what a single reconcile run did
+type RunSummary struct {
+	Networks  int // networks reported by ZeroTier
+	Filtered  int // networks left after the filters
+	Applied   int // interfaces whose DNS settings were applied or changed
+	Unchanged int // interfaces that already had the desired settings
+	Removed   int // interfaces restored because their network went away
+	Errors    int // per-interface failures that did not abort the run
+	Held      int // networks held back by their hold_down
+}
+
+var (
+	summaryMu sync.Mutex
+	summary   RunSummary
+)
+
+// ResetSummary clears the counters before a run
+func ResetSummary() {
+	summaryMu.Lock()
+	defer summaryMu.Unlock()
+	summary = RunSummary{}
+}
+
+// Summary returns the counters of the current or last run
+func Summary() RunSummary {
+	summaryMu.Lock()
+	defer summaryMu.Unlock()
+	return summary
+}
+
+// countSummary updates the counters of the current run
+func countSummary(update func(s *RunSummary)) {
+	summaryMu.Lock()
+	defer summaryMu.Unlock()
+	update(&summary)
+}
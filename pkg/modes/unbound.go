@@ -0,0 +1,473 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/client"
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/dns"
+	"zeroplex/pkg/initsys"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/state"
+	"zeroplex/pkg/utils"
+
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+// unboundFileHeader marks the include file zeroplex generates, so no other file is ever overwritten
+const unboundFileHeader = "# Generated by zeroplex for ZeroTier DNS, do not edit"
+
+// unboundBlockPrefix starts the stanzas of one interface in the include file
+const unboundBlockPrefix = "# zeroplex: interface "
+
+// UnboundMode forwards the domains of each ZeroTier network to its DNS servers through unbound.
+// The forward-zone stanzas are written to an include file and unbound is reloaded, or, with
+// unbound.control, added at runtime with "unbound-control forward_add" so the cache survives.
+type UnboundMode struct {
+	*BaseMode
+}
+
+// NewUnboundMode creates a new unbound mode runner
+func NewUnboundMode(cfg config.Config, zt *client.Client, dryRun bool) (*UnboundMode, error) {
+	logger := log.NewScopedLogger("[modes/unbound]", cfg.Default.Log.Level)
+	if cfg.Default.Unbound.Control {
+		if !utils.CommandExists("unbound-control") {
+			logger.Error("unbound-control command not found")
+			return nil, fmt.Errorf("unbound-control is required with unbound.control but is not available")
+		}
+	} else {
+		dir := filepath.Dir(cfg.Default.Unbound.IncludeFile)
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			logger.Error("unbound configuration directory %s not found", dir)
+			return nil, fmt.Errorf("unbound configuration directory %s does not exist", dir)
+		}
+	}
+
+	return &UnboundMode{
+		BaseMode: NewBaseMode(cfg, zt, dryRun, "unbound"),
+	}, nil
+}
+
+// GetMode returns the mode name
+func (u *UnboundMode) GetMode() string {
+	return "unbound"
+}
+
+// Run executes the unbound mode logic
+func (u *UnboundMode) Run(ctx context.Context) error {
+	logger := log.NewScopedLogger("[modes/unbound]", u.GetConfig().Default.Log.Level).WithContext(ctx)
+	logger.Trace(">>> UnboundMode.Run() started")
+	logger.Debug("Running in unbound mode (dry-run: %t, control: %t, reconcile: %t)",
+		u.IsDryRun(), u.GetConfig().Default.Unbound.Control, u.GetConfig().Default.Unbound.Reconcile)
+
+	networks, err := u.ProcessNetworks(ctx)
+	if err != nil {
+		logger.Error("Failed to process networks: %v", err)
+		return fmt.Errorf("failed to process networks: %w", err)
+	}
+
+	logIgnoredMDNSAndDoT(u.GetConfig().Default.Features, networks, "unbound forward zones have no per-network mDNS or DNS-over-TLS settings, ignoring them", logger)
+
+	if !changesAllowed(u.BaseMode, logger) {
+		reportDrift("unbound", unboundDrift(networks, u.BaseMode, logger), logger)
+		return nil
+	}
+
+	if err := guardChanges("unbound", networks, func() []Drift { return unboundDrift(networks, u.BaseMode, logger) }, u.BaseMode, logger); err != nil {
+		return err
+	}
+
+	if u.GetConfig().Default.Unbound.Control {
+		u.processZones(networks, logger)
+	} else {
+		u.processIncludeFile(networks, logger)
+	}
+
+	logger.Trace("<<< UnboundMode.Run() completed")
+	return nil
+}
+
+// unboundEntries returns the interfaces whose domains should be forwarded, one entry per network.
+// Networks without servers or domains are skipped, since a forward zone needs both.
+func unboundEntries(networks *service.GetNetworksResponse, base *BaseMode, logger *log.Logger) []state.Interface {
+	cfg := base.GetConfig().Default
+	var entries []state.Interface
+	for _, network := range *networks.JSON200 {
+		if base.ValidateNetwork(network) != nil {
+			continue
+		}
+		servers := base.GetDNSServers(network)
+		domains := base.GetSearchDomains(network, cfg.Features.AddReverseDomains)
+		if len(servers) == 0 || len(domains) == 0 {
+			logger.Debug("Network %s has no DNS servers or domains, nothing to forward", GetNetworkName(network))
+			continue
+		}
+		interfaceName := *network.PortDeviceName
+		// forward-addr takes link-local IPv6 servers with their zone
+		for i, server := range servers {
+			if ip := net.ParseIP(server); ip != nil && ip.To4() == nil && ip.IsLinkLocalUnicast() {
+				servers[i] = server + "%" + interfaceName
+			}
+		}
+		index, _ := dns.LinkIndex(interfaceName)
+		entry := state.Interface{
+			Name:        interfaceName,
+			Index:       index,
+			NetworkID:   utils.GetString(network.Id),
+			NetworkName: utils.GetString(network.Name),
+			Mode:        "unbound",
+			DNS:         servers,
+			Domains:     domains,
+		}
+		if !cfg.Unbound.Control {
+			entry.Files = []string{cfg.Unbound.IncludeFile}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// unboundZone returns the zone name unbound uses for a domain
+func unboundZone(domain string) string {
+	return strings.TrimSuffix(domain, ".") + "."
+}
+
+// unboundBlock renders the stanzas of one interface. Private ZeroTier zones are unsigned, so they
+// are marked insecure to keep DNSSEC validation from failing them; reverse zones are made
+// transparent, since unbound answers the private address ranges itself by default.
+func unboundBlock(entry state.Interface) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s%s, ZeroTier network %s\n", unboundBlockPrefix, entry.Name, entry.NetworkName)
+	b.WriteString("server:\n")
+	for _, domain := range entry.Domains {
+		fmt.Fprintf(&b, "    domain-insecure: %q\n", unboundZone(domain))
+		if strings.HasSuffix(domain, ".arpa") {
+			fmt.Fprintf(&b, "    local-zone: %q transparent\n", unboundZone(domain))
+		}
+	}
+	for _, domain := range entry.Domains {
+		b.WriteString("forward-zone:\n")
+		fmt.Fprintf(&b, "    name: %q\n", unboundZone(domain))
+		for _, server := range entry.DNS {
+			fmt.Fprintf(&b, "    forward-addr: %s\n", server)
+		}
+	}
+	return b.String()
+}
+
+// unboundIncludeBlocks splits the generated include file into the blocks of each interface
+func unboundIncludeBlocks(path string) (map[string]string, bool) {
+	content, err := os.ReadFile(path)
+	if err != nil || !strings.HasPrefix(string(content), unboundFileHeader) {
+		return nil, false
+	}
+	blocks := map[string]string{}
+	var name string
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		if rest, ok := strings.CutPrefix(line, unboundBlockPrefix); ok {
+			name, _, _ = strings.Cut(strings.TrimSpace(rest), ",")
+		}
+		if name != "" {
+			blocks[name] += line
+		}
+	}
+	return blocks, true
+}
+
+// unboundIncludeContent renders the include file. Without reconcile the blocks of interfaces whose
+// network was left are carried over from the current file.
+func unboundIncludeContent(entries []state.Interface, cfg config.UnboundConfig) string {
+	blocks := map[string]string{}
+	if existing, ok := unboundIncludeBlocks(cfg.IncludeFile); ok && !cfg.Reconcile {
+		blocks = existing
+	}
+	for _, entry := range entries {
+		blocks[entry.Name] = unboundBlock(entry)
+	}
+	names := make([]string, 0, len(blocks))
+	for name := range blocks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(unboundFileHeader + "\n")
+	for _, name := range names {
+		b.WriteString(blocks[name])
+	}
+	return b.String()
+}
+
+// processIncludeFile rewrites the include file if it changed and reloads unbound
+func (u *UnboundMode) processIncludeFile(networks *service.GetNetworksResponse, logger *log.Logger) {
+	cfg := u.GetConfig().Default.Unbound
+	entries := unboundEntries(networks, u.BaseMode, logger)
+	content := unboundIncludeContent(entries, cfg)
+	current := map[string]struct{}{}
+	for _, entry := range entries {
+		current[entry.Name] = struct{}{}
+	}
+	logger.Verbose("Processing %d networks for unbound configuration", len(*networks.JSON200))
+
+	if existing, err := os.ReadFile(cfg.IncludeFile); err == nil && string(existing) == content {
+		logger.Verbose("No changes needed for %s; already up-to-date", cfg.IncludeFile)
+		for _, entry := range entries {
+			recordManaged(entry, logger)
+		}
+	} else if u.IsDryRun() {
+		logger.Info("[dry-run] Would write %s:\n%s", cfg.IncludeFile, content)
+	} else if err := os.WriteFile(cfg.IncludeFile, []byte(content), 0644); err != nil {
+		logger.Warn("Failed to write %s: %v", cfg.IncludeFile, err)
+		countSummary(func(s *RunSummary) { s.Errors++ })
+		return
+	} else {
+		for _, entry := range entries {
+			logger.Verbose("Configured Interface=%s, Network=%s, ID=%s, DNS Servers=%v, Zones=%v in %s",
+				entry.Name, entry.NetworkName, entry.NetworkID, entry.DNS, entry.Domains, cfg.IncludeFile)
+			recordManaged(entry, logger)
+		}
+		reloadUnbound(cfg, logger)
+	}
+
+	if cfg.Reconcile {
+		revertLeft("unbound", current, u.IsDryRun(), logger, func(entry state.Interface) {
+			logger.Info("Network %s left, no longer forwarding %v for %s", entry.NetworkID, entry.Domains, entry.Name)
+		})
+	}
+}
+
+// processZones adds the forward zones of every network at runtime with unbound-control, then
+// removes the zones of networks that were left
+func (u *UnboundMode) processZones(networks *service.GetNetworksResponse, logger *log.Logger) {
+	cfg := u.GetConfig().Default.Unbound
+	entries := unboundEntries(networks, u.BaseMode, logger)
+	forwards, err := unboundForwards()
+	if err != nil {
+		logger.Warn("Failed to list the forward zones of unbound: %v", err)
+		countSummary(func(s *RunSummary) { s.Errors++ })
+		return
+	}
+	current := map[string]struct{}{}
+	wanted := map[string]bool{}
+	logger.Verbose("Processing %d networks for unbound forward zones", len(*networks.JSON200))
+
+	for _, entry := range entries {
+		current[entry.Name] = struct{}{}
+		var missing []string
+		for _, domain := range entry.Domains {
+			wanted[unboundZone(domain)] = true
+			if !dns.CompareDNS(forwards[unboundZone(domain)], entry.DNS) {
+				missing = append(missing, domain)
+			}
+		}
+		if len(missing) == 0 {
+			logger.Verbose("No changes needed for %s; unbound already forwards %v to %v", entry.Name, entry.Domains, entry.DNS)
+			recordManaged(entry, logger)
+			continue
+		}
+		if u.IsDryRun() {
+			logger.Info("[dry-run] Would forward %v to %v for %s through unbound-control", missing, entry.DNS, entry.Name)
+			continue
+		}
+		failed := false
+		for _, domain := range missing {
+			if err := unboundAddZone(domain, entry.DNS); err != nil {
+				logger.Warn("Failed to add the unbound forward zone %s for %s: %v", domain, entry.Name, err)
+				failed = true
+			}
+		}
+		if failed {
+			countSummary(func(s *RunSummary) { s.Errors++ })
+			continue
+		}
+		logger.Verbose("Configured Interface=%s, Network=%s, ID=%s, DNS Servers=%v, Zones=%v through unbound-control",
+			entry.Name, entry.NetworkName, entry.NetworkID, entry.DNS, entry.Domains)
+		recordManaged(entry, logger)
+	}
+
+	if cfg.Reconcile {
+		revertLeft("unbound", current, u.IsDryRun(), logger, func(entry state.Interface) {
+			logger.Info("Network %s left, removing the unbound forward zones %v of %s", entry.NetworkID, entry.Domains, entry.Name)
+			for _, domain := range entry.Domains {
+				if !wanted[unboundZone(domain)] {
+					unboundRemoveZone(domain, logger)
+				}
+			}
+		})
+	}
+}
+
+// unboundForwards lists the forward zones unbound holds, with their servers
+func unboundForwards() (map[string][]string, error) {
+	out, err := exec.Command("unbound-control", "list_forwards").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	forwards := map[string][]string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		// example.com. IN forward [+i] 10.0.0.1 10.0.0.2
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[2] != "forward" {
+			continue
+		}
+		var servers []string
+		for _, field := range fields[3:] {
+			if !strings.HasPrefix(field, "+") {
+				servers = append(servers, field)
+			}
+		}
+		forwards[strings.ToLower(fields[0])] = servers
+	}
+	return forwards, nil
+}
+
+// unboundAddZone adds or replaces a forward zone, marked insecure like in the include file
+func unboundAddZone(domain string, servers []string) error {
+	if strings.HasSuffix(domain, ".arpa") {
+		if out, err := exec.Command("unbound-control", "local_zone", unboundZone(domain), "transparent").CombinedOutput(); err != nil {
+			return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+		}
+	}
+	args := append([]string{"forward_add", "+i", unboundZone(domain)}, servers...)
+	if out, err := exec.Command("unbound-control", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// unboundRemoveZone removes a forward zone added by unboundAddZone
+func unboundRemoveZone(domain string, logger *log.Logger) {
+	if out, err := exec.Command("unbound-control", "forward_remove", "+i", unboundZone(domain)).CombinedOutput(); err != nil {
+		logger.Warn("Failed to remove the unbound forward zone %s: %v: %s", domain, err, strings.TrimSpace(string(out)))
+	}
+	if strings.HasSuffix(domain, ".arpa") {
+		if out, err := exec.Command("unbound-control", "local_zone_remove", unboundZone(domain)).CombinedOutput(); err != nil {
+			logger.Warn("Failed to remove the unbound local zone %s: %v: %s", domain, err, strings.TrimSpace(string(out)))
+		}
+	}
+}
+
+// reloadUnbound makes unbound read the include file again
+func reloadUnbound(cfg config.UnboundConfig, logger *log.Logger) {
+	if !cfg.Reload {
+		logger.Debug("unbound.reload is off, not reloading %s", cfg.Service)
+		return
+	}
+	services := initsys.Current()
+	logger.Info("Forward zones changed; reloading %s...", cfg.Service)
+	if err := services.Reload(cfg.Service); err != nil {
+		logger.Warn("Failed to reload %s (%s): %v", cfg.Service, services.Name(), err)
+		countSummary(func(s *RunSummary) { s.Errors++ })
+	}
+}
+
+// unboundDrift compares the forward zones unbound has with the ones it should have
+func unboundDrift(networks *service.GetNetworksResponse, base *BaseMode, logger *log.Logger) []Drift {
+	cfg := base.GetConfig().Default.Unbound
+	entries := unboundEntries(networks, base, logger)
+	current := map[string]bool{}
+	for _, entry := range entries {
+		current[entry.Name] = true
+	}
+	var drifts []Drift
+
+	if !cfg.Control {
+		blocks, _ := unboundIncludeBlocks(cfg.IncludeFile)
+		for _, entry := range entries {
+			if have, ok := blocks[entry.Name]; !ok || have != unboundBlock(entry) {
+				d := Drift{Interface: entry.Name, NetworkID: entry.NetworkID, Kind: DriftFile, Desired: []string{cfg.IncludeFile}}
+				if ok {
+					d.Current = []string{cfg.IncludeFile}
+				}
+				drifts = append(drifts, d)
+			}
+		}
+		if cfg.Reconcile {
+			for name := range blocks {
+				if !current[name] {
+					drifts = append(drifts, Drift{Interface: name, Kind: DriftStale})
+				}
+			}
+		}
+		return drifts
+	}
+
+	forwards, err := unboundForwards()
+	if err != nil {
+		logger.Warn("Failed to list the forward zones of unbound: %v", err)
+		return nil
+	}
+	for _, entry := range entries {
+		var have []string
+		for _, domain := range entry.Domains {
+			if servers, ok := forwards[unboundZone(domain)]; ok {
+				have = append(have, domain)
+				if !dns.CompareDNS(servers, entry.DNS) {
+					drifts = append(drifts, Drift{Interface: entry.Name, NetworkID: entry.NetworkID, Kind: DriftDNS, Current: servers, Desired: entry.DNS})
+				}
+			}
+		}
+		if !dns.CompareDNS(have, entry.Domains) {
+			drifts = append(drifts, Drift{Interface: entry.Name, NetworkID: entry.NetworkID, Kind: DriftDomains, Current: have, Desired: entry.Domains})
+		}
+	}
+	if store, err := state.Default(); err == nil && cfg.Reconcile {
+		for _, entry := range store.Interfaces() {
+			if entry.Mode == "unbound" && !current[entry.Name] {
+				drifts = append(drifts, Drift{Interface: entry.Name, NetworkID: entry.NetworkID, Kind: DriftStale, Current: entry.Domains})
+			}
+		}
+	}
+	return drifts
+}
+
+// restoreUnbound removes every forward zone zeroplex added, returning the interfaces whose zones
+// were removed
+func restoreUnbound(cfg config.UnboundConfig, dryRun bool, logger *log.Logger) []string {
+	var restored []string
+	store, err := state.Default()
+	if err != nil {
+		logger.Warn("State store unavailable, cannot find unbound forward zones to restore: %v", err)
+		return nil
+	}
+	for _, entry := range store.Interfaces() {
+		if entry.Mode != "unbound" {
+			continue
+		}
+		restored = append(restored, entry.Name)
+		if dryRun {
+			logger.Info("[dry-run] Would remove the unbound forward zones %v of %s", entry.Domains, entry.Name)
+			continue
+		}
+		if cfg.Control {
+			for _, domain := range entry.Domains {
+				unboundRemoveZone(domain, logger)
+			}
+		}
+		forgetManaged(entry.Name, logger)
+	}
+
+	// unbound refuses to start when a file it includes by name is missing, so it is emptied rather than removed
+	if blocks, ok := unboundIncludeBlocks(cfg.IncludeFile); ok && len(blocks) > 0 && !cfg.Control {
+		if dryRun {
+			logger.Info("[dry-run] Would empty %s", cfg.IncludeFile)
+		} else if err := os.WriteFile(cfg.IncludeFile, []byte(unboundFileHeader+"\n"), 0644); err != nil {
+			logger.Warn("Failed to empty %s: %v", cfg.IncludeFile, err)
+		} else {
+			logger.Info("Removed the ZeroTier forward zones from %s", cfg.IncludeFile)
+			reloadUnbound(cfg, logger)
+		}
+	}
+	return restored
+}
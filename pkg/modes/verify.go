@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/exitcode"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/metrics"
+	"zeroplex/pkg/utils"
+
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+// defaultVerifyTimeout bounds how long a canary lookup may keep failing before the apply is marked failed
+const defaultVerifyTimeout = 10 * time.Second
+
+// verifyRetryDelay is the pause between canary lookups while waiting for an apply to take effect
+const verifyRetryDelay = time.Second
+
+// VerificationError reports that DNS settings were applied but the canary record did not resolve
+type VerificationError struct {
+	Interface string
+	Host      string
+	Err       error
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("apply verification failed on %s: %s did not resolve: %v", e.Interface, e.Host, e.Err)
+}
+
+func (e *VerificationError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode reports a failed verification as a partial apply
+func (e *VerificationError) ExitCode() int {
+	return exitcode.PartialApply
+}
+
+// queryThroughLink resolves host using only the DNS servers of iface
+func queryThroughLink(ctx context.Context, iface, host string) error {
+	out, err := exec.CommandContext(ctx, "resolvectl", "query", "-i", iface, host).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// VerifyApplied resolves features.verify_hostname through every configured interface and returns a
+// *VerificationError for the first one where it does not succeed within features.verify_timeout.
+// It does nothing when no canary is configured or on a dry run.
+func (b *BaseMode) VerifyApplied(ctx context.Context, networks *service.GetNetworksResponse) error {
+	features := b.cfg.Default.Features
+	if features.VerifyHostname == "" || b.dryRun || networks == nil || networks.JSON200 == nil {
+		return nil
+	}
+	logger := log.NewScopedLogger(fmt.Sprintf("[modes/%s/verify]", b.mode), b.cfg.Default.Log.Level).WithContext(ctx)
+	timeout := defaultVerifyTimeout
+	if features.VerifyTimeout != "" {
+		if d, err := time.ParseDuration(features.VerifyTimeout); err == nil && d > 0 {
+			timeout = d
+		} else {
+			logger.Warn("Invalid verify_timeout %q, using %s", features.VerifyTimeout, timeout)
+		}
+	}
+
+	for _, network := range *networks.JSON200 {
+		if network.PortDeviceName == nil || network.Dns == nil || network.Dns.Servers == nil || len(*network.Dns.Servers) == 0 {
+			continue
+		}
+		iface := *network.PortDeviceName
+		host, ok := utils.NetworkVars(iface, stringValue(network.Id), b.GetDNSDomain(network)).ExpandStrict(features.VerifyHostname)
+		if !ok {
+			logger.Debug("Skipping verification of %s: it has no value for a variable in %q", iface, features.VerifyHostname)
+			continue
+		}
+		if err := verifyLink(ctx, iface, host, timeout); err != nil {
+			metrics.Inc("zeroplex_verify_total", "Apply verification lookups by result", metrics.Labels{"interface": iface, "result": "failure"})
+			logger.Warn("Canary %s did not resolve through %s within %s: %v", host, iface, timeout, err)
+			return &VerificationError{Interface: iface, Host: host, Err: err}
+		}
+		metrics.Inc("zeroplex_verify_total", "Apply verification lookups by result", metrics.Labels{"interface": iface, "result": "success"})
+		logger.Verbose("Verified %s: %s resolves through the link", iface, host)
+	}
+	return nil
+}
+
+// verifyLink retries the canary lookup until it succeeds or timeout elapses
+func verifyLink(ctx context.Context, iface, host string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		err := queryThroughLink(ctx, iface, host)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(verifyRetryDelay):
+		}
+	}
+}
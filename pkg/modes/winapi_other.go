@@ -0,0 +1,41 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !windows
+
+package modes
+
+import (
+	"zeroplex/pkg/log"
+
+	"errors"
+)
+
+var errWindowsOnly = errors.New("adapter DNS settings and NRPT rules can only be changed on Windows")
+
+func adapterDNSSettings(name string) (adapterDNS, error) {
+	return adapterDNS{}, errWindowsOnly
+}
+
+func setAdapterDNS(name string, settings adapterDNS) error {
+	return errWindowsOnly
+}
+
+func nrptRules() (map[string]nrptRule, error) {
+	return nil, errWindowsOnly
+}
+
+func setNRPTRule(rule nrptRule) error {
+	return errWindowsOnly
+}
+
+func removeNRPTRule(domain string) error {
+	return errWindowsOnly
+}
+
+func nrptPolicyActive() bool {
+	return false
+}
+
+func flushWindowsCache(logger *log.Logger) {}
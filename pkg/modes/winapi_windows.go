@@ -0,0 +1,265 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/utils"
+
+	"fmt"
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// The TCP/IP parameters of each adapter, keyed by its GUID
+const (
+	tcpipInterfacesKey  = `SYSTEM\CurrentControlSet\Services\Tcpip\Parameters\Interfaces\`
+	tcpip6InterfacesKey = `SYSTEM\CurrentControlSet\Services\Tcpip6\Parameters\Interfaces\`
+)
+
+// SetInterfaceDnsSettings is only exported by iphlpapi.dll from Windows 10 2004 on
+var procSetInterfaceDnsSettings = windows.NewLazySystemDLL("iphlpapi.dll").NewProc("SetInterfaceDnsSettings")
+
+// Flags and version of DNS_INTERFACE_SETTINGS, from netioapi.h
+const (
+	dnsInterfaceSettingsVersion1 = 1
+	dnsSettingIPv6               = 0x0001
+	dnsSettingNameServer         = 0x0002
+	dnsSettingDomain             = 0x0020
+)
+
+// dnsInterfaceSettings is DNS_INTERFACE_SETTINGS
+type dnsInterfaceSettings struct {
+	Version             uint32
+	Flags               uint64
+	Domain              *uint16
+	NameServer          *uint16
+	SearchList          *uint16
+	RegistrationEnabled uint32
+	RegisterAdapterName uint32
+	EnableLLMNR         uint32
+	QueryAdapterName    uint32
+	ProfileNameServer   *uint16
+}
+
+// adapterDNSSettings reads the static DNS servers and suffix of an adapter from its registry keys,
+// where both SetInterfaceDnsSettings and netsh keep them
+func adapterDNSSettings(name string) (adapterDNS, error) {
+	adapter, err := utils.FindWindowsAdapter(name)
+	if err != nil {
+		return adapterDNS{}, err
+	}
+	var settings adapterDNS
+	for _, path := range []string{tcpipInterfacesKey, tcpip6InterfacesKey} {
+		key, err := registry.OpenKey(registry.LOCAL_MACHINE, path+adapter.GUID, registry.QUERY_VALUE)
+		if err != nil {
+			continue
+		}
+		if servers, _, err := key.GetStringValue("NameServer"); err == nil {
+			settings.Servers = append(settings.Servers, strings.FieldsFunc(servers, func(r rune) bool { return r == ',' || r == ' ' })...)
+		}
+		if domain, _, err := key.GetStringValue("Domain"); err == nil && settings.Suffix == "" {
+			settings.Suffix = domain
+		}
+		key.Close()
+	}
+	return settings, nil
+}
+
+// setAdapterDNS replaces the static DNS servers and suffix of an adapter; empty settings clear them
+func setAdapterDNS(name string, settings adapterDNS) error {
+	adapter, err := utils.FindWindowsAdapter(name)
+	if err != nil {
+		return err
+	}
+	var v4, v6 []string
+	for _, server := range settings.Servers {
+		if ip := net.ParseIP(server); ip != nil && ip.To4() == nil {
+			v6 = append(v6, server)
+		} else {
+			v4 = append(v4, server)
+		}
+	}
+	if procSetInterfaceDnsSettings.Find() != nil {
+		return setAdapterDNSNetsh(adapter, v4, v6, settings.Suffix)
+	}
+
+	guid, err := windows.GUIDFromString(adapter.GUID)
+	if err != nil {
+		return fmt.Errorf("invalid adapter GUID %s: %w", adapter.GUID, err)
+	}
+	ipv4 := dnsInterfaceSettings{Version: dnsInterfaceSettingsVersion1, Flags: dnsSettingNameServer | dnsSettingDomain}
+	ipv4.NameServer, _ = windows.UTF16PtrFromString(strings.Join(v4, ","))
+	ipv4.Domain, _ = windows.UTF16PtrFromString(settings.Suffix)
+	if err := setInterfaceDnsSettings(guid, &ipv4); err != nil {
+		return fmt.Errorf("SetInterfaceDnsSettings failed for %s: %w", name, err)
+	}
+	ipv6 := dnsInterfaceSettings{Version: dnsInterfaceSettingsVersion1, Flags: dnsSettingNameServer | dnsSettingIPv6}
+	ipv6.NameServer, _ = windows.UTF16PtrFromString(strings.Join(v6, ","))
+	if err := setInterfaceDnsSettings(guid, &ipv6); err != nil {
+		return fmt.Errorf("SetInterfaceDnsSettings failed for the IPv6 servers of %s: %w", name, err)
+	}
+	return nil
+}
+
+// setInterfaceDnsSettings calls SetInterfaceDnsSettings, which takes the GUID by value: behind a
+// pointer on amd64, in two registers on arm64 and on the stack on 386 and arm
+func setInterfaceDnsSettings(guid windows.GUID, settings *dnsInterfaceSettings) error {
+	var r uintptr
+	switch runtime.GOARCH {
+	case "amd64":
+		r, _, _ = procSetInterfaceDnsSettings.Call(uintptr(unsafe.Pointer(&guid)), uintptr(unsafe.Pointer(settings)))
+	case "arm64":
+		words := (*[2]uint64)(unsafe.Pointer(&guid))
+		r, _, _ = procSetInterfaceDnsSettings.Call(uintptr(words[0]), uintptr(words[1]), uintptr(unsafe.Pointer(settings)))
+	default:
+		words := (*[4]uint32)(unsafe.Pointer(&guid))
+		r, _, _ = procSetInterfaceDnsSettings.Call(uintptr(words[0]), uintptr(words[1]), uintptr(words[2]), uintptr(words[3]), uintptr(unsafe.Pointer(settings)))
+	}
+	if r != 0 {
+		return windows.Errno(r)
+	}
+	return nil
+}
+
+// setAdapterDNSNetsh sets the servers with netsh on releases without SetInterfaceDnsSettings. netsh
+// has no per-adapter suffix, so that goes straight into the registry value the DNS client reads.
+func setAdapterDNSNetsh(adapter utils.WindowsAdapter, v4, v6 []string, suffix string) error {
+	target := "name=" + adapter.FriendlyName
+	for _, family := range []struct {
+		name    string
+		servers []string
+	}{{"ipv4", v4}, {"ipv6", v6}} {
+		if len(family.servers) == 0 {
+			// Fails when there is nothing to delete
+			_, _ = utils.ExecuteCommand("netsh", "interface", family.name, "delete", "dnsservers", target, "address=all", "validate=no")
+			continue
+		}
+		for i, server := range family.servers {
+			args := []string{"interface", family.name, "add", "dnsservers", target, "address=" + server, "index=" + strconv.Itoa(i+1), "validate=no"}
+			if i == 0 {
+				args = []string{"interface", family.name, "set", "dnsservers", target, "source=static", "address=" + server, "register=none", "validate=no"}
+			}
+			if _, err := utils.ExecuteCommand("netsh", args...); err != nil {
+				return err
+			}
+		}
+	}
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, tcpipInterfacesKey+adapter.GUID, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("failed to open the TCP/IP parameters of %s: %w", adapter.FriendlyName, err)
+	}
+	defer key.Close()
+	if err := key.SetStringValue("Domain", suffix); err != nil {
+		return fmt.Errorf("failed to set the DNS suffix of %s: %w", adapter.FriendlyName, err)
+	}
+	return nil
+}
+
+// Local NRPT rules, and the Group Policy rules that take precedence over all of them
+const (
+	nrptLocalKey  = `SYSTEM\CurrentControlSet\Services\Dnscache\Parameters\DnsPolicyConfig`
+	nrptPolicyKey = `SOFTWARE\Policies\Microsoft\Windows NT\DNSClient\DnsPolicyConfig`
+)
+
+// The values of an NRPT rule, as Add-DnsClientNrptRule writes them
+const (
+	nrptRuleVersion      = 2
+	nrptGenericDNSServer = 0x8 // ConfigOptions: GenericDNSServers holds the servers of the namespace
+)
+
+var procDnsFlushResolverCache = windows.NewLazySystemDLL("dnsapi.dll").NewProc("DnsFlushResolverCache")
+
+// nrptRules reads the NRPT rules zeroplex created, keyed by domain
+func nrptRules() (map[string]nrptRule, error) {
+	rules := map[string]nrptRule{}
+	parent, err := registry.OpenKey(registry.LOCAL_MACHINE, nrptLocalKey, registry.ENUMERATE_SUB_KEYS)
+	if err == registry.ErrNotExist {
+		return rules, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", nrptLocalKey, err)
+	}
+	defer parent.Close()
+	names, err := parent.ReadSubKeyNames(-1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the NRPT rules: %w", err)
+	}
+	for _, name := range names {
+		domain, ok := strings.CutPrefix(name, nrptRulePrefix)
+		if !ok {
+			continue
+		}
+		key, err := registry.OpenKey(parent, name, registry.QUERY_VALUE)
+		if err != nil {
+			continue
+		}
+		rule := nrptRule{Domain: domain}
+		if servers, _, err := key.GetStringValue("GenericDNSServers"); err == nil {
+			rule.Servers = strings.FieldsFunc(servers, func(r rune) bool { return r == ';' })
+		}
+		if comment, _, err := key.GetStringValue("Comment"); err == nil {
+			rule.parseComment(comment)
+		}
+		key.Close()
+		rules[domain] = rule
+	}
+	return rules, nil
+}
+
+// setNRPTRule creates or replaces the NRPT rule of a domain, covering the domain and every name under it
+func setNRPTRule(rule nrptRule) error {
+	key, _, err := registry.CreateKey(registry.LOCAL_MACHINE, nrptLocalKey+`\`+nrptRulePrefix+rule.Domain, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("failed to create the NRPT rule of %s: %w", rule.Domain, err)
+	}
+	defer key.Close()
+	for _, set := range []func() error{
+		func() error { return key.SetDWordValue("Version", nrptRuleVersion) },
+		func() error { return key.SetStringsValue("Name", []string{rule.Domain, "." + rule.Domain}) },
+		func() error { return key.SetStringValue("GenericDNSServers", strings.Join(rule.Servers, ";")) },
+		func() error { return key.SetDWordValue("ConfigOptions", nrptGenericDNSServer) },
+		func() error { return key.SetStringValue("IPSECCARestriction", "") },
+		func() error { return key.SetStringValue("Comment", rule.comment()) },
+	} {
+		if err := set(); err != nil {
+			return fmt.Errorf("failed to write the NRPT rule of %s: %w", rule.Domain, err)
+		}
+	}
+	return nil
+}
+
+// removeNRPTRule deletes the NRPT rule zeroplex created for a domain
+func removeNRPTRule(domain string) error {
+	err := registry.DeleteKey(registry.LOCAL_MACHINE, nrptLocalKey+`\`+nrptRulePrefix+domain)
+	if err != nil && err != registry.ErrNotExist {
+		return fmt.Errorf("failed to remove the NRPT rule of %s: %w", domain, err)
+	}
+	return nil
+}
+
+// nrptPolicyActive reports whether Group Policy distributes NRPT rules, in which case the DNS
+// client ignores the local rules
+func nrptPolicyActive() bool {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, nrptPolicyKey, registry.ENUMERATE_SUB_KEYS)
+	if err != nil {
+		return false
+	}
+	defer key.Close()
+	names, err := key.ReadSubKeyNames(1)
+	return err == nil && len(names) > 0
+}
+
+// flushWindowsCache drops cached answers, so the DNS client applies changed rules at once
+func flushWindowsCache(logger *log.Logger) {
+	if r, _, err := procDnsFlushResolverCache.Call(); r == 0 {
+		logger.Debug("DnsFlushResolverCache failed: %v", err)
+	}
+}
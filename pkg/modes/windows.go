@@ -0,0 +1,422 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modes
+
+import (
+	"zeroplex/pkg/client"
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/dns"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/state"
+	"zeroplex/pkg/utils"
+
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+// WindowsMode routes the domains of each ZeroTier network to its DNS servers on Windows with Name
+// Resolution Policy Table rules, as Add-DnsClientNrptRule would, which is split DNS: other names keep
+// using the servers they had. With windows.interface_dns it also sets the DNS servers and
+// connection-specific suffix of the adapter, through SetInterfaceDnsSettings or, on releases older
+// than Windows 10 2004, netsh and the adapter's registry key.
+type WindowsMode struct {
+	*BaseMode
+}
+
+// NewWindowsMode creates a new Windows mode runner
+func NewWindowsMode(cfg config.Config, zt *client.Client, dryRun bool) (*WindowsMode, error) {
+	if runtime.GOOS != "windows" {
+		return nil, fmt.Errorf("the windows mode is only available on Windows")
+	}
+	return &WindowsMode{
+		BaseMode: NewBaseMode(cfg, zt, dryRun, "windows"),
+	}, nil
+}
+
+// GetMode returns the mode name
+func (w *WindowsMode) GetMode() string {
+	return "windows"
+}
+
+// Run executes the Windows mode logic
+func (w *WindowsMode) Run(ctx context.Context) error {
+	logger := log.NewScopedLogger("[modes/windows]", w.GetConfig().Default.Log.Level).WithContext(ctx)
+	logger.Trace(">>> WindowsMode.Run() started")
+	logger.Debug("Running in windows mode (dry-run: %t)", w.IsDryRun())
+
+	networks, err := w.ProcessNetworks(ctx)
+	if err != nil {
+		logger.Error("Failed to process networks: %v", err)
+		return fmt.Errorf("failed to process networks: %w", err)
+	}
+
+	logIgnoredMDNSAndDoT(w.GetConfig().Default.Features, networks, "Windows adapters have no per-adapter mDNS or DNS-over-TLS settings, ignoring them", logger)
+
+	settings := w.GetConfig().Default.Windows
+	if !settings.NRPT && !settings.InterfaceDNS {
+		logger.Warn("windows.nrpt and windows.interface_dns are both off, there is nothing to configure")
+	}
+	if settings.NRPT && nrptPolicyActive() {
+		logger.Warn("Group Policy distributes NRPT rules, the DNS client ignores the rules zeroplex creates")
+	}
+
+	if !changesAllowed(w.BaseMode, logger) {
+		reportDrift("windows", windowsDrift(networks, w.BaseMode, logger), logger)
+		return nil
+	}
+
+	if err := guardChanges("windows", networks, func() []Drift { return windowsDrift(networks, w.BaseMode, logger) }, w.BaseMode, logger); err != nil {
+		return err
+	}
+
+	w.processNetworks(networks, logger)
+
+	logger.Trace("<<< WindowsMode.Run() completed")
+	return nil
+}
+
+// adapterDNS is the DNS configuration of a Windows network adapter
+type adapterDNS struct {
+	Servers []string // IPv4 and IPv6 servers, in order
+	Suffix  string   // connection-specific DNS suffix
+}
+
+// domains returns the suffix as a domain list, for the state store and drift reports
+func (a adapterDNS) domains() []string {
+	if a.Suffix == "" {
+		return nil
+	}
+	return []string{a.Suffix}
+}
+
+// desired returns the settings a network's adapter should have. The suffix is the first forward
+// domain of the network; reverse domains can't be a suffix and further domains have nowhere to go.
+func (w *WindowsMode) desired(network service.Network, logger *log.Logger) adapterDNS {
+	want := adapterDNS{Servers: w.GetDNSServers(network)}
+	for _, domain := range w.GetSearchDomains(network, false) {
+		switch {
+		case strings.HasSuffix(domain, ".arpa"):
+		case want.Suffix == "":
+			want.Suffix = domain
+		default:
+			logger.Debug("Windows takes a single DNS suffix per adapter; not using %s for %s", domain, GetNetworkName(network))
+		}
+	}
+	return want
+}
+
+// nrptRulePrefix names the registry keys of the NRPT rules zeroplex creates, so only those are ever
+// replaced or removed
+const nrptRulePrefix = "zeroplex-"
+
+// nrptRule routes a domain and the names under it to the DNS servers of a ZeroTier network
+type nrptRule struct {
+	Domain    string
+	Servers   []string
+	Interface string
+	NetworkID string
+}
+
+// comment is the rule's Comment value, naming the network and adapter it was created for
+func (r nrptRule) comment() string {
+	return fmt.Sprintf("Managed by zeroplex for ZeroTier network %s on %s", r.NetworkID, r.Interface)
+}
+
+// parseComment takes the network and adapter back from a Comment value
+func (r *nrptRule) parseComment(comment string) {
+	rest, ok := strings.CutPrefix(comment, "Managed by zeroplex for ZeroTier network ")
+	if !ok {
+		return
+	}
+	r.NetworkID, r.Interface, _ = strings.Cut(rest, " on ")
+}
+
+// windowsNetwork is what a network's adapter and NRPT rules should look like
+type windowsNetwork struct {
+	entry   state.Interface
+	adapter adapterDNS
+	rules   []nrptRule
+}
+
+// windowsNetworks returns the desired configuration of every network with DNS servers. The NRPT
+// rules, with windows.nrpt, cover all domains of the network, reverse domains included.
+func (w *WindowsMode) windowsNetworks(networks *service.GetNetworksResponse, logger *log.Logger) []windowsNetwork {
+	cfg := w.GetConfig().Default
+	var result []windowsNetwork
+	for _, network := range *networks.JSON200 {
+		if w.ValidateNetwork(network) != nil {
+			continue
+		}
+		want := windowsNetwork{}
+		if cfg.Windows.InterfaceDNS {
+			want.adapter = w.desired(network, logger)
+		}
+		servers := w.GetDNSServers(network)
+		if len(servers) == 0 {
+			logger.Debug("Network %s has no DNS servers, nothing to do", GetNetworkName(network))
+			continue
+		}
+		interfaceName := *network.PortDeviceName
+		networkID := utils.GetString(network.Id)
+		var domains []string
+		if cfg.Windows.NRPT {
+			domains = w.GetSearchDomains(network, cfg.Features.AddReverseDomains)
+			for _, domain := range domains {
+				want.rules = append(want.rules, nrptRule{Domain: strings.ToLower(domain), Servers: servers, Interface: interfaceName, NetworkID: networkID})
+			}
+			if len(domains) == 0 && !cfg.Windows.InterfaceDNS {
+				logger.Debug("Network %s has no domains, nothing to route", GetNetworkName(network))
+				continue
+			}
+		} else {
+			domains = want.adapter.domains()
+		}
+		index, _ := dns.LinkIndex(interfaceName)
+		want.entry = state.Interface{
+			Name:        interfaceName,
+			Index:       index,
+			NetworkID:   networkID,
+			NetworkName: utils.GetString(network.Name),
+			Mode:        "windows",
+			DNS:         servers,
+			Domains:     domains,
+		}
+		result = append(result, want)
+	}
+	return result
+}
+
+// wantedRules collects the NRPT rules of the networks, keyed by domain
+func wantedRules(wanted []windowsNetwork, logger *log.Logger) map[string]nrptRule {
+	rules := map[string]nrptRule{}
+	for _, want := range wanted {
+		for _, rule := range want.rules {
+			if existing, ok := rules[rule.Domain]; ok {
+				logger.Warn("Domain %s is used by both %s and %s; using the servers of %s", rule.Domain, existing.Interface, rule.Interface, rule.Interface)
+			}
+			rules[rule.Domain] = rule
+		}
+	}
+	return rules
+}
+
+// processNetworks applies the NRPT rules and adapter settings of every network, then removes the
+// rules no network needs any more and clears the settings of adapters whose network was left
+func (w *WindowsMode) processNetworks(networks *service.GetNetworksResponse, logger *log.Logger) {
+	settings := w.GetConfig().Default.Windows
+	current := map[string]struct{}{}
+	failed := map[string]bool{}
+	logger.Verbose("Processing %d networks for Windows DNS configuration", len(*networks.JSON200))
+
+	wanted := w.windowsNetworks(networks, logger)
+	for _, want := range wanted {
+		current[want.entry.Name] = struct{}{}
+		if settings.InterfaceDNS && !w.configureAdapter(want, logger) {
+			failed[want.entry.Name] = true
+		}
+	}
+
+	if settings.NRPT {
+		changed := false
+		existing, err := nrptRules()
+		if err != nil {
+			logger.Warn("Could not read the NRPT rules: %v", err)
+			countSummary(func(s *RunSummary) { s.Errors++ })
+			return
+		}
+		rules := wantedRules(wanted, logger)
+		domains := make([]string, 0, len(rules))
+		for domain := range rules {
+			domains = append(domains, domain)
+		}
+		sort.Strings(domains)
+		for _, domain := range domains {
+			rule := rules[domain]
+			have, ok := existing[domain]
+			delete(existing, domain)
+			if ok && dns.CompareDNS(have.Servers, rule.Servers) && have.Interface == rule.Interface {
+				continue
+			}
+			if w.IsDryRun() {
+				logger.Info("[dry-run] Would route %s to %v with an NRPT rule", domain, rule.Servers)
+				continue
+			}
+			if err := setNRPTRule(rule); err != nil {
+				logger.Warn("%v", err)
+				failed[rule.Interface] = true
+				continue
+			}
+			logger.Verbose("Routing %s to %v for %s", domain, rule.Servers, rule.Interface)
+			changed = true
+		}
+		for domain := range existing {
+			if w.IsDryRun() {
+				logger.Info("[dry-run] Would remove the stale NRPT rule of %s", domain)
+				continue
+			}
+			logger.Info("Removing the stale NRPT rule of %s", domain)
+			if err := removeNRPTRule(domain); err != nil {
+				logger.Warn("%v", err)
+				countSummary(func(s *RunSummary) { s.Errors++ })
+				continue
+			}
+			changed = true
+		}
+		if changed {
+			flushWindowsCache(logger)
+		}
+	}
+
+	if !w.IsDryRun() {
+		for _, want := range wanted {
+			if failed[want.entry.Name] {
+				countSummary(func(s *RunSummary) { s.Errors++ })
+				continue
+			}
+			recordManaged(want.entry, logger)
+		}
+	}
+
+	revertLeft("windows", current, w.IsDryRun(), logger, func(entry state.Interface) {
+		logger.Info("Network %s left, no longer resolving %v through %s", entry.NetworkID, entry.Domains, entry.Name)
+		if settings.InterfaceDNS {
+			clearAdapterDNS(entry.Name, logger)
+		}
+	})
+}
+
+// configureAdapter sets the servers and suffix of a network's adapter, reporting whether it has them
+func (w *WindowsMode) configureAdapter(want windowsNetwork, logger *log.Logger) bool {
+	interfaceName := want.entry.Name
+	have, err := adapterDNSSettings(interfaceName)
+	if err != nil {
+		logger.Warn("Could not read the DNS settings of %s: %v", interfaceName, err)
+		return false
+	}
+	if dns.CompareDNS(have.Servers, want.adapter.Servers) && strings.EqualFold(have.Suffix, want.adapter.Suffix) {
+		logger.Verbose("No changes needed for %s; it already has DNS %v and suffix %q", interfaceName, want.adapter.Servers, want.adapter.Suffix)
+		return true
+	}
+	if w.IsDryRun() {
+		logger.Info("[dry-run] Would set %s to DNS %v and suffix %q", interfaceName, want.adapter.Servers, want.adapter.Suffix)
+		return true
+	}
+	if err := setAdapterDNS(interfaceName, want.adapter); err != nil {
+		logger.Warn("Failed to configure %s: %v", interfaceName, err)
+		return false
+	}
+	logger.Verbose("Configured Interface=%s, Network=%s, ID=%s, DNS Servers=%v, Suffix=%s",
+		interfaceName, want.entry.NetworkName, want.entry.NetworkID, want.adapter.Servers, want.adapter.Suffix)
+	return true
+}
+
+// clearAdapterDNS removes the servers and suffix of an adapter, if it is still there
+func clearAdapterDNS(name string, logger *log.Logger) {
+	if _, err := adapterDNSSettings(name); err != nil {
+		logger.Debug("Adapter %s is gone, nothing to clear", name)
+		return
+	}
+	if err := setAdapterDNS(name, adapterDNS{}); err != nil {
+		logger.Warn("Failed to clear the DNS settings of %s: %v", name, err)
+	}
+}
+
+// windowsDrift compares each network's desired DNS with its NRPT rules and the settings of its adapter
+func windowsDrift(networks *service.GetNetworksResponse, base *BaseMode, logger *log.Logger) []Drift {
+	w := &WindowsMode{BaseMode: base}
+	settings := base.GetConfig().Default.Windows
+	wanted := w.windowsNetworks(networks, logger)
+	var drifts []Drift
+	if settings.InterfaceDNS {
+		for _, want := range wanted {
+			interfaceName := want.entry.Name
+			have, err := adapterDNSSettings(interfaceName)
+			if err != nil {
+				logger.Warn("Could not read the DNS settings of %s: %v", interfaceName, err)
+				continue
+			}
+			networkID := want.entry.NetworkID
+			if !dns.CompareDNS(have.Servers, want.adapter.Servers) {
+				drifts = append(drifts, Drift{Interface: interfaceName, NetworkID: networkID, Kind: DriftDNS, Current: have.Servers, Desired: want.adapter.Servers})
+			}
+			if !strings.EqualFold(have.Suffix, want.adapter.Suffix) {
+				drifts = append(drifts, Drift{Interface: interfaceName, NetworkID: networkID, Kind: DriftDomains, Current: have.domains(), Desired: want.adapter.domains()})
+			}
+		}
+	}
+	if !settings.NRPT {
+		return drifts
+	}
+	existing, err := nrptRules()
+	if err != nil {
+		logger.Warn("Could not read the NRPT rules: %v", err)
+		return drifts
+	}
+	for domain, rule := range wantedRules(wanted, logger) {
+		have, ok := existing[domain]
+		delete(existing, domain)
+		if ok && dns.CompareDNS(have.Servers, rule.Servers) && have.Interface == rule.Interface {
+			continue
+		}
+		drifts = append(drifts, Drift{Interface: rule.Interface, NetworkID: rule.NetworkID, Kind: DriftDNS, Current: have.Servers, Desired: rule.Servers})
+	}
+	for domain, rule := range existing {
+		drifts = append(drifts, Drift{Interface: rule.Interface, NetworkID: rule.NetworkID, Kind: DriftStale, Current: []string{domain}})
+	}
+	return drifts
+}
+
+// restoreWindows removes every NRPT rule zeroplex created and, with windows.interface_dns, clears
+// the DNS settings of every adapter it configured, returning the interfaces that were restored
+func restoreWindows(settings config.WindowsConfig, dryRun bool, logger *log.Logger) []string {
+	if rules, err := nrptRules(); err != nil {
+		logger.Warn("Could not read the NRPT rules: %v", err)
+	} else if len(rules) > 0 {
+		for domain := range rules {
+			if dryRun {
+				logger.Info("[dry-run] Would remove the NRPT rule of %s", domain)
+				continue
+			}
+			if err := removeNRPTRule(domain); err != nil {
+				logger.Warn("%v", err)
+				continue
+			}
+			logger.Info("Removed the NRPT rule of %s", domain)
+		}
+		if !dryRun {
+			flushWindowsCache(logger)
+		}
+	}
+
+	store, err := state.Default()
+	if err != nil {
+		logger.Warn("State store unavailable, cannot find Windows adapters to restore: %v", err)
+		return nil
+	}
+	var restored []string
+	for _, entry := range store.Interfaces() {
+		if entry.Mode != "windows" {
+			continue
+		}
+		restored = append(restored, entry.Name)
+		if dryRun {
+			if settings.InterfaceDNS {
+				logger.Info("[dry-run] Would clear the DNS settings of %s", entry.Name)
+			}
+			continue
+		}
+		if settings.InterfaceDNS {
+			clearAdapterDNS(entry.Name, logger)
+			logger.Info("Cleared the DNS settings of %s", entry.Name)
+		}
+		forgetManaged(entry.Name, logger)
+	}
+	return restored
+}
@@ -0,0 +1,30 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package privilege works out whether zeroplex can make the changes of its configuration without
+// running as root: with capabilities, polkit rules and write access to the files it manages.
+package privilege
+
+import (
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/exitcode"
+	"zeroplex/pkg/utils"
+
+	"fmt"
+	"strings"
+)
+
+// Check returns what the process lacks to make the changes of cfg, or nothing when it runs as root
+// (elevated, on Windows). Outside Linux, only root can make them.
+func Check(cfg config.Config) []string {
+	if utils.IsPrivileged() {
+		return nil
+	}
+	return check(cfg.Default)
+}
+
+// Error is the error zeroplex exits with when it lacks what Check returned
+func Error(missing []string) error {
+	return exitcode.Wrap(exitcode.Privilege, fmt.Errorf("not running as root, and missing %s", strings.Join(missing, "; ")))
+}
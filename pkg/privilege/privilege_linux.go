@@ -0,0 +1,187 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package privilege
+
+import (
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/initsys"
+	"zeroplex/pkg/modes"
+	"zeroplex/pkg/state"
+
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"golang.org/x/sys/unix"
+)
+
+// capNetAdmin is CAP_NET_ADMIN, which systemd-resolved and systemd-networkd accept in place of
+// a polkit authorization
+const capNetAdmin = 12
+
+// checker answers the questions of check, connecting to polkit the first time it is asked
+type checker struct {
+	netAdmin bool
+	bus      *dbus.Conn
+	busErr   error
+}
+
+// check returns what p needs that the process can't do
+func check(p config.Profile) []string {
+	c := &checker{netAdmin: HasCapability(capNetAdmin)}
+	defer c.close()
+	var missing []string
+	dir := p.StateDir
+	if dir == "" {
+		dir = state.DefaultDir().Path()
+	}
+	if !writable(dir) {
+		missing = append(missing, "write access to the state directory "+dir)
+	}
+	if p.Hardening.User != "" {
+		missing = append(missing, "root to switch to hardening.user "+p.Hardening.User)
+	}
+	for _, mode := range p.ActiveModes() {
+		missing = append(missing, c.mode(mode, p)...)
+	}
+	return missing
+}
+
+// mode returns what mode needs that the process can't do
+func (c *checker) mode(mode string, p config.Profile) []string {
+	var missing []string
+	switch mode {
+	case "noop":
+	case "resolved":
+		if !c.netAdmin && !(c.allows("org.freedesktop.resolve1.set-dns-servers") && c.allows("org.freedesktop.resolve1.set-domains") && c.allows("org.freedesktop.resolve1.revert")) {
+			missing = append(missing, "CAP_NET_ADMIN, or polkit authorization for org.freedesktop.resolve1.set-dns-servers, set-domains and revert, for mode resolved")
+		}
+	case "networkd":
+		if !writable(modes.NetworkdConfigDir) && !writable(modes.NetworkdRuntimeDir) {
+			missing = append(missing, fmt.Sprintf("write access to %s or %s for mode networkd", modes.NetworkdConfigDir, modes.NetworkdRuntimeDir))
+		}
+		if p.Networkd.AutoRestart && !c.netAdmin && !c.allows("org.freedesktop.network1.reload") {
+			missing = append(missing, "CAP_NET_ADMIN, or polkit authorization for org.freedesktop.network1.reload, for networkd.auto_restart")
+		}
+	case "networkmanager":
+		if !c.allows("org.freedesktop.NetworkManager.network-control") {
+			missing = append(missing, "polkit authorization for org.freedesktop.NetworkManager.network-control, for mode networkmanager")
+		}
+	case "resolvfile":
+		path := modes.ResolvConfPath
+		if target, err := filepath.EvalSymlinks(path); err == nil {
+			path = target
+		}
+		if !writable(filepath.Dir(path)) {
+			missing = append(missing, fmt.Sprintf("write access to %s for mode resolvfile", filepath.Dir(path)))
+		}
+	case "dnsmasq":
+		dir := p.Dnsmasq.ConfigDir
+		if p.Dnsmasq.ServersFile != "" {
+			dir = filepath.Dir(p.Dnsmasq.ServersFile)
+		}
+		if !writable(dir) {
+			missing = append(missing, fmt.Sprintf("write access to %s for mode dnsmasq", dir))
+		}
+		if p.Dnsmasq.Reload {
+			missing = append(missing, c.service(p.Dnsmasq.Service, "dnsmasq", "dnsmasq.reload")...)
+		}
+	case "unbound":
+		if p.Unbound.Control {
+			break
+		}
+		if dir := filepath.Dir(p.Unbound.IncludeFile); !writable(dir) {
+			missing = append(missing, fmt.Sprintf("write access to %s for mode unbound", dir))
+		}
+		if p.Unbound.Reload {
+			missing = append(missing, c.service(p.Unbound.Service, "unbound", "unbound.reload")...)
+		}
+	default:
+		missing = append(missing, "root for mode "+mode)
+	}
+	return missing
+}
+
+// service returns what reloading service (or fallback, when it is unset) for key needs
+func (c *checker) service(service, fallback, key string) []string {
+	if service == "" {
+		service = fallback
+	}
+	// polkit rules for org.freedesktop.systemd1.manage-units usually name the unit, which only
+	// systemd itself may pass along when asking, so the reload can't be checked beforehand
+	if name := initsys.Current().Name(); name != "systemd" {
+		return []string{fmt.Sprintf("root to reload %s under %s, for %s", service, name, key)}
+	}
+	return nil
+}
+
+// allows asks polkit whether this process may do action without authenticating
+func (c *checker) allows(action string) bool {
+	if c.bus == nil && c.busErr == nil {
+		c.bus, c.busErr = dbus.ConnectSystemBus()
+	}
+	if c.busErr != nil {
+		return false
+	}
+	subject := struct {
+		Kind    string
+		Details map[string]dbus.Variant
+	}{"unix-process", map[string]dbus.Variant{
+		"pid":        dbus.MakeVariant(uint32(os.Getpid())),
+		"start-time": dbus.MakeVariant(uint64(0)),
+	}}
+	var result struct {
+		Authorized bool
+		Challenge  bool
+		Details    map[string]string
+	}
+	err := c.bus.Object("org.freedesktop.PolicyKit1", "/org/freedesktop/PolicyKit1/Authority").
+		Call("org.freedesktop.PolicyKit1.Authority.CheckAuthorization", 0, subject, action, map[string]string{}, uint32(0), "").
+		Store(&result)
+	return err == nil && result.Authorized
+}
+
+func (c *checker) close() {
+	if c.bus != nil {
+		c.bus.Close()
+	}
+}
+
+// HasCapability reports whether capability is in the effective set of the process
+func HasCapability(capability uint) bool {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "CapEff:"); ok {
+			set, err := strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+			return err == nil && set&(1<<capability) != 0
+		}
+	}
+	return false
+}
+
+// writable reports whether the process may create files in dir, or create dir in the nearest
+// directory above it that exists
+func writable(dir string) bool {
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			return info.IsDir() && unix.Faccessat(unix.AT_FDCWD, dir, unix.W_OK|unix.X_OK, unix.AT_EACCESS) == nil
+		}
+		parent := filepath.Dir(dir)
+		if !os.IsNotExist(err) || parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
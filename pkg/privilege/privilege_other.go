@@ -0,0 +1,16 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !linux
+
+package privilege
+
+import (
+	"zeroplex/pkg/config"
+)
+
+// check asks for root: the backends of this platform change system settings only it may change
+func check(p config.Profile) []string {
+	return []string{"root (an elevated administrator on Windows), which the modes of this platform need"}
+}
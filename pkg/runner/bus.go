@@ -0,0 +1,333 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package runner
+
+import (
+	"zeroplex/pkg/bus"
+	"zeroplex/pkg/state"
+
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+// busState is the daemon's system bus connection and the last state announced on it
+type busState struct {
+	mu    sync.Mutex
+	conn  *dbus.Conn
+	props map[string]interface{} // bus.Interface properties as last announced
+	last  string
+}
+
+// busObject is the daemon object exported on the system bus for desktop helpers such as `zeroplex tray`
+type busObject struct {
+	r *Runner
+}
+
+// Status returns the current bus.Snapshot as JSON
+func (o *busObject) Status() (string, *dbus.Error) {
+	snap, err := o.r.Snapshot()
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return string(data), nil
+}
+
+// Summary returns the one-line state, e.g. "ZeroTier DNS: active (3 networks)"
+func (o *busObject) Summary() (string, *dbus.Error) {
+	snap, err := o.r.Snapshot()
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return snap.Summary(), nil
+}
+
+// Apply resumes scheduled runs if a Restore paused them and reconciles immediately
+func (o *busObject) Apply() *dbus.Error {
+	o.r.logger.Info("Apply requested over D-Bus")
+	if err := o.r.Apply(); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+// Restore reverts every managed interface and pauses scheduled runs until the next Apply
+func (o *busObject) Restore() ([]string, *dbus.Error) {
+	o.r.logger.Info("Restore requested over D-Bus; pausing scheduled runs until the next apply")
+	restored, err := o.r.RestoreAll()
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+	return restored, nil
+}
+
+// Reload re-reads the configuration and reconciles; an invalid configuration is rejected
+func (o *busObject) Reload() *dbus.Error {
+	o.r.logger.Info("Reload requested over D-Bus")
+	if err := o.r.Reload(); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+// busProperties returns the values of the bus.Interface properties for a snapshot
+func busProperties(snap bus.Snapshot) map[string]interface{} {
+	networks := []bus.NetworkProperty{}
+	for _, n := range snap.Networks {
+		servers := n.Servers
+		if servers == nil {
+			servers = []string{}
+		}
+		networks = append(networks, bus.NetworkProperty{
+			ID: n.ID, Name: n.Name, Interface: n.Interface, Status: n.Status,
+			Domain: n.Domain, Servers: servers, Managed: n.Managed,
+		})
+	}
+	interfaces := []bus.InterfaceProperty{}
+	if store, err := state.Default(); err == nil {
+		for _, entry := range store.Interfaces() {
+			interfaces = append(interfaces, bus.InterfaceProperty{
+				Name: entry.Name, NetworkID: entry.NetworkID, Mode: entry.Mode,
+				DNS: append([]string{}, entry.DNS...), Domains: append([]string{}, entry.Domains...),
+			})
+		}
+	}
+	return map[string]interface{}{
+		bus.PropertyMode:        snap.Mode,
+		bus.PropertySummary:     snap.Summary(),
+		bus.PropertyEnforcing:   snap.Enforcing,
+		bus.PropertyPaused:      snap.Paused,
+		bus.PropertyDisabled:    snap.Disabled,
+		bus.PropertyLastError:   snap.LastError,
+		bus.PropertyConfigEpoch: snap.ConfigEpoch,
+		bus.PropertyNetworks:    networks,
+		bus.PropertyInterfaces:  interfaces,
+	}
+}
+
+// busPropertiesObject serves org.freedesktop.DBus.Properties for the read-only bus.Interface properties
+type busPropertiesObject struct {
+	r *Runner
+}
+
+func (p *busPropertiesObject) Get(iface, name string) (dbus.Variant, *dbus.Error) {
+	if iface != bus.Interface {
+		return dbus.Variant{}, prop.ErrIfaceNotFound
+	}
+	p.r.bus.mu.Lock()
+	defer p.r.bus.mu.Unlock()
+	value, ok := p.r.bus.props[name]
+	if !ok {
+		return dbus.Variant{}, prop.ErrPropNotFound
+	}
+	return dbus.MakeVariant(value), nil
+}
+
+func (p *busPropertiesObject) GetAll(iface string) (map[string]dbus.Variant, *dbus.Error) {
+	if iface != bus.Interface {
+		return nil, prop.ErrIfaceNotFound
+	}
+	p.r.bus.mu.Lock()
+	defer p.r.bus.mu.Unlock()
+	all := make(map[string]dbus.Variant, len(p.r.bus.props))
+	for name, value := range p.r.bus.props {
+		all[name] = dbus.MakeVariant(value)
+	}
+	return all, nil
+}
+
+func (p *busPropertiesObject) Set(iface, name string, value dbus.Variant) *dbus.Error {
+	return prop.ErrReadOnly
+}
+
+// propertiesIntrospection describes the properties, which all announce their new value when they change
+func propertiesIntrospection(props map[string]interface{}) []introspect.Property {
+	var out []introspect.Property
+	for name, value := range props {
+		out = append(out, introspect.Property{
+			Name: name, Type: dbus.SignatureOf(value).String(), Access: "read",
+			Annotations: []introspect.Annotation{{Name: "org.freedesktop.DBus.Property.EmitsChangedSignal", Value: "true"}},
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// startBus exports the daemon object on the system bus; the returned func releases it again
+func (r *Runner) startBus() (func(), error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+	obj := &busObject{r: r}
+	if err := conn.Export(obj, bus.Path, bus.Interface); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// The ZeroTier API may not answer yet; the properties follow with the first announced state
+	snap, _ := r.Snapshot()
+	props := busProperties(snap)
+	if err := conn.Export(&busPropertiesObject{r: r}, bus.Path, "org.freedesktop.DBus.Properties"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	node := introspect.Node{
+		Name: string(bus.Path),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{Name: bus.Interface, Methods: introspect.Methods(obj), Properties: propertiesIntrospection(props), Signals: []introspect.Signal{
+				{Name: bus.SignalStateChanged, Args: []introspect.Arg{{Name: "summary", Type: "s"}, {Name: "snapshot", Type: "s"}}},
+				{Name: bus.SignalRunFinished, Args: []introspect.Arg{{Name: "trigger", Type: "s"}, {Name: "success", Type: "b"}, {Name: "error", Type: "s"}}},
+			}},
+		},
+	}
+	if err := conn.Export(introspect.NewIntrospectable(&node), bus.Path, "org.freedesktop.DBus.Introspectable"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	reply, err := conn.RequestName(bus.Name, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to request %s: %w", bus.Name, err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		conn.Close()
+		return nil, fmt.Errorf("%s is already owned by another process", bus.Name)
+	}
+	r.logger.Verbose("Exported %s on the system bus", bus.Name)
+	r.bus.mu.Lock()
+	r.bus.conn = conn
+	r.bus.props = props
+	r.bus.mu.Unlock()
+	return func() {
+		r.bus.mu.Lock()
+		r.bus.conn = nil
+		r.bus.props = nil
+		r.bus.mu.Unlock()
+		conn.ReleaseName(bus.Name)
+		conn.Close()
+	}, nil
+}
+
+// StartBus exports the daemon object outside of daemon mode; the returned func releases it again
+func (r *Runner) StartBus() (func(), error) {
+	return r.startBus()
+}
+
+// announceRun emits RunFinished for a finished run, followed by StateChanged if the state differs
+// from the last one announced
+func (r *Runner) announceRun(trigger Trigger, err error) {
+	r.bus.mu.Lock()
+	conn := r.bus.conn
+	r.bus.mu.Unlock()
+	if conn == nil {
+		return
+	}
+	message := ""
+	if err != nil {
+		message = err.Error()
+	}
+	if err := conn.Emit(bus.Path, bus.Interface+"."+bus.SignalRunFinished, string(trigger), err == nil, message); err != nil {
+		r.logger.Debug("Failed to emit %s: %v", bus.SignalRunFinished, err)
+	}
+	r.announceState()
+}
+
+// announceState updates the properties that changed, each emitting PropertiesChanged, and emits
+// StateChanged when the snapshot differs from the last one announced; run timestamps are ignored
+// so that an unchanged run doesn't count as a change
+func (r *Runner) announceState() {
+	r.bus.mu.Lock()
+	defer r.bus.mu.Unlock()
+	if r.bus.conn == nil {
+		return
+	}
+	snap, err := r.Snapshot()
+	if err != nil {
+		r.logger.Debug("Not announcing state: %v", err)
+		return
+	}
+	changed := map[string]dbus.Variant{}
+	for name, value := range busProperties(snap) {
+		if !reflect.DeepEqual(r.bus.props[name], value) {
+			r.bus.props[name] = value
+			changed[name] = dbus.MakeVariant(value)
+		}
+	}
+	if len(changed) > 0 {
+		if err := r.bus.conn.Emit(bus.Path, "org.freedesktop.DBus.Properties.PropertiesChanged", bus.Interface, changed, []string{}); err != nil {
+			r.logger.Debug("Failed to emit PropertiesChanged: %v", err)
+		}
+	}
+	key := snap
+	key.LastRunAt, key.NextRunAt, key.LastTrigger = time.Time{}, time.Time{}, ""
+	keyData, _ := json.Marshal(key)
+	if string(keyData) == r.bus.last {
+		return
+	}
+	r.bus.last = string(keyData)
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return
+	}
+	if err := r.bus.conn.Emit(bus.Path, bus.Interface+"."+bus.SignalStateChanged, snap.Summary(), string(data)); err != nil {
+		r.logger.Debug("Failed to emit %s: %v", bus.SignalStateChanged, err)
+	}
+}
+
+// Snapshot collects the daemon status and per-network DNS state returned over D-Bus
+func (r *Runner) Snapshot() (bus.Snapshot, error) {
+	status := r.Status()
+	cfg := r.config()
+	snap := bus.Snapshot{
+		Mode:           cfg.Default.Mode,
+		Enforcing:      cfg.Default.Enforcing(),
+		Paused:         status.Paused,
+		Disabled:       status.Disabled,
+		DisabledReason: status.DisabledReason,
+		LastTrigger:    string(status.LastTrigger),
+		LastRunAt:      status.LastRunAt,
+		LastError:      status.LastError,
+		NextRunAt:      status.NextRunAt,
+		ConfigEpoch:    status.ConfigEpoch,
+		Networks:       []bus.Network{},
+	}
+	networks, err := getZTNetworksDomains(r.ztClient())
+	if err != nil {
+		return snap, fmt.Errorf("failed to query ZeroTier networks: %w", err)
+	}
+	managed := map[string]bool{}
+	if store, err := state.Default(); err == nil {
+		store.Reload()
+		for _, entry := range store.Interfaces() {
+			managed[entry.Name] = true
+		}
+	}
+	for _, network := range networks {
+		snap.Networks = append(snap.Networks, bus.Network{
+			ID:        network.NetworkID,
+			Name:      network.Name,
+			Interface: network.Interface,
+			Status:    network.Status,
+			Domain:    network.Domain,
+			Servers:   network.Servers,
+			Managed:   managed[network.Interface],
+			AuthURL:   network.AuthURL,
+		})
+	}
+	return snap, nil
+}
@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package runner
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// configSettle is how long the configuration files must stay unchanged after an event before they
+// are reloaded, so an editor or a deployment writing several files causes one reload
+const configSettle = time.Second
+
+// configFingerprint hashes the configuration the daemon was started from: each file, and every
+// file of a configuration or drop-in directory and its conf.d. Reading the files rather than
+// trusting the events skips reloads for writes that changed nothing, and catches files replaced
+// through a symlink swap, as Kubernetes does with a mounted ConfigMap.
+func (r *Runner) configFingerprint() [sha256.Size]byte {
+	hash := sha256.New()
+	for _, path := range r.configPaths {
+		files := []string{path}
+		if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+			files = nil
+			for _, dir := range []string{path, filepath.Join(path, "conf.d")} {
+				entries, _ := os.ReadDir(dir)
+				for _, entry := range entries {
+					if !entry.IsDir() {
+						files = append(files, filepath.Join(dir, entry.Name()))
+					}
+				}
+			}
+		}
+		for _, file := range files {
+			hash.Write([]byte(file))
+			if content, err := os.ReadFile(file); err == nil {
+				hash.Write(content)
+			}
+			hash.Write([]byte{0})
+		}
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], hash.Sum(nil))
+	return sum
+}
+
+// watchConfig reloads the configuration whenever its files change, until stop is closed. The
+// directories holding them are watched, since editors and deployments usually replace a file
+// rather than write to it. Directories that don't exist yet when the daemon starts aren't.
+func (r *Runner) watchConfig(stop <-chan struct{}) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		r.logger.Warn("Not watching the configuration files: %v", err)
+		return
+	}
+	defer watcher.Close()
+	watched := map[string]bool{}
+	for _, path := range r.configPaths {
+		dirs := []string{filepath.Dir(path)}
+		if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+			dirs = []string{path, filepath.Join(path, "conf.d")}
+		}
+		for _, dir := range dirs {
+			if watched[dir] {
+				continue
+			}
+			if err := watcher.Add(dir); err != nil {
+				r.logger.Debug("Not watching %s: %v", dir, err)
+				continue
+			}
+			watched[dir] = true
+		}
+	}
+	if len(watched) == 0 {
+		r.logger.Warn("Not watching the configuration files: none of their directories can be watched")
+		return
+	}
+	r.logger.Verbose("Watching %v for configuration changes", r.configPaths)
+
+	last := r.configFingerprint()
+	settle := time.NewTimer(configSettle)
+	settle.Stop()
+	for {
+		select {
+		case <-stop:
+			settle.Stop()
+			return
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			r.logger.Debug("Configuration watcher error: %v", err)
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			settle.Reset(configSettle)
+		case <-settle.C:
+			current := r.configFingerprint()
+			if current == last {
+				continue
+			}
+			last = current
+			r.logger.Info("Configuration files changed, reloading")
+			// A rejected configuration is logged by Reload and the running one kept
+			_ = r.Reload()
+		}
+	}
+}
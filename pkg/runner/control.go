@@ -0,0 +1,367 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package runner
+
+import (
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/control"
+	"zeroplex/pkg/events"
+	"zeroplex/pkg/filters"
+	"zeroplex/pkg/initsys"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/metrics"
+	"zeroplex/pkg/modes"
+	"zeroplex/pkg/state"
+
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// controlActions bounds the recorded actions included in a status document
+const controlActions = 50
+
+// startControl serves the control API on the configured Unix socket; the returned func stops it
+func (r *Runner) startControl() (func(), error) {
+	settings := r.config().Default.Control
+	socket := settings.Socket
+	if socket == "" {
+		socket = control.DefaultSocket
+	}
+	acl, err := resolveControlACL(settings)
+	if err != nil {
+		return nil, err
+	}
+	// A socket passed by systemd socket activation belongs to its .socket unit, which sets its
+	// owner and mode and removes it
+	listener, activated := initsys.ActivatedListener("control", "unix", socket)
+	if activated {
+		socket = listener.Addr().String()
+		// Whoever the .socket unit lets connect
+		acl.shared = true
+	} else {
+		if err := os.MkdirAll(filepath.Dir(socket), 0755); err != nil {
+			return nil, err
+		}
+		// A socket left behind by a previous instance would make Listen fail
+		if info, err := os.Lstat(socket); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(socket)
+		}
+		if listener, err = net.Listen("unix", socket); err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", socket, err)
+		}
+		perm, err := shareControlSocket(socket, acl, settings.Mode)
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+		acl.shared = acl.owner != -1 || acl.readers != -1 || acl.admins != -1 || perm&0077 != 0
+	}
+	r.controlACL = acl
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(control.StatusPath, r.serveRead("status", r.serveStatus))
+	mux.HandleFunc(control.EventsPath, r.serveRead("events", r.serveEvents))
+	mux.HandleFunc(control.LogsPath, r.serveRead("logs", r.serveLogs))
+	mux.HandleFunc(control.MetricsPath, r.serveRead("metrics", r.serveMetrics))
+	mux.HandleFunc(control.NetworksPath, r.serveRead("networks", r.serveNetworks))
+	mux.HandleFunc(control.ApplyPath, r.serveCommand("apply", func() (control.Result, error) {
+		return control.Result{Message: "reconcile run requested"}, r.Apply()
+	}))
+	mux.HandleFunc(control.RestorePath, r.serveCommand("restore", func() (control.Result, error) {
+		restored, err := r.RestoreAll()
+		return control.Result{Message: "restored; scheduled runs paused until the next apply", Restored: restored}, err
+	}))
+	mux.HandleFunc(control.ReloadPath, r.serveCommand("reload", func() (control.Result, error) {
+		return control.Result{Message: "configuration reloaded"}, r.Reload()
+	}))
+	mux.HandleFunc(control.PausePath, r.serveCommand("pause", func() (control.Result, error) {
+		r.Pause()
+		return control.Result{Message: "scheduled runs paused"}, nil
+	}))
+	mux.HandleFunc(control.ResumePath, r.serveCommand("resume", func() (control.Result, error) {
+		r.Resume()
+		return control.Result{Message: "scheduled runs resumed"}, nil
+	}))
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second, ConnContext: withPeer}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			r.logger.Warn("Control API stopped: %v", err)
+		}
+	}()
+	if activated {
+		r.logger.Verbose("Control API listening on %s, passed by socket activation", socket)
+	} else {
+		r.logger.Verbose("Control API listening on %s", socket)
+	}
+	return func() {
+		server.Close()
+		if !activated {
+			os.Remove(socket)
+		}
+	}, nil
+}
+
+// controlACL is who may use the control API: the IDs control.user, control.group and
+// control.admin_group resolve to, or -1 for those unset
+type controlACL struct {
+	owner   int  // owns the socket, and may do everything root may
+	readers int  // may read, over the GET endpoints
+	admins  int  // may also run the commands
+	shared  bool // anyone but root and the daemon's own user may connect to the socket
+}
+
+// resolveControlACL looks up the user and groups of control
+func resolveControlACL(cfg config.ControlConfig) (controlACL, error) {
+	acl := controlACL{owner: -1, readers: -1, admins: -1}
+	if cfg.User != "" {
+		u, err := user.Lookup(cfg.User)
+		if err != nil {
+			return acl, fmt.Errorf("unknown control.user %s: %w", cfg.User, err)
+		}
+		if acl.owner, err = strconv.Atoi(u.Uid); err != nil {
+			return acl, fmt.Errorf("control.user %s has a non-numeric uid %s", cfg.User, u.Uid)
+		}
+	}
+	for _, g := range []struct {
+		key, name string
+		gid       *int
+	}{{"control.group", cfg.Group, &acl.readers}, {"control.admin_group", cfg.AdminGroup, &acl.admins}} {
+		if g.name == "" {
+			continue
+		}
+		group, err := user.LookupGroup(g.name)
+		if err != nil {
+			return acl, fmt.Errorf("unknown %s %s: %w", g.key, g.name, err)
+		}
+		if *g.gid, err = strconv.Atoi(group.Gid); err != nil {
+			return acl, fmt.Errorf("%s %s has a non-numeric gid %s", g.key, g.name, group.Gid)
+		}
+	}
+	return acl, nil
+}
+
+// shareControlSocket gives socket to the owner and the group of acl, the readers' group or else the
+// admins'. Its mode is the octal mode if set, or else 0600, 0660 with a group and 0666 when readers
+// and admins are different groups; what each connection may do is then decided by controlAccess.
+// It returns the mode it set.
+func shareControlSocket(socket string, acl controlACL, mode string) (os.FileMode, error) {
+	gid := acl.readers
+	if gid == -1 {
+		gid = acl.admins
+	}
+	perm := os.FileMode(0600)
+	switch {
+	case acl.readers != -1 && acl.admins != -1 && acl.readers != acl.admins:
+		perm = 0666
+	case gid != -1:
+		perm = 0660
+	}
+	if mode != "" {
+		m, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil || m > 0777 {
+			return 0, fmt.Errorf("invalid control.mode %s (must be octal permissions such as 0660)", mode)
+		}
+		perm = os.FileMode(m)
+	}
+	if acl.owner != -1 || gid != -1 {
+		if err := os.Chown(socket, acl.owner, gid); err != nil {
+			return 0, fmt.Errorf("failed to give %s to control.user and control.group: %w", socket, err)
+		}
+	}
+	return perm, os.Chmod(socket, perm)
+}
+
+type peerKey struct{}
+
+// withPeer records the credentials of the client of a control API connection in its context
+func withPeer(ctx context.Context, conn net.Conn) context.Context {
+	if peer, ok := control.PeerCredentials(conn); ok {
+		return context.WithValue(ctx, peerKey{}, peer)
+	}
+	return ctx
+}
+
+// controlAccess is what a client of the control API may do
+type controlAccess int
+
+const (
+	accessNone    controlAccess = iota
+	accessRead                  // the GET endpoints
+	accessCommand               // also apply, restore, reload, pause and resume
+)
+
+// controlAccess returns what the client of req may do, see controlACL.access
+func (r *Runner) controlAccess(req *http.Request) controlAccess {
+	peer, ok := req.Context().Value(peerKey{}).(control.Peer)
+	return r.controlACL.access(peer, ok)
+}
+
+// access returns what a client with the credentials peer, if they are known, may do: root, the
+// daemon's own user, control.user and the members of control.admin_group everything, the members
+// of control.group read. Unless the socket is shared, by the control settings, by control.mode or
+// by socket activation, nobody else can connect, so a client whose credentials are unknown may do
+// everything then, and nothing otherwise.
+func (a controlACL) access(peer control.Peer, known bool) controlAccess {
+	switch {
+	case !known && !a.shared:
+		return accessCommand
+	case !known:
+		return accessNone
+	case peer.UID == 0 || peer.UID == os.Geteuid() || peer.UID == a.owner || (a.admins != -1 && peer.InGroup(a.admins)):
+		return accessCommand
+	case a.readers != -1 && peer.InGroup(a.readers):
+		return accessRead
+	}
+	return accessNone
+}
+
+// peerName describes the client of req in log messages
+func peerName(req *http.Request) string {
+	peer, ok := req.Context().Value(peerKey{}).(control.Peer)
+	return describePeer(peer, ok)
+}
+
+// describePeer describes a client with the credentials peer, if they are known, in log messages
+func describePeer(peer control.Peer, known bool) string {
+	if !known {
+		return "of unknown user"
+	}
+	if u, err := user.LookupId(strconv.Itoa(peer.UID)); err == nil {
+		return fmt.Sprintf("from user %s (%d)", u.Username, peer.UID)
+	}
+	return fmt.Sprintf("from user %d", peer.UID)
+}
+
+// serveRead returns the handler of a GET endpoint, which needs read access
+func (r *Runner) serveRead(name string, serve http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if r.controlAccess(req) < accessRead {
+			r.logger.Warn("Refused %s over the control API %s, which is neither in control.group nor in control.admin_group", name, peerName(req))
+			http.Error(w, name+" needs membership of control.group or control.admin_group", http.StatusForbidden)
+			return
+		}
+		serve(w, req)
+	}
+}
+
+// StartControl serves the control API outside of daemon mode; the returned func stops it
+func (r *Runner) StartControl() (func(), error) {
+	return r.startControl()
+}
+
+// serveStatus writes the current control.Status document
+func (r *Runner) serveStatus(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(r.controlStatus())
+}
+
+// controlStatus builds the control.Status document
+func (r *Runner) controlStatus() control.Status {
+	status := control.Status{Version: r.version, DriftPending: modes.DriftPending(), Watchdogs: r.Watchdogs(), Events: events.Recent()}
+	status.Hostname, _ = os.Hostname()
+	snap, err := r.Snapshot()
+	if err != nil {
+		// Still useful without the ZeroTier API; the error shows up as the last error
+		snap.LastError = err.Error()
+	}
+	status.Snapshot = snap
+	if store, err := state.Default(); err == nil {
+		store.Reload()
+		current := store.Snapshot()
+		status.Interfaces = store.Interfaces()
+		status.Actions = current.Actions
+		if len(status.Actions) > controlActions {
+			status.Actions = status.Actions[len(status.Actions)-controlActions:]
+		}
+	}
+	return status
+}
+
+// serveNetworks writes the networks ZeroTier has joined, marking those the configured filters keep
+func (r *Runner) serveNetworks(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	resp, err := r.ztClient().Refresh(req.Context())
+	if err == nil && resp.JSON200 == nil {
+		err = fmt.Errorf("ZeroTier API returned %s", resp.Status())
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	// ApplyFilters replaces the list rather than filtering it in place
+	all := *resp.JSON200
+	filters.ApplyFilters(resp, r.config().Default)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(control.JoinedNetworks(all, *resp.JSON200))
+}
+
+// serveLogs writes the buffered log records, limited to the last tail if given
+func (r *Runner) serveLogs(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tail := 0
+	if value := req.URL.Query().Get("tail"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid tail", http.StatusBadRequest)
+			return
+		}
+		tail = n
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(log.Tail(tail))
+}
+
+// serveMetrics writes the process metrics in OpenMetrics text format
+func (r *Runner) serveMetrics(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+	metrics.Default().WriteOpenMetrics(w)
+}
+
+// serveCommand returns the handler of a command endpoint, which only accepts POST
+func (r *Runner) serveCommand(name string, run func() (control.Result, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.controlAccess(req) < accessCommand {
+			r.logger.Warn("Refused %s over the control API %s, which may only read the status", name, peerName(req))
+			http.Error(w, name+" needs root, control.user or membership of control.admin_group; members of control.group may only read the status", http.StatusForbidden)
+			return
+		}
+		r.logger.Info("%s requested over the control API", strings.ToUpper(name[:1])+name[1:])
+		result, err := run()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
@@ -0,0 +1,121 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package runner
+
+import (
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/control"
+	"zeroplex/pkg/grpcapi"
+
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestControlACLAccess(t *testing.T) {
+	// nobody stands for a user that is neither root nor the one running the tests
+	const nobody = 65534
+	acl := controlACL{owner: 1000, readers: 100, admins: 200, shared: true}
+	for _, tc := range []struct {
+		who   string
+		acl   controlACL
+		peer  control.Peer
+		known bool
+		want  controlAccess
+	}{
+		{"root", acl, control.Peer{UID: 0}, true, accessCommand},
+		{"the daemon's user", acl, control.Peer{UID: os.Geteuid()}, true, accessCommand},
+		{"control.user", acl, control.Peer{UID: 1000}, true, accessCommand},
+		{"an admin", acl, control.Peer{UID: nobody, Groups: []int{200}}, true, accessCommand},
+		{"an admin by a supplementary group", acl, control.Peer{UID: nobody, Groups: []int{nobody, 200}}, true, accessCommand},
+		{"a reader", acl, control.Peer{UID: nobody, Groups: []int{100}}, true, accessRead},
+		{"neither", acl, control.Peer{UID: nobody, Groups: []int{nobody}}, true, accessNone},
+		{"an unknown client of a shared socket", acl, control.Peer{}, false, accessNone},
+		{"an unknown client of a private socket", controlACL{owner: -1, readers: -1, admins: -1}, control.Peer{}, false, accessCommand},
+		{"a member of a group when none is set", controlACL{owner: -1, readers: -1, admins: -1, shared: true}, control.Peer{UID: nobody, Groups: []int{nobody}}, true, accessNone},
+	} {
+		if got := tc.acl.access(tc.peer, tc.known); got != tc.want {
+			t.Errorf("access of %s = %d, want %d", tc.who, got, tc.want)
+		}
+	}
+}
+
+func TestShareControlSocketModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sockets have no owner and mode on Windows")
+	}
+	// The test's own group, the only one chown accepts without root
+	gid := os.Getgid()
+	socket := filepath.Join(t.TempDir(), "control.sock")
+	if err := os.WriteFile(socket, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name string
+		acl  controlACL
+		mode string
+		want os.FileMode
+	}{
+		{"private", controlACL{owner: -1, readers: -1, admins: -1}, "", 0600},
+		{"control.group", controlACL{owner: -1, readers: gid, admins: -1}, "", 0660},
+		{"control.admin_group", controlACL{owner: -1, readers: -1, admins: gid}, "", 0660},
+		{"both groups", controlACL{owner: -1, readers: gid, admins: gid + 1}, "", 0666},
+		{"control.mode", controlACL{owner: -1, readers: gid, admins: -1}, "0640", 0640},
+	} {
+		perm, err := shareControlSocket(socket, tc.acl, tc.mode)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		info, err := os.Stat(socket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm != tc.want || info.Mode().Perm() != tc.want {
+			t.Errorf("%s: mode %v, set %v; want %v", tc.name, perm, info.Mode().Perm(), tc.want)
+		}
+	}
+	for _, mode := range []string{"0999", "1777", "rw-rw----"} {
+		if _, err := shareControlSocket(socket, controlACL{owner: -1, readers: -1, admins: -1}, mode); err == nil {
+			t.Errorf("control.mode %s accepted", mode)
+		}
+	}
+}
+
+func TestGRPCAuthorize(t *testing.T) {
+	r := New(config.Config{}, false)
+	acl := controlACL{owner: -1, readers: 100, admins: 200, shared: true}
+	reader := peerAuthInfo{peer: control.Peer{UID: 65534, Groups: []int{100}}, known: true}
+	admin := peerAuthInfo{peer: control.Peer{UID: 65534, Groups: []int{200}}, known: true}
+	for _, tc := range []struct {
+		who    string
+		auth   grpcAuth
+		info   peerAuthInfo
+		method string
+		want   codes.Code
+	}{
+		{"a reader", grpcAuth{r: r, acl: acl}, reader, grpcapi.Management_Status_FullMethodName, codes.OK},
+		{"a reader", grpcAuth{r: r, acl: acl}, reader, grpcapi.Management_WatchEvents_FullMethodName, codes.OK},
+		{"a reader", grpcAuth{r: r, acl: acl}, reader, grpcapi.Management_Restore_FullMethodName, codes.PermissionDenied},
+		{"an admin", grpcAuth{r: r, acl: acl}, admin, grpcapi.Management_Restore_FullMethodName, codes.OK},
+		{"neither", grpcAuth{r: r, acl: acl}, peerAuthInfo{peer: control.Peer{UID: 65534}, known: true}, grpcapi.Management_Status_FullMethodName, codes.PermissionDenied},
+		{"an unknown client", grpcAuth{r: r, acl: acl}, peerAuthInfo{}, grpcapi.Management_Status_FullMethodName, codes.PermissionDenied},
+		// Over TCP nobody is known, so only the calls that read are served
+		{"a TCP client", grpcAuth{r: r, acl: acl, tcp: true}, peerAuthInfo{}, grpcapi.Management_Status_FullMethodName, codes.OK},
+		{"a TCP client", grpcAuth{r: r, acl: acl, tcp: true}, peerAuthInfo{}, grpcapi.Management_Pause_FullMethodName, codes.PermissionDenied},
+		{"root on TCP", grpcAuth{r: r, acl: acl, tcp: true}, peerAuthInfo{peer: control.Peer{UID: 0}, known: true}, grpcapi.Management_Pause_FullMethodName, codes.PermissionDenied},
+	} {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: tc.info})
+		if code := status.Code(tc.auth.authorize(ctx, tc.method)); code != tc.want {
+			t.Errorf("%s by %s = %s, want %s", tc.method, tc.who, code, tc.want)
+		}
+	}
+}
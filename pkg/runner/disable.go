@@ -0,0 +1,81 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package runner
+
+import (
+	"zeroplex/pkg/events"
+	"zeroplex/pkg/metrics"
+
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DisableFile is the sentinel file that administratively disables zeroplex while it exists: runs
+// only detect and report drift, and nothing is applied, retried or restored. It is checked before
+// every run rather than watched, and being a file it survives restarts and reboots. Its first line,
+// if any, is reported as the reason.
+var DisableFile = "/etc/zeroplex.disable"
+
+// AdminDisabled reports whether DisableFile exists, and the reason written in it
+func AdminDisabled() (string, bool) {
+	f, err := os.Open(DisableFile)
+	if err != nil {
+		// A sentinel that exists but can't be read still disables
+		return "", !os.IsNotExist(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	if scanner.Scan() {
+		return strings.TrimSpace(scanner.Text()), true
+	}
+	return "", true
+}
+
+// DisabledError returns the error refusing a command while DisableFile exists, or nil
+func DisabledError() error {
+	reason, disabled := AdminDisabled()
+	if !disabled {
+		return nil
+	}
+	if reason != "" {
+		reason = " (" + reason + ")"
+	}
+	return fmt.Errorf("administratively disabled by %s%s; remove it first", DisableFile, reason)
+}
+
+// checkAdminDisabled checks DisableFile, logging, publishing and exporting each change
+func (r *Runner) checkAdminDisabled() (string, bool) {
+	reason, disabled := AdminDisabled()
+	if r.adminDisabled.Swap(disabled) != disabled {
+		if disabled {
+			logged := ""
+			if reason != "" {
+				logged = " (" + reason + ")"
+			}
+			r.logger.Warn("Administratively disabled by %s%s: drift is only reported, nothing is changed until it is removed", DisableFile, logged)
+			events.Publish(events.Event{
+				Type:    events.TypeAdminDisabled,
+				Mode:    r.config().Default.Mode,
+				Message: "administratively disabled by " + DisableFile,
+				Data:    map[string]interface{}{"reason": reason},
+			})
+		} else {
+			r.logger.Info("%s removed, changes are applied again", DisableFile)
+			events.Publish(events.Event{
+				Type:    events.TypeAdminEnabled,
+				Mode:    r.config().Default.Mode,
+				Message: DisableFile + " removed",
+			})
+		}
+	}
+	value := 0.0
+	if disabled {
+		value = 1
+	}
+	metrics.Set("zeroplex_admin_disabled", "Whether the sentinel file administratively disabling zeroplex exists", value, nil)
+	return reason, disabled
+}
@@ -0,0 +1,186 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package runner
+
+import (
+	"zeroplex/pkg/utils"
+
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Exit codes of a watchdog_exec probe: 0 is healthy and 1 unhealthy. Any other exit code, a
+// timeout or a command that can't be started means the probe itself is broken, which is reported
+// but does not reapply DNS.
+const (
+	execProbeHealthy   = 0
+	execProbeUnhealthy = 1
+)
+
+// defaultExecProbeTimeout bounds a watchdog_exec run unless watchdog_exec_timeout is set
+const defaultExecProbeTimeout = 10 * time.Second
+
+// execProbeWaitDelay is how long a probe that exited or was killed may keep its output open,
+// through a process it left in the background, before the watchdog stops waiting for it
+const execProbeWaitDelay = time.Second
+
+// execProbe is a watchdog probe running a site-provided command
+type execProbe struct {
+	command []string
+	env     []string // ZEROPLEX_* variables describing the network, for per-network probes
+	timeout time.Duration
+}
+
+// target names the probe in the watchdog status
+func (p execProbe) target() string {
+	return strings.Join(p.command, " ")
+}
+
+// run executes the probe once. broken is set when the result says nothing about DNS.
+func (p execProbe) run() (healthy, broken bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, p.command[0], p.command[1:]...)
+	cmd.Env = append(os.Environ(), p.env...)
+	killProcessGroup(cmd)
+	cmd.WaitDelay = execProbeWaitDelay
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	runErr := cmd.Run()
+	if errors.Is(runErr, exec.ErrWaitDelay) {
+		// The probe exited successfully but left a process holding its output: kill it, the exit
+		// status is what counts
+		_ = cmd.Cancel()
+		runErr = nil
+	}
+
+	output := strings.TrimSpace(out.String())
+	if first, _, _ := strings.Cut(output, "\n"); len(first) > 200 {
+		output = first[:200]
+	} else {
+		output = first
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case runErr == nil:
+		return true, false, nil
+	case ctx.Err() == context.DeadlineExceeded:
+		return false, true, fmt.Errorf("probe timed out after %s", p.timeout)
+	case errors.As(runErr, &exitErr) && exitErr.ExitCode() == execProbeUnhealthy:
+		if output == "" {
+			output = "exit status 1"
+		}
+		return false, false, errors.New(output)
+	case errors.As(runErr, &exitErr):
+		return false, true, fmt.Errorf("probe error (exit status %d): %s", exitErr.ExitCode(), output)
+	}
+	return false, true, fmt.Errorf("probe error: %w", runErr)
+}
+
+// execProbes builds the watchdog_exec probes: one per network when the command uses a
+// per-network variable, otherwise a single one
+func (r *Runner) execProbes() []execProbe {
+	features := r.config().Default.Features
+	timeout := defaultExecProbeTimeout
+	if features.WatchdogExecTimeout != "" {
+		if d, err := utils.ParseInterval(features.WatchdogExecTimeout); err == nil && d > 0 {
+			timeout = d
+		}
+	}
+	perNetwork := false
+	for _, arg := range features.WatchdogExec {
+		perNetwork = perNetwork || utils.HasNetworkVars(arg)
+	}
+	if !perNetwork {
+		command := make([]string, len(features.WatchdogExec))
+		for i, arg := range features.WatchdogExec {
+			command[i] = utils.HostVars().Expand(arg)
+		}
+		return []execProbe{{command: command, timeout: timeout}}
+	}
+
+	networks, err := getZTNetworksDomains(r.ztClient())
+	if err != nil {
+		r.logger.Warn("DNS watchdog: failed to get ZeroTier networks for watchdog_exec substitution: %v", err)
+		return nil
+	}
+	var probes []execProbe
+	for _, netinfo := range networks {
+		vars := utils.NetworkVars(netinfo.Interface, netinfo.NetworkID, netinfo.Domain)
+		probe := execProbe{timeout: timeout, env: []string{
+			"ZEROPLEX_INTERFACE=" + netinfo.Interface,
+			"ZEROPLEX_NETWORK_ID=" + netinfo.NetworkID,
+			"ZEROPLEX_DOMAIN=" + netinfo.Domain,
+			"ZEROPLEX_DNS_SERVERS=" + strings.Join(netinfo.Servers, " "),
+		}}
+		for _, arg := range features.WatchdogExec {
+			expanded, ok := vars.ExpandStrict(arg)
+			if !ok {
+				probe.command = nil
+				break
+			}
+			probe.command = append(probe.command, expanded)
+		}
+		if probe.command == nil {
+			r.logger.Debug("DNS watchdog: skipping interface %s, it has no value for a variable in watchdog_exec", netinfo.Interface)
+			continue
+		}
+		probes = append(probes, probe)
+	}
+	if len(probes) == 0 {
+		r.logger.Warn("DNS watchdog: no ZeroTier networks provide the values needed by watchdog_exec %q", strings.Join(features.WatchdogExec, " "))
+	}
+	return probes
+}
+
+// startExecWatchdogs starts a goroutine for each watchdog_exec probe
+func (r *Runner) startExecWatchdogs(interval time.Duration, backoff []time.Duration) {
+	for _, probe := range r.execProbes() {
+		r.logger.Info("DNS watchdog (exec): Command=%s, timeout=%s, interval=%s, backoff=%v", probe.target(), probe.timeout, interval, backoff)
+		go func(probe execProbe) {
+			r.supervise("DNS watchdog", nil, func() { r.watchExec(probe, interval, backoff) })
+		}(probe)
+	}
+}
+
+// watchExec runs probe every interval, triggering a poll and backoff runs whenever it reports DNS
+// as unhealthy
+func (r *Runner) watchExec(probe execProbe, interval time.Duration, backoff []time.Duration) {
+	check := func() (bool, bool, error) {
+		healthy, broken, err := probe.run()
+		r.recordWatchdog("exec", probe.target(), healthy, err)
+		return healthy, broken, err
+	}
+	for {
+		healthy, broken, err := check()
+		if healthy {
+			r.logger.Trace("DNS watchdog: %s reports DNS healthy", probe.target())
+			r.watchdogSleep(interval)
+			continue
+		}
+		if broken {
+			r.logger.Warn("DNS watchdog: %s failed to run (%v), not reapplying DNS", probe.target(), err)
+			r.watchdogSleep(interval)
+			continue
+		}
+		r.logger.Warn("DNS watchdog: %s reports DNS unhealthy (%v), triggering poll and backoff", probe.target(), err)
+		go r.retryUntilDNSOk(context.Background(), TriggerWatchdog, "watchdog-exec failure")
+		for _, bo := range backoff {
+			if healthy, _, _ := check(); healthy {
+				r.logger.Info("DNS watchdog: %s reports DNS healthy after backoff", probe.target())
+				break
+			}
+			r.logger.Warn("DNS watchdog: %s still reports DNS unhealthy, waiting %s", probe.target(), bo)
+			_ = r.executeTask(withTrigger(context.Background(), TriggerWatchdog))
+			time.Sleep(bo)
+		}
+	}
+}
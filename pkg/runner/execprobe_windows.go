@@ -0,0 +1,13 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package runner
+
+import (
+	"os/exec"
+)
+
+// killProcessGroup leaves cmd as it is: cancelling it kills the probe itself, and WaitDelay stops
+// waiting for anything it started that still holds its output
+func killProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,112 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package runner
+
+import (
+	"zeroplex/pkg/initsys"
+	"zeroplex/pkg/secrets"
+
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Features describes what a zeroplex binary can do, so support can tell from `zeroplex version
+// --json` or the startup log which backends and integrations a user's build has
+type Features struct {
+	Version      string   `json:"version"`
+	BuildTime    string   `json:"build_time,omitempty"`
+	GoVersion    string   `json:"go_version"`
+	Platform     string   `json:"platform"`
+	Modes        []string `json:"modes"`                  // modes available on this platform
+	InitSystems  []string `json:"init_systems"`           // service managers zeroplex can drive
+	Secrets      []string `json:"secret_providers"`       // schemes of secret references
+	Integrations []string `json:"integrations"`           // integrations built in
+	Enabled      []string `json:"enabled,omitempty"`      // integrations the configuration turns on
+	Experimental []string `json:"experimental,omitempty"` // experimental features the configuration turns on
+}
+
+// platformModes returns the modes validateEnvironment accepts on this platform
+func platformModes() []string {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"auto", "macos", "noop"}
+	case "windows":
+		return []string{"auto", "windows", "noop"}
+	}
+	return []string{"auto", "networkd", "resolved", "networkmanager", "resolvconf", "resolvfile", "dnsmasq", "unbound", "openwrt", "noop"}
+}
+
+// platformIntegrations returns the integrations built in on this platform
+func platformIntegrations() []string {
+	integrations := []string{"control", "metrics", "webhooks", "health", "grpc", "watchdog", "resolv_watch", "coordination"}
+	switch runtime.GOOS {
+	case "linux":
+		integrations = append(integrations, "dbus", "tray", "netlink")
+	case "windows":
+		integrations = append(integrations, "interface_notifications")
+	}
+	return integrations
+}
+
+// BuildFeatures returns the features of this binary, without regard to any configuration
+func BuildFeatures(version, buildTime string) Features {
+	f := Features{
+		Version:      version,
+		GoVersion:    runtime.Version(),
+		Platform:     runtime.GOOS + "/" + runtime.GOARCH,
+		Modes:        platformModes(),
+		InitSystems:  []string{"none"},
+		Secrets:      secrets.Schemes(),
+		Integrations: platformIntegrations(),
+	}
+	if buildTime != "unknown" {
+		f.BuildTime = buildTime
+	}
+	if runtime.GOOS == "linux" {
+		f.InitSystems = initsys.Names
+	}
+	return f
+}
+
+// Features returns the features of this binary and the integrations the configuration enables
+func (r *Runner) Features(version, buildTime string) Features {
+	f := BuildFeatures(version, buildTime)
+	d := r.config().Default
+	for _, integration := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"control", d.Control.Enabled},
+		{"metrics", d.Control.Enabled},
+		{"webhooks", len(d.Webhooks) > 0},
+		{"health", d.Health.Enabled},
+		{"grpc", d.GRPC.Enabled},
+		{"watchdog", r.watchdogConfigured()},
+		{"resolv_watch", d.ResolvWatch.Enabled},
+		{"coordination", d.Coordination.Enabled},
+		{"dbus", d.Daemon.DBus && runtime.GOOS == "linux"},
+	} {
+		if integration.enabled {
+			f.Enabled = append(f.Enabled, integration.name)
+		}
+	}
+	f.Experimental = d.ActiveExperiments()
+	return f
+}
+
+// Summary renders the features on one line, for the startup log
+func (f Features) Summary() string {
+	enabled := "none"
+	if len(f.Enabled) > 0 {
+		enabled = strings.Join(f.Enabled, ",")
+	}
+	summary := fmt.Sprintf("platform=%s modes=%s init_systems=%s secret_providers=%s integrations=%s enabled=%s",
+		f.Platform, strings.Join(f.Modes, ","), strings.Join(f.InitSystems, ","), strings.Join(f.Secrets, ","), strings.Join(f.Integrations, ","), enabled)
+	if len(f.Experimental) > 0 {
+		summary += " experimental=" + strings.Join(f.Experimental, ",")
+	}
+	return summary
+}
@@ -0,0 +1,309 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package runner
+
+import (
+	"zeroplex/pkg/control"
+	"zeroplex/pkg/events"
+	"zeroplex/pkg/grpcapi"
+
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// defaultGRPCListen is where the gRPC management API listens unless grpc.listen says otherwise
+const defaultGRPCListen = "unix:/run/zeroplex/grpc.sock"
+
+// startGRPC serves the gRPC management API on grpc.listen; the returned func stops it. Requests
+// are authorized like those of the control API, by the peer credentials of the Unix socket and
+// the control settings. A TCP client's credentials are unknown, so it may only read.
+func (r *Runner) startGRPC() (func(), error) {
+	cfg := r.config()
+	address := cfg.Default.GRPC.Listen
+	if address == "" {
+		address = defaultGRPCListen
+	}
+	settings := cfg.Default.Control
+	acl, err := resolveControlACL(settings)
+	if err != nil {
+		return nil, err
+	}
+	var listener net.Listener
+	socket, unix := strings.CutPrefix(address, "unix:")
+	if unix {
+		if err := os.MkdirAll(filepath.Dir(socket), 0755); err != nil {
+			return nil, err
+		}
+		// A socket left behind by a previous instance would make Listen fail
+		if info, err := os.Lstat(socket); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(socket)
+		}
+		l, err := net.Listen("unix", socket)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", socket, err)
+		}
+		perm, err := shareControlSocket(socket, acl, settings.Mode)
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+		acl.shared = acl.owner != -1 || acl.readers != -1 || acl.admins != -1 || perm&0077 != 0
+		listener = l
+	} else {
+		l, err := net.Listen("tcp", address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+		}
+		r.logger.Warn("The gRPC management API on %s serves only Status and WatchEvents, to anyone who can reach it; the other calls need a Unix socket", address)
+		listener = l
+	}
+
+	auth := grpcAuth{r: r, acl: acl, tcp: !unix}
+	server := grpc.NewServer(grpc.Creds(peerCredentials{}), grpc.UnaryInterceptor(auth.unary), grpc.StreamInterceptor(auth.stream))
+	grpcapi.RegisterManagementServer(server, &managementServer{r: r})
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			r.logger.Warn("gRPC management API stopped: %v", err)
+		}
+	}()
+	r.logger.Verbose("gRPC management API listening on %s", address)
+	return func() {
+		server.Stop()
+		if unix {
+			os.Remove(socket)
+		}
+	}, nil
+}
+
+// peerCredentials are the transport credentials of the gRPC API: there is no handshake, the peer
+// credentials of a Unix socket connection are only recorded for grpcAuth
+type peerCredentials struct{}
+
+// peerAuthInfo is the client of a gRPC connection, as peerCredentials recorded it
+type peerAuthInfo struct {
+	peer  control.Peer
+	known bool
+}
+
+func (peerAuthInfo) AuthType() string { return "peercred" }
+
+func (peerCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	client, known := control.PeerCredentials(conn)
+	return conn, peerAuthInfo{peer: client, known: known}, nil
+}
+
+func (peerCredentials) ClientHandshake(_ context.Context, _ string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return conn, peerAuthInfo{}, nil
+}
+
+func (peerCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "peercred"}
+}
+
+func (c peerCredentials) Clone() credentials.TransportCredentials { return c }
+
+func (peerCredentials) OverrideServerName(string) error { return nil }
+
+// grpcAuth refuses the gRPC calls the client may not make
+type grpcAuth struct {
+	r   *Runner
+	acl controlACL
+	tcp bool
+}
+
+// readMethods are the gRPC calls that only read
+var readMethods = map[string]bool{
+	grpcapi.Management_Status_FullMethodName:      true,
+	grpcapi.Management_WatchEvents_FullMethodName: true,
+}
+
+// authorize returns the error method is refused with, if the client of ctx may not call it
+func (a grpcAuth) authorize(ctx context.Context, method string) error {
+	need := accessCommand
+	if readMethods[method] {
+		need = accessRead
+	}
+	var info peerAuthInfo
+	if p, ok := peer.FromContext(ctx); ok {
+		info, _ = p.AuthInfo.(peerAuthInfo)
+	}
+	access := a.acl.access(info.peer, info.known)
+	if a.tcp {
+		access = accessRead
+	}
+	if access >= need {
+		return nil
+	}
+	name := method[strings.LastIndex(method, "/")+1:]
+	switch {
+	case a.tcp:
+		a.r.logger.Warn("Refused %s over the gRPC API on TCP, where callers are unknown", name)
+		return status.Errorf(codes.PermissionDenied, "%s is only served on a Unix socket", name)
+	case need == accessRead:
+		a.r.logger.Warn("Refused %s over the gRPC API %s, which is neither in control.group nor in control.admin_group", name, describePeer(info.peer, info.known))
+		return status.Errorf(codes.PermissionDenied, "%s needs membership of control.group or control.admin_group", name)
+	}
+	a.r.logger.Warn("Refused %s over the gRPC API %s, which may only read the status", name, describePeer(info.peer, info.known))
+	return status.Errorf(codes.PermissionDenied, "%s needs root, control.user or membership of control.admin_group; members of control.group may only read the status", name)
+}
+
+func (a grpcAuth) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := a.authorize(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a grpcAuth) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := a.authorize(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// StartGRPC serves the gRPC management API outside of daemon mode; the returned func stops it
+func (r *Runner) StartGRPC() (func(), error) {
+	return r.startGRPC()
+}
+
+// managementServer implements grpcapi.ManagementServer with the operations of the control API
+type managementServer struct {
+	grpcapi.UnimplementedManagementServer
+	r *Runner
+}
+
+func (s *managementServer) Status(ctx context.Context, _ *grpcapi.StatusRequest) (*grpcapi.StatusResponse, error) {
+	current := s.r.controlStatus()
+	resp := &grpcapi.StatusResponse{
+		Mode:        current.Mode,
+		Enforcing:   current.Enforcing,
+		Paused:      current.Paused,
+		LastTrigger: current.LastTrigger,
+		LastRunAt:   timestamp(current.LastRunAt),
+		LastError:   current.LastError,
+		NextRunAt:   timestamp(current.NextRunAt),
+		ConfigEpoch: current.ConfigEpoch,
+	}
+	for _, n := range current.Networks {
+		resp.Networks = append(resp.Networks, &grpcapi.Network{
+			Id: n.ID, Name: n.Name, Interface: n.Interface, Status: n.Status,
+			Domain: n.Domain, Servers: n.Servers, Managed: n.Managed,
+		})
+	}
+	for _, iface := range current.Interfaces {
+		resp.Interfaces = append(resp.Interfaces, &grpcapi.Interface{
+			Name: iface.Name, NetworkId: iface.NetworkID, NetworkName: iface.NetworkName, Mode: iface.Mode,
+			Dns: iface.DNS, Domains: iface.Domains, UpdatedAt: timestamp(iface.UpdatedAt),
+		})
+	}
+	return resp, nil
+}
+
+func (s *managementServer) Apply(ctx context.Context, _ *grpcapi.CommandRequest) (*grpcapi.CommandResponse, error) {
+	s.r.logger.Info("Apply requested over the gRPC API")
+	if err := s.r.Apply(); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &grpcapi.CommandResponse{Message: "reconcile run requested"}, nil
+}
+
+func (s *managementServer) Restore(ctx context.Context, _ *grpcapi.CommandRequest) (*grpcapi.CommandResponse, error) {
+	s.r.logger.Info("Restore requested over the gRPC API")
+	restored, err := s.r.RestoreAll()
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &grpcapi.CommandResponse{Message: "restored; scheduled runs paused until the next apply", Restored: restored}, nil
+}
+
+func (s *managementServer) Reload(ctx context.Context, _ *grpcapi.CommandRequest) (*grpcapi.CommandResponse, error) {
+	s.r.logger.Info("Reload requested over the gRPC API")
+	if err := s.r.Reload(); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &grpcapi.CommandResponse{Message: "configuration reloaded"}, nil
+}
+
+func (s *managementServer) Pause(ctx context.Context, _ *grpcapi.CommandRequest) (*grpcapi.CommandResponse, error) {
+	s.r.logger.Info("Pause requested over the gRPC API")
+	s.r.Pause()
+	return &grpcapi.CommandResponse{Message: "scheduled runs paused"}, nil
+}
+
+func (s *managementServer) Resume(ctx context.Context, _ *grpcapi.CommandRequest) (*grpcapi.CommandResponse, error) {
+	s.r.logger.Info("Resume requested over the gRPC API")
+	s.r.Resume()
+	return &grpcapi.CommandResponse{Message: "scheduled runs resumed"}, nil
+}
+
+func (s *managementServer) WatchEvents(req *grpcapi.WatchEventsRequest, stream grpcapi.Management_WatchEventsServer) error {
+	if req.Replay < 0 {
+		return status.Error(codes.InvalidArgument, "replay must be a non-negative number")
+	}
+	types := map[string]bool{}
+	for _, t := range req.Types {
+		types[t] = true
+	}
+	wanted := func(ev events.Event) bool { return len(types) == 0 || types[ev.Type] }
+	backlog, ch, cancel := subscribeEvents(wanted, int(req.Replay))
+	defer cancel()
+
+	for _, ev := range backlog {
+		if err := stream.Send(eventMessage(ev)); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case ev, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if !wanted(ev) {
+				continue
+			}
+			if err := stream.Send(eventMessage(ev)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// eventMessage converts a published event to its gRPC message
+func eventMessage(ev events.Event) *grpcapi.Event {
+	msg := &grpcapi.Event{
+		Type: ev.Type, Time: timestamp(ev.Time), Mode: ev.Mode, Interface: ev.Interface,
+		NetworkId: ev.NetworkID, Message: ev.Message, Labels: ev.Labels, ConfigEpoch: ev.ConfigEpoch,
+	}
+	if len(ev.Data) > 0 {
+		if data, err := json.Marshal(ev.Data); err == nil {
+			msg.DataJson = string(data)
+		}
+	}
+	return msg
+}
+
+// timestamp converts t, leaving the zero time unset
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
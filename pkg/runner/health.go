@@ -0,0 +1,133 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package runner
+
+import (
+	"zeroplex/pkg/initsys"
+
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// defaultHealthListen is where the health endpoints listen unless health.listen says otherwise
+const defaultHealthListen = "127.0.0.1:9780"
+
+// healthAPITimeout bounds the ZeroTier API query of a readiness probe
+const healthAPITimeout = 2 * time.Second
+
+// Readiness is the document /readyz answers with
+type Readiness struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"` // check -> "ok" or why it fails
+}
+
+// startHealth serves /healthz and /readyz on health.listen; the returned func stops it
+func (r *Runner) startHealth() (func(), error) {
+	address := r.config().Default.Health.Listen
+	if address == "" {
+		address = defaultHealthListen
+	}
+	listener, activated := initsys.ActivatedListener("health", "tcp", address)
+	if !activated {
+		var err error
+		if listener, err = net.Listen("tcp", address); err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", r.serveHealthz)
+	mux.HandleFunc("/readyz", r.serveReadyz)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			r.logger.Warn("Health endpoints stopped: %v", err)
+		}
+	}()
+	r.logger.Verbose("Health endpoints listening on http://%s", listener.Addr())
+	return func() {
+		server.Close()
+	}, nil
+}
+
+// StartHealth serves the health endpoints outside of daemon mode; the returned func stops it
+func (r *Runner) StartHealth() (func(), error) {
+	return r.startHealth()
+}
+
+// serveHealthz answers liveness probes: the process is up and, in daemon mode, its scheduler runs
+func (r *Runner) serveHealthz(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.daemon != nil && !r.daemon.IsRunning() {
+		http.Error(w, "daemon stopped", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// serveReadyz answers readiness probes: the ZeroTier API answers and DNS was applied successfully
+// at least once
+func (r *Runner) serveReadyz(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	readiness := r.Readiness(req.Context())
+	w.Header().Set("Content-Type", "application/json")
+	if !readiness.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(readiness)
+}
+
+// Readiness runs the readiness checks
+func (r *Runner) Readiness(ctx context.Context) Readiness {
+	readiness := Readiness{Ready: true, Checks: map[string]string{}}
+	check := func(name string, err error) {
+		if err != nil {
+			readiness.Ready = false
+			readiness.Checks[name] = err.Error()
+			return
+		}
+		readiness.Checks[name] = "ok"
+	}
+
+	if r.daemon != nil && !r.daemon.IsRunning() {
+		check("daemon", errors.New("daemon stopped"))
+	} else {
+		check("daemon", nil)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthAPITimeout)
+	defer cancel()
+	// Networks reuses a list fetched within the last second, so frequent probes cost one request
+	if networks, err := r.ztClient().Networks(ctx); err != nil {
+		check("zerotier_api", err)
+	} else if networks.JSON200 == nil {
+		check("zerotier_api", fmt.Errorf("unexpected response: %s", networks.Status()))
+	} else {
+		check("zerotier_api", nil)
+	}
+
+	if status := r.Status(); status.LastSuccess.IsZero() {
+		if status.LastError != "" {
+			check("dns_applied", fmt.Errorf("no successful run yet, last error: %s", status.LastError))
+		} else {
+			check("dns_applied", errors.New("no successful run yet"))
+		}
+	} else {
+		check("dns_applied", nil)
+	}
+	return readiness
+}
@@ -0,0 +1,45 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package runner
+
+import (
+	"zeroplex/pkg/modes"
+
+	"sync"
+	"time"
+)
+
+// holdDownRun is the run scheduled for when the first network held down by its hold_down is due
+type holdDownRun struct {
+	mu    sync.Mutex
+	timer *time.Timer
+}
+
+// scheduleHoldDownRun requests a run for when the first network the last run held down has been
+// ready for its hold_down, unless the next poll comes first
+func (r *Runner) scheduleHoldDownRun() {
+	due := modes.HoldDownDue()
+	r.holdDown.mu.Lock()
+	defer r.holdDown.mu.Unlock()
+	if r.holdDown.timer != nil {
+		r.holdDown.timer.Stop()
+		r.holdDown.timer = nil
+	}
+	if due.IsZero() {
+		return
+	}
+	if next := r.nextRun(); !next.IsZero() && !next.Before(due) {
+		return
+	}
+	r.logger.Verbose("Running again at %s, once the networks held down are due", due.Format("15:04:05"))
+	r.holdDown.timer = time.AfterFunc(time.Until(due), func() {
+		if r.daemon == nil || r.daemon.IsPaused() {
+			return
+		}
+		if err := r.RequestRun(TriggerHoldDown); err != nil {
+			r.logger.Debug("Not running for the networks held down: %v", err)
+		}
+	})
+}
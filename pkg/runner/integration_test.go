@@ -0,0 +1,2721 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build integration && linux
+
+package runner_test
+
+import (
+	"zeroplex/internal/testharness"
+	"zeroplex/pkg/bus"
+	"zeroplex/pkg/client"
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/control"
+	"zeroplex/pkg/dns"
+	"zeroplex/pkg/events"
+	"zeroplex/pkg/exitcode"
+	"zeroplex/pkg/filters"
+	"zeroplex/pkg/grpcapi"
+	"zeroplex/pkg/hardening"
+	"zeroplex/pkg/initsys"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/metrics"
+	"zeroplex/pkg/modes"
+	"zeroplex/pkg/privilege"
+	"zeroplex/pkg/runner"
+	"zeroplex/pkg/state"
+
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/vishvananda/netlink"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+func TestResolvedApplyAndRestore(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztresolv0", "10.147.17.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000001", Name: "lab", Interface: "ztresolv0",
+		Servers: []string{"10.147.17.1"}, Domain: "lab.example", Addresses: []string{"10.147.17.5/24"},
+	})
+
+	r := runner.New(h.Config("resolved"), false)
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	servers, domains := h.ResolvedLink("ztresolv0")
+	if strings.Join(servers, " ") != "10.147.17.1" {
+		t.Errorf("servers = %v, want [10.147.17.1]", servers)
+	}
+	if strings.Join(domains, " ") != "~lab.example" {
+		t.Errorf("domains = %v, want [~lab.example]", domains)
+	}
+	store, err := state.Open(h.StatePath)
+	if err != nil {
+		t.Fatalf("open state: %v", err)
+	}
+	if entry, ok := store.Snapshot().Interfaces["ztresolv0"]; !ok || entry.NetworkID != "8056c2e21c000001" || entry.Mode != "resolved" {
+		t.Errorf("state entry = %+v (recorded %v), want resolved entry for 8056c2e21c000001", entry, ok)
+	}
+
+	// Leaving the network must revert the link
+	h.API.SetNetworks()
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("second RunOnce: %v", err)
+	}
+	if servers, _ := h.ResolvedLink("ztresolv0"); len(servers) != 0 {
+		t.Errorf("servers after leave = %v, want none", servers)
+	}
+	if !h.Called("resolvectl revert") {
+		t.Errorf("expected resolvectl revert, calls: %v", h.Calls())
+	}
+	if err := store.Reload(); err != nil {
+		t.Fatalf("reload state: %v", err)
+	}
+	if _, ok := store.Snapshot().Interfaces["ztresolv0"]; ok {
+		t.Errorf("state still records ztresolv0 after leaving the network")
+	}
+}
+
+func TestResolvedDryRunMakesNoChanges(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztdry0", "10.147.18.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000002", Name: "dry", Interface: "ztdry0",
+		Servers: []string{"10.147.18.1"}, Domain: "dry.example",
+	})
+
+	if err := runner.New(h.Config("resolved"), true).RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if servers, _ := h.ResolvedLink("ztdry0"); len(servers) != 0 {
+		t.Errorf("dry run set servers %v", servers)
+	}
+}
+
+func TestNetworkdWritesAndReconciles(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztnetd0", "10.147.19.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000003", Name: "netd", Interface: "ztnetd0",
+		Servers: []string{"10.147.19.1", "10.147.19.2"}, Domain: "netd.example",
+	})
+
+	r := runner.New(h.Config("networkd"), false)
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	file := filepath.Join(h.NetworkdDir, "99-ztnetd0.network")
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("expected %s: %v", file, err)
+	}
+	for _, want := range []string{"Name=ztnetd0", "DNS=10.147.19.1", "DNS=10.147.19.2", "Domains=~netd.example"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("%s missing %q:\n%s", file, want, content)
+		}
+	}
+	if !h.Called("networkctl reload") {
+		t.Errorf("expected networkctl reload, calls: %v", h.Calls())
+	}
+	if got, want := modes.Summary(), (modes.RunSummary{Networks: 1, Filtered: 1, Applied: 1}); got != want {
+		t.Errorf("summary = %+v, want %+v", got, want)
+	}
+
+	h.API.SetNetworks()
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("second RunOnce: %v", err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be reconciled away, stat err: %v", file, err)
+	}
+	if got, want := modes.Summary(), (modes.RunSummary{Removed: 1}); got != want {
+		t.Errorf("summary after leave = %+v, want %+v", got, want)
+	}
+}
+
+func TestOneShotTimeoutAbortsWedgedRun(t *testing.T) {
+	h := testharness.New(t)
+	h.API.SetDelay(time.Minute)
+
+	cfg := h.Config("resolved")
+	cfg.Default.Timeout = "200ms"
+	started := time.Now()
+	err := runner.New(cfg, false).RunOnce()
+	if code := exitcode.Code(err); code != exitcode.Timeout {
+		t.Fatalf("RunOnce = %v (exit code %d), want exit code %d", err, code, exitcode.Timeout)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("RunOnce took %s to give up", elapsed)
+	}
+}
+
+func TestNetworkdFallsBackOnReadOnlyEtc(t *testing.T) {
+	h := testharness.New(t)
+	h.ReadOnlyNetworkd()
+	h.AddZTInterface("ztro0", "10.147.30.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000030", Name: "ro", Interface: "ztro0",
+		Servers: []string{"10.147.30.1"}, Domain: "ro.example",
+	})
+
+	cfg := h.Config("networkd")
+	if err := runner.New(cfg, false).RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	runtimeFile := filepath.Join(h.RuntimeDir, "99-ztro0.network")
+	content, err := os.ReadFile(runtimeFile)
+	if err != nil || !strings.Contains(string(content), "DNS=10.147.30.1") {
+		t.Fatalf("expected %s with the network's DNS, got %q (%v)", runtimeFile, content, err)
+	}
+	if _, err := os.Stat(filepath.Join(h.NetworkdDir, "99-ztro0.network")); !os.IsNotExist(err) {
+		t.Errorf("wrote to the read-only directory: %v", err)
+	}
+	intent := filepath.Join(filepath.Dir(h.StatePath), "networkd-runtime.json")
+	if data, err := os.ReadFile(intent); err != nil || !strings.Contains(string(data), "99-ztro0.network") {
+		t.Fatalf("expected %s to keep the file, got %q (%v)", intent, data, err)
+	}
+
+	// A reboot empties /run; the file comes back before ZeroTier answers
+	if err := os.Remove(runtimeFile); err != nil {
+		t.Fatal(err)
+	}
+	offline := cfg
+	offline.Default.Client.Host = "http://127.0.0.1"
+	offline.Default.Client.Port = 1
+	_ = runner.New(offline, false).RunOnce()
+	if restored, err := os.ReadFile(runtimeFile); err != nil || string(restored) != string(content) {
+		t.Fatalf("expected %s re-created from the state directory, got %q (%v)", runtimeFile, restored, err)
+	}
+
+	if restored, _ := runner.New(cfg, false).RestoreAll(); len(restored) != 1 || restored[0] != "ztro0" {
+		t.Errorf("RestoreAll = %v, want [ztro0]", restored)
+	}
+	if _, err := os.Stat(runtimeFile); !os.IsNotExist(err) {
+		t.Errorf("restore left %s: %v", runtimeFile, err)
+	}
+	if data, err := os.ReadFile(intent); err != nil || strings.Contains(string(data), "99-ztro0.network") {
+		t.Errorf("restore left the file in %s: %q (%v)", intent, data, err)
+	}
+}
+
+func TestRestoreAllWaitsForTheRunInProgress(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztwait0", "10.147.48.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000048", Name: "wait", Interface: "ztwait0",
+		Servers: []string{"10.147.48.1"}, Domain: "wait.example",
+	})
+	// The run is still waiting for ZeroTier when the restore is asked for
+	h.API.SetDelay(500 * time.Millisecond)
+
+	r := runner.New(h.Config("networkd"), false)
+	done := make(chan error, 1)
+	go func() { done <- r.RunOnce() }()
+	time.Sleep(100 * time.Millisecond)
+	restored, err := r.RestoreAll()
+	if err != nil {
+		t.Fatalf("RestoreAll: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if len(restored) != 1 || restored[0] != "ztwait0" {
+		t.Errorf("RestoreAll = %v, want [ztwait0] applied by the run it waited for", restored)
+	}
+	if _, err := os.Stat(filepath.Join(h.RuntimeDir, "99-ztwait0.network")); !os.IsNotExist(err) {
+		t.Errorf("the run reapplied ztwait0 after the restore: %v", err)
+	}
+}
+
+func TestDnsmasqWritesSnippetsAndRestarts(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztdm0", "10.147.31.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000031", Name: "dm", Interface: "ztdm0",
+		Servers: []string{"10.147.31.1", "10.147.31.2"}, Domain: "dm.example",
+	})
+
+	cfg := h.Config("dnsmasq")
+	cfg.Default.Dnsmasq.ConfigDir = t.TempDir()
+	r := runner.New(cfg, false)
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	file := filepath.Join(cfg.Default.Dnsmasq.ConfigDir, "zeroplex-ztdm0.conf")
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("expected %s: %v", file, err)
+	}
+	for _, want := range []string{"server=/dm.example/10.147.31.1\n", "server=/dm.example/10.147.31.2\n"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("%s missing %q:\n%s", file, want, content)
+		}
+	}
+	if !h.Called("systemctl try-restart dnsmasq.service") {
+		t.Errorf("expected dnsmasq to be restarted, calls: %v", h.Calls())
+	}
+
+	// Nothing changed, so dnsmasq keeps running
+	before := len(h.Calls())
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("second RunOnce: %v", err)
+	}
+	for _, call := range h.Calls()[before:] {
+		if strings.Contains(call, "dnsmasq") {
+			t.Errorf("unchanged run touched dnsmasq: %s", call)
+		}
+	}
+
+	h.API.SetNetworks()
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce after leave: %v", err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed, stat err: %v", file, err)
+	}
+	if got, want := modes.Summary(), (modes.RunSummary{Removed: 1}); got != want {
+		t.Errorf("summary after leave = %+v, want %+v", got, want)
+	}
+}
+
+func TestUnboundIncludeFileReconciles(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztub0", "10.147.39.5/24")
+	h.AddZTInterface("ztub1", "10.147.40.5/24")
+	h.API.SetNetworks(
+		testharness.Network{ID: "8056c2e21c000039", Name: "ub0", Interface: "ztub0", Servers: []string{"10.147.39.1"}, Domain: "ub0.example"},
+		testharness.Network{ID: "8056c2e21c000040", Name: "ub1", Interface: "ztub1", Servers: []string{"10.147.40.1"}, Domain: "ub1.example"},
+	)
+
+	cfg := h.Config("unbound")
+	cfg.Default.Unbound.IncludeFile = filepath.Join(t.TempDir(), "zeroplex.conf")
+	r := runner.New(cfg, false)
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	content, err := os.ReadFile(cfg.Default.Unbound.IncludeFile)
+	if err != nil {
+		t.Fatalf("expected include file: %v", err)
+	}
+	for _, want := range []string{"name: \"ub0.example.\"\n    forward-addr: 10.147.39.1\n", "domain-insecure: \"ub1.example.\"\n"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("include file missing %q:\n%s", want, content)
+		}
+	}
+	if !h.Called("systemctl reload unbound.service") {
+		t.Errorf("expected unbound to be reloaded, calls: %v", h.Calls())
+	}
+
+	h.API.SetNetworks(testharness.Network{ID: "8056c2e21c000039", Name: "ub0", Interface: "ztub0", Servers: []string{"10.147.39.1"}, Domain: "ub0.example"})
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce after leave: %v", err)
+	}
+	content, _ = os.ReadFile(cfg.Default.Unbound.IncludeFile)
+	if strings.Contains(string(content), "ub1.example") || !strings.Contains(string(content), "ub0.example") {
+		t.Errorf("include file after leaving ub1:\n%s", content)
+	}
+	if got := modes.Summary(); got.Unchanged != 1 || got.Removed != 1 {
+		t.Errorf("summary after leave = %+v, want 1 unchanged and 1 removed", got)
+	}
+}
+
+func TestUnboundControlAddsAndRemovesZones(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztuc0", "10.147.41.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000041", Name: "uc", Interface: "ztuc0",
+		Servers: []string{"10.147.41.1", "10.147.41.2"}, Domain: "uc.example",
+	})
+
+	cfg := h.Config("unbound")
+	cfg.Default.Unbound.Control = true
+	r := runner.New(cfg, false)
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if got := h.UnboundForward("uc.example."); got != "10.147.41.1 10.147.41.2" {
+		t.Errorf("forward zone servers = %q, want 10.147.41.1 10.147.41.2", got)
+	}
+
+	before := len(h.Calls())
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("second RunOnce: %v", err)
+	}
+	for _, call := range h.Calls()[before:] {
+		if strings.Contains(call, "forward_add") {
+			t.Errorf("unchanged run re-added the zone: %s", call)
+		}
+	}
+
+	h.API.SetNetworks()
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce after leave: %v", err)
+	}
+	if got := h.UnboundForward("uc.example."); got != "" {
+		t.Errorf("forward zone still present after leave: %q", got)
+	}
+}
+
+func TestOpenWrtManagesUciServers(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztowrt0", "10.147.43.5/24")
+	network := testharness.Network{
+		ID: "8056c2e21c000043", Name: "owrt", Interface: "ztowrt0",
+		Servers: []string{"10.147.43.1"}, Domain: "owrt.example",
+	}
+	h.API.SetNetworks(network)
+	// An upstream configured by hand, which zeroplex must leave alone
+	if err := os.WriteFile(filepath.Join(h.StateDir, "uci.dhcp.@dnsmasq[0].server"), []byte("9.9.9.9\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := runner.New(h.Config("openwrt"), false)
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if got := strings.Join(h.UciList("dhcp.@dnsmasq[0].server"), " "); got != "9.9.9.9 /owrt.example/10.147.43.1" {
+		t.Errorf("server list = %q", got)
+	}
+	if got := strings.Join(h.UciList("dhcp.@dnsmasq[0].rebind_domain"), " "); got != "owrt.example" {
+		t.Errorf("rebind_domain list = %q, want owrt.example", got)
+	}
+	if !h.Called("uci commit dhcp") || !h.Called("ubus call service event") {
+		t.Errorf("changes were not committed and reloaded: %v", h.Calls())
+	}
+
+	network.Servers = []string{"10.147.43.2"}
+	h.API.SetNetworks(network)
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("second RunOnce: %v", err)
+	}
+	if got := strings.Join(h.UciList("dhcp.@dnsmasq[0].server"), " "); got != "9.9.9.9 /owrt.example/10.147.43.2" {
+		t.Errorf("server list after change = %q", got)
+	}
+
+	h.API.SetNetworks()
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce after leave: %v", err)
+	}
+	if got := strings.Join(h.UciList("dhcp.@dnsmasq[0].server"), " "); got != "9.9.9.9" {
+		t.Errorf("server list after leave = %q, want only the manual upstream", got)
+	}
+	if got := h.UciList("dhcp.@dnsmasq[0].rebind_domain"); len(got) != 0 {
+		t.Errorf("rebind_domain list after leave = %v, want empty", got)
+	}
+}
+
+func TestResolvWatchReassertsAfterRewrite(t *testing.T) {
+	h := testharness.New(t)
+	if err := os.WriteFile(h.ResolvConf, []byte("nameserver 192.0.2.53\n"), 0644); err != nil {
+		t.Fatalf("write resolv.conf: %v", err)
+	}
+	h.AddZTInterface("ztrw0", "10.147.44.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000044", Name: "rw", Interface: "ztrw0",
+		Servers: []string{"10.147.44.1"}, Domain: "rw.example",
+	})
+
+	cfg := h.Config("resolvfile")
+	cfg.Default.ResolvWatch.Enabled = true
+	cfg.Default.ResolvWatch.Paths = []string{h.ResolvConf}
+	r := runner.New(cfg, false)
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if changed := r.CheckResolvConf(); len(changed) != 0 {
+		t.Errorf("zeroplex's own write was reported as a rewrite: %v", changed)
+	}
+
+	// A VPN client replaces the file
+	if err := os.WriteFile(h.ResolvConf, []byte("nameserver 198.51.100.1\n"), 0644); err != nil {
+		t.Fatalf("rewrite resolv.conf: %v", err)
+	}
+	ch, cancel := events.Subscribe(16)
+	defer cancel()
+	if changed := r.CheckResolvConf(); len(changed) != 1 || changed[0] != h.ResolvConf {
+		t.Fatalf("CheckResolvConf = %v, want %s", changed, h.ResolvConf)
+	}
+	if content, _ := os.ReadFile(h.ResolvConf); !strings.Contains(string(content), "nameserver 10.147.44.1") {
+		t.Errorf("managed block was not re-asserted:\n%s", content)
+	}
+	drift := false
+	for len(ch) > 0 {
+		if ev := <-ch; ev.Type == events.TypeDrift && ev.Data["kind"] == "resolv_conf" {
+			drift = true
+		}
+	}
+	if !drift {
+		t.Error("no resolv_conf drift event was published")
+	}
+	if changed := r.CheckResolvConf(); len(changed) != 0 {
+		t.Errorf("re-asserting run was reported as a rewrite: %v", changed)
+	}
+}
+
+func TestCoordinationYieldsAndOverrides(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztco0", "10.147.45.5/24")
+	h.AddResolvedLink("tailscale0", "corp.example", "~ts.net")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000045", Name: "co", Interface: "ztco0",
+		Servers: []string{"10.147.45.1"}, Domain: "corp.example",
+	})
+
+	cfg := h.Config("resolved")
+	cfg.Default.Coordination.Domains = map[string]string{"corp.example": "yield"}
+	ch, cancel := events.Subscribe(16)
+	defer cancel()
+	if err := runner.New(cfg, false).RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if _, domains := h.ResolvedLink("ztco0"); len(domains) != 0 {
+		t.Errorf("yielded domain still configured on ztco0: %v", domains)
+	}
+	conflict := false
+	for len(ch) > 0 {
+		if ev := <-ch; ev.Type == events.TypeConflict && ev.Data["manager"] == "tailscaled" && ev.Data["domain"] == "corp.example" {
+			conflict = true
+		}
+	}
+	if !conflict {
+		t.Error("no dns_conflict event for the domain tailscaled routes")
+	}
+
+	cfg.Default.Coordination.Domains = map[string]string{"corp.example": "override"}
+	if err := runner.New(cfg, false).RunOnce(); err != nil {
+		t.Fatalf("RunOnce with override: %v", err)
+	}
+	if _, domains := h.ResolvedLink("ztco0"); strings.Join(domains, " ") != "~corp.example" {
+		t.Errorf("ztco0 domains = %v, want ~corp.example", domains)
+	}
+	if _, domains := h.ResolvedLink("tailscale0"); strings.Join(domains, " ") != "~ts.net" {
+		t.Errorf("tailscale0 domains = %v, want only ~ts.net left", domains)
+	}
+}
+
+func TestApplyRecordsDelta(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztdelta0", "10.147.42.5/24")
+	network := testharness.Network{
+		ID: "8056c2e21c000042", Name: "delta", Interface: "ztdelta0",
+		Servers: []string{"10.147.42.1", "10.147.42.2"}, Domain: "delta.example",
+	}
+	h.API.SetNetworks(network)
+
+	r := runner.New(h.Config("resolvconf"), false)
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+
+	network.Servers = []string{"10.147.42.2", "10.147.42.3"}
+	h.API.SetNetworks(network)
+	ch, cancel := events.Subscribe(8)
+	defer cancel()
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("second RunOnce: %v", err)
+	}
+	var apply *events.Event
+	for len(ch) > 0 {
+		if ev := <-ch; ev.Type == events.TypeApply && ev.Interface == "ztdelta0" {
+			apply = &ev
+		}
+	}
+	if apply == nil {
+		t.Fatalf("no apply event for ztdelta0")
+	}
+	if got := fmt.Sprint(apply.Data["dns_added"], apply.Data["dns_removed"]); got != "[10.147.42.3] [10.147.42.1]" {
+		t.Errorf("apply event delta = %s, want [10.147.42.3] [10.147.42.1]", got)
+	}
+	if _, ok := apply.Data["domains_added"]; ok {
+		t.Errorf("apply event reports unchanged domains: %v", apply.Data)
+	}
+
+	store, err := state.Open(h.StatePath)
+	if err != nil {
+		t.Fatalf("open state: %v", err)
+	}
+	actions := store.Snapshot().Actions
+	if len(actions) == 0 {
+		t.Fatalf("no actions recorded")
+	}
+	last := actions[len(actions)-1]
+	if last.Operation != "apply" || last.Details["dns_added"] != "10.147.42.3" || last.Details["dns_removed"] != "10.147.42.1" {
+		t.Errorf("last action = %+v, want apply adding 10.147.42.3 and removing 10.147.42.1", last)
+	}
+}
+
+func TestMacOSWritesResolverFiles(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztmac0", "10.147.43.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000043", Name: "mac", Interface: "ztmac0",
+		Servers: []string{"10.147.43.1", "10.147.43.2"}, Domain: "mac.example",
+	})
+	unrelated := filepath.Join(h.ResolverDir, "corp.example")
+	if err := os.MkdirAll(h.ResolverDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(unrelated, []byte("nameserver 192.0.2.53\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := runner.New(h.Config("macos"), false)
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	file := filepath.Join(h.ResolverDir, "mac.example")
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("expected %s: %v", file, err)
+	}
+	if !strings.Contains(string(content), "nameserver 10.147.43.1\nnameserver 10.147.43.2\n") {
+		t.Errorf("%s:\n%s", file, content)
+	}
+
+	h.API.SetNetworks()
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce after leave: %v", err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed, stat err: %v", file, err)
+	}
+	if _, err := os.Stat(unrelated); err != nil {
+		t.Errorf("resolver file not written by zeroplex was touched: %v", err)
+	}
+}
+
+func TestDetectModeWithoutSystemd(t *testing.T) {
+	h := testharness.New(t)
+	if err := initsys.Use("openrc"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = initsys.Use("auto") })
+
+	r := runner.New(h.Config("auto"), false)
+	if mode, ok := r.DetectMode(); !ok || mode != "resolvconf" {
+		t.Errorf("with resolvconf installed: detected %q (%t), want resolvconf", mode, ok)
+	}
+	if err := os.Remove(filepath.Join(h.Dir, "bin", "resolvconf")); err != nil {
+		t.Fatal(err)
+	}
+	if mode, ok := r.DetectMode(); !ok || mode != "resolvfile" {
+		t.Errorf("without resolvconf: detected %q (%t), want resolvfile", mode, ok)
+	}
+	if h.Called("systemctl") {
+		t.Errorf("systemctl was run under OpenRC: %v", h.Calls())
+	}
+}
+
+func TestNetworkManagerAppliesAndReapplies(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztnm0", "10.147.27.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000027", Name: "nm", Interface: "ztnm0",
+		Servers: []string{"10.147.27.1", "fd00::1"}, Domain: "nm.example",
+	})
+
+	r := runner.New(h.Config("networkmanager"), false)
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if got := h.NetworkManagerDevice("ztnm0", "ipv4.dns"); got != "10.147.27.1" {
+		t.Errorf("ipv4.dns = %q, want 10.147.27.1", got)
+	}
+	if got := h.NetworkManagerDevice("ztnm0", "ipv6.dns"); got != "fd00::1" {
+		t.Errorf("ipv6.dns = %q, want fd00::1", got)
+	}
+	if got := h.NetworkManagerDevice("ztnm0", "ipv4.dns-search"); !strings.Contains(got, "nm.example") {
+		t.Errorf("ipv4.dns-search = %q, want nm.example", got)
+	}
+	store, err := state.Open(h.StatePath)
+	if err != nil {
+		t.Fatalf("open state: %v", err)
+	}
+	if entry, ok := store.Snapshot().Interfaces["ztnm0"]; !ok || entry.Mode != "networkmanager" {
+		t.Errorf("state entry for ztnm0 = %+v (present %t)", entry, ok)
+	}
+
+	// A second run finds the settings in place and leaves the device alone
+	before := len(h.Calls())
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("second RunOnce: %v", err)
+	}
+	for _, call := range h.Calls()[before:] {
+		if strings.HasPrefix(call, "nmcli device modify") {
+			t.Errorf("unchanged settings were modified again: %s", call)
+		}
+	}
+
+	h.API.SetNetworks()
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce after leave: %v", err)
+	}
+	if !h.Called("nmcli device reapply ztnm0") {
+		t.Errorf("expected nmcli device reapply, calls: %v", h.Calls())
+	}
+	if got := h.NetworkManagerDevice("ztnm0", "ipv4.dns"); got != "" {
+		t.Errorf("ipv4.dns after leave = %q, want none", got)
+	}
+}
+
+func TestResolvconfAddsAndDeletes(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztrc0", "10.147.28.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000028", Name: "rc", Interface: "ztrc0",
+		Servers: []string{"10.147.28.1", "fd00::1"}, Domain: "rc.example",
+	})
+
+	r := runner.New(h.Config("resolvconf"), false)
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	entry := h.Resolvconf("ztrc0")
+	for _, line := range []string{"nameserver 10.147.28.1\n", "nameserver fd00::1\n", "search rc.example\n"} {
+		if !strings.Contains(entry, line) {
+			t.Errorf("resolvconf entry of ztrc0 lacks %q:\n%s", line, entry)
+		}
+	}
+	store, err := state.Open(h.StatePath)
+	if err != nil {
+		t.Fatalf("open state: %v", err)
+	}
+	if got, ok := store.Snapshot().Interfaces["ztrc0"]; !ok || got.Mode != "resolvconf" {
+		t.Errorf("state entry for ztrc0 = %+v (present %t)", got, ok)
+	}
+
+	// A second run reads the entry back and leaves it alone
+	before := len(h.Calls())
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("second RunOnce: %v", err)
+	}
+	for _, call := range h.Calls()[before:] {
+		if strings.HasPrefix(call, "resolvconf -a") {
+			t.Errorf("unchanged entry was added again: %s", call)
+		}
+	}
+
+	h.API.SetNetworks()
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce after leave: %v", err)
+	}
+	if !h.Called("resolvconf -d ztrc0") {
+		t.Errorf("expected resolvconf -d, calls: %v", h.Calls())
+	}
+	if got := h.Resolvconf("ztrc0"); got != "" {
+		t.Errorf("resolvconf entry after leave = %q, want none", got)
+	}
+}
+
+func TestResolvFileManagesBlock(t *testing.T) {
+	h := testharness.New(t)
+	original := "# written by dhclient\nnameserver 192.0.2.53\nsearch lan.example\n"
+	if err := os.WriteFile(h.ResolvConf, []byte(original), 0644); err != nil {
+		t.Fatalf("write resolv.conf: %v", err)
+	}
+	h.AddZTInterface("ztrf0", "10.147.29.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000029", Name: "rf", Interface: "ztrf0",
+		Servers: []string{"10.147.29.1"}, Domain: "rf.example",
+	})
+
+	r := runner.New(h.Config("resolvfile"), false)
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	content, err := os.ReadFile(h.ResolvConf)
+	if err != nil {
+		t.Fatalf("read resolv.conf: %v", err)
+	}
+	for _, line := range []string{
+		"nameserver 10.147.29.1\nsearch rf.example lan.example\n# END zeroplex managed block\n",
+		"nameserver 192.0.2.53\n# zeroplex: search lan.example\n",
+	} {
+		if !strings.Contains(string(content), line) {
+			t.Errorf("resolv.conf lacks %q:\n%s", line, content)
+		}
+	}
+	backup := filepath.Join(filepath.Dir(h.StatePath), "resolv.conf.backup")
+	if saved, err := os.ReadFile(backup); err != nil || string(saved) != original {
+		t.Errorf("backup = %q (%v), want the original file", saved, err)
+	}
+
+	// A second run finds the block current and leaves the file alone
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("second RunOnce: %v", err)
+	}
+	if again, _ := os.ReadFile(h.ResolvConf); string(again) != string(content) {
+		t.Errorf("second run changed resolv.conf:\n%s", again)
+	}
+	if got, want := modes.Summary(), (modes.RunSummary{Networks: 1, Filtered: 1, Unchanged: 1}); got != want {
+		t.Errorf("summary of unchanged run = %+v, want %+v", got, want)
+	}
+
+	h.API.SetNetworks()
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce after leave: %v", err)
+	}
+	if restored, _ := os.ReadFile(h.ResolvConf); string(restored) != original {
+		t.Errorf("resolv.conf after leave = %q, want %q", restored, original)
+	}
+	if _, err := os.Stat(backup); !os.IsNotExist(err) {
+		t.Errorf("backup still present after leave, stat err: %v", err)
+	}
+}
+
+func TestRenamedInterfaceKeepsState(t *testing.T) {
+	h := testharness.New(t)
+	link := h.AddZTInterface("ztren0", "10.147.26.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000026", Name: "ren", Interface: "ztren0",
+		Servers: []string{"10.147.26.1"}, Domain: "ren.example",
+	})
+
+	r := runner.New(h.Config("networkd"), false)
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+
+	// Rename the device the way udev does, keeping the old name as an altname; ZeroTier still
+	// reports the name it created
+	if err := netlink.LinkSetDown(link); err != nil {
+		t.Fatalf("set ztren0 down: %v", err)
+	}
+	if err := netlink.LinkSetName(link, "ztren1"); err != nil {
+		t.Fatalf("rename ztren0: %v", err)
+	}
+	if err := netlink.LinkAddAltName(link, "ztren0"); err != nil {
+		t.Skipf("cannot add altname: %v", err)
+	}
+	if err := netlink.LinkSetUp(link); err != nil {
+		t.Fatalf("set ztren1 up: %v", err)
+	}
+
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("second RunOnce: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(h.NetworkdDir, "99-ztren0.network")); !os.IsNotExist(err) {
+		t.Errorf("expected 99-ztren0.network to be removed, stat err: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(h.NetworkdDir, "99-ztren1.network"))
+	if err != nil {
+		t.Fatalf("expected 99-ztren1.network: %v", err)
+	}
+	if !strings.Contains(string(content), "Name=ztren1") {
+		t.Errorf("99-ztren1.network does not match the new name:\n%s", content)
+	}
+	store, err := state.Open(h.StatePath)
+	if err != nil {
+		t.Fatalf("open state: %v", err)
+	}
+	snapshot := store.Snapshot()
+	interfaces := snapshot.Interfaces
+	if _, ok := interfaces["ztren0"]; ok {
+		t.Errorf("state still records the old name ztren0")
+	}
+	if entry, ok := interfaces["ztren1"]; !ok || entry.NetworkID != "8056c2e21c000026" {
+		t.Errorf("state entry for ztren1 = %+v (recorded %v), want network 8056c2e21c000026", entry, ok)
+	}
+	renamed := false
+	for _, action := range snapshot.Actions {
+		if action.Operation == "rename" && action.Interface == "ztren1" && action.Details["from"] == "ztren0" {
+			renamed = true
+		}
+	}
+	if !renamed {
+		t.Errorf("no rename action recorded, actions: %+v", snapshot.Actions)
+	}
+}
+
+func TestFiltersExcludeNetworks(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztkeep0", "10.147.20.5/24")
+	h.AddZTInterface("ztskip0", "10.147.21.5/24")
+	h.API.SetNetworks(
+		testharness.Network{ID: "8056c2e21c000004", Name: "keep", Interface: "ztkeep0", Servers: []string{"10.147.20.1"}, Domain: "keep.example"},
+		testharness.Network{ID: "8056c2e21c000005", Name: "skip", Interface: "ztskip0", Servers: []string{"10.147.21.1"}, Domain: "skip.example"},
+	)
+
+	cfg := h.Config("resolved")
+	cfg.Default.Filters = []map[string]interface{}{{"type": "name", "value": "keep"}}
+	if err := runner.New(cfg, false).RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if servers, _ := h.ResolvedLink("ztkeep0"); len(servers) == 0 {
+		t.Errorf("filtered-in network was not applied")
+	}
+	if servers, _ := h.ResolvedLink("ztskip0"); len(servers) != 0 {
+		t.Errorf("filtered-out network was applied: %v", servers)
+	}
+}
+
+func TestNetworkOverridesServersAndMode(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztov0", "10.147.31.5/24")
+	h.AddZTInterface("ztov1", "10.147.32.5/24")
+	h.API.SetNetworks(
+		testharness.Network{ID: "8056c2e21c000031", Name: "ov0", Interface: "ztov0", Servers: []string{"10.147.31.1"}, Domain: "ov0.example"},
+		testharness.Network{ID: "8056c2e21c000032", Name: "ov1", Interface: "ztov1", Servers: []string{"10.147.32.1"}, Domain: "ov1.example"},
+	)
+	cfg := h.Config("resolvfile")
+	cfg.Default.Networks = map[string]config.NetworkOverride{
+		"8056C2E21C000031": {DNSServers: []string{"10.147.31.53"}, ExtraSearchDomains: []string{"svc.%domain%"}},
+		"8056c2e21c000032": {Mode: "resolvconf"},
+	}
+
+	if err := runner.New(cfg, false).RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	content, _ := os.ReadFile(h.ResolvConf)
+	if !strings.Contains(string(content), "nameserver 10.147.31.53\nsearch ov0.example svc.ov0.example\n") {
+		t.Errorf("resolv.conf lacks the overridden servers and search domains of ov0:\n%s", content)
+	}
+	if strings.Contains(string(content), "10.147.31.1") || strings.Contains(string(content), "ov1.example") {
+		t.Errorf("resolv.conf has the pushed server of ov0 or the network handed to resolvconf:\n%s", content)
+	}
+	if entry := h.Resolvconf("ztov1"); !strings.Contains(entry, "nameserver 10.147.32.1\n") {
+		t.Errorf("resolvconf entry of ztov1 = %q, want its servers", entry)
+	}
+	if entry := h.Resolvconf("ztov0"); entry != "" {
+		t.Errorf("resolvconf entry of ztov0 = %q, want none", entry)
+	}
+
+	restored := modes.RestoreManaged(cfg, false)
+	if !slices.Contains(restored, "ztov1") || h.Resolvconf("ztov1") != "" {
+		t.Errorf("RestoreManaged = %v, resolvconf entry of ztov1 %q; want it restored", restored, h.Resolvconf("ztov1"))
+	}
+
+	// resolved, the default mode, honours the per-network DNS-over-TLS of the overridden network only
+	dot0 := h.AddZTInterface("ztov2", "10.147.46.5/24")
+	dot1 := h.AddZTInterface("ztov3", "10.147.47.5/24")
+	h.API.SetNetworks(
+		testharness.Network{ID: "8056c2e21c000046", Name: "ov2", Interface: "ztov2", Servers: []string{"10.147.46.1"}, Domain: "ov2.example"},
+		testharness.Network{ID: "8056c2e21c000047", Name: "ov3", Interface: "ztov3", Servers: []string{"10.147.47.1"}, Domain: "ov3.example"},
+	)
+	enabled := true
+	cfg = h.Config("resolved")
+	cfg.Default.Networks = map[string]config.NetworkOverride{"8056c2e21c000046": {DNSOverTLS: &enabled}}
+	if err := runner.New(cfg, false).RunOnce(); err != nil {
+		t.Fatalf("RunOnce in resolved mode: %v", err)
+	}
+	if !h.Called(fmt.Sprintf("resolvectl dnsovertls %d yes", dot0.Attrs().Index)) {
+		t.Errorf("DNS-over-TLS not enabled on ztov2 by its override, calls: %v", h.Calls())
+	}
+	if h.Called(fmt.Sprintf("resolvectl dnsovertls %d yes", dot1.Attrs().Index)) {
+		t.Errorf("DNS-over-TLS enabled on ztov3, which has no override, calls: %v", h.Calls())
+	}
+	modes.RestoreManaged(cfg, false)
+}
+
+func TestTokenFromSystemdCredential(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztcred0", "10.147.35.5/24")
+	h.API.SetNetworks(testharness.Network{ID: "8056c2e21c000035", Name: "cred", Interface: "ztcred0", Servers: []string{"10.147.35.1"}, Domain: "cred.example"})
+	cfg := h.Config("resolvfile")
+	// Like a sandboxed unit, which can't read /var/lib/zerotier-one
+	cfg.Default.Client.TokenFile = filepath.Join(h.Dir, "unreadable")
+	credentials := t.TempDir()
+	if err := os.WriteFile(filepath.Join(credentials, client.TokenCredential), []byte(testharness.Token+"\n"), 0400); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CREDENTIALS_DIRECTORY", credentials)
+
+	if err := runner.New(cfg, false).RunOnce(); err != nil {
+		t.Fatalf("RunOnce with the token in a credential: %v", err)
+	}
+	if content, _ := os.ReadFile(h.ResolvConf); !strings.Contains(string(content), "nameserver 10.147.35.1") {
+		t.Errorf("resolv.conf not applied with the token in a credential:\n%s", content)
+	}
+}
+
+func TestHoldDownDelaysFlappingNetwork(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("zthold0", "10.147.36.5/24")
+	network := testharness.Network{ID: "8056c2e21c000036", Name: "hold", Interface: "zthold0", Servers: []string{"10.147.36.1"}, Domain: "hold.example"}
+	h.API.SetNetworks(network)
+	cfg := h.Config("resolvfile")
+	cfg.Default.Networks = map[string]config.NetworkOverride{network.ID: {HoldDown: "400ms"}}
+	run := func(status string) bool {
+		t.Helper()
+		network.Status = status
+		h.API.SetNetworks(network)
+		if err := runner.New(cfg, false).RunOnce(); err != nil {
+			t.Fatalf("RunOnce: %v", err)
+		}
+		content, _ := os.ReadFile(h.ResolvConf)
+		return strings.Contains(string(content), "nameserver 10.147.36.1")
+	}
+
+	if run("OK") {
+		t.Fatal("DNS applied as soon as the network was ready")
+	}
+	time.Sleep(250 * time.Millisecond)
+	if run("REQUESTING_CONFIGURATION") {
+		t.Fatal("DNS applied while the network was not ready")
+	}
+	// The flap starts the hold-down over
+	run("OK")
+	time.Sleep(250 * time.Millisecond)
+	if run("OK") {
+		t.Fatal("DNS applied before the network was ready for hold_down since it last flapped")
+	}
+	time.Sleep(250 * time.Millisecond)
+	if !run("OK") {
+		t.Error("DNS not applied once the network was ready for hold_down")
+	}
+}
+
+func TestTokenFromEnvAndCommand(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("zttok0", "10.147.37.5/24")
+	h.API.SetNetworks(testharness.Network{ID: "8056c2e21c000037", Name: "tok", Interface: "zttok0", Servers: []string{"10.147.37.1"}, Domain: "tok.example"})
+	t.Setenv("ZEROPLEX_TEST_TOKEN", testharness.Token)
+	for _, tc := range []struct {
+		name   string
+		client func(*config.ClientConfig)
+	}{
+		{"token_env", func(c *config.ClientConfig) { c.TokenEnv = "ZEROPLEX_TEST_TOKEN" }},
+		{"token_command", func(c *config.ClientConfig) { c.TokenCommand = "echo " + testharness.Token }},
+	} {
+		cfg := h.Config("resolvfile")
+		cfg.Default.Client.TokenFile = filepath.Join(h.Dir, "unreadable")
+		tc.client(&cfg.Default.Client)
+		os.WriteFile(h.ResolvConf, nil, 0644)
+		if err := runner.New(cfg, false).RunOnce(); err != nil {
+			t.Fatalf("RunOnce with %s: %v", tc.name, err)
+		}
+		if content, _ := os.ReadFile(h.ResolvConf); !strings.Contains(string(content), "nameserver 10.147.37.1") {
+			t.Errorf("resolv.conf not applied with %s:\n%s", tc.name, content)
+		}
+	}
+}
+
+func TestServerOrderByRTT(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztrtt0", "10.147.34.5/24", "10.147.34.2/24", "10.147.34.3/24")
+	h.ServeDNS("10.147.34.2", 200*time.Millisecond)
+	h.ServeDNS("10.147.34.3", 0)
+	// Nothing listens on 10.147.34.5, so it refuses the query
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000034", Name: "rtt", Interface: "ztrtt0",
+		Servers: []string{"10.147.34.5", "10.147.34.2", "10.147.34.3"}, Domain: "rtt.example",
+	})
+	cfg := h.Config("resolvfile")
+
+	if err := runner.New(cfg, false).RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	content, _ := os.ReadFile(h.ResolvConf)
+	if !strings.Contains(string(content), "nameserver 10.147.34.5\nnameserver 10.147.34.2\nnameserver 10.147.34.3\n") {
+		t.Errorf("resolv.conf without server_order lacks the pushed order:\n%s", content)
+	}
+
+	cfg.Default.ServerOrder.Strategy = "rtt"
+	if err := runner.New(cfg, false).RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	content, _ = os.ReadFile(h.ResolvConf)
+	if !strings.Contains(string(content), "nameserver 10.147.34.3\nnameserver 10.147.34.2\nnameserver 10.147.34.5\n") {
+		t.Errorf("resolv.conf lacks the servers fastest first, the one refusing last:\n%s", content)
+	}
+}
+
+func TestDisableFileStopsChanges(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztoff0", "10.147.33.5/24")
+	h.API.SetNetworks(testharness.Network{ID: "8056c2e21c000033", Name: "off0", Interface: "ztoff0", Servers: []string{"10.147.33.1"}, Domain: "off0.example"})
+	cfg := h.Config("resolvfile")
+	if err := os.WriteFile(h.DisableFile, []byte("INC-1234 resolver outage\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := runner.New(cfg, false).RunOnce()
+	if exitcode.Code(err) != exitcode.DriftPending {
+		t.Errorf("RunOnce while disabled = %v, want drift pending", err)
+	}
+	if content, _ := os.ReadFile(h.ResolvConf); strings.Contains(string(content), "10.147.33.1") {
+		t.Errorf("resolv.conf changed while disabled:\n%s", content)
+	}
+	r := runner.New(cfg, false)
+	if status := r.Status(); !status.Disabled || status.DisabledReason != "INC-1234 resolver outage" {
+		t.Errorf("Status = disabled %t reason %q, want disabled with the reason of the file", status.Disabled, status.DisabledReason)
+	}
+	if _, err := r.RestoreAll(); err == nil {
+		t.Error("RestoreAll succeeded while disabled")
+	}
+
+	os.Remove(h.DisableFile)
+	if err := runner.New(cfg, false).RunOnce(); err != nil {
+		t.Fatalf("RunOnce after removing the file: %v", err)
+	}
+	if content, _ := os.ReadFile(h.ResolvConf); !strings.Contains(string(content), "nameserver 10.147.33.1") {
+		t.Errorf("resolv.conf not applied once the file was removed:\n%s", content)
+	}
+}
+
+func TestObserveOnlyReportsDriftWithoutChanges(t *testing.T) {
+	// Like the mock API, the receiver must listen in the original namespace
+	received := make(chan events.Event, 4)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var ev events.Event
+		if err := json.NewDecoder(req.Body).Decode(&ev); err == nil {
+			received <- ev
+		}
+	}))
+	defer hook.Close()
+
+	h := testharness.New(t)
+	h.AddZTInterface("ztobs0", "10.147.22.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000006", Name: "obs", Interface: "ztobs0",
+		Servers: []string{"10.147.22.1"}, Domain: "obs.example",
+	})
+
+	cfg := h.Config("resolved")
+	enforce := false
+	cfg.Default.Enforce = &enforce
+	cfg.Default.Webhooks = []config.WebhookConfig{{URL: hook.URL}}
+	cfg.Default.Labels = map[string]string{"site": "lab1"}
+	if err := runner.New(cfg, false).RunOnce(); exitcode.Code(err) != exitcode.DriftPending {
+		t.Fatalf("RunOnce = %v, want drift pending exit code", err)
+	}
+	if servers, _ := h.ResolvedLink("ztobs0"); len(servers) != 0 {
+		t.Errorf("observe-only run changed DNS: %v", servers)
+	}
+	select {
+	case ev := <-received:
+		if ev.Type != events.TypeDrift || ev.Interface != "ztobs0" {
+			t.Errorf("unexpected event %+v", ev)
+		}
+		if ev.Labels["site"] != "lab1" {
+			t.Errorf("event labels = %v, want site=lab1", ev.Labels)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no drift webhook received")
+	}
+}
+
+func TestExtraSearchDomainsExpandVariables(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztvars0", "10.147.23.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000007", Name: "vars", Interface: "ztvars0",
+		Servers: []string{"10.147.23.1"}, Domain: "vars.example",
+	})
+
+	cfg := h.Config("resolved")
+	cfg.Default.Features.ExtraSearchDomains = []string{"svc.%domain%", "%network_id%.nets.example"}
+	if err := runner.New(cfg, false).RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	_, domains := h.ResolvedLink("ztvars0")
+	want := "~8056c2e21c000007.nets.example ~svc.vars.example ~vars.example"
+	if strings.Join(domains, " ") != want {
+		t.Errorf("domains = %v, want %s", domains, want)
+	}
+}
+
+func TestApplyVerificationFailsRun(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztverify0", "10.147.24.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000008", Name: "verify", Interface: "ztverify0",
+		Servers: []string{"10.147.24.1"}, Domain: "verify.example",
+	})
+
+	cfg := h.Config("resolved")
+	cfg.Default.Features.VerifyHostname = "canary.%domain%"
+	cfg.Default.Features.VerifyTimeout = "1s"
+	r := runner.New(cfg, false)
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce with working canary: %v", err)
+	}
+	if !h.Called("resolvectl query -i ztverify0 canary.verify.example") {
+		t.Errorf("expected canary query, calls: %v", h.Calls())
+	}
+
+	h.FailQueries(true)
+	err := r.RunOnce()
+	var verifyErr *modes.VerificationError
+	if !errors.As(err, &verifyErr) || verifyErr.Interface != "ztverify0" {
+		t.Fatalf("RunOnce with failing canary = %v, want VerificationError for ztverify0", err)
+	}
+	if code := exitcode.Code(err); code != exitcode.PartialApply {
+		t.Errorf("exit code = %d, want %d", code, exitcode.PartialApply)
+	}
+}
+
+func TestNegativeTrustAnchorsFollowDNSSEC(t *testing.T) {
+	h := testharness.New(t)
+	link := h.AddZTInterface("ztnta0", "10.147.25.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000009", Name: "nta", Interface: "ztnta0",
+		Servers: []string{"10.147.25.1"}, Domain: "nta.example",
+	})
+	if err := os.WriteFile(filepath.Join(h.StateDir, "global.dnssec"), []byte("yes\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := runner.New(h.Config("resolved"), false)
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	ntaFile := filepath.Join(h.StateDir, fmt.Sprintf("%d.nta", link.Attrs().Index))
+	anchors, err := os.ReadFile(ntaFile)
+	if err != nil || strings.TrimSpace(string(anchors)) != "nta.example" {
+		t.Fatalf("negative trust anchors = %q (%v), want nta.example", anchors, err)
+	}
+
+	// Restoring the link drops the anchors with the rest of its settings
+	h.API.SetNetworks()
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("second RunOnce: %v", err)
+	}
+	if _, err := os.Stat(ntaFile); !os.IsNotExist(err) {
+		t.Errorf("negative trust anchors still present after restore")
+	}
+}
+
+func TestControlStatusReportsManagedInterfaces(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztctl0", "10.147.22.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000009", Name: "ctl", Interface: "ztctl0",
+		Servers: []string{"10.147.22.1"}, Domain: "ctl.example", Addresses: []string{"10.147.22.5/24"},
+	})
+
+	cfg := h.Config("resolved")
+	cfg.Default.Control.Socket = filepath.Join(t.TempDir(), "control.sock")
+	r := runner.New(cfg, false)
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	stop, err := r.StartControl()
+	if err != nil {
+		t.Fatalf("StartControl: %v", err)
+	}
+	defer stop()
+
+	status, err := control.NewClient(cfg.Default.Control.Socket).Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if len(status.Networks) != 1 || !status.Networks[0].Managed || status.Networks[0].Domain != "ctl.example" {
+		t.Errorf("networks = %+v, want managed ctl.example", status.Networks)
+	}
+	if len(status.Interfaces) != 1 || status.Interfaces[0].Name != "ztctl0" {
+		t.Errorf("interfaces = %+v, want ztctl0", status.Interfaces)
+	}
+	if status.Summary() != "ZeroTier DNS: active (1 network)" {
+		t.Errorf("summary = %q", status.Summary())
+	}
+	if hostname, _ := os.Hostname(); status.Hostname != hostname {
+		t.Errorf("hostname = %q, want %q", status.Hostname, hostname)
+	}
+	fleet := control.SummarizeFleet([]control.HostReport{{Host: "a", Status: status}, {Host: "b", Error: "exit status 255"}})
+	if fleet.Hosts != 2 || fleet.Unreachable != 1 || fleet.Failing != 0 || fleet.Versions["unknown"] != 1 {
+		t.Errorf("fleet summary = %+v, want one reachable host of an unknown version", fleet)
+	}
+
+	client := control.NewClient(cfg.Default.Control.Socket)
+	records, err := client.Logs(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Logs: %v", err)
+	}
+	configured := false
+	for _, rec := range records {
+		if rec.Scope == "[dns]" && strings.Contains(rec.Message, "Configured for Interface: ztctl0") {
+			configured = true
+		}
+	}
+	if !configured {
+		t.Errorf("logs do not include the apply of ztctl0: %+v", records)
+	}
+	if tail, err := client.Logs(context.Background(), 1); err != nil || len(tail) != 1 || tail[0] != records[len(records)-1] {
+		t.Errorf("Logs(tail=1) = %+v (%v), want the last record", tail, err)
+	}
+
+	var dump strings.Builder
+	if err := client.Metrics(context.Background(), &dump); err != nil {
+		t.Fatalf("Metrics: %v", err)
+	}
+	if !strings.Contains(dump.String(), "zeroplex_runs_total") || !strings.HasSuffix(dump.String(), "# EOF\n") {
+		t.Errorf("metrics dump is not OpenMetrics with the run counter:\n%s", dump.String())
+	}
+}
+
+func TestControlGroupReadsButCannotCommand(t *testing.T) {
+	if _, err := exec.LookPath("curl"); err != nil {
+		t.Skip("curl is not installed")
+	}
+	h := testharness.New(t)
+	h.AddZTInterface("ztgrp0", "10.147.38.5/24")
+	h.API.SetNetworks(testharness.Network{ID: "8056c2e21c000038", Name: "grp", Interface: "ztgrp0", Servers: []string{"10.147.38.1"}, Domain: "grp.example"})
+
+	// nobody has to reach the socket, which t.TempDir makes private
+	dir, err := os.MkdirTemp("", "zeroplex-control")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	os.Chmod(dir, 0755)
+	cfg := h.Config("resolvfile")
+	cfg.Default.Control.Socket = filepath.Join(dir, "control.sock")
+	cfg.Default.Control.Group = "nogroup"
+	r := runner.New(cfg, false)
+	stop, err := r.StartControl()
+	if err != nil {
+		t.Fatalf("StartControl: %v", err)
+	}
+	defer stop()
+	info, err := os.Stat(cfg.Default.Control.Socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0660 || info.Sys().(*syscall.Stat_t).Gid != 65534 {
+		t.Errorf("socket mode %v gid %d, want 0660 for nogroup", info.Mode().Perm(), info.Sys().(*syscall.Stat_t).Gid)
+	}
+
+	// As an unprivileged member of the group
+	curl := func(args ...string) (int, string) {
+		t.Helper()
+		cmd := exec.Command("curl", append([]string{"-s", "-o", "-", "-w", "\n%{http_code}", "--unix-socket", cfg.Default.Control.Socket}, args...)...)
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: &syscall.Credential{Uid: 65534, Gid: 65534}}
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("curl %v: %v", args, err)
+		}
+		// -w puts the status code on a line of its own after the body
+		i := strings.LastIndex(string(out), "\n")
+		code, _ := strconv.Atoi(string(out[i+1:]))
+		return code, string(out[:i])
+	}
+	if code, body := curl("http://zeroplex" + control.StatusPath); code != http.StatusOK || !strings.Contains(body, "grp.example") {
+		t.Errorf("status as a group member = %d %s, want the status document", code, body)
+	}
+	if code, body := curl("http://zeroplex" + control.NetworksPath); code != http.StatusOK || !strings.Contains(body, `"included":true`) {
+		t.Errorf("networks as a group member = %d %s, want the joined network", code, body)
+	}
+	if code, _ := curl("-X", "POST", "http://zeroplex"+control.RestorePath); code != http.StatusForbidden {
+		t.Errorf("restore as a group member = %d, want refused", code)
+	}
+
+	networks, err := control.NewClient(cfg.Default.Control.Socket).Networks(context.Background())
+	if err != nil {
+		t.Fatalf("Networks: %v", err)
+	}
+	if len(networks) != 1 || networks[0].ID != "8056c2e21c000038" || !networks[0].Included || networks[0].Domain != "grp.example" {
+		t.Errorf("networks = %+v, want grp included", networks)
+	}
+	if _, err := control.NewClient(cfg.Default.Control.Socket).Command(context.Background(), control.PausePath); err != nil {
+		t.Errorf("pause as root: %v", err)
+	}
+}
+
+func TestControlAdminGroupMayCommand(t *testing.T) {
+	if _, err := exec.LookPath("curl"); err != nil {
+		t.Skip("curl is not installed")
+	}
+	h := testharness.New(t)
+	h.AddZTInterface("ztacl0", "10.147.39.5/24")
+	h.API.SetNetworks(testharness.Network{ID: "8056c2e21c000039", Name: "acl", Interface: "ztacl0", Servers: []string{"10.147.39.1"}, Domain: "acl.example"})
+
+	dir, err := os.MkdirTemp("", "zeroplex-control")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	os.Chmod(dir, 0755)
+	cfg := h.Config("resolvfile")
+	cfg.Default.Control.Socket = filepath.Join(dir, "control.sock")
+	cfg.Default.Control.Group = "daemon"
+	cfg.Default.Control.AdminGroup = "nogroup"
+	r := runner.New(cfg, false)
+	stop, err := r.StartControl()
+	if err != nil {
+		t.Fatalf("StartControl: %v", err)
+	}
+	defer stop()
+	info, err := os.Stat(cfg.Default.Control.Socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0666 || info.Sys().(*syscall.Stat_t).Gid != 1 {
+		t.Errorf("socket mode %v gid %d, want 0666 for the readers' group daemon", info.Mode().Perm(), info.Sys().(*syscall.Stat_t).Gid)
+	}
+
+	curl := func(uid, gid uint32, groups []uint32, args ...string) int {
+		t.Helper()
+		cmd := exec.Command("curl", append([]string{"-s", "-o", "/dev/null", "-w", "%{http_code}", "--unix-socket", cfg.Default.Control.Socket}, args...)...)
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: &syscall.Credential{Uid: uid, Gid: gid, Groups: groups}}
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("curl %v as %d: %v", args, uid, err)
+		}
+		code, _ := strconv.Atoi(string(out))
+		return code
+	}
+	for _, tc := range []struct {
+		who      string
+		uid, gid uint32
+		groups   []uint32 // supplementary
+		method   string
+		path     string
+		want     int
+	}{
+		{"a reader", 1, 1, nil, "GET", control.StatusPath, http.StatusOK},
+		{"an admin", 65534, 65534, nil, "POST", control.PausePath, http.StatusOK},
+		{"an admin by a supplementary group", 2, 2, []uint32{65534}, "POST", control.PausePath, http.StatusOK},
+		{"neither", 2, 2, nil, "GET", control.StatusPath, http.StatusForbidden},
+	} {
+		if code := curl(tc.uid, tc.gid, tc.groups, "-X", tc.method, "http://zeroplex"+tc.path); code != tc.want {
+			t.Errorf("%s %s as %s = %d, want %d", tc.method, tc.path, tc.who, code, tc.want)
+		}
+	}
+}
+
+func TestControlEventsStreamsApply(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztevt0", "10.147.23.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c00000a", Name: "evt", Interface: "ztevt0",
+		Servers: []string{"10.147.23.1"}, Domain: "evt.example", Addresses: []string{"10.147.23.5/24"},
+	})
+
+	cfg := h.Config("resolved")
+	cfg.Default.Control.Socket = filepath.Join(t.TempDir(), "control.sock")
+	r := runner.New(cfg, false)
+	stop, err := r.StartControl()
+	if err != nil {
+		t.Fatalf("StartControl: %v", err)
+	}
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	received := make(chan events.Event, 1)
+	go control.NewClient(cfg.Default.Control.Socket).Events(ctx, control.EventsOptions{Types: []string{events.TypeApply}}, func(ev events.Event) error {
+		if ev.Interface == "ztevt0" {
+			received <- ev
+			return errors.New("done")
+		}
+		return nil
+	})
+	// Give the subscription a moment to register before the run publishes
+	time.Sleep(200 * time.Millisecond)
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+
+	select {
+	case ev := <-received:
+		if ev.NetworkID != "8056c2e21c00000a" || ev.Mode != "resolved" {
+			t.Errorf("apply event = %+v", ev)
+		}
+	case <-ctx.Done():
+		t.Fatalf("no apply event streamed for ztevt0")
+	}
+}
+
+func TestHealthReadyAfterFirstApply(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("zthlt0", "10.147.23.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c00000b", Name: "hlt", Interface: "zthlt0",
+		Servers: []string{"10.147.23.1"}, Domain: "hlt.example", Addresses: []string{"10.147.23.5/24"},
+	})
+
+	cfg := h.Config("resolved")
+	r := runner.New(cfg, false)
+	if readiness := r.Readiness(context.Background()); readiness.Ready || readiness.Checks["dns_applied"] == "ok" || readiness.Checks["zerotier_api"] != "ok" {
+		t.Errorf("readiness before the first run = %+v, want unready with only dns_applied failing", readiness)
+	}
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if readiness := r.Readiness(context.Background()); !readiness.Ready {
+		t.Errorf("readiness after the first run = %+v, want ready", readiness)
+	}
+
+	// Served over HTTP on a fixed port, as probes see it
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	cfg.Default.Health.Listen = address
+	r = runner.New(cfg, false)
+	stop, err := r.StartHealth()
+	if err != nil {
+		t.Fatalf("StartHealth: %v", err)
+	}
+	defer stop()
+	for path, want := range map[string]int{"/healthz": http.StatusOK, "/readyz": http.StatusServiceUnavailable} {
+		resp := getInNamespace(t, address, path)
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("GET %s = %d, want %d", path, resp.StatusCode, want)
+		}
+	}
+	resp := getInNamespace(t, address, "/readyz")
+	defer resp.Body.Close()
+	var readiness runner.Readiness
+	if err := json.NewDecoder(resp.Body).Decode(&readiness); err != nil {
+		t.Fatalf("decode /readyz: %v", err)
+	}
+	if readiness.Checks["zerotier_api"] != "ok" || readiness.Checks["dns_applied"] == "ok" {
+		t.Errorf("/readyz of a daemon without a run = %+v, want only dns_applied failing", readiness)
+	}
+
+	cfg.Default.Client.Token = "wrong"
+	if readiness := runner.New(cfg, false).Readiness(context.Background()); readiness.Checks["zerotier_api"] == "ok" {
+		t.Errorf("readiness with a rejected token = %+v, want zerotier_api failing", readiness)
+	}
+}
+
+// getInNamespace sends a GET request from the test's network namespace; http.Client dials on other
+// threads, which are outside of it
+func getInNamespace(t *testing.T, address, path string) *http.Response {
+	t.Helper()
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	req, _ := http.NewRequest(http.MethodGet, "http://"+address+path, nil)
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	return resp
+}
+
+func TestConfigEpochTagsEventsAndActions(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztepoch0", "10.147.24.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c00000c", Name: "epoch", Interface: "ztepoch0",
+		Servers: []string{"10.147.24.1"}, Domain: "epoch.example",
+	})
+
+	cfg := h.Config("resolvconf")
+	cfg.Default.ConfigEpoch = "2025-06-r3"
+	r := runner.New(cfg, false)
+	ch, cancel := events.Subscribe(8)
+	defer cancel()
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	applied := false
+	for len(ch) > 0 {
+		if ev := <-ch; ev.Type == events.TypeApply && ev.Interface == "ztepoch0" {
+			applied = true
+			if ev.ConfigEpoch != "2025-06-r3" {
+				t.Errorf("apply event epoch = %q, want 2025-06-r3", ev.ConfigEpoch)
+			}
+		}
+	}
+	if !applied {
+		t.Fatalf("no apply event for ztepoch0")
+	}
+
+	store, err := state.Open(h.StatePath)
+	if err != nil {
+		t.Fatalf("open state: %v", err)
+	}
+	actions := store.Snapshot().Actions
+	if len(actions) == 0 || actions[len(actions)-1].ConfigEpoch != "2025-06-r3" {
+		t.Errorf("actions = %+v, want the last one tagged with epoch 2025-06-r3", actions)
+	}
+	if epoch := r.Status().ConfigEpoch; epoch != "2025-06-r3" {
+		t.Errorf("status epoch = %q, want 2025-06-r3", epoch)
+	}
+}
+
+func TestControlCommandsRestoreApplyAndReload(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztcmd0", "10.147.25.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c00000d", Name: "cmd", Interface: "ztcmd0",
+		Servers: []string{"10.147.25.1"}, Domain: "cmd.example", Addresses: []string{"10.147.25.5/24"},
+	})
+
+	// The control API serves requests outside the test's network namespace, so this uses a mode
+	// that applies without looking up the link
+	cfg := h.Config("resolvconf")
+	cfg.Default.Control.Socket = filepath.Join(t.TempDir(), "control.sock")
+	r := runner.New(cfg, false)
+	next := cfg
+	var reloadErr error
+	r.SetReloader(func() (config.Config, error) { return next, reloadErr })
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	stop, err := r.StartControl()
+	if err != nil {
+		t.Fatalf("StartControl: %v", err)
+	}
+	defer stop()
+	client := control.NewClient(cfg.Default.Control.Socket)
+	ctx := context.Background()
+
+	result, err := client.Command(ctx, control.RestorePath)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if strings.Join(result.Restored, " ") != "ztcmd0" {
+		t.Errorf("restored = %v, want [ztcmd0]", result.Restored)
+	}
+	if entry := h.Resolvconf("ztcmd0"); entry != "" {
+		t.Errorf("resolvconf entry after restore = %q, want none", entry)
+	}
+
+	if _, err := client.Command(ctx, control.ApplyPath); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if entry := h.Resolvconf("ztcmd0"); !strings.Contains(entry, "nameserver 10.147.25.1\n") {
+		t.Errorf("resolvconf entry after apply = %q, want nameserver 10.147.25.1", entry)
+	}
+
+	next.Default.ConfigEpoch = "reloaded"
+	if _, err := client.Command(ctx, control.ReloadPath); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if status, err := client.Status(ctx); err != nil || status.ConfigEpoch != "reloaded" {
+		t.Errorf("status after reload = %+v (%v), want epoch reloaded", status.Snapshot, err)
+	}
+
+	// An invalid configuration is rejected and the running one kept
+	reloadErr = errors.New("client.host: missing")
+	if _, err := client.Command(ctx, control.ReloadPath); err == nil || !strings.Contains(err.Error(), "client.host") {
+		t.Errorf("reload of an invalid configuration = %v, want the validation error", err)
+	}
+	if epoch := r.Status().ConfigEpoch; epoch != "reloaded" {
+		t.Errorf("epoch after rejected reload = %q, want reloaded", epoch)
+	}
+}
+
+func TestReloadSwitchesTheZeroTierClient(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztcli0", "10.147.49.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000049", Name: "cli", Interface: "ztcli0",
+		Servers: []string{"10.147.49.1"}, Domain: "cli.example",
+	})
+
+	next := h.Config("resolvconf")
+	offline := next
+	offline.Default.Client.Host = "http://127.0.0.1"
+	offline.Default.Client.Port = 1
+	r := runner.New(offline, false)
+	r.SetReloader(func() (config.Config, error) { return next, nil })
+	if err := r.RunOnce(); err == nil {
+		t.Fatal("RunOnce against the offline client succeeded")
+	}
+
+	// The run started by Reload asks the client of the new configuration
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if entry := h.Resolvconf("ztcli0"); !strings.Contains(entry, "nameserver 10.147.49.1\n") {
+		t.Errorf("resolvconf entry after reload = %q, want nameserver 10.147.49.1", entry)
+	}
+}
+
+func TestNetworkdFirstReloadWaitsForCarrier(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztcar0", "10.147.34.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000016", Name: "car", Interface: "ztcar0",
+		Servers: []string{"10.147.34.1"}, Domain: "car.example",
+	})
+	sys := t.TempDir()
+	saved := modes.SysClassNet
+	modes.SysClassNet = sys
+	t.Cleanup(func() { modes.SysClassNet = saved })
+	carrier := filepath.Join(sys, "ztcar0", "carrier")
+	if err := os.MkdirAll(filepath.Dir(carrier), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(carrier, []byte("0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	const delay = 500 * time.Millisecond
+	time.AfterFunc(delay, func() { os.WriteFile(carrier, []byte("1\n"), 0644) })
+
+	cfg := h.Config("networkd")
+	cfg.Default.Networkd.WaitForCarrier = "10s"
+	r := runner.New(cfg, false)
+	started := time.Now()
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed < delay || elapsed > 5*time.Second {
+		t.Errorf("run took %s, want it to reload once the carrier came up after %s", elapsed, delay)
+	}
+	if !h.Called("networkctl reload") {
+		t.Fatalf("expected networkctl reload, calls: %v", h.Calls())
+	}
+
+	// Only the first reload of the process waits
+	os.WriteFile(carrier, []byte("0\n"), 0644)
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000016", Name: "car", Interface: "ztcar0",
+		Servers: []string{"10.147.34.2"}, Domain: "car.example",
+	})
+	started = time.Now()
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("second RunOnce: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("second run took %s, want no wait for the carrier", elapsed)
+	}
+}
+
+func TestNetworkdReloadLimitRefusesAndAlerts(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztlim0", "10.147.26.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c00000e", Name: "lim", Interface: "ztlim0",
+		Servers: []string{"10.147.26.1"}, Domain: "lim.example",
+	})
+
+	cfg := h.Config("networkd")
+	cfg.Default.Networkd.MaxReloadsPerHour = 1
+	r := runner.New(cfg, false)
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if !h.Called("networkctl reload") {
+		t.Fatalf("expected networkctl reload, calls: %v", h.Calls())
+	}
+
+	// A second change within the hour is written but does not reload networkd
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c00000e", Name: "lim", Interface: "ztlim0",
+		Servers: []string{"10.147.26.2"}, Domain: "lim.example",
+	})
+	ch, cancel := events.Subscribe(8)
+	defer cancel()
+	before := len(h.Calls())
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("second RunOnce: %v", err)
+	}
+	for _, call := range h.Calls()[before:] {
+		if strings.HasPrefix(call, "networkctl reload") {
+			t.Errorf("networkd reloaded beyond max_reloads_per_hour")
+		}
+	}
+	content, err := os.ReadFile(filepath.Join(h.NetworkdDir, "99-ztlim0.network"))
+	if err != nil || !strings.Contains(string(content), "DNS=10.147.26.2") {
+		t.Errorf("network file not rewritten: %s (%v)", content, err)
+	}
+	alerted := false
+	for len(ch) > 0 {
+		if ev := <-ch; ev.Type == events.TypeReloadLimit && ev.Data["service"] == "systemd-networkd" {
+			alerted = true
+		}
+	}
+	if !alerted {
+		t.Errorf("no reload_limit event published")
+	}
+	if got := modes.Summary().Errors; got != 1 {
+		t.Errorf("summary errors = %d, want 1", got)
+	}
+}
+
+func TestGRPCStatusEventsAndRestore(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztgrpc0", "10.147.27.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c00000f", Name: "grpc", Interface: "ztgrpc0",
+		Servers: []string{"10.147.27.1"}, Domain: "grpc.example",
+	})
+
+	// Like the control API, the gRPC API serves requests outside the test's network namespace
+	cfg := h.Config("resolvconf")
+	socket := filepath.Join(t.TempDir(), "grpc.sock")
+	cfg.Default.GRPC.Listen = "unix:" + socket
+	r := runner.New(cfg, false)
+	stop, err := r.StartGRPC()
+	if err != nil {
+		t.Fatalf("StartGRPC: %v", err)
+	}
+	defer stop()
+	conn, err := grpc.NewClient("unix:"+socket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.NewClient: %v", err)
+	}
+	defer conn.Close()
+	client := grpcapi.NewManagementClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream, err := client.WatchEvents(ctx, &grpcapi.WatchEventsRequest{Types: []string{events.TypeApply, events.TypeRestore}})
+	if err != nil {
+		t.Fatalf("WatchEvents: %v", err)
+	}
+	// Give the subscription a moment to register before the run publishes
+	time.Sleep(200 * time.Millisecond)
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	ev, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	if ev.Type != events.TypeApply || ev.Interface != "ztgrpc0" || ev.NetworkId != "8056c2e21c00000f" {
+		t.Errorf("streamed event = %+v, want apply of ztgrpc0", ev)
+	}
+
+	status, err := client.Status(ctx, &grpcapi.StatusRequest{})
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if status.Mode != "resolvconf" || len(status.Interfaces) != 1 || status.Interfaces[0].Name != "ztgrpc0" {
+		t.Errorf("status = %+v, want resolvconf managing ztgrpc0", status)
+	}
+
+	restored, err := client.Restore(ctx, &grpcapi.CommandRequest{})
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if strings.Join(restored.Restored, " ") != "ztgrpc0" {
+		t.Errorf("restored = %v, want [ztgrpc0]", restored.Restored)
+	}
+	if ev, err := stream.Recv(); err != nil || ev.Type != events.TypeRestore || ev.Interface != "ztgrpc0" {
+		t.Errorf("streamed event after restore = %+v (%v), want restore of ztgrpc0", ev, err)
+	}
+}
+
+func TestGRPCAuthorizesLikeTheControlAPI(t *testing.T) {
+	// The calls of a member of control.group are made as nobody, from a child process
+	if socket := os.Getenv("ZEROPLEX_TEST_GRPC_SOCKET"); socket != "" {
+		conn, err := grpc.NewClient("unix:"+socket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		client := grpcapi.NewManagementClient(conn)
+		_, statusErr := client.Status(context.Background(), &grpcapi.StatusRequest{})
+		_, restoreErr := client.Restore(context.Background(), &grpcapi.CommandRequest{})
+		fmt.Printf("CODES %s %s\n", grpcstatus.Code(statusErr), grpcstatus.Code(restoreErr))
+		return
+	}
+	h := testharness.New(t)
+	h.API.SetNetworks()
+	dir, err := os.MkdirTemp("", "zeroplex-grpc-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Chmod(dir, 0755)
+	binary := filepath.Join(dir, "runner.test")
+	content, err := os.ReadFile(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(binary, content, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := h.Config("noop")
+	socket := filepath.Join(dir, "grpc.sock")
+	cfg.Default.GRPC.Listen = "unix:" + socket
+	cfg.Default.Control.Group = "nogroup"
+	stop, err := runner.New(cfg, false).StartGRPC()
+	if err != nil {
+		t.Fatalf("StartGRPC: %v", err)
+	}
+	defer stop()
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0660 || info.Sys().(*syscall.Stat_t).Gid != 65534 {
+		t.Errorf("socket mode %v gid %d, want 0660 for control.group nogroup", info.Mode().Perm(), info.Sys().(*syscall.Stat_t).Gid)
+	}
+	cmd := exec.Command(binary, "-test.run", "^TestGRPCAuthorizesLikeTheControlAPI$")
+	cmd.Env = append(os.Environ(), "ZEROPLEX_TEST_GRPC_SOCKET="+socket)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Credential: &syscall.Credential{Uid: 65534, Gid: 65534}}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("child: %v\n%s", err, output)
+	}
+	if !strings.Contains(string(output), "CODES OK PermissionDenied\n") {
+		t.Errorf("Status and Restore as a member of control.group: %s; want OK and PermissionDenied", output)
+	}
+}
+
+func TestBusPropertiesFollowManagedInterfaces(t *testing.T) {
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+	// A private bus stands in for the system bus; the session policy lets anyone own names
+	address := "unix:path=" + filepath.Join(t.TempDir(), "bus")
+	daemon := exec.Command("dbus-daemon", "--session", "--nofork", "--address="+address)
+	if err := daemon.Start(); err != nil {
+		t.Fatalf("dbus-daemon: %v", err)
+	}
+	t.Cleanup(func() { daemon.Process.Kill(); daemon.Wait() })
+	t.Setenv("DBUS_SYSTEM_BUS_ADDRESS", address)
+	var conn *dbus.Conn
+	var err error
+	for i := 0; i < 50; i++ {
+		if conn, err = dbus.Connect(address); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("connect to the private bus: %v", err)
+	}
+	defer conn.Close()
+
+	h := testharness.New(t)
+	h.AddZTInterface("ztbus0", "10.147.28.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000010", Name: "bus", Interface: "ztbus0",
+		Servers: []string{"10.147.28.1"}, Domain: "bus.example",
+	})
+	cfg := h.Config("resolvconf")
+	r := runner.New(cfg, false)
+	// Reload is called from the bus connection's goroutine, after a request the race detector
+	// doesn't see as synchronizing
+	var nextMu sync.Mutex
+	next := cfg
+	r.SetReloader(func() (config.Config, error) {
+		nextMu.Lock()
+		defer nextMu.Unlock()
+		return next, nil
+	})
+	release, err := r.StartBus()
+	if err != nil {
+		t.Fatalf("StartBus: %v", err)
+	}
+	defer release()
+
+	if err := conn.AddMatchSignal(dbus.WithMatchInterface("org.freedesktop.DBus.Properties"), dbus.WithMatchMember("PropertiesChanged")); err != nil {
+		t.Fatalf("AddMatchSignal: %v", err)
+	}
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	if err := r.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	select {
+	case sig := <-signals:
+		changed := sig.Body[1].(map[string]dbus.Variant)
+		if _, ok := changed[bus.PropertyInterfaces]; !ok || sig.Body[0] != bus.Interface {
+			t.Errorf("PropertiesChanged = %v, want %s changed on %s", sig.Body, bus.PropertyInterfaces, bus.Interface)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no PropertiesChanged after the run")
+	}
+
+	obj := conn.Object(bus.Name, bus.Path)
+	var interfaces []bus.InterfaceProperty
+	if err := obj.StoreProperty(bus.Interface+"."+bus.PropertyInterfaces, &interfaces); err != nil {
+		t.Fatalf("get %s: %v", bus.PropertyInterfaces, err)
+	}
+	if len(interfaces) != 1 || interfaces[0].Name != "ztbus0" || strings.Join(interfaces[0].DNS, " ") != "10.147.28.1" {
+		t.Errorf("%s = %+v, want ztbus0 with 10.147.28.1", bus.PropertyInterfaces, interfaces)
+	}
+
+	nextMu.Lock()
+	next.Default.ConfigEpoch = "bus-reload"
+	nextMu.Unlock()
+	if err := obj.Call(bus.Interface+".Reload", 0).Err; err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if epoch, err := obj.GetProperty(bus.Interface + "." + bus.PropertyConfigEpoch); err != nil || epoch.Value() != "bus-reload" {
+		t.Errorf("%s after Reload = %v (%v), want bus-reload", bus.PropertyConfigEpoch, epoch, err)
+	}
+}
+
+func TestDualStackReadinessWaitsForBothFamilies(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztdual0", "10.147.29.5/24")
+	network := testharness.Network{
+		ID: "8056c2e21c000011", Name: "dual", Interface: "ztdual0",
+		Servers: []string{"10.147.29.1"}, Domain: "dual.example", Addresses: []string{"fd80:56c2:e21c::5/88"},
+	}
+	h.API.SetNetworks(network)
+	cfg := h.Config("resolvconf")
+
+	if ready, status, err := runner.New(cfg, false).InterfaceReady("ztdual0"); err != nil || !ready {
+		t.Fatalf("InterfaceReady without dual_stack = %t, %q, %v; want ready", ready, status, err)
+	}
+	cfg.Default.InterfaceWatch.Networks = map[string]config.NetworkReadinessConfig{network.ID: {DualStack: true}}
+	if ready, status, err := runner.New(cfg, false).InterfaceReady("ztdual0"); err != nil || ready || status != "no_ipv4" {
+		t.Errorf("InterfaceReady with only IPv6 = %t, %q, %v; want not ready with no_ipv4", ready, status, err)
+	}
+
+	// A new runner, since the network list is cached for a second
+	network.Addresses = append(network.Addresses, "10.147.29.5/24")
+	h.API.SetNetworks(network)
+	if ready, status, err := runner.New(cfg, false).InterfaceReady("ztdual0"); err != nil || !ready {
+		t.Errorf("InterfaceReady with both families = %t, %q, %v; want ready", ready, status, err)
+	}
+}
+
+func TestIPv6OnlyNetworkReadiness(t *testing.T) {
+	h := testharness.New(t)
+	// Up with only a link-local address, and the fe80::/64 route the kernel adds for it
+	link := h.AddZTInterface("ztv6only0", "fe80::5/64")
+	network := testharness.Network{
+		ID: "8056c2e21c000013", Name: "v6only", Interface: "ztv6only0",
+		Servers: []string{"fd80:56c2:e21c:13::1"}, Domain: "v6only.example", Addresses: []string{"fd80:56c2:e21c:13::5/64"},
+	}
+	h.API.SetNetworks(network)
+	cfg := h.Config("resolvconf")
+
+	ready := func(readiness string) (bool, string) {
+		t.Helper()
+		// A new runner each time, since the network list is cached for a second
+		c := cfg
+		c.Default.InterfaceWatch = config.InterfaceWatch{Readiness: readiness}
+		ok, status, err := runner.New(c, false).InterfaceReady("ztv6only0")
+		if err != nil {
+			t.Fatalf("InterfaceReady: %v", err)
+		}
+		return ok, status
+	}
+	if ok, status := ready("route-present"); ok || status != "no_route" {
+		t.Errorf("route-present with only the kernel's IPv6 routes = %t, %q; want not ready with no_route", ok, status)
+	}
+	if ok, status := ready("address-present"); ok || status != "no_address" {
+		t.Errorf("address-present before the address = %t, %q; want not ready with no_address", ok, status)
+	}
+
+	addr, _ := netlink.ParseAddr("fd80:56c2:e21c:13::5/64")
+	addr.Flags = syscall.IFA_F_NODAD
+	if err := netlink.AddrAdd(link, addr); err != nil {
+		t.Fatalf("add address: %v", err)
+	}
+	if ok, status := ready("address-present"); !ok {
+		t.Errorf("address-present = %t, %q; want ready", ok, status)
+	}
+	if ok, status := ready("route-present"); !ok {
+		t.Errorf("route-present = %t, %q; want ready through the IPv6 connected route", ok, status)
+	}
+}
+
+func TestReadinessStrategiesPerNetwork(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztrdy0", "10.147.30.5/24")
+	// ZeroTier still reports the network as requesting its configuration
+	network := testharness.Network{
+		ID: "8056c2e21c000012", Name: "rdy", Interface: "ztrdy0", Status: "REQUESTING_CONFIGURATION",
+		Servers: []string{"10.147.30.1"}, Domain: "rdy.example", Addresses: []string{"10.147.30.9/24"},
+	}
+	h.API.SetNetworks(network)
+	cfg := h.Config("resolvconf")
+
+	ready := func(watch config.InterfaceWatch) (bool, string) {
+		t.Helper()
+		// A new runner each time, since the network list is cached for a second
+		c := cfg
+		c.Default.InterfaceWatch = watch
+		ok, status, err := runner.New(c, false).InterfaceReady("ztrdy0")
+		if err != nil {
+			t.Fatalf("InterfaceReady: %v", err)
+		}
+		return ok, status
+	}
+	if ok, status := ready(config.InterfaceWatch{}); ok || status != "REQUESTING_CONFIGURATION" {
+		t.Errorf("api-status = %t, %q; want not ready", ok, status)
+	}
+	if ok, status := ready(config.InterfaceWatch{Readiness: "route-present"}); !ok {
+		t.Errorf("route-present = %t, %q; want ready through the connected route", ok, status)
+	}
+	perNetwork := config.InterfaceWatch{Networks: map[string]config.NetworkReadinessConfig{
+		network.ID: {Readiness: "composite", Checks: []string{"route-present", "address-present"}},
+	}}
+	if ok, status := ready(perNetwork); ok || status != "address-present: no_address" {
+		t.Errorf("composite without the assigned address = %t, %q; want address-present: no_address", ok, status)
+	}
+
+	network.Addresses = []string{"10.147.30.5/24"}
+	h.API.SetNetworks(network)
+	if ok, status := ready(perNetwork); !ok || status != "route-present+address-present" {
+		t.Errorf("composite = %t, %q; want ready", ok, status)
+	}
+}
+
+func TestHardeningLocksStateDirectory(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("zthrd0", "10.147.31.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000013", Name: "hrd", Interface: "zthrd0",
+		Servers: []string{"10.147.31.1"}, Domain: "hrd.example",
+	})
+	if err := runner.New(h.Config("resolvconf"), false).RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	dir := filepath.Dir(h.StatePath)
+	if err := os.Chmod(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := syscall.Umask(0022)
+	defer syscall.Umask(previous)
+	if err := hardening.Apply(config.HardeningConfig{Umask: "0077", LockStateDir: true}, log.NewLogger("[test]", "error")); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	for path, want := range map[string]os.FileMode{dir: 0700, h.StatePath: 0600} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("stat %s: %v", path, err)
+		}
+		if info.Mode().Perm() != want {
+			t.Errorf("%s = %v, want %v", path, info.Mode().Perm(), want)
+		}
+	}
+	if mask := syscall.Umask(0077); mask != 0077 {
+		t.Errorf("umask = %04o, want 0077", mask)
+	}
+}
+
+func TestStateDirPreparesAndMigrates(t *testing.T) {
+	root := t.TempDir()
+	legacy := state.NewStateDir(filepath.Join(root, "legacy"))
+	dir := state.NewStateDir(filepath.Join(root, "state"))
+	for _, d := range []string{legacy.Path(), filepath.Join(dir.Path(), "tmp")} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(dir.Path(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// An interrupted write, and one that is still going on
+	stale, fresh := dir.File("tmp/.state-1.json"), dir.File("tmp/.state-2.json")
+	for _, path := range []string{stale, fresh} {
+		if err := os.WriteFile(path, []byte("{"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := dir.Prepare()
+	if err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+	if len(changes) != 3 {
+		t.Errorf("changes = %q, want the two directories restricted and one write removed", changes)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("stale temporary file kept: %v", err)
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Errorf("fresh temporary file removed: %v", err)
+	}
+	info, err := os.Stat(dir.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0700 {
+		t.Errorf("mode = %v, want 0700", info.Mode().Perm())
+	}
+
+	for name, content := range map[string]string{"state.json": "legacy", "resolv.conf.backup": "nameserver 1.1.1.1\n", "state.json.lock": ""} {
+		if err := os.WriteFile(legacy.File(name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dir.WriteFile("state.json", []byte("current"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	moved, err := dir.Migrate(legacy)
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if len(moved) != 1 || moved[0] != "resolv.conf.backup" {
+		t.Errorf("moved = %q, want only resolv.conf.backup", moved)
+	}
+	if data, _ := os.ReadFile(dir.File("state.json")); string(data) != "current" {
+		t.Errorf("state.json = %q, want the existing one kept", data)
+	}
+	if _, err := os.Stat(legacy.File("state.json.lock")); err != nil {
+		t.Errorf("lock file moved: %v", err)
+	}
+}
+
+func TestStateExportImportCarriesStoreAndFiles(t *testing.T) {
+	root := t.TempDir()
+	old := state.NewStateDir(filepath.Join(root, "old"))
+	store, err := state.Open(old.File("state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetInterface(state.Interface{Name: "ztexport0", NetworkID: "8056c2e21c000015", Mode: "resolvfile", DNS: []string{"10.147.33.1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RecordAction(state.Action{Mode: "resolvfile", Operation: "apply", Interface: "ztexport0", Applied: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.MarkReady("8056c2e21c000015", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := old.WriteFile("resolv.conf.backup", []byte("nameserver 1.1.1.1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := state.Export(old.File("state.json"))
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if names := bundle.Names(); len(names) != 1 || names[0] != "resolv.conf.backup" {
+		t.Errorf("files = %q, want only resolv.conf.backup", names)
+	}
+	encoded, err := json.Marshal(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded state.Bundle
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	rebuilt := state.NewStateDir(filepath.Join(root, "rebuilt"))
+	written, err := state.Import(rebuilt.File("state.json"), decoded)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if len(written) != 1 {
+		t.Errorf("written = %q, want resolv.conf.backup", written)
+	}
+	restored, err := state.Open(rebuilt.File("state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := restored.Snapshot()
+	if entry := snap.Interfaces["ztexport0"]; entry.NetworkID != "8056c2e21c000015" || len(entry.DNS) != 1 {
+		t.Errorf("interface = %+v, want the exported entry", entry)
+	}
+	if len(snap.Actions) != 1 || snap.Actions[0].Operation != "apply" {
+		t.Errorf("actions = %+v, want the exported history", snap.Actions)
+	}
+	if since, _ := restored.MarkReady("8056c2e21c000015", true); !since.Equal(bundle.State.ReadySince["8056c2e21c000015"]) {
+		t.Errorf("ready since %v, want the exported %v", since, bundle.State.ReadySince["8056c2e21c000015"])
+	}
+	info, err := os.Stat(rebuilt.File("resolv.conf.backup"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(rebuilt.File("resolv.conf.backup")); string(data) != "nameserver 1.1.1.1\n" || info.Mode().Perm() != 0644 {
+		t.Errorf("resolv.conf.backup = %q %v, want the exported content and mode", data, info.Mode().Perm())
+	}
+	if empty, err := state.Empty(rebuilt.File("state.json")); err != nil || empty {
+		t.Errorf("Empty = %v, %v after the import", empty, err)
+	}
+
+	decoded.Files["../escape"] = state.BundleFile{Content: []byte("x")}
+	if _, err := state.Import(rebuilt.File("state.json"), decoded); err == nil {
+		t.Error("Import accepted a file name outside the state directory")
+	}
+}
+
+func TestFlushRevertsLinksRecordedByAnotherProcess(t *testing.T) {
+	h := testharness.New(t)
+	link := h.AddZTInterface("ztflush0", "10.147.32.5/24")
+	// An entry left by a daemon that has exited: this process has no saved DNS for the link
+	store, err := state.Default()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetInterface(state.Interface{
+		Name: "ztflush0", Index: link.Attrs().Index, NetworkID: "8056c2e21c000014", Mode: "resolved",
+		DNS: []string{"10.147.32.1"}, Domains: []string{"flush.example"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Links changed by earlier tests in this process are restored as well
+	restored := modes.RestoreManaged(h.Config("resolved"), false)
+	if !strings.Contains(strings.Join(restored, " "), "ztflush0") {
+		t.Fatalf("restored = %q, want ztflush0", restored)
+	}
+	if !h.Called(fmt.Sprintf("resolvectl revert %d", link.Attrs().Index)) {
+		t.Errorf("expected resolvectl revert, calls: %v", h.Calls())
+	}
+	store.Reload()
+	if len(store.Interfaces()) != 0 {
+		t.Errorf("state store still has %v", store.Interfaces())
+	}
+}
+
+func TestSnapshotIsDeterministic(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztsnb0", "10.147.34.5/24")
+	h.AddZTInterface("ztsna0", "10.147.33.5/24")
+	h.AddZTInterface("ztsnx0", "10.147.35.5/24")
+	h.API.SetNetworks(
+		testharness.Network{ID: "8056c2e21c000016", Name: "snap-b", Interface: "ztsnb0", Servers: []string{"10.147.34.1"}, Domain: "b.example"},
+		testharness.Network{ID: "8056c2e21c000015", Name: "snap-a", Interface: "ztsna0", Servers: []string{"10.147.33.1"}, Domain: "a.example"},
+		testharness.Network{ID: "8056c2e21c000017", Name: "skip", Interface: "ztsnx0", Servers: []string{"10.147.35.1"}, Domain: "x.example"},
+	)
+	cfg := h.Config("networkd")
+	cfg.Default.Filters = []map[string]interface{}{{"type": "name", "value": "snap-*"}}
+
+	snapshot := func() modes.Snapshot {
+		resp, err := client.New(cfg.Default.Client).Networks(context.Background())
+		if err != nil || resp.JSON200 == nil {
+			t.Fatalf("Networks: %v", err)
+		}
+		filters.ApplyFilters(resp, cfg.Default)
+		return modes.DesiredSnapshot(cfg, resp)
+	}
+	first := snapshot()
+	if len(first.Networks) != 2 || first.Networks[0].Interface != "ztsna0" || first.Networks[1].Interface != "ztsnb0" {
+		t.Fatalf("networks = %+v, want snap-a and snap-b in ID order", first.Networks)
+	}
+	if len(first.Interfaces) != 2 || first.Interfaces[0].DNS[0] != "10.147.33.1" {
+		t.Errorf("interfaces = %+v", first.Interfaces)
+	}
+	if len(first.Files) != 2 || !strings.HasSuffix(first.Files[0].Path, "99-ztsna0.network") || !strings.Contains(first.Files[0].Content, "DNS=10.147.33.1") {
+		t.Errorf("files = %+v", first.Files)
+	}
+	// A run changes what the system has, not what is desired
+	if err := runner.New(cfg, false).RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	a, _ := json.Marshal(first)
+	b, _ := json.Marshal(snapshot())
+	if string(a) != string(b) {
+		t.Errorf("snapshot changed after a run:\n%s\n%s", a, b)
+	}
+}
+
+func TestEmptyFilterKeepsManagedInterfaces(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztempty0", "10.147.36.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000018", Name: "empty", Interface: "ztempty0",
+		Servers: []string{"10.147.36.1"}, Domain: "empty.example",
+	})
+	cfg := h.Config("networkd")
+	cfg.Default.Networkd.Reconcile = true
+	cfg.Default.Safety.KeepOnEmptyFilter = true
+	if err := runner.New(cfg, false).RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	file := filepath.Join(h.NetworkdDir, "99-ztempty0.network")
+
+	// A filter that no longer matches the network, e.g. after it was renamed
+	cfg.Default.Filters = []map[string]interface{}{{"type": "name", "value": "renamed"}}
+	err := runner.New(cfg, false).RunOnce()
+	if code := exitcode.Code(err); code != exitcode.SafetyLimit {
+		t.Fatalf("RunOnce with an empty selection = %v (exit code %d), want exit code %d", err, code, exitcode.SafetyLimit)
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("aborted run removed %s: %v", file, err)
+	}
+	var out strings.Builder
+	metrics.Default().WriteOpenMetrics(&out)
+	if !strings.Contains(out.String(), `zeroplex_filters_empty{mode="networkd"} 1`) {
+		t.Errorf("zeroplex_filters_empty not set:\n%s", out.String())
+	}
+
+	// Without the setting the run goes ahead and removes the file
+	cfg.Default.Safety.KeepOnEmptyFilter = false
+	if err := runner.New(cfg, false).RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("%s kept, stat err: %v", file, err)
+	}
+}
+
+func TestFailedLinkIsRetriedOnItsOwn(t *testing.T) {
+	h := testharness.New(t)
+	h.AddZTInterface("ztretry0", "10.147.37.5/24")
+	h.API.SetNetworks(testharness.Network{
+		ID: "8056c2e21c000019", Name: "retry", Interface: "ztretry0",
+		Servers: []string{"10.147.37.1"}, Domain: "retry.example",
+	})
+	r := runner.New(h.Config("resolved"), false)
+
+	// systemd-resolved is restarting
+	h.FailLinkChanges(true)
+	r.RunOnce()
+	failure, failing := dns.LinkFailureOf("ztretry0")
+	if !failing || failure.Failures != 1 || strings.Join(failure.DNS, " ") != "10.147.37.1" {
+		t.Fatalf("failure of ztretry0 = %+v (recorded %v), want 1 failure applying 10.147.37.1", failure, failing)
+	}
+	if applied := r.RetryFailedLinks(); len(applied) != 0 {
+		t.Errorf("RetryFailedLinks while still failing = %v, want none", applied)
+	}
+	if failure, _ := dns.LinkFailureOf("ztretry0"); failure.Failures != 2 {
+		t.Errorf("failures after a failed retry = %d, want 2", failure.Failures)
+	}
+
+	// It is back: the retry applies just that interface's DNS, without a run
+	h.FailLinkChanges(false)
+	if applied := r.RetryFailedLinks(); strings.Join(applied, " ") != "ztretry0" {
+		t.Fatalf("RetryFailedLinks = %v, want [ztretry0]", applied)
+	}
+	if servers, domains := h.ResolvedLink("ztretry0"); strings.Join(servers, " ") != "10.147.37.1" || strings.Join(domains, " ") != "~retry.example" {
+		t.Errorf("link after the retry = %v %v, want [10.147.37.1] [~retry.example]", servers, domains)
+	}
+	if _, failing := dns.LinkFailureOf("ztretry0"); failing {
+		t.Errorf("ztretry0 still recorded as failing after the retry applied it")
+	}
+	var out strings.Builder
+	metrics.Default().WriteOpenMetrics(&out)
+	if !strings.Contains(out.String(), `zeroplex_link_apply_failures_total{interface="ztretry0"} 2`) ||
+		!strings.Contains(out.String(), `zeroplex_link_apply_consecutive_failures{interface="ztretry0"} 0`) {
+		t.Errorf("link failure metrics missing:\n%s", out.String())
+	}
+}
+
+func TestSystemdNotifyReadyReloadAndWatchdog(t *testing.T) {
+	h := testharness.New(t)
+	h.API.SetNetworks()
+
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", socket)
+	t.Setenv("WATCHDOG_USEC", "100000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+
+	cfg := h.Config("noop")
+	cfg.Default.Daemon.Enabled = true
+	cfg.Default.Daemon.PollInterval = "1h"
+	r := runner.New(cfg, false)
+	r.SetReloader(func() (config.Config, error) { return cfg, nil })
+	done := make(chan error, 1)
+	go func() { done <- r.RunDaemon() }()
+
+	// Waits for a notification holding want, returning it
+	await := func(want string) string {
+		t.Helper()
+		buf := make([]byte, 4096)
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			conn.SetReadDeadline(deadline)
+			n, err := conn.Read(buf)
+			if err != nil {
+				t.Fatalf("no notification with %s: %v", want, err)
+			}
+			if state := string(buf[:n]); strings.Contains(state, want) {
+				return state
+			}
+		}
+	}
+	if state := await("READY=1"); !strings.Contains(state, "STATUS=Last run at") {
+		t.Errorf("READY=1 sent as %q, want it with the status of the first run", state)
+	}
+	await("WATCHDOG=1")
+	await("WATCHDOG=1")
+
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if state := await("RELOADING=1"); !strings.Contains(state, "MONOTONIC_USEC=") {
+		t.Errorf("RELOADING=1 sent as %q, want it with MONOTONIC_USEC", state)
+	}
+	if state := await("READY=1"); !strings.Contains(state, "STATUS=Configuration reloaded") {
+		t.Errorf("READY=1 after the reload sent as %q", state)
+	}
+
+	syscall.Kill(os.Getpid(), syscall.SIGTERM)
+	await("STOPPING=1")
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("RunDaemon: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("daemon did not stop on SIGTERM")
+	}
+}
+
+func TestWatchdogExecExitCodes(t *testing.T) {
+	h := testharness.New(t)
+	h.API.SetNetworks()
+	// The probe does what the file says; once the test has removed it, it reports DNS healthy
+	behaviour := filepath.Join(t.TempDir(), "behaviour")
+	script := filepath.Join(h.Dir, "probe.sh")
+	if err := os.WriteFile(script, []byte(`#!/bin/sh
+case "$(cat "$1" 2>/dev/null)" in
+  fail) echo "resolver down"; exit 1 ;;
+  broken) echo "no dig"; exit 3 ;;
+  hang) sleep 30 ;;
+  linger) sleep 30 & exit 0 ;;
+esac
+exit 0
+`), 0755); err != nil {
+		t.Fatal(err)
+	}
+	set := func(b string) time.Time {
+		t.Helper()
+		if err := os.WriteFile(behaviour, []byte(b), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return time.Now()
+	}
+	set("fail")
+
+	cfg := h.Config("noop")
+	cfg.Default.Daemon.Enabled = true
+	cfg.Default.Daemon.PollInterval = "1h"
+	cfg.Default.Features.WatchdogExec = []string{script, behaviour}
+	cfg.Default.Features.WatchdogExecTimeout = "300ms"
+	cfg.Default.Features.WatchdogInterval = "100ms"
+	cfg.Default.Features.WatchdogBackoff = []string{"100ms"}
+	r := runner.New(cfg, false)
+	done := make(chan error, 1)
+	go func() { done <- r.RunDaemon() }()
+
+	// Waits for a check of the probe finished after since that matches want
+	await := func(what string, since time.Time, want func(control.Watchdog) bool) {
+		t.Helper()
+		var last control.Watchdog
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+			for _, wd := range r.Watchdogs() {
+				if wd.Kind == "exec" {
+					last = wd
+				}
+			}
+			if last.LastCheck.After(since) && want(last) {
+				return
+			}
+		}
+		t.Fatalf("%s: last check %+v", what, last)
+	}
+	await("exit status 1", time.Time{}, func(wd control.Watchdog) bool {
+		return !wd.Healthy && wd.LastError == "resolver down"
+	})
+	await("exit status 3", set("broken"), func(wd control.Watchdog) bool {
+		return !wd.Healthy && strings.Contains(wd.LastError, "exit status 3")
+	})
+	await("timeout", set("hang"), func(wd control.Watchdog) bool {
+		return !wd.Healthy && strings.Contains(wd.LastError, "timed out after 300ms")
+	})
+	// A process left holding the output of the probe doesn't hold up the watchdog
+	await("exit status 0 with a process in the background", set("linger"), func(wd control.Watchdog) bool {
+		return wd.Healthy
+	})
+	await("exit status 0", set("ok"), func(wd control.Watchdog) bool {
+		return wd.Healthy && wd.Failures == 0
+	})
+
+	syscall.Kill(os.Getpid(), syscall.SIGTERM)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("RunDaemon: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("daemon did not stop on SIGTERM")
+	}
+}
+
+func TestControlAndHealthTakeActivatedSockets(t *testing.T) {
+	// The sockets must be file descriptors 3 and up, so the daemon side runs in a child process
+	if listen := os.Getenv("ZEROPLEX_TEST_ACTIVATED"); listen != "" {
+		serveActivatedSockets(t, listen)
+		return
+	}
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "control.sock")
+	controlListener, err := net.ListenUnix("unix", &net.UnixAddr{Name: socket, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	controlListener.SetUnlinkOnClose(false)
+	healthListener, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []*os.File
+	for _, l := range []interface{ File() (*os.File, error) }{controlListener, healthListener} {
+		file, err := l.File()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		files = append(files, file)
+	}
+	address := healthListener.Addr().String()
+	controlListener.Close()
+	healthListener.Close()
+
+	// The control socket is found by its name, the health one by its address
+	cmd := exec.Command("sh", "-c", `LISTEN_PID=$$ exec "$0" -test.run '^TestControlAndHealthTakeActivatedSockets$' -test.v`, os.Args[0])
+	cmd.Env = append(os.Environ(), "LISTEN_FDS=2", "LISTEN_FDNAMES=control:zeroplex-health.socket",
+		"ZEROPLEX_TEST_ACTIVATED="+filepath.Join(dir, "configured.sock")+","+address)
+	cmd.ExtraFiles = files
+	var output strings.Builder
+	cmd.Stdout, cmd.Stderr = &output, &output
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+
+	if _, err := control.NewClient(socket).Status(context.Background()); err != nil {
+		t.Errorf("Status over the activated socket: %v", err)
+	}
+	if resp, err := http.Get("http://" + address + "/healthz"); err != nil {
+		t.Errorf("/healthz over the activated socket: %v", err)
+	} else {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("/healthz = %d, want 200", resp.StatusCode)
+		}
+	}
+
+	cmd.Process.Signal(syscall.SIGTERM)
+	if err := cmd.Wait(); err != nil {
+		t.Errorf("child: %v\n%s", err, output.String())
+	}
+	if _, err := os.Stat(socket); err != nil {
+		t.Errorf("activated socket removed on stop: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "configured.sock")); !os.IsNotExist(err) {
+		t.Errorf("configured socket created next to the activated one: %v", err)
+	}
+}
+
+// serveActivatedSockets serves the control API on the configured socket and the health
+// endpoints on the configured address of listen until SIGTERM
+func serveActivatedSockets(t *testing.T, listen string) {
+	h := testharness.New(t)
+	h.API.SetNetworks()
+	socket, address, _ := strings.Cut(listen, ",")
+	cfg := h.Config("noop")
+	cfg.Default.Control.Socket = socket
+	cfg.Default.Health.Listen = address
+	r := runner.New(cfg, false)
+	stopControl, err := r.StartControl()
+	if err != nil {
+		t.Fatalf("StartControl: %v", err)
+	}
+	stopHealth, err := r.StartHealth()
+	if err != nil {
+		t.Fatalf("StartHealth: %v", err)
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Error("LISTEN_FDS left set for the commands zeroplex runs")
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM)
+	<-signals
+	stopControl()
+	stopHealth()
+}
+
+func TestPrivilegeCheckWithoutRoot(t *testing.T) {
+	// The check runs as nobody, in a child process
+	if mode := os.Getenv("ZEROPLEX_TEST_UNPRIVILEGED"); mode != "" {
+		cfg := config.DefaultConfig()
+		cfg.Default.Mode = mode
+		cfg.Default.StateDir = os.Getenv("ZEROPLEX_TEST_STATE_DIR")
+		missing, _ := json.Marshal(privilege.Check(cfg))
+		fmt.Printf("MISSING %s\n", missing)
+		return
+	}
+	// nobody must be able to run the test binary, which go test keeps in a private directory
+	dir, err := os.MkdirTemp("", "zeroplex-unprivileged-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Chmod(dir, 0755)
+	binary := filepath.Join(dir, "runner.test")
+	content, err := os.ReadFile(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(binary, content, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writable := filepath.Join(dir, "state")
+	if err := os.Mkdir(writable, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chown(writable, 65534, 65534); err != nil {
+		t.Fatal(err)
+	}
+	locked := filepath.Join(dir, "locked")
+	if err := os.Mkdir(locked, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(mode, stateDir string, caps ...uintptr) []string {
+		t.Helper()
+		cmd := exec.Command(binary, "-test.run", "^TestPrivilegeCheckWithoutRoot$")
+		cmd.Env = append(os.Environ(), "ZEROPLEX_TEST_UNPRIVILEGED="+mode, "ZEROPLEX_TEST_STATE_DIR="+stateDir, "DBUS_SYSTEM_BUS_ADDRESS=unix:path="+filepath.Join(dir, "no-bus"))
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: &syscall.Credential{Uid: 65534, Gid: 65534}, AmbientCaps: caps}
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("child: %v\n%s", err, output)
+		}
+		for _, line := range strings.Split(string(output), "\n") {
+			if encoded, ok := strings.CutPrefix(line, "MISSING "); ok {
+				var missing []string
+				if err := json.Unmarshal([]byte(encoded), &missing); err != nil {
+					t.Fatal(err)
+				}
+				return missing
+			}
+		}
+		t.Fatalf("no result from the child:\n%s", output)
+		return nil
+	}
+	const capNetAdmin = 12
+	if missing := check("noop", writable); len(missing) != 0 {
+		t.Errorf("noop with a writable state directory misses %q", missing)
+	}
+	if missing := check("noop", filepath.Join(locked, "state")); len(missing) != 1 || !strings.Contains(missing[0], "state directory") {
+		t.Errorf("noop with a locked state directory misses %q, want the state directory", missing)
+	}
+	if missing := check("resolved", writable); len(missing) != 1 || !strings.HasPrefix(missing[0], "CAP_NET_ADMIN, or polkit") {
+		t.Errorf("resolved without CAP_NET_ADMIN or polkit misses %q", missing)
+	}
+	if missing := check("resolved", writable, capNetAdmin); len(missing) != 0 {
+		t.Errorf("resolved with CAP_NET_ADMIN misses %q", missing)
+	}
+	if missing := check("openwrt", writable); len(missing) != 1 || missing[0] != "root for mode openwrt" {
+		t.Errorf("openwrt misses %q, want root", missing)
+	}
+	if err := privilege.Error([]string{"a", "b"}); exitcode.Code(err) != exitcode.Privilege || !strings.Contains(err.Error(), "missing a; b") {
+		t.Errorf("Error = %v (code %d)", err, exitcode.Code(err))
+	}
+}
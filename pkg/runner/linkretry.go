@@ -0,0 +1,134 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package runner
+
+import (
+	"zeroplex/pkg/dns"
+
+	"context"
+	"sync"
+	"time"
+)
+
+// linkRetries tracks the interfaces being retried after their DNS failed to apply
+type linkRetries struct {
+	mu     sync.Mutex
+	active map[string]bool
+}
+
+// linkRetryDelay returns how long to wait before retry attempt (counting from 0) of an interface,
+// by interface_watch.retry: its backoff list, or else delay doubled each attempt up to a minute for
+// count attempts. It returns false once the attempts are used up.
+func (r *Runner) linkRetryDelay(attempt int) (time.Duration, bool) {
+	retryCfg := r.config().Default.InterfaceWatch.Retry
+	if len(retryCfg.Backoff) > 0 {
+		if attempt >= len(retryCfg.Backoff) {
+			return 0, false
+		}
+		d, err := time.ParseDuration(retryCfg.Backoff[attempt])
+		if err != nil || d <= 0 {
+			d = 2 * time.Second
+		}
+		return d, true
+	}
+	if attempt >= retryCfg.Count {
+		return 0, false
+	}
+	baseDelay, err := time.ParseDuration(retryCfg.Delay)
+	if err != nil || baseDelay <= 0 {
+		baseDelay = 2 * time.Second
+	}
+	d := baseDelay << attempt
+	if d > time.Minute || d <= 0 {
+		d = time.Minute
+	}
+	return d, true
+}
+
+// startLinkRetries starts a retry of every interface whose DNS failed to apply in the last run and
+// isn't being retried yet, so a resolver that was briefly unavailable (systemd-resolved restarting)
+// is caught up with before the next poll
+func (r *Runner) startLinkRetries() {
+	for _, failure := range dns.LinkFailures() {
+		r.linkRetry.mu.Lock()
+		if r.linkRetry.active == nil {
+			r.linkRetry.active = map[string]bool{}
+		}
+		if r.linkRetry.active[failure.Interface] {
+			r.linkRetry.mu.Unlock()
+			continue
+		}
+		r.linkRetry.active[failure.Interface] = true
+		r.linkRetry.mu.Unlock()
+		go r.retryLink(failure.Interface)
+	}
+}
+
+// retryLink reapplies the DNS of one interface with backoff until it succeeds, a run applies it,
+// or the attempts are used up
+func (r *Runner) retryLink(iface string) {
+	defer r.recoverHandler("link retry")
+	defer func() {
+		r.linkRetry.mu.Lock()
+		delete(r.linkRetry.active, iface)
+		r.linkRetry.mu.Unlock()
+	}()
+	for attempt := 0; ; attempt++ {
+		d, ok := r.linkRetryDelay(attempt)
+		if !ok {
+			if failure, failing := dns.LinkFailureOf(iface); failing {
+				r.logger.Warn("DNS for %s still fails after %d attempt(s), leaving it to the next run: %s", iface, failure.Failures, failure.LastError)
+			}
+			return
+		}
+		time.Sleep(d)
+		failure, failing := dns.LinkFailureOf(iface)
+		if !failing {
+			// A run or another trigger applied it in the meantime
+			return
+		}
+		if r.daemon == nil || !r.daemon.IsRunning() || r.daemon.IsPaused() {
+			return
+		}
+		if _, disabled := AdminDisabled(); disabled {
+			r.logger.Verbose("Not retrying DNS for %s, administratively disabled by %s", iface, DisableFile)
+			return
+		}
+		if window, deferring := r.config().Default.Maintenance.Deferring(time.Now()); deferring {
+			r.logger.Verbose("Not retrying DNS for %s inside maintenance window %s", iface, window)
+			return
+		}
+		r.logger.Verbose("Retrying DNS for %s (attempt %d, %d failure(s) so far)", iface, attempt+1, failure.Failures)
+		err := r.reapplyLink(failure)
+		if err == nil {
+			r.logger.Info("DNS applied to %s on retry %d, after failing for %s", iface, attempt+1, time.Since(failure.Since).Round(time.Second))
+			return
+		}
+		r.logger.Debug("Retry %d of %s failed: %v", attempt+1, iface, err)
+	}
+}
+
+// reapplyLink applies the DNS a failed attempt was to apply to its interface, and nothing else
+func (r *Runner) reapplyLink(failure dns.LinkFailure) error {
+	defer dns.LockInterface(failure.Interface)()
+	return dns.ConfigureDNSAndSearchDomains(context.Background(), failure.Interface, failure.DNS, failure.Domains, false, r.config().Default.Log.Level)
+}
+
+// RetryFailedLinks makes one attempt at every interface whose DNS failed to apply, and returns those
+// it applied to. It makes none while DisableFile exists.
+func (r *Runner) RetryFailedLinks() []string {
+	var applied []string
+	if _, disabled := AdminDisabled(); disabled {
+		return applied
+	}
+	for _, failure := range dns.LinkFailures() {
+		if err := r.reapplyLink(failure); err == nil {
+			applied = append(applied, failure.Interface)
+		} else {
+			r.logger.Debug("Retry of %s failed: %v", failure.Interface, err)
+		}
+	}
+	return applied
+}
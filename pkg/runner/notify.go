@@ -0,0 +1,68 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package runner
+
+import (
+	"zeroplex/pkg/daemon"
+	"zeroplex/pkg/initsys"
+	"zeroplex/pkg/modes"
+
+	"fmt"
+	"strings"
+	"time"
+)
+
+// sdNotify sends state to systemd when the daemon runs in a Type=notify unit
+func (r *Runner) sdNotify(state ...string) {
+	if _, err := initsys.Notify(strings.Join(state, "\n")); err != nil {
+		r.logger.Debug("Failed to notify the service manager: %v", err)
+	}
+}
+
+// notifyRun tells systemd the outcome of a daemon run as STATUS=, with READY=1 once the first run
+// succeeds, so units ordered after zeroplex start with the DNS of the networks in place
+func (r *Runner) notifyRun(err error) {
+	at := time.Now().Format("15:04:05")
+	if err != nil {
+		r.sdNotify(fmt.Sprintf("STATUS=Last run at %s failed: %v", at, err))
+		return
+	}
+	s := modes.Summary()
+	status := fmt.Sprintf("STATUS=Last run at %s: %d networks, %d applied, %d unchanged", at, s.Networks, s.Applied, s.Unchanged)
+	if r.notifiedReady.CompareAndSwap(false, true) {
+		r.logger.Debug("Telling the service manager zeroplex is ready")
+		r.sdNotify("READY=1", status)
+		return
+	}
+	r.sdNotify(status)
+}
+
+// notifyReloading tells systemd a reload started, and returns the func telling it the reload is over
+func (r *Runner) notifyReloading() func(error) {
+	r.sdNotify("RELOADING=1", "STATUS=Reloading the configuration", fmt.Sprintf("MONOTONIC_USEC=%d", initsys.MonotonicUsec()))
+	return func(err error) {
+		status := "STATUS=Configuration reloaded"
+		if err != nil {
+			status = fmt.Sprintf("STATUS=Kept the running configuration: %v", err)
+		}
+		if !r.notifiedReady.Load() {
+			// Still starting up: READY=1 waits for the first run to succeed
+			r.sdNotify(status)
+			return
+		}
+		r.sdNotify("READY=1", status)
+	}
+}
+
+// startWatchdog pings the systemd watchdog from the scheduler loop at half of WatchdogSec=, so
+// systemd restarts a daemon whose runs hang
+func (r *Runner) startWatchdog(scheduler *daemon.Scheduled) {
+	interval, ok := initsys.WatchdogInterval()
+	if !ok {
+		return
+	}
+	r.logger.Verbose("Pinging the systemd watchdog every %s (WatchdogSec=%s)", interval/2, interval)
+	scheduler.SetHeartbeat(interval/2, func() { r.sdNotify("WATCHDOG=1") })
+}
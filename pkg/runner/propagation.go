@@ -0,0 +1,122 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package runner
+
+import (
+	"zeroplex/pkg/metrics"
+
+	"sync"
+	"time"
+)
+
+// recoveryBuckets are the histogram buckets (in seconds) of zeroplex_dns_recovery_seconds; DNS
+// normally comes back within seconds of a resume, but waiting for ZeroTier to rejoin takes longer
+var recoveryBuckets = []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300, 600}
+
+// propagationTracker measures how long DNS takes to work again after a resume, interface or
+// watchdog trigger, from the trigger to the first successful verification
+type propagationTracker struct {
+	mu      sync.Mutex
+	pending map[Trigger]time.Time
+	stats   map[Trigger]*recoveryStats
+	wake    chan struct{} // closed to cut short the watchdogs' wait between checks
+}
+
+// recoveryStats summarises the recoveries measured for one trigger
+type recoveryStats struct {
+	count int
+	total time.Duration
+	max   time.Duration
+}
+
+// startPropagation starts the recovery clock for trigger. The clock of a trigger that has not
+// recovered yet keeps running, so a storm of events is measured from its first event.
+func (r *Runner) startPropagation(trigger Trigger) {
+	p := &r.propagation
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pending == nil {
+		p.pending = map[Trigger]time.Time{}
+	}
+	if _, ok := p.pending[trigger]; !ok {
+		p.pending[trigger] = time.Now()
+	}
+}
+
+// runSucceeded is called after every successful reconcile run. Without a watchdog the run (and the
+// apply verification it includes) is the verification; with one, the watchdogs are woken so their
+// next check confirms the recovery without waiting for the rest of their interval.
+func (r *Runner) runSucceeded() {
+	p := &r.propagation
+	p.mu.Lock()
+	if len(p.pending) == 0 {
+		p.mu.Unlock()
+		return
+	}
+	if r.watchdogConfigured() {
+		if p.wake != nil {
+			close(p.wake)
+			p.wake = nil
+		}
+		p.mu.Unlock()
+		return
+	}
+	p.mu.Unlock()
+	r.finishPropagation("run", TriggerResume, TriggerInterface)
+}
+
+// watchdogHealthy is called after every successful watchdog check and ends all pending clocks
+func (r *Runner) watchdogHealthy() {
+	r.finishPropagation("watchdog", TriggerResume, TriggerInterface, TriggerWatchdog)
+}
+
+// finishPropagation records the recovery time of every pending trigger among triggers
+func (r *Runner) finishPropagation(verifiedBy string, triggers ...Trigger) {
+	p := &r.propagation
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, trigger := range triggers {
+		started, ok := p.pending[trigger]
+		if !ok {
+			continue
+		}
+		delete(p.pending, trigger)
+		took := time.Since(started)
+		if p.stats == nil {
+			p.stats = map[Trigger]*recoveryStats{}
+		}
+		stats := p.stats[trigger]
+		if stats == nil {
+			stats = &recoveryStats{}
+			p.stats[trigger] = stats
+		}
+		stats.count++
+		stats.total += took
+		if took > stats.max {
+			stats.max = took
+		}
+		metrics.Default().Observe("zeroplex_dns_recovery_seconds", "Time from a resume, interface or watchdog trigger until DNS was verified working again",
+			took.Seconds(), recoveryBuckets, metrics.Labels{"trigger": string(trigger)})
+		r.logger.Info("DNS recovered %s after %s (verified by %s); %d %s recoveries so far, average %s, slowest %s",
+			took.Round(100*time.Millisecond), trigger, verifiedBy, stats.count, trigger,
+			(stats.total / time.Duration(stats.count)).Round(100*time.Millisecond), stats.max.Round(100*time.Millisecond))
+	}
+}
+
+// watchdogSleep waits d between watchdog checks, returning early when a run succeeded while a
+// recovery was being measured
+func (r *Runner) watchdogSleep(d time.Duration) {
+	p := &r.propagation
+	p.mu.Lock()
+	if p.wake == nil {
+		p.wake = make(chan struct{})
+	}
+	wake := p.wake
+	p.mu.Unlock()
+	select {
+	case <-time.After(d):
+	case <-wake:
+	}
+}
@@ -0,0 +1,292 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package runner
+
+import (
+	"zeroplex/pkg/client"
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/utils"
+
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/zerotier/go-zerotier-one/service"
+)
+
+// dnsProbeTimeout bounds the query the dns-probe readiness strategy sends to each DNS server
+const dnsProbeTimeout = 2 * time.Second
+
+// readinessStrategy decides whether DNS can be applied to the interface of a joined network
+type readinessStrategy interface {
+	// ready reports whether iface, which is up, is ready, with a status naming what it waits for if not
+	ready(ctx context.Context, iface *net.Interface, network service.Network) (bool, string, error)
+}
+
+// apiStatusReadiness waits for ZeroTier to report the network OK with DNS servers pushed
+type apiStatusReadiness struct{}
+
+func (apiStatusReadiness) ready(ctx context.Context, iface *net.Interface, network service.Network) (bool, string, error) {
+	status := utils.GetString(network.Status)
+	if status == "OK" && network.Dns != nil && network.Dns.Servers != nil && len(*network.Dns.Servers) > 0 {
+		return true, status, nil
+	}
+	return false, status, nil
+}
+
+// addressPresentReadiness waits for an address ZeroTier assigned to be configured on the interface.
+// On Linux an IPv6 address only counts once duplicate address detection is done with it, since
+// nothing can be sent from it while it is tentative.
+type addressPresentReadiness struct{}
+
+func (addressPresentReadiness) ready(ctx context.Context, iface *net.Interface, network service.Network) (bool, string, error) {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return false, "addrs_error", fmt.Errorf("failed to list the addresses of %s: %w", iface.Name, err)
+	}
+	unusable, err := utils.UnusableAddresses(iface.Index)
+	if err != nil {
+		return false, "addrs_error", fmt.Errorf("failed to list the addresses of %s: %w", iface.Name, err)
+	}
+	tentative := false
+	if network.AssignedAddresses != nil {
+		for _, cidr := range *network.AssignedAddresses {
+			assigned := utils.ParseIP(cidr)
+			for _, addr := range addrs {
+				ipnet, ok := addr.(*net.IPNet)
+				if !ok || !ipnet.IP.Equal(assigned) {
+					continue
+				}
+				if containsNetIP(unusable, assigned) {
+					tentative = true
+					continue
+				}
+				return true, "address_present", nil
+			}
+		}
+	}
+	if tentative {
+		return false, "address_tentative", nil
+	}
+	return false, "no_address", nil
+}
+
+// containsNetIP reports whether ip is one of ips
+func containsNetIP(ips []net.IP, ip net.IP) bool {
+	for _, other := range ips {
+		if other.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// routePresentReadiness waits for the routes ZeroTier pushes for the network itself (those without
+// a gateway) to be in the routing table through the interface, or for any route through it if
+// there are none. The routing table is only read on Linux; elsewhere it waits for an address.
+type routePresentReadiness struct{}
+
+func (routePresentReadiness) ready(ctx context.Context, iface *net.Interface, network service.Network) (bool, string, error) {
+	if runtime.GOOS != "linux" {
+		return addressPresentReadiness{}.ready(ctx, iface, network)
+	}
+	routes, err := utils.InterfaceRoutes(iface.Index)
+	if err != nil {
+		return false, "routes_error", fmt.Errorf("failed to list the routes through %s: %w", iface.Name, err)
+	}
+	// The kernel adds fe80::/64 and ff00::/8 to every interface with IPv6, so they don't show that
+	// ZeroTier has configured anything
+	installed := map[string]bool{}
+	for _, dst := range routes {
+		if dst.IP.To4() == nil && (dst.IP.IsLinkLocalUnicast() || dst.IP.IsMulticast()) {
+			continue
+		}
+		installed[dst.String()] = true
+	}
+	var wanted []string
+	if network.Routes != nil {
+		for _, route := range *network.Routes {
+			if route.Via != nil && *route.Via != "" {
+				continue
+			}
+			if _, target, err := net.ParseCIDR(utils.GetString(route.Target)); err == nil {
+				wanted = append(wanted, target.String())
+			}
+		}
+	}
+	if len(wanted) == 0 {
+		if len(installed) > 0 {
+			return true, "route_present", nil
+		}
+		return false, "no_route", nil
+	}
+	for _, target := range wanted {
+		if !installed[target] {
+			return false, "no_route", nil
+		}
+	}
+	return true, "route_present", nil
+}
+
+// dnsProbeReadiness waits for one of the network's DNS servers to answer a query for its domain
+// (or the root zone), through the interface's routes. Any answer counts, including NXDOMAIN. A
+// link-local IPv6 server is reached through the interface itself.
+type dnsProbeReadiness struct{}
+
+func (dnsProbeReadiness) ready(ctx context.Context, iface *net.Interface, network service.Network) (bool, string, error) {
+	if network.Dns == nil || network.Dns.Servers == nil || len(*network.Dns.Servers) == 0 {
+		return false, "no_dns_servers", nil
+	}
+	name := strings.TrimSuffix(utils.GetString(network.Dns.Domain), ".") + "."
+	var lastErr error
+	for _, server := range *network.Dns.Servers {
+		if ip := net.ParseIP(server); ip != nil && ip.To4() == nil && ip.IsLinkLocalUnicast() {
+			server += "%" + iface.Name
+		}
+		resolver := &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, proto, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, proto, net.JoinHostPort(server, "53"))
+			},
+		}
+		probeCtx, cancel := context.WithTimeout(ctx, dnsProbeTimeout)
+		_, err := resolver.LookupNS(probeCtx, name)
+		cancel()
+		var dnsErr *net.DNSError
+		if err == nil || (errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+			return true, "dns_answered", nil
+		}
+		lastErr = err
+	}
+	// An unanswered probe means the interface isn't ready yet, not that the check failed
+	return false, fmt.Sprintf("dns_unanswered (%v)", lastErr), nil
+}
+
+// compositeReadiness waits for every one of its strategies
+type compositeReadiness struct {
+	names      []string
+	strategies []readinessStrategy
+}
+
+func (c compositeReadiness) ready(ctx context.Context, iface *net.Interface, network service.Network) (bool, string, error) {
+	for i, strategy := range c.strategies {
+		ready, status, err := strategy.ready(ctx, iface, network)
+		if err != nil || !ready {
+			return false, c.names[i] + ": " + status, err
+		}
+	}
+	return true, strings.Join(c.names, "+"), nil
+}
+
+// newReadinessStrategy returns the config.ReadinessStrategies strategy called name; checks are the
+// strategies a composite one combines
+func newReadinessStrategy(name string, checks []string) readinessStrategy {
+	switch name {
+	case "address-present":
+		return addressPresentReadiness{}
+	case "route-present":
+		return routePresentReadiness{}
+	case "dns-probe":
+		return dnsProbeReadiness{}
+	case "composite":
+		composite := compositeReadiness{}
+		for _, check := range checks {
+			composite.names = append(composite.names, check)
+			composite.strategies = append(composite.strategies, newReadinessStrategy(check, nil))
+		}
+		return composite
+	}
+	return apiStatusReadiness{}
+}
+
+// networkReadiness returns the readiness strategy of the network with ID networkID, set for it under
+// interface_watch.networks or else by interface_watch.readiness
+func networkReadiness(watch config.InterfaceWatch, networkID string) readinessStrategy {
+	name, checks := watch.Readiness, watch.Checks
+	if network, ok := watch.Networks[networkID]; ok {
+		if network.Readiness != "" {
+			name = network.Readiness
+		}
+		if len(network.Checks) > 0 {
+			checks = network.Checks
+		}
+	}
+	return newReadinessStrategy(name, checks)
+}
+
+// InterfaceReady reports whether DNS can be applied to the ZeroTier interface ifaceName, with its
+// readiness status
+func (r *Runner) InterfaceReady(ifaceName string) (bool, string, error) {
+	return isZTInterfaceReady(r.ztClient(), ifaceName, r.config().Default.InterfaceWatch)
+}
+
+// isZTInterfaceReady checks that the ZeroTier interface is up and ready by the readiness strategy of
+// its network. A network set to dual_stack also needs both an IPv4 and an IPv6 address assigned.
+func isZTInterfaceReady(zt *client.Client, ifaceName string, watch config.InterfaceWatch) (bool, string, error) {
+	iface, err := net.InterfaceByName(ifaceName)
+	if err != nil {
+		return false, "iface_not_found", fmt.Errorf("interface %s not found: %w", ifaceName, err)
+	}
+	if iface.Flags&net.FlagUp == 0 {
+		return false, "iface_down", fmt.Errorf("interface %s exists but is down", ifaceName)
+	}
+
+	ctx := context.Background()
+	resp, err := zt.Networks(ctx)
+	if err != nil {
+		return false, "api_unreachable", fmt.Errorf("ZeroTier API unreachable: %w (iface %s is up)", err, ifaceName)
+	}
+	if resp.JSON200 == nil {
+		return false, "api_error", fmt.Errorf("ZeroTier API returned %s", resp.Status())
+	}
+	for _, nw := range *resp.JSON200 {
+		if utils.GetString(nw.PortDeviceName) != ifaceName {
+			continue
+		}
+		id := utils.GetString(nw.Id)
+		ready, status, err := networkReadiness(watch, id).ready(ctx, iface, nw)
+		if err != nil || !ready {
+			return false, status, err
+		}
+		if watch.Networks[id].DualStack {
+			if missing := missingAddressFamily(nw.AssignedAddresses); missing != "" {
+				return false, "no_" + missing, nil
+			}
+		}
+		return true, status, nil
+	}
+	return false, "not_found", nil
+}
+
+// missingAddressFamily returns "ipv4" or "ipv6" if no address of that family is among the assigned
+// addresses, or "" if both are
+func missingAddressFamily(assigned *[]string) string {
+	var v4, v6 bool
+	if assigned != nil {
+		for _, cidr := range *assigned {
+			ip := net.ParseIP(strings.SplitN(cidr, "/", 2)[0])
+			if ip == nil {
+				continue
+			}
+			if ip.To4() != nil {
+				v4 = true
+			} else {
+				v6 = true
+			}
+		}
+	}
+	if !v4 {
+		return "ipv4"
+	}
+	if !v6 {
+		return "ipv6"
+	}
+	return ""
+}
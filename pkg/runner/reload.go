@@ -0,0 +1,143 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package runner
+
+import (
+	"zeroplex/pkg/client"
+	"zeroplex/pkg/config"
+	"zeroplex/pkg/log"
+	"zeroplex/pkg/modes"
+	"zeroplex/pkg/utils"
+
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// SetReloader sets how Reload reads the configuration again: the same files, profile and command
+// line flags the daemon was started with. paths are the files and configuration directories it
+// reads, which daemon.watch_config watches.
+func (r *Runner) SetReloader(load func() (config.Config, error), paths ...string) {
+	r.reload = load
+	r.configPaths = paths
+}
+
+// SetVersion sets the version of the binary reported in the control status
+func (r *Runner) SetVersion(version string) {
+	r.version = version
+}
+
+// Reload re-reads and validates the configuration and applies it to the running daemon, then
+// reconciles. An invalid configuration is rejected and the current one kept. Settings that are
+// only read at startup keep their current values until a restart, with a warning naming them.
+func (r *Runner) Reload() (err error) {
+	if r.reload == nil {
+		return errors.New("this instance cannot reload its configuration")
+	}
+	// SIGHUP, a file change and the management APIs can ask at the same time
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+	if r.daemon != nil {
+		reloaded := r.notifyReloading()
+		defer func() { reloaded(err) }()
+	}
+	next, err := r.reload()
+	if err != nil {
+		r.logger.Warn("Not reloading the configuration: %v", err)
+		return err
+	}
+	if kept := keepStartupSettings(&r.config().Default, &next.Default); len(kept) > 0 {
+		r.logger.Warn("Changes to %s take effect after a restart", strings.Join(kept, ", "))
+	}
+
+	// A new client, so its cached network list and its address and token come from next
+	r.zt.Store(client.New(next.Default.Client))
+	r.cfg.Store(&next)
+	log.SetLevel(next.Default.Log.Level)
+	r.configureEvents()
+	if interval, err := utils.ParseInterval(next.Default.Daemon.PollInterval); err == nil {
+		r.SetPollInterval(interval)
+	}
+	r.logger.Info("Configuration reloaded")
+	if err := r.RequestRun(TriggerReload); err != nil {
+		return r.executeTask(withTrigger(context.Background(), TriggerReload))
+	}
+	return nil
+}
+
+// keepStartupSettings copies the settings the daemon only reads at startup from current to next,
+// returning the keys whose new values were not taken over
+func keepStartupSettings(current, next *config.Profile) []string {
+	var kept []string
+	if next.Mode != "auto" && !strings.EqualFold(next.Mode, current.Mode) {
+		kept = append(kept, "mode")
+	}
+	// The running mode may also have been detected from auto
+	next.Mode = current.Mode
+	// The poll interval is the one daemon setting that can change at runtime
+	daemon := current.Daemon
+	daemon.PollInterval = next.Daemon.PollInterval
+	if daemon != next.Daemon {
+		kept = append(kept, "daemon")
+		next.Daemon = daemon
+	}
+	for _, setting := range []struct {
+		key           string
+		current, next interface{}
+	}{
+		{"control", &current.Control, &next.Control},
+		{"health", &current.Health, &next.Health},
+		{"grpc", &current.GRPC, &next.GRPC},
+		{"hardening", &current.Hardening, &next.Hardening},
+		{"state_dir", &current.StateDir, &next.StateDir},
+		{"interface_watch", &current.InterfaceWatch, &next.InterfaceWatch},
+		{"resolv_watch", &current.ResolvWatch, &next.ResolvWatch},
+		{"init_system", &current.InitSystem, &next.InitSystem},
+		{"features.watchdog_ip", &current.Features.WatchdogIP, &next.Features.WatchdogIP},
+		{"features.watchdog_interval", &current.Features.WatchdogInterval, &next.Features.WatchdogInterval},
+		{"features.watchdog_backoff", &current.Features.WatchdogBackoff, &next.Features.WatchdogBackoff},
+		{"features.watchdog_hostname", &current.Features.WatchdogHostname, &next.Features.WatchdogHostname},
+		{"features.watchdog_expected_ip", &current.Features.WatchdogExpectedIP, &next.Features.WatchdogExpectedIP},
+		{"features.watchdog_networks", &current.Features.WatchdogNetworks, &next.Features.WatchdogNetworks},
+		{"features.watchdog_exec", &current.Features.WatchdogExec, &next.Features.WatchdogExec},
+		{"features.watchdog_exec_timeout", &current.Features.WatchdogExecTimeout, &next.Features.WatchdogExecTimeout},
+	} {
+		have := reflect.ValueOf(setting.current).Elem()
+		want := reflect.ValueOf(setting.next).Elem()
+		if !reflect.DeepEqual(have.Interface(), want.Interface()) {
+			kept = append(kept, setting.key)
+			want.Set(have)
+		}
+	}
+	return kept
+}
+
+// Apply resumes scheduled runs if a restore paused them and reconciles immediately
+func (r *Runner) Apply() error {
+	r.Resume()
+	if err := r.RequestRun(TriggerManual); err != nil {
+		return r.executeTask(withTrigger(context.Background(), TriggerManual))
+	}
+	return nil
+}
+
+// RestoreAll reverts every managed interface and pauses scheduled runs until the next Apply,
+// returning the restored interfaces. A run in progress finishes first, so it can't reapply what
+// was just restored. It is refused while DisableFile exists.
+func (r *Runner) RestoreAll() ([]string, error) {
+	if err := DisabledError(); err != nil {
+		return nil, err
+	}
+	r.Pause()
+	r.runMu.Lock()
+	restored := modes.RestoreManaged(*r.config(), r.dryRun)
+	r.runMu.Unlock()
+	r.announceState()
+	if restored == nil {
+		restored = []string{}
+	}
+	return restored, nil
+}
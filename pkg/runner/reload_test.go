@@ -0,0 +1,48 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package runner
+
+import (
+	"zeroplex/pkg/config"
+
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestKeepStartupSettings(t *testing.T) {
+	current := config.Profile{Mode: "resolved"}
+	current.Daemon.PollInterval = "1m"
+	current.Control.Socket = "/run/zeroplex/control.sock"
+	current.Features.WatchdogIP = "10.147.20.1"
+
+	next := current
+	next.Mode = "auto"
+	next.Daemon.PollInterval = "5m"
+	next.Control.Socket = "/nonexistent/control.sock"
+	next.ConfigEpoch = "reloaded"
+	if kept := keepStartupSettings(&current, &next); len(kept) != 1 || kept[0] != "control" {
+		t.Errorf("kept = %v, want [control]", kept)
+	}
+	if next.Control.Socket != current.Control.Socket {
+		t.Errorf("control.socket = %s, want the running %s", next.Control.Socket, current.Control.Socket)
+	}
+	// auto keeps the detected mode without a warning, the rest is taken over
+	if next.Mode != "resolved" || next.Daemon.PollInterval != "5m" || next.ConfigEpoch != "reloaded" {
+		t.Errorf("mode %s, poll interval %s, epoch %s; want resolved, 5m, reloaded", next.Mode, next.Daemon.PollInterval, next.ConfigEpoch)
+	}
+
+	next = current
+	next.Mode = "networkd"
+	next.Daemon.DBus = true
+	next.Features.WatchdogIP = "10.147.20.2"
+	kept := keepStartupSettings(&current, &next)
+	if want := []string{"mode", "daemon", "features.watchdog_ip"}; !slices.Equal(kept, want) {
+		t.Errorf("kept = %v, want %v", kept, want)
+	}
+	if !reflect.DeepEqual(next, current) {
+		t.Errorf("profile after keeping = %+v, want the running one", next)
+	}
+}
@@ -0,0 +1,153 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package runner
+
+import (
+	"zeroplex/pkg/events"
+	"zeroplex/pkg/metrics"
+	"zeroplex/pkg/modes"
+	"zeroplex/pkg/utils"
+
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resolvedStubFile is the resolv.conf systemd-resolved generates pointing at its stub listener
+const resolvedStubFile = "/run/systemd/resolve/stub-resolv.conf"
+
+// resolvWatch remembers the last seen version of each watched resolver file
+type resolvWatch struct {
+	mu   sync.Mutex
+	seen map[string]string // path -> resolvFingerprint
+}
+
+// resolvWatchPaths returns the files resolv_watch watches
+func (r *Runner) resolvWatchPaths() []string {
+	if paths := r.config().Default.ResolvWatch.Paths; len(paths) > 0 {
+		return paths
+	}
+	return []string{modes.ResolvConfPath, resolvedStubFile}
+}
+
+// resolvFingerprint identifies the version of a file: where its symlink points and what it holds.
+// Missing files have an empty fingerprint, so their appearance is a change too.
+func resolvFingerprint(path string) string {
+	target, _ := os.Readlink(path)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return target
+	}
+	sum := sha256.Sum256(content)
+	return target + ":" + hex.EncodeToString(sum[:])
+}
+
+// rebaseResolvWatch records the current version of every watched file, so changes zeroplex makes
+// itself during a run are not reported as rewrites
+func (r *Runner) rebaseResolvWatch() {
+	if !r.config().Default.ResolvWatch.Enabled {
+		return
+	}
+	r.resolvWatch.mu.Lock()
+	defer r.resolvWatch.mu.Unlock()
+	r.resolvWatch.seen = map[string]string{}
+	for _, path := range r.resolvWatchPaths() {
+		r.resolvWatch.seen[path] = resolvFingerprint(path)
+	}
+}
+
+// CheckResolvConf compares the watched files with the versions recorded after the last run,
+// reports the ones rewritten by other software and, with resolv_watch.reassert, starts a run to
+// put the managed settings back. It returns the rewritten paths.
+func (r *Runner) CheckResolvConf() []string {
+	r.resolvWatch.mu.Lock()
+	if r.resolvWatch.seen == nil {
+		r.resolvWatch.mu.Unlock()
+		r.rebaseResolvWatch()
+		return nil
+	}
+	var changed []string
+	for _, path := range r.resolvWatchPaths() {
+		fingerprint := resolvFingerprint(path)
+		if previous, ok := r.resolvWatch.seen[path]; ok && previous != fingerprint {
+			changed = append(changed, path)
+		}
+		r.resolvWatch.seen[path] = fingerprint
+	}
+	r.resolvWatch.mu.Unlock()
+	if len(changed) == 0 {
+		return nil
+	}
+	sort.Strings(changed)
+
+	cfg := r.config()
+	mode := cfg.Default.Mode
+	for _, path := range changed {
+		r.logger.Warn("%s was rewritten by other software; split DNS for ZeroTier may be bypassed", path)
+		if target, err := os.Readlink(path); err == nil {
+			r.logger.Verbose("%s now links to %s", path, target)
+		}
+		metrics.Inc("zeroplex_resolv_conf_rewrites_total", "Rewrites of watched resolver files by other software", metrics.Labels{"path": path})
+		events.Publish(events.Event{
+			Type:    events.TypeDrift,
+			Mode:    mode,
+			Message: "resolver configuration rewritten (" + path + ")",
+			Data:    map[string]interface{}{"kind": "resolv_conf", "path": path},
+		})
+	}
+	if (mode == "resolved" || mode == "networkd") && !resolvConfUsesStub(modes.ResolvConfPath) {
+		// zeroplex never points resolv.conf back at systemd-resolved itself, that is the admin's call
+		r.logger.Warn("%s no longer points at the systemd-resolved stub resolver; lookups bypass the per-interface ZeroTier DNS", modes.ResolvConfPath)
+	}
+
+	if cfg.Default.ResolvWatch.Reassert {
+		r.logger.Info("Re-asserting the managed DNS settings after the rewrite of %s", strings.Join(changed, ", "))
+		if err := r.RequestRun(TriggerResolvConf); err != nil {
+			_ = r.executeTask(withTrigger(context.Background(), TriggerResolvConf))
+		}
+	}
+	return changed
+}
+
+// resolvConfUsesStub reports whether a resolv.conf sends lookups to systemd-resolved
+func resolvConfUsesStub(path string) bool {
+	if target, err := os.Readlink(path); err == nil && strings.Contains(target, "/systemd/resolve/") {
+		return true
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "nameserver" && (fields[1] == "127.0.0.53" || fields[1] == "127.0.0.54") {
+			return true
+		}
+	}
+	return false
+}
+
+// watchResolvConf checks the watched files every resolv_watch.interval until stop is closed
+func (r *Runner) watchResolvConf(stop <-chan struct{}) {
+	interval, err := utils.ParseInterval(r.config().Default.ResolvWatch.Interval)
+	if err != nil || interval <= 0 {
+		interval = 5 * time.Second
+	}
+	r.logger.Verbose("Watching %s for rewrites every %s", strings.Join(r.resolvWatchPaths(), ", "), interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			r.CheckResolvConf()
+		}
+	}
+}
@@ -5,22 +5,33 @@
 package runner
 
 import (
+	"zeroplex/pkg/client"
 	"zeroplex/pkg/config"
 	"zeroplex/pkg/daemon"
 	"zeroplex/pkg/dns"
+	"zeroplex/pkg/events"
+	"zeroplex/pkg/exitcode"
+	"zeroplex/pkg/hardening"
+	"zeroplex/pkg/initsys"
 	"zeroplex/pkg/log"
+	"zeroplex/pkg/metrics"
 	"zeroplex/pkg/modes"
+	"zeroplex/pkg/privilege"
+	"zeroplex/pkg/state"
 	"zeroplex/pkg/utils"
 
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
-	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
+	"runtime/debug"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -29,20 +40,49 @@ import (
 
 // Runner manages the execution of the ZeroPlex in both one-shot and daemon modes
 type Runner struct {
-	cfg            config.Config
+	cfg            atomic.Pointer[config.Config] // replaced whole by Reload, read through config
 	dryRun         bool
-	daemon         daemon.Interface
+	daemon         daemon.Scheduler
 	logger         *log.Logger
 	ifaceWatchStop chan struct{} // for stopping interface watcher
+	recovery       recoveryTracker
+	propagation    propagationTracker
+	status         runStatus
+	bus            busState
+	resolvWatch    resolvWatch
+	linkRetry      linkRetries
+	holdDown       holdDownRun
+	controlACL     controlACL                    // who may use the control API, set by startControl
+	zt             atomic.Pointer[client.Client] // shared by the modes and the helpers querying ZeroTier, replaced by Reload
+	reload         func() (config.Config, error)
+	reloadMu       sync.Mutex
+	runMu          sync.Mutex  // held while a run applies or RestoreAll restores, so the two never interleave
+	configPaths    []string    // read by reload, watched with daemon.watch_config
+	adminDisabled  atomic.Bool // whether DisableFile existed at the last check, see checkAdminDisabled
+	notifiedReady  atomic.Bool // whether systemd was sent READY=1, see notifyRun
+	version        string      // reported in the control status, see SetVersion
 }
 
 // New creates a new runner instance
 func New(cfg config.Config, dryRun bool) *Runner {
-	return &Runner{
-		cfg:    cfg,
+	r := &Runner{
 		dryRun: dryRun,
 		logger: log.NewScopedLogger("[runner]", cfg.Default.Log.Level),
 	}
+	r.cfg.Store(&cfg)
+	r.zt.Store(client.New(cfg.Default.Client))
+	return r
+}
+
+// config returns the configuration in effect. Reload swaps it for a new one rather than changing
+// it, so a caller needing several settings to agree, such as a run, reads it once and keeps it.
+func (r *Runner) config() *config.Config {
+	return r.cfg.Load()
+}
+
+// ztClient returns the client for the ZeroTier service of the configuration in effect
+func (r *Runner) ztClient() *client.Client {
+	return r.zt.Load()
 }
 
 // Run executes the application based on configuration
@@ -65,16 +105,17 @@ func (r *Runner) Run() error {
 	go r.startDNSWatchdog()
 
 	// Auto-detect mode if needed
-	if r.cfg.Default.Mode == "auto" {
+	if cfg := *r.config(); cfg.Default.Mode == "auto" {
 		detectedMode, detected := r.detectMode()
 		if detected {
-			r.cfg.Default.Mode = detectedMode
+			cfg.Default.Mode = detectedMode
+			r.cfg.Store(&cfg)
 			r.logger.Info("Auto-detected mode: %s", detectedMode)
 		} else {
 			r.logger.Warn("Failed to auto-detect mode, keeping 'auto'")
 		}
 	} else {
-		r.logger.Info("Using configured mode: %s", r.cfg.Default.Mode)
+		r.logger.Info("Using configured mode: %s", r.config().Default.Mode)
 	}
 
 	r.logger.Info("[debug] Exiting Runner.Run() (should not happen in daemon mode)")
@@ -83,12 +124,29 @@ func (r *Runner) Run() error {
 
 // validateEnvironment checks if the runtime environment is suitable
 func (r *Runner) validateEnvironment() error {
-	if os.Geteuid() != 0 {
-		return fmt.Errorf("ERROR You need to be root to run this program")
+	if missing := privilege.Check(*r.config()); len(missing) > 0 {
+		return privilege.Error(missing)
 	}
 
-	if runtime.GOOS != "linux" {
-		return fmt.Errorf("ERROR This tool is only needed on Linux")
+	for _, mode := range r.config().Default.ActiveModes() {
+		switch runtime.GOOS {
+		case "linux":
+		case "darwin":
+			// Only the backends that don't depend on Linux services work on macOS
+			switch mode {
+			case "auto", "macos", "noop":
+			default:
+				return exitcode.Wrap(exitcode.Config, fmt.Errorf("ERROR Mode %s is not available on macOS (use macos or noop)", mode))
+			}
+		case "windows":
+			switch mode {
+			case "auto", "windows", "noop":
+			default:
+				return exitcode.Wrap(exitcode.Config, fmt.Errorf("ERROR Mode %s is not available on Windows (use windows or noop)", mode))
+			}
+		default:
+			return fmt.Errorf("ERROR This tool only runs on Linux, macOS and Windows")
+		}
 	}
 
 	return nil
@@ -96,27 +154,72 @@ func (r *Runner) validateEnvironment() error {
 
 // detectMode automatically detects which systemd service is running
 func (r *Runner) detectMode() (string, bool) {
-	r.logger.Trace("DetectMode() - checking systemd services")
-
-	r.logger.Debug("Checking systemd-networkd.service status...")
-	networkdOutput, networkdErr := utils.ExecuteCommand("systemctl", "is-active", "systemd-networkd.service")
-	networkdActive := networkdErr == nil && strings.TrimSpace(networkdOutput) == "active"
-	r.logger.Debug("systemd-networkd.service active: %t", networkdActive)
-
-	r.logger.Debug("Checking systemd-resolved.service status...")
-	resolvedOutput, resolvedErr := utils.ExecuteCommand("systemctl", "is-active", "systemd-resolved.service")
-	resolvedActive := resolvedErr == nil && strings.TrimSpace(resolvedOutput) == "active"
-	r.logger.Debug("systemd-resolved.service active: %t", resolvedActive)
-
-	if networkdActive {
-		return "networkd", true
-	} else if resolvedActive {
-		return "resolved", true
+	switch runtime.GOOS {
+	case "darwin":
+		return "macos", true
+	case "windows":
+		return "windows", true
+	}
+	// OpenWrt runs procd rather than a service manager zeroplex can ask, and dnsmasq from UCI
+	if _, err := os.Stat(modes.OpenWrtRelease); err == nil && utils.CommandExists("uci") {
+		return "openwrt", true
+	}
+	r.logger.Trace("DetectMode() - checking services")
+
+	services := initsys.Current()
+	// systemd-networkd and systemd-resolved only run under systemd; elsewhere don't ask for them
+	if services.Name() == "systemd" {
+		r.logger.Debug("Checking systemd-networkd status (%s)...", services.Name())
+		networkdActive := services.IsActive("systemd-networkd")
+		r.logger.Debug("systemd-networkd active: %t", networkdActive)
+
+		r.logger.Debug("Checking systemd-resolved status (%s)...", services.Name())
+		resolvedActive := services.IsActive("systemd-resolved")
+		r.logger.Debug("systemd-resolved active: %t", resolvedActive)
+
+		if networkdActive {
+			return "networkd", true
+		} else if resolvedActive {
+			return "resolved", true
+		}
 	} else {
-		r.logger.Error("Neither systemd-networkd nor systemd-resolved is running")
-		utils.ErrorHandler("Neither systemd-networkd nor systemd-resolved is running. Please manually set the mode using the -mode flag or configuration file.", nil, true)
-		return "", false
+		r.logger.Debug("Init system is %s, not checking for systemd-networkd or systemd-resolved", services.Name())
+	}
+
+	// Desktops without systemd-resolved commonly leave interfaces and DNS to NetworkManager
+	r.logger.Debug("Checking NetworkManager status (%s)...", services.Name())
+	networkManagerActive := services.IsActive("NetworkManager") && utils.CommandExists("nmcli")
+	r.logger.Debug("NetworkManager active: %t", networkManagerActive)
+	if networkManagerActive {
+		return "networkmanager", true
+	}
+
+	// Routers and Pi-hole style hosts answer DNS with dnsmasq
+	r.logger.Debug("Checking dnsmasq status (%s)...", services.Name())
+	dnsmasqActive := services.IsActive("dnsmasq")
+	r.logger.Debug("dnsmasq active: %t", dnsmasqActive)
+	if dnsmasqActive {
+		return "dnsmasq", true
+	}
+
+	// Hosts running unbound as their local caching resolver
+	r.logger.Debug("Checking unbound status (%s)...", services.Name())
+	unboundActive := services.IsActive("unbound")
+	r.logger.Debug("unbound active: %t", unboundActive)
+	if unboundActive {
+		return "unbound", true
+	}
+
+	// Minimal installs (Alpine, Void, Debian without systemd-resolved) manage resolv.conf with resolvconf
+	resolvconfAvailable := utils.CommandExists("resolvconf")
+	r.logger.Debug("resolvconf available: %t", resolvconfAvailable)
+	if resolvconfAvailable {
+		return "resolvconf", true
 	}
+
+	// Nothing else manages DNS, so nothing else will rewrite resolv.conf under zeroplex either
+	r.logger.Warn("None of systemd-networkd, systemd-resolved, NetworkManager, dnsmasq or unbound is running (%s) and resolvconf is not installed; editing %s directly. Set the mode with -mode or the configuration file to choose another backend.", services.Name(), modes.ResolvConfPath)
+	return "resolvfile", true
 }
 
 // DetectMode exposes the detectMode method for external use
@@ -127,7 +230,53 @@ func (r *Runner) DetectMode() (string, bool) {
 // runOnce executes the application once and exits
 func (r *Runner) runOnce() error {
 	r.logger.Info("Running in one-shot mode")
-	return r.executeTask(context.Background())
+	r.configureEvents()
+	if err := r.executeOnce(); err != nil {
+		return err
+	}
+	// Observe-only drift is not a failed run, but a one-shot check should still say so
+	if pending := modes.DriftPending(); !r.config().Default.Enforcing() && pending > 0 {
+		return exitcode.Wrap(exitcode.DriftPending, fmt.Errorf("drift pending on %d item(s); run with enforcement to correct", pending))
+	}
+	if _, disabled := AdminDisabled(); disabled && modes.DriftPending() > 0 {
+		return exitcode.Wrap(exitcode.DriftPending, fmt.Errorf("drift pending on %d item(s); administratively disabled by %s", modes.DriftPending(), DisableFile))
+	}
+	if pending := modes.DriftPending(); modes.Deferring() != "" && pending > 0 {
+		return exitcode.Wrap(exitcode.DriftPending, fmt.Errorf("drift pending on %d item(s); deferred by maintenance window %q", pending, modes.Deferring()))
+	}
+	return nil
+}
+
+// timeoutGrace is how long a one-shot run that hit its timeout gets to wind down once its context
+// is cancelled, so it isn't cut off halfway through writing a file
+const timeoutGrace = time.Second
+
+// executeOnce runs the reconcile of a one-shot run, aborting it once the timeout setting is up so
+// a wedged ZeroTier API or resolver can't hang the scripts and boot units running zeroplex
+func (r *Runner) executeOnce() error {
+	timeout, err := time.ParseDuration(r.config().Default.Timeout)
+	if err != nil || timeout <= 0 {
+		return r.executeTask(context.Background())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- r.executeTask(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+	// API requests and commands started with the context are being cancelled
+	select {
+	case err := <-done:
+		if err == nil {
+			return nil
+		}
+	case <-time.After(timeoutGrace):
+	}
+	r.logger.Error("Run did not finish within %s (timeout), aborting", timeout)
+	return exitcode.Wrap(exitcode.Timeout, fmt.Errorf("run did not finish within %s", timeout))
 }
 
 // RunOnce executes the application once and exits
@@ -137,7 +286,12 @@ func (r *Runner) RunOnce() error {
 
 // runDaemon starts the application in daemon mode
 func (r *Runner) runDaemon() error {
-	r.logger.Verbose("Running in daemon mode with interval: %s", r.cfg.Default.Daemon.PollInterval)
+	cfg := r.config()
+	r.logger.Verbose("Running in daemon mode with interval: %s", cfg.Default.Daemon.PollInterval)
+	r.configureEvents()
+	if !cfg.Default.Enforcing() {
+		r.logger.Info("Observe-only mode (enforce: false): drift will be reported but nothing will be changed")
+	}
 
 	// Start D-Bus sleep/resume watcher with structured logging
 	r.logger.Debug("About to start sleep watcher goroutine (PRE)")
@@ -145,61 +299,184 @@ func (r *Runner) runDaemon() error {
 		ctx := context.Background()
 		StartSleepResumeWatcher(ctx, logger, func() {
 			r.logger.Verbose("System resume detected (D-Bus), triggering DNS/interface re-check with backoff")
-			go r.retryUntilDNSOk(context.Background(), "resume event")
+			r.startPropagation(TriggerResume)
+			go r.retryUntilDNSOk(context.Background(), TriggerResume, "resume event")
 		})
 	}(r.logger.Debug)
 	r.logger.Debug("After starting sleep watcher goroutine (POST)")
 
+	if r.watchdogConfigured() {
+		go r.startDNSWatchdog()
+	}
+
+	if cfg.Default.Daemon.DBus {
+		if release, err := r.startBus(); err != nil {
+			r.logger.Warn("D-Bus interface unavailable: %v", err)
+		} else {
+			defer release()
+		}
+	}
+
+	if cfg.Default.Control.Enabled {
+		if stop, err := r.startControl(); err != nil {
+			r.logger.Warn("Control API unavailable: %v", err)
+		} else {
+			defer stop()
+		}
+	}
+
+	if cfg.Default.Health.Enabled {
+		if stop, err := r.startHealth(); err != nil {
+			r.logger.Warn("Health endpoints unavailable: %v", err)
+		} else {
+			defer stop()
+		}
+	}
+
+	if cfg.Default.GRPC.Enabled {
+		if stop, err := r.startGRPC(); err != nil {
+			r.logger.Warn("gRPC management API unavailable: %v", err)
+		} else {
+			defer stop()
+		}
+	}
+
+	// With hardening.user set, the rest of the daemon runs unprivileged; the sockets above stay bound
+	if err := hardening.DropPrivileges(cfg.Default.Hardening, r.logger); err != nil {
+		return exitcode.Wrap(exitcode.Privilege, err)
+	}
+	if user := cfg.Default.Hardening.User; user != "" {
+		dropped := *cfg
+		dropped.Default.Hardening.User = ""
+		if missing := privilege.Check(dropped); len(missing) > 0 {
+			r.logger.Warn("As hardening.user %s, zeroplex is missing %s; the changes needing them will fail", user, strings.Join(missing, "; "))
+		}
+	}
+
 	// Start interface watcher if enabled
-	r.logger.Debug("Interface watch mode: %s", r.cfg.Default.InterfaceWatch.Mode)
-	if r.cfg.Default.InterfaceWatch.Mode == "event" {
+	r.logger.Debug("Interface watch mode: %s", cfg.Default.InterfaceWatch.Mode)
+	if cfg.Default.InterfaceWatch.Mode == "event" {
 		r.ifaceWatchStop = make(chan struct{})
-		err := utils.WatchInterfacesNetlink(r.handleInterfaceEvent, r.ifaceWatchStop, r.cfg.Default.Log.Level)
+		err := utils.WatchInterfacesNetlink(r.handleInterfaceEvent, r.ifaceWatchStop, cfg.Default.Log.Level)
 		if err != nil {
 			r.logger.Error("Netlink watcher failed: %v. Falling back to polling mode.", err)
-			go utils.PollInterfaces(5*time.Second, r.handleInterfaceEvent, r.ifaceWatchStop, r.cfg.Default.Log.Level)
+			go utils.PollInterfaces(5*time.Second, r.handleInterfaceEvent, r.ifaceWatchStop, cfg.Default.Log.Level)
 		}
-	} else if r.cfg.Default.InterfaceWatch.Mode == "poll" {
+	} else if cfg.Default.InterfaceWatch.Mode == "poll" {
 		r.ifaceWatchStop = make(chan struct{})
-		go utils.PollInterfaces(5*time.Second, r.handleInterfaceEvent, r.ifaceWatchStop, r.cfg.Default.Log.Level)
+		go utils.PollInterfaces(5*time.Second, r.handleInterfaceEvent, r.ifaceWatchStop, cfg.Default.Log.Level)
 		// No error to check for goroutine
 		// Optionally log after a short delay
 	}
 
+	if cfg.Default.ResolvWatch.Enabled {
+		stopResolvWatch := make(chan struct{})
+		defer close(stopResolvWatch)
+		go r.supervise("resolv.conf watcher", stopResolvWatch, func() { r.watchResolvConf(stopResolvWatch) })
+	}
+
 	// Parse interval
-	interval, err := time.ParseDuration(r.cfg.Default.Daemon.PollInterval)
+	interval, err := time.ParseDuration(cfg.Default.Daemon.PollInterval)
 	if err != nil {
-		return fmt.Errorf("invalid poll interval: %w", err)
+		return exitcode.Wrap(exitcode.Config, fmt.Errorf("invalid poll interval: %w", err))
+	}
+
+	// Create daemon; the first execution happens immediately on start, later ones on the timer.
+	// Triggered runs arrive with their reason already attached to the context.
+	initial := !cfg.Default.Daemon.SkipInitialRun
+	scheduler := daemon.NewScheduled(interval, func(ctx context.Context) error {
+		if !hasTrigger(ctx) {
+			trigger := TriggerTimer
+			if initial {
+				trigger = TriggerStartup
+			}
+			ctx = withTrigger(ctx, trigger)
+		}
+		initial = false
+		err := r.executeTask(ctx)
+		var verifyErr *modes.VerificationError
+		if errors.As(err, &verifyErr) {
+			go r.retryUntilDNSOk(context.Background(), TriggerVerify, "apply verification failure")
+		}
+		return err
+	})
+	if cfg.Default.Daemon.StartJitter != "" {
+		if jitter, err := utils.ParseInterval(cfg.Default.Daemon.StartJitter); err == nil && jitter > 0 {
+			r.logger.Verbose("Initial run will be delayed by up to %s (start_jitter)", jitter)
+			scheduler.SetStartJitter(jitter)
+		}
+	}
+	if cfg.Default.Daemon.SkipInitialRun {
+		r.logger.Verbose("Skipping initial run (skip_initial_run); first run after %s", interval)
+		scheduler.SetSkipInitialRun(true)
 	}
+	r.startWatchdog(scheduler)
+	r.daemon = scheduler
 
-	// Create daemon
-	r.daemon = daemon.NewSimple(interval, r.executeTask)
+	if cfg.Default.Daemon.WatchConfig {
+		stopConfigWatch := make(chan struct{})
+		defer close(stopConfigWatch)
+		go r.supervise("configuration watcher", stopConfigWatch, func() { r.watchConfig(stopConfigWatch) })
+	}
 
-	// Set up signal handling for graceful shutdown
+	// Set up signal handling for graceful shutdown, and SIGHUP for reloading the configuration
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	// Start daemon
 	if err := r.daemon.Start(); err != nil {
 		return fmt.Errorf("failed to start daemon: %w", err)
 	}
+	if cfg.Default.Daemon.SkipInitialRun {
+		// No run to wait for
+		r.notifiedReady.Store(true)
+		r.sdNotify("READY=1", fmt.Sprintf("STATUS=Waiting for the first run at %s", r.nextRun().Format("15:04:05")))
+	}
 
 	// Wait for shutdown signal
 	sig := <-sigChan
+	for sig == syscall.SIGHUP {
+		r.logger.Info("Received SIGHUP, reloading the configuration")
+		// A rejected configuration is logged by Reload and the running one kept
+		go func() { _ = r.Reload() }()
+		sig = <-sigChan
+	}
 	r.logger.Info("Received signal %s, shutting down gracefully...", sig)
+	r.sdNotify("STOPPING=1", "STATUS=Shutting down")
+
+	// Stop daemon and wait for any in-flight run so restore doesn't race an apply
+	r.daemon.Stop()
+	select {
+	case <-r.daemon.Done():
+	case <-time.After(30 * time.Second):
+		r.logger.Warn("Timed out waiting for in-flight run to finish")
+	}
 
-	// If restore_on_exit is enabled, restore DNS for all managed interfaces
-	if r.cfg.Default.Features.RestoreOnExit {
+	// If restore_on_exit is enabled, restore DNS for all managed interfaces, with the settings of
+	// the last reload
+	cfg = r.config()
+	if _, disabled := AdminDisabled(); disabled && cfg.Default.Features.RestoreOnExit {
+		r.logger.Info("restore_on_exit enabled, but administratively disabled by %s: leaving DNS as it is", DisableFile)
+	} else if cfg.Default.Features.RestoreOnExit {
 		r.logger.Info("restore_on_exit enabled: restoring DNS for all managed interfaces...")
 		saved := dns.GetSavedDNSState()
 		for iface := range saved {
 			r.logger.Info("Restoring DNS for interface %s", iface)
-			dns.RestoreSavedDNS(iface, r.cfg.Default.Log.Level)
+			dns.RestoreSavedDNS(context.Background(), iface, cfg.Default.Log.Level)
+		}
+		// The other backends keep no saved DNS; reapplying the connection, deleting the entry or
+		// removing the generated files puts them back
+		for _, mode := range cfg.Default.ActiveModes() {
+			switch mode {
+			case "networkmanager", "resolvconf", "resolvfile", "dnsmasq", "unbound", "openwrt", "macos", "windows":
+				restore := *cfg
+				restore.Default.Mode = mode
+				restore.Default.Networks = nil
+				modes.RestoreManaged(restore, r.dryRun)
+			}
 		}
 	}
 
-	// Stop daemon
-	r.daemon.Stop()
 	return nil
 }
 
@@ -209,37 +486,217 @@ func (r *Runner) RunDaemon() error {
 }
 
 func (r *Runner) executeTask(ctx context.Context) error {
-	taskLogger := log.NewScopedLogger("[runner/task]", r.cfg.Default.Log.Level)
+	// Every line logged for this run carries its ID, so concurrent runs can be told apart
+	ctx = log.WithRunID(ctx, log.NewRunID())
+	// The whole run works with one configuration, even if a reload replaces it halfway
+	cfg, zt := *r.config(), r.ztClient()
+	taskLogger := log.NewScopedLogger("[runner/task]", cfg.Default.Log.Level).WithContext(ctx)
+	trigger := triggerFrom(ctx)
+	started := time.Now()
+	taskLogger.Verbose("Reconcile run triggered by %s", trigger)
+
+	r.runMu.Lock()
+	modes.ResetSummary()
+	err := r.runModeSafely(ctx, cfg, zt, taskLogger)
+	r.rebaseResolvWatch()
+	r.recordRun(trigger, started, err)
+	logRunSummary(taskLogger, trigger, cfg.Default.ConfigEpoch, time.Since(started), err)
+	r.runMu.Unlock()
+	r.announceRun(trigger, err)
+	if err == nil {
+		r.runSucceeded()
+	}
+	if r.daemon != nil {
+		r.notifyRun(err)
+		r.startLinkRetries()
+		r.scheduleHoldDownRun()
+	}
+
+	if next := r.nextRun(); !next.IsZero() {
+		taskLogger.Verbose("Reconcile run (trigger=%s) finished in %s; next scheduled run at %s (in %s)",
+			trigger, time.Since(started).Round(time.Millisecond), next.Format("15:04:05"), time.Until(next).Round(time.Second))
+	} else {
+		taskLogger.Debug("Reconcile run (trigger=%s) finished in %s", trigger, time.Since(started).Round(time.Millisecond))
+	}
+	return err
+}
 
+// logRunSummary logs one line with the counters of a run, for journal greps and log-based alerting
+func logRunSummary(logger *log.Logger, trigger Trigger, epoch string, took time.Duration, err error) {
+	s := modes.Summary()
+	if err != nil {
+		s.Errors++
+	}
+	line := fmt.Sprintf("Run summary: trigger=%s networks=%d filtered=%d applied=%d unchanged=%d removed=%d duration=%s errors=%d",
+		trigger, s.Networks, s.Filtered, s.Applied, s.Unchanged, s.Removed, took.Round(time.Millisecond), s.Errors)
+	if s.Held > 0 {
+		line += fmt.Sprintf(" held=%d", s.Held)
+	}
+	if epoch != "" {
+		line += " config_epoch=" + epoch
+	}
+	logger.Info("%s", line)
+}
+
+// configureEvents registers the configured webhook sinks, and the fleet labels and configuration
+// epoch attached to metrics, events and recorded actions
+func (r *Runner) configureEvents() {
+	cfg := r.config()
+	labels := cfg.Default.Labels
+	if len(labels) > 0 {
+		r.logger.Debug("Fleet labels: %v", labels)
+	}
+	metrics.SetConstLabels(labels)
+	events.SetLabels(labels)
+	state.SetLabels(labels)
+	epoch := cfg.Default.ConfigEpoch
+	if epoch != "" {
+		r.logger.Info("Enforcing configuration epoch %s", epoch)
+		metrics.Set("zeroplex_config_epoch_info", "Configuration epoch the node enforces", 1, metrics.Labels{"epoch": epoch})
+	}
+	events.SetConfigEpoch(epoch)
+	state.SetConfigEpoch(epoch)
+	events.ConfigureWebhooks(cfg.Default.Webhooks, cfg.Default.Log.Level)
+}
+
+// runModeSafely executes runMode, converting a panic into a failed run instead of crashing the daemon
+func (r *Runner) runModeSafely(ctx context.Context, cfg config.Config, zt *client.Client, taskLogger *log.Logger) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			taskLogger.Error("PANIC during reconcile run (trigger=%s): %v\n%s", triggerFrom(ctx), rec, debug.Stack())
+			countPanic("reconcile run")
+			err = fmt.Errorf("reconcile run panicked: %v", rec)
+		}
+	}()
+	return r.runMode(ctx, cfg, zt, taskLogger)
+}
+
+// countPanic counts a recovered panic in name
+func countPanic(name string) {
+	metrics.Inc("zeroplex_task_panics_total", "Panics recovered in reconcile runs, watchers and event handlers", metrics.Labels{"name": name})
+}
+
+// recoverHandler logs and swallows a panic escaping a watcher or event handler goroutine
+func (r *Runner) recoverHandler(name string) {
+	if rec := recover(); rec != nil {
+		r.logger.Error("PANIC in %s: %v\n%s", name, rec, debug.Stack())
+		countPanic(name)
+	}
+}
+
+// Delays before a loop run by supervise is restarted after a panic: doubling from the first to
+// the last, and back to the first once it has run for superviseSettle without panicking
+const (
+	superviseFirstDelay = time.Second
+	superviseMaxDelay   = time.Minute
+	superviseSettle     = time.Minute
+)
+
+// supervise runs loop, a watcher meant to run for the life of the daemon, and runs it again after
+// a panic, so one bad event doesn't leave DNS unwatched until a restart. It returns once loop
+// returns on its own, or stop is closed while waiting to restart it.
+func (r *Runner) supervise(name string, stop <-chan struct{}, loop func()) {
+	delay := superviseFirstDelay
+	for {
+		started := time.Now()
+		if !r.runRecovered(name, loop) {
+			return
+		}
+		if time.Since(started) >= superviseSettle {
+			delay = superviseFirstDelay
+		}
+		r.logger.Warn("Restarting the %s in %s after a panic", name, delay)
+		select {
+		case <-stop:
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, superviseMaxDelay)
+	}
+}
+
+// runRecovered runs f, reporting whether it panicked
+func (r *Runner) runRecovered(name string, f func()) (panicked bool) {
+	// Left true by a panic, which recoverHandler swallows before runRecovered returns
+	panicked = true
+	defer r.recoverHandler(name)
+	f()
+	return false
+}
+
+// runMode runs the default mode of cfg, then a runner for each other mode that networks.<id>.mode
+// selects, and joins their errors. zt is the client of cfg.
+func (r *Runner) runMode(ctx context.Context, cfg config.Config, zt *client.Client, taskLogger *log.Logger) error {
 	if r.dryRun {
 		taskLogger.Info("DRY RUN MODE: No actual changes will be made")
 	}
 
-	// Create the appropriate mode runner
+	if _, disabled := r.checkAdminDisabled(); disabled {
+		taskLogger.Info("Administratively disabled by %s: only detecting drift", DisableFile)
+		enforce := false
+		cfg.Default.Enforce = &enforce
+	}
+
+	var errs []error
+	for _, mode := range cfg.Default.ActiveModes() {
+		modeRunner, err := r.newModeRunner(cfg, zt, mode)
+		if err == nil {
+			// Execute the mode-specific logic
+			err = modeRunner.Run(ctx)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
+
+// newModeRunner creates the runner of mode for cfg, querying ZeroTier with zt
+func (r *Runner) newModeRunner(cfg config.Config, zt *client.Client, mode string) (modes.ModeRunner, error) {
 	var modeRunner modes.ModeRunner
 	var err error
 
-	switch r.cfg.Default.Mode {
+	switch mode {
 	case "networkd":
-		modeRunner, err = modes.NewNetworkdMode(r.cfg, r.dryRun)
+		modeRunner, err = modes.NewNetworkdMode(cfg, zt, r.dryRun)
 	case "resolved":
-		modeRunner, err = modes.NewResolvedMode(r.cfg, r.dryRun)
+		modeRunner, err = modes.NewResolvedMode(cfg, zt, r.dryRun)
+	case "networkmanager":
+		modeRunner, err = modes.NewNetworkManagerMode(cfg, zt, r.dryRun)
+	case "resolvconf":
+		modeRunner, err = modes.NewResolvconfMode(cfg, zt, r.dryRun)
+	case "dnsmasq":
+		modeRunner, err = modes.NewDnsmasqMode(cfg, zt, r.dryRun)
+	case "unbound":
+		modeRunner, err = modes.NewUnboundMode(cfg, zt, r.dryRun)
+	case "openwrt":
+		modeRunner, err = modes.NewOpenWrtMode(cfg, zt, r.dryRun)
+	case "macos":
+		modeRunner, err = modes.NewMacOSMode(cfg, zt, r.dryRun)
+	case "windows":
+		modeRunner, err = modes.NewWindowsMode(cfg, zt, r.dryRun)
+	case "resolvfile":
+		modeRunner, err = modes.NewResolvFileMode(cfg, zt, r.dryRun)
+	case "noop":
+		modeRunner, err = modes.NewNoopMode(cfg, zt, r.dryRun)
 	default:
-		return fmt.Errorf("invalid mode: %s", r.cfg.Default.Mode)
+		return nil, fmt.Errorf("invalid mode: %s", mode)
 	}
 
 	if err != nil {
-		return fmt.Errorf("failed to create mode runner: %w", err)
+		return nil, fmt.Errorf("failed to create mode runner: %w", err)
 	}
-
-	// Execute the mode-specific logic
-	return modeRunner.Run(ctx)
+	return modeRunner, nil
 }
 
 // Stop gracefully stops the runner if it's in daemon mode
 func (r *Runner) Stop() {
 	if r.daemon != nil && r.daemon.IsRunning() {
 		r.daemon.Stop()
+		<-r.daemon.Done()
 	}
 
 	// Stop interface watcher if running
@@ -251,10 +708,18 @@ func (r *Runner) Stop() {
 
 // handleInterfaceEvent is called on interface add/remove/up/down
 func (r *Runner) handleInterfaceEvent(ev utils.InterfaceEvent) {
-	isZT := strings.HasPrefix(ev.Name, "zt") // Only act on ZeroTier interfaces
+	defer r.recoverHandler("interface event handler")
+	// Only act on ZeroTier interfaces; a zt interface renamed by udev may no longer look like one
+	isZT := ev.ZeroTier || strings.HasPrefix(ev.Name, "zt") || strings.HasPrefix(ev.OldName, "zt")
 	if isZT {
+		if ev.Type == utils.InterfaceRenamed {
+			r.logger.Info("ZeroTier interface %s was renamed to %s", ev.OldName, ev.Name)
+		}
+		if ev.Type == utils.InterfaceAdded || ev.Type == utils.InterfaceUp || ev.Type == utils.InterfaceRenamed {
+			r.startPropagation(TriggerInterface)
+		}
 		r.logger.Info("ZeroTier interface %s event (%s), checking readiness and applying DNS if ready", ev.Name, ev.Type)
-		retryCfg := r.cfg.Default.InterfaceWatch.Retry
+		retryCfg := r.config().Default.InterfaceWatch.Retry
 		var backoffSeq []time.Duration
 		if len(retryCfg.Backoff) > 0 {
 			for _, s := range retryCfg.Backoff {
@@ -287,7 +752,7 @@ func (r *Runner) handleInterfaceEvent(ev utils.InterfaceEvent) {
 				r.logger.Warn("ZeroTier interface %s did not become ready after %.0fs (max_total), skipping DNS apply", ev.Name, maxTotal.Seconds())
 				break
 			}
-			ready, status, err := isZTInterfaceReady(r.cfg, ev.Name)
+			ready, status, err := r.InterfaceReady(ev.Name)
 			if err != nil {
 				lastErr = err
 				// Log detailed diagnostics for readiness errors
@@ -302,7 +767,7 @@ func (r *Runner) handleInterfaceEvent(ev utils.InterfaceEvent) {
 				}
 			} else if ready {
 				r.logger.Info("ZeroTier interface %s is ready (status=%s), applying DNS", ev.Name, status)
-				_ = r.executeTask(context.Background())
+				_ = r.executeTask(withTrigger(context.Background(), TriggerInterface))
 				r.logger.Info("DNS applied for ZeroTier interface %s after %d attempt(s), total wait %.1fs", ev.Name, attempt+1, time.Since(startTime).Seconds())
 				return
 			} else {
@@ -348,7 +813,7 @@ func (r *Runner) ShowStartupBanner() {
 	fmt.Println(" 888   888   888     888     d8(  888  o.  )88b   888 . d8(  888  888   .o8  888 `88b.")
 	fmt.Println("o888o o888o o888o   d888b    `Y888\"\"8o 8\"\"888P'   \"888\" `Y888\"\"8o `Y8bod8P' o888o o888o")
 	fmt.Println()
-	if r.cfg.Default.Log.Timestamps {
+	if r.config().Default.Log.Timestamps {
 		timestamp := time.Now().Format("2006-01-02 15:04:05")
 		fmt.Printf("%s Starting ZeroPlex version: %s\n", timestamp, utils.GetVersion())
 	} else {
@@ -356,9 +821,27 @@ func (r *Runner) ShowStartupBanner() {
 	}
 }
 
+// watchdogConfigured reports whether any DNS watchdog (IP, hostname, exec or per-network) is configured
+func (r *Runner) watchdogConfigured() bool {
+	features := r.config().Default.Features
+	return features.WatchdogIP != "" || features.WatchdogHostname != "" || len(features.WatchdogExec) > 0 || len(r.enabledWatchdogNetworks()) > 0
+}
+
+// enabledWatchdogNetworks returns the per-network watchdog entries that are enabled, keyed by network ID
+func (r *Runner) enabledWatchdogNetworks() map[string]config.NetworkWatchdogConfig {
+	enabled := map[string]config.NetworkWatchdogConfig{}
+	for id, wd := range r.config().Default.Features.WatchdogNetworks {
+		if wd.Enabled {
+			enabled[strings.ToLower(id)] = wd
+		}
+	}
+	return enabled
+}
+
 // startDNSWatchdog launches a goroutine that pings the watchdog_ip and triggers a poll on failure
 func (r *Runner) startDNSWatchdog() {
-	cfg := r.cfg.Default.Features
+	defer r.recoverHandler("DNS watchdog")
+	cfg := r.config().Default.Features
 	interval := time.Minute
 	if cfg.WatchdogInterval != "" {
 		if d, err := time.ParseDuration(cfg.WatchdogInterval); err == nil {
@@ -377,99 +860,211 @@ func (r *Runner) startDNSWatchdog() {
 			backoff = parsed
 		}
 	}
+
+	if len(cfg.WatchdogExec) > 0 {
+		r.startExecWatchdogs(interval, backoff)
+		if cfg.WatchdogIP == "" && cfg.WatchdogHostname == "" && len(r.enabledWatchdogNetworks()) == 0 {
+			return
+		}
+	}
+
+	if perNetwork := r.enabledWatchdogNetworks(); len(perNetwork) > 0 {
+		r.startNetworkWatchdogs(perNetwork, interval, backoff)
+		if cfg.WatchdogIP == "" && cfg.WatchdogHostname == "" {
+			return
+		}
+	}
+
 	var watchdogIP string = cfg.WatchdogIP
 	if watchdogIP == "" {
-		if len(r.cfg.Default.Client.Host) > 0 {
-			watchdogIP = r.cfg.Default.Client.Host
+		if _, host, _, err := r.config().Default.Client.Address(); err == nil {
+			watchdogIP = host
 		}
 	}
+	watchdogIP = utils.HostVars().Expand(watchdogIP)
 	watchdogHostname := cfg.WatchdogHostname
-	watchdogExpectedIP := cfg.WatchdogExpectedIP
-	if strings.Contains(watchdogHostname, "%domain%") {
-		networks, err := getZTNetworksDomains(r.cfg)
-		if err != nil {
-			r.logger.Warn("DNS watchdog: failed to get ZeroTier domains for %%domain%% substitution: %v", err)
+	watchdogExpected := utils.SplitIPs(cfg.WatchdogExpectedIP)
+	if watchdogHostname != "" {
+		if !utils.HasNetworkVars(watchdogHostname) {
+			host := utils.HostVars().Expand(watchdogHostname)
+			r.logger.Info("DNS watchdog (hostname): Hostname=%s, ExpectedIP=%s, interval=%s, backoff=%v", host, cfg.WatchdogExpectedIP, interval, backoff)
+			r.supervise("DNS watchdog", nil, func() { r.watchHostname(host, watchdogExpected, interval, backoff) })
 			return
 		}
-		if len(networks) == 0 {
-			r.logger.Warn("DNS watchdog: no ZeroTier networks with domains found for %%domain%% substitution")
+		networks, err := getZTNetworksDomains(r.ztClient())
+		if err != nil {
+			r.logger.Warn("DNS watchdog: failed to get ZeroTier networks for watchdog_hostname substitution: %v", err)
 			return
 		}
+		started := 0
 		for _, netinfo := range networks {
-			host := strings.ReplaceAll(watchdogHostname, "%domain%", netinfo.Domain)
-			r.logger.Info("DNS watchdog (hostname) for interface %s: Hostname=%s, ExpectedIP=%s, interval=%s, backoff=%v", netinfo.Interface, host, watchdogExpectedIP, interval, backoff)
-			go func(host, expectedIP, iface string) {
-				for {
-					ok := false
-					ips, err := net.LookupHost(host)
-					if err == nil {
-						for _, ip := range ips {
-							if ip == expectedIP {
-								ok = true
-								break
-							}
-						}
-					}
-					if ok {
-						r.logger.Trace("DNS watchdog: %s resolves to %s", host, expectedIP)
-						time.Sleep(interval)
-						continue
-					}
-					r.logger.Warn("DNS watchdog: %s does not resolve to %s (got: %v, err: %v), triggering poll and backoff", host, expectedIP, ips, err)
-					go r.retryUntilDNSOk(context.Background(), "watchdog-hostname failure")
-					for _, bo := range backoff {
-						ips, err := net.LookupHost(host)
-						ok := false
-						if err == nil {
-							for _, ip := range ips {
-								if ip == expectedIP {
-									ok = true
-									break
-								}
-							}
-						}
-						if ok {
-							r.logger.Info("DNS watchdog: %s resolves to %s after backoff", host, expectedIP)
-							break
-						}
-						r.logger.Warn("DNS watchdog: %s still does not resolve to %s, waiting %s", host, expectedIP, bo)
-						_ = r.executeTask(context.Background())
-						time.Sleep(bo)
-					}
-				}
-			}(host, watchdogExpectedIP, netinfo.Interface)
+			host, ok := utils.NetworkVars(netinfo.Interface, netinfo.NetworkID, netinfo.Domain).ExpandStrict(watchdogHostname)
+			if !ok {
+				r.logger.Debug("DNS watchdog: skipping interface %s, it has no value for a variable in %q", netinfo.Interface, watchdogHostname)
+				continue
+			}
+			r.logger.Info("DNS watchdog (hostname) for interface %s: Hostname=%s, ExpectedIP=%s, interval=%s, backoff=%v", netinfo.Interface, host, cfg.WatchdogExpectedIP, interval, backoff)
+			started++
+			go func(host string) {
+				r.supervise("DNS watchdog", nil, func() { r.watchHostname(host, watchdogExpected, interval, backoff) })
+			}(host)
+		}
+		if started == 0 {
+			r.logger.Warn("DNS watchdog: no ZeroTier networks provide the values needed by watchdog_hostname %q", watchdogHostname)
 		}
 		return
 	} else if watchdogIP != "" {
 		r.logger.Info("DNS watchdog enabled: IP=%s, interval=%s, backoff=%v", watchdogIP, interval, backoff)
-		for {
-			if utils.Ping(watchdogIP) {
-				r.logger.Trace("DNS watchdog: %s is reachable", watchdogIP)
-				time.Sleep(interval)
-				continue
-			}
-			r.logger.Warn("DNS watchdog: %s unreachable, triggering poll and backoff", watchdogIP)
-			go r.retryUntilDNSOk(context.Background(), "watchdog-ip failure")
-			for _, bo := range backoff {
-				if utils.Ping(watchdogIP) {
-					r.logger.Info("DNS watchdog: %s is reachable after backoff", watchdogIP)
-					break
-				}
-				r.logger.Warn("DNS watchdog: %s still unreachable, waiting %s", watchdogIP, bo)
-				_ = r.executeTask(context.Background())
-				time.Sleep(bo)
-			}
-		}
+		r.supervise("DNS watchdog", nil, func() { r.watchIP(watchdogIP, interval, backoff) })
 	} else {
 		r.logger.Warn("No watchdog_ip or hostname configured and no DNS server found; DNS watchdog disabled")
 		return
 	}
 }
 
+// watchIP pings watchdogIP every interval, triggering a poll and backoff runs whenever it is
+// unreachable
+func (r *Runner) watchIP(watchdogIP string, interval time.Duration, backoff []time.Duration) {
+	reachable := func() bool {
+		ok := utils.Ping(watchdogIP)
+		var err error
+		if !ok {
+			err = fmt.Errorf("%s unreachable", watchdogIP)
+		}
+		r.recordWatchdog("ping", watchdogIP, ok, err)
+		return ok
+	}
+	for {
+		if reachable() {
+			r.logger.Trace("DNS watchdog: %s is reachable", watchdogIP)
+			r.watchdogSleep(interval)
+			continue
+		}
+		r.logger.Warn("DNS watchdog: %s unreachable, triggering poll and backoff", watchdogIP)
+		go r.retryUntilDNSOk(context.Background(), TriggerWatchdog, "watchdog-ip failure")
+		for _, bo := range backoff {
+			if reachable() {
+				r.logger.Info("DNS watchdog: %s is reachable after backoff", watchdogIP)
+				break
+			}
+			r.logger.Warn("DNS watchdog: %s still unreachable, waiting %s", watchdogIP, bo)
+			_ = r.executeTask(withTrigger(context.Background(), TriggerWatchdog))
+			time.Sleep(bo)
+		}
+	}
+}
+
+// defaultNetworkWatchdogHostname is probed when a per-network watchdog sets no hostname, so each
+// node checks that its own record resolves through the overlay DNS
+const defaultNetworkWatchdogHostname = "%hostname%.%domain%"
+
+// startNetworkWatchdogs starts one hostname watchdog for each enabled network in watchdog_networks
+func (r *Runner) startNetworkWatchdogs(perNetwork map[string]config.NetworkWatchdogConfig, interval time.Duration, backoff []time.Duration) {
+	networks, err := getZTNetworksDomains(r.ztClient())
+	if err != nil {
+		r.logger.Warn("DNS watchdog: failed to get ZeroTier networks for watchdog_networks: %v", err)
+		return
+	}
+	joined := map[string]ZTNetworkInfo{}
+	for _, netinfo := range networks {
+		joined[strings.ToLower(netinfo.NetworkID)] = netinfo
+	}
+	for id, wd := range perNetwork {
+		netinfo, ok := joined[id]
+		if !ok {
+			r.logger.Warn("DNS watchdog: network %s has a watchdog configured but is not joined", id)
+			continue
+		}
+		hostname := wd.Hostname
+		if hostname == "" {
+			hostname = defaultNetworkWatchdogHostname
+		}
+		host, ok := utils.NetworkVars(netinfo.Interface, netinfo.NetworkID, netinfo.Domain).ExpandStrict(hostname)
+		if !ok {
+			r.logger.Warn("DNS watchdog: network %s has no value for a variable in %q (no DNS domain pushed?), watchdog disabled", id, hostname)
+			continue
+		}
+		expected := netinfo.Addresses
+		if wd.ExpectedIP != "" {
+			expected = utils.SplitIPs(wd.ExpectedIP)
+		}
+		r.logger.Info("DNS watchdog (network %s, interface %s): Hostname=%s, ExpectedIP=%v, interval=%s, backoff=%v", id, netinfo.Interface, host, expected, interval, backoff)
+		go func(host string, expected []string) {
+			r.supervise("DNS watchdog", nil, func() { r.watchHostname(host, expected, interval, backoff) })
+		}(host, expected)
+	}
+}
+
+// watchHostname checks that host resolves to one of expected (or to anything, if expected is
+// empty) every interval, triggering a poll and backoff runs whenever it does not. Only AAAA
+// records are asked for when every expected address is IPv6, and only A records when every one
+// is IPv4, so a network assigning only IPv6 addresses is checked by its AAAA record alone.
+func (r *Runner) watchHostname(host string, expected []string, interval time.Duration, backoff []time.Duration) {
+	network := utils.LookupNetwork(expected)
+	lookup := func() ([]string, bool, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		answers, err := net.DefaultResolver.LookupIP(ctx, network, host)
+		ips := make([]string, 0, len(answers))
+		for _, ip := range answers {
+			ips = append(ips, ip.String())
+		}
+		if err != nil {
+			return ips, false, err
+		}
+		if len(expected) == 0 {
+			return ips, len(ips) > 0, nil
+		}
+		for _, ip := range ips {
+			if utils.ContainsIP(expected, ip) {
+				return ips, true, nil
+			}
+		}
+		return ips, false, fmt.Errorf("resolved to %v, expected one of %v", ips, expected)
+	}
+	resolves := func() ([]string, bool, error) {
+		ips, ok, err := lookup()
+		r.recordWatchdog("lookup", host, ok, err)
+		return ips, ok, err
+	}
+	for {
+		ips, ok, err := resolves()
+		if ok {
+			r.logger.Trace("DNS watchdog: %s resolves to %v", host, ips)
+			r.watchdogSleep(interval)
+			continue
+		}
+		r.logger.Warn("DNS watchdog: %s does not resolve to %v (got: %v, err: %v), triggering poll and backoff", host, expected, ips, err)
+		go r.retryUntilDNSOk(context.Background(), TriggerWatchdog, "watchdog-hostname failure")
+		for _, bo := range backoff {
+			if _, ok, _ := resolves(); ok {
+				r.logger.Info("DNS watchdog: %s resolves after backoff", host)
+				break
+			}
+			r.logger.Warn("DNS watchdog: %s still does not resolve to %v, waiting %s", host, expected, bo)
+			_ = r.executeTask(withTrigger(context.Background(), TriggerWatchdog))
+			time.Sleep(bo)
+		}
+	}
+}
+
+// recoveryTracker coordinates concurrent retryUntilDNSOk loops so resume, watchdog and
+// interface triggers can't pile up independent backoff loops
+type recoveryTracker struct {
+	mu       sync.Mutex
+	cancel   context.CancelFunc
+	seq      uint64
+	active   int
+	deadline time.Time // shared by superseding attempts within one storm
+}
+
 // retryUntilDNSOk aggressively retries DNS/interface re-checks with backoff until success or max retries/time.
-func (r *Runner) retryUntilDNSOk(ctx context.Context, reason string) {
+// A new call supersedes any loop already in flight, which stops once the run it is in finishes, and all attempts of one
+// storm share a global deadline.
+func (r *Runner) retryUntilDNSOk(ctx context.Context, trigger Trigger, reason string) {
+	defer r.recoverHandler("recovery loop")
 	r.logger.Debug("retryUntilDNSOk called with reason: %s", reason)
-	retryCfg := r.cfg.Default.InterfaceWatch.Retry
+	retryCfg := r.config().Default.InterfaceWatch.Retry
 	var backoffSeq []time.Duration
 	if len(retryCfg.Backoff) > 0 {
 		for _, s := range retryCfg.Backoff {
@@ -485,7 +1080,59 @@ func (r *Runner) retryUntilDNSOk(ctx context.Context, reason string) {
 			maxTotal = d
 		}
 	}
+	globalTimeout := 10 * time.Minute
+	if retryCfg.GlobalTimeout != "" {
+		if d, err := time.ParseDuration(retryCfg.GlobalTimeout); err == nil && d > 0 {
+			globalTimeout = d
+		}
+	}
+	maxConcurrent := 2
+	if retryCfg.MaxConcurrent > 0 {
+		maxConcurrent = retryCfg.MaxConcurrent
+	}
+
+	r.recovery.mu.Lock()
+	if r.recovery.active >= maxConcurrent {
+		active := r.recovery.active
+		r.recovery.mu.Unlock()
+		r.logger.Warn("%s: %d recovery attempts already active (max_concurrent=%d), dropping trigger", reason, active, maxConcurrent)
+		return
+	}
+	if r.recovery.cancel != nil {
+		r.logger.Debug("%s: superseding recovery attempt already in flight", reason)
+		r.recovery.cancel()
+	}
 	startTime := time.Now()
+	if r.recovery.active == 0 || r.recovery.deadline.IsZero() {
+		r.recovery.deadline = startTime.Add(globalTimeout)
+	}
+	runDeadline := startTime.Add(maxTotal)
+	if runDeadline.After(r.recovery.deadline) {
+		runDeadline = r.recovery.deadline
+	}
+	ctx, cancel := context.WithDeadline(withTrigger(ctx, trigger), runDeadline)
+	// Superseding only stops the loop between attempts; cancelling a run would abort it halfway
+	// through applying, leaving links half configured
+	superseded, supersede := context.WithCancel(ctx)
+	r.recovery.seq++
+	id := r.recovery.seq
+	r.recovery.cancel = supersede
+	r.recovery.active++
+	r.recovery.mu.Unlock()
+
+	defer func() {
+		cancel()
+		r.recovery.mu.Lock()
+		r.recovery.active--
+		if r.recovery.seq == id {
+			r.recovery.cancel = nil
+		}
+		if r.recovery.active == 0 {
+			r.recovery.deadline = time.Time{}
+		}
+		r.recovery.mu.Unlock()
+	}()
+
 	attempt := 0
 	for {
 		if len(backoffSeq) > 0 {
@@ -497,9 +1144,13 @@ func (r *Runner) retryUntilDNSOk(ctx context.Context, reason string) {
 				break
 			}
 		}
-		if time.Since(startTime) > maxTotal {
-			r.logger.Warn("%s: did not succeed after %.0fs (max_total), giving up", reason, maxTotal.Seconds())
-			break
+		if err := superseded.Err(); err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				r.logger.Warn("%s: did not succeed after %.0fs (max_total/global_timeout), giving up", reason, time.Since(startTime).Seconds())
+			} else {
+				r.logger.Debug("%s: recovery attempt superseded or cancelled, stopping", reason)
+			}
+			return
 		}
 		err := r.executeTask(ctx)
 		if err == nil {
@@ -522,7 +1173,10 @@ func (r *Runner) retryUntilDNSOk(ctx context.Context, reason string) {
 				d = maxDelay
 			}
 		}
-		time.Sleep(d)
+		select {
+		case <-time.After(d):
+		case <-superseded.Done():
+		}
 		attempt++
 	}
 }
@@ -586,90 +1240,57 @@ func StartSleepResumeWatcher(ctx context.Context, logger func(msg string, args .
 
 type ZTNetworkInfo struct {
 	Interface string
+	NetworkID string
+	Name      string
+	Status    string
+	AuthURL   string // SSO login URL while Status is AUTHENTICATION_REQUIRED
 	Domain    string
+	Servers   []string
+	Addresses []string // assigned addresses without prefix length
 }
 
-func getZTNetworksDomains(cfg config.Config) ([]ZTNetworkInfo, error) {
-	client := &http.Client{Timeout: 5 * time.Second}
-	url := fmt.Sprintf("%s:%d/networks", strings.TrimRight(cfg.Default.Client.Host, "/"), cfg.Default.Client.Port)
-	req, err := http.NewRequest("GET", url, nil)
+func getZTNetworksDomains(zt *client.Client) ([]ZTNetworkInfo, error) {
+	resp, err := zt.Networks(context.Background())
 	if err != nil {
 		return nil, err
 	}
-	token := cfg.Default.Client.TokenFile
-	if token != "" {
-		content, err := os.ReadFile(token)
-		if err == nil {
-			req.Header.Add("X-ZT1-Auth", strings.TrimSpace(string(content)))
-		}
+	if resp.JSON200 == nil {
+		return nil, fmt.Errorf("ZeroTier API returned %s", resp.Status())
 	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+	// The typed network model has no authenticationURL, so it is read from the raw response
+	var authURLs []struct {
+		ID      string `json:"id"`
+		AuthURL string `json:"authenticationURL"`
 	}
-	defer resp.Body.Close()
-	var networks []map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&networks); err != nil {
-		return nil, err
+	_ = json.Unmarshal(resp.Body, &authURLs)
+	authURL := map[string]string{}
+	for _, nw := range authURLs {
+		authURL[nw.ID] = nw.AuthURL
 	}
+
 	var result []ZTNetworkInfo
-	for _, nw := range networks {
-		iface, _ := nw["portDeviceName"].(string)
-		dns, _ := nw["dns"].(map[string]interface{})
-		var domain string
-		if dns != nil {
-			domain, _ = dns["domain"].(string)
-		}
-		if iface != "" && domain != "" {
-			result = append(result, ZTNetworkInfo{Interface: iface, Domain: domain})
+	for _, nw := range *resp.JSON200 {
+		iface := utils.GetString(nw.PortDeviceName)
+		if iface == "" {
+			continue
 		}
-	}
-	return result, nil
-}
-
-// isZTInterfaceReady merged from zt_ready.go
-
-func isZTInterfaceReady(cfg config.Config, ifaceName string) (bool, string, error) {
-	iface, err := net.InterfaceByName(ifaceName)
-	if err != nil {
-		return false, "iface_not_found", fmt.Errorf("interface %s not found: %w", ifaceName, err)
-	}
-	if iface.Flags&net.FlagUp == 0 {
-		return false, "iface_down", fmt.Errorf("interface %s exists but is down", ifaceName)
-	}
-
-	client := &http.Client{Timeout: 5 * time.Second}
-	url := fmt.Sprintf("%s:%d/networks", strings.TrimRight(cfg.Default.Client.Host, "/"), cfg.Default.Client.Port)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return false, "api_error", err
-	}
-	token := cfg.Default.Client.TokenFile
-	if token != "" {
-		content, err := os.ReadFile(token)
-		if err == nil {
-			req.Header.Add("X-ZT1-Auth", strings.TrimSpace(string(content)))
+		var domain string
+		var servers []string
+		if nw.Dns != nil {
+			domain = utils.GetString(nw.Dns.Domain)
+			if nw.Dns.Servers != nil {
+				servers = append(servers, *nw.Dns.Servers...)
+			}
 		}
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return false, "api_unreachable", fmt.Errorf("ZeroTier API unreachable: %w (iface %s is up)", err, ifaceName)
-	}
-	defer resp.Body.Close()
-	var networks []map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&networks); err != nil {
-		return false, "api_decode_error", err
-	}
-	for _, nw := range networks {
-		if nw["portDeviceName"] == ifaceName {
-			status, _ := nw["status"].(string)
-			dns, _ := nw["dns"].(map[string]interface{})
-			servers, _ := dns["servers"].([]interface{})
-			if status == "OK" && len(servers) > 0 {
-				return true, status, nil
+		var addresses []string
+		if nw.AssignedAddresses != nil {
+			for _, cidr := range *nw.AssignedAddresses {
+				addresses = append(addresses, strings.SplitN(cidr, "/", 2)[0])
 			}
-			return false, status, nil
 		}
+		id := utils.GetString(nw.Id)
+		result = append(result, ZTNetworkInfo{Interface: iface, NetworkID: id, Name: utils.GetString(nw.Name), Status: utils.GetString(nw.Status),
+			AuthURL: authURL[id], Domain: domain, Servers: servers, Addresses: addresses})
 	}
-	return false, "not_found", nil
+	return result, nil
 }
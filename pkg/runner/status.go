@@ -0,0 +1,213 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package runner
+
+import (
+	"zeroplex/pkg/control"
+	"zeroplex/pkg/events"
+	"zeroplex/pkg/metrics"
+
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Trigger describes why a reconcile run was started
+type Trigger string
+
+const (
+	TriggerStartup    Trigger = "startup"
+	TriggerTimer      Trigger = "timer"
+	TriggerInterface  Trigger = "interface-event"
+	TriggerResume     Trigger = "resume"
+	TriggerWatchdog   Trigger = "watchdog"
+	TriggerManual     Trigger = "manual"
+	TriggerVerify     Trigger = "verify"
+	TriggerResolvConf Trigger = "resolv-conf"
+	TriggerReload     Trigger = "reload"
+	TriggerHoldDown   Trigger = "hold-down"
+)
+
+type triggerKey struct{}
+
+// withTrigger annotates ctx with the reason for the run it is passed to
+func withTrigger(ctx context.Context, trigger Trigger) context.Context {
+	return context.WithValue(ctx, triggerKey{}, trigger)
+}
+
+// hasTrigger reports whether ctx already carries a trigger
+func hasTrigger(ctx context.Context) bool {
+	_, ok := ctx.Value(triggerKey{}).(Trigger)
+	return ok
+}
+
+// triggerFrom returns the trigger stored in ctx, defaulting to manual
+func triggerFrom(ctx context.Context) Trigger {
+	if t, ok := ctx.Value(triggerKey{}).(Trigger); ok {
+		return t
+	}
+	return TriggerManual
+}
+
+// Status is a point-in-time snapshot of the runner's scheduling state
+type Status struct {
+	LastTrigger    Trigger           `json:"last_trigger,omitempty"`
+	LastRunAt      time.Time         `json:"last_run_at,omitempty"`
+	LastDuration   time.Duration     `json:"last_duration,omitempty"`
+	LastError      string            `json:"last_error,omitempty"`
+	LastSuccess    time.Time         `json:"last_success_at,omitempty"`
+	NextRunAt      time.Time         `json:"next_run_at,omitempty"`
+	Paused         bool              `json:"paused"`
+	Disabled       bool              `json:"disabled"`                  // by DisableFile
+	DisabledReason string            `json:"disabled_reason,omitempty"` // the first line of DisableFile
+	Labels         map[string]string `json:"labels,omitempty"`
+	ConfigEpoch    string            `json:"config_epoch,omitempty"`
+}
+
+// NextRunIn returns the time remaining until the next scheduled run (zero if none)
+func (s Status) NextRunIn() time.Duration {
+	if s.NextRunAt.IsZero() {
+		return 0
+	}
+	if d := time.Until(s.NextRunAt); d > 0 {
+		return d
+	}
+	return 0
+}
+
+type runStatus struct {
+	mu        sync.Mutex
+	status    Status
+	watchdogs map[string]control.Watchdog
+}
+
+// recordWatchdog stores the result of a single watchdog check of target
+func (r *Runner) recordWatchdog(kind, target string, healthy bool, err error) {
+	r.status.mu.Lock()
+	defer r.status.mu.Unlock()
+	if r.status.watchdogs == nil {
+		r.status.watchdogs = map[string]control.Watchdog{}
+	}
+	wd, seen := r.status.watchdogs[kind+"/"+target]
+	wasHealthy := !seen || wd.Healthy
+	wd.Target, wd.Kind, wd.Healthy, wd.LastCheck = target, kind, healthy, time.Now()
+	wd.LastError = ""
+	if healthy {
+		wd.Failures = 0
+	} else {
+		wd.Failures++
+		if err != nil {
+			wd.LastError = err.Error()
+		}
+	}
+	r.status.watchdogs[kind+"/"+target] = wd
+
+	if healthy {
+		r.watchdogHealthy()
+	}
+	switch {
+	case wasHealthy && !healthy:
+		r.startPropagation(TriggerWatchdog)
+		events.Publish(events.Event{Type: events.TypeWatchdogFailure, Mode: r.config().Default.Mode, Message: fmt.Sprintf("watchdog %s of %s failing: %s", kind, target, wd.LastError),
+			Data: map[string]interface{}{"target": target, "kind": kind}})
+	case !wasHealthy && healthy:
+		events.Publish(events.Event{Type: events.TypeWatchdogRecovered, Mode: r.config().Default.Mode, Message: fmt.Sprintf("watchdog %s of %s healthy again", kind, target),
+			Data: map[string]interface{}{"target": target, "kind": kind}})
+	}
+}
+
+// Watchdogs returns the latest result of every watchdog target, sorted by target
+func (r *Runner) Watchdogs() []control.Watchdog {
+	r.status.mu.Lock()
+	defer r.status.mu.Unlock()
+	list := make([]control.Watchdog, 0, len(r.status.watchdogs))
+	for _, wd := range r.status.watchdogs {
+		list = append(list, wd)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Target < list[j].Target })
+	return list
+}
+
+// recordRun stores the outcome of a finished run and updates the matching metrics
+func (r *Runner) recordRun(trigger Trigger, started time.Time, err error) {
+	r.status.mu.Lock()
+	r.status.status.LastTrigger = trigger
+	r.status.status.LastRunAt = started
+	r.status.status.LastDuration = time.Since(started)
+	if err != nil {
+		r.status.status.LastError = err.Error()
+	} else {
+		r.status.status.LastError = ""
+		r.status.status.LastSuccess = started
+	}
+	r.status.mu.Unlock()
+
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	metrics.Inc("zeroplex_runs_total", "Reconcile runs by trigger and result", metrics.Labels{"trigger": string(trigger), "result": result})
+	metrics.Set("zeroplex_last_run_timestamp_seconds", "Unix time the last reconcile run started", float64(started.Unix()), nil)
+	if next := r.nextRun(); !next.IsZero() {
+		metrics.Set("zeroplex_next_run_timestamp_seconds", "Unix time the next scheduled reconcile run is due", float64(next.Unix()), nil)
+	}
+}
+
+func (r *Runner) nextRun() time.Time {
+	if r.daemon == nil {
+		return time.Time{}
+	}
+	return r.daemon.NextRun()
+}
+
+// Status returns the current scheduling state, including the last trigger and next scheduled run
+func (r *Runner) Status() Status {
+	r.status.mu.Lock()
+	s := r.status.status
+	r.status.mu.Unlock()
+	s.NextRunAt = r.nextRun()
+	cfg := r.config()
+	s.Labels = cfg.Default.Labels
+	s.ConfigEpoch = cfg.Default.ConfigEpoch
+	if r.daemon != nil {
+		s.Paused = r.daemon.IsPaused()
+	}
+	s.DisabledReason, s.Disabled = AdminDisabled()
+	return s
+}
+
+// RequestRun asks the running daemon to reconcile immediately, recording trigger as the reason
+func (r *Runner) RequestRun(trigger Trigger) error {
+	if r.daemon == nil || !r.daemon.IsRunning() {
+		return fmt.Errorf("daemon is not running")
+	}
+	r.daemon.Trigger(withTrigger(context.Background(), trigger))
+	return nil
+}
+
+// SetPollInterval changes the daemon poll interval without restarting it
+func (r *Runner) SetPollInterval(interval time.Duration) {
+	if r.daemon != nil {
+		r.daemon.SetInterval(interval)
+	}
+}
+
+// Pause suspends scheduled reconcile runs; triggered runs still execute
+func (r *Runner) Pause() {
+	if r.daemon != nil {
+		r.daemon.Pause()
+	}
+	r.announceState()
+}
+
+// Resume re-enables scheduled reconcile runs after Pause
+func (r *Runner) Resume() {
+	if r.daemon != nil {
+		r.daemon.Resume()
+	}
+	r.announceState()
+}
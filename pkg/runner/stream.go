@@ -0,0 +1,271 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package runner
+
+import (
+	"zeroplex/pkg/events"
+
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// streamBuffer is how many events a slow stream client may fall behind before missing some
+	streamBuffer = 64
+	// streamKeepalive is how often an idle stream is poked so clients notice a dead daemon
+	streamKeepalive = 30 * time.Second
+	// websocketGUID is the fixed key suffix of the RFC 6455 handshake
+	websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+)
+
+// eventWriter delivers one event to a stream client, or a keepalive when ev is nil
+type eventWriter func(ev *events.Event) error
+
+// serveEvents streams published events as Server-Sent Events, or over a WebSocket when asked to
+func (r *Runner) serveEvents(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := req.URL.Query()
+	types := map[string]bool{}
+	for _, t := range strings.Split(query.Get("types"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types[t] = true
+		}
+	}
+	replay := 0
+	if value := query.Get("replay"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			http.Error(w, "replay must be a non-negative number", http.StatusBadRequest)
+			return
+		}
+		replay = n
+	}
+	wanted := func(ev events.Event) bool { return len(types) == 0 || types[ev.Type] }
+	backlog, ch, cancel := subscribeEvents(wanted, replay)
+	defer cancel()
+
+	ctx := req.Context()
+	var write eventWriter
+	if strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		var closed context.Context
+		var err error
+		write, closed, err = upgradeWebsocket(w, req)
+		if err != nil {
+			r.logger.Debug("Control API: websocket upgrade failed: %v", err)
+			return
+		}
+		ctx = closed
+	} else {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+		write = func(ev *events.Event) error {
+			if ev == nil {
+				_, err := io.WriteString(w, ": keepalive\n\n")
+				flusher.Flush()
+				return err
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data); err != nil {
+				return err
+			}
+			flusher.Flush()
+			return nil
+		}
+	}
+
+	for i := range backlog {
+		if write(&backlog[i]) != nil {
+			return
+		}
+	}
+	keepalive := time.NewTicker(streamKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepalive.C:
+			if write(nil) != nil {
+				return
+			}
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			if wanted(ev) && write(&ev) != nil {
+				return
+			}
+		}
+	}
+}
+
+// upgradeWebsocket completes the RFC 6455 handshake and returns a writer sending each event as a
+// text message, and a context cancelled once the client closes the connection. Messages sent by
+// the client are read and discarded; only close and ping are acted on.
+func upgradeWebsocket(w http.ResponseWriter, req *http.Request) (eventWriter, context.Context, error) {
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if key == "" || !strings.Contains(strings.ToLower(req.Header.Get("Connection")), "upgrade") {
+		http.Error(w, "bad websocket handshake", http.StatusBadRequest)
+		return nil, nil, fmt.Errorf("missing Sec-WebSocket-Key or Connection: upgrade")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket unsupported", http.StatusInternalServerError)
+		return nil, nil, fmt.Errorf("connection cannot be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	var mu sync.Mutex
+	send := func(opcode byte, payload []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		header := []byte{0x80 | opcode}
+		switch n := len(payload); {
+		case n < 126:
+			header = append(header, byte(n))
+		case n <= 0xffff:
+			header = append(header, 126, 0, 0)
+			binary.BigEndian.PutUint16(header[2:], uint16(n))
+		default:
+			header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+			binary.BigEndian.PutUint64(header[2:], uint64(n))
+		}
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if _, err := rw.Write(header); err != nil {
+			return err
+		}
+		if _, err := rw.Write(payload); err != nil {
+			return err
+		}
+		return rw.Flush()
+	}
+
+	// The request context ends when the handler returns, which is also when the connection is done
+	ctx, cancel := context.WithCancel(req.Context())
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	go func() {
+		defer cancel()
+		for {
+			opcode, payload, err := readWebsocketFrame(rw.Reader)
+			if err != nil {
+				return
+			}
+			switch opcode {
+			case 0x8: // close
+				send(0x8, payload)
+				return
+			case 0x9: // ping
+				send(0xA, payload)
+			}
+		}
+	}()
+
+	write := func(ev *events.Event) error {
+		if ev == nil {
+			return send(0x9, nil)
+		}
+		data, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		return send(0x1, data)
+	}
+	return write, ctx, nil
+}
+
+// readWebsocketFrame reads a single client frame, unmasking its payload
+func readWebsocketFrame(r *bufio.Reader) (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := head[0] & 0x0f
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	// The stream is one way; anything bigger than a close reason or ping is not expected
+	if length > 64*1024 {
+		return 0, nil, fmt.Errorf("websocket frame of %d bytes too large", length)
+	}
+	var mask [4]byte
+	if head[1]&0x80 != 0 {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
+
+// subscribeEvents subscribes to published events, returning the last replay wanted ones as well.
+// It subscribes before taking the replay so nothing published in between is lost.
+func subscribeEvents(wanted func(events.Event) bool, replay int) ([]events.Event, <-chan events.Event, func()) {
+	ch, cancel := events.Subscribe(streamBuffer)
+	var backlog []events.Event
+	for _, ev := range events.Recent() {
+		if wanted(ev) {
+			backlog = append(backlog, ev)
+		}
+	}
+	if len(backlog) > replay {
+		backlog = backlog[len(backlog)-replay:]
+	}
+	return backlog, ch, cancel
+}
@@ -0,0 +1,205 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package secrets resolves secret references such as vault://, env:// or cmd:// to their values,
+// so tokens and shared secrets never have to sit in plaintext configuration.
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheTTL is how long values from expensive providers (command, vault) are reused
+var CacheTTL = 5 * time.Minute
+
+// Provider resolves the part of a reference after "<scheme>://"
+type Provider func(ref string) (string, error)
+
+var providers = map[string]Provider{
+	"file":          fromFile,
+	"env":           fromEnv,
+	"cmd":           fromCommand,
+	"command":       fromCommand,
+	"vault":         fromVault,
+	"systemd-creds": fromSystemdCreds,
+}
+
+// cached marks providers whose results are cached for CacheTTL
+var cached = map[string]bool{"cmd": true, "command": true, "vault": true}
+
+type cacheEntry struct {
+	value   string
+	expires time.Time
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = map[string]cacheEntry{}
+)
+
+// Schemes returns the supported secret schemes, sorted
+func Schemes() []string {
+	schemes := make([]string, 0, len(providers))
+	for scheme := range providers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// IsReference reports whether value uses one of the supported secret schemes
+func IsReference(value string) bool {
+	scheme, _, ok := strings.Cut(value, "://")
+	if !ok {
+		return false
+	}
+	_, known := providers[scheme]
+	return known
+}
+
+// Resolve returns the secret a reference points to. Values that are not references are returned
+// unchanged so plain literals keep working.
+func Resolve(value string) (string, error) {
+	scheme, ref, ok := strings.Cut(value, "://")
+	if !ok {
+		return value, nil
+	}
+	provider, known := providers[scheme]
+	if !known {
+		return value, nil
+	}
+
+	if cached[scheme] {
+		cacheMu.Lock()
+		entry, hit := cache[value]
+		cacheMu.Unlock()
+		if hit && time.Now().Before(entry.expires) {
+			return entry.value, nil
+		}
+	}
+
+	secret, err := provider(ref)
+	if err != nil {
+		return "", fmt.Errorf("%s secret: %w", scheme, err)
+	}
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return "", fmt.Errorf("%s secret %q is empty", scheme, ref)
+	}
+
+	if cached[scheme] {
+		cacheMu.Lock()
+		cache[value] = cacheEntry{value: secret, expires: time.Now().Add(CacheTTL)}
+		cacheMu.Unlock()
+	}
+	return secret, nil
+}
+
+// fromFile reads file:///path/to/secret
+func fromFile(ref string) (string, error) {
+	content, err := os.ReadFile(ref)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// fromEnv reads env://NAME
+func fromEnv(ref string) (string, error) {
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", ref)
+	}
+	return value, nil
+}
+
+// fromCommand runs cmd://<shell command> and uses its standard output
+func fromCommand(ref string) (string, error) {
+	cmd := exec.Command("/bin/sh", "-c", ref)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("command failed: %w", err)
+	}
+	return string(out), nil
+}
+
+// fromSystemdCreds reads systemd-creds://<name> from $CREDENTIALS_DIRECTORY (LoadCredential=/SetCredential=)
+func fromSystemdCreds(ref string) (string, error) {
+	dir := os.Getenv("CREDENTIALS_DIRECTORY")
+	if dir == "" {
+		return "", fmt.Errorf("CREDENTIALS_DIRECTORY is not set (is the unit using LoadCredential=%s?)", ref)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, ref))
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// fromVault reads vault://<path>#<field> using VAULT_ADDR and VAULT_TOKEN (or ~/.vault-token).
+// Both KV v1 and KV v2 (path containing /data/) responses are understood; field defaults to "value".
+func fromVault(ref string) (string, error) {
+	secretPath, field, _ := strings.Cut(ref, "#")
+	if field == "" {
+		field = "value"
+	}
+	addr := strings.TrimRight(os.Getenv("VAULT_ADDR"), "/")
+	if addr == "" {
+		return "", fmt.Errorf("VAULT_ADDR is not set")
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			if content, err := os.ReadFile(filepath.Join(home, ".vault-token")); err == nil {
+				token = strings.TrimSpace(string(content))
+			}
+		}
+	}
+	if token == "" {
+		return "", fmt.Errorf("no Vault token (set VAULT_TOKEN)")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, addr+"/v1/"+strings.TrimLeft(secretPath, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned %s for %s", resp.Status, secretPath)
+	}
+
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode vault response: %w", err)
+	}
+	data := body.Data
+	// KV v2 nests the secret under data.data
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		data = inner
+	}
+	value, ok := data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("field %q not found in %s", field, secretPath)
+	}
+	return value, nil
+}
@@ -0,0 +1,134 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// bundleVersion is the version of the Bundle document written by Export
+const bundleVersion = 1
+
+// Bundle is everything a state directory holds, carried across a reinstall or to another host:
+// the state store with its action history, and the files next to it, such as the resolv.conf
+// backup of the resolvfile mode and the runtime networkd intent
+type Bundle struct {
+	Version    int                   `json:"version"`
+	ExportedAt time.Time             `json:"exported_at"`
+	Hostname   string                `json:"hostname,omitempty"`
+	State      State                 `json:"state"`
+	Files      map[string]BundleFile `json:"files,omitempty"` // by name in the state directory
+}
+
+// BundleFile is a file of the state directory, other than the state store
+type BundleFile struct {
+	Mode    os.FileMode `json:"mode"`
+	Content []byte      `json:"content"`
+}
+
+// Export collects the state store at path and the other files of its directory. Lock files and
+// the tmp/ subdirectory are left out.
+func Export(path string) (Bundle, error) {
+	store, err := Open(path)
+	if err != nil {
+		return Bundle{}, err
+	}
+	bundle := Bundle{Version: bundleVersion, ExportedAt: time.Now().UTC(), Files: map[string]BundleFile{}}
+	bundle.Hostname, _ = os.Hostname()
+	store.mu.Lock()
+	bundle.State = store.state
+	store.mu.Unlock()
+
+	dir := NewStateDir(filepath.Dir(path))
+	entries, err := os.ReadDir(dir.Path())
+	if os.IsNotExist(err) {
+		return bundle, nil
+	} else if err != nil {
+		return Bundle{}, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || name == filepath.Base(path) || strings.HasSuffix(name, ".lock") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return Bundle{}, err
+		}
+		content, err := os.ReadFile(dir.File(name))
+		if err != nil {
+			return Bundle{}, fmt.Errorf("failed to read %s: %w", dir.File(name), err)
+		}
+		bundle.Files[name] = BundleFile{Mode: info.Mode().Perm(), Content: content}
+	}
+	return bundle, nil
+}
+
+// Names returns the names of the files of the bundle, sorted
+func (b Bundle) Names() []string {
+	names := make([]string, 0, len(b.Files))
+	for name := range b.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Empty reports whether the store at path records nothing: no interfaces and no actions
+func Empty(path string) (bool, error) {
+	store, err := Open(path)
+	if err != nil {
+		return false, err
+	}
+	snap := store.Snapshot()
+	return len(snap.Interfaces) == 0 && len(snap.Actions) == 0, nil
+}
+
+// Import replaces the state store at path with the one of bundle, under its lock so a running
+// daemon doesn't write in between, and writes the files of the bundle into its directory. It
+// returns the files it wrote besides the store.
+func Import(path string, bundle Bundle) ([]string, error) {
+	if bundle.Version < 1 || bundle.Version > bundleVersion {
+		return nil, fmt.Errorf("unsupported state bundle version %d (expected %d)", bundle.Version, bundleVersion)
+	}
+	for name := range bundle.Files {
+		if name != filepath.Base(name) || name == "." || name == ".." || name == filepath.Base(path) || strings.HasSuffix(name, ".lock") {
+			return nil, fmt.Errorf("invalid file name %q in the state bundle", name)
+		}
+	}
+	if bundle.State.Version > currentVersion {
+		return nil, fmt.Errorf("unsupported state store version %d (expected at most %d)", bundle.State.Version, currentVersion)
+	}
+	store := &Store{path: path}
+	err := store.update(func(st *State) {
+		*st = bundle.State
+		if st.Interfaces == nil {
+			st.Interfaces = map[string]Interface{}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dir := NewStateDir(filepath.Dir(path))
+	var written []string
+	for _, name := range bundle.Names() {
+		file := bundle.Files[name]
+		mode := file.Mode.Perm()
+		if mode == 0 {
+			mode = 0600
+		}
+		if err := dir.WriteFile(name, file.Content, mode); err != nil {
+			return written, fmt.Errorf("failed to write %s: %w", dir.File(name), err)
+		}
+		written = append(written, name)
+	}
+	return written, nil
+}
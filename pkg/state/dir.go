@@ -0,0 +1,184 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// tempDirName is the subdirectory of a StateDir that holds files being written
+const tempDirName = "tmp"
+
+// staleTemp is how old a temporary file must be for Prepare to consider its write interrupted,
+// rather than still going on in another zeroplex process
+const staleTemp = time.Minute
+
+// StateDir is a directory zeroplex keeps its own files in: the state store, the resolv.conf
+// backup and similar. Files are written to a temporary file in its tmp/ subdirectory first and
+// renamed into place, so a crash never leaves a half-written file behind; the leftovers of an
+// interrupted write are removed by Prepare.
+type StateDir struct {
+	path string
+}
+
+// NewStateDir returns the StateDir at path
+func NewStateDir(path string) StateDir {
+	return StateDir{path: path}
+}
+
+// DefaultDir returns the StateDir holding the state store at DefaultPath, /var/lib/zeroplex
+// unless the state_dir setting moved it
+func DefaultDir() StateDir {
+	return NewStateDir(filepath.Dir(DefaultPath))
+}
+
+// SetDefaultDir moves the state store at DefaultPath into dir
+func SetDefaultDir(dir string) {
+	DefaultPath = filepath.Join(dir, filepath.Base(DefaultPath))
+}
+
+// Path returns the directory
+func (d StateDir) Path() string {
+	return d.path
+}
+
+// File returns the path of the file called name in the directory
+func (d StateDir) File(name string) string {
+	return filepath.Join(d.path, name)
+}
+
+// Prepare creates the directory, and its tmp/ subdirectory, accessible to their owner only,
+// tightens their permissions if another user can read or write them, and removes temporary
+// files left behind by interrupted writes. It returns what it tightened or removed, for logging.
+func (d StateDir) Prepare() ([]string, error) {
+	var changed []string
+	temp := filepath.Join(d.path, tempDirName)
+	for _, dir := range []string{d.path, temp} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return changed, fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+		info, err := os.Stat(dir)
+		if err != nil {
+			return changed, err
+		}
+		if info.Mode().Perm()&0077 != 0 {
+			if err := os.Chmod(dir, 0700); err != nil {
+				return changed, fmt.Errorf("failed to restrict %s: %w", dir, err)
+			}
+			changed = append(changed, fmt.Sprintf("restricted %s from %04o to 0700", dir, info.Mode().Perm()))
+		}
+	}
+	leftovers, err := filepath.Glob(filepath.Join(temp, "*"))
+	if err != nil {
+		return changed, err
+	}
+	// Before the tmp/ subdirectory, the state store wrote its temporary files next to itself
+	if legacy, err := filepath.Glob(filepath.Join(d.path, ".state-*.json")); err == nil {
+		leftovers = append(leftovers, legacy...)
+	}
+	for _, path := range leftovers {
+		info, err := os.Stat(path)
+		if err != nil || time.Since(info.ModTime()) < staleTemp {
+			continue
+		}
+		if err := os.RemoveAll(path); err == nil {
+			changed = append(changed, "removed interrupted write "+filepath.Base(path))
+		}
+	}
+	return changed, nil
+}
+
+// CreateTemp creates a temporary file in the tmp/ subdirectory, to be renamed into the directory
+// once it is complete
+func (d StateDir) CreateTemp(pattern string) (*os.File, error) {
+	temp := filepath.Join(d.path, tempDirName)
+	if err := os.MkdirAll(temp, 0700); err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(temp, pattern)
+}
+
+// WriteFile atomically replaces the file called name in the directory with data
+func (d StateDir) WriteFile(name string, data []byte, perm os.FileMode) error {
+	tmp, err := d.CreateTemp("." + name + "-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for %s: %w", name, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), d.File(name)); err != nil {
+		return err
+	}
+	return d.sync()
+}
+
+// sync flushes the directory itself, so a file renamed into it is still there after a power loss
+func (d StateDir) sync() error {
+	if err := syncDir(d.path); err != nil {
+		return fmt.Errorf("failed to sync %s: %w", d.path, err)
+	}
+	return nil
+}
+
+// Migrate moves the files of the legacy directory into this one, leaving alone any that already
+// exist here and the lock files, which other processes may hold. It returns the files it moved.
+func (d StateDir) Migrate(legacy StateDir) ([]string, error) {
+	if filepath.Clean(legacy.path) == filepath.Clean(d.path) {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(legacy.path)
+	// Running without root, zeroplex can't have written to a legacy directory it can't read
+	if os.IsNotExist(err) || os.IsPermission(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var moved []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || strings.HasSuffix(name, ".lock") {
+			continue
+		}
+		from, to := legacy.File(name), d.File(name)
+		if _, err := os.Stat(to); err == nil {
+			continue
+		}
+		if err := os.Rename(from, to); err != nil {
+			// A different filesystem: copy, then remove the original
+			content, readErr := os.ReadFile(from)
+			if readErr != nil {
+				return moved, fmt.Errorf("failed to migrate %s: %w", from, readErr)
+			}
+			if err := d.WriteFile(name, content, 0600); err != nil {
+				return moved, fmt.Errorf("failed to migrate %s: %w", from, err)
+			}
+			os.Remove(from)
+		}
+		moved = append(moved, name)
+	}
+	if len(moved) > 0 {
+		if err := d.sync(); err != nil {
+			return moved, err
+		}
+	}
+	return moved, nil
+}
@@ -0,0 +1,11 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package state
+
+// syncDir does nothing: a directory can't be flushed on Windows, where NTFS journals the rename
+// itself
+func syncDir(path string) error {
+	return nil
+}
@@ -0,0 +1,19 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package state
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Windows has no /var/lib; keep the store next to ZeroTier's own data
+func init() {
+	programData := os.Getenv("ProgramData")
+	if programData == "" {
+		programData = `C:\ProgramData`
+	}
+	DefaultPath = filepath.Join(programData, "zeroplex", "state.json")
+}
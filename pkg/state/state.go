@@ -0,0 +1,334 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package state persists what zeroplex believes it manages (and what it did) across runs.
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultPath is where the state store is kept unless overridden, in the DefaultDir
+var DefaultPath = "/var/lib/zeroplex/state.json"
+
+// maxActions bounds the action history kept in the store
+const maxActions = 200
+
+const currentVersion = 1
+
+// Interface is the recorded configuration of a single managed interface
+type Interface struct {
+	Name        string    `json:"name"`
+	Index       int       `json:"index,omitempty"`
+	AltNames    []string  `json:"altnames,omitempty"`
+	NetworkID   string    `json:"network_id,omitempty"`
+	NetworkName string    `json:"network_name,omitempty"`
+	Mode        string    `json:"mode"`
+	DNS         []string  `json:"dns,omitempty"`
+	Domains     []string  `json:"domains,omitempty"`
+	Files       []string  `json:"files,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Action is a single change zeroplex made, or would have made
+type Action struct {
+	Time        time.Time         `json:"time"`
+	Mode        string            `json:"mode"`
+	Operation   string            `json:"operation"`
+	Interface   string            `json:"interface,omitempty"`
+	NetworkID   string            `json:"network_id,omitempty"`
+	Applied     bool              `json:"applied"`
+	Details     map[string]string `json:"details,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	ConfigEpoch string            `json:"config_epoch,omitempty"`
+}
+
+// State is the persisted document
+type State struct {
+	Version    int                    `json:"version"`
+	Interfaces map[string]Interface   `json:"interfaces"`
+	Actions    []Action               `json:"actions,omitempty"`
+	Reloads    map[string][]time.Time `json:"reloads,omitempty"`     // service -> recent reloads zeroplex triggered
+	ReadySince map[string]time.Time   `json:"ready_since,omitempty"` // network ID -> since when it has been ready without a break
+}
+
+// Store is a JSON file backed state document safe for concurrent use. Mutations re-read the file
+// under an advisory lock, so edits made by other processes (e.g. `zeroplex state forget`) are kept.
+type Store struct {
+	mu    sync.Mutex
+	path  string
+	state State
+}
+
+var (
+	defaultMu    sync.Mutex
+	defaultStore *Store
+	labels       map[string]string
+	epoch        string
+)
+
+// SetLabels sets the fleet identity labels recorded with every action
+func SetLabels(next map[string]string) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	labels = next
+}
+
+// SetConfigEpoch sets the configuration generation recorded with every action
+func SetConfigEpoch(next string) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	epoch = next
+}
+
+// Open loads the store at path, starting empty if the file does not exist yet
+func Open(path string) (*Store, error) {
+	s := &Store{path: path}
+	if err := s.loadLocked(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// loadLocked replaces the in-memory state with the file contents; s.mu must be held
+func (s *Store) loadLocked() error {
+	next := State{Version: currentVersion, Interfaces: map[string]Interface{}}
+	content, err := os.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read state file %s: %w", s.path, err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(content, &next); err != nil {
+			return fmt.Errorf("failed to parse state file %s: %w", s.path, err)
+		}
+		if next.Interfaces == nil {
+			next.Interfaces = map[string]Interface{}
+		}
+	}
+	s.state = next
+	return nil
+}
+
+// update runs fn against the latest on-disk state and persists the result, holding an
+// exclusive lock on the state file's lock companion for the whole read-modify-write
+func (s *Store) update(fn func(st *State)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	lock, err := os.OpenFile(s.path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open state lock: %w", err)
+	}
+	defer lock.Close()
+	if err := lockFile(lock); err != nil {
+		return fmt.Errorf("failed to lock state: %w", err)
+	}
+	defer unlockFile(lock)
+
+	if err := s.loadLocked(); err != nil {
+		return err
+	}
+	fn(&s.state)
+	return s.saveLocked()
+}
+
+// Reload re-reads the state file, picking up changes made by other processes
+func (s *Store) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadLocked()
+}
+
+// Default returns the process-wide store at DefaultPath, reopening it if DefaultPath changed
+func Default() (*Store, error) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultStore != nil && defaultStore.path == DefaultPath {
+		return defaultStore, nil
+	}
+	s, err := Open(DefaultPath)
+	if err != nil {
+		return nil, err
+	}
+	defaultStore = s
+	return s, nil
+}
+
+// Path returns the file backing the store
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Snapshot returns a deep copy of the state as last read or written
+func (s *Store) Snapshot() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := State{Version: s.state.Version, Interfaces: make(map[string]Interface, len(s.state.Interfaces))}
+	for k, v := range s.state.Interfaces {
+		v.DNS = append([]string(nil), v.DNS...)
+		v.Domains = append([]string(nil), v.Domains...)
+		v.Files = append([]string(nil), v.Files...)
+		v.AltNames = append([]string(nil), v.AltNames...)
+		out.Interfaces[k] = v
+	}
+	out.Actions = append([]Action(nil), s.state.Actions...)
+	if len(s.state.Reloads) > 0 {
+		out.Reloads = make(map[string][]time.Time, len(s.state.Reloads))
+		for k, v := range s.state.Reloads {
+			out.Reloads[k] = append([]time.Time(nil), v...)
+		}
+	}
+	return out
+}
+
+// Interfaces returns the recorded interfaces sorted by name
+func (s *Store) Interfaces() []Interface {
+	snap := s.Snapshot()
+	out := make([]Interface, 0, len(snap.Interfaces))
+	for _, v := range snap.Interfaces {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// SetInterface records (or replaces) an interface entry and persists the store
+func (s *Store) SetInterface(entry Interface) error {
+	if entry.UpdatedAt.IsZero() {
+		entry.UpdatedAt = time.Now()
+	}
+	return s.update(func(st *State) {
+		st.Interfaces[entry.Name] = entry
+	})
+}
+
+// Rename replaces the entry recorded as old with entry, keyed by its new name, in a single write
+func (s *Store) Rename(old string, entry Interface) error {
+	if entry.UpdatedAt.IsZero() {
+		entry.UpdatedAt = time.Now()
+	}
+	return s.update(func(st *State) {
+		delete(st.Interfaces, old)
+		st.Interfaces[entry.Name] = entry
+	})
+}
+
+// Forget removes an interface entry, reporting whether it existed
+func (s *Store) Forget(name string) (bool, error) {
+	var existed bool
+	err := s.update(func(st *State) {
+		if _, existed = st.Interfaces[name]; existed {
+			delete(st.Interfaces, name)
+		}
+	})
+	return existed, err
+}
+
+// RecordAction appends to the bounded action history and persists the store
+func (s *Store) RecordAction(action Action) error {
+	if action.Time.IsZero() {
+		action.Time = time.Now()
+	}
+	defaultMu.Lock()
+	if action.Labels == nil {
+		action.Labels = labels
+	}
+	if action.ConfigEpoch == "" {
+		action.ConfigEpoch = epoch
+	}
+	defaultMu.Unlock()
+	return s.update(func(st *State) {
+		st.Actions = append(st.Actions, action)
+		if len(st.Actions) > maxActions {
+			st.Actions = st.Actions[len(st.Actions)-maxActions:]
+		}
+	})
+}
+
+// ReserveReload records a reload of service unless limit reloads were already recorded within
+// window, reporting whether it may go ahead and how many the window holds. A limit of 0 is unlimited.
+func (s *Store) ReserveReload(service string, limit int, window time.Duration) (bool, int, error) {
+	now := time.Now()
+	var allowed bool
+	var count int
+	err := s.update(func(st *State) {
+		var recent []time.Time
+		for _, at := range st.Reloads[service] {
+			if now.Sub(at) < window {
+				recent = append(recent, at)
+			}
+		}
+		if allowed = limit == 0 || len(recent) < limit; allowed {
+			recent = append(recent, now)
+		}
+		count = len(recent)
+		if st.Reloads == nil {
+			st.Reloads = map[string][]time.Time{}
+		}
+		st.Reloads[service] = recent
+	})
+	return allowed, count, err
+}
+
+// MarkReady records whether the network with ID id is ready, and returns since when it has been
+// ready without a break, or the zero time if it isn't
+func (s *Store) MarkReady(id string, ready bool) (time.Time, error) {
+	var since time.Time
+	err := s.update(func(st *State) {
+		if !ready {
+			delete(st.ReadySince, id)
+			return
+		}
+		if since = st.ReadySince[id]; since.IsZero() {
+			since = time.Now()
+			if st.ReadySince == nil {
+				st.ReadySince = map[string]time.Time{}
+			}
+			st.ReadySince[id] = since
+		}
+	})
+	return since, err
+}
+
+// saveLocked atomically rewrites the state file; s.mu (and the file lock) must be held
+func (s *Store) saveLocked() error {
+	s.state.Version = currentVersion
+	content, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+	dir := NewStateDir(filepath.Dir(s.path))
+	tmp, err := dir.CreateTemp(".state-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(content, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close state: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return fmt.Errorf("failed to set state permissions: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+	return dir.sync()
+}
@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !windows
+
+package top
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// resizeSignals redraw the dashboard when the terminal is resized
+var resizeSignals = []os.Signal{syscall.SIGWINCH}
+
+// terminal is the controlling terminal the dashboard draws on
+type terminal struct {
+	in, out *os.File
+	saved   *unix.Termios
+}
+
+// openTerminal fails when stdin is not a terminal
+func openTerminal(in, out *os.File) (*terminal, error) {
+	saved, err := unix.IoctlGetTermios(int(in.Fd()), getTermios)
+	if err != nil {
+		return nil, err
+	}
+	return &terminal{in: in, out: out, saved: saved}, nil
+}
+
+// makeRaw turns off line buffering and echo, so single key presses are read
+func (t *terminal) makeRaw() error {
+	raw := *t.saved
+	raw.Lflag &^= unix.ICANON | unix.ECHO
+	raw.Cc[unix.VMIN], raw.Cc[unix.VTIME] = 1, 0
+	return unix.IoctlSetTermios(int(t.in.Fd()), setTermios, &raw)
+}
+
+func (t *terminal) restore() {
+	unix.IoctlSetTermios(int(t.in.Fd()), setTermios, t.saved)
+}
+
+// size returns the terminal's columns and rows
+func (t *terminal) size() (int, int, bool) {
+	ws, err := unix.IoctlGetWinsize(int(t.out.Fd()), unix.TIOCGWINSZ)
+	if err != nil || ws.Col == 0 {
+		return 0, 0, false
+	}
+	return int(ws.Col), int(ws.Row), true
+}
@@ -0,0 +1,55 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package top
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// resizeSignals is empty: the Windows console has no resize signal, so the dashboard picks up a
+// new size on its next refresh
+var resizeSignals []os.Signal
+
+// terminal is the console the dashboard draws on
+type terminal struct {
+	in, out           windows.Handle
+	savedIn, savedOut uint32
+}
+
+// openTerminal fails when stdin is not a console
+func openTerminal(in, out *os.File) (*terminal, error) {
+	t := &terminal{in: windows.Handle(in.Fd()), out: windows.Handle(out.Fd())}
+	if err := windows.GetConsoleMode(t.in, &t.savedIn); err != nil {
+		return nil, err
+	}
+	if err := windows.GetConsoleMode(t.out, &t.savedOut); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// makeRaw turns off line input and echo, and turns on the escape sequences the dashboard draws with
+func (t *terminal) makeRaw() error {
+	if err := windows.SetConsoleMode(t.in, t.savedIn&^(windows.ENABLE_LINE_INPUT|windows.ENABLE_ECHO_INPUT)); err != nil {
+		return err
+	}
+	return windows.SetConsoleMode(t.out, t.savedOut|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING)
+}
+
+func (t *terminal) restore() {
+	windows.SetConsoleMode(t.in, t.savedIn)
+	windows.SetConsoleMode(t.out, t.savedOut)
+}
+
+// size returns the console window's columns and rows
+func (t *terminal) size() (int, int, bool) {
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(t.out, &info); err != nil {
+		return 0, 0, false
+	}
+	return int(info.Window.Right-info.Window.Left) + 1, int(info.Window.Bottom-info.Window.Top) + 1, true
+}
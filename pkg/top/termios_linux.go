@@ -0,0 +1,13 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package top
+
+import "golang.org/x/sys/unix"
+
+// The ioctls reading and setting the terminal attributes
+const (
+	getTermios = unix.TCGETS
+	setTermios = unix.TCSETS
+)
@@ -0,0 +1,265 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package top implements `zeroplex top`, a terminal dashboard fed by the daemon's control API
+package top
+
+import (
+	"zeroplex/pkg/control"
+
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultInterval is how often the dashboard refreshes
+const DefaultInterval = 2 * time.Second
+
+// Options configures the dashboard
+type Options struct {
+	Socket   string
+	Interval time.Duration
+	Once     bool // print a single frame and exit, also used when stdout is not a terminal
+}
+
+const (
+	clearScreen    = "\x1b[H\x1b[2J"
+	enterAltScreen = "\x1b[?1049h\x1b[?25l"
+	leaveAltScreen = "\x1b[?25h\x1b[?1049l"
+	bold           = "\x1b[1m"
+	reset          = "\x1b[0m"
+)
+
+// Run shows the dashboard until ctx is cancelled or q is pressed
+func Run(ctx context.Context, opts Options) error {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	client := control.NewClient(opts.Socket)
+	out := os.Stdout
+
+	terminal, err := openTerminal(os.Stdin, out)
+	if opts.Once || err != nil {
+		status, err := client.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, line := range render(status, nil, 0, 0, time.Now()) {
+			fmt.Fprintln(out, stripStyle(line))
+		}
+		return nil
+	}
+
+	// Read single key presses without echo, and restore the terminal however we exit
+	if err := terminal.makeRaw(); err != nil {
+		return fmt.Errorf("failed to configure terminal: %w", err)
+	}
+	defer terminal.restore()
+	io.WriteString(out, enterAltScreen)
+	defer io.WriteString(out, leaveAltScreen)
+
+	keys := make(chan byte)
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if n, err := os.Stdin.Read(buf); err != nil || n == 0 {
+				close(keys)
+				return
+			}
+			keys <- buf[0]
+		}
+	}()
+	resized := make(chan os.Signal, 1)
+	if len(resizeSignals) > 0 {
+		signal.Notify(resized, resizeSignals...)
+	}
+	defer signal.Stop(resized)
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	var status control.Status
+	var fetchErr error
+	fetch := func() {
+		fetchCtx, cancel := context.WithTimeout(ctx, opts.Interval+5*time.Second)
+		defer cancel()
+		status, fetchErr = client.Status(fetchCtx)
+	}
+	draw := func() {
+		width, height := 100, 40
+		if w, h, ok := terminal.size(); ok {
+			width, height = w, h
+		}
+		var frame strings.Builder
+		frame.WriteString(clearScreen)
+		frame.WriteString(strings.Join(render(status, fetchErr, width, height, time.Now()), "\r\n"))
+		io.WriteString(out, frame.String())
+	}
+
+	fetch()
+	for {
+		draw()
+		select {
+		case <-ctx.Done():
+			return nil
+		case key, ok := <-keys:
+			if !ok || key == 'q' || key == 'Q' || key == 3 {
+				return nil
+			}
+			if key == 'r' || key == 'R' {
+				fetch()
+			}
+		case <-resized:
+		case <-ticker.C:
+			fetch()
+		}
+	}
+}
+
+// render lays out one frame; width and height of 0 mean unbounded
+func render(status control.Status, fetchErr error, width, height int, now time.Time) []string {
+	var lines []string
+	header := "zeroplex top"
+	if fetchErr != nil {
+		lines = append(lines, bold+header+reset, "", "Error: "+fetchErr.Error(), "", "Retrying every refresh; press q to quit.")
+		return fit(lines, width, height)
+	}
+	lines = append(lines, bold+header+" — "+status.Summary()+reset)
+	lines = append(lines, fmt.Sprintf("mode=%s enforce=%t paused=%t  last run: %s  next run: %s",
+		status.Mode, status.Enforcing, status.Paused, lastRun(status, now), nextRun(status, now)))
+	if status.LastError != "" {
+		lines = append(lines, "last error: "+status.LastError)
+	}
+
+	lines = append(lines, "", bold+"NETWORKS"+reset)
+	lines = append(lines, table("INTERFACE\tNETWORK\tSTATUS\tDNS\tDOMAIN\tMANAGED", len(status.Networks), func(i int) string {
+		n := status.Networks[i]
+		name := n.Name
+		if name == "" {
+			name = n.ID
+		}
+		return strings.Join([]string{orDash(n.Interface), name, orDash(n.Status), orDash(strings.Join(n.Servers, ",")), orDash(n.Domain), yesNo(n.Managed)}, "\t")
+	})...)
+
+	lines = append(lines, "", bold+"MANAGED INTERFACES"+reset)
+	lines = append(lines, table("INTERFACE\tMODE\tDNS\tSEARCH DOMAINS\tUPDATED", len(status.Interfaces), func(i int) string {
+		in := status.Interfaces[i]
+		return strings.Join([]string{in.Name, in.Mode, orDash(strings.Join(in.DNS, ",")), orDash(strings.Join(in.Domains, " ")), ago(in.UpdatedAt, now)}, "\t")
+	})...)
+
+	lines = append(lines, "", bold+"WATCHDOG"+reset)
+	lines = append(lines, table("TARGET\tCHECK\tSTATE\tFAILURES\tLAST CHECK\tERROR", len(status.Watchdogs), func(i int) string {
+		wd := status.Watchdogs[i]
+		state := "ok"
+		if !wd.Healthy {
+			state = "FAILING"
+		}
+		return strings.Join([]string{wd.Target, wd.Kind, state, fmt.Sprint(wd.Failures), ago(wd.LastCheck, now), orDash(wd.LastError)}, "\t")
+	})...)
+
+	// Newest history first, so it survives truncation on short terminals
+	lines = append(lines, "", bold+"RECENT EVENTS"+reset)
+	lines = append(lines, table("TIME\tTYPE\tINTERFACE\tMESSAGE", len(status.Events), func(i int) string {
+		ev := status.Events[len(status.Events)-1-i]
+		return strings.Join([]string{ev.Time.Local().Format("15:04:05"), ev.Type, orDash(ev.Interface), orDash(ev.Message)}, "\t")
+	})...)
+
+	lines = append(lines, "", bold+"ACTIVITY"+reset)
+	lines = append(lines, table("TIME\tOPERATION\tINTERFACE\tAPPLIED", len(status.Actions), func(i int) string {
+		a := status.Actions[len(status.Actions)-1-i]
+		return strings.Join([]string{a.Time.Local().Format("15:04:05"), a.Operation, orDash(a.Interface), yesNo(a.Applied)}, "\t")
+	})...)
+
+	if height > 0 && len(lines) > height-1 {
+		lines = lines[:height-1]
+	}
+	if height > 0 {
+		lines = append(lines, "q quit · r refresh")
+	}
+	return fit(lines, width, 0)
+}
+
+// table renders rows with aligned columns, or "(none)" when there are none
+func table(header string, rows int, row func(int) string) []string {
+	if rows == 0 {
+		return []string{"  (none)"}
+	}
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  "+header)
+	for i := 0; i < rows; i++ {
+		fmt.Fprintln(tw, "  "+row(i))
+	}
+	tw.Flush()
+	return strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+}
+
+// fit truncates lines to width columns and the frame to height lines (0 = unbounded)
+func fit(lines []string, width, height int) []string {
+	if height > 0 && len(lines) > height {
+		lines = lines[:height]
+	}
+	if width <= 0 {
+		return lines
+	}
+	for i, line := range lines {
+		plain := stripStyle(line)
+		if utf8.RuneCountInString(plain) > width {
+			lines[i] = string([]rune(plain)[:width])
+		}
+	}
+	return lines
+}
+
+func stripStyle(s string) string {
+	return strings.NewReplacer(bold, "", reset, "").Replace(s)
+}
+
+func lastRun(status control.Status, now time.Time) string {
+	if status.LastRunAt.IsZero() {
+		return "never"
+	}
+	result := "ok"
+	if status.LastError != "" {
+		result = "failed"
+	}
+	return fmt.Sprintf("%s (%s, %s)", ago(status.LastRunAt, now), orDash(status.LastTrigger), result)
+}
+
+func nextRun(status control.Status, now time.Time) string {
+	if status.NextRunAt.IsZero() {
+		return "-"
+	}
+	if d := status.NextRunAt.Sub(now); d > 0 {
+		return "in " + d.Round(time.Second).String()
+	}
+	return "due"
+}
+
+func ago(t, now time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return now.Sub(t).Round(time.Second).String() + " ago"
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,152 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package tray
+
+import (
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const menuInterface = "com.canonical.dbusmenu"
+
+// menuItem is a single entry of the tray menu; id 0 is the invisible root
+type menuItem struct {
+	label     string
+	enabled   bool
+	separator bool
+	onClick   func()
+}
+
+// menuLayout is the (ia{sv}av) structure returned by GetLayout
+type menuLayout struct {
+	ID         int32
+	Properties map[string]dbus.Variant
+	Children   []dbus.Variant
+}
+
+// menuProperties is an entry of the a(ia{sv}) array returned by GetGroupProperties
+type menuProperties struct {
+	ID         int32
+	Properties map[string]dbus.Variant
+}
+
+// menu implements com.canonical.dbusmenu for a flat list of items
+type menu struct {
+	mu       sync.Mutex
+	conn     *dbus.Conn
+	path     dbus.ObjectPath
+	items    []menuItem
+	revision uint32
+}
+
+// set replaces the menu items and tells the host to fetch the new layout
+func (m *menu) set(items []menuItem) {
+	m.mu.Lock()
+	m.items = items
+	m.revision++
+	revision := m.revision
+	m.mu.Unlock()
+	m.conn.Emit(m.path, menuInterface+".LayoutUpdated", revision, int32(0))
+}
+
+func (m *menu) properties(id int32) map[string]dbus.Variant {
+	if id == 0 {
+		return map[string]dbus.Variant{"children-display": dbus.MakeVariant("submenu")}
+	}
+	item := m.items[id-1]
+	if item.separator {
+		return map[string]dbus.Variant{"type": dbus.MakeVariant("separator")}
+	}
+	return map[string]dbus.Variant{
+		"label":   dbus.MakeVariant(item.label),
+		"enabled": dbus.MakeVariant(item.enabled),
+	}
+}
+
+// GetLayout returns the whole menu; it is small enough that parent and depth can be ignored
+func (m *menu) GetLayout(parentID int32, recursionDepth int32, propertyNames []string) (uint32, menuLayout, *dbus.Error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	root := menuLayout{ID: 0, Properties: m.properties(0), Children: []dbus.Variant{}}
+	for i := range m.items {
+		id := int32(i + 1)
+		child := menuLayout{ID: id, Properties: m.properties(id), Children: []dbus.Variant{}}
+		root.Children = append(root.Children, dbus.MakeVariant(child))
+	}
+	return m.revision, root, nil
+}
+
+// GetGroupProperties returns the properties of the given items (all items if ids is empty)
+func (m *menu) GetGroupProperties(ids []int32, propertyNames []string) ([]menuProperties, *dbus.Error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(ids) == 0 {
+		for i := 0; i <= len(m.items); i++ {
+			ids = append(ids, int32(i))
+		}
+	}
+	result := []menuProperties{}
+	for _, id := range ids {
+		if id >= 0 && int(id) <= len(m.items) {
+			result = append(result, menuProperties{ID: id, Properties: m.properties(id)})
+		}
+	}
+	return result, nil
+}
+
+// GetProperty returns a single property of an item
+func (m *menu) GetProperty(id int32, name string) (dbus.Variant, *dbus.Error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id < 0 || int(id) > len(m.items) {
+		return dbus.Variant{}, dbus.MakeFailedError(errUnknownItem)
+	}
+	value, ok := m.properties(id)[name]
+	if !ok {
+		return dbus.Variant{}, dbus.MakeFailedError(errUnknownItem)
+	}
+	return value, nil
+}
+
+// Event runs the action of a clicked item
+func (m *menu) Event(id int32, eventID string, data dbus.Variant, timestamp uint32) *dbus.Error {
+	if eventID != "clicked" {
+		return nil
+	}
+	m.mu.Lock()
+	var onClick func()
+	if id > 0 && int(id) <= len(m.items) {
+		onClick = m.items[id-1].onClick
+	}
+	m.mu.Unlock()
+	if onClick != nil {
+		go onClick()
+	}
+	return nil
+}
+
+// EventGroup is the batched form of Event
+func (m *menu) EventGroup(events []struct {
+	ID        int32
+	EventID   string
+	Data      dbus.Variant
+	Timestamp uint32
+}) ([]int32, *dbus.Error) {
+	for _, ev := range events {
+		m.Event(ev.ID, ev.EventID, ev.Data, ev.Timestamp)
+	}
+	return []int32{}, nil
+}
+
+// AboutToShow reports that the layout does not need refreshing before display
+func (m *menu) AboutToShow(id int32) (bool, *dbus.Error) {
+	return false, nil
+}
+
+// AboutToShowGroup is the batched form of AboutToShow
+func (m *menu) AboutToShowGroup(ids []int32) ([]int32, []int32, *dbus.Error) {
+	return []int32{}, []int32{}, nil
+}
@@ -0,0 +1,433 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package tray implements `zeroplex tray`, a StatusNotifierItem for the user session that shows the
+// daemon's per-network DNS state and offers apply/restore and SSO sign-in actions
+package tray
+
+import (
+	"zeroplex/pkg/bus"
+	"zeroplex/pkg/log"
+
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+const (
+	itemInterface = "org.kde.StatusNotifierItem"
+	itemPath      = dbus.ObjectPath("/StatusNotifierItem")
+	menuPath      = dbus.ObjectPath("/MenuBar")
+
+	watcherName      = "org.kde.StatusNotifierWatcher"
+	watcherPath      = dbus.ObjectPath("/StatusNotifierWatcher")
+	notificationsAPI = "org.freedesktop.Notifications"
+)
+
+// DefaultInterval is how often the daemon is polled for its status
+const DefaultInterval = 10 * time.Second
+
+var errUnknownItem = errors.New("unknown menu item")
+
+// Options configures the tray helper
+type Options struct {
+	Interval time.Duration
+	LogLevel string
+}
+
+// pixmap is an (iiay) icon image; the tray only uses themed icon names, so these stay empty
+type pixmap struct {
+	Width  int32
+	Height int32
+	Data   []byte
+}
+
+// toolTip is the (sa(iiay)ss) ToolTip property
+type toolTip struct {
+	IconName    string
+	IconPixmap  []pixmap
+	Title       string
+	Description string
+}
+
+type tray struct {
+	logger   *log.Logger
+	session  *dbus.Conn
+	daemon   dbus.BusObject
+	props    *prop.Properties
+	menu     *menu
+	quit     context.CancelFunc
+	refresh  chan struct{}
+	mu       sync.Mutex
+	notified map[string]uint32 // SSO URL -> notification id
+	actions  map[uint32]string // notification id -> SSO URL
+}
+
+// Run shows the tray item until ctx is cancelled or Quit is chosen from its menu
+func Run(ctx context.Context, opts Options) error {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	logger := log.NewScopedLogger("[tray]", opts.LogLevel)
+
+	system, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+	defer system.Close()
+	session, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return fmt.Errorf("failed to connect to session bus: %w", err)
+	}
+	defer session.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	t := &tray{
+		logger:   logger,
+		session:  session,
+		daemon:   system.Object(bus.Name, bus.Path),
+		menu:     &menu{conn: session, path: menuPath},
+		quit:     cancel,
+		refresh:  make(chan struct{}, 1),
+		notified: map[string]uint32{},
+		actions:  map[uint32]string{},
+	}
+	if err := t.export(); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("org.kde.StatusNotifierItem-%d-1", os.Getpid())
+	if _, err := session.RequestName(name, dbus.NameFlagDoNotQueue); err != nil {
+		return fmt.Errorf("failed to request %s: %w", name, err)
+	}
+	if err := session.Object(watcherName, watcherPath).Call(watcherName+".RegisterStatusNotifierItem", 0, name).Err; err != nil {
+		return fmt.Errorf("no StatusNotifierItem host is running (%s): %w", watcherName, err)
+	}
+	t.watchNotificationActions()
+	t.watchDaemon(system)
+	logger.Info("Tray item registered as %s, polling %s every %s", name, bus.Name, opts.Interval)
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	for {
+		t.update()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-t.refresh:
+		}
+	}
+}
+
+// export publishes the StatusNotifierItem and its menu on the session bus
+func (t *tray) export() error {
+	props, err := prop.Export(t.session, itemPath, prop.Map{itemInterface: {
+		"Category":          {Value: "SystemServices", Emit: prop.EmitFalse},
+		"Id":                {Value: "zeroplex", Emit: prop.EmitFalse},
+		"Title":             {Value: "ZeroTier DNS", Emit: prop.EmitFalse},
+		"Status":            {Value: "Active", Emit: prop.EmitFalse},
+		"WindowId":          {Value: int32(0), Emit: prop.EmitFalse},
+		"IconName":          {Value: "network-vpn", Emit: prop.EmitFalse},
+		"IconPixmap":        {Value: []pixmap{}, Emit: prop.EmitFalse},
+		"OverlayIconName":   {Value: "", Emit: prop.EmitFalse},
+		"OverlayIconPixmap": {Value: []pixmap{}, Emit: prop.EmitFalse},
+		"AttentionIconName": {Value: "dialog-warning", Emit: prop.EmitFalse},
+		"ToolTip":           {Value: toolTip{IconPixmap: []pixmap{}}, Emit: prop.EmitFalse},
+		"ItemIsMenu":        {Value: true, Emit: prop.EmitFalse},
+		"Menu":              {Value: menuPath, Emit: prop.EmitFalse},
+	}})
+	if err != nil {
+		return err
+	}
+	t.props = props
+	if err := t.session.Export(t, itemPath, itemInterface); err != nil {
+		return err
+	}
+
+	menuProps, err := prop.Export(t.session, menuPath, prop.Map{menuInterface: {
+		"Version":       {Value: uint32(3), Emit: prop.EmitFalse},
+		"TextDirection": {Value: "ltr", Emit: prop.EmitFalse},
+		"Status":        {Value: "normal", Emit: prop.EmitFalse},
+		"IconThemePath": {Value: []string{}, Emit: prop.EmitFalse},
+	}})
+	if err != nil {
+		return err
+	}
+	if err := t.session.Export(t.menu, menuPath, menuInterface); err != nil {
+		return err
+	}
+
+	for path, node := range map[dbus.ObjectPath]*introspect.Node{
+		itemPath: {Name: string(itemPath), Interfaces: []introspect.Interface{
+			introspect.IntrospectData, prop.IntrospectData,
+			{Name: itemInterface, Methods: introspect.Methods(t), Properties: props.Introspection(itemInterface)},
+		}},
+		menuPath: {Name: string(menuPath), Interfaces: []introspect.Interface{
+			introspect.IntrospectData, prop.IntrospectData,
+			{Name: menuInterface, Methods: introspect.Methods(t.menu), Properties: menuProps.Introspection(menuInterface)},
+		}},
+	} {
+		if err := t.session.Export(introspect.NewIntrospectable(node), path, "org.freedesktop.DBus.Introspectable"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Activate is called on a primary click; hosts that show the menu instead never call it
+func (t *tray) Activate(x, y int32) *dbus.Error {
+	t.requestRefresh()
+	return nil
+}
+
+// SecondaryActivate is called on a middle click
+func (t *tray) SecondaryActivate(x, y int32) *dbus.Error {
+	t.requestRefresh()
+	return nil
+}
+
+// ContextMenu is only called by hosts that don't render the exported menu themselves
+func (t *tray) ContextMenu(x, y int32) *dbus.Error {
+	return nil
+}
+
+// Scroll is ignored
+func (t *tray) Scroll(delta int32, orientation string) *dbus.Error {
+	return nil
+}
+
+func (t *tray) requestRefresh() {
+	select {
+	case t.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// status fetches the daemon snapshot over the system bus
+func (t *tray) status() (bus.Snapshot, error) {
+	var snap bus.Snapshot
+	var data string
+	if err := t.daemon.Call(bus.Interface+".Status", 0).Store(&data); err != nil {
+		return snap, err
+	}
+	err := json.Unmarshal([]byte(data), &snap)
+	return snap, err
+}
+
+// update refreshes the icon, tooltip and menu from the daemon's current state
+func (t *tray) update() {
+	snap, err := t.status()
+	if err != nil {
+		t.logger.Debug("Daemon status unavailable: %v", err)
+		t.show("NeedsAttention", "network-error", "ZeroTier DNS: daemon unavailable", err.Error())
+		t.menu.set([]menuItem{
+			{label: "ZeroTier DNS: daemon unavailable"},
+			{separator: true},
+			{label: "Quit", enabled: true, onClick: t.quit},
+		})
+		return
+	}
+
+	status, icon := "Active", "network-vpn"
+	var lines []string
+	var signIn []menuItem
+	for _, network := range snap.Networks {
+		lines = append(lines, networkLine(network))
+		if network.NeedsAuthentication() && network.AuthURL != "" {
+			url := network.AuthURL
+			signIn = append(signIn, menuItem{label: fmt.Sprintf("Sign in to %s…", networkName(network)), enabled: true, onClick: func() { t.open(url) }})
+			t.notifyAuthentication(network)
+		}
+	}
+	switch {
+	case len(signIn) > 0:
+		status, icon = "NeedsAttention", "dialog-password"
+	case snap.LastError != "":
+		status, icon = "NeedsAttention", "dialog-warning"
+		lines = append(lines, "Last run failed: "+snap.LastError)
+	case snap.Paused:
+		icon = "network-vpn-disconnected"
+	}
+	summary := snap.Summary()
+	t.show(status, icon, summary, strings.Join(lines, "\n"))
+
+	items := []menuItem{{label: summary}, {separator: true}}
+	for _, line := range lines {
+		items = append(items, menuItem{label: line})
+	}
+	if len(signIn) > 0 {
+		items = append(items, menuItem{separator: true})
+		items = append(items, signIn...)
+	}
+	items = append(items,
+		menuItem{separator: true},
+		menuItem{label: "Apply DNS now", enabled: true, onClick: t.apply},
+		menuItem{label: "Restore DNS and pause", enabled: snap.Enforcing, onClick: t.restore},
+		menuItem{separator: true},
+		menuItem{label: "Quit", enabled: true, onClick: t.quit},
+	)
+	t.menu.set(items)
+}
+
+// show sets the item status, icon and tooltip, signalling the host only for what changed
+func (t *tray) show(status, icon, title, description string) {
+	if t.props.GetMust(itemInterface, "Status") != status {
+		t.props.SetMust(itemInterface, "Status", status)
+		t.session.Emit(itemPath, itemInterface+".NewStatus", status)
+	}
+	if t.props.GetMust(itemInterface, "IconName") != icon {
+		t.props.SetMust(itemInterface, "IconName", icon)
+		t.session.Emit(itemPath, itemInterface+".NewIcon")
+	}
+	tip := toolTip{IconName: icon, IconPixmap: []pixmap{}, Title: title, Description: description}
+	if current, ok := t.props.GetMust(itemInterface, "ToolTip").(toolTip); !ok || current.Title != tip.Title || current.Description != tip.Description || current.IconName != tip.IconName {
+		t.props.SetMust(itemInterface, "ToolTip", tip)
+		t.session.Emit(itemPath, itemInterface+".NewToolTip")
+	}
+}
+
+func (t *tray) apply() {
+	if err := t.daemon.Call(bus.Interface+".Apply", 0).Err; err != nil {
+		t.logger.Warn("Apply failed: %v", err)
+		t.notify("ZeroTier DNS: apply failed", err.Error(), nil)
+	}
+	t.requestRefresh()
+}
+
+func (t *tray) restore() {
+	var restored []string
+	if err := t.daemon.Call(bus.Interface+".Restore", 0).Store(&restored); err != nil {
+		t.logger.Warn("Restore failed: %v", err)
+		t.notify("ZeroTier DNS: restore failed", err.Error(), nil)
+	} else {
+		t.logger.Info("Restored %v", restored)
+	}
+	t.requestRefresh()
+}
+
+// open hands an SSO login URL to the desktop's browser
+func (t *tray) open(url string) {
+	if err := exec.Command("xdg-open", url).Start(); err != nil {
+		t.logger.Warn("Failed to open %s: %v", url, err)
+	}
+}
+
+// notifyAuthentication raises a desktop notification the first time a network asks for an SSO login
+func (t *tray) notifyAuthentication(network bus.Network) {
+	t.mu.Lock()
+	_, done := t.notified[network.AuthURL]
+	t.mu.Unlock()
+	if done {
+		return
+	}
+	id := t.notify(fmt.Sprintf("ZeroTier: sign in to %s", networkName(network)),
+		"This network requires authentication before its DNS settings can be used.", []string{"default", "Sign in"})
+	t.mu.Lock()
+	t.notified[network.AuthURL] = id
+	t.actions[id] = network.AuthURL
+	t.mu.Unlock()
+}
+
+// notify sends a desktop notification and returns its id (0 if it could not be shown)
+func (t *tray) notify(summary, body string, actions []string) uint32 {
+	if actions == nil {
+		actions = []string{}
+	}
+	var id uint32
+	err := t.session.Object(notificationsAPI, "/org/freedesktop/Notifications").Call(notificationsAPI+".Notify", 0,
+		"zeroplex", uint32(0), "network-vpn", summary, body, actions, map[string]dbus.Variant{}, int32(-1)).Store(&id)
+	if err != nil {
+		t.logger.Debug("Failed to show notification: %v", err)
+	}
+	return id
+}
+
+// watchNotificationActions opens the SSO URL when a sign-in notification is clicked
+func (t *tray) watchNotificationActions() {
+	if err := t.session.AddMatchSignal(
+		dbus.WithMatchInterface(notificationsAPI),
+		dbus.WithMatchMember("ActionInvoked"),
+	); err != nil {
+		t.logger.Debug("Not watching notification actions: %v", err)
+		return
+	}
+	signals := make(chan *dbus.Signal, 10)
+	t.session.Signal(signals)
+	go func() {
+		for sig := range signals {
+			if sig.Name != notificationsAPI+".ActionInvoked" || len(sig.Body) < 1 {
+				continue
+			}
+			id, _ := sig.Body[0].(uint32)
+			t.mu.Lock()
+			url, ok := t.actions[id]
+			t.mu.Unlock()
+			if ok {
+				t.open(url)
+			}
+		}
+	}()
+}
+
+// watchDaemon refreshes as soon as the daemon announces a state change instead of waiting for the next poll
+func (t *tray) watchDaemon(system *dbus.Conn) {
+	if err := system.AddMatchSignal(
+		dbus.WithMatchInterface(bus.Interface),
+		dbus.WithMatchMember(bus.SignalStateChanged),
+	); err != nil {
+		t.logger.Debug("Not watching %s signals, polling only: %v", bus.Interface, err)
+		return
+	}
+	signals := make(chan *dbus.Signal, 10)
+	system.Signal(signals)
+	go func() {
+		for sig := range signals {
+			if sig.Name == bus.Interface+"."+bus.SignalStateChanged {
+				t.requestRefresh()
+			}
+		}
+	}()
+}
+
+func networkName(network bus.Network) string {
+	if network.Name != "" {
+		return network.Name
+	}
+	return network.ID
+}
+
+// networkLine describes one network for the tooltip and menu
+func networkLine(network bus.Network) string {
+	line := networkName(network)
+	if network.Interface != "" {
+		line += " (" + network.Interface + ")"
+	}
+	switch {
+	case network.NeedsAuthentication():
+		return line + ": sign-in required"
+	case network.Status != "" && network.Status != "OK":
+		return line + ": " + strings.ToLower(strings.ReplaceAll(network.Status, "_", " "))
+	case len(network.Servers) == 0:
+		return line + ": no DNS pushed"
+	}
+	if network.Domain != "" {
+		line += ": " + network.Domain
+	}
+	if network.Managed {
+		return line + " (managed)"
+	}
+	return line + " (not applied)"
+}
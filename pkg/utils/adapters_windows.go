@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	iphlpapi                        = windows.NewLazySystemDLL("iphlpapi.dll")
+	procConvertInterfaceLuidToNameW = iphlpapi.NewProc("ConvertInterfaceLuidToNameW")
+)
+
+// WindowsAdapter is a network adapter as listed by GetAdaptersAddresses
+type WindowsAdapter struct {
+	Name         string // interface name, e.g. ethernet_32771, which ZeroTier reports as the port device
+	GUID         string // {...}, the key of the adapter's TCP/IP parameters in the registry
+	FriendlyName string // the name shown in the control panel and taken by netsh
+	Description  string
+	Index        int
+	Up           bool
+}
+
+// ZeroTier reports whether the adapter is a ZeroTier virtual port
+func (a WindowsAdapter) ZeroTier() bool {
+	return strings.Contains(a.Description, "ZeroTier")
+}
+
+// WindowsAdapters lists the network adapters of the system
+func WindowsAdapters() ([]WindowsAdapter, error) {
+	size := uint32(15000)
+	for {
+		buf := make([]byte, size)
+		first := (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0]))
+		err := windows.GetAdaptersAddresses(windows.AF_UNSPEC, 0, 0, first, &size)
+		if err == windows.ERROR_BUFFER_OVERFLOW {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list network adapters: %w", err)
+		}
+		var adapters []WindowsAdapter
+		for a := first; a != nil; a = a.Next {
+			adapters = append(adapters, WindowsAdapter{
+				Name:         luidName(a.Luid),
+				GUID:         windows.BytePtrToString(a.AdapterName),
+				FriendlyName: windows.UTF16PtrToString(a.FriendlyName),
+				Description:  windows.UTF16PtrToString(a.Description),
+				Index:        int(a.IfIndex),
+				Up:           a.OperStatus == windows.IfOperStatusUp,
+			})
+		}
+		return adapters, nil
+	}
+}
+
+// FindWindowsAdapter returns the adapter with the given interface name, friendly name or GUID
+func FindWindowsAdapter(name string) (WindowsAdapter, error) {
+	adapters, err := WindowsAdapters()
+	if err != nil {
+		return WindowsAdapter{}, err
+	}
+	for _, a := range adapters {
+		if strings.EqualFold(a.Name, name) || strings.EqualFold(a.FriendlyName, name) || strings.EqualFold(a.GUID, name) {
+			return a, nil
+		}
+	}
+	return WindowsAdapter{}, fmt.Errorf("no network adapter named %s", name)
+}
+
+// luidName returns the interface name of an adapter, empty when it can't be converted
+func luidName(luid uint64) string {
+	if procConvertInterfaceLuidToNameW.Find() != nil {
+		return ""
+	}
+	var buf [256]uint16
+	r, _, _ := procConvertInterfaceLuidToNameW.Call(uintptr(unsafe.Pointer(&luid)), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	if r != 0 {
+		return ""
+	}
+	return windows.UTF16ToString(buf[:])
+}
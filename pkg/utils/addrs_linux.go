@@ -0,0 +1,33 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package utils
+
+import (
+	"net"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// UnusableAddresses returns the IPv6 addresses of the interface with the given index that can't
+// be used yet, or ever: those still tentative while duplicate address detection runs, and those
+// it found in use elsewhere
+func UnusableAddresses(index int) ([]net.IP, error) {
+	link, err := netlink.LinkByIndex(index)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := netlink.AddrList(link, netlink.FAMILY_V6)
+	if err != nil {
+		return nil, err
+	}
+	var unusable []net.IP
+	for _, addr := range addrs {
+		if addr.Flags&(unix.IFA_F_TENTATIVE|unix.IFA_F_DADFAILED) != 0 {
+			unusable = append(unusable, addr.IP)
+		}
+	}
+	return unusable, nil
+}
@@ -0,0 +1,16 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !linux
+
+package utils
+
+import (
+	"net"
+)
+
+// UnusableAddresses is only known on Linux; elsewhere every address is taken as usable
+func UnusableAddresses(index int) ([]net.IP, error) {
+	return nil, nil
+}
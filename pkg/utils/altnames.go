@@ -0,0 +1,46 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package utils
+
+import (
+	"net"
+	"runtime"
+
+	"github.com/vishvananda/netlink"
+)
+
+// LinkNames returns the current name and the altnames of the interface known as name. The kernel
+// also matches altnames, so an interface renamed by udev is still found under a name it kept as
+// an altname. Elsewhere than on Linux interfaces have no altnames.
+func LinkNames(name string) (string, []string, error) {
+	if runtime.GOOS != "linux" {
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			return "", nil, err
+		}
+		return iface.Name, nil, nil
+	}
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return "", nil, err
+	}
+	return link.Attrs().Name, link.Attrs().AltNames, nil
+}
+
+// LinkNamesByIndex returns the current name and the altnames of the interface with ifindex index
+func LinkNamesByIndex(index int) (string, []string, error) {
+	if runtime.GOOS != "linux" {
+		iface, err := net.InterfaceByIndex(index)
+		if err != nil {
+			return "", nil, err
+		}
+		return iface.Name, nil, nil
+	}
+	link, err := netlink.LinkByIndex(index)
+	if err != nil {
+		return "", nil, err
+	}
+	return link.Attrs().Name, link.Attrs().AltNames, nil
+}
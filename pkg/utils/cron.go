@@ -0,0 +1,122 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronWindow is a time window written as a cron expression: every minute the expression matches is
+// inside the window, so "* 9-17 * * 1-5" covers 09:00 to 17:59 on weekdays
+type CronWindow struct {
+	expr                          string
+	minute, hour, dom, month, dow uint64 // bit n set: value n matches
+	domAny, dowAny                bool
+}
+
+var cronMonths = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+var cronDays = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+
+// ParseCronWindow parses a five-field cron expression (minute, hour, day of month, month, day of
+// week). Fields take "*", values, ranges ("9-17"), steps ("*/15", "0-30/10") and comma-separated
+// lists; months and days of the week also take their three-letter English names, and Sunday is 0
+// or 7. As in cron, when both day fields are restricted a day matching either is inside the window.
+func ParseCronWindow(expr string) (CronWindow, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return CronWindow{}, fmt.Errorf("invalid cron expression %q: want 5 fields (minute hour day-of-month month day-of-week), got %d", expr, len(fields))
+	}
+	w := CronWindow{expr: expr, domAny: fields[2] == "*", dowAny: fields[4] == "*"}
+	var err error
+	if w.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return CronWindow{}, fmt.Errorf("invalid cron expression %q: minute: %w", expr, err)
+	}
+	if w.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return CronWindow{}, fmt.Errorf("invalid cron expression %q: hour: %w", expr, err)
+	}
+	if w.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return CronWindow{}, fmt.Errorf("invalid cron expression %q: day of month: %w", expr, err)
+	}
+	if w.month, err = parseCronField(fields[3], 1, 12, cronMonths); err != nil {
+		return CronWindow{}, fmt.Errorf("invalid cron expression %q: month: %w", expr, err)
+	}
+	if w.dow, err = parseCronField(fields[4], 0, 7, cronDays); err != nil {
+		return CronWindow{}, fmt.Errorf("invalid cron expression %q: day of week: %w", expr, err)
+	}
+	if w.dow&(1<<7) != 0 {
+		w.dow |= 1
+	}
+	return w, nil
+}
+
+// Contains reports whether t falls inside the window
+func (w CronWindow) Contains(t time.Time) bool {
+	if w.minute&(1<<uint(t.Minute())) == 0 || w.hour&(1<<uint(t.Hour())) == 0 || w.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	domMatch := w.dom&(1<<uint(t.Day())) != 0
+	dowMatch := w.dow&(1<<uint(t.Weekday())) != 0
+	if w.domAny || w.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// String returns the expression the window was parsed from
+func (w CronWindow) String() string {
+	return w.expr
+}
+
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+
+		lo, hi := min, max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = parseCronValue(from, min, max, names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = parseCronValue(to, min, max, names); err != nil {
+					return 0, err
+				}
+				if hi < lo {
+					return 0, fmt.Errorf("invalid range %q", rangePart)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func parseCronValue(value string, min, max int, names map[string]int) (int, error) {
+	if n, ok := names[strings.ToLower(value)]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("invalid value %q (must be %d-%d)", value, min, max)
+	}
+	return n, nil
+}
@@ -10,11 +10,10 @@ import (
 	"zeroplex/pkg/log"
 
 	"github.com/vishvananda/netlink"
-	"golang.org/x/sys/unix"
 )
 
 // InterfaceEventType represents the type of interface event
-// (add, remove, up, down)
+// (add, remove, up, down, rename)
 type InterfaceEventType string
 
 const (
@@ -22,76 +21,33 @@ const (
 	InterfaceRemoved InterfaceEventType = "removed"
 	InterfaceUp      InterfaceEventType = "up"
 	InterfaceDown    InterfaceEventType = "down"
+	InterfaceRenamed InterfaceEventType = "renamed"
 )
 
 // InterfaceEvent represents an interface event
 // Name: interface name, Type: event type
 // Index: interface index
 // Link: netlink.Link object (may be nil for removed)
+// OldName: previous name, set for renamed
+// ZeroTier: set by watchers that tell ZeroTier adapters apart by more than the name (Windows)
 type InterfaceEvent struct {
-	Name  string
-	Type  InterfaceEventType
-	Index int
-	Link  netlink.Link
-}
-
-// WatchInterfacesNetlink watches for interface add/remove/up/down events using netlink.
-// Calls the callback for each event.
-func WatchInterfacesNetlink(callback func(InterfaceEvent), stopCh <-chan struct{}, logLevel string) error {
-	logger := log.NewScopedLogger("[interface_watch]", logLevel)
-	logger.Verbose("Netlink watcher started")
-	ch := make(chan netlink.LinkUpdate)
-	done := make(chan struct{})
-	if err := netlink.LinkSubscribe(ch, done); err != nil {
-		logger.Error("Netlink LinkSubscribe failed: %v", err)
-		return err
-	}
-	go func() {
-		for {
-			select {
-			case update := <-ch:
-				// Only log [event-raw] at TRACE level for non-ZeroTier interfaces
-				if logLevel == "trace" && update.Link.Attrs().Name[:2] != "zt" && update.Link.Attrs().Name[:3] != "ZT" {
-					logger.Trace("[event-raw] LinkUpdate: Name=%s, Index=%d, Type=%d, OperState=%s, Flags=%v, Change=%v", update.Link.Attrs().Name, update.Link.Attrs().Index, update.Header.Type, update.Link.Attrs().OperState, update.Link.Attrs().Flags, update.Change)
-				} else if logLevel == "debug" || logLevel == "trace" {
-					// For ZeroTier interfaces or higher log levels, keep as Debug
-					logger.Debug("[event-raw] LinkUpdate: Name=%s, Index=%d, Type=%d, OperState=%s, Flags=%v, Change=%v", update.Link.Attrs().Name, update.Link.Attrs().Index, update.Header.Type, update.Link.Attrs().OperState, update.Link.Attrs().Flags, update.Change)
-				}
-				var eventType InterfaceEventType
-				if update.Header.Type == unix.RTM_DELLINK {
-					eventType = InterfaceRemoved
-				} else if update.Header.Type == unix.RTM_NEWLINK {
-					if update.Link.Attrs().OperState == netlink.OperUp {
-						eventType = InterfaceUp
-					} else {
-						eventType = InterfaceDown
-					}
-				}
-				logger.Debug("[event] EventType=%s, Name=%s, Index=%d, OperState=%s", eventType, update.Link.Attrs().Name, update.Link.Attrs().Index, update.Link.Attrs().OperState)
-				callback(InterfaceEvent{
-					Name:  update.Link.Attrs().Name,
-					Type:  eventType,
-					Index: update.Link.Attrs().Index,
-					Link:  update.Link,
-				})
-			case <-stopCh:
-				close(done)
-				logger.Verbose("Netlink watcher stopped")
-				return
-			}
-		}
-	}()
-	return nil
+	Name     string
+	Type     InterfaceEventType
+	Index    int
+	Link     netlink.Link
+	OldName  string
+	ZeroTier bool
 }
 
 // PollInterfaces periodically lists interfaces and calls the callback for add/remove events.
 // interval: polling interval
 type InterfacePollState struct {
-	Known map[string]struct{}
+	Known   map[string]struct{}
+	Indexes map[string]int
 }
 
 func NewInterfacePollState() *InterfacePollState {
-	return &InterfacePollState{Known: make(map[string]struct{})}
+	return &InterfacePollState{Known: make(map[string]struct{}), Indexes: make(map[string]int)}
 }
 
 func PollInterfaces(interval time.Duration, callback func(InterfaceEvent), stopCh <-chan struct{}, logLevel string) {
@@ -113,13 +69,28 @@ func PollInterfaces(interval time.Duration, callback func(InterfaceEvent), stopC
 			for _, link := range links {
 				current[link.Attrs().Name] = link
 			}
-			// Detect added
+			// Names that disappeared, by the index they had, so a new name on that index is a rename
+			gone := make(map[int]string)
+			for name := range state.Known {
+				if _, ok := current[name]; !ok {
+					gone[state.Indexes[name]] = name
+				}
+			}
+			// Detect added and renamed
 			for name, link := range current {
 				if _, ok := state.Known[name]; !ok {
-					logger.Debug("Event: added %s (index %d)", name, link.Attrs().Index)
-					callback(InterfaceEvent{Name: name, Type: InterfaceAdded, Index: link.Attrs().Index, Link: link})
+					if old, ok := gone[link.Attrs().Index]; ok {
+						logger.Debug("Event: renamed %s to %s (index %d)", old, name, link.Attrs().Index)
+						callback(InterfaceEvent{Name: name, Type: InterfaceRenamed, Index: link.Attrs().Index, Link: link, OldName: old})
+						delete(state.Known, old)
+						delete(state.Indexes, old)
+					} else {
+						logger.Debug("Event: added %s (index %d)", name, link.Attrs().Index)
+						callback(InterfaceEvent{Name: name, Type: InterfaceAdded, Index: link.Attrs().Index, Link: link})
+					}
 				}
 				state.Known[name] = struct{}{}
+				state.Indexes[name] = link.Attrs().Index
 			}
 			// Detect removed
 			for name := range state.Known {
@@ -127,6 +98,7 @@ func PollInterfaces(interval time.Duration, callback func(InterfaceEvent), stopC
 					logger.Debug("Event: removed %s", name)
 					callback(InterfaceEvent{Name: name, Type: InterfaceRemoved, Index: 0, Link: nil})
 					delete(state.Known, name)
+					delete(state.Indexes, name)
 				}
 			}
 		}
@@ -0,0 +1,71 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package utils
+
+import (
+	"zeroplex/pkg/log"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// WatchInterfacesNetlink watches for interface add/remove/up/down events using netlink.
+// Calls the callback for each event.
+func WatchInterfacesNetlink(callback func(InterfaceEvent), stopCh <-chan struct{}, logLevel string) error {
+	logger := log.NewScopedLogger("[interface_watch]", logLevel)
+	logger.Verbose("Netlink watcher started")
+	ch := make(chan netlink.LinkUpdate)
+	done := make(chan struct{})
+	if err := netlink.LinkSubscribe(ch, done); err != nil {
+		logger.Error("Netlink LinkSubscribe failed: %v", err)
+		return err
+	}
+	go func() {
+		// Names by ifindex, to tell a rename apart from other link changes
+		names := make(map[int]string)
+		for {
+			select {
+			case update := <-ch:
+				// Only log [event-raw] at TRACE level for non-ZeroTier interfaces
+				if logLevel == "trace" && update.Link.Attrs().Name[:2] != "zt" && update.Link.Attrs().Name[:3] != "ZT" {
+					logger.Trace("[event-raw] LinkUpdate: Name=%s, Index=%d, Type=%d, OperState=%s, Flags=%v, Change=%v", update.Link.Attrs().Name, update.Link.Attrs().Index, update.Header.Type, update.Link.Attrs().OperState, update.Link.Attrs().Flags, update.Change)
+				} else if logLevel == "debug" || logLevel == "trace" {
+					// For ZeroTier interfaces or higher log levels, keep as Debug
+					logger.Debug("[event-raw] LinkUpdate: Name=%s, Index=%d, Type=%d, OperState=%s, Flags=%v, Change=%v", update.Link.Attrs().Name, update.Link.Attrs().Index, update.Header.Type, update.Link.Attrs().OperState, update.Link.Attrs().Flags, update.Change)
+				}
+				var eventType InterfaceEventType
+				var oldName string
+				index, name := update.Link.Attrs().Index, update.Link.Attrs().Name
+				if update.Header.Type == unix.RTM_DELLINK {
+					eventType = InterfaceRemoved
+					delete(names, index)
+				} else if update.Header.Type == unix.RTM_NEWLINK {
+					if known, ok := names[index]; ok && known != name {
+						eventType = InterfaceRenamed
+						oldName = known
+					} else if update.Link.Attrs().OperState == netlink.OperUp {
+						eventType = InterfaceUp
+					} else {
+						eventType = InterfaceDown
+					}
+					names[index] = name
+				}
+				logger.Debug("[event] EventType=%s, Name=%s, Index=%d, OperState=%s", eventType, update.Link.Attrs().Name, update.Link.Attrs().Index, update.Link.Attrs().OperState)
+				callback(InterfaceEvent{
+					Name:    name,
+					Type:    eventType,
+					Index:   index,
+					Link:    update.Link,
+					OldName: oldName,
+				})
+			case <-stopCh:
+				close(done)
+				logger.Verbose("Netlink watcher stopped")
+				return
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,18 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !linux && !windows
+
+package utils
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// WatchInterfacesNetlink is only available on Linux; elsewhere it fails so callers fall back to
+// PollInterfaces
+func WatchInterfacesNetlink(callback func(InterfaceEvent), stopCh <-chan struct{}, logLevel string) error {
+	return fmt.Errorf("netlink interface events are not available on %s", runtime.GOOS)
+}
@@ -0,0 +1,103 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package utils
+
+import (
+	"zeroplex/pkg/log"
+
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	procNotifyIpInterfaceChange = iphlpapi.NewProc("NotifyIpInterfaceChange")
+	procCancelMibChangeNotify2  = iphlpapi.NewProc("CancelMibChangeNotify2")
+)
+
+// WatchInterfacesNetlink watches for interface events on Windows, which has no netlink. It asks
+// for NotifyIpInterfaceChange notifications and turns each one into events by comparing the
+// adapter list with the one seen before.
+func WatchInterfacesNetlink(callback func(InterfaceEvent), stopCh <-chan struct{}, logLevel string) error {
+	logger := log.NewScopedLogger("[interface_watch]", logLevel)
+	if err := procNotifyIpInterfaceChange.Find(); err != nil {
+		return fmt.Errorf("interface notifications are not available: %w", err)
+	}
+	known, err := WindowsAdapters()
+	if err != nil {
+		return err
+	}
+
+	// The notification runs on a system thread; only signal the watcher goroutine from it
+	changed := make(chan struct{}, 1)
+	notify := windows.NewCallback(func(callerContext, row, notificationType uintptr) uintptr {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+		return 0
+	})
+	var handle windows.Handle
+	if r, _, _ := procNotifyIpInterfaceChange.Call(windows.AF_UNSPEC, notify, 0, 0, uintptr(unsafe.Pointer(&handle))); r != 0 {
+		logger.Error("NotifyIpInterfaceChange failed: %v", windows.Errno(r))
+		return fmt.Errorf("NotifyIpInterfaceChange failed: %w", windows.Errno(r))
+	}
+	logger.Verbose("Interface notification watcher started")
+
+	go func() {
+		defer procCancelMibChangeNotify2.Call(uintptr(handle))
+		for {
+			select {
+			case <-changed:
+				current, err := WindowsAdapters()
+				if err != nil {
+					logger.Warn("Failed to list adapters after a notification: %v", err)
+					continue
+				}
+				for _, ev := range adapterEvents(known, current) {
+					logger.Debug("[event] EventType=%s, Name=%s, Index=%d", ev.Type, ev.Name, ev.Index)
+					callback(ev)
+				}
+				known = current
+			case <-stopCh:
+				logger.Verbose("Interface notification watcher stopped")
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// adapterEvents compares two adapter lists by ifindex
+func adapterEvents(before, after []WindowsAdapter) []InterfaceEvent {
+	previous := make(map[int]WindowsAdapter, len(before))
+	for _, a := range before {
+		previous[a.Index] = a
+	}
+	var events []InterfaceEvent
+	for _, a := range after {
+		ev := InterfaceEvent{Name: a.Name, Index: a.Index, ZeroTier: a.ZeroTier()}
+		old, ok := previous[a.Index]
+		delete(previous, a.Index)
+		switch {
+		case !ok:
+			ev.Type = InterfaceAdded
+		case old.Name != a.Name:
+			ev.Type, ev.OldName = InterfaceRenamed, old.Name
+		case old.Up != a.Up && a.Up:
+			ev.Type = InterfaceUp
+		case old.Up != a.Up:
+			ev.Type = InterfaceDown
+		default:
+			continue
+		}
+		events = append(events, ev)
+	}
+	for _, a := range previous {
+		events = append(events, InterfaceEvent{Name: a.Name, Type: InterfaceRemoved, Index: a.Index, ZeroTier: a.ZeroTier()})
+	}
+	return events
+}
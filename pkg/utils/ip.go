@@ -0,0 +1,73 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package utils
+
+import (
+	"net"
+	"strings"
+)
+
+// ParseIP parses an IPv4 or IPv6 address as it is written in the configuration, in ZeroTier's
+// assigned addresses or in DNS answers: with or without a prefix length, and with a zone for a
+// link-local IPv6 address. It returns nil if s is not an address.
+func ParseIP(s string) net.IP {
+	s = strings.TrimSpace(s)
+	s = strings.SplitN(s, "/", 2)[0]
+	s = strings.SplitN(s, "%", 2)[0]
+	return net.ParseIP(strings.Trim(s, "[]"))
+}
+
+// IsIPv6 reports whether s is an IPv6 address, taking an IPv4-mapped one as IPv4
+func IsIPv6(s string) bool {
+	ip := ParseIP(s)
+	return ip != nil && ip.To4() == nil
+}
+
+// SplitIPs splits a comma separated list of addresses, dropping empty entries
+func SplitIPs(s string) []string {
+	var ips []string
+	for _, ip := range strings.Split(s, ",") {
+		if ip = strings.TrimSpace(ip); ip != "" {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// ContainsIP reports whether ip is one of list, comparing the addresses rather than how they are
+// written, so fd00::1 matches fd00:0:0::0001 and 10.0.0.1 matches ::ffff:10.0.0.1
+func ContainsIP(list []string, ip string) bool {
+	parsed := ParseIP(ip)
+	if parsed == nil {
+		return Contains(list, ip)
+	}
+	for _, item := range list {
+		if other := ParseIP(item); other != nil && other.Equal(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// LookupNetwork returns the network to resolve a name for when it is expected to resolve to one
+// of expected: "ip6" to ask only for AAAA records if they are all IPv6 addresses, "ip4" to ask
+// only for A records if they are all IPv4 addresses, and "ip" for both otherwise
+func LookupNetwork(expected []string) string {
+	v4, v6 := false, false
+	for _, ip := range expected {
+		if IsIPv6(ip) {
+			v6 = true
+		} else {
+			v4 = true
+		}
+	}
+	switch {
+	case v6 && !v4:
+		return "ip6"
+	case v4 && !v6:
+		return "ip4"
+	}
+	return "ip"
+}
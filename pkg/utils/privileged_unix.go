@@ -0,0 +1,14 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !windows
+
+package utils
+
+import "os"
+
+// IsPrivileged reports whether the process runs as root
+func IsPrivileged() bool {
+	return os.Geteuid() == 0
+}
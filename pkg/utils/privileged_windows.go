@@ -0,0 +1,13 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package utils
+
+import "golang.org/x/sys/windows"
+
+// IsPrivileged reports whether the process runs elevated, as changing adapter DNS settings needs
+// administrator rights
+func IsPrivileged() bool {
+	return windows.GetCurrentProcessToken().IsElevated()
+}
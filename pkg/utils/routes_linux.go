@@ -0,0 +1,30 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package utils
+
+import (
+	"net"
+
+	"github.com/vishvananda/netlink"
+)
+
+// InterfaceRoutes returns the destinations of the routes through the interface with the given index
+func InterfaceRoutes(index int) ([]*net.IPNet, error) {
+	link, err := netlink.LinkByIndex(index)
+	if err != nil {
+		return nil, err
+	}
+	routes, err := netlink.RouteList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return nil, err
+	}
+	var dsts []*net.IPNet
+	for _, route := range routes {
+		if route.Dst != nil {
+			dsts = append(dsts, route.Dst)
+		}
+	}
+	return dsts, nil
+}
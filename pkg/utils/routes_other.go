@@ -0,0 +1,18 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !linux
+
+package utils
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+)
+
+// InterfaceRoutes is only available on Linux; elsewhere it fails
+func InterfaceRoutes(index int) ([]*net.IPNet, error) {
+	return nil, fmt.Errorf("reading the routing table is not available on %s", runtime.GOOS)
+}
@@ -5,10 +5,15 @@
 package utils
 
 import (
+	"zeroplex/pkg/exitcode"
+
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
+	"runtime"
 	"strings"
+	"time"
 )
 
 func Contains(slice []string, value string) bool {
@@ -43,12 +48,6 @@ func ExecuteCommand(name string, args ...string) (string, error) {
 	return string(output), nil
 }
 
-func ServiceExists(serviceName string) bool {
-	cmd := exec.Command("systemctl", "status", serviceName)
-	err := cmd.Run()
-	return err == nil
-}
-
 func ParseResolvectlOutput(output string, prefix string) []string {
 	parsed := []string{}
 	lines := strings.Split(strings.TrimSpace(output), "\n")
@@ -78,7 +77,21 @@ func FormatSliceDebug(slice []string, isExcludeFilter bool) string {
 	return strings.Join(slice, ", ")
 }
 
+// ErrorHandler prints an error and, when exit is set, exits with the generic failure code
 func ErrorHandler(context string, err error, exit bool) {
+	printError(context, err)
+	if exit {
+		os.Exit(exitcode.Failure)
+	}
+}
+
+// ExitWithError prints an error and exits with code (see package exitcode)
+func ExitWithError(context string, err error, code int) {
+	printError(context, err)
+	os.Exit(code)
+}
+
+func printError(context string, err error) {
 	if err != nil {
 		if os.IsNotExist(err) {
 			fmt.Fprintf(os.Stderr, "ERROR: %s: %v\n", context, err)
@@ -92,17 +105,31 @@ func ErrorHandler(context string, err error, exit bool) {
 	} else if context != "" {
 		fmt.Fprintf(os.Stderr, "ERROR: %s\n", context)
 	}
-
-	if exit {
-		os.Exit(1)
-	}
 }
 
-// Ping returns true if the given IP responds to a single ICMP echo request (ping)
+// Ping returns true if the given IP responds to a single ICMP echo request (ping). An IPv6
+// address is pinged with ping -6, which the ping of iputils and busybox both take, or with ping6
+// outside Linux, where ping is IPv4 only; a link-local one needs its zone, as in fe80::1%zt0.
 func Ping(ip string) bool {
-	cmd := exec.Command("ping", "-c", "1", "-W", "2", ip)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	args := pingCommand(ip)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
 	if err := cmd.Run(); err != nil {
 		return false
 	}
 	return true
 }
+
+// pingCommand returns the command sending one echo request to ip. Outside Linux the -W flags of
+// ping6 disagree, so the time to wait for the reply is only bounded by Ping's context there.
+func pingCommand(ip string) []string {
+	switch {
+	case !IsIPv6(ip):
+		return []string{"ping", "-c", "1", "-W", "2", ip}
+	case runtime.GOOS == "linux":
+		return []string{"ping", "-6", "-c", "1", "-W", "2", ip}
+	default:
+		return []string{"ping6", "-c", "1", ip}
+	}
+}
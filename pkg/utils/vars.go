@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: © 2025 Nfrastack <code@nfrastack.com>
+//
+// SPDX-License-Identifier: BSD-3-Clause
+
+package utils
+
+import (
+	"os"
+	"strings"
+)
+
+// Vars are the values substituted for %name% placeholders in user supplied strings such as
+// watchdog targets, webhook URLs and extra search domains
+type Vars struct {
+	Domain    string // %domain%: the ZeroTier DNS domain of the network
+	Interface string // %interface%: the ZeroTier interface name
+	NetworkID string // %network_id%: the ZeroTier network ID
+	Hostname  string // %hostname%: the local hostname
+}
+
+// networkVarNames are the placeholders that only have a value in the context of a single network
+var networkVarNames = []string{"%domain%", "%interface%", "%network_id%"}
+
+// HostVars returns the variables that do not depend on a network
+func HostVars() Vars {
+	hostname, _ := os.Hostname()
+	return Vars{Hostname: hostname}
+}
+
+// NetworkVars returns the variables for a single network on this host
+func NetworkVars(iface, networkID, domain string) Vars {
+	v := HostVars()
+	v.Interface = iface
+	v.NetworkID = networkID
+	v.Domain = strings.TrimSuffix(domain, ".")
+	return v
+}
+
+func (v Vars) values() map[string]string {
+	return map[string]string{
+		"%domain%":     v.Domain,
+		"%interface%":  v.Interface,
+		"%network_id%": v.NetworkID,
+		"%hostname%":   v.Hostname,
+	}
+}
+
+// Expand replaces every known placeholder in s; unknown %...% sequences are left untouched
+func (v Vars) Expand(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	pairs := []string{}
+	for name, value := range v.values() {
+		pairs = append(pairs, name, value)
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
+
+// ExpandStrict is like Expand but reports false if s references a variable that has no value,
+// so callers can skip e.g. a per-network search domain for a network without a DNS domain
+func (v Vars) ExpandStrict(s string) (string, bool) {
+	for name, value := range v.values() {
+		if value == "" && strings.Contains(s, name) {
+			return "", false
+		}
+	}
+	return v.Expand(s), true
+}
+
+// HasNetworkVars reports whether s references a per-network variable and so must be expanded
+// once per network rather than once per host
+func HasNetworkVars(s string) bool {
+	for _, name := range networkVarNames {
+		if strings.Contains(s, name) {
+			return true
+		}
+	}
+	return false
+}